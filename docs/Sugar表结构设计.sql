@@ -38,6 +38,22 @@ CREATE TABLE `sugar_team_members` (
     FOREIGN KEY (`team_id`) REFERENCES `sugar_teams`(`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户与团队的多对多关系表';
 
+-- API令牌表: 外部Agent通过MCP等接口以用户身份访问Sugar，只保存令牌摘要
+CREATE TABLE `sugar_api_tokens` (
+    `id` CHAR(36) NOT NULL,
+    `user_id` VARCHAR(20) NOT NULL COMMENT '令牌所属用户',
+    `name` VARCHAR(100) NULL COMMENT '令牌名称',
+    `token_hash` CHAR(64) NOT NULL COMMENT '令牌SHA-256摘要',
+    `token_prefix` VARCHAR(16) NULL COMMENT '令牌前缀, 用于识别',
+    `expires_at` TIMESTAMP NULL DEFAULT NULL COMMENT '过期时间, 为空表示永不过期',
+    `last_used_at` TIMESTAMP NULL DEFAULT NULL COMMENT '最近使用时间',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_token_hash` (`token_hash`),
+    INDEX `idx_sugar_api_tokens_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储外部Agent访问Sugar使用的API令牌';
+
 -- =================================================================
 -- Section 2: Resource and Content Management (Simplified)
 -- 资源与内容管理 (简化后)
@@ -51,13 +67,18 @@ CREATE TABLE `sugar_workspaces` (
     `parent_id` CHAR(36) NULL,
     `team_id` CHAR(36) NOT NULL COMMENT '资源统一归属于团队',
     `content` JSON NULL,
+    `revision` INTEGER NOT NULL DEFAULT 0 COMMENT '内容修订号, 每次写入内容时递增, 用于乐观并发控制',
+    `operation_revision` INTEGER NOT NULL DEFAULT 0 COMMENT '内容快照已包含的协作操作修订号',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_by` VARCHAR(20) NULL,
     `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+    `deleted_by` VARCHAR(20) NULL COMMENT '删除者',
+    `deleted_root` CHAR(36) NULL COMMENT '随哪个项目一起被删除, 用户直接删除的项目为自身ID',
     PRIMARY KEY (`id`),
     UNIQUE KEY `uk_parent_name_team` (`parent_id`, `team_id`, `name`),
+    INDEX `idx_sugar_workspaces_deleted_root` (`deleted_root`),
     INDEX `idx_sugar_workspaces_team_id` (`team_id`),
     FOREIGN KEY (`parent_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE,
     FOREIGN KEY (`team_id`) REFERENCES `sugar_teams`(`id`)
@@ -70,13 +91,33 @@ CREATE TABLE `sugar_file_versions` (
     `file_id` CHAR(36) NOT NULL,
     `version_number` INTEGER NOT NULL,
     `content` JSON NOT NULL,
+    `name` VARCHAR(100) NULL COMMENT '版本名称, 命名的版本不会被合并或清理',
+    `is_pinned` BOOLEAN NOT NULL DEFAULT FALSE COMMENT '是否置顶, 置顶的版本不会被合并或清理',
+    `source` VARCHAR(20) NULL COMMENT '版本来源: save/restore/baseline',
+    `restored_from` INTEGER NULL COMMENT '恢复自哪个版本号',
+    `save_count` INTEGER NOT NULL DEFAULT 1 COMMENT '合并的保存次数',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NULL DEFAULT NULL COMMENT '最后一次合并保存的时间',
     PRIMARY KEY (`id`),
     UNIQUE KEY `uk_file_version` (`file_id`, `version_number`),
     FOREIGN KEY (`file_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储文件的历史版本，用于版本回溯';
 
+-- 工作簿协作操作日志表 (sugar_workbook_operations)
+CREATE TABLE `sugar_workbook_operations` (
+    `id` BIGINT AUTO_INCREMENT NOT NULL,
+    `file_id` CHAR(36) NOT NULL,
+    `revision` INTEGER NOT NULL COMMENT '操作修订号, 同一文件内连续递增',
+    `command` JSON NOT NULL COMMENT 'Univer变更命令',
+    `session_id` CHAR(36) NULL COMMENT '提交操作的协作会话ID',
+    `created_by` VARCHAR(20) NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_file_operation` (`file_id`, `revision`),
+    FOREIGN KEY (`file_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='实时协作的操作日志，压缩为内容快照后删除';
+
 
 -- =================================================================
 -- Section 3: Sharing and Permissions
@@ -89,7 +130,7 @@ CREATE TABLE `sugar_workspace_permissions` (
     `workspace_id` CHAR(36) NOT NULL,
     `grantee_type` ENUM('user', 'team') NOT NULL COMMENT '授权对象类型',
     `grantee_id` VARCHAR(36) NOT NULL COMMENT 'user_id 或 team_id',
-    `permission_level` ENUM('editor', 'commenter', 'viewer') NOT NULL COMMENT '权限级别',
+    `permission_level` ENUM('editor', 'viewer') NOT NULL COMMENT '权限级别',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_by` VARCHAR(20) NULL,
@@ -110,6 +151,8 @@ CREATE TABLE `sugar_share_links` (
     `password_hash` VARCHAR(255) NULL,
     `expires_at` TIMESTAMP NULL DEFAULT NULL,
     `is_active` BOOLEAN NOT NULL DEFAULT true,
+    `freeze_data` BOOLEAN NOT NULL DEFAULT false COMMENT '是否冻结 SUGAR 公式结果',
+    `snapshot` JSON NULL COMMENT '冻结时保存的工作簿快照',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_by` VARCHAR(20) NULL,
@@ -120,6 +163,65 @@ CREATE TABLE `sugar_share_links` (
     FOREIGN KEY (`workspace_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储通过链接分享的配置';
 
+-- 工作簿模板表: 团队模板库
+CREATE TABLE `sugar_workbook_templates` (
+    `id` BIGINT AUTO_INCREMENT NOT NULL,
+    `team_id` CHAR(36) NOT NULL COMMENT '所属团队',
+    `name` VARCHAR(255) NOT NULL,
+    `description` VARCHAR(500) NULL,
+    `source_file_id` CHAR(36) NULL COMMENT '生成模板的工作簿ID, 工作簿删除后模板仍保留',
+    `content` JSON NOT NULL COMMENT '模板内容快照, SUGAR 公式参数中可包含 {{name}} 占位符',
+    `parameters` JSON NULL COMMENT '模板参数定义: [{name, label, defaultValue}]',
+    `created_by` VARCHAR(20) NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_by` VARCHAR(20) NULL,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_team_name` (`team_id`, `name`),
+    INDEX `idx_sugar_workbook_templates_team_id` (`team_id`),
+    FOREIGN KEY (`team_id`) REFERENCES `sugar_teams`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储团队工作簿模板';
+
+-- 工作簿搜索文档表: 从工作簿内容中提取的工作表名、单元格文本和公式，随内容写入重建
+CREATE TABLE `sugar_workbook_search_documents` (
+    `file_id` CHAR(36) NOT NULL,
+    `entries` JSON NULL COMMENT '[{sheet, cell, text, formula}]',
+    `revision` INT NOT NULL DEFAULT 0 COMMENT '建立索引时的内容修订号',
+    `indexed_at` TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (`file_id`),
+    FOREIGN KEY (`file_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储工作簿搜索文档';
+
+-- 工作簿搜索词表: 倒排索引，英文和数字按词、中日韩文字按单字和相邻两字切分，公式引用的模型和智能体按名称整体索引
+CREATE TABLE `sugar_workbook_search_terms` (
+    `id` BIGINT AUTO_INCREMENT NOT NULL,
+    `file_id` CHAR(36) NOT NULL,
+    `kind` ENUM('text') NOT NULL,
+    `term` VARCHAR(191) NOT NULL COMMENT '小写的搜索词',
+    PRIMARY KEY (`id`),
+    INDEX `idx_sugar_workbook_search_terms_term` (`kind`, `term`),
+    INDEX `idx_sugar_workbook_search_terms_file_id` (`file_id`),
+    FOREIGN KEY (`file_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储工作簿搜索词';
+
+-- 工作簿引用表: 血缘关系中工作簿一端的边，单元格公式按名称引用的语义模型及其字段、智能体，随内容写入重建
+-- 连接 → 模型通过 sugar_semantic_models.connection_id 关联，模型 → 工作簿通过本表的 kind + name_key 关联
+CREATE TABLE `sugar_workbook_references` (
+    `id` BIGINT AUTO_INCREMENT NOT NULL,
+    `file_id` CHAR(36) NOT NULL,
+    `kind` ENUM('model', 'agent') NOT NULL,
+    `name_key` VARCHAR(100) NOT NULL COMMENT '小写的模型或智能体名称',
+    `name` VARCHAR(100) NOT NULL COMMENT '公式中书写的名称',
+    `column_name` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '引用的模型字段，无法确定时为空',
+    `function` VARCHAR(32) NOT NULL COMMENT '公式函数，如 SUGAR.GET',
+    `sheet` VARCHAR(100) NOT NULL DEFAULT '',
+    `cell` VARCHAR(20) NOT NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_sugar_workbook_references_target` (`kind`, `name_key`),
+    INDEX `idx_sugar_workbook_references_file_id` (`file_id`),
+    FOREIGN KEY (`file_id`) REFERENCES `sugar_workspaces`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储工作簿对语义模型和智能体的引用';
+
 
 -- =================================================================
 -- Section 3: Semantic Layer and Data Connectors
@@ -175,6 +277,9 @@ CREATE TABLE `sugar_semantic_models` (
     `parameter_config` JSON NOT NULL COMMENT '查询参数配置, 定义用户可用的筛选条件',
     `returnable_columns_config` JSON NOT NULL COMMENT '可返回字段配置, 定义用户可获取的数据列',
     `permission_key_column` VARCHAR(255) NULL COMMENT '用于行级权限判断的字段名, 如 city_code',
+    `anonymization_policy` JSON NULL COMMENT '匿名化策略, 定义敏感维度、必须编码的值、k/l 阈值、噪声级别及是否允许输出绝对值',
+    `anonymization_seed` BIGINT NULL COMMENT '确定性匿名化的噪声种子, 首次使用时生成, 不通过接口读写',
+    `analysis_semantics` JSON NULL COMMENT '分析口径, 声明期间维度与粒度、余额/发生额指标、期初期末指标对(balancePairs)及财年口径',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_by` VARCHAR(20) NULL,
@@ -208,6 +313,7 @@ CREATE TABLE `sugar_agents` (
     `agent_type` ENUM('system', 'custom') NOT NULL COMMENT '系统预置, 团队自定义',
     `team_id` CHAR(36) NOT NULL COMMENT '所有者团队ID',
     `endpoint_config` JSON NOT NULL COMMENT '定义 Agent 的调用方式, 如 API URL, headers 等',
+    `anonymization_policy` JSON NULL COMMENT '匿名化策略覆盖, 已设置的字段只能收紧语义模型上的策略',
     `created_by` VARCHAR(20) NULL,
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_by` VARCHAR(20) NULL,
@@ -287,9 +393,58 @@ CREATE TABLE `sugar_execution_logs` (
     `status` ENUM('pending', 'success', 'failed', 'timeout') NOT NULL,
     `result_summary` TEXT NULL,
     `duration_ms` INTEGER NULL,
+    `anonymization_session_id` VARCHAR(36) NULL COMMENT '关联的匿名化会话ID，用于重新解码匿名化输出',
+    `anonymization_policy` JSON NULL COMMENT '本次调用解析后生效的匿名化策略及各字段来源',
     `executed_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
     INDEX `idx_sugar_execution_logs_user_id` (`user_id`),
     INDEX `idx_sugar_execution_logs_workspace_id` (`workspace_id`),
-    INDEX `idx_sugar_execution_logs_type_status` (`log_type`, `status`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='记录所有高成本的后台任务执行，用于审计、计费和调试';
\ No newline at end of file
+    INDEX `idx_sugar_execution_logs_type_status` (`log_type`, `status`),
+    INDEX `idx_sugar_execution_logs_anonymization_session_id` (`anonymization_session_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='记录所有高成本的后台任务执行，用于审计、计费和调试';
+
+
+-- =================================================================
+-- Section 6: Anonymization and Privacy
+-- 匿名化与隐私预算
+-- =================================================================
+
+-- 匿名化会话表: 持久化AIFETCH的代号映射，用于审计时重新解码执行日志，以及同一对话的多轮复用代号
+CREATE TABLE `sugar_anonymization_sessions` (
+    `id` CHAR(36) NOT NULL,
+    `conversation_id` VARCHAR(64) NULL COMMENT '所属对话ID, 同一对话的多轮复用同一会话',
+    `user_id` VARCHAR(20) NULL COMMENT '创建会话的用户ID',
+    `agent_id` CHAR(36) NULL COMMENT '关联的AI Agent ID',
+    `encrypted_mapping` TEXT NULL COMMENT 'AES-GCM加密的代号映射, 超过保留期限后清空',
+    `config` JSON NULL COMMENT '匿名化配置',
+    `epsilon_spent` DOUBLE NOT NULL DEFAULT 0 COMMENT '累计消耗的差分隐私预算',
+    `mapping_count` INTEGER NOT NULL DEFAULT 0 COMMENT '代号映射数量',
+    `turn_count` INTEGER NOT NULL DEFAULT 0 COMMENT '使用该会话的分析轮数',
+    `expires_at` TIMESTAMP NULL DEFAULT NULL COMMENT '映射保留截止时间',
+    `purged_at` TIMESTAMP NULL DEFAULT NULL COMMENT '映射销毁时间',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `idx_sugar_anonymization_sessions_conversation_id` (`conversation_id`),
+    INDEX `idx_sugar_anonymization_sessions_user_id` (`user_id`),
+    INDEX `idx_sugar_anonymization_sessions_expires_at` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储加密的匿名化代号映射，用于合规审计';
+
+-- 隐私预算账本表: 按 用户 × 语义模型 × 时间窗口 累计AIFETCH消耗的差分隐私预算ε
+CREATE TABLE `sugar_privacy_budget_ledgers` (
+    `id` CHAR(36) NOT NULL,
+    `user_id` VARCHAR(20) NOT NULL,
+    `semantic_model_id` CHAR(36) NOT NULL,
+    `model_name` VARCHAR(100) NULL COMMENT '语义模型名称',
+    `window_start` TIMESTAMP NOT NULL COMMENT '时间窗口开始',
+    `window_end` TIMESTAMP NOT NULL COMMENT '时间窗口结束',
+    `epsilon_budget` DOUBLE NOT NULL COMMENT '窗口内的ε总预算',
+    `epsilon_spent` DOUBLE NOT NULL DEFAULT 0 COMMENT '已消耗的ε, 以条件更新扣减保证并发请求不超出预算',
+    `query_count` INTEGER NOT NULL DEFAULT 0 COMMENT '消耗预算的分析次数',
+    `coarsened_count` INTEGER NOT NULL DEFAULT 0 COMMENT '预算不足时降级为定性输出的次数',
+    `refused_count` INTEGER NOT NULL DEFAULT 0 COMMENT '预算不足时被拒绝的次数',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_privacy_budget_window` (`user_id`, `semantic_model_id`, `window_start`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='记录差分隐私预算的消耗，防止多次请求平均掉噪声';
\ No newline at end of file
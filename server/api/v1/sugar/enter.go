@@ -16,18 +16,41 @@ type ApiGroup struct {
 	SugarWorkspacesApi
 	SugarFormulaQueryApi
 	SugarFoldersApi
+	SugarApiTokensApi
+	SugarAnonymizationSessionsApi
+	SugarPrivacyBudgetApi
+	SugarFileVersionsApi
+	SugarWorkbookCollaborationApi
+	SugarWorkspacePermissionsApi
+	SugarShareLinksApi
+	SugarRecycleBinApi
+	SugarWorkbookTemplatesApi
+	SugarWorkbookSearchApi
+	SugarWorkbookLineageApi
 }
 
 var (
-	sugarTeamsService             = service.ServiceGroupApp.SugarServiceGroup.SugarTeamsService
-	sugarTeamMembersService       = service.ServiceGroupApp.SugarServiceGroup.SugarTeamMembersService
-	sugarDbConnectionsService     = service.ServiceGroupApp.SugarServiceGroup.SugarDbConnectionsService
-	sugarSemanticModelsService    = service.ServiceGroupApp.SugarServiceGroup.SugarSemanticModelsService
-	sugarAgentsService            = service.ServiceGroupApp.SugarServiceGroup.SugarAgentsService
-	sugarCityPermissionsService   = service.ServiceGroupApp.SugarServiceGroup.SugarCityPermissionsService
-	sugarRowLevelOverridesService = service.ServiceGroupApp.SugarServiceGroup.SugarRowLevelOverridesService
-	sugarExecutionLogsService     = service.ServiceGroupApp.SugarServiceGroup.SugarExecutionLogsService
-	sugarWorkspacesService        = service.ServiceGroupApp.SugarServiceGroup.SugarWorkspacesService
-	sugarFormulaQueryService      = service.ServiceGroupApp.SugarServiceGroup.SugarFormulaQueryService
-	sugarFoldersService           = service.ServiceGroupApp.SugarServiceGroup.SugarFoldersService
+	sugarTeamsService                 = service.ServiceGroupApp.SugarServiceGroup.SugarTeamsService
+	sugarTeamMembersService           = service.ServiceGroupApp.SugarServiceGroup.SugarTeamMembersService
+	sugarDbConnectionsService         = service.ServiceGroupApp.SugarServiceGroup.SugarDbConnectionsService
+	sugarSemanticModelsService        = service.ServiceGroupApp.SugarServiceGroup.SugarSemanticModelsService
+	sugarAgentsService                = service.ServiceGroupApp.SugarServiceGroup.SugarAgentsService
+	sugarCityPermissionsService       = service.ServiceGroupApp.SugarServiceGroup.SugarCityPermissionsService
+	sugarRowLevelOverridesService     = service.ServiceGroupApp.SugarServiceGroup.SugarRowLevelOverridesService
+	sugarExecutionLogsService         = service.ServiceGroupApp.SugarServiceGroup.SugarExecutionLogsService
+	sugarWorkspacesService            = service.ServiceGroupApp.SugarServiceGroup.SugarWorkspacesService
+	sugarFormulaQueryService          = service.ServiceGroupApp.SugarServiceGroup.SugarFormulaQueryService
+	sugarFoldersService               = service.ServiceGroupApp.SugarServiceGroup.SugarFoldersService
+	sugarApiTokensService             = service.ServiceGroupApp.SugarServiceGroup.SugarApiTokensService
+	sugarAnonymizationSessionsService = service.ServiceGroupApp.SugarServiceGroup.SugarAnonymizationSessionsService
+	sugarPrivacyBudgetService         = service.ServiceGroupApp.SugarServiceGroup.SugarPrivacyBudgetService
+	sugarFileVersionsService          = service.ServiceGroupApp.SugarServiceGroup.SugarFileVersionsService
+	sugarWorkbookCollaborationService = service.ServiceGroupApp.SugarServiceGroup.SugarWorkbookCollaborationService
+	sugarAuthorizationService         = service.ServiceGroupApp.SugarServiceGroup.SugarAuthorizationService
+	sugarWorkspacePermissionsService  = service.ServiceGroupApp.SugarServiceGroup.SugarWorkspacePermissionsService
+	sugarShareLinksService            = service.ServiceGroupApp.SugarServiceGroup.SugarShareLinksService
+	sugarRecycleBinService            = service.ServiceGroupApp.SugarServiceGroup.SugarRecycleBinService
+	sugarWorkbookTemplatesService     = service.ServiceGroupApp.SugarServiceGroup.SugarWorkbookTemplatesService
+	sugarWorkbookSearchService        = service.ServiceGroupApp.SugarServiceGroup.SugarWorkbookSearchService
+	sugarWorkbookLineageService       = service.ServiceGroupApp.SugarServiceGroup.SugarWorkbookLineageService
 )
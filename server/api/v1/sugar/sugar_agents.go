@@ -36,7 +36,7 @@ func (sugarAgentsApi *SugarAgentsApi) CreateSugarAgents(c *gin.Context) {
 	userIdStr := strconv.Itoa(int(userId))
 	sugarAgents.CreatedBy = &userIdStr
 
-	err = sugarAgentsService.CreateSugarAgents(ctx, &sugarAgents)
+	err = sugarAgentsService.CreateSugarAgents(ctx, &sugarAgents, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("创建失败!", zap.Error(err))
 		response.FailWithMessage("创建失败:"+err.Error(), c)
@@ -181,3 +181,16 @@ func (sugarAgentsApi *SugarAgentsApi) GetSugarAgentsList(c *gin.Context) {
 		PageSize: pageInfo.PageSize,
 	}, "获取成功", c)
 }
+
+// GetAvailableAgentTools 获取Agent可声明使用的工具列表
+// @Tags SugarAgents
+// @Summary 获取Agent可声明使用的工具列表
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.Response{data=[]string,msg=string} "获取成功"
+// @Router /sugarAgents/getAvailableAgentTools [get]
+func (sugarAgentsApi *SugarAgentsApi) GetAvailableAgentTools(c *gin.Context) {
+	ctx := c.Request.Context()
+	response.OkWithData(sugarAgentsService.GetAvailableAgentTools(ctx), c)
+}
@@ -0,0 +1,43 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarAnonymizationSessionsApi struct{}
+
+// RedecodeExecutionLog 使用持久化的匿名化映射重新解码执行日志
+// @Tags SugarAnonymizationSessions
+// @Summary 使用持久化的匿名化映射重新解码执行日志，用于审计AI结论
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarAnonymizationRedecodeRequest true "执行日志ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarAnonymizationRedecodeResponse,msg=string} "解码成功"
+// @Router /sugarAnonymizationSessions/redecodeExecutionLog [post]
+func (s *SugarAnonymizationSessionsApi) RedecodeExecutionLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarAnonymizationRedecodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarAnonymizationSessionsService.RedecodeExecutionLog(ctx, req.LogId, userIdStr, utils.GetUserAuthorityId(c))
+	if err != nil {
+		global.GVA_LOG.Error("重新解码执行日志失败!", zap.Error(err))
+		response.FailWithMessage("重新解码失败: "+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(result, "解码成功", c)
+}
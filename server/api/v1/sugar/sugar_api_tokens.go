@@ -0,0 +1,88 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarApiTokensApi struct{}
+
+// CreateApiToken 创建API令牌
+// @Tags SugarApiTokens
+// @Summary 创建API令牌，明文令牌只在响应中返回一次
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarApiTokensCreateRequest true "令牌名称和有效天数"
+// @Success 200 {object} response.Response{data=sugarRes.SugarApiTokensCreateResponse,msg=string} "创建成功"
+// @Router /sugarApiTokens/createApiToken [post]
+func (s *SugarApiTokensApi) CreateApiToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarApiTokensCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarApiTokensService.CreateApiToken(ctx, &req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("创建API令牌失败!", zap.Error(err))
+		response.FailWithMessage("创建API令牌失败: "+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(result, "创建成功", c)
+}
+
+// GetApiTokenList 获取当前用户的API令牌列表
+// @Tags SugarApiTokens
+// @Summary 获取当前用户的API令牌列表
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.Response{data=[]sugar.SugarApiTokens,msg=string} "获取成功"
+// @Router /sugarApiTokens/getApiTokenList [get]
+func (s *SugarApiTokensApi) GetApiTokenList(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, err := sugarApiTokensService.GetApiTokenList(ctx, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取API令牌列表失败!", zap.Error(err))
+		response.FailWithMessage("获取失败: "+err.Error(), c)
+		return
+	}
+	response.OkWithData(list, c)
+}
+
+// RevokeApiToken 吊销API令牌
+// @Tags SugarApiTokens
+// @Summary 吊销API令牌
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query string true "令牌ID"
+// @Success 200 {object} response.Response{msg=string} "吊销成功"
+// @Router /sugarApiTokens/revokeApiToken [delete]
+func (s *SugarApiTokensApi) RevokeApiToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Query("id")
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	if err := sugarApiTokensService.RevokeApiToken(ctx, id, userIdStr); err != nil {
+		global.GVA_LOG.Error("吊销API令牌失败!", zap.Error(err))
+		response.FailWithMessage("吊销失败: "+err.Error(), c)
+		return
+	}
+	response.OkWithMessage("吊销成功", c)
+}
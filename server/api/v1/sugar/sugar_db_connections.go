@@ -1,11 +1,15 @@
 package sugar
 
 import (
-	
+	"errors"
+	"strconv"
+
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
     "github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
     "github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
     sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+    sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+    "github.com/flipped-aurora/gin-vue-admin/server/utils"
     "github.com/gin-gonic/gin"
     "go.uber.org/zap"
 )
@@ -33,7 +37,9 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) CreateSugarDbConnections(c *
 		response.FailWithMessage(err.Error(), c)
 		return
 	}
-	err = sugarDbConnectionsService.CreateSugarDbConnections(ctx,&sugarDbConnections)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	sugarDbConnections.CreatedBy = &userIdStr
+	err = sugarDbConnectionsService.CreateSugarDbConnections(ctx,&sugarDbConnections,userIdStr)
 	if err != nil {
         global.GVA_LOG.Error("创建失败!", zap.Error(err))
 		response.FailWithMessage("创建失败:" + err.Error(), c)
@@ -49,6 +55,7 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) CreateSugarDbConnections(c *
 // @Accept application/json
 // @Produce application/json
 // @Param data body sugar.SugarDbConnections true "删除Sugar数据库配置表"
+// @Param force query bool false "使用该连接的语义模型仍被工作簿引用时返回受影响的模型和工作簿，确认后传 true 强制删除"
 // @Success 200 {object} response.Response{msg=string} "删除成功"
 // @Router /sugarDbConnections/deleteSugarDbConnections [delete]
 func (sugarDbConnectionsApi *SugarDbConnectionsApi) DeleteSugarDbConnections(c *gin.Context) {
@@ -56,8 +63,14 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) DeleteSugarDbConnections(c *
     ctx := c.Request.Context()
 
 	id := c.Query("id")
-	err := sugarDbConnectionsService.DeleteSugarDbConnections(ctx,id)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	err := sugarDbConnectionsService.DeleteSugarDbConnections(ctx,id,userIdStr,c.Query("force") == "true")
 	if err != nil {
+		var conflictErr *sugarService.LineageConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Impact, err.Error(), c)
+			return
+		}
         global.GVA_LOG.Error("删除失败!", zap.Error(err))
 		response.FailWithMessage("删除失败:" + err.Error(), c)
 		return
@@ -71,6 +84,7 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) DeleteSugarDbConnections(c *
 // @Security ApiKeyAuth
 // @Accept application/json
 // @Produce application/json
+// @Param force query bool false "使用这些连接的语义模型仍被工作簿引用时返回受影响的模型和工作簿，确认后传 true 强制删除"
 // @Success 200 {object} response.Response{msg=string} "批量删除成功"
 // @Router /sugarDbConnections/deleteSugarDbConnectionsByIds [delete]
 func (sugarDbConnectionsApi *SugarDbConnectionsApi) DeleteSugarDbConnectionsByIds(c *gin.Context) {
@@ -78,8 +92,14 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) DeleteSugarDbConnectionsById
     ctx := c.Request.Context()
 
 	ids := c.QueryArray("ids[]")
-	err := sugarDbConnectionsService.DeleteSugarDbConnectionsByIds(ctx,ids)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	err := sugarDbConnectionsService.DeleteSugarDbConnectionsByIds(ctx,ids,userIdStr,c.Query("force") == "true")
 	if err != nil {
+		var conflictErr *sugarService.LineageConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Impact, err.Error(), c)
+			return
+		}
         global.GVA_LOG.Error("批量删除失败!", zap.Error(err))
 		response.FailWithMessage("批量删除失败:" + err.Error(), c)
 		return
@@ -106,7 +126,9 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) UpdateSugarDbConnections(c *
 		response.FailWithMessage(err.Error(), c)
 		return
 	}
-	err = sugarDbConnectionsService.UpdateSugarDbConnections(ctx,sugarDbConnections)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	sugarDbConnections.UpdatedBy = &userIdStr
+	err = sugarDbConnectionsService.UpdateSugarDbConnections(ctx,sugarDbConnections,userIdStr)
 	if err != nil {
         global.GVA_LOG.Error("更新失败!", zap.Error(err))
 		response.FailWithMessage("更新失败:" + err.Error(), c)
@@ -129,7 +151,8 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) FindSugarDbConnections(c *gi
     ctx := c.Request.Context()
 
 	id := c.Query("id")
-	resugarDbConnections, err := sugarDbConnectionsService.GetSugarDbConnections(ctx,id)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	resugarDbConnections, err := sugarDbConnectionsService.GetSugarDbConnections(ctx,id,userIdStr)
 	if err != nil {
         global.GVA_LOG.Error("查询失败!", zap.Error(err))
 		response.FailWithMessage("查询失败:" + err.Error(), c)
@@ -156,7 +179,8 @@ func (sugarDbConnectionsApi *SugarDbConnectionsApi) GetSugarDbConnectionsList(c
 		response.FailWithMessage(err.Error(), c)
 		return
 	}
-	list, total, err := sugarDbConnectionsService.GetSugarDbConnectionsInfoList(ctx,pageInfo)
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	list, total, err := sugarDbConnectionsService.GetSugarDbConnectionsInfoList(ctx,pageInfo,userIdStr)
 	if err != nil {
 	    global.GVA_LOG.Error("获取失败!", zap.Error(err))
         response.FailWithMessage("获取失败:" + err.Error(), c)
@@ -0,0 +1,158 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarFileVersionsApi struct{}
+
+// GetFileVersionList 分页获取工作簿历史版本
+// @Tags SugarFileVersions
+// @Summary 分页获取工作簿历史版本，包含作者和保存时间，不含内容快照
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarFileVersionsSearch true "文件ID及分页信息"
+// @Success 200 {object} response.Response{data=response.PageResult,msg=string} "获取成功"
+// @Router /sugarFileVersions/getFileVersionList [get]
+func (s *SugarFileVersionsApi) GetFileVersionList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var pageInfo sugarReq.SugarFileVersionsSearch
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, total, err := sugarFileVersionsService.GetFileVersionList(ctx, pageInfo, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取历史版本失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(response.PageResult{
+		List:     list,
+		Total:    total,
+		Page:     pageInfo.Page,
+		PageSize: pageInfo.PageSize,
+	}, "获取成功", c)
+}
+
+// FindFileVersion 获取历史版本详情
+// @Tags SugarFileVersions
+// @Summary 获取历史版本详情，包含该版本的工作簿内容
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "历史版本ID"
+// @Success 200 {object} response.Response{data=object,msg=string} "查询成功"
+// @Router /sugarFileVersions/findFileVersion [get]
+func (s *SugarFileVersionsApi) FindFileVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.ParseInt(c.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("历史版本ID无效", c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	version, err := sugarFileVersionsService.GetFileVersion(ctx, id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("查询历史版本失败!", zap.Error(err))
+		response.FailWithMessage("查询失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithData(version, c)
+}
+
+// DiffFileVersions 对比两个历史版本
+// @Tags SugarFileVersions
+// @Summary 在工作表/单元格级别对比两个历史版本，toVersion 为0时与当前内容对比
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarFileVersionDiffRequest true "文件ID及版本号"
+// @Success 200 {object} response.Response{data=object,msg=string} "对比成功"
+// @Router /sugarFileVersions/diffFileVersions [get]
+func (s *SugarFileVersionsApi) DiffFileVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFileVersionDiffRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarFileVersionsService.DiffFileVersions(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("对比历史版本失败!", zap.Error(err))
+		response.FailWithMessage("对比失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithData(result, c)
+}
+
+// RestoreFileVersion 恢复历史版本
+// @Tags SugarFileVersions
+// @Summary 将工作簿恢复为指定历史版本的内容，恢复结果作为新版本保存
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFileVersionRestoreRequest true "历史版本ID"
+// @Success 200 {object} response.Response{data=object,msg=string} "恢复成功"
+// @Router /sugarFileVersions/restoreFileVersion [post]
+func (s *SugarFileVersionsApi) RestoreFileVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFileVersionRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	version, err := sugarFileVersionsService.RestoreFileVersion(ctx, req.Id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("恢复历史版本失败!", zap.Error(err))
+		response.FailWithMessage("恢复失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(version, "恢复成功", c)
+}
+
+// UpdateFileVersion 命名或置顶历史版本
+// @Tags SugarFileVersions
+// @Summary 命名或置顶历史版本，命名或置顶的版本不会被合并或按保留策略清理
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFileVersionUpdateRequest true "版本名称及置顶状态"
+// @Success 200 {object} response.Response{msg=string} "更新成功"
+// @Router /sugarFileVersions/updateFileVersion [put]
+func (s *SugarFileVersionsApi) UpdateFileVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFileVersionUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	if err := sugarFileVersionsService.UpdateFileVersion(ctx, req, userIdStr); err != nil {
+		global.GVA_LOG.Error("更新历史版本失败!", zap.Error(err))
+		response.FailWithMessage("更新失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithMessage("更新成功", c)
+}
@@ -138,6 +138,37 @@ func (s *SugarFoldersApi) MoveItem(c *gin.Context) {
 	response.OkWithData(result, c)
 }
 
+// CopyItem 复制文件夹或文件
+// @Tags SugarFolders
+// @Summary 复制文件夹或文件，文件夹连同其下内容一起复制；可复制到其他文件夹或团队，重名时自动追加序号
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFoldersCopyRequest true "复制数据"
+// @Success 200 {object} response.Response{data=sugarRes.SugarFoldersCopyResponse,msg=string} "复制成功"
+// @Router /sugarFolders/copy [post]
+func (s *SugarFoldersApi) CopyItem(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFoldersCopyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarFoldersService.CopyItem(ctx, &req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("复制失败!", zap.Error(err))
+		response.FailWithMessage("复制失败: "+err.Error(), c)
+		return
+	}
+
+	response.OkWithData(result, c)
+}
+
 // DeleteItem 删除文件夹或文件
 // @Tags SugarFolders
 // @Summary 删除文件夹或文件
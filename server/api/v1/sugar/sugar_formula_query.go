@@ -88,6 +88,80 @@ func (s *SugarFormulaQueryApi) ExecuteSugarGet(c *gin.Context) {
 	response.OkWithData(result, c)
 }
 
+// ExecuteSugarContribution 执行 SUGAR.CONTRIBUTION 公式
+// @Tags SugarFormulaQuery
+// @Summary 执行 SUGAR.CONTRIBUTION 公式（贡献度下钻分析，不经过大模型）
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFormulaContributionRequest true "SUGAR.CONTRIBUTION 公式请求"
+// @Success 200 {object} response.Response{data=sugarRes.SugarFormulaContributionResponse,msg=string} "执行成功"
+// @Router /sugarFormulaQuery/executeContribution [post]
+func (s *SugarFormulaQueryApi) ExecuteSugarContribution(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFormulaContributionRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarFormulaQueryService.ExecuteContributionFormula(ctx, &req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("SUGAR.CONTRIBUTION 执行失败!", zap.Error(err))
+		response.FailWithMessage("SUGAR.CONTRIBUTION 执行失败: "+err.Error(), c)
+		return
+	}
+
+	// 检查业务层返回的错误
+	if result.Error != "" {
+		response.FailWithMessage(result.Error, c)
+		return
+	}
+
+	response.OkWithData(result, c)
+}
+
+// ExecuteSugarAnomaly 执行 SUGAR.ANOMALY 公式
+// @Tags SugarFormulaQuery
+// @Summary 执行 SUGAR.ANOMALY 公式（时间序列异常值与变点检测，可对异常期执行贡献度分析）
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFormulaAnomalyRequest true "SUGAR.ANOMALY 公式请求"
+// @Success 200 {object} response.Response{data=sugarRes.SugarFormulaAnomalyResponse,msg=string} "执行成功"
+// @Router /sugarFormulaQuery/executeAnomaly [post]
+func (s *SugarFormulaQueryApi) ExecuteSugarAnomaly(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarFormulaAnomalyRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarFormulaQueryService.ExecuteAnomalyFormula(ctx, &req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("SUGAR.ANOMALY 执行失败!", zap.Error(err))
+		response.FailWithMessage("SUGAR.ANOMALY 执行失败: "+err.Error(), c)
+		return
+	}
+
+	// 检查业务层返回的错误
+	if result.Error != "" {
+		response.FailWithMessage(result.Error, c)
+		return
+	}
+
+	response.OkWithData(result, c)
+}
+
 // ExecuteAiFetch 执行 AIFETCH 公式
 // @Tags SugarFormulaQuery
 // @Summary 执行 AIFETCH 公式
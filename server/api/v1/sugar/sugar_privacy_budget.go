@@ -0,0 +1,42 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarPrivacyBudgetApi struct{}
+
+// GetPrivacyBudgetList 分页获取隐私预算账本
+// @Tags SugarPrivacyBudget
+// @Summary 分页获取差分隐私预算账本（用户 × 语义模型 × 时间窗口），供管理员查看预算消耗
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarPrivacyBudgetSearch true "分页及筛选条件"
+// @Success 200 {object} response.Response{data=response.PageResult,msg=string} "获取成功"
+// @Router /sugarPrivacyBudget/getPrivacyBudgetList [get]
+func (s *SugarPrivacyBudgetApi) GetPrivacyBudgetList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var pageInfo sugarReq.SugarPrivacyBudgetSearch
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	list, total, err := sugarPrivacyBudgetService.GetPrivacyBudgetList(ctx, pageInfo)
+	if err != nil {
+		global.GVA_LOG.Error("获取隐私预算账本失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(response.PageResult{
+		List:     list,
+		Total:    total,
+		Page:     pageInfo.Page,
+		PageSize: pageInfo.PageSize,
+	}, "获取成功", c)
+}
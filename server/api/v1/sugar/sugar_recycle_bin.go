@@ -0,0 +1,110 @@
+package sugar
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarRecycleBinApi struct{}
+
+// GetRecycleBinList 分页获取团队回收站
+// @Tags SugarRecycleBin
+// @Summary 分页获取团队回收站，文件夹连同其下内容作为一项展示，并标明删除者和预计彻底删除的时间
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarRecycleBinSearch true "团队ID及分页参数"
+// @Success 200 {object} response.Response{data=response.PageResult{list=[]sugarRes.SugarRecycleBinItem},msg=string} "获取成功"
+// @Router /sugarRecycleBin/getRecycleBinList [get]
+func (s *SugarRecycleBinApi) GetRecycleBinList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var pageInfo sugarReq.SugarRecycleBinSearch
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, total, err := sugarRecycleBinService.GetRecycleBinList(ctx, pageInfo, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取回收站失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(response.PageResult{
+		List:     list,
+		Total:    total,
+		Page:     pageInfo.Page,
+		PageSize: pageInfo.PageSize,
+	}, "获取成功", c)
+}
+
+// RestoreItem 从回收站恢复项目
+// @Tags SugarRecycleBin
+// @Summary 恢复项目及随其一起删除的内容；原位置已有同名项目时返回冲突详情，或按 conflictStrategy=rename 自动重命名
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarRecycleBinRestoreRequest true "项目ID及冲突处理方式"
+// @Success 200 {object} response.Response{data=sugarRes.SugarRecycleBinRestoreResponse,msg=string} "恢复成功"
+// @Router /sugarRecycleBin/restoreItem [post]
+func (s *SugarRecycleBinApi) RestoreItem(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarRecycleBinRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	result, err := sugarRecycleBinService.RestoreItem(ctx, req, userIdStr)
+	if err != nil {
+		var conflictErr *sugarService.RecycleBinConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Conflict, err.Error(), c)
+			return
+		}
+		global.GVA_LOG.Error("恢复失败!", zap.Error(err))
+		response.FailWithMessage("恢复失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(result, "恢复成功", c)
+}
+
+// PurgeItem 彻底删除回收站中的项目
+// @Tags SugarRecycleBin
+// @Summary 彻底删除回收站中的项目及随其一起删除的内容、历史版本和分享链接，删除后无法恢复
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarRecycleBinPurgeRequest true "项目ID"
+// @Success 200 {object} response.Response{data=int,msg=string} "删除成功"
+// @Router /sugarRecycleBin/purgeItem [delete]
+func (s *SugarRecycleBinApi) PurgeItem(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarRecycleBinPurgeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	purged, err := sugarRecycleBinService.PurgeItem(ctx, req.Id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("彻底删除失败!", zap.Error(err))
+		response.FailWithMessage("删除失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(purged, "删除成功", c)
+}
@@ -1,12 +1,14 @@
 package sugar
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
 	"github.com/flipped-aurora/gin-vue-admin/server/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -35,7 +37,7 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) CreateSugarSemanticModels(
 	userIdStr := strconv.Itoa(int(userId))
 	sugarSemanticModels.CreatedBy = &userIdStr
 
-	err = sugarSemanticModelsService.CreateSugarSemanticModels(ctx, &sugarSemanticModels)
+	err = sugarSemanticModelsService.CreateSugarSemanticModels(ctx, &sugarSemanticModels, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("创建失败!", zap.Error(err))
 		response.FailWithMessage("创建失败:"+err.Error(), c)
@@ -51,6 +53,7 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) CreateSugarSemanticModels(
 // @Accept application/json
 // @Produce application/json
 // @Param id query string true "指标ID"
+// @Param force query bool false "模型仍被工作簿引用时返回受影响的工作簿，确认后传 true 强制删除"
 // @Success 200 {object} response.Response{msg=string} "删除成功"
 // @Router /sugarSemanticModels/deleteSugarSemanticModels [delete]
 func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModels(c *gin.Context) {
@@ -59,8 +62,13 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModels(
 	userId := utils.GetUserID(c)
 	userIdStr := strconv.Itoa(int(userId))
 
-	err := sugarSemanticModelsService.DeleteSugarSemanticModels(ctx, id, userIdStr)
+	err := sugarSemanticModelsService.DeleteSugarSemanticModels(ctx, id, userIdStr, c.Query("force") == "true")
 	if err != nil {
+		var conflictErr *sugarService.LineageConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Impact, err.Error(), c)
+			return
+		}
 		global.GVA_LOG.Error("删除失败!", zap.Error(err))
 		response.FailWithMessage("删除失败:"+err.Error(), c)
 		return
@@ -75,6 +83,7 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModels(
 // @Accept application/json
 // @Produce application/json
 // @Param ids query []string true "指标ID列表"
+// @Param force query bool false "模型仍被工作簿引用时返回受影响的工作簿，确认后传 true 强制删除"
 // @Success 200 {object} response.Response{msg=string} "批量删除成功"
 // @Router /sugarSemanticModels/deleteSugarSemanticModelsByIds [delete]
 func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModelsByIds(c *gin.Context) {
@@ -83,8 +92,13 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModelsB
 	userId := utils.GetUserID(c)
 	userIdStr := strconv.Itoa(int(userId))
 
-	err := sugarSemanticModelsService.DeleteSugarSemanticModelsByIds(ctx, ids, userIdStr)
+	err := sugarSemanticModelsService.DeleteSugarSemanticModelsByIds(ctx, ids, userIdStr, c.Query("force") == "true")
 	if err != nil {
+		var conflictErr *sugarService.LineageConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Impact, err.Error(), c)
+			return
+		}
 		global.GVA_LOG.Error("批量删除失败!", zap.Error(err))
 		response.FailWithMessage("批量删除失败:"+err.Error(), c)
 		return
@@ -99,6 +113,7 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) DeleteSugarSemanticModelsB
 // @Accept application/json
 // @Produce application/json
 // @Param data body sugar.SugarSemanticModels true "更新Sugar指标语义表"
+// @Param force query bool false "重命名模型或移除仍被工作簿引用的字段时返回受影响的工作簿，确认后传 true 强制更新"
 // @Success 200 {object} response.Response{msg=string} "更新成功"
 // @Router /sugarSemanticModels/updateSugarSemanticModels [put]
 func (sugarSemanticModelsApi *SugarSemanticModelsApi) UpdateSugarSemanticModels(c *gin.Context) {
@@ -113,8 +128,13 @@ func (sugarSemanticModelsApi *SugarSemanticModelsApi) UpdateSugarSemanticModels(
 	userIdStr := strconv.Itoa(int(userId))
 	sugarSemanticModels.UpdatedBy = &userIdStr
 
-	err = sugarSemanticModelsService.UpdateSugarSemanticModels(ctx, sugarSemanticModels, userIdStr)
+	err = sugarSemanticModelsService.UpdateSugarSemanticModels(ctx, sugarSemanticModels, userIdStr, c.Query("force") == "true")
 	if err != nil {
+		var conflictErr *sugarService.LineageConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Impact, err.Error(), c)
+			return
+		}
 		global.GVA_LOG.Error("更新失败!", zap.Error(err))
 		response.FailWithMessage("更新失败:"+err.Error(), c)
 		return
@@ -0,0 +1,175 @@
+package sugar
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarShareLinksApi struct{}
+
+// CreateShareLink 创建分享链接
+// @Tags SugarShareLinks
+// @Summary 为工作簿创建公开分享链接，可设置访问密码、过期时间，并可冻结 SUGAR 公式结果
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkCreateRequest true "文件ID、权限级别及链接选项"
+// @Success 200 {object} response.Response{data=sugar.SugarShareLinks,msg=string} "创建成功"
+// @Router /sugarShareLinks/createShareLink [post]
+func (s *SugarShareLinksApi) CreateShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarShareLinkCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	link, err := sugarShareLinksService.CreateShareLink(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("创建分享链接失败!", zap.Error(err))
+		response.FailWithMessage("创建失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(link, "创建成功", c)
+}
+
+// RevokeShareLink 关闭分享链接
+// @Tags SugarShareLinks
+// @Summary 关闭分享链接，关闭后通过该链接打开的会话也无法继续保存
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "链接ID"
+// @Success 200 {object} response.Response{msg=string} "关闭成功"
+// @Router /sugarShareLinks/revokeShareLink [delete]
+func (s *SugarShareLinksApi) RevokeShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.ParseInt(c.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("链接ID无效", c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	if err = sugarShareLinksService.RevokeShareLink(ctx, id, userIdStr); err != nil {
+		global.GVA_LOG.Error("关闭分享链接失败!", zap.Error(err))
+		response.FailWithMessage("关闭失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithMessage("关闭成功", c)
+}
+
+// GetShareLinkList 获取工作簿的分享链接列表
+// @Tags SugarShareLinks
+// @Summary 获取工作簿的全部分享链接，包括已关闭和已过期的链接
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarShareLinkSearch true "文件ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarShareLinkItem,msg=string} "获取成功"
+// @Router /sugarShareLinks/getShareLinkList [get]
+func (s *SugarShareLinksApi) GetShareLinkList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var search sugarReq.SugarShareLinkSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, err := sugarShareLinksService.GetShareLinkList(ctx, search.WorkspaceId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取分享链接失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(list, "获取成功", c)
+}
+
+// OpenSharedWorkbook 通过分享链接打开工作簿
+// @Tags SugarShareLinks
+// @Summary 无需登录，通过链接令牌打开工作簿；链接设置了密码时需提供密码，密码多次错误后暂时禁止尝试
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkOpenRequest true "链接令牌及访问密码"
+// @Success 200 {object} response.Response{data=sugarRes.SugarSharedWorkbookResponse,msg=string} "打开成功，需要密码时 code 为 7，data.needPassword 为 true"
+// @Router /sugarShareLinks/openSharedWorkbook [post]
+func (s *SugarShareLinksApi) OpenSharedWorkbook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarShareLinkOpenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	result, err := sugarShareLinksService.OpenSharedWorkbook(ctx, req, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, sugarService.ErrShareLinkPasswordRequired) {
+			response.FailWithDetailed(map[string]bool{"needPassword": true}, err.Error(), c)
+			return
+		}
+		response.FailWithMessage("打开失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(result, "打开成功", c)
+}
+
+// SaveSharedWorkbook 通过可编辑的分享链接保存工作簿
+// @Tags SugarShareLinks
+// @Summary 无需登录，携带打开链接时获得的会话令牌保存工作簿；修订号过期时可携带 baseContent 自动合并
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkSaveRequest true "会话令牌及工作簿内容"
+// @Success 200 {object} response.Response{data=object,msg=string} "保存成功，修订号过期且无法合并时 code 为 7，data 为冲突详情"
+// @Router /sugarShareLinks/saveSharedWorkbook [put]
+func (s *SugarShareLinksApi) SaveSharedWorkbook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarShareLinkSaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	contentBytes, err := json.Marshal(req.Content)
+	if err != nil {
+		response.FailWithMessage("内容格式错误", c)
+		return
+	}
+	var baseBytes []byte
+	if req.BaseContent != nil {
+		if baseBytes, err = json.Marshal(req.BaseContent); err != nil {
+			response.FailWithMessage("基准内容格式错误", c)
+			return
+		}
+	}
+
+	result, err := sugarShareLinksService.SaveSharedWorkbook(ctx, req.SessionToken, contentBytes, baseBytes, req.Revision)
+	if err != nil {
+		var conflictErr *sugarService.WorkbookConflictError
+		if errors.As(err, &conflictErr) {
+			response.FailWithDetailed(conflictErr.Conflict, err.Error(), c)
+			return
+		}
+		global.GVA_LOG.Error("通过分享链接保存失败!", zap.Error(err))
+		response.FailWithMessage("保存失败:"+err.Error(), c)
+		return
+	}
+	message := "保存成功"
+	if result.Merged {
+		message = "保存成功，已自动合并其他人的修改"
+	}
+	response.OkWithDetailed(result, message, c)
+}
@@ -45,7 +45,7 @@ func (sugarTeamMembersApi *SugarTeamMembersApi) CreateSugarTeamMembers(c *gin.Co
 		CreatedBy: &userIdStr,
 	}
 
-	err = sugarTeamMembersService.CreateSugarTeamMembers(ctx, &sugarTeamMember)
+	err = sugarTeamMembersService.CreateSugarTeamMembers(ctx, &sugarTeamMember, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("创建失败!", zap.Error(err))
 		response.FailWithMessage("创建失败:"+err.Error(), c)
@@ -93,7 +93,9 @@ func (sugarTeamMembersApi *SugarTeamMembersApi) DeleteSugarTeamMembersByIds(c *g
 	ctx := c.Request.Context()
 
 	ids := c.QueryArray("ids[]")
-	err := sugarTeamMembersService.DeleteSugarTeamMembersByIds(ctx, ids)
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+	err := sugarTeamMembersService.DeleteSugarTeamMembersByIds(ctx, ids, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("批量删除失败!", zap.Error(err))
 		response.FailWithMessage("批量删除失败:"+err.Error(), c)
@@ -138,7 +140,7 @@ func (sugarTeamMembersApi *SugarTeamMembersApi) UpdateSugarTeamMembers(c *gin.Co
 		UpdatedBy: &userIdStr,
 	}
 
-	err = sugarTeamMembersService.UpdateSugarTeamMembers(ctx, sugarTeamMembers)
+	err = sugarTeamMembersService.UpdateSugarTeamMembers(ctx, sugarTeamMembers, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("更新失败!", zap.Error(err))
 		response.FailWithMessage("更新失败:"+err.Error(), c)
@@ -203,6 +203,33 @@ func (sugarTeamsApi *SugarTeamsApi) GetSugarTeamsList(c *gin.Context) {
 	}, "获取成功", c)
 }
 
+// GetMyTeamPermissions 获取当前用户在团队中的角色及允许的操作
+// @Tags SugarTeams
+// @Summary 获取当前用户在团队中的角色及对工作区、语义模型、智能体、数据库连接允许的操作
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param teamId query string true "团队ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarTeamPermissionsResponse,msg=string} "获取成功"
+// @Router /sugarTeams/getMyTeamPermissions [get]
+func (sugarTeamsApi *SugarTeamsApi) GetMyTeamPermissions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	teamId := c.Query("teamId")
+	if teamId == "" {
+		response.FailWithMessage("团队ID不能为空", c)
+		return
+	}
+	userIdStr := strconv.Itoa(int(utils.GetUserID(c)))
+	permissions, err := sugarAuthorizationService.GetTeamPermissions(ctx, teamId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取团队权限失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithData(permissions, c)
+}
+
 // GetSugarTeamsPublic 不需要鉴权的团队信息表接口
 // @Tags SugarTeams
 // @Summary 不需要鉴权的团队信息表接口
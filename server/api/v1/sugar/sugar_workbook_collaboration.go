@@ -0,0 +1,146 @@
+package sugar
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	collabReadLimit    = 32 << 20         // 单条消息上限，内容快照可能较大
+	collabWriteTimeout = 10 * time.Second // 单条消息写入超时
+	collabPongTimeout  = 60 * time.Second // 超过该时间未收到客户端响应视为连接已断开
+	collabPingInterval = 25 * time.Second // 心跳间隔，需小于 collabPongTimeout
+)
+
+var collabUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     checkCollabOrigin,
+}
+
+// checkCollabOrigin 通过查询参数携带令牌的连接允许跨域（开发环境经代理访问）；
+// 只依赖 cookie 中令牌的连接必须同源，防止其他站点借用户 cookie 建立连接
+func checkCollabOrigin(r *http.Request) bool {
+	if r.URL.Query().Get("x-token") != "" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+type SugarWorkbookCollaborationApi struct{}
+
+// Connect 建立工作簿实时协作连接
+// @Tags SugarWorkbookCollaboration
+// @Summary 升级为 WebSocket 连接，收发 Univer 变更命令、在线状态和内容快照，消息格式见 WorkbookCollabMessage
+// @Security ApiKeyAuth
+// @Param id query string true "工作簿文件ID"
+// @Param x-token query string false "登录令牌，浏览器无法设置请求头时使用"
+// @Success 101 {string} string "切换为 WebSocket 协议"
+// @Router /sugarWorkbookCollaboration/connect [get]
+func (s *SugarWorkbookCollaborationApi) Connect(c *gin.Context) {
+	ctx := c.Request.Context()
+	fileId := c.Query("id")
+	if fileId == "" {
+		response.FailWithMessage("文件ID不能为空", c)
+		return
+	}
+	userId := strconv.Itoa(int(utils.GetUserID(c)))
+	userName := utils.GetUserName(c)
+	if claims := utils.GetUserInfo(c); claims != nil && claims.NickName != "" {
+		userName = claims.NickName
+	}
+
+	if err := sugarWorkbookCollaborationService.Authorize(ctx, fileId, userId); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	ws, err := collabUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已向客户端写入错误响应
+		global.GVA_LOG.Warn("建立协作连接失败", zap.String("fileId", fileId), zap.Error(err))
+		return
+	}
+	conn := newWorkbookCollabConn(ws)
+	defer conn.Close()
+	go conn.keepAlive()
+	sugarWorkbookCollaborationService.Serve(ctx, fileId, userId, userName, conn)
+}
+
+// workbookCollabConn 把 gorilla/websocket 连接适配为服务层的 WorkbookCollabConn，并维护心跳
+type workbookCollabConn struct {
+	ws        *websocket.Conn
+	writeMu   sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWorkbookCollabConn(ws *websocket.Conn) *workbookCollabConn {
+	ws.SetReadLimit(collabReadLimit)
+	_ = ws.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	})
+	return &workbookCollabConn{ws: ws, done: make(chan struct{})}
+}
+
+func (c *workbookCollabConn) ReadMessage() ([]byte, error) {
+	for {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if messageType == websocket.TextMessage {
+			_ = c.ws.SetReadDeadline(time.Now().Add(collabPongTimeout))
+			return data, nil
+		}
+	}
+}
+
+func (c *workbookCollabConn) WriteMessage(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *workbookCollabConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.ws.Close()
+	})
+	return err
+}
+
+// keepAlive 定期发送 ping，客户端未响应时读超时会结束连接
+func (c *workbookCollabConn) keepAlive() {
+	ticker := time.NewTicker(collabPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(collabWriteTimeout)); err != nil {
+				_ = c.Close()
+				return
+			}
+		}
+	}
+}
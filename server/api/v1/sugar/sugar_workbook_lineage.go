@@ -0,0 +1,126 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarWorkbookLineageApi struct{}
+
+// GetModelImpact 语义模型影响分析
+// @Tags SugarWorkbookLineage
+// @Summary 查询依赖语义模型的工作簿，可只分析指定字段，并按字段汇总引用的工作簿数
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "语义模型ID及可选的字段"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getModelImpact [get]
+func (s *SugarWorkbookLineageApi) GetModelImpact(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarLineageImpactRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	impact, err := sugarWorkbookLineageService.GetModelImpact(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取影响分析失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(impact, "获取成功", c)
+}
+
+// GetConnectionImpact 数据库连接影响分析
+// @Tags SugarWorkbookLineage
+// @Summary 查询使用数据库连接的语义模型，以及依赖这些模型的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "数据库连接ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getConnectionImpact [get]
+func (s *SugarWorkbookLineageApi) GetConnectionImpact(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarLineageImpactRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	impact, err := sugarWorkbookLineageService.GetConnectionImpact(ctx, req.Id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取影响分析失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(impact, "获取成功", c)
+}
+
+// GetAgentImpact 智能体影响分析
+// @Tags SugarWorkbookLineage
+// @Summary 查询通过 AI.FETCH 引用智能体的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "智能体ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getAgentImpact [get]
+func (s *SugarWorkbookLineageApi) GetAgentImpact(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarLineageImpactRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	impact, err := sugarWorkbookLineageService.GetAgentImpact(ctx, req.Id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取影响分析失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(impact, "获取成功", c)
+}
+
+// GetWorkbookDependencies 查询工作簿依赖
+// @Tags SugarWorkbookLineage
+// @Summary 查询工作簿公式引用的语义模型、字段和智能体，并标出已不存在的对象和字段
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookDependenciesRequest true "工作簿ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkbookDependency,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getWorkbookDependencies [get]
+func (s *SugarWorkbookLineageApi) GetWorkbookDependencies(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkbookDependenciesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	dependencies, err := sugarWorkbookLineageService.GetWorkbookDependencies(ctx, req.Id, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取工作簿依赖失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(dependencies, "获取成功", c)
+}
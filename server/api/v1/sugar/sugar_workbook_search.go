@@ -0,0 +1,75 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarWorkbookSearchApi struct{}
+
+// SearchWorkbooks 搜索工作簿
+// @Tags SugarWorkbookSearch
+// @Summary 在可查看的工作簿中按关键词搜索文件名、工作表名、单元格文本和公式，或查找引用了指定语义模型、智能体的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookSearchRequest true "关键词、模型或智能体名称及分页参数"
+// @Success 200 {object} response.Response{data=response.PageResult{list=[]sugarRes.SugarWorkbookSearchItem},msg=string} "获取成功"
+// @Router /sugarWorkbookSearch/searchWorkbooks [get]
+func (s *SugarWorkbookSearchApi) SearchWorkbooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	var pageInfo sugarReq.SugarWorkbookSearchRequest
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, total, err := sugarWorkbookSearchService.SearchWorkbooks(ctx, pageInfo, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("搜索工作簿失败!", zap.Error(err))
+		response.FailWithMessage("搜索失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(response.PageResult{
+		List:     list,
+		Total:    total,
+		Page:     pageInfo.Page,
+		PageSize: pageInfo.PageSize,
+	}, "获取成功", c)
+}
+
+// RebuildSearchIndex 重建团队搜索索引
+// @Tags SugarWorkbookSearch
+// @Summary 重建团队全部工作簿的搜索索引和引用关系，用于索引功能上线前已有的工作簿，需要团队管理权限
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookSearchRebuildRequest true "团队ID"
+// @Success 200 {object} response.Response{data=int,msg=string} "重建成功"
+// @Router /sugarWorkbookSearch/rebuildSearchIndex [post]
+func (s *SugarWorkbookSearchApi) RebuildSearchIndex(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkbookSearchRebuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	indexed, err := sugarWorkbookSearchService.RebuildSearchIndex(ctx, req.TeamId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("重建搜索索引失败!", zap.Error(err))
+		response.FailWithMessage("重建失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(indexed, "重建成功", c)
+}
@@ -0,0 +1,125 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarWorkbookTemplatesApi struct{}
+
+// CreateTemplate 由工作簿生成模板
+// @Tags SugarWorkbookTemplates
+// @Summary 以工作簿当前内容生成团队模板，SUGAR 公式参数中的 {{name}} 占位符成为模板参数
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookTemplateCreateRequest true "工作簿ID、模板名称及参数说明"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkbookTemplates,msg=string} "创建成功"
+// @Router /sugarWorkbookTemplates/createTemplate [post]
+func (s *SugarWorkbookTemplatesApi) CreateTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkbookTemplateCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	template, err := sugarWorkbookTemplatesService.CreateTemplate(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("创建模板失败!", zap.Error(err))
+		response.FailWithMessage("创建失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(template, "创建成功", c)
+}
+
+// GetTemplateList 获取团队模板库
+// @Tags SugarWorkbookTemplates
+// @Summary 获取团队模板库中的模板及其参数
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookTemplateSearch true "团队ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkbookTemplateItem,msg=string} "获取成功"
+// @Router /sugarWorkbookTemplates/getTemplateList [get]
+func (s *SugarWorkbookTemplatesApi) GetTemplateList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var search sugarReq.SugarWorkbookTemplateSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, err := sugarWorkbookTemplatesService.GetTemplateList(ctx, search.TeamId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取模板库失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(list, "获取成功", c)
+}
+
+// DeleteTemplate 删除模板
+// @Tags SugarWorkbookTemplates
+// @Summary 从模板库删除模板，已由模板创建的工作簿不受影响
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookTemplateDeleteRequest true "模板ID"
+// @Success 200 {object} response.Response{msg=string} "删除成功"
+// @Router /sugarWorkbookTemplates/deleteTemplate [delete]
+func (s *SugarWorkbookTemplatesApi) DeleteTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkbookTemplateDeleteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	if err := sugarWorkbookTemplatesService.DeleteTemplate(ctx, req.Id, userIdStr); err != nil {
+		global.GVA_LOG.Error("删除模板失败!", zap.Error(err))
+		response.FailWithMessage("删除失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithMessage("删除成功", c)
+}
+
+// InstantiateTemplate 由模板新建工作簿
+// @Tags SugarWorkbookTemplates
+// @Summary 由模板新建工作簿，参数取值代入 SUGAR 公式中的占位符
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookTemplateInstantiateRequest true "模板ID、新工作簿位置及参数取值"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkspaces,msg=string} "创建成功"
+// @Router /sugarWorkbookTemplates/instantiateTemplate [post]
+func (s *SugarWorkbookTemplatesApi) InstantiateTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkbookTemplateInstantiateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	workspace, err := sugarWorkbookTemplatesService.InstantiateTemplate(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("由模板创建工作簿失败!", zap.Error(err))
+		response.FailWithMessage("创建失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(workspace, "创建成功", c)
+}
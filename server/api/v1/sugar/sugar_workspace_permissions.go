@@ -0,0 +1,125 @@
+package sugar
+
+import (
+	"strconv"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SugarWorkspacePermissionsApi struct{}
+
+// GrantWorkspacePermission 授予文件或文件夹权限
+// @Tags SugarWorkspacePermissions
+// @Summary 将文件或文件夹分享给用户或团队，授予文件夹的权限会继承到其下全部内容
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkspacePermissionGrantRequest true "文件ID、授权对象及权限级别"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkspacePermissions,msg=string} "授权成功"
+// @Router /sugarWorkspacePermissions/grantWorkspacePermission [post]
+func (s *SugarWorkspacePermissionsApi) GrantWorkspacePermission(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req sugarReq.SugarWorkspacePermissionGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	permission, err := sugarWorkspacePermissionsService.GrantWorkspacePermission(ctx, req, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("授权失败!", zap.Error(err))
+		response.FailWithMessage("授权失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(permission, "授权成功", c)
+}
+
+// RevokeWorkspacePermission 撤销文件或文件夹权限
+// @Tags SugarWorkspacePermissions
+// @Summary 撤销一条授权，被授权的用户也可以自行退出分享
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "授权ID"
+// @Success 200 {object} response.Response{msg=string} "撤销成功"
+// @Router /sugarWorkspacePermissions/revokeWorkspacePermission [delete]
+func (s *SugarWorkspacePermissionsApi) RevokeWorkspacePermission(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.ParseInt(c.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("授权ID无效", c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	if err = sugarWorkspacePermissionsService.RevokeWorkspacePermission(ctx, id, userIdStr); err != nil {
+		global.GVA_LOG.Error("撤销授权失败!", zap.Error(err))
+		response.FailWithMessage("撤销失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithMessage("撤销成功", c)
+}
+
+// GetWorkspacePermissionList 获取文件或文件夹的授权列表
+// @Tags SugarWorkspacePermissions
+// @Summary 获取文件或文件夹自身及从上级文件夹继承的授权
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkspacePermissionSearch true "文件或文件夹ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkspaceGrantItem,msg=string} "获取成功"
+// @Router /sugarWorkspacePermissions/getWorkspacePermissionList [get]
+func (s *SugarWorkspacePermissionsApi) GetWorkspacePermissionList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var search sugarReq.SugarWorkspacePermissionSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	list, err := sugarWorkspacePermissionsService.GetWorkspacePermissionList(ctx, search.WorkspaceId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取授权列表失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(list, "获取成功", c)
+}
+
+// GetWorkspaceAccess 获取文件或文件夹的有效访问权限
+// @Tags SugarWorkspacePermissions
+// @Summary 汇总团队角色和授权，列出能访问该文件或文件夹的全部用户和团队及其允许的操作
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkspacePermissionSearch true "文件或文件夹ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarWorkspaceAccessResponse,msg=string} "获取成功"
+// @Router /sugarWorkspacePermissions/getWorkspaceAccess [get]
+func (s *SugarWorkspacePermissionsApi) GetWorkspaceAccess(c *gin.Context) {
+	ctx := c.Request.Context()
+	var search sugarReq.SugarWorkspacePermissionSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		response.FailWithMessage(err.Error(), c)
+		return
+	}
+	userId := utils.GetUserID(c)
+	userIdStr := strconv.Itoa(int(userId))
+
+	access, err := sugarWorkspacePermissionsService.GetWorkspaceAccess(ctx, search.WorkspaceId, userIdStr)
+	if err != nil {
+		global.GVA_LOG.Error("获取有效权限失败!", zap.Error(err))
+		response.FailWithMessage("获取失败:"+err.Error(), c)
+		return
+	}
+	response.OkWithDetailed(access, "获取成功", c)
+}
@@ -2,12 +2,15 @@ package sugar
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/common/response"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
 	"github.com/flipped-aurora/gin-vue-admin/server/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -253,26 +256,37 @@ func (sugarWorkspacesApi *SugarWorkspacesApi) CreateWorkbookFile(c *gin.Context)
 
 // SaveWorkbookContent 保存工作簿内容
 // @Tags SugarWorkspaces
-// @Summary 保存工作簿内容
+// @Summary 保存工作簿内容，需携带打开时获得的修订号；修订号过期时可携带 baseContent 自动合并不重叠的修改
 // @Security ApiKeyAuth
 // @Accept application/json
 // @Produce application/json
-// @Param data body object true "保存工作簿内容数据"
-// @Success 200 {object} response.Response{msg=string} "保存成功"
+// @Param If-Match header string false "修订号，与请求体中的 revision 二选一"
+// @Param data body sugarReq.SugarWorkbookSaveRequest true "保存工作簿内容数据"
+// @Success 200 {object} response.Response{data=object,msg=string} "保存成功，修订号过期且无法合并时 code 为 7，data 为冲突详情"
 // @Router /sugarWorkspaces/saveWorkbookContent [put]
 func (sugarWorkspacesApi *SugarWorkspacesApi) SaveWorkbookContent(c *gin.Context) {
 	ctx := c.Request.Context()
-	var req struct {
-		Id      string `json:"id" binding:"required"`
-		Content any    `json:"content" binding:"required"`
-	}
-
+	var req sugarReq.SugarWorkbookSaveRequest
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		response.FailWithMessage(err.Error(), c)
 		return
 	}
 
+	// 修订号优先取请求体，其次取 If-Match 请求头（ETag）
+	revision := req.Revision
+	if revision == nil {
+		if etag := strings.Trim(strings.TrimPrefix(c.GetHeader("If-Match"), "W/"), `"`); etag != "" {
+			if value, err := strconv.Atoi(etag); err == nil {
+				revision = &value
+			}
+		}
+	}
+	if revision == nil {
+		response.FailWithMessage("缺少工作簿修订号，请重新打开文件后再保存", c)
+		return
+	}
+
 	userId := utils.GetUserID(c)
 	userIdStr := strconv.Itoa(int(userId))
 
@@ -282,15 +296,33 @@ func (sugarWorkspacesApi *SugarWorkspacesApi) SaveWorkbookContent(c *gin.Context
 		response.FailWithMessage("内容格式错误", c)
 		return
 	}
+	var baseBytes []byte
+	if req.BaseContent != nil {
+		if baseBytes, err = json.Marshal(req.BaseContent); err != nil {
+			response.FailWithMessage("基准内容格式错误", c)
+			return
+		}
+	}
 
-	err = sugarWorkspacesService.SaveWorkbookContent(ctx, req.Id, contentBytes, userIdStr)
+	result, err := sugarWorkspacesService.SaveWorkbookContent(ctx, req.Id, contentBytes, baseBytes, *revision, userIdStr)
 	if err != nil {
+		var conflictErr *sugarService.WorkbookConflictError
+		if errors.As(err, &conflictErr) {
+			c.Header("ETag", workbookETag(conflictErr.Conflict.CurrentRevision))
+			response.FailWithDetailed(conflictErr.Conflict, err.Error(), c)
+			return
+		}
 		global.GVA_LOG.Error("保存工作簿内容失败!", zap.Error(err))
 		response.FailWithMessage("保存失败:"+err.Error(), c)
 		return
 	}
 
-	response.OkWithMessage("保存成功", c)
+	c.Header("ETag", workbookETag(result.Revision))
+	message := "保存成功"
+	if result.Merged {
+		message = "保存成功，已自动合并其他人的修改"
+	}
+	response.OkWithDetailed(result, message, c)
 }
 
 // GetWorkbookContent 获取工作簿内容
@@ -300,7 +332,7 @@ func (sugarWorkspacesApi *SugarWorkspacesApi) SaveWorkbookContent(c *gin.Context
 // @Accept application/json
 // @Produce application/json
 // @Param id query string true "文件ID"
-// @Success 200 {object} response.Response{data=object,msg=string} "获取成功"
+// @Success 200 {object} response.Response{data=object,msg=string} "获取成功，ETag 响应头为修订号"
 // @Router /sugarWorkspaces/getWorkbookContent [get]
 func (sugarWorkspacesApi *SugarWorkspacesApi) GetWorkbookContent(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -313,12 +345,18 @@ func (sugarWorkspacesApi *SugarWorkspacesApi) GetWorkbookContent(c *gin.Context)
 	userId := utils.GetUserID(c)
 	userIdStr := strconv.Itoa(int(userId))
 
-	content, err := sugarWorkspacesService.GetWorkbookContent(ctx, id, userIdStr)
+	result, err := sugarWorkspacesService.GetWorkbookContent(ctx, id, userIdStr)
 	if err != nil {
 		global.GVA_LOG.Error("获取工作簿内容失败!", zap.Error(err))
 		response.FailWithMessage("获取失败:"+err.Error(), c)
 		return
 	}
 
-	response.OkWithData(content, c)
+	c.Header("ETag", workbookETag(result.Revision))
+	response.OkWithData(result, c)
+}
+
+// workbookETag 将修订号格式化为 ETag 响应头
+func workbookETag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
 }
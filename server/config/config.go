@@ -40,4 +40,7 @@ type Server struct {
 
 	// LLM配置
 	LLM LLM `mapstructure:"llm" json:"llm" yaml:"llm"`
+
+	// Sugar业务配置
+	Sugar Sugar `mapstructure:"sugar" json:"sugar" yaml:"sugar"`
 }
@@ -15,6 +15,8 @@ type OpenAIConfig struct {
 	BaseURL   string `mapstructure:"base-url" json:"base-url" yaml:"base-url"`       // OpenAI兼容的API地址
 	Token     string `mapstructure:"token" json:"token" yaml:"token"`                // API密钥或访问令牌
 	ModelName string `mapstructure:"model-name" json:"model-name" yaml:"model-name"` // 要使用的模型名称
+	// 结构化输出能力: json_schema, json_object, 留空表示仅通过提示词约束
+	StructuredOutput string `mapstructure:"structured-output" json:"structured-output" yaml:"structured-output"`
 }
 
 // MCPConfig MCP模式的LLM配置
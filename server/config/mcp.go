@@ -6,4 +6,5 @@ type MCP struct {
 	SSEPath     string `mapstructure:"sse_path" json:"sse_path" yaml:"sse_path"`             // SSE路径
 	MessagePath string `mapstructure:"message_path" json:"message_path" yaml:"message_path"` // 消息路径
 	UrlPrefix   string `mapstructure:"url_prefix" json:"url_prefix" yaml:"url_prefix"`       // URL前缀
+	RequireAuth bool   `mapstructure:"require_auth" json:"require_auth" yaml:"require_auth"` // 是否要求所有MCP连接携带JWT或API令牌
 }
@@ -0,0 +1,48 @@
+package config
+
+// Sugar Sugar业务配置
+type Sugar struct {
+	Anonymization Anonymization `mapstructure:"anonymization" json:"anonymization" yaml:"anonymization"`
+	PrivacyBudget PrivacyBudget `mapstructure:"privacy-budget" json:"privacy-budget" yaml:"privacy-budget"`
+	FileVersions  FileVersions  `mapstructure:"file-versions" json:"file-versions" yaml:"file-versions"`
+	Collaboration Collaboration `mapstructure:"collaboration" json:"collaboration" yaml:"collaboration"`
+	RecycleBin    RecycleBin    `mapstructure:"recycle-bin" json:"recycle-bin" yaml:"recycle-bin"`
+}
+
+// Anonymization 匿名化配置
+type Anonymization struct {
+	EncryptionKey         string `mapstructure:"encryption-key" json:"encryption-key" yaml:"encryption-key"`                            // 映射加密密钥，为空时由JWT签名密钥派生
+	RetentionDays         int    `mapstructure:"retention-days" json:"retention-days" yaml:"retention-days"`                            // 映射保留天数，到期后销毁加密映射，<=0 时使用默认30天
+	MetadataRetentionDays int    `mapstructure:"metadata-retention-days" json:"metadata-retention-days" yaml:"metadata-retention-days"` // 会话元数据保留天数，到期后删除记录，<=0 表示永久保留
+	ReviewerAuthorityIds  []uint `mapstructure:"reviewer-authority-ids" json:"reviewer-authority-ids" yaml:"reviewer-authority-ids"`    // 可重新解码他人执行日志的角色ID，为空时仅超级管理员(888)
+	LeakPolicy            string `mapstructure:"leak-policy" json:"leak-policy" yaml:"leak-policy"`                                     // 提示词中发现未匿名化的原始维度值时的处理策略：block 阻止调用，redact 替换为代号（默认）
+	Deterministic         bool   `mapstructure:"deterministic" json:"deterministic" yaml:"deterministic"`                               // 默认是否使用确定性匿名化，可由语义模型或Agent的匿名化策略覆盖
+}
+
+// PrivacyBudget 差分隐私预算配置，按 用户 × 语义模型 × 时间窗口 累计消耗
+type PrivacyBudget struct {
+	EpsilonPerQuery float64 `mapstructure:"epsilon-per-query" json:"epsilon-per-query" yaml:"epsilon-per-query"` // 每次AIFETCH分析消耗的ε，<=0 时使用默认0.5
+	BudgetPerWindow float64 `mapstructure:"budget-per-window" json:"budget-per-window" yaml:"budget-per-window"` // 每个时间窗口内的ε总预算，<=0 时使用默认5
+	WindowHours     int     `mapstructure:"window-hours" json:"window-hours" yaml:"window-hours"`                // 时间窗口长度（小时），<=0 时使用默认24小时
+	ExhaustedAction string  `mapstructure:"exhausted-action" json:"exhausted-action" yaml:"exhausted-action"`    // 预算耗尽时的处理：coarsen 只返回定性结论（默认），refuse 拒绝分析
+}
+
+// FileVersions 工作簿历史版本配置
+type FileVersions struct {
+	CoalesceMinutes int `mapstructure:"coalesce-minutes" json:"coalesce-minutes" yaml:"coalesce-minutes"` // 同一用户在该时间内的连续自动保存合并为一个版本，<=0 时使用默认5分钟
+	MaxVersions     int `mapstructure:"max-versions" json:"max-versions" yaml:"max-versions"`             // 每个文件保留的未命名版本数上限，<=0 时使用默认100
+	RetentionDays   int `mapstructure:"retention-days" json:"retention-days" yaml:"retention-days"`       // 未命名版本的保留天数，<=0 时使用默认90天；命名或置顶的版本和最新版本始终保留
+}
+
+// Collaboration 工作簿实时协作配置
+type Collaboration struct {
+	Broker                 string `mapstructure:"broker" json:"broker" yaml:"broker"`                                                       // 协作消息的分发方式：memory 仅在本进程内分发（默认），redis 通过 Redis 发布订阅在多个实例之间转发
+	RedisName              string `mapstructure:"redis-name" json:"redis-name" yaml:"redis-name"`                                           // broker 为 redis 时使用 redis-list 中的实例，为空时使用默认 redis
+	CompactOperations      int    `mapstructure:"compact-operations" json:"compact-operations" yaml:"compact-operations"`                   // 未压缩的操作达到该数量时请求内容快照，<=0 时使用默认200
+	CompactIntervalSeconds int    `mapstructure:"compact-interval-seconds" json:"compact-interval-seconds" yaml:"compact-interval-seconds"` // 有未压缩的操作时请求内容快照的间隔（秒），<=0 时使用默认60秒
+}
+
+// RecycleBin 工作区回收站配置
+type RecycleBin struct {
+	RetentionDays int `mapstructure:"retention-days" json:"retention-days" yaml:"retention-days"` // 删除的文件和文件夹在回收站中的保留天数，到期后由定时任务彻底删除，<=0 时使用默认30天
+}
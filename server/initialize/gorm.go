@@ -6,6 +6,7 @@ import (
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/example"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"github.com/flipped-aurora/gin-vue-admin/server/task"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -74,5 +75,13 @@ func RegisterTables() {
 	// 	global.GVA_LOG.Error("register biz_table failed", zap.Error(err))
 	// 	os.Exit(0)
 	// }
+
+	// 旧版本按字段名识别期初/期末对比，升级后为语义模型补充显式声明
+	if err = task.BackfillAnalysisSemantics(db); err != nil {
+		global.GVA_LOG.Error("backfill analysis semantics failed", zap.Error(err))
+	}
+	if err = task.MigrateCommenterGrants(db); err != nil {
+		global.GVA_LOG.Error("migrate commenter grants failed", zap.Error(err))
+	}
 	global.GVA_LOG.Info("register table success")
 }
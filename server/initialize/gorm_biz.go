@@ -7,7 +7,7 @@ import (
 
 func bizModel() error {
 	db := global.GVA_DB
-	err := db.AutoMigrate(sugar.SugarTeams{}, sugar.SugarTeamMembers{}, sugar.SugarDbConnections{}, sugar.SugarSemanticModels{}, sugar.SugarAgents{}, sugar.SugarCityPermissions{}, sugar.SugarRowLevelOverrides{}, sugar.SugarExecutionLogs{}, sugar.SugarWorkspaces{})
+	err := db.AutoMigrate(sugar.SugarTeams{}, sugar.SugarTeamMembers{}, sugar.SugarDbConnections{}, sugar.SugarSemanticModels{}, sugar.SugarAgents{}, sugar.SugarCityPermissions{}, sugar.SugarRowLevelOverrides{}, sugar.SugarExecutionLogs{}, sugar.SugarWorkspaces{}, sugar.SugarApiTokens{}, sugar.SugarAnonymizationSessions{}, sugar.SugarPrivacyBudgetLedgers{}, sugar.SugarFileVersions{}, sugar.SugarWorkbookOperations{}, sugar.SugarWorkspacePermissions{}, sugar.SugarShareLinks{}, sugar.SugarWorkbookTemplates{}, sugar.SugarWorkbookSearchDocuments{}, sugar.SugarWorkbookSearchTerms{}, sugar.SugarWorkbookReferences{})
 	if err != nil {
 		return err
 	}
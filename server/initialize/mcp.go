@@ -9,17 +9,14 @@ import (
 func McpRun() *server.SSEServer {
 	config := global.GVA_CONFIG.MCP
 
-	s := server.NewMCPServer(
-		config.Name,
-		config.Version,
-	)
+	// 创建MCP服务并注册全部工具，会话建立时绑定认证身份
+	s := mcpTool.NewServer(config.Name, config.Version)
 
 	global.GVA_MCP_SERVER = s
 
-	mcpTool.RegisterAllTools(s)
-
 	return server.NewSSEServer(s,
 		server.WithSSEEndpoint(config.SSEPath),
 		server.WithMessageEndpoint(config.MessagePath),
-		server.WithBaseURL(config.UrlPrefix))
+		server.WithBaseURL(config.UrlPrefix),
+		server.WithSSEContextFunc(mcpTool.SSEContextFunc))
 }
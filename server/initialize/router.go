@@ -6,6 +6,7 @@ import (
 
 	"github.com/flipped-aurora/gin-vue-admin/server/docs"
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
 	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
 	"github.com/flipped-aurora/gin-vue-admin/server/router"
 	"github.com/gin-gonic/gin"
@@ -42,13 +43,15 @@ func Routers() *gin.Engine {
 
 	sseServer := McpRun()
 
-	// 注册mcp服务
+	// 注册mcp服务，连接和消息请求均支持 JWT 或 API令牌 认证
+	sseHandler := mcpTool.AuthMiddleware(global.GVA_CONFIG.MCP.RequireAuth, sseServer.SSEHandler())
+	messageHandler := mcpTool.AuthMiddleware(global.GVA_CONFIG.MCP.RequireAuth, sseServer.MessageHandler())
 	Router.GET(global.GVA_CONFIG.MCP.SSEPath, func(c *gin.Context) {
-		sseServer.SSEHandler().ServeHTTP(c.Writer, c.Request)
+		sseHandler.ServeHTTP(c.Writer, c.Request)
 	})
 
 	Router.POST(global.GVA_CONFIG.MCP.MessagePath, func(c *gin.Context) {
-		sseServer.MessageHandler().ServeHTTP(c.Writer, c.Request)
+		messageHandler.ServeHTTP(c.Writer, c.Request)
 	})
 
 	systemRouter := router.RouterGroupApp.System
@@ -26,5 +26,16 @@ func initBizRouter(routers ...*gin.RouterGroup) {
 		sugarRouter.InitSugarWorkspacesRouter(privateGroup, publicGroup)
 		sugarRouter.InitSugarFormulaQueryRouter(privateGroup, publicGroup) // Sugar公式查询路由
 		sugarRouter.InitSugarFoldersRouter(privateGroup, publicGroup)      // Sugar文件夹管理路由
+		sugarRouter.InitSugarApiTokensRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarAnonymizationSessionsRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarPrivacyBudgetRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarFileVersionsRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarWorkspacePermissionsRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarShareLinksRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarRecycleBinRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarWorkbookTemplatesRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarWorkbookSearchRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarWorkbookLineageRouter(privateGroup, publicGroup)
+		sugarRouter.InitSugarWorkbookCollaborationRouter(privateGroup, publicGroup)
 	}
 }
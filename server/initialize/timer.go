@@ -24,6 +24,28 @@ func Timer() {
 			fmt.Println("add timer error:", err)
 		}
 
+		// 销毁过期的匿名化映射
+		_, err = global.GVA_Timer.AddTaskByFunc("PurgeAnonymizationSessions", "@daily", func() {
+			err := task.PurgeAnonymizationSessions(global.GVA_DB)
+			if err != nil {
+				fmt.Println("timer error:", err)
+			}
+		}, "定时销毁超过保留期限的匿名化映射", option...)
+		if err != nil {
+			fmt.Println("add timer error:", err)
+		}
+
+		// 彻底删除回收站中超过保留期限的项目
+		_, err = global.GVA_Timer.AddTaskByFunc("PurgeSugarRecycleBin", "@daily", func() {
+			err := task.PurgeSugarRecycleBin(global.GVA_DB)
+			if err != nil {
+				fmt.Println("timer error:", err)
+			}
+		}, "定时彻底删除回收站中超过保留期限的文件", option...)
+		if err != nil {
+			fmt.Println("add timer error:", err)
+		}
+
 		// 其他定时任务定在这里 参考上方使用方法
 
 		//_, err := global.GVA_Timer.AddTaskByFunc("定时任务标识", "corn表达式", func() {
@@ -0,0 +1,127 @@
+package mcpTool
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AIFETCH 分析工具，schema 在此处声明，执行器由 sugar 服务绑定；依赖AIFETCH上下文，仅供进程内调用
+// 用户身份由执行器从 context 中获取，不作为参数暴露给模型，避免被注入或伪造的用户ID参与鉴权
+func init() {
+	RegisterInternalTool(newSmartAnonymizedAnalyzerTool())
+	RegisterInternalTool(newDataScopeExplorerTool())
+	RegisterInternalTool(newAnonymizedDataAnalyzerTool())
+}
+
+// groupByDimensionsDescription 分组维度参数说明，强调语义顺序以便匿名化还原后语句通顺
+const groupByDimensionsDescription = "进行分组和归因分析的维度列名列表，如 ['区域', '产品类别']。**重要：请按照业务逻辑的语义顺序排列维度，这样有利于后续匿名化还原时保持语句的通顺性。常见排序原则：组织机构→业务分类→具体属性。示例：固定资产分析应按 ['使用部门', '资产类型', '折旧年限'] 顺序（部门→资产→属性），货币资金分析应按 ['银行名称', '账户类型', '币种'] 顺序。**"
+
+// stringItems 字符串数组的元素定义
+var stringItems = map[string]interface{}{"type": "string"}
+
+// timeIntelligenceDescription 时间智能参数说明
+const timeIntelligenceDescription = "可选的时间智能函数。提供后本期与对比期由服务端按模型声明的日期字段和财年口径计算，筛选条件中无需再填写时间条件。YOY 同比、MOM 月环比、QOQ 季环比、YTD 财年初至今、PTD 期间初至今、ROLLING 最近N期、SPLY 上年同期。"
+
+// timeIntelligenceProperties 时间智能参数的属性定义
+var timeIntelligenceProperties = map[string]interface{}{
+	"function": map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"YOY", "MOM", "QOQ", "YTD", "PTD", "ROLLING", "SPLY"},
+		"description": "时间智能函数。",
+	},
+	"anchorDate": map[string]interface{}{
+		"type":        "string",
+		"description": "锚定日期，格式 YYYY-MM-DD；YTD/PTD 默认为当天，其他函数默认为上一个完整期间。例如分析2024年3月同比时填写 2024-03-31。",
+	},
+	"grain": map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"day", "week", "month", "quarter", "year"},
+		"description": "期间粒度，YOY/PTD/ROLLING/SPLY 使用，默认为模型声明的粒度。",
+	},
+	"periods": map[string]interface{}{
+		"type":        "integer",
+		"description": "ROLLING 的期数，默认12。",
+	},
+}
+
+func newSmartAnonymizedAnalyzerTool() mcp.Tool {
+	return mcp.NewTool("smart_anonymized_analyzer",
+		mcp.WithDescription("智能匿名化数据分析工具，自动进行数据范围探索和匿名化分析的完整流程。该工具会先验证数据可用性，然后进行匿名化贡献度分析，确保数据安全和分析准确性。调用时请确保维度按语义逻辑顺序排列。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("要分析的语义模型名称。"),
+		),
+		mcp.WithString("targetMetric",
+			mcp.Required(),
+			mcp.Description("需要分析的核心指标列名，例如 '销售金额'、'利润' 等。"),
+		),
+		mcp.WithObject("currentPeriodFilters",
+			mcp.Required(),
+			mcp.Description("获取本期数据的筛选条件，格式为 {\"列名\": \"筛选值\"}。"),
+		),
+		mcp.WithObject("basePeriodFilters",
+			mcp.Required(),
+			mcp.Description("获取基期（如上期、预算）数据的筛选条件，格式为 {\"列名\": \"筛选值\"}；使用 timeIntelligence 时可传 {}，沿用本期的非时间条件。"),
+		),
+		mcp.WithObject("timeIntelligence",
+			mcp.Properties(timeIntelligenceProperties),
+			mcp.Description(timeIntelligenceDescription),
+		),
+		mcp.WithArray("groupByDimensions",
+			mcp.Required(),
+			mcp.Items(stringItems),
+			mcp.Description(groupByDimensionsDescription),
+		),
+		mcp.WithBoolean("enableDataValidation",
+			mcp.Description("是否启用数据范围验证，默认为true。启用后会先验证筛选条件的有效性。"),
+		),
+	)
+}
+
+func newDataScopeExplorerTool() mcp.Tool {
+	return mcp.NewTool("data_scope_explorer",
+		mcp.WithDescription("数据范围探索工具，用于查看语义模型中各维度的可用取值和数据量，帮助确定合理的筛选条件。仅做数据探索，不进行贡献度分析。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("要探索的语义模型名称。"),
+		),
+		mcp.WithArray("exploreDimensions",
+			mcp.Required(),
+			mcp.Items(stringItems),
+			mcp.Description("需要查看可用取值的维度列名列表。"),
+		),
+		mcp.WithObject("sampleFilters",
+			mcp.Description("可选的示例筛选条件，格式为 {\"列名\": \"筛选值\"}。"),
+		),
+	)
+}
+
+func newAnonymizedDataAnalyzerTool() mcp.Tool {
+	return mcp.NewTool("anonymized_data_analyzer",
+		mcp.WithDescription("匿名化数据分析工具，直接获取本期和基期数据进行贡献度分析并以匿名化形式交给模型分析，不做数据可用性验证。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("要分析的语义模型名称。"),
+		),
+		mcp.WithString("targetMetric",
+			mcp.Required(),
+			mcp.Description("需要分析的核心指标列名。"),
+		),
+		mcp.WithObject("currentPeriodFilters",
+			mcp.Required(),
+			mcp.Description("获取本期数据的筛选条件，格式为 {\"列名\": \"筛选值\"}。"),
+		),
+		mcp.WithObject("basePeriodFilters",
+			mcp.Required(),
+			mcp.Description("获取基期数据的筛选条件，格式为 {\"列名\": \"筛选值\"}；使用 timeIntelligence 时可传 {}，沿用本期的非时间条件。"),
+		),
+		mcp.WithObject("timeIntelligence",
+			mcp.Properties(timeIntelligenceProperties),
+			mcp.Description(timeIntelligenceDescription),
+		),
+		mcp.WithArray("groupByDimensions",
+			mcp.Required(),
+			mcp.Items(stringItems),
+			mcp.Description(groupByDimensionsDescription),
+		),
+	)
+}
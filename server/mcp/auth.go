@@ -0,0 +1,172 @@
+package mcpTool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// 认证方式
+const (
+	AuthMethodJWT      = "jwt"
+	AuthMethodApiToken = "api_token"
+)
+
+// Identity MCP会话的已认证身份
+type Identity struct {
+	UserId      uint   `json:"userId"`
+	Username    string `json:"username"`
+	AuthorityId uint   `json:"authorityId"`
+	AuthMethod  string `json:"authMethod"` // jwt 或 api_token
+}
+
+// UserIdString 返回字符串形式的用户ID，与 sugar 服务中的 userId 参数保持一致
+func (i *Identity) UserIdString() string {
+	return strconv.FormatUint(uint64(i.UserId), 10)
+}
+
+// Authenticator 令牌认证器接口，由上层服务实现（JWT、API令牌等），通过 SetAuthenticator 绑定
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// ErrUnauthenticated 需要认证但未提供有效身份
+var ErrUnauthenticated = errors.New("未认证的MCP会话，请使用 Authorization: Bearer <JWT或API令牌> 连接")
+
+var (
+	authenticator   Authenticator
+	authenticatorMu sync.RWMutex
+	sessionTokens   sync.Map // sessionId -> 建立SSE连接时使用的令牌
+)
+
+type identityKey struct{}
+
+type tokenKey struct{}
+
+// SetAuthenticator 绑定令牌认证器
+func SetAuthenticator(a Authenticator) {
+	authenticatorMu.Lock()
+	defer authenticatorMu.Unlock()
+	authenticator = a
+}
+
+// WithIdentity 将身份写入 context
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext 从 context 中读取身份
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
+
+// RequireIdentity 读取身份，不存在时返回 ErrUnauthenticated
+func RequireIdentity(ctx context.Context) (*Identity, error) {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return identity, nil
+}
+
+// TokenFromRequest 从请求头中提取令牌，依次尝试 Authorization: Bearer 和 x-token
+// 不接受查询参数中的令牌，避免长期有效的凭据出现在访问日志和代理记录中
+func TokenFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+	}
+	return r.Header.Get("x-token")
+}
+
+// authenticate 使用绑定的认证器校验令牌
+func authenticate(ctx context.Context, token string) (*Identity, error) {
+	authenticatorMu.RLock()
+	a := authenticator
+	authenticatorMu.RUnlock()
+	if a == nil {
+		return nil, errors.New("MCP服务未配置认证器")
+	}
+	return a.Authenticate(ctx, token)
+}
+
+// AuthenticateRequest 认证HTTP请求；未携带令牌时返回 (nil, nil)
+func AuthenticateRequest(r *http.Request) (*Identity, error) {
+	token := TokenFromRequest(r)
+	if token == "" {
+		return nil, nil
+	}
+	return authenticate(r.Context(), token)
+}
+
+// AuthMiddleware 在SSE连接和消息请求上执行认证，认证通过的身份写入请求 context
+// requireAuth 为 true 时拒绝未携带令牌的请求
+func AuthMiddleware(requireAuth bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := AuthenticateRequest(r)
+		if err != nil {
+			writeAuthError(w, err.Error())
+			return
+		}
+		if identity == nil {
+			if requireAuth {
+				writeAuthError(w, ErrUnauthenticated.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(WithIdentity(r.Context(), identity), tokenKey{}, TokenFromRequest(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// writeAuthError 返回401错误
+func writeAuthError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// SSEContextFunc 为每条MCP消息补全身份：消息请求自身携带令牌时以其为准，
+// 否则重新校验建立SSE连接时使用的令牌，令牌过期、被注销或用户被冻结后会话中的后续调用不再具有身份
+func SSEContextFunc(ctx context.Context, r *http.Request) context.Context {
+	if _, ok := IdentityFromContext(ctx); ok {
+		return ctx
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		if token, ok := sessionTokens.Load(session.SessionID()); ok {
+			identity, err := authenticate(ctx, token.(string))
+			if err != nil {
+				return ctx
+			}
+			return WithIdentity(ctx, identity)
+		}
+	}
+	return ctx
+}
+
+// NewServer 创建注册了全部工具、并在会话建立时绑定身份的MCP服务
+func NewServer(name, version string) *server.MCPServer {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		if token, ok := ctx.Value(tokenKey{}).(string); ok && token != "" {
+			sessionTokens.Store(session.SessionID(), token)
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		sessionTokens.Delete(session.SessionID())
+	})
+
+	s := server.NewMCPServer(name, version, server.WithHooks(hooks))
+	RegisterAllTools(s)
+	return s
+}
@@ -0,0 +1,86 @@
+package mcpTool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// tokenAuthenticator 测试认证器：valid 中的令牌有效
+type tokenAuthenticator struct {
+	valid map[string]uint
+}
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if userId, ok := a.valid[token]; ok {
+		return &Identity{UserId: userId, AuthMethod: AuthMethodJWT}, nil
+	}
+	return nil, errors.New("令牌无效")
+}
+
+type testSession struct{ id string }
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return make(chan mcp.JSONRPCNotification, 1)
+}
+func (s *testSession) SessionID() string { return s.id }
+
+func TestTokenFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/sse?token=from-query", nil)
+	if token := TokenFromRequest(r); token != "" {
+		t.Fatalf("不应接受查询参数中的令牌: %q", token)
+	}
+	r.Header.Set("x-token", "from-header")
+	if token := TokenFromRequest(r); token != "from-header" {
+		t.Fatalf("应读取 x-token 头: %q", token)
+	}
+	r.Header.Set("Authorization", "bearer from-bearer")
+	if token := TokenFromRequest(r); token != "from-bearer" {
+		t.Fatalf("应优先读取 Authorization 头: %q", token)
+	}
+}
+
+func TestSessionIdentityRevalidated(t *testing.T) {
+	auth := &tokenAuthenticator{valid: map[string]uint{"session-token": 7}}
+	SetAuthenticator(auth)
+	defer SetAuthenticator(nil)
+
+	s := NewServer("test", "1.0")
+	session := &testSession{id: "session-1"}
+
+	// 建立SSE连接时通过认证，会话记录连接使用的令牌
+	var registerCtx context.Context
+	handler := AuthMiddleware(true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registerCtx = r.Context()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/sse", nil)
+	r.Header.Set("Authorization", "Bearer session-token")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if registerCtx == nil {
+		t.Fatal("携带有效令牌的连接应通过认证")
+	}
+	if err := s.RegisterSession(registerCtx, session); err != nil {
+		t.Fatalf("注册会话失败: %v", err)
+	}
+	defer s.UnregisterSession(context.Background(), session.id)
+
+	// 消息请求未携带令牌时使用会话令牌的身份
+	message := httptest.NewRequest(http.MethodPost, "/message", nil)
+	ctx := SSEContextFunc(s.WithContext(context.Background(), session), message)
+	if identity, err := RequireIdentity(ctx); err != nil || identity.UserId != 7 {
+		t.Fatalf("应使用会话的身份: %+v %v", identity, err)
+	}
+
+	// 令牌失效后，同一会话中的后续调用不再具有身份
+	delete(auth.valid, "session-token")
+	ctx = SSEContextFunc(s.WithContext(context.Background(), session), message)
+	if _, err := RequireIdentity(ctx); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("令牌失效后会话不应保留身份: %v", err)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	mcpClient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-func NewClient(baseUrl, name, version, serverName string) (*mcpClient.Client, error) {
-	client, err := mcpClient.NewSSEMCPClient(baseUrl)
+// NewClient 创建并初始化SSE客户端，options 可用于附加认证头等传输选项，如 mcpClient.WithHeaders
+func NewClient(baseUrl, name, version, serverName string, options ...transport.ClientOption) (*mcpClient.Client, error) {
+	client, err := mcpClient.NewSSEMCPClient(baseUrl, options...)
 	if err != nil {
 		return nil, err
 	}
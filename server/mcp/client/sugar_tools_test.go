@@ -0,0 +1,384 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/system/request"
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/glebarez/sqlite"
+	mcpClient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/songzhibin97/gkit/cache/local_cache"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const testServerName = "sugar-mcp-test"
+
+// setupSugarToolsDB 初始化内存数据库：alice(1) 只能看北京，bob(2) 只能看上海，carol(3) 未加入团队
+func setupSugarToolsDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	// 贡献度分析并发查询本期和基期，内存库只能共享同一连接
+	sqlDB.SetMaxOpenConns(1)
+
+	statements := []string{
+		`CREATE TABLE sys_users (id INTEGER PRIMARY KEY, username TEXT, authority_id INTEGER, enable INTEGER, deleted_at DATETIME)`,
+		`CREATE TABLE sugar_team_members (id INTEGER PRIMARY KEY, team_id TEXT, user_id TEXT, role TEXT)`,
+		`CREATE TABLE sugar_row_level_overrides (id INTEGER PRIMARY KEY, user_id TEXT)`,
+		`CREATE TABLE sugar_city_permissions (id INTEGER PRIMARY KEY, user_id TEXT, city_code TEXT)`,
+		`CREATE TABLE sales_facts (city_code TEXT, product TEXT, period TEXT, amount REAL)`,
+		`INSERT INTO sys_users (id, username, authority_id, enable) VALUES (1, 'alice', 888, 1), (2, 'bob', 888, 1), (3, 'carol', 888, 1)`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor'), ('team-1', '2', 'viewer')`,
+		`INSERT INTO sugar_city_permissions (user_id, city_code) VALUES ('1', 'BJ'), ('2', 'SH')`,
+		`INSERT INTO sales_facts VALUES
+			('BJ', 'A', '2024Q1', 100), ('BJ', 'A', '2024Q2', 150),
+			('BJ', 'B', '2024Q1', 50), ('BJ', 'B', '2024Q2', 40),
+			('SH', 'A', '2024Q1', 200), ('SH', 'A', '2024Q2', 260)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("初始化数据失败: %v\n%s", err, statement)
+		}
+	}
+	if err := db.AutoMigrate(&sugar.SugarSemanticModels{}, &sugar.SugarApiTokens{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+
+	id, name, description, teamId, table, permissionKey := "model-1", "销售事实", "按城市、产品和期间统计的销售额", "team-1", "sales_facts", "city_code"
+	model := sugar.SugarSemanticModels{
+		Id:                  &id,
+		Name:                &name,
+		Description:         &description,
+		TeamId:              &teamId,
+		SourceTableName:     &table,
+		PermissionKeyColumn: &permissionKey,
+		ParameterConfig:     []byte(`{"期间": {"column": "period", "operator": "=", "description": "统计期间"}, "产品": {"column": "product", "operator": "="}}`),
+		ReturnableColumnsConfig: []byte(`{
+			"城市": {"column": "city_code", "type": "dimension"},
+			"产品": {"column": "product", "type": "dimension"},
+			"期间": {"column": "period", "type": "dimension"},
+			"销售额": {"column": "amount", "type": "metric", "description": "销售金额"}
+		}`),
+	}
+	if err := db.Create(&model).Error; err != nil {
+		t.Fatalf("创建语义模型失败: %v", err)
+	}
+
+	global.GVA_DB = db
+	global.GVA_LOG = zap.NewNop()
+	global.BlackCache = local_cache.NewCache()
+	global.GVA_CONFIG.JWT.SigningKey = "sugar-mcp-test"
+	global.GVA_CONFIG.JWT.ExpiresTime = "1d"
+	global.GVA_CONFIG.JWT.BufferTime = "1h"
+}
+
+// startSugarToolsServer 启动带认证中间件的SSE服务
+func startSugarToolsServer(t *testing.T, requireAuth bool) string {
+	t.Helper()
+	var sseServer *server.SSEServer
+	mux := http.NewServeMux()
+	mux.Handle("/sse", mcpTool.AuthMiddleware(requireAuth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sseServer.SSEHandler().ServeHTTP(w, r)
+	})))
+	mux.Handle("/message", mcpTool.AuthMiddleware(requireAuth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sseServer.MessageHandler().ServeHTTP(w, r)
+	})))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	sseServer = server.NewSSEServer(mcpTool.NewServer(testServerName, "1.0.0"),
+		server.WithBaseURL(ts.URL),
+		server.WithSSEContextFunc(mcpTool.SSEContextFunc),
+	)
+	return ts.URL
+}
+
+func connect(t *testing.T, baseURL, token string) *mcpClient.Client {
+	t.Helper()
+	headers := map[string]string{}
+	if token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	c, err := NewClient(baseURL+"/sse", "test-client", "1.0.0", testServerName, mcpClient.WithHeaders(headers))
+	if err != nil {
+		t.Fatalf("连接MCP服务失败: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func issueJWT(t *testing.T, userId uint, username string) string {
+	t.Helper()
+	j := utils.NewJWT()
+	token, err := j.CreateToken(j.CreateClaims(request.BaseClaims{ID: userId, Username: username, AuthorityId: 888}))
+	if err != nil {
+		t.Fatalf("签发JWT失败: %v", err)
+	}
+	return token
+}
+
+func issueApiToken(t *testing.T, userId string) string {
+	t.Helper()
+	var service sugarService.SugarApiTokensService
+	resp, err := service.CreateApiToken(context.Background(), &sugarReq.SugarApiTokensCreateRequest{Name: "agent"}, userId)
+	if err != nil {
+		t.Fatalf("创建API令牌失败: %v", err)
+	}
+	return resp.Token
+}
+
+// callTool 调用工具并返回文本结果
+func callTool(t *testing.T, c *mcpClient.Client, name string, args map[string]interface{}) (string, bool) {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	result, err := c.CallTool(context.Background(), req)
+	if err != nil {
+		t.Fatalf("调用工具 %s 失败: %v", name, err)
+	}
+	if len(result.Content) == 0 {
+		t.Fatalf("工具 %s 没有返回内容", name)
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("工具 %s 返回了非文本内容: %#v", name, result.Content[0])
+	}
+	return text.Text, result.IsError
+}
+
+func callToolJSON(t *testing.T, c *mcpClient.Client, name string, args map[string]interface{}, out interface{}) {
+	t.Helper()
+	text, isError := callTool(t, c, name, args)
+	if isError {
+		t.Fatalf("工具 %s 返回错误: %s", name, text)
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		t.Fatalf("解析工具 %s 结果失败: %v\n%s", name, err, text)
+	}
+}
+
+func TestSugarToolsListing(t *testing.T) {
+	setupSugarToolsDB(t)
+	c := connect(t, startSugarToolsServer(t, true), issueJWT(t, 1, "alice"))
+
+	result, err := c.ListTools(context.Background(), mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatalf("获取工具列表失败: %v", err)
+	}
+	names := map[string]bool{}
+	for _, tool := range result.Tools {
+		names[tool.Name] = true
+	}
+	for _, name := range []string{"sugar_list_models", "sugar_describe_model", "sugar_get", "sugar_calc", "sugar_contribution_analysis"} {
+		if !names[name] {
+			t.Errorf("工具列表缺少 %s", name)
+		}
+	}
+	for _, name := range []string{"smart_anonymized_analyzer", "data_scope_explorer", "anonymized_data_analyzer"} {
+		if names[name] {
+			t.Errorf("内部工具 %s 不应对外暴露", name)
+		}
+	}
+}
+
+func TestSugarToolsWithJWT(t *testing.T) {
+	setupSugarToolsDB(t)
+	c := connect(t, startSugarToolsServer(t, true), issueJWT(t, 1, "alice"))
+
+	var models []map[string]interface{}
+	callToolJSON(t, c, "sugar_list_models", map[string]interface{}{"keyword": "销售"}, &models)
+	if len(models) != 1 || models[0]["name"] != "销售事实" {
+		t.Fatalf("模型列表不正确: %v", models)
+	}
+
+	var description struct {
+		Dimensions []struct{ Name string } `json:"dimensions"`
+		Metrics    []struct{ Name string } `json:"metrics"`
+		Parameters []struct{ Name, Operator string }
+	}
+	callToolJSON(t, c, "sugar_describe_model", map[string]interface{}{"modelName": "销售事实"}, &description)
+	if len(description.Dimensions) != 3 || len(description.Metrics) != 1 || description.Metrics[0].Name != "销售额" {
+		t.Fatalf("模型结构不正确: %+v", description)
+	}
+	if len(description.Parameters) != 2 || description.Parameters[0].Operator != "=" {
+		t.Fatalf("模型参数不正确: %+v", description.Parameters)
+	}
+
+	var got struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	callToolJSON(t, c, "sugar_get", map[string]interface{}{
+		"modelName":     "销售事实",
+		"returnColumns": []string{"产品", "销售额"},
+		"filters":       map[string]interface{}{"期间": "2024Q2"},
+		"groupBy":       []string{"产品"},
+	}, &got)
+	amounts := map[string]float64{}
+	for _, row := range got.Results {
+		amounts[row["产品"].(string)] = row["销售额"].(float64)
+	}
+	// 上海的产品A(260)不在 alice 的行级权限内
+	if len(amounts) != 2 || amounts["A"] != 150 || amounts["B"] != 40 {
+		t.Fatalf("SUGAR.GET 结果未按行级权限过滤: %v", got.Results)
+	}
+
+	var calc struct {
+		Result float64 `json:"result"`
+	}
+	callToolJSON(t, c, "sugar_calc", map[string]interface{}{
+		"modelName":  "销售事实",
+		"calcColumn": "销售额",
+		"calcMethod": "sum",
+	}, &calc)
+	if calc.Result != 340 {
+		t.Fatalf("SUGAR.CALC 结果应为北京合计 340，实际为 %v", calc.Result)
+	}
+
+	var contribution struct {
+		CurrentTotal float64 `json:"currentTotal"`
+		BaseTotal    float64 `json:"baseTotal"`
+		TotalChange  float64 `json:"totalChange"`
+		Items        []struct {
+			Dimensions  map[string]interface{} `json:"dimensions"`
+			ChangeValue float64                `json:"changeValue"`
+		} `json:"items"`
+	}
+	callToolJSON(t, c, "sugar_contribution_analysis", map[string]interface{}{
+		"modelName":            "销售事实",
+		"targetMetric":         "销售额",
+		"currentPeriodFilters": map[string]interface{}{"期间": "2024Q2"},
+		"basePeriodFilters":    map[string]interface{}{"期间": "2024Q1"},
+		"groupByDimensions":    []string{"产品"},
+		"topN":                 1,
+	}, &contribution)
+	if contribution.CurrentTotal != 190 || contribution.BaseTotal != 150 || contribution.TotalChange != 40 {
+		t.Fatalf("贡献度分析汇总不正确: %+v", contribution)
+	}
+	if len(contribution.Items) != 1 || contribution.Items[0].Dimensions["产品"] != "A" || contribution.Items[0].ChangeValue != 50 {
+		t.Fatalf("贡献度分析明细不正确: %+v", contribution.Items)
+	}
+}
+
+func TestSugarToolsWithApiToken(t *testing.T) {
+	setupSugarToolsDB(t)
+	baseURL := startSugarToolsServer(t, true)
+
+	bob := connect(t, baseURL, issueApiToken(t, "2"))
+	var calc struct {
+		Result float64 `json:"result"`
+	}
+	callToolJSON(t, bob, "sugar_calc", map[string]interface{}{
+		"modelName":  "销售事实",
+		"calcColumn": "销售额",
+		"calcMethod": "SUM",
+	}, &calc)
+	if calc.Result != 460 {
+		t.Fatalf("bob 只能看到上海数据 460，实际为 %v", calc.Result)
+	}
+
+	carol := connect(t, baseURL, issueApiToken(t, "3"))
+	var models []map[string]interface{}
+	callToolJSON(t, carol, "sugar_list_models", nil, &models)
+	if len(models) != 0 {
+		t.Fatalf("未加入团队的用户不应看到模型: %v", models)
+	}
+	if text, isError := callTool(t, carol, "sugar_describe_model", map[string]interface{}{"modelName": "销售事实"}); !isError {
+		t.Fatalf("未加入团队的用户不应能查看模型: %s", text)
+	}
+}
+
+func TestSugarToolsRejectUnauthenticated(t *testing.T) {
+	setupSugarToolsDB(t)
+	baseURL := startSugarToolsServer(t, true)
+
+	for name, token := range map[string]string{
+		"无令牌":     "",
+		"无效JWT":   "not-a-jwt",
+		"无效API令牌": "sgr_0000",
+	} {
+		if _, err := NewClient(baseURL+"/sse", "test-client", "1.0.0", testServerName, mcpClient.WithHeaders(map[string]string{"Authorization": "Bearer " + token})); err == nil {
+			t.Errorf("%s: 应拒绝连接", name)
+		}
+	}
+
+	token := issueApiToken(t, "1")
+	var service sugarService.SugarApiTokensService
+	tokens, err := service.GetApiTokenList(context.Background(), "1")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("获取令牌列表失败: %v", err)
+	}
+	if err := service.RevokeApiToken(context.Background(), *tokens[0].Id, "1"); err != nil {
+		t.Fatalf("吊销令牌失败: %v", err)
+	}
+	if _, err := NewClient(baseURL+"/sse", "test-client", "1.0.0", testServerName, mcpClient.WithHeaders(map[string]string{"Authorization": "Bearer " + token})); err == nil {
+		t.Error("已吊销的API令牌应被拒绝")
+	}
+}
+
+func TestSugarToolsRequireIdentityWhenAuthOptional(t *testing.T) {
+	setupSugarToolsDB(t)
+	c := connect(t, startSugarToolsServer(t, false), "")
+
+	text, isError := callTool(t, c, "sugar_calc", map[string]interface{}{
+		"modelName":  "销售事实",
+		"calcColumn": "销售额",
+		"calcMethod": "SUM",
+	})
+	if !isError || !strings.Contains(text, "未认证") {
+		t.Fatalf("匿名会话调用工具应返回未认证错误，实际: %s", text)
+	}
+}
+
+func TestSugarToolsIgnoreUserIdArgument(t *testing.T) {
+	setupSugarToolsDB(t)
+	alice := connect(t, startSugarToolsServer(t, true), issueJWT(t, 1, "alice"))
+
+	// alice 在参数中伪造 bob 的用户ID，查询仍按 alice 的身份执行行级权限
+	var calc struct {
+		Result float64 `json:"result"`
+	}
+	callToolJSON(t, alice, "sugar_calc", map[string]interface{}{
+		"modelName":  "销售事实",
+		"calcColumn": "销售额",
+		"calcMethod": "SUM",
+		"userId":     "2",
+	}, &calc)
+	if calc.Result != 340 {
+		t.Fatalf("伪造的 userId 不应生效，期望北京合计 340，实际为 %v", calc.Result)
+	}
+
+	var got struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	callToolJSON(t, alice, "sugar_get", map[string]interface{}{
+		"modelName":     "销售事实",
+		"returnColumns": []string{"城市", "销售额"},
+		"groupBy":       []string{"城市"},
+		"userId":        "2",
+	}, &got)
+	for _, row := range got.Results {
+		if row["城市"] != "BJ" {
+			t.Fatalf("伪造的 userId 读取到了其他用户的数据: %v", got.Results)
+		}
+	}
+}
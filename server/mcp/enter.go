@@ -2,7 +2,10 @@ package mcpTool
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -16,18 +19,66 @@ type McpTool interface {
 	New() mcp.Tool
 }
 
+// ToolExecutor 工具执行器接口
+// 本包只负责声明工具的 schema，执行逻辑由上层服务实现后通过 BindExecutor 绑定，
+// 因此 mcpTool 包不需要（也不允许）引用 service 层
+type ToolExecutor interface {
+	Execute(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+}
+
+// ToolExecutorFunc 函数形式的工具执行器
+type ToolExecutorFunc func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// Execute 调用函数本身
+func (f ToolExecutorFunc) Execute(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return f(ctx, request)
+}
+
+// FunctionSchema 工具的函数调用定义，可直接转换为 OpenAI function calling 的工具定义
+type FunctionSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
 // 工具注册表
 var toolRegister = make(map[string]McpTool)
 
+// 执行器注册表
+var (
+	executorRegister = make(map[string]ToolExecutor)
+	executorMu       sync.RWMutex
+)
+
 // RegisterTool 供工具在init时调用，将自己注册到工具注册表中
 func RegisterTool(tool McpTool) {
 	mcpTool := tool.New()
 	toolRegister[mcpTool.Name] = tool
 }
 
+// RegisterDeclaredTool 注册一个只声明 schema 的工具，其执行逻辑由 BindExecutor 绑定的执行器提供
+func RegisterDeclaredTool(tool mcp.Tool) {
+	RegisterTool(&declaredTool{tool: tool})
+}
+
+// RegisterInternalTool 注册一个仅供进程内调用（如AIFETCH）的声明式工具，不会暴露给外部MCP客户端
+func RegisterInternalTool(tool mcp.Tool) {
+	RegisterTool(&declaredTool{tool: tool, internal: true})
+}
+
+// BindExecutor 为已声明的工具绑定执行器，供上层服务在init时调用
+func BindExecutor(toolName string, executor ToolExecutor) {
+	executorMu.Lock()
+	defer executorMu.Unlock()
+	executorRegister[toolName] = executor
+}
+
 // RegisterAllTools 将所有注册的工具注册到MCP服务中
 func RegisterAllTools(mcpServer *server.MCPServer) {
 	for _, tool := range toolRegister {
+		if declared, ok := tool.(*declaredTool); ok && declared.internal {
+			continue
+		}
 		mcpServer.AddTool(tool.New(), tool.Handle)
 	}
 }
@@ -40,3 +91,91 @@ func InvokeTool(ctx context.Context, toolName string, request mcp.CallToolReques
 	}
 	return tool.Handle(ctx, request)
 }
+
+// IsExecutable 判断工具是否已注册且可以执行（声明式工具需要已绑定执行器）
+func IsExecutable(toolName string) bool {
+	tool, ok := toolRegister[toolName]
+	if !ok {
+		return false
+	}
+	if declared, ok := tool.(*declaredTool); ok {
+		return declared.executor() != nil
+	}
+	return true
+}
+
+// ExecutableToolNames 返回所有可执行工具的名称（已排序）
+func ExecutableToolNames() []string {
+	names := make([]string, 0, len(toolRegister))
+	for name := range toolRegister {
+		if IsExecutable(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FunctionSchemas 根据工具名称获取函数调用定义，工具的 schema 只在注册处声明一次
+func FunctionSchemas(toolNames ...string) ([]FunctionSchema, error) {
+	schemas := make([]FunctionSchema, 0, len(toolNames))
+	for _, name := range toolNames {
+		tool, ok := toolRegister[name]
+		if !ok {
+			return nil, fmt.Errorf("MCP工具 '%s' 未注册", name)
+		}
+		schema, err := toFunctionSchema(tool.New())
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// toFunctionSchema 将 mcp.Tool 的输入 schema 转换为通用的 JSON Schema map
+func toFunctionSchema(tool mcp.Tool) (FunctionSchema, error) {
+	raw := []byte(tool.RawInputSchema)
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(tool.InputSchema); err != nil {
+			return FunctionSchema{}, fmt.Errorf("序列化工具 '%s' 的参数定义失败: %w", tool.Name, err)
+		}
+	}
+	var parameters map[string]interface{}
+	if err := json.Unmarshal(raw, &parameters); err != nil {
+		return FunctionSchema{}, fmt.Errorf("解析工具 '%s' 的参数定义失败: %w", tool.Name, err)
+	}
+	return FunctionSchema{
+		Name:        tool.Name,
+		Description: tool.Description,
+		Parameters:  parameters,
+	}, nil
+}
+
+// declaredTool 只声明 schema、执行时转发给绑定执行器的工具
+type declaredTool struct {
+	tool     mcp.Tool
+	internal bool // 仅供进程内调用
+}
+
+// New 返回工具注册信息
+func (t *declaredTool) New() mcp.Tool {
+	return t.tool
+}
+
+// Handle 将调用转发给绑定的执行器
+func (t *declaredTool) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	executor := t.executor()
+	if executor == nil {
+		return nil, fmt.Errorf("MCP工具 '%s' 未绑定执行器", t.tool.Name)
+	}
+	return executor.Execute(ctx, request)
+}
+
+// executor 获取工具绑定的执行器
+func (t *declaredTool) executor() ToolExecutor {
+	executorMu.RLock()
+	defer executorMu.RUnlock()
+	return executorRegister[t.tool.Name]
+}
@@ -0,0 +1,119 @@
+package mcpTool
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Sugar 语义查询工具，面向外部MCP客户端；执行器由 sugar 服务绑定，按会话身份执行行级权限
+func init() {
+	RegisterDeclaredTool(newSugarListModelsTool())
+	RegisterDeclaredTool(newSugarDescribeModelTool())
+	RegisterDeclaredTool(newSugarGetTool())
+	RegisterDeclaredTool(newSugarCalcTool())
+	RegisterDeclaredTool(newSugarContributionAnalysisTool())
+}
+
+func newSugarListModelsTool() mcp.Tool {
+	return mcp.NewTool("sugar_list_models",
+		mcp.WithDescription("列出当前用户所在团队中可访问的语义模型。"),
+		mcp.WithString("keyword",
+			mcp.Description("可选的名称或描述关键字，用于过滤模型。"),
+		),
+	)
+}
+
+func newSugarDescribeModelTool() mcp.Tool {
+	return mcp.NewTool("sugar_describe_model",
+		mcp.WithDescription("查看语义模型的维度、指标和可用筛选参数，调用 sugar_get、sugar_calc 前应先了解模型结构。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("语义模型名称。"),
+		),
+	)
+}
+
+func newSugarGetTool() mcp.Tool {
+	return mcp.NewTool("sugar_get",
+		mcp.WithDescription("执行 SUGAR.GET 查询，按筛选条件返回明细或分组汇总数据，结果受当前用户的行级权限约束。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("语义模型名称。"),
+		),
+		mcp.WithArray("returnColumns",
+			mcp.Required(),
+			mcp.Items(stringItems),
+			mcp.Description("需要返回的列名数组，可以是维度或指标。"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description("筛选条件，格式为 {\"参数名\": \"筛选值\"}；日期字段可使用区间 {\"start\": \"YYYY-MM-DD\", \"end\": \"YYYY-MM-DD\"}。"),
+		),
+		mcp.WithArray("groupBy",
+			mcp.Items(stringItems),
+			mcp.Description("分组列名数组，提供后指标列按 SUM 汇总。"),
+		),
+		mcp.WithObject("timeIntelligence",
+			mcp.Properties(timeIntelligenceProperties),
+			mcp.Description(timeIntelligenceDescription+"有对比期时，每个指标列追加 _对比期、_变化、_变化率 三列。"),
+		),
+	)
+}
+
+func newSugarCalcTool() mcp.Tool {
+	return mcp.NewTool("sugar_calc",
+		mcp.WithDescription("执行 SUGAR.CALC 聚合计算，返回单个数值，结果受当前用户的行级权限约束。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("语义模型名称。"),
+		),
+		mcp.WithString("calcColumn",
+			mcp.Required(),
+			mcp.Description("参与计算的列名。"),
+		),
+		mcp.WithString("calcMethod",
+			mcp.Required(),
+			mcp.Description("计算方式。"),
+			mcp.Enum("SUM", "AVG", "COUNT", "MAX", "MIN"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description("筛选条件，格式为 {\"参数名\": \"筛选值\"}。"),
+		),
+		mcp.WithObject("timeIntelligence",
+			mcp.Properties(timeIntelligenceProperties),
+			mcp.Description(timeIntelligenceDescription+"有对比期时同时返回 baseResult、change 和 changeRate。"),
+		),
+	)
+}
+
+func newSugarContributionAnalysisTool() mcp.Tool {
+	return mcp.NewTool("sugar_contribution_analysis",
+		mcp.WithDescription("对指标在本期与基期之间的变化做维度贡献度分析，返回按贡献绝对值排序的维度组合。"),
+		mcp.WithString("modelName",
+			mcp.Required(),
+			mcp.Description("语义模型名称。"),
+		),
+		mcp.WithString("targetMetric",
+			mcp.Required(),
+			mcp.Description("需要分析的指标列名。"),
+		),
+		mcp.WithObject("currentPeriodFilters",
+			mcp.Required(),
+			mcp.Description("本期数据的筛选条件，格式为 {\"参数名\": \"筛选值\"}。"),
+		),
+		mcp.WithObject("basePeriodFilters",
+			mcp.Required(),
+			mcp.Description("基期数据的筛选条件，格式为 {\"参数名\": \"筛选值\"}；使用 timeIntelligence 时可传 {}，沿用本期的非时间条件。"),
+		),
+		mcp.WithObject("timeIntelligence",
+			mcp.Properties(timeIntelligenceProperties),
+			mcp.Description(timeIntelligenceDescription),
+		),
+		mcp.WithArray("groupByDimensions",
+			mcp.Required(),
+			mcp.Items(stringItems),
+			mcp.Description("进行归因分析的维度列名数组。"),
+		),
+		mcp.WithNumber("topN",
+			mcp.Description("返回贡献最大的前N个维度组合，默认20。"),
+		),
+	)
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// WebSocketToken 浏览器建立 WebSocket 连接时无法设置请求头，允许通过查询参数 x-token 传递令牌
+// 需要放在 JWTAuth 之前，令牌的校验仍由 JWTAuth 完成
+func WebSocketToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Header.Get("x-token") == "" {
+			if token := c.Query("x-token"); token != "" {
+				c.Request.Header.Set("x-token", token)
+			}
+		}
+		c.Next()
+	}
+}
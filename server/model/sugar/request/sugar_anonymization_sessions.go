@@ -0,0 +1,6 @@
+package request
+
+// SugarAnonymizationRedecodeRequest 重新解码执行日志请求
+type SugarAnonymizationRedecodeRequest struct {
+	LogId int64 `json:"logId" binding:"required"` // 执行日志ID
+}
@@ -0,0 +1,7 @@
+package request
+
+// SugarApiTokensCreateRequest 创建API令牌请求
+type SugarApiTokensCreateRequest struct {
+	Name          string `json:"name" binding:"required"` // 令牌名称
+	ExpiresInDays int    `json:"expiresInDays"`           // 有效天数，0 表示永不过期
+}
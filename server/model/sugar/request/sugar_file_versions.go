@@ -0,0 +1,31 @@
+package request
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+)
+
+// SugarFileVersionsSearch 历史版本列表查询条件
+type SugarFileVersionsSearch struct {
+	request.PageInfo
+	FileId    string `json:"fileId" form:"fileId" binding:"required"` // 工作簿文件ID
+	OnlyNamed bool   `json:"onlyNamed" form:"onlyNamed"`              // 只看命名或置顶的版本
+}
+
+// SugarFileVersionDiffRequest 版本对比请求
+type SugarFileVersionDiffRequest struct {
+	FileId      string `json:"fileId" form:"fileId" binding:"required"`           // 工作簿文件ID
+	FromVersion int    `json:"fromVersion" form:"fromVersion" binding:"required"` // 旧版本号
+	ToVersion   int    `json:"toVersion" form:"toVersion"`                        // 新版本号，为0时与当前内容对比
+}
+
+// SugarFileVersionRestoreRequest 恢复历史版本请求
+type SugarFileVersionRestoreRequest struct {
+	Id int64 `json:"id" binding:"required"` // 历史版本ID
+}
+
+// SugarFileVersionUpdateRequest 命名或置顶历史版本请求
+type SugarFileVersionUpdateRequest struct {
+	Id       int64   `json:"id" binding:"required"` // 历史版本ID
+	Name     *string `json:"name"`                  // 版本名称，传空字符串表示取消命名
+	IsPinned *bool   `json:"isPinned"`              // 是否置顶
+}
@@ -28,6 +28,14 @@ type SugarFoldersMoveRequest struct {
 	TeamId   string  `json:"teamId" binding:"required"` // 目标团队ID
 }
 
+// SugarFoldersCopyRequest 复制文件夹或文件请求
+type SugarFoldersCopyRequest struct {
+	Id       string  `json:"id" binding:"required"` // 要复制的项目ID
+	TeamId   string  `json:"teamId"`                // 目标团队ID，为空则在原位置创建副本
+	ParentId *string `json:"parentId"`              // 目标父文件夹ID，为空则复制到根目录；TeamId 为空时忽略
+	Name     string  `json:"name"`                  // 副本名称，为空则沿用原名称；与同级项目重名时自动追加序号
+}
+
 // SugarFoldersDeleteRequest 删除文件夹或文件请求
 type SugarFoldersDeleteRequest struct {
 	Id string `json:"id" form:"id" binding:"required"` // 要删除的项目ID
@@ -1,10 +1,19 @@
 package request
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // SugarFormulaAiFetchRequest AIFETCH 公式请求结构
 type SugarFormulaAiFetchRequest struct {
-	AgentName   string `json:"agentName" binding:"required"` // Agent 名称
-	Description string `json:"description"`                  // 用户输入的自然语言分析需求
-	DataRange   string `json:"dataRange,omitempty"`          // 可选的数据范围，如果提供则优先使用
+	AgentName      string          `json:"agentName" binding:"required"` // Agent 名称
+	Description    string          `json:"description"`                  // 用户输入的自然语言分析需求
+	DataRange      string          `json:"dataRange,omitempty"`          // 可选的数据范围，如果提供则优先使用
+	OutputSchema   *AiOutputSchema `json:"outputSchema,omitempty"`       // 可选的结构化输出定义，提供后返回二维表格结果
+	ConversationId string          `json:"conversationId,omitempty"`     // 可选的对话ID，同一对话的多轮分析复用匿名化代号
 }
 
 // SugarFormulaAiExplainRequest AIEXPLAIN 公式请求结构
@@ -12,3 +21,142 @@ type SugarFormulaAiExplainRangeRequest struct {
 	DataSource  [][]interface{} `json:"dataSource" binding:"required"`  // 前端传入的二维数据
 	Description string          `json:"description" binding:"required"` // 用户的分析需求
 }
+
+// AiOutputColumn 结构化输出的列定义
+type AiOutputColumn struct {
+	Name        string `json:"name"`                  // 列名，同时作为表头
+	Type        string `json:"type"`                  // 列类型: string, number, integer, boolean, date
+	Description string `json:"description,omitempty"` // 列说明，提供给模型理解
+}
+
+// AiOutputSchema AIFETCH 结构化输出定义
+// 可以通过 Columns 简单声明列名和类型，也可以直接提供 JSONSchema（需为对象数组结构）
+type AiOutputSchema struct {
+	Name       string                 `json:"name,omitempty"`       // schema 名称，默认为 aifetch_table
+	Columns    []AiOutputColumn       `json:"columns,omitempty"`    // 列定义
+	JSONSchema map[string]interface{} `json:"jsonSchema,omitempty"` // 原始 JSON Schema
+	MaxRows    int                    `json:"maxRows,omitempty"`    // 最大返回行数，0 表示不限制
+}
+
+// 支持的结构化输出列类型
+var aiOutputColumnTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"date":    true,
+}
+
+// Validate 校验结构化输出定义，并在只提供 JSONSchema 时从中推导列定义
+func (s *AiOutputSchema) Validate() error {
+	if s == nil {
+		return errors.New("结构化输出定义为空")
+	}
+	if len(s.Columns) == 0 && len(s.JSONSchema) > 0 {
+		columns, err := columnsFromJSONSchema(s.JSONSchema)
+		if err != nil {
+			return err
+		}
+		s.Columns = columns
+	}
+	if len(s.Columns) == 0 {
+		return errors.New("结构化输出至少需要定义一列")
+	}
+
+	seen := make(map[string]bool)
+	for i := range s.Columns {
+		column := &s.Columns[i]
+		column.Name = strings.TrimSpace(column.Name)
+		if column.Name == "" {
+			return fmt.Errorf("第%d列缺少列名", i+1)
+		}
+		if seen[column.Name] {
+			return fmt.Errorf("列名重复: %s", column.Name)
+		}
+		seen[column.Name] = true
+
+		column.Type = strings.ToLower(strings.TrimSpace(column.Type))
+		if column.Type == "" {
+			column.Type = "string"
+		}
+		if !aiOutputColumnTypes[column.Type] {
+			return fmt.Errorf("列 %s 的类型不受支持: %s", column.Name, column.Type)
+		}
+	}
+	if s.MaxRows < 0 {
+		return errors.New("maxRows 不能为负数")
+	}
+	if s.Name == "" {
+		s.Name = "aifetch_table"
+	}
+	return nil
+}
+
+// columnsFromJSONSchema 从 {"type":"array","items":{"type":"object","properties":{...}}} 或
+// {"type":"object","properties":{"rows":{...}}} 形式的 JSON Schema 中推导列定义
+func columnsFromJSONSchema(schema map[string]interface{}) ([]AiOutputColumn, error) {
+	items := schema
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		if rows, ok := props["rows"].(map[string]interface{}); ok {
+			items = rows
+		}
+	}
+	if itemSchema, ok := items["items"].(map[string]interface{}); ok {
+		items = itemSchema
+	}
+
+	properties, ok := items["properties"].(map[string]interface{})
+	if !ok || len(properties) == 0 {
+		return nil, errors.New("JSON Schema 中缺少行对象的 properties 定义")
+	}
+
+	// properties 是无序的，优先按 required 的顺序排列，若声明了 x-order 则以其为准，剩余列按名称排序
+	var order []string
+	if required, ok := items["required"].([]interface{}); ok {
+		for _, name := range required {
+			if str, ok := name.(string); ok {
+				order = append(order, str)
+			}
+		}
+	}
+	if xOrder, ok := items["x-order"].([]interface{}); ok {
+		order = order[:0]
+		for _, name := range xOrder {
+			if str, ok := name.(string); ok {
+				order = append(order, str)
+			}
+		}
+	}
+	added := make(map[string]bool)
+	var columns []AiOutputColumn
+	appendColumn := func(name string) {
+		if added[name] {
+			return
+		}
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok {
+			return
+		}
+		added[name] = true
+		columnType, _ := prop["type"].(string)
+		if format, _ := prop["format"].(string); format == "date" || format == "date-time" {
+			columnType = "date"
+		}
+		description, _ := prop["description"].(string)
+		columns = append(columns, AiOutputColumn{Name: name, Type: columnType, Description: description})
+	}
+	for _, name := range order {
+		appendColumn(name)
+	}
+	var rest []string
+	for name := range properties {
+		if !added[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		appendColumn(name)
+	}
+	return columns, nil
+}
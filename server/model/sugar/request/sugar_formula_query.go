@@ -6,6 +6,8 @@ type SugarFormulaCalcRequest struct {
 	CalcColumn string                 `json:"calcColumn" binding:"required"` // 计算列名
 	CalcMethod string                 `json:"calcMethod" binding:"required"` // 计算方式: SUM, AVG, COUNT, MAX, MIN
 	Filters    map[string]interface{} `json:"filters"`                       // 筛选条件键值对
+
+	TimeIntelligence *TimeIntelligence `json:"timeIntelligence,omitempty"` // 可选的时间智能计算，提供后按模型声明的日期字段确定本期与对比期
 }
 
 // SugarFormulaGetRequest SUGAR.GET 公式请求结构
@@ -14,6 +16,94 @@ type SugarFormulaGetRequest struct {
 	ReturnColumns []string               `json:"returnColumns" binding:"required"` // 返回列名列表
 	Filters       map[string]interface{} `json:"filters"`                          // 筛选条件键值对
 	GroupBy       []string               `json:"groupBy"`                          // 分组字段列表，用于聚合查询
+
+	TimeIntelligence *TimeIntelligence `json:"timeIntelligence,omitempty"` // 可选的时间智能计算，提供后按模型声明的日期字段确定本期与对比期
+}
+
+// SugarFormulaContributionRequest SUGAR.CONTRIBUTION 公式请求结构
+// 不经过大模型，直接执行贡献度计算与智能下钻
+type SugarFormulaContributionRequest struct {
+	ModelName            string                 `json:"modelName" binding:"required"`    // 语义模型名称
+	TargetMetric         string                 `json:"targetMetric" binding:"required"` // 分析的目标指标
+	Dimensions           []string               `json:"dimensions" binding:"required"`   // 候选分析维度
+	CurrentPeriodFilters map[string]interface{} `json:"currentPeriodFilters"`            // 本期筛选条件
+	BasePeriodFilters    map[string]interface{} `json:"basePeriodFilters"`               // 基期筛选条件
+
+	TimeIntelligence *TimeIntelligence           `json:"timeIntelligence,omitempty"` // 可选的时间智能计算，提供后由服务端计算本期与基期的日期范围
+	Config           *ContributionAnalysisConfig `json:"config,omitempty"`           // 可选的分析配置，未填写的项使用默认值
+}
+
+// ContributionAnalysisConfig 贡献度下钻分析配置，字段为空时使用默认配置
+type ContributionAnalysisConfig struct {
+	DiscriminationThreshold            *float64 `json:"discriminationThreshold,omitempty"`            // 区分度阈值，低于此值停止下钻
+	MinContributionThreshold           *float64 `json:"minContributionThreshold,omitempty"`           // 最小贡献度阈值（百分比），低于此值的组合被过滤
+	MaxDrillDownLevels                 *int     `json:"maxDrillDownLevels,omitempty"`                 // 最大下钻层级
+	TopCombinationsCount               *int     `json:"topCombinationsCount,omitempty"`               // 返回的顶级组合数量上限
+	MinTopCombinations                 *int     `json:"minTopCombinations,omitempty"`                 // 至少返回的顶级组合数量
+	EnableSmartStop                    *bool    `json:"enableSmartStop,omitempty"`                    // 是否按区分度改善幅度智能停止
+	DiscriminationImprovementThreshold *float64 `json:"discriminationImprovementThreshold,omitempty"` // 区分度改善阈值
+
+	DecompositionMethod string   `json:"decompositionMethod,omitempty"` // 变化分解方法: additive（默认）, lmdi, shapley, mix_rate
+	FactorMetrics       []string `json:"factorMetrics,omitempty"`       // lmdi/shapley 的因素链指标，如 ["销量"] 表示 目标指标 = 销量 × (目标指标/销量)；shapley 在因素之间而非维度之间分摊
+	RatioNumerator      string   `json:"ratioNumerator,omitempty"`      // mix_rate 的分子指标，目标指标 = Σ分子 / Σ分母
+	RatioDenominator    string   `json:"ratioDenominator,omitempty"`    // mix_rate 的分母指标
+}
+
+// SugarFormulaAnomalyRequest SUGAR.ANOMALY 公式请求结构
+// 在语义模型声明的日期字段上按期汇总指标，检测异常值和均值变点
+type SugarFormulaAnomalyRequest struct {
+	ModelName  string                 `json:"modelName" binding:"required"` // 语义模型名称
+	Metric     string                 `json:"metric" binding:"required"`    // 检测的指标
+	Dimensions []string               `json:"dimensions"`                   // 可选的拆分维度，按维度组合分别检测
+	Filters    map[string]interface{} `json:"filters"`                      // 筛选条件
+	StartDate  string                 `json:"startDate,omitempty"`          // 检测区间起始日期，格式 YYYY-MM-DD，需与 endDate 同时提供
+	EndDate    string                 `json:"endDate,omitempty"`            // 检测区间结束日期，格式 YYYY-MM-DD
+	Grain      string                 `json:"grain,omitempty"`              // 期间粒度: day, week, month, quarter, year，默认为模型声明的粒度
+
+	Config  *AnomalyDetectionConfig `json:"config,omitempty"`  // 可选的检测配置，未填写的项使用默认值
+	Explain *AnomalyExplainConfig   `json:"explain,omitempty"` // 可选，对异常期执行贡献度分析
+}
+
+// AnomalyDetectionConfig 异常与变点检测配置，字段为空时使用默认配置
+type AnomalyDetectionConfig struct {
+	Threshold          *float64 `json:"threshold,omitempty"`          // 残差稳健 z 分数的异常阈值，默认 3.5
+	SeasonLength       *int     `json:"seasonLength,omitempty"`       // 季节周期（期数），默认按粒度：日7、周52、月12、季4，0 表示不做季节分解
+	TrendWindow        *int     `json:"trendWindow,omitempty"`        // 趋势滚动中位数窗口（期数），默认 5
+	ChangePointPenalty *float64 `json:"changePointPenalty,omitempty"` // 变点惩罚系数，越大检出的变点越少，默认 3
+	MinSegmentLength   *int     `json:"minSegmentLength,omitempty"`   // 变点两侧至少包含的期数，默认 3
+	MaxChangePoints    *int     `json:"maxChangePoints,omitempty"`    // 每条序列最多检出的变点数量，0 表示不检测变点，默认 5
+}
+
+// AnomalyExplainConfig 异常解释配置：以异常期为本期、上一期（季节分解时为上一周期同期）为基期执行贡献度分析
+type AnomalyExplainConfig struct {
+	Dimensions   []string                    `json:"dimensions" binding:"required"` // 贡献度分析的候选维度
+	MaxAnomalies int                         `json:"maxAnomalies,omitempty"`        // 解释的异常数量上限，按异常分数绝对值从大到小选取，默认 3
+	Config       *ContributionAnalysisConfig `json:"config,omitempty"`              // 可选的贡献度分析配置
+}
+
+// 时间智能函数
+const (
+	TimeFunctionYoY     = "YOY"     // 同比：锚定日期所在期间 对比 上年同期
+	TimeFunctionMoM     = "MOM"     // 环比（月）：锚定日期所在月 对比 上月
+	TimeFunctionQoQ     = "QOQ"     // 环比（季）：锚定日期所在财季 对比 上一财季
+	TimeFunctionYTD     = "YTD"     // 财年初至锚定日期 对比 上年同期
+	TimeFunctionPTD     = "PTD"     // 期间初至锚定日期 对比 上一期间的相同天数
+	TimeFunctionRolling = "ROLLING" // 截至锚定日期所在期间的最近N期 对比 再往前N期
+	TimeFunctionSPLY    = "SPLY"    // 上年同期：只返回锚定日期所在期间的上年同期，无对比期
+)
+
+// TimeIntelligence 时间智能计算参数，期间边界由服务端根据语义模型的日期字段和财年口径计算
+type TimeIntelligence struct {
+	Function   string `json:"function"`             // 时间智能函数: YOY, MOM, QOQ, YTD, PTD, ROLLING, SPLY
+	AnchorDate string `json:"anchorDate,omitempty"` // 锚定日期，格式 YYYY-MM-DD；YTD/PTD 默认为当天，其他函数默认为上一个完整期间的最后一天
+	Grain      string `json:"grain,omitempty"`      // 期间粒度: day, week, month, quarter, year；YOY/PTD/ROLLING/SPLY 使用，默认为模型声明的粒度
+	Periods    int    `json:"periods,omitempty"`    // ROLLING 的期数，默认 12
+}
+
+// DateRange 日期区间（含首尾，格式 YYYY-MM-DD），可作为日期字段的筛选值
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
 }
 
 // ValidateCalcMethod 验证计算方式是否有效
@@ -0,0 +1,13 @@
+package request
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+)
+
+// SugarPrivacyBudgetSearch 隐私预算账本查询条件
+type SugarPrivacyBudgetSearch struct {
+	request.PageInfo
+	UserId          string `json:"userId" form:"userId"`                   // 用户ID
+	SemanticModelId string `json:"semanticModelId" form:"semanticModelId"` // 语义模型ID
+	OnlyActive      bool   `json:"onlyActive" form:"onlyActive"`           // 只看当前时间窗口
+}
@@ -0,0 +1,26 @@
+package request
+
+import "github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+
+// 恢复时与同名项目冲突的处理方式
+const (
+	RecycleBinConflictFail   = ""       // 返回冲突详情，由用户决定
+	RecycleBinConflictRename = "rename" // 自动在名称后追加序号
+)
+
+// SugarRecycleBinSearch 查询团队回收站的条件
+type SugarRecycleBinSearch struct {
+	TeamId string `json:"teamId" form:"teamId" binding:"required"` // 团队ID
+	request.PageInfo
+}
+
+// SugarRecycleBinRestoreRequest 从回收站恢复请求
+type SugarRecycleBinRestoreRequest struct {
+	Id               string `json:"id" binding:"required"` // 要恢复的项目ID
+	ConflictStrategy string `json:"conflictStrategy"`      // 原位置已有同名项目时的处理方式：为空返回冲突，rename 自动重命名
+}
+
+// SugarRecycleBinPurgeRequest 彻底删除请求
+type SugarRecycleBinPurgeRequest struct {
+	Id string `json:"id" form:"id" binding:"required"` // 要彻底删除的项目ID
+}
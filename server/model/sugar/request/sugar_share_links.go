@@ -0,0 +1,31 @@
+package request
+
+import "time"
+
+// SugarShareLinkCreateRequest 创建分享链接请求
+type SugarShareLinkCreateRequest struct {
+	WorkspaceId     string     `json:"workspaceId" binding:"required"`     // 工作簿文件ID
+	PermissionLevel string     `json:"permissionLevel" binding:"required"` // 权限级别：editor 或 viewer
+	Password        string     `json:"password"`                           // 可选，访问密码
+	ExpiresAt       *time.Time `json:"expiresAt"`                          // 可选，过期时间，为空表示永不过期
+	FreezeData      bool       `json:"freezeData"`                         // 是否冻结 SUGAR 和 AI.FETCH 公式结果，冻结后打开者看到的是创建时的数据，不会执行实时查询
+}
+
+// SugarShareLinkSearch 查询工作簿分享链接的条件
+type SugarShareLinkSearch struct {
+	WorkspaceId string `json:"workspaceId" form:"workspaceId" binding:"required"` // 工作簿文件ID
+}
+
+// SugarShareLinkOpenRequest 通过链接打开工作簿请求
+type SugarShareLinkOpenRequest struct {
+	Token    string `json:"token" binding:"required"` // 链接令牌
+	Password string `json:"password"`                 // 访问密码，链接设置了密码时必填
+}
+
+// SugarShareLinkSaveRequest 通过可编辑的链接保存工作簿请求
+type SugarShareLinkSaveRequest struct {
+	SessionToken string `json:"sessionToken" binding:"required"` // 打开链接时获得的会话令牌
+	Content      any    `json:"content" binding:"required"`      // 工作簿内容
+	Revision     int    `json:"revision"`                        // 打开或上次保存时获得的修订号
+	BaseContent  any    `json:"baseContent"`                     // 可选，该修订号对应的内容，修订号过期时用于三方合并
+}
@@ -0,0 +1,12 @@
+package request
+
+// SugarLineageImpactRequest 影响分析请求
+type SugarLineageImpactRequest struct {
+	Id      string   `json:"id" form:"id" binding:"required"` // 语义模型、数据库连接或智能体ID
+	Columns []string `json:"columns" form:"columns[]"`        // 可选，只分析引用了语义模型这些字段的工作簿
+}
+
+// SugarWorkbookDependenciesRequest 查询工作簿依赖请求
+type SugarWorkbookDependenciesRequest struct {
+	Id string `json:"id" form:"id" binding:"required"` // 工作簿ID
+}
@@ -0,0 +1,17 @@
+package request
+
+import "github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+
+// SugarWorkbookSearchRequest 搜索工作簿的条件，关键词、模型和智能体至少填写一项，同时填写时需全部满足
+type SugarWorkbookSearchRequest struct {
+	Keyword   string `json:"keyword" form:"keyword"`     // 关键词，空格分隔的多个词需全部出现在文件名、工作表名、单元格文本或公式中
+	ModelName string `json:"modelName" form:"modelName"` // 只返回 SUGAR 公式引用了该语义模型的工作簿
+	AgentName string `json:"agentName" form:"agentName"` // 只返回 AI.FETCH 公式引用了该智能体的工作簿
+	TeamId    string `json:"teamId" form:"teamId"`       // 可选，只搜索该团队的工作簿
+	request.PageInfo
+}
+
+// SugarWorkbookSearchRebuildRequest 重建团队搜索索引请求
+type SugarWorkbookSearchRebuildRequest struct {
+	TeamId string `json:"teamId" binding:"required"` // 团队ID
+}
@@ -0,0 +1,30 @@
+package request
+
+import "github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+
+// SugarWorkbookTemplateCreateRequest 由工作簿生成模板请求
+type SugarWorkbookTemplateCreateRequest struct {
+	FileId      string                         `json:"fileId" binding:"required"` // 作为模板的工作簿ID，模板归属于该工作簿所在团队
+	Name        string                         `json:"name" binding:"required"`   // 模板名称
+	Description string                         `json:"description"`               // 模板说明
+	Parameters  []sugar.SugarTemplateParameter `json:"parameters"`                // 参数的显示名称和默认值，未声明的占位符按名称自动生成
+}
+
+// SugarWorkbookTemplateSearch 查询团队模板库的条件
+type SugarWorkbookTemplateSearch struct {
+	TeamId string `json:"teamId" form:"teamId" binding:"required"` // 团队ID
+}
+
+// SugarWorkbookTemplateDeleteRequest 删除模板请求
+type SugarWorkbookTemplateDeleteRequest struct {
+	Id int64 `json:"id" form:"id" binding:"required"` // 模板ID
+}
+
+// SugarWorkbookTemplateInstantiateRequest 由模板新建工作簿请求
+type SugarWorkbookTemplateInstantiateRequest struct {
+	TemplateId int64             `json:"templateId" binding:"required"` // 模板ID
+	Name       string            `json:"name" binding:"required"`       // 新工作簿名称
+	TeamId     string            `json:"teamId" binding:"required"`     // 目标团队ID
+	ParentId   *string           `json:"parentId"`                      // 目标文件夹ID，为空则创建在根目录
+	Parameters map[string]string `json:"parameters"`                    // 参数取值，如 {"period": "2024-06", "region": "华东"}
+}
@@ -0,0 +1,14 @@
+package request
+
+// SugarWorkspacePermissionGrantRequest 授予文件或文件夹权限请求，同一对象重复授予时更新权限级别
+type SugarWorkspacePermissionGrantRequest struct {
+	WorkspaceId     string `json:"workspaceId" binding:"required"`     // 文件或文件夹ID
+	GranteeType     string `json:"granteeType" binding:"required"`     // 授权对象类型：user 或 team
+	GranteeId       string `json:"granteeId" binding:"required"`       // 用户ID或团队ID
+	PermissionLevel string `json:"permissionLevel" binding:"required"` // 权限级别：editor 或 viewer
+}
+
+// SugarWorkspacePermissionSearch 查询文件或文件夹权限的条件
+type SugarWorkspacePermissionSearch struct {
+	WorkspaceId string `json:"workspaceId" form:"workspaceId" binding:"required"` // 文件或文件夹ID
+}
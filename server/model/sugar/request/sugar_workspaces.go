@@ -8,3 +8,11 @@ type SugarWorkspacesSearch struct {
 	request.PageInfo
 	ParentId *string `json:"parentId" form:"parentId"`
 }
+
+// SugarWorkbookSaveRequest 保存工作簿内容请求
+type SugarWorkbookSaveRequest struct {
+	Id          string `json:"id" binding:"required"`      // 文件ID
+	Content     any    `json:"content" binding:"required"` // 工作簿内容
+	Revision    *int   `json:"revision"`                   // 打开或上次保存时获得的修订号，也可通过 If-Match 请求头传递
+	BaseContent any    `json:"baseContent"`                // 可选，该修订号对应的内容，修订号过期时用于三方合并
+}
@@ -0,0 +1,18 @@
+package response
+
+import "time"
+
+// SugarAnonymizationRedecodeResponse 重新解码执行日志匿名化输出的结果
+type SugarAnonymizationRedecodeResponse struct {
+	LogId              int64      `json:"logId"`              // 执行日志ID
+	SessionId          string     `json:"sessionId"`          // 匿名化会话ID
+	ConversationId     string     `json:"conversationId"`     // 所属对话ID
+	SessionCreatedBy   string     `json:"sessionCreatedBy"`   // 创建会话的用户ID
+	AnonymizedOutput   string     `json:"anonymizedOutput"`   // 日志中记录的模型原始输出
+	DecodedOutput      string     `json:"decodedOutput"`      // 使用持久化映射重新解码的结果
+	FinalResult        string     `json:"finalResult"`        // 日志中记录的最终返回结果
+	MatchesFinalResult bool       `json:"matchesFinalResult"` // 重新解码结果是否包含于最终返回结果中
+	MappingCount       int        `json:"mappingCount"`       // 会话中的代号映射数量
+	EpsilonSpent       float64    `json:"epsilonSpent"`       // 会话累计消耗的差分隐私预算
+	ExpiresAt          *time.Time `json:"expiresAt"`          // 映射保留截止时间
+}
@@ -0,0 +1,9 @@
+package response
+
+import "github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+
+// SugarApiTokensCreateResponse 创建API令牌响应，明文令牌只在创建时返回一次
+type SugarApiTokensCreateResponse struct {
+	Token    string               `json:"token"`    // 明文令牌
+	ApiToken sugar.SugarApiTokens `json:"apiToken"` // 令牌记录
+}
@@ -0,0 +1,7 @@
+package response
+
+// SugarTeamPermissionsResponse 用户在团队中的角色及各类资源允许的操作
+type SugarTeamPermissionsResponse struct {
+	Role        string              `json:"role"`        // 团队角色 owner/admin/editor/viewer
+	Permissions map[string][]string `json:"permissions"` // 资源类型 -> 允许的操作 read/edit/share/delete/manage
+}
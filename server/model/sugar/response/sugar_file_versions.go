@@ -0,0 +1,73 @@
+package response
+
+import (
+	"time"
+)
+
+// 单元格变化类型
+const (
+	WorkbookDiffAdded    = "added"    // 新增
+	WorkbookDiffRemoved  = "removed"  // 删除
+	WorkbookDiffModified = "modified" // 修改
+)
+
+// SugarFileVersionItem 历史版本列表项，不含内容快照
+type SugarFileVersionItem struct {
+	Id            int64      `json:"id"`            // 历史版本ID
+	FileId        string     `json:"fileId"`        // 工作簿文件ID
+	VersionNumber int        `json:"versionNumber"` // 版本号
+	Name          string     `json:"name"`          // 版本名称
+	IsPinned      bool       `json:"isPinned"`      // 是否置顶
+	Source        string     `json:"source"`        // 版本来源：save / restore / baseline
+	RestoredFrom  *int       `json:"restoredFrom"`  // 恢复自哪个版本号
+	SaveCount     int        `json:"saveCount"`     // 合并的保存次数
+	CreatedBy     string     `json:"createdBy"`     // 作者用户ID
+	AuthorName    string     `json:"authorName"`    // 作者昵称
+	CreatedAt     *time.Time `json:"createdAt"`     // 创建时间
+	UpdatedAt     *time.Time `json:"updatedAt"`     // 最后一次合并保存的时间
+}
+
+// SugarFileVersionDiffResponse 两个版本之间的工作表/单元格级差异
+type SugarFileVersionDiffResponse struct {
+	FileId          string              `json:"fileId"`          // 工作簿文件ID
+	FromVersion     int                 `json:"fromVersion"`     // 旧版本号
+	ToVersion       int                 `json:"toVersion"`       // 新版本号，为0表示当前内容
+	WorkbookChanges []string            `json:"workbookChanges"` // 发生变化的工作簿级属性，如 styles、sheetOrder
+	Sheets          []WorkbookSheetDiff `json:"sheets"`          // 发生变化的工作表
+	Summary         WorkbookDiffSummary `json:"summary"`         // 变化统计
+	Truncated       bool                `json:"truncated"`       // 单元格变化过多时只返回前若干条明细，统计仍为全量
+}
+
+// WorkbookDiffSummary 变化统计
+type WorkbookDiffSummary struct {
+	SheetsAdded    int `json:"sheetsAdded"`    // 新增工作表数
+	SheetsRemoved  int `json:"sheetsRemoved"`  // 删除工作表数
+	SheetsModified int `json:"sheetsModified"` // 修改工作表数
+	CellsAdded     int `json:"cellsAdded"`     // 新增单元格数
+	CellsRemoved   int `json:"cellsRemoved"`   // 删除单元格数
+	CellsModified  int `json:"cellsModified"`  // 修改单元格数
+}
+
+// WorkbookSheetDiff 单个工作表的差异
+type WorkbookSheetDiff struct {
+	SheetId         string             `json:"sheetId"`         // 工作表ID
+	Name            string             `json:"name"`            // 工作表名称（删除的工作表为旧名称）
+	OldName         string             `json:"oldName"`         // 重命名前的名称，未重命名时为空
+	Status          string             `json:"status"`          // added / removed / modified
+	PropertyChanges []string           `json:"propertyChanges"` // 发生变化的工作表属性，如 mergeData、rowData
+	CellsAdded      int                `json:"cellsAdded"`      // 新增单元格数
+	CellsRemoved    int                `json:"cellsRemoved"`    // 删除单元格数
+	CellsModified   int                `json:"cellsModified"`   // 修改单元格数
+	Cells           []WorkbookCellDiff `json:"cells"`           // 单元格变化明细，按行列排序
+}
+
+// WorkbookCellDiff 单元格差异
+type WorkbookCellDiff struct {
+	Cell          string                 `json:"cell"`          // 单元格地址，如 B3
+	Row           int                    `json:"row"`           // 行号，从0开始
+	Col           int                    `json:"col"`           // 列号，从0开始
+	Type          string                 `json:"type"`          // added / removed / modified
+	ChangedFields []string               `json:"changedFields"` // 发生变化的单元格字段，如 v、f、s
+	Before        map[string]interface{} `json:"before"`        // 旧单元格内容
+	After         map[string]interface{} `json:"after"`         // 新单元格内容
+}
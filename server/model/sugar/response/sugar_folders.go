@@ -11,6 +11,8 @@ type SugarFoldersWorkspaceTreeNode struct {
 	Type     string                           `json:"type"`     // 节点类型：folder 或 file
 	ParentId *string                          `json:"parentId"` // 父节点ID
 	TeamId   string                           `json:"teamId"`   // 团队ID
+	Actions  []string                         `json:"actions"`  // 当前用户允许的操作
+	Shared   bool                             `json:"shared"`   // 是否为分享给当前用户的团队外内容
 	Children []*SugarFoldersWorkspaceTreeNode `json:"children"` // 子节点
 }
 
@@ -34,6 +36,13 @@ type SugarFoldersMoveResponse struct {
 	SugarWorkspace sugar.SugarWorkspaces `json:"sugarWorkspace"` // 移动后的工作空间项目
 }
 
+// SugarFoldersCopyResponse 复制响应
+type SugarFoldersCopyResponse struct {
+	SugarWorkspace sugar.SugarWorkspaces `json:"sugarWorkspace"` // 复制出的项目
+	CopiedCount    int                   `json:"copiedCount"`    // 复制的项目总数（含子项目）
+	Renamed        bool                  `json:"renamed"`        // 是否因同名被自动重命名
+}
+
 // SugarFoldersDeleteResponse 删除响应
 type SugarFoldersDeleteResponse struct {
 	Message string `json:"message"` // 删除结果消息
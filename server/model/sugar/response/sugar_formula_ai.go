@@ -2,15 +2,20 @@ package response
 
 // SugarFormulaAiResponse AI公式通用响应结构
 type SugarFormulaAiResponse struct {
-	Text  string `json:"text,omitempty"`  // AI返回的文本分析结果（用于AIEXPLAIN）
-	Error string `json:"error,omitempty"` // 执行过程中的错误信息
+	Text     string          `json:"text,omitempty"`     // AI返回的文本分析结果（用于AIEXPLAIN）
+	Result   [][]interface{} `json:"result,omitempty"`   // 结构化输出的二维表格结果，第一行为表头
+	Warnings []string        `json:"warnings,omitempty"` // 结构化输出校验与修复过程中的提示信息
+	Error    string          `json:"error,omitempty"`    // 执行过程中的错误信息
+
+	PrivacyBudget *SugarPrivacyBudgetStatus `json:"privacyBudget,omitempty"` // 本次分析的差分隐私预算状态
 }
 
 // NewAiSuccessResponseWithData 创建成功的AI响应（带数据结果）
 func NewAiSuccessResponseWithData(result [][]interface{}) *SugarFormulaAiResponse {
 	return &SugarFormulaAiResponse{
-		Text:  "",
-		Error: "",
+		Text:   "",
+		Result: result,
+		Error:  "",
 	}
 }
 
@@ -25,8 +30,9 @@ func NewAiSuccessResponseWithText(text string) *SugarFormulaAiResponse {
 // NewAiSuccessResponseWithBoth 创建成功的AI响应（带数据和文本结果）
 func NewAiSuccessResponseWithBoth(result [][]interface{}, text string) *SugarFormulaAiResponse {
 	return &SugarFormulaAiResponse{
-		Text:  text,
-		Error: "",
+		Text:   text,
+		Result: result,
+		Error:  "",
 	}
 }
 
@@ -4,6 +4,11 @@ package response
 type SugarFormulaCalcResponse struct {
 	Result interface{} `json:"result"` // 计算结果，可能是数字或错误信息
 	Error  string      `json:"error"`  // 错误信息
+
+	BaseResult       interface{}           `json:"baseResult,omitempty"`       // 对比期计算结果，仅时间智能对比函数返回
+	Change           *float64              `json:"change,omitempty"`           // 本期 - 对比期
+	ChangeRate       *float64              `json:"changeRate,omitempty"`       // 变化率（百分比），对比期为0时省略
+	TimeIntelligence *TimeIntelligenceInfo `json:"timeIntelligence,omitempty"` // 时间智能计算使用的期间
 }
 
 // SugarFormulaGetResponse SUGAR.GET 公式响应结构
@@ -12,6 +17,169 @@ type SugarFormulaGetResponse struct {
 	Columns []string                 `json:"columns"` // 列信息
 	Count   int                      `json:"count"`   // 结果数量
 	Error   string                   `json:"error"`   // 错误信息
+
+	TimeIntelligence *TimeIntelligenceInfo `json:"timeIntelligence,omitempty"` // 时间智能计算使用的期间
+}
+
+// SugarFormulaContributionResponse SUGAR.CONTRIBUTION 公式响应结构
+type SugarFormulaContributionResponse struct {
+	Results []map[string]interface{} `json:"results"` // 顶级贡献组合，每行包含各分析维度、本期值、基期值、变化值、贡献度；mix_rate 时另含整体比率变化贡献
+	Columns []string                 `json:"columns"` // 列信息
+	Count   int                      `json:"count"`   // 结果数量
+	Error   string                   `json:"error"`   // 错误信息
+
+	CurrentTotal      float64                      `json:"currentTotal"`               // 本期合计
+	BaseTotal         float64                      `json:"baseTotal"`                  // 基期合计
+	TotalChange       float64                      `json:"totalChange"`                // 总变化值
+	DrillDownPath     []string                     `json:"drillDownPath"`              // 下钻路径
+	Levels            []ContributionLevel          `json:"levels"`                     // 各下钻层级
+	OptimalDimensions []string                     `json:"optimalDimensions"`          // 最优层级的维度
+	StopReason        string                       `json:"stopReason"`                 // 停止下钻的原因
+	Summary           string                       `json:"summary"`                    // 分析摘要
+	DataQuality       *ContributionDataQuality     `json:"dataQuality,omitempty"`      // 数据质量报告
+	Config            ContributionAnalysisSettings `json:"config"`                     // 实际生效的分析配置
+	Warnings          []string                     `json:"warnings,omitempty"`         // 警告信息
+	Decomposition     *ContributionDecomposition   `json:"decomposition,omitempty"`    // 非加法分解的结果，结果表同时包含各效应列
+	TimeIntelligence  *TimeIntelligenceInfo        `json:"timeIntelligence,omitempty"` // 时间智能计算使用的期间
+}
+
+// ContributionLevel 下钻层级
+type ContributionLevel struct {
+	Dimensions       []string `json:"dimensions"`       // 该层级的维度
+	Discrimination   float64  `json:"discrimination"`   // 区分度
+	CombinationCount int      `json:"combinationCount"` // 过滤后的组合数量
+	MaxContribution  float64  `json:"maxContribution"`  // 最大贡献度（绝对值）
+	Optimal          bool     `json:"optimal"`          // 是否为最优层级
+}
+
+// ContributionDataQuality 贡献度数据质量报告
+type ContributionDataQuality struct {
+	QualityScore      float64          `json:"qualityScore"`      // 质量得分（0-100）
+	TotalCombinations int              `json:"totalCombinations"` // 组合总数
+	ValidCombinations int              `json:"validCombinations"` // 有效组合数
+	Issues            []string         `json:"issues"`            // 发现的问题
+	Recommendations   []string         `json:"recommendations"`   // 改进建议
+	DimensionCoverage map[string]int   `json:"dimensionCoverage"` // 各维度覆盖的组合数
+	Stats             ContributionStat `json:"stats"`             // 贡献度分布统计
+}
+
+// ContributionStat 贡献度分布统计
+type ContributionStat struct {
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	StdDev    float64 `json:"stdDev"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	ZeroCount int     `json:"zeroCount"`
+}
+
+// ContributionAnalysisSettings 贡献度下钻分析配置
+type ContributionAnalysisSettings struct {
+	DiscriminationThreshold            float64  `json:"discriminationThreshold"`
+	MinContributionThreshold           float64  `json:"minContributionThreshold"`
+	MaxDrillDownLevels                 int      `json:"maxDrillDownLevels"`
+	TopCombinationsCount               int      `json:"topCombinationsCount"`
+	MinTopCombinations                 int      `json:"minTopCombinations"`
+	EnableSmartStop                    bool     `json:"enableSmartStop"`
+	DiscriminationImprovementThreshold float64  `json:"discriminationImprovementThreshold"`
+	DecompositionMethod                string   `json:"decompositionMethod"`
+	FactorMetrics                      []string `json:"factorMetrics,omitempty"`
+	RatioNumerator                     string   `json:"ratioNumerator,omitempty"`
+	RatioDenominator                   string   `json:"ratioDenominator,omitempty"`
+}
+
+// ContributionDecomposition 非加法分解的结果，各效应合计等于总变化值
+type ContributionDecomposition struct {
+	Method       string                `json:"method"`       // 分解方法
+	BaseValue    float64               `json:"baseValue"`    // 基期目标值（mix_rate 为整体比率）
+	CurrentValue float64               `json:"currentValue"` // 本期目标值（mix_rate 为整体比率）
+	TotalChange  float64               `json:"totalChange"`  // 总变化值
+	Effects      []DecompositionEffect `json:"effects"`      // 各效应合计
+}
+
+// DecompositionEffect 单个效应的合计
+type DecompositionEffect struct {
+	Name    string  `json:"name"`
+	Value   float64 `json:"value"`
+	Percent float64 `json:"percent"` // 占总变化的百分比
+}
+
+// SugarFormulaAnomalyResponse SUGAR.ANOMALY 公式响应结构
+type SugarFormulaAnomalyResponse struct {
+	Results []map[string]interface{} `json:"results"` // 检出的异常值和变点，每行包含拆分维度、期间、类型、实际值、预期值、偏离值、异常分数；变点行的实际值、预期值为变点后、前两段的均值
+	Columns []string                 `json:"columns"` // 列信息
+	Count   int                      `json:"count"`   // 结果数量
+	Error   string                   `json:"error"`   // 错误信息
+
+	DateColumn   string                   `json:"dateColumn"`             // 按期汇总使用的日期字段
+	Grain        string                   `json:"grain"`                  // 期间粒度
+	Config       AnomalyDetectionSettings `json:"config"`                 // 实际生效的检测配置
+	Series       []AnomalySeries          `json:"series"`                 // 各条时间序列的逐期检测结果
+	Explanations []AnomalyExplanation     `json:"explanations,omitempty"` // 异常期的贡献度分析
+	Warnings     []string                 `json:"warnings,omitempty"`     // 警告信息
+}
+
+// AnomalyDetectionSettings 异常与变点检测配置
+type AnomalyDetectionSettings struct {
+	Threshold          float64 `json:"threshold"`
+	SeasonLength       int     `json:"seasonLength"`
+	TrendWindow        int     `json:"trendWindow"`
+	ChangePointPenalty float64 `json:"changePointPenalty"`
+	MinSegmentLength   int     `json:"minSegmentLength"`
+	MaxChangePoints    int     `json:"maxChangePoints"`
+}
+
+// AnomalySeries 单个维度组合的时间序列检测结果
+type AnomalySeries struct {
+	Dimensions   map[string]interface{} `json:"dimensions,omitempty"` // 拆分维度取值，未拆分时为空
+	Method       string                 `json:"method"`               // 检测方法: robust, seasonal
+	SeasonLength int                    `json:"seasonLength"`         // 实际使用的季节周期，未做季节分解时为0
+	Points       []AnomalyPoint         `json:"points"`
+	ChangePoints []AnomalyChangePoint   `json:"changePoints"`
+}
+
+// AnomalyPoint 单期检测结果
+type AnomalyPoint struct {
+	Period    string      `json:"period"`    // 期间标签，如 2024-03、2024Q1
+	Range     PeriodRange `json:"range"`     // 期间的起止日期
+	Value     float64     `json:"value"`     // 实际值
+	Expected  float64     `json:"expected"`  // 预期值（趋势 + 季节项）
+	Score     float64     `json:"score"`     // 异常分数（残差的稳健 z 分数）
+	IsAnomaly bool        `json:"isAnomaly"` // 是否为异常值
+}
+
+// AnomalyChangePoint 均值变点，Period 为新水平的第一期
+type AnomalyChangePoint struct {
+	Period     string      `json:"period"`
+	Range      PeriodRange `json:"range"`
+	BeforeMean float64     `json:"beforeMean"` // 变点前一段的均值（已去除季节项）
+	AfterMean  float64     `json:"afterMean"`  // 变点后一段的均值（已去除季节项）
+	Shift      float64     `json:"shift"`      // 水平变化
+	Score      float64     `json:"score"`      // 水平变化与噪声水平之比
+}
+
+// AnomalyExplanation 异常期的贡献度分析
+type AnomalyExplanation struct {
+	Dimensions   map[string]interface{}            `json:"dimensions,omitempty"` // 异常所在序列的拆分维度取值
+	Period       string                            `json:"period"`               // 异常期
+	Score        float64                           `json:"score"`                // 异常分数
+	Current      PeriodRange                       `json:"current"`              // 本期（异常期）
+	Base         PeriodRange                       `json:"base"`                 // 基期
+	Contribution *SugarFormulaContributionResponse `json:"contribution"`         // 贡献度分析结果
+}
+
+// TimeIntelligenceInfo 时间智能计算实际使用的期间
+type TimeIntelligenceInfo struct {
+	Function   string       `json:"function"`
+	DateColumn string       `json:"dateColumn"`     // 用于筛选的日期字段
+	Current    PeriodRange  `json:"current"`        // 本期
+	Base       *PeriodRange `json:"base,omitempty"` // 对比期
+}
+
+// PeriodRange 期间（含首尾）
+type PeriodRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
 }
 
 // NewCalcSuccessResponse 创建成功的计算响应
@@ -48,3 +216,21 @@ func NewGetErrorResponse(error string) *SugarFormulaGetResponse {
 		Error:   error,
 	}
 }
+
+// NewContributionErrorResponse 创建错误的贡献度分析响应
+func NewContributionErrorResponse(error string) *SugarFormulaContributionResponse {
+	return &SugarFormulaContributionResponse{
+		Results: []map[string]interface{}{},
+		Count:   0,
+		Error:   error,
+	}
+}
+
+// NewAnomalyErrorResponse 创建错误的异常检测响应
+func NewAnomalyErrorResponse(error string) *SugarFormulaAnomalyResponse {
+	return &SugarFormulaAnomalyResponse{
+		Results: []map[string]interface{}{},
+		Count:   0,
+		Error:   error,
+	}
+}
@@ -0,0 +1,24 @@
+package response
+
+import "time"
+
+// 隐私预算授予方式
+const (
+	PrivacyBudgetModeFull    = "full"    // 按配置的ε完整授予
+	PrivacyBudgetModeReduced = "reduced" // 剩余预算不足一次完整消耗，按剩余ε授予，噪声更大
+	PrivacyBudgetModeCoarse  = "coarse"  // 预算耗尽，只输出定性结论
+	PrivacyBudgetModeRefused = "refused" // 预算耗尽，拒绝分析
+)
+
+// SugarPrivacyBudgetStatus 一次AIFETCH分析的隐私预算状态
+type SugarPrivacyBudgetStatus struct {
+	SemanticModelId  string    `json:"semanticModelId"`  // 语义模型ID
+	ModelName        string    `json:"modelName"`        // 语义模型名称
+	WindowStart      time.Time `json:"windowStart"`      // 时间窗口开始
+	WindowEnd        time.Time `json:"windowEnd"`        // 时间窗口结束
+	EpsilonBudget    float64   `json:"epsilonBudget"`    // 窗口内的ε总预算
+	EpsilonSpent     float64   `json:"epsilonSpent"`     // 含本次在内已消耗的ε
+	EpsilonRemaining float64   `json:"epsilonRemaining"` // 剩余ε
+	EpsilonGranted   float64   `json:"epsilonGranted"`   // 本次授予的ε
+	Mode             string    `json:"mode"`             // 授予方式：full / reduced / coarse / refused
+}
@@ -0,0 +1,35 @@
+package response
+
+import "time"
+
+// SugarRecycleBinItem 回收站中的项目，文件夹连同其下内容作为一项展示
+type SugarRecycleBinItem struct {
+	Id            string     `json:"id"`            // 项目ID
+	Name          string     `json:"name"`          // 名称
+	Type          string     `json:"type"`          // 类型：folder 或 file
+	TeamId        string     `json:"teamId"`        // 团队ID
+	ParentId      *string    `json:"parentId"`      // 删除前所在的文件夹ID
+	ParentName    string     `json:"parentName"`    // 删除前所在的文件夹名称，根目录为空
+	ItemCount     int64      `json:"itemCount"`     // 随该项目一起删除的子项目数
+	DeletedBy     *string    `json:"deletedBy"`     // 删除者ID
+	DeletedByName string     `json:"deletedByName"` // 删除者昵称
+	DeletedAt     *time.Time `json:"deletedAt"`     // 删除时间
+	PurgeAt       *time.Time `json:"purgeAt"`       // 预计彻底删除的时间
+}
+
+// SugarRecycleBinRestoreConflict 恢复时原位置已存在同名项目
+type SugarRecycleBinRestoreConflict struct {
+	ExistingId    string `json:"existingId"`    // 同名项目ID
+	Name          string `json:"name"`          // 冲突的名称
+	SuggestedName string `json:"suggestedName"` // 建议使用的新名称
+}
+
+// SugarRecycleBinRestoreResponse 恢复结果
+type SugarRecycleBinRestoreResponse struct {
+	Id            string  `json:"id"`            // 项目ID
+	Name          string  `json:"name"`          // 恢复后的名称
+	ParentId      *string `json:"parentId"`      // 恢复到的文件夹ID，为空表示根目录
+	Renamed       bool    `json:"renamed"`       // 是否因同名冲突被重命名
+	MovedToRoot   bool    `json:"movedToRoot"`   // 原文件夹已不存在，恢复到了团队根目录
+	RestoredCount int     `json:"restoredCount"` // 恢复的项目总数（含子项目）
+}
@@ -0,0 +1,35 @@
+package response
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// SugarShareLinkItem 分享链接列表项
+type SugarShareLinkItem struct {
+	Id              int64      `json:"id"`              // 链接ID
+	WorkspaceId     string     `json:"workspaceId"`     // 工作簿文件ID
+	Token           string     `json:"token"`           // 链接令牌
+	PermissionLevel string     `json:"permissionLevel"` // 权限级别
+	HasPassword     bool       `json:"hasPassword"`     // 是否需要密码
+	ExpiresAt       *time.Time `json:"expiresAt"`       // 过期时间
+	Expired         bool       `json:"expired"`         // 是否已过期
+	IsActive        bool       `json:"isActive"`        // 是否有效
+	FreezeData      bool       `json:"freezeData"`      // 是否冻结 SUGAR 和 AI.FETCH 公式结果
+	CreatedBy       *string    `json:"createdBy"`       // 创建者ID
+	CreatorName     string     `json:"creatorName"`     // 创建者昵称
+	CreatedAt       *time.Time `json:"createdAt"`       // 创建时间
+}
+
+// SugarSharedWorkbookResponse 通过链接打开的工作簿会话
+type SugarSharedWorkbookResponse struct {
+	SessionToken     string         `json:"sessionToken"`                 // 会话令牌，可编辑的链接保存时需携带
+	SessionExpiresAt time.Time      `json:"sessionExpiresAt"`             // 会话过期时间，过期后需重新打开链接
+	Name             string         `json:"name"`                         // 工作簿名称
+	PermissionLevel  string         `json:"permissionLevel"`              // 权限级别
+	ReadOnly         bool           `json:"readOnly"`                     // 是否只读
+	Frozen           bool           `json:"frozen"`                       // 内容是否为冻结的快照，SUGAR 和 AI.FETCH 公式已替换为分享时的结果
+	Content          datatypes.JSON `json:"content" swaggertype:"object"` // 工作簿内容
+	Revision         int            `json:"revision"`                     // 内容修订号，保存时需原样带回
+}
@@ -0,0 +1,59 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 工作簿实时协作消息类型
+const (
+	WorkbookCollabTypeWelcome         = "welcome"         // 服务器 -> 客户端：连接建立，携带会话ID、最新操作修订号和在线协作者
+	WorkbookCollabTypeOperation       = "op"              // 客户端提交 Univer 变更命令；服务器向其他协作者广播带修订号的命令
+	WorkbookCollabTypeAck             = "ack"             // 服务器 -> 客户端：确认客户端提交的命令及分配的修订号
+	WorkbookCollabTypeSync            = "sync"            // 客户端 -> 服务器：请求 revision 之后的操作
+	WorkbookCollabTypeOperations      = "ops"             // 服务器 -> 客户端：补发的操作列表
+	WorkbookCollabTypeReload          = "reload"          // 服务器 -> 客户端：请求的操作已压缩到内容快照或提交的快照已过期，需要重新加载工作簿
+	WorkbookCollabTypePresence        = "presence"        // 在线状态和选区，客户端提交后广播给其他协作者
+	WorkbookCollabTypeLeave           = "leave"           // 服务器 -> 客户端：协作者离开
+	WorkbookCollabTypeSnapshotRequest = "snapshotRequest" // 服务器 -> 客户端：请求提交内容快照以压缩操作日志
+	WorkbookCollabTypeSnapshot        = "snapshot"        // 客户端 -> 服务器：提交已应用到 revision 的内容快照，documentRevision 为加载时的内容修订号
+	WorkbookCollabTypeSnapshotSaved   = "snapshotSaved"   // 服务器 -> 客户端：内容快照已保存
+	WorkbookCollabTypeError           = "error"           // 服务器 -> 客户端：处理消息失败
+)
+
+// WorkbookCollabMessage 工作簿实时协作消息，不同类型使用的字段见消息类型说明
+type WorkbookCollabMessage struct {
+	Type             string                    `json:"type"`
+	SessionId        string                    `json:"sessionId,omitempty"`        // 协作会话ID
+	UserId           string                    `json:"userId,omitempty"`           // 用户ID
+	UserName         string                    `json:"userName,omitempty"`         // 用户名称
+	ClientSeq        int64                     `json:"clientSeq,omitempty"`        // 客户端命令序号，ack 时原样返回
+	Revision         int                       `json:"revision,omitempty"`         // 操作修订号
+	SnapshotRevision int                       `json:"snapshotRevision,omitempty"` // 内容快照已包含的操作修订号
+	DocumentRevision int                       `json:"documentRevision,omitempty"` // 工作簿内容修订号，与整体保存的乐观并发控制一致
+	Joined           bool                      `json:"joined,omitempty"`           // presence 消息是否为新加入的协作者
+	ReadOnly         bool                      `json:"readOnly,omitempty"`         // welcome 消息中表示当前用户没有编辑权限
+	Command          json.RawMessage           `json:"command,omitempty"`          // Univer 变更命令 {id, params}
+	Selection        json.RawMessage           `json:"selection,omitempty"`        // 当前选区
+	Content          json.RawMessage           `json:"content,omitempty"`          // 内容快照
+	Operations       []WorkbookCollabOperation `json:"ops,omitempty"`              // 补发的操作
+	Users            []WorkbookCollabPresence  `json:"users,omitempty"`            // 在线协作者
+	Message          string                    `json:"message,omitempty"`          // 错误信息
+}
+
+// WorkbookCollabOperation 操作日志中的一条命令
+type WorkbookCollabOperation struct {
+	Revision  int             `json:"revision"`
+	SessionId string          `json:"sessionId"`
+	UserId    string          `json:"userId"`
+	Command   json.RawMessage `json:"command"`
+	CreatedAt *time.Time      `json:"createdAt"`
+}
+
+// WorkbookCollabPresence 协作者的在线状态
+type WorkbookCollabPresence struct {
+	SessionId string          `json:"sessionId"`
+	UserId    string          `json:"userId"`
+	UserName  string          `json:"userName"`
+	Selection json.RawMessage `json:"selection,omitempty"`
+}
@@ -0,0 +1,58 @@
+package response
+
+// SugarLineageCellReference 单元格中的一处引用
+type SugarLineageCellReference struct {
+	Sheet    string `json:"sheet"`    // 工作表名
+	Cell     string `json:"cell"`     // 单元格地址，如 B3
+	Function string `json:"function"` // 公式函数，如 SUGAR.GET
+	Column   string `json:"column"`   // 引用的模型字段，无法确定时为空
+}
+
+// SugarLineageWorkbook 依赖某个对象的工作簿
+type SugarLineageWorkbook struct {
+	Id             string                      `json:"id"`             // 工作簿ID
+	Name           string                      `json:"name"`           // 工作簿名称
+	TeamId         string                      `json:"teamId"`         // 所属团队
+	TeamName       string                      `json:"teamName"`       // 团队名称
+	ParentId       *string                     `json:"parentId"`       // 所在文件夹
+	References     []SugarLineageCellReference `json:"references"`     // 引用所在的单元格，最多返回前几处
+	ReferenceCount int                         `json:"referenceCount"` // 引用总数
+}
+
+// SugarLineageModel 数据库连接下的语义模型
+type SugarLineageModel struct {
+	Id            string `json:"id"`            // 语义模型ID
+	Name          string `json:"name"`          // 语义模型名称
+	WorkbookCount int    `json:"workbookCount"` // 依赖该模型的工作簿数
+}
+
+// SugarLineageColumn 被工作簿引用的模型字段
+type SugarLineageColumn struct {
+	Name          string `json:"name"`          // 字段名称
+	WorkbookCount int    `json:"workbookCount"` // 引用该字段的工作簿数
+}
+
+// SugarLineageImpact 影响分析结果：修改或删除对象后会受影响的语义模型和工作簿
+type SugarLineageImpact struct {
+	Kind          string                 `json:"kind"`          // 对象类型 connection/model/agent
+	Id            string                 `json:"id"`            // 对象ID
+	Name          string                 `json:"name"`          // 对象名称
+	Models        []SugarLineageModel    `json:"models"`        // 数据库连接下的语义模型
+	Columns       []SugarLineageColumn   `json:"columns"`       // 被引用的模型字段
+	Workbooks     []SugarLineageWorkbook `json:"workbooks"`     // 用户有权查看的依赖工作簿
+	WorkbookCount int                    `json:"workbookCount"` // 依赖的工作簿总数，包括用户无权查看的
+}
+
+// SugarWorkbookDependency 工作簿依赖的语义模型或智能体
+type SugarWorkbookDependency struct {
+	Kind           string                      `json:"kind"`           // 对象类型 model/agent
+	Name           string                      `json:"name"`           // 公式中书写的名称
+	Id             string                      `json:"id"`             // 解析到的对象ID，找不到时为空
+	TeamId         string                      `json:"teamId"`         // 对象所属团队
+	ConnectionId   string                      `json:"connectionId"`   // 语义模型使用的数据库连接
+	ConnectionName string                      `json:"connectionName"` // 数据库连接名称
+	Columns        []string                    `json:"columns"`        // 引用的模型字段
+	MissingColumns []string                    `json:"missingColumns"` // 模型中已不存在的字段
+	References     []SugarLineageCellReference `json:"references"`     // 引用所在的单元格，最多返回前几处
+	ReferenceCount int                         `json:"referenceCount"` // 引用总数
+}
@@ -0,0 +1,23 @@
+package response
+
+import "time"
+
+// SugarWorkbookSearchMatch 工作簿中命中的位置
+type SugarWorkbookSearchMatch struct {
+	Sheet   string `json:"sheet"`   // 工作表名
+	Cell    string `json:"cell"`    // 单元格地址，如 B3；命中工作表名时为空
+	Text    string `json:"text"`    // 单元格文本
+	Formula string `json:"formula"` // 单元格公式
+}
+
+// SugarWorkbookSearchItem 搜索结果
+type SugarWorkbookSearchItem struct {
+	Id         string                     `json:"id"`         // 工作簿ID
+	Name       string                     `json:"name"`       // 工作簿名称
+	TeamId     string                     `json:"teamId"`     // 所属团队
+	TeamName   string                     `json:"teamName"`   // 团队名称
+	ParentId   *string                    `json:"parentId"`   // 所在文件夹
+	UpdatedAt  *time.Time                 `json:"updatedAt"`  // 最后修改时间
+	Matches    []SugarWorkbookSearchMatch `json:"matches"`    // 命中的位置，最多返回前几处
+	MatchCount int                        `json:"matchCount"` // 命中的位置总数
+}
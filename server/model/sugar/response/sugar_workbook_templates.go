@@ -0,0 +1,20 @@
+package response
+
+import (
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+)
+
+// SugarWorkbookTemplateItem 模板库列表项
+type SugarWorkbookTemplateItem struct {
+	Id           int64                          `json:"id"`           // 模板ID
+	TeamId       string                         `json:"teamId"`       // 所属团队
+	Name         string                         `json:"name"`         // 模板名称
+	Description  string                         `json:"description"`  // 模板说明
+	SourceFileId *string                        `json:"sourceFileId"` // 生成模板的工作簿ID
+	Parameters   []sugar.SugarTemplateParameter `json:"parameters"`   // 实例化时可填写的参数
+	CreatedBy    *string                        `json:"createdBy"`    // 创建者ID
+	CreatorName  string                         `json:"creatorName"`  // 创建者昵称
+	CreatedAt    *time.Time                     `json:"createdAt"`    // 创建时间
+}
@@ -0,0 +1,36 @@
+package response
+
+import "time"
+
+// SugarWorkspaceGrantItem 文件或文件夹上的一条授权，包括从上级文件夹继承的授权
+type SugarWorkspaceGrantItem struct {
+	Id              int64      `json:"id"`              // 授权ID，撤销时使用
+	WorkspaceId     string     `json:"workspaceId"`     // 授权所在的文件或文件夹ID
+	WorkspaceName   string     `json:"workspaceName"`   // 授权所在的文件或文件夹名称
+	Inherited       bool       `json:"inherited"`       // 是否继承自上级文件夹
+	GranteeType     string     `json:"granteeType"`     // 授权对象类型 user/team
+	GranteeId       string     `json:"granteeId"`       // 用户ID或团队ID
+	GranteeName     string     `json:"granteeName"`     // 用户昵称或团队名称
+	PermissionLevel string     `json:"permissionLevel"` // 权限级别
+	CreatedBy       *string    `json:"createdBy"`       // 授权人
+	CreatedAt       *time.Time `json:"createdAt"`       // 授权时间
+}
+
+// SugarWorkspaceAccessEntry 一个用户或团队对文件的有效权限
+type SugarWorkspaceAccessEntry struct {
+	GranteeType     string   `json:"granteeType"`     // user/team
+	GranteeId       string   `json:"granteeId"`       // 用户ID或团队ID
+	GranteeName     string   `json:"granteeName"`     // 用户昵称或团队名称
+	Role            string   `json:"role"`            // 作为文件所属团队成员的角色，非成员为空
+	PermissionLevel string   `json:"permissionLevel"` // 授权获得的最高权限级别，没有授权为空
+	GrantedOn       string   `json:"grantedOn"`       // 权限级别来自哪个文件或文件夹的授权
+	Actions         []string `json:"actions"`         // 合并团队角色和授权后允许的操作
+}
+
+// SugarWorkspaceAccessResponse 文件或文件夹的有效权限
+type SugarWorkspaceAccessResponse struct {
+	WorkspaceId string                      `json:"workspaceId"` // 文件或文件夹ID
+	TeamId      string                      `json:"teamId"`      // 所属团队ID
+	Actions     []string                    `json:"actions"`     // 当前用户允许的操作
+	Entries     []SugarWorkspaceAccessEntry `json:"entries"`     // 团队成员及被授权的用户、团队
+}
@@ -0,0 +1,35 @@
+package response
+
+import (
+	"gorm.io/datatypes"
+)
+
+// SugarWorkbookContentResponse 工作簿内容及其修订号
+type SugarWorkbookContentResponse struct {
+	Content           datatypes.JSON `json:"content" swaggertype:"object"` // 工作簿内容
+	Revision          int            `json:"revision"`                     // 内容修订号，保存时需原样带回
+	OperationRevision int            `json:"operationRevision"`            // 内容已包含的协作操作修订号，加入实时协作时从该修订号之后同步操作
+}
+
+// SugarWorkbookSaveResponse 保存工作簿的结果
+type SugarWorkbookSaveResponse struct {
+	Revision int            `json:"revision"`                               // 保存后的修订号
+	Version  int            `json:"version"`                                // 保存后对应的历史版本号
+	Merged   bool           `json:"merged"`                                 // 是否与他人的修改自动合并
+	Content  datatypes.JSON `json:"content,omitempty" swaggertype:"object"` // 自动合并后的内容，客户端应以此刷新工作簿
+}
+
+// SugarWorkbookSaveConflict 保存冲突：提交的修订号已过期且无法自动合并
+type SugarWorkbookSaveConflict struct {
+	CurrentRevision int                     `json:"currentRevision"` // 服务器当前的修订号
+	NeedBaseContent bool                    `json:"needBaseContent"` // 未提供基准内容，携带 baseContent 重新提交即可尝试自动合并
+	Conflicts       []WorkbookMergeConflict `json:"conflicts"`       // 双方修改了同一位置，无法自动合并
+}
+
+// WorkbookMergeConflict 三方合并中双方修改了同一位置
+type WorkbookMergeConflict struct {
+	SheetId   string `json:"sheetId"`   // 工作表ID，工作簿级属性冲突时为空
+	SheetName string `json:"sheetName"` // 工作表名称
+	Cell      string `json:"cell"`      // 冲突的单元格地址，如 B3
+	Property  string `json:"property"`  // 冲突的属性，如 styles、mergeData；整个工作表冲突时为 sheet
+}
@@ -3,23 +3,31 @@ package sugar
 
 import (
 	"time"
+
+	"gorm.io/datatypes"
 )
 
 // sugar智能体表 结构体  SugarAgents
 type SugarAgents struct {
-	Id             *string    `json:"id" form:"id" gorm:"primarykey;column:id;"`                 //id字段
-	Name           *string    `json:"name" form:"name" gorm:"column:name;size:100;"`             //name字段
-	Description    *string    `json:"description" form:"description" gorm:"column:description;"` //description字段
-	Prompt         *string    `json:"prompt" form:"prompt" gorm:"column:prompt;"`
-	Semantic       *string    `json:"semantic" form:"semantic" gorm:"column:semantic;"`
-	AgentType      string     `json:"agentType" form:"agentType" gorm:"comment:系统预置, 团队自定义;column:agent_type;type:enum('');"`                                         //系统预置, 团队自定义
-	TeamId         *string    `json:"teamId" form:"teamId" gorm:"column:team_id;"`                                                                                    //teamId字段
-	EndpointConfig string     `json:"endpointConfig" form:"endpointConfig" gorm:"comment:定义 Agent 的调用方式, 如 API URL, headers 等;column:endpoint_config;type:enum('');"` //定义 Agent 的调用方式, 如 API URL, headers 等
-	CreatedBy      *string    `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                                   //createdBy字段
-	CreatedAt      *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                           //createdAt字段
-	UpdatedBy      *string    `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`                                                                   //updatedBy字段
-	UpdatedAt      *time.Time `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                           //updatedAt字段
-	DeletedAt      *time.Time `json:"deletedAt" form:"deletedAt" gorm:"column:deleted_at;"`                                                                           //deletedAt字段
+	Id                   *string        `json:"id" form:"id" gorm:"primarykey;column:id;"`                 //id字段
+	Name                 *string        `json:"name" form:"name" gorm:"column:name;size:100;"`             //name字段
+	Description          *string        `json:"description" form:"description" gorm:"column:description;"` //description字段
+	Prompt               *string        `json:"prompt" form:"prompt" gorm:"column:prompt;"`
+	Semantic             *string        `json:"semantic" form:"semantic" gorm:"column:semantic;"`
+	AgentType            string         `json:"agentType" form:"agentType" gorm:"comment:系统预置, 团队自定义;column:agent_type;type:enum('');"`                                                    //系统预置, 团队自定义
+	TeamId               *string        `json:"teamId" form:"teamId" gorm:"column:team_id;"`                                                                                               //teamId字段
+	EndpointConfig       string         `json:"endpointConfig" form:"endpointConfig" gorm:"comment:定义 Agent 的调用方式, 如 API URL, headers 等;column:endpoint_config;type:enum('');"`            //定义 Agent 的调用方式, 如 API URL, headers 等
+	SystemPromptTemplate *string        `json:"systemPromptTemplate" form:"systemPromptTemplate" gorm:"comment:工具调用阶段的系统提示词模板, 为空时使用内置模板;column:system_prompt_template;"`                  //工具调用阶段的系统提示词模板, 为空时使用内置模板
+	AllowedTools         datatypes.JSON `json:"allowedTools" form:"allowedTools" gorm:"comment:允许调用的工具名称列表;column:allowed_tools;" swaggertype:"array,string"`                              //允许调用的工具名称列表
+	AllowedModels        datatypes.JSON `json:"allowedModels" form:"allowedModels" gorm:"comment:允许访问的语义模型名称列表, 为空表示不限制;column:allowed_models;" swaggertype:"array,string"`                //允许访问的语义模型名称列表, 为空表示不限制
+	ModelParams          datatypes.JSON `json:"modelParams" form:"modelParams" gorm:"comment:模型调用参数, 如 temperature, maxTokens;column:model_params;" swaggertype:"object"`                  //模型调用参数, 如 temperature, maxTokens
+	OutputSchema         datatypes.JSON `json:"outputSchema" form:"outputSchema" gorm:"comment:AIFETCH 默认的结构化输出定义;column:output_schema;" swaggertype:"object"`                             //AIFETCH 默认的结构化输出定义
+	AnonymizationPolicy  datatypes.JSON `json:"anonymizationPolicy" form:"anonymizationPolicy" gorm:"comment:匿名化策略覆盖, 已设置的字段只能收紧语义模型策略;column:anonymization_policy;" swaggertype:"object"` //匿名化策略覆盖, 已设置的字段只能收紧语义模型策略
+	CreatedBy            *string        `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                                              //createdBy字段
+	CreatedAt            *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                                      //createdAt字段
+	UpdatedBy            *string        `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`                                                                              //updatedBy字段
+	UpdatedAt            *time.Time     `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                                      //updatedAt字段
+	DeletedAt            *time.Time     `json:"deletedAt" form:"deletedAt" gorm:"column:deleted_at;"`                                                                                      //deletedAt字段
 }
 
 // TableName sugar智能体表 SugarAgents自定义表名 sugar_agents
@@ -0,0 +1,30 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Sugar匿名化会话 结构体  SugarAnonymizationSessions
+// 持久化AIFETCH的匿名化映射，用于合规审计时重新解码执行日志中的 AnonymizedOutput，以及同一对话的多轮之间复用代号
+type SugarAnonymizationSessions struct {
+	Id               *string        `json:"id" form:"id" gorm:"primarykey;column:id;"`                                                                       //id字段
+	ConversationId   *string        `json:"conversationId" form:"conversationId" gorm:"comment:所属对话ID, 同一对话的多轮复用同一会话;column:conversation_id;size:64;index;"` //所属对话ID
+	UserId           *string        `json:"userId" form:"userId" gorm:"comment:创建会话的用户ID;column:user_id;size:20;index;"`                                     //创建会话的用户ID
+	AgentId          *string        `json:"agentId" form:"agentId" gorm:"comment:关联的AI Agent ID;column:agent_id;size:36;"`                                   //关联的AI Agent ID
+	EncryptedMapping *string        `json:"-" gorm:"comment:AES-GCM加密的代号映射, 超过保留期限后清空;column:encrypted_mapping;type:text;"`                                  //加密的代号映射
+	Config           datatypes.JSON `json:"config" form:"config" gorm:"comment:匿名化配置;column:config;" swaggertype:"object"`                                   //匿名化配置
+	EpsilonSpent     float64        `json:"epsilonSpent" form:"epsilonSpent" gorm:"comment:累计消耗的差分隐私预算;column:epsilon_spent;default:0;"`                     //累计消耗的差分隐私预算
+	MappingCount     int            `json:"mappingCount" form:"mappingCount" gorm:"comment:代号映射数量;column:mapping_count;default:0;"`                          //代号映射数量
+	TurnCount        int            `json:"turnCount" form:"turnCount" gorm:"comment:使用该会话的分析轮数;column:turn_count;default:0;"`                               //使用该会话的分析轮数
+	ExpiresAt        *time.Time     `json:"expiresAt" form:"expiresAt" gorm:"comment:映射保留截止时间;column:expires_at;index;"`                                     //映射保留截止时间
+	PurgedAt         *time.Time     `json:"purgedAt" form:"purgedAt" gorm:"comment:映射销毁时间;column:purged_at;"`                                                //映射销毁时间
+	CreatedAt        *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                            //createdAt字段
+	UpdatedAt        *time.Time     `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                            //updatedAt字段
+}
+
+// TableName Sugar匿名化会话 SugarAnonymizationSessions自定义表名 sugar_anonymization_sessions
+func (SugarAnonymizationSessions) TableName() string {
+	return "sugar_anonymization_sessions"
+}
@@ -0,0 +1,24 @@
+package sugar
+
+import (
+	"time"
+)
+
+// Sugar API令牌 结构体  SugarApiTokens
+// 用于外部Agent通过MCP等接口以用户身份访问Sugar，数据库中只保存令牌的SHA-256摘要
+type SugarApiTokens struct {
+	Id          *string    `json:"id" form:"id" gorm:"primarykey;column:id;"`                                             //id字段
+	UserId      *string    `json:"userId" form:"userId" gorm:"comment:令牌所属用户;column:user_id;size:20;index;"`              //令牌所属用户
+	Name        *string    `json:"name" form:"name" gorm:"comment:令牌名称;column:name;size:100;"`                            //令牌名称
+	TokenHash   string     `json:"-" gorm:"comment:令牌SHA-256摘要;column:token_hash;size:64;uniqueIndex;"`                   //令牌SHA-256摘要
+	TokenPrefix string     `json:"tokenPrefix" form:"tokenPrefix" gorm:"comment:令牌前缀, 用于识别;column:token_prefix;size:16;"` //令牌前缀, 用于识别
+	ExpiresAt   *time.Time `json:"expiresAt" form:"expiresAt" gorm:"comment:过期时间, 为空表示永不过期;column:expires_at;"`           //过期时间, 为空表示永不过期
+	LastUsedAt  *time.Time `json:"lastUsedAt" form:"lastUsedAt" gorm:"comment:最近使用时间;column:last_used_at;"`               //最近使用时间
+	CreatedAt   *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                  //createdAt字段
+	DeletedAt   *time.Time `json:"deletedAt" form:"deletedAt" gorm:"column:deleted_at;"`                                  //deletedAt字段
+}
+
+// TableName Sugar API令牌 SugarApiTokens自定义表名 sugar_api_tokens
+func (SugarApiTokens) TableName() string {
+	return "sugar_api_tokens"
+}
@@ -0,0 +1,36 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 历史版本来源
+const (
+	FileVersionSourceSave     = "save"     // 保存工作簿时生成
+	FileVersionSourceRestore  = "restore"  // 恢复历史版本时生成
+	FileVersionSourceBaseline = "baseline" // 首次生成版本前，为已有内容补记的基线版本
+)
+
+// Sugar文件历史版本 结构体  SugarFileVersions
+// 每次保存工作簿时记录内容快照，同一用户的连续自动保存合并到同一个版本
+type SugarFileVersions struct {
+	Id            int64          `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                             //id字段
+	FileId        *string        `json:"fileId" form:"fileId" gorm:"comment:工作簿文件ID;column:file_id;size:36;uniqueIndex:uk_file_version,priority:1;"`          //工作簿文件ID
+	VersionNumber int            `json:"versionNumber" form:"versionNumber" gorm:"comment:版本号;column:version_number;uniqueIndex:uk_file_version,priority:2;"` //版本号
+	Content       datatypes.JSON `json:"content,omitempty" form:"content" gorm:"comment:工作簿内容快照;column:content;" swaggertype:"object"`                        //工作簿内容快照
+	Name          *string        `json:"name" form:"name" gorm:"comment:版本名称, 命名的版本不会被合并或清理;column:name;size:100;"`                                           //版本名称
+	IsPinned      bool           `json:"isPinned" form:"isPinned" gorm:"comment:是否置顶, 置顶的版本不会被合并或清理;column:is_pinned;default:false;"`                         //是否置顶
+	Source        string         `json:"source" form:"source" gorm:"comment:版本来源: save/restore/baseline;column:source;size:20;"`                              //版本来源
+	RestoredFrom  *int           `json:"restoredFrom" form:"restoredFrom" gorm:"comment:恢复自哪个版本号;column:restored_from;"`                                      //恢复自哪个版本号
+	SaveCount     int            `json:"saveCount" form:"saveCount" gorm:"comment:合并的保存次数;column:save_count;default:1;"`                                      //合并的保存次数
+	CreatedBy     *string        `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                        //createdBy字段
+	CreatedAt     *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                //createdAt字段
+	UpdatedAt     *time.Time     `json:"updatedAt" form:"updatedAt" gorm:"comment:最后一次合并保存的时间;column:updated_at;"`                                            //最后一次合并保存的时间
+}
+
+// TableName Sugar文件历史版本 SugarFileVersions自定义表名 sugar_file_versions
+func (SugarFileVersions) TableName() string {
+	return "sugar_file_versions"
+}
@@ -0,0 +1,28 @@
+package sugar
+
+import (
+	"time"
+)
+
+// Sugar隐私预算账本 结构体  SugarPrivacyBudgetLedgers
+// 按 用户 × 语义模型 × 时间窗口 累计AIFETCH消耗的差分隐私预算ε，防止多次请求平均掉噪声
+type SugarPrivacyBudgetLedgers struct {
+	Id              *string    `json:"id" form:"id" gorm:"primarykey;column:id;"`                                                                                                       //id字段
+	UserId          *string    `json:"userId" form:"userId" gorm:"comment:用户ID;column:user_id;size:20;uniqueIndex:idx_privacy_budget_window,priority:1;"`                               //用户ID
+	SemanticModelId *string    `json:"semanticModelId" form:"semanticModelId" gorm:"comment:语义模型ID;column:semantic_model_id;size:36;uniqueIndex:idx_privacy_budget_window,priority:2;"` //语义模型ID
+	ModelName       *string    `json:"modelName" form:"modelName" gorm:"comment:语义模型名称;column:model_name;size:100;"`                                                                    //语义模型名称
+	WindowStart     *time.Time `json:"windowStart" form:"windowStart" gorm:"comment:时间窗口开始;column:window_start;uniqueIndex:idx_privacy_budget_window,priority:3;"`                      //时间窗口开始
+	WindowEnd       *time.Time `json:"windowEnd" form:"windowEnd" gorm:"comment:时间窗口结束;column:window_end;"`                                                                             //时间窗口结束
+	EpsilonBudget   float64    `json:"epsilonBudget" form:"epsilonBudget" gorm:"comment:窗口内的ε总预算;column:epsilon_budget;"`                                                               //窗口内的ε总预算
+	EpsilonSpent    float64    `json:"epsilonSpent" form:"epsilonSpent" gorm:"comment:已消耗的ε;column:epsilon_spent;default:0;"`                                                           //已消耗的ε
+	QueryCount      int        `json:"queryCount" form:"queryCount" gorm:"comment:消耗预算的分析次数;column:query_count;default:0;"`                                                             //消耗预算的分析次数
+	CoarsenedCount  int        `json:"coarsenedCount" form:"coarsenedCount" gorm:"comment:预算不足时降级为定性输出的次数;column:coarsened_count;default:0;"`                                           //降级为定性输出的次数
+	RefusedCount    int        `json:"refusedCount" form:"refusedCount" gorm:"comment:预算不足时被拒绝的次数;column:refused_count;default:0;"`                                                     //被拒绝的次数
+	CreatedAt       *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                                            //createdAt字段
+	UpdatedAt       *time.Time `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                                            //updatedAt字段
+}
+
+// TableName Sugar隐私预算账本 SugarPrivacyBudgetLedgers自定义表名 sugar_privacy_budget_ledgers
+func (SugarPrivacyBudgetLedgers) TableName() string {
+	return "sugar_privacy_budget_ledgers"
+}
@@ -17,6 +17,9 @@ type SugarSemanticModels struct {
   ParameterConfig  datatypes.JSON `json:"parameterConfig" form:"parameterConfig" gorm:"comment:查询参数配置, 定义用户可用的筛选条件;column:parameter_config;" swaggertype:"object"`  //查询参数配置, 定义用户可用的筛选条件
   ReturnableColumnsConfig  datatypes.JSON `json:"returnableColumnsConfig" form:"returnableColumnsConfig" gorm:"comment:可返回字段配置, 定义用户可获取的数据列;column:returnable_columns_config;" swaggertype:"object"`  //可返回字段配置, 定义用户可获取的数据列
   PermissionKeyColumn  *string `json:"permissionKeyColumn" form:"permissionKeyColumn" gorm:"comment:用于行级权限判断的字段名, 如 city_code;column:permission_key_column;size:255;"`  //用于行级权限判断的字段名, 如 city_code
+  AnonymizationPolicy  datatypes.JSON `json:"anonymizationPolicy" form:"anonymizationPolicy" gorm:"comment:匿名化策略, 定义敏感维度、必须编码的值、k/l 阈值、噪声级别及是否允许输出绝对值;column:anonymization_policy;" swaggertype:"object"`  //匿名化策略, 定义敏感维度、必须编码的值、k/l 阈值、噪声级别及是否允许输出绝对值
+  AnonymizationSeed  *int64 `json:"-" gorm:"comment:确定性匿名化的噪声种子, 首次使用时生成, 修改后噪声随之变化;column:anonymization_seed;"`  //确定性匿名化的噪声种子, 首次使用时生成, 修改后噪声随之变化
+  AnalysisSemantics  datatypes.JSON `json:"analysisSemantics" form:"analysisSemantics" gorm:"comment:分析口径, 声明期间维度与粒度、余额/发生额指标、期初期末指标对及财年口径;column:analysis_semantics;" swaggertype:"object"`  //分析口径, 声明期间维度与粒度、余额/发生额指标、期初期末指标对及财年口径
   CreatedBy  *string `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`  //createdBy字段
   CreatedAt  *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`  //createdAt字段
   UpdatedBy  *string `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`  //updatedBy字段
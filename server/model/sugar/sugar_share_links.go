@@ -0,0 +1,36 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 分享链接权限级别
+const (
+	ShareLinkPermissionEditor = "editor" // 打开链接的人可以编辑
+	ShareLinkPermissionViewer = "viewer" // 打开链接的人只能查看
+)
+
+// Sugar分享链接 结构体  SugarShareLinks
+// 通过链接公开分享工作簿，打开者无需登录；访问时仍以创建者的权限校验，创建者失去权限后链接随之失效
+type SugarShareLinks struct {
+	Id              int64          `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                                     //id字段
+	WorkspaceId     *string        `json:"workspaceId" form:"workspaceId" gorm:"comment:工作簿文件ID;column:workspace_id;size:36;index:idx_sugar_share_links_workspace_id;"` //工作簿文件ID
+	Token           string         `json:"token" form:"token" gorm:"comment:链接令牌;column:token;size:50;uniqueIndex:uk_token;"`                                           //链接令牌
+	PermissionLevel string         `json:"permissionLevel" form:"permissionLevel" gorm:"comment:权限级别 editor/viewer;column:permission_level;size:20;"`                   //权限级别
+	PasswordHash    *string        `json:"-" gorm:"comment:访问密码的bcrypt摘要, 为空表示无需密码;column:password_hash;size:255;"`                                                     //访问密码摘要
+	ExpiresAt       *time.Time     `json:"expiresAt" form:"expiresAt" gorm:"comment:过期时间, 为空表示永不过期;column:expires_at;"`                                                 //过期时间
+	IsActive        bool           `json:"isActive" form:"isActive" gorm:"comment:是否有效;column:is_active;default:true;"`                                                 //是否有效
+	FreezeData      bool           `json:"freezeData" form:"freezeData" gorm:"comment:是否冻结 SUGAR 公式结果;column:freeze_data;default:false;"`                               //是否冻结 SUGAR 公式结果
+	Snapshot        datatypes.JSON `json:"-" gorm:"comment:冻结时保存的工作簿快照;column:snapshot;"`                                                                               //冻结时保存的工作簿快照
+	CreatedBy       *string        `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                                //createdBy字段
+	CreatedAt       *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                        //createdAt字段
+	UpdatedBy       *string        `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`                                                                //updatedBy字段
+	UpdatedAt       *time.Time     `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                        //updatedAt字段
+}
+
+// TableName Sugar分享链接 SugarShareLinks自定义表名 sugar_share_links
+func (SugarShareLinks) TableName() string {
+	return "sugar_share_links"
+}
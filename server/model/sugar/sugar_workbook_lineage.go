@@ -0,0 +1,27 @@
+package sugar
+
+// 工作簿引用的对象类型
+const (
+	WorkbookReferenceModel = "model" // SUGAR 公式引用的语义模型
+	WorkbookReferenceAgent = "agent" // AI.FETCH 公式引用的智能体
+)
+
+// Sugar工作簿引用 结构体  SugarWorkbookReferences
+// 血缘关系中工作簿一端的边：单元格公式按名称引用的语义模型及其字段、智能体；随工作簿内容写入时与搜索索引一起重建
+// 每个单元格引用的每个字段一条，公式中无法确定字段时（如字段来自单元格引用）字段名为空
+type SugarWorkbookReferences struct {
+	Id         int64   `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                                            //id字段
+	FileId     *string `json:"fileId" form:"fileId" gorm:"comment:工作簿文件ID;column:file_id;size:36;index:idx_sugar_workbook_references_file_id;"`                    //工作簿文件ID
+	Kind       string  `json:"kind" form:"kind" gorm:"comment:引用的对象类型 model/agent;column:kind;size:10;index:idx_sugar_workbook_references_target,priority:1;"`     //引用的对象类型
+	NameKey    string  `json:"-" form:"-" gorm:"comment:小写的模型或智能体名称, 用于不区分大小写的查找;column:name_key;size:100;index:idx_sugar_workbook_references_target,priority:2;"` //小写的名称
+	Name       string  `json:"name" form:"name" gorm:"comment:公式中书写的模型或智能体名称;column:name;size:100;"`                                                               //公式中书写的名称
+	ColumnName string  `json:"columnName" form:"columnName" gorm:"comment:引用的模型字段, 包括返回列、计算列、维度和筛选条件;column:column_name;size:255;"`                                //引用的模型字段
+	Function   string  `json:"function" form:"function" gorm:"comment:公式函数, 如 SUGAR.GET;column:function;size:32;"`                                                 //公式函数
+	Sheet      string  `json:"sheet" form:"sheet" gorm:"comment:工作表名;column:sheet;size:100;"`                                                                      //工作表名
+	Cell       string  `json:"cell" form:"cell" gorm:"comment:单元格地址, 如 B3;column:cell;size:20;"`                                                                   //单元格地址
+}
+
+// TableName Sugar工作簿引用 SugarWorkbookReferences自定义表名 sugar_workbook_references
+func (SugarWorkbookReferences) TableName() string {
+	return "sugar_workbook_references"
+}
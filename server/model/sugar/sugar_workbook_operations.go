@@ -0,0 +1,24 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Sugar工作簿协作操作日志 结构体  SugarWorkbookOperations
+// 实时协作时客户端提交的 Univer 变更命令，按服务器分配的修订号排序；压缩为内容快照后删除
+type SugarWorkbookOperations struct {
+	Id        int64          `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                            //id字段
+	FileId    *string        `json:"fileId" form:"fileId" gorm:"comment:工作簿文件ID;column:file_id;size:36;uniqueIndex:uk_file_operation,priority:1;"`       //工作簿文件ID
+	Revision  int            `json:"revision" form:"revision" gorm:"comment:操作修订号, 同一文件内连续递增;column:revision;uniqueIndex:uk_file_operation,priority:2;"` //操作修订号
+	Command   datatypes.JSON `json:"command" form:"command" gorm:"comment:Univer变更命令;column:command;" swaggertype:"object"`                              //Univer变更命令
+	SessionId string         `json:"sessionId" form:"sessionId" gorm:"comment:提交操作的协作会话ID;column:session_id;size:36;"`                                   //提交操作的协作会话ID
+	CreatedBy *string        `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                       //createdBy字段
+	CreatedAt *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                               //createdAt字段
+}
+
+// TableName Sugar工作簿协作操作日志 SugarWorkbookOperations自定义表名 sugar_workbook_operations
+func (SugarWorkbookOperations) TableName() string {
+	return "sugar_workbook_operations"
+}
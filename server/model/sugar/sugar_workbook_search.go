@@ -0,0 +1,40 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 搜索词类型，公式引用的模型和智能体由 SugarWorkbookReferences 记录，不在搜索词中重复索引
+const (
+	WorkbookSearchTermText = "text" // 工作表名、单元格文本和公式中的词
+)
+
+// Sugar工作簿搜索文档 结构体  SugarWorkbookSearchDocuments
+// 每个工作簿一条，保存从内容中提取的工作表名、单元格文本和公式，用于展示命中位置；随工作簿内容写入时重建
+type SugarWorkbookSearchDocuments struct {
+	FileId    *string        `json:"fileId" form:"fileId" gorm:"primarykey;column:file_id;size:36;"`                                    //工作簿文件ID
+	Entries   datatypes.JSON `json:"entries" form:"entries" gorm:"comment:提取的工作表名、单元格文本和公式;column:entries;" swaggertype:"array,object"` //提取的内容
+	Revision  int            `json:"revision" form:"revision" gorm:"comment:建立索引时的内容修订号;column:revision;default:0;"`                    //建立索引时的内容修订号
+	IndexedAt *time.Time     `json:"indexedAt" form:"indexedAt" gorm:"comment:建立索引的时间;column:indexed_at;"`                              //建立索引的时间
+}
+
+// TableName Sugar工作簿搜索文档 SugarWorkbookSearchDocuments自定义表名 sugar_workbook_search_documents
+func (SugarWorkbookSearchDocuments) TableName() string {
+	return "sugar_workbook_search_documents"
+}
+
+// Sugar工作簿搜索词 结构体  SugarWorkbookSearchTerms
+// 倒排索引：英文和数字按词、中日韩文字按单字和相邻两字切分
+type SugarWorkbookSearchTerms struct {
+	Id     int64   `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                               //id字段
+	FileId *string `json:"fileId" form:"fileId" gorm:"comment:工作簿文件ID;column:file_id;size:36;index:idx_sugar_workbook_search_terms_file_id;"`     //工作簿文件ID
+	Kind   string  `json:"kind" form:"kind" gorm:"comment:搜索词类型 text;column:kind;size:10;index:idx_sugar_workbook_search_terms_term,priority:1;"` //搜索词类型
+	Term   string  `json:"term" form:"term" gorm:"comment:小写的搜索词;column:term;size:191;index:idx_sugar_workbook_search_terms_term,priority:2;"`    //小写的搜索词
+}
+
+// TableName Sugar工作簿搜索词 SugarWorkbookSearchTerms自定义表名 sugar_workbook_search_terms
+func (SugarWorkbookSearchTerms) TableName() string {
+	return "sugar_workbook_search_terms"
+}
@@ -0,0 +1,35 @@
+package sugar
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// SugarTemplateParameter 模板参数，实例化时替换 SUGAR 公式参数中的 {{name}} 占位符
+type SugarTemplateParameter struct {
+	Name         string `json:"name"`         // 占位符名称
+	Label        string `json:"label"`        // 显示名称
+	DefaultValue string `json:"defaultValue"` // 默认值，为空表示实例化时必填
+}
+
+// Sugar工作簿模板 结构体  SugarWorkbookTemplates
+// 团队模板库中的模板，由已有工作簿生成，保存生成时的内容快照
+type SugarWorkbookTemplates struct {
+	Id           int64          `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                                     //id字段
+	TeamId       *string        `json:"teamId" form:"teamId" gorm:"comment:所属团队;column:team_id;size:36;index:idx_sugar_workbook_templates_team_id;"`                 //所属团队
+	Name         string         `json:"name" form:"name" gorm:"comment:模板名称;column:name;size:255;"`                                                                  //模板名称
+	Description  string         `json:"description" form:"description" gorm:"comment:模板说明;column:description;size:500;"`                                             //模板说明
+	SourceFileId *string        `json:"sourceFileId" form:"sourceFileId" gorm:"comment:生成模板的工作簿ID;column:source_file_id;size:36;"`                                   //生成模板的工作簿ID
+	Content      datatypes.JSON `json:"-" gorm:"comment:模板内容快照;column:content;"`                                                                                     //模板内容快照
+	Parameters   datatypes.JSON `json:"parameters" form:"parameters" gorm:"comment:模板参数定义, SugarTemplateParameter 数组;column:parameters;" swaggertype:"array,object"` //模板参数定义
+	CreatedBy    *string        `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                                //createdBy字段
+	CreatedAt    *time.Time     `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                        //createdAt字段
+	UpdatedBy    *string        `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`                                                                //updatedBy字段
+	UpdatedAt    *time.Time     `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                        //updatedAt字段
+}
+
+// TableName Sugar工作簿模板 SugarWorkbookTemplates自定义表名 sugar_workbook_templates
+func (SugarWorkbookTemplates) TableName() string {
+	return "sugar_workbook_templates"
+}
@@ -0,0 +1,39 @@
+package sugar
+
+import (
+	"time"
+)
+
+// 授权对象类型
+const (
+	WorkspaceGranteeUser = "user" // 单个用户，可以不是文件所属团队的成员
+	WorkspaceGranteeTeam = "team" // 团队的全部成员
+)
+
+// 授权级别，授予文件夹的权限会继承到其下全部文件和文件夹；分享只能由团队角色获得
+const (
+	WorkspacePermissionEditor = "editor" // 查看和编辑
+	WorkspacePermissionViewer = "viewer" // 只能查看
+)
+
+// WorkspacePermissionLegacyCommenter 旧版本的评论授权，没有对应的评论功能，启动时迁移为查看授权
+const WorkspacePermissionLegacyCommenter = "commenter"
+
+// Sugar文件权限授予 结构体  SugarWorkspacePermissions
+// 在团队角色之外为用户或团队单独授予文件/文件夹的权限，只会扩大访问范围，不会收回团队角色已有的权限
+type SugarWorkspacePermissions struct {
+	Id              int64      `json:"id" form:"id" gorm:"primaryKey;column:id;autoIncrement;"`                                                                                                                                          //id字段
+	WorkspaceId     *string    `json:"workspaceId" form:"workspaceId" gorm:"comment:文件或文件夹ID;column:workspace_id;size:36;uniqueIndex:uk_workspace_grantee,priority:1;"`                                                                  //文件或文件夹ID
+	GranteeType     string     `json:"granteeType" form:"granteeType" gorm:"comment:授权对象类型 user/team;column:grantee_type;size:10;uniqueIndex:uk_workspace_grantee,priority:2;index:idx_sugar_workspace_permissions_grantee,priority:1;"` //授权对象类型
+	GranteeId       string     `json:"granteeId" form:"granteeId" gorm:"comment:user_id 或 team_id;column:grantee_id;size:36;uniqueIndex:uk_workspace_grantee,priority:3;index:idx_sugar_workspace_permissions_grantee,priority:2;"`      //user_id 或 team_id
+	PermissionLevel string     `json:"permissionLevel" form:"permissionLevel" gorm:"comment:权限级别 editor/viewer;column:permission_level;size:20;"`                                                                                        //权限级别
+	CreatedBy       *string    `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`                                                                                                                                     //createdBy字段
+	CreatedAt       *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`                                                                                                                                             //createdAt字段
+	UpdatedBy       *string    `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`                                                                                                                                     //updatedBy字段
+	UpdatedAt       *time.Time `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`                                                                                                                                             //updatedAt字段
+}
+
+// TableName Sugar文件权限授予 SugarWorkspacePermissions自定义表名 sugar_workspace_permissions
+func (SugarWorkspacePermissions) TableName() string {
+	return "sugar_workspace_permissions"
+}
@@ -14,11 +14,15 @@ type SugarWorkspaces struct {
   ParentId  *string `json:"parentId" form:"parentId" gorm:"column:parent_id;"`  //parentId字段
   TeamId  *string `json:"teamId" form:"teamId" gorm:"comment:资源统一归属于团队;column:team_id;"`  //资源统一归属于团队
   Content  datatypes.JSON `json:"content" form:"content" gorm:"column:content;" swaggertype:"object"`  //content字段
+  Revision  int `json:"revision" form:"revision" gorm:"comment:内容修订号, 每次写入内容时递增, 用于乐观并发控制;column:revision;default:0;"`  //内容修订号
+  OperationRevision  int `json:"operationRevision" form:"operationRevision" gorm:"comment:内容快照已包含的协作操作修订号;column:operation_revision;default:0;"`  //内容快照已包含的协作操作修订号
   CreatedBy  *string `json:"createdBy" form:"createdBy" gorm:"column:created_by;size:20;"`  //createdBy字段
   CreatedAt  *time.Time `json:"createdAt" form:"createdAt" gorm:"column:created_at;"`  //createdAt字段
   UpdatedBy  *string `json:"updatedBy" form:"updatedBy" gorm:"column:updated_by;size:20;"`  //updatedBy字段
   UpdatedAt  *time.Time `json:"updatedAt" form:"updatedAt" gorm:"column:updated_at;"`  //updatedAt字段
   DeletedAt  *time.Time `json:"deletedAt" form:"deletedAt" gorm:"column:deleted_at;"`  //deletedAt字段
+  DeletedBy  *string `json:"deletedBy" form:"deletedBy" gorm:"comment:删除者;column:deleted_by;size:20;"`  //删除者
+  DeletedRoot  *string `json:"deletedRoot" form:"deletedRoot" gorm:"comment:随哪个项目一起被删除, 用户直接删除的项目为自身ID;column:deleted_root;size:36;index;"`  //随哪个项目一起被删除
 }
 
 
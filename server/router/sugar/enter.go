@@ -14,18 +14,40 @@ type RouterGroup struct {
 	SugarWorkspacesRouter
 	SugarFormulaQueryRouter
 	SugarFoldersRouter
+	SugarApiTokensRouter
+	SugarAnonymizationSessionsRouter
+	SugarPrivacyBudgetRouter
+	SugarFileVersionsRouter
+	SugarWorkbookCollaborationRouter
+	SugarWorkspacePermissionsRouter
+	SugarShareLinksRouter
+	SugarRecycleBinRouter
+	SugarWorkbookTemplatesRouter
+	SugarWorkbookSearchRouter
+	SugarWorkbookLineageRouter
 }
 
 var (
-	sugarTeamsApi             = api.ApiGroupApp.SugarApiGroup.SugarTeamsApi
-	sugarTeamMembersApi       = api.ApiGroupApp.SugarApiGroup.SugarTeamMembersApi
-	sugarDbConnectionsApi     = api.ApiGroupApp.SugarApiGroup.SugarDbConnectionsApi
-	sugarSemanticModelsApi    = api.ApiGroupApp.SugarApiGroup.SugarSemanticModelsApi
-	sugarAgentsApi            = api.ApiGroupApp.SugarApiGroup.SugarAgentsApi
-	sugarCityPermissionsApi   = api.ApiGroupApp.SugarApiGroup.SugarCityPermissionsApi
-	sugarRowLevelOverridesApi = api.ApiGroupApp.SugarApiGroup.SugarRowLevelOverridesApi
-	sugarExecutionLogsApi     = api.ApiGroupApp.SugarApiGroup.SugarExecutionLogsApi
-	sugarWorkspacesApi        = api.ApiGroupApp.SugarApiGroup.SugarWorkspacesApi
-	sugarFormulaQueryApi      = api.ApiGroupApp.SugarApiGroup.SugarFormulaQueryApi
-	sugarFoldersApi           = api.ApiGroupApp.SugarApiGroup.SugarFoldersApi
+	sugarTeamsApi                 = api.ApiGroupApp.SugarApiGroup.SugarTeamsApi
+	sugarTeamMembersApi           = api.ApiGroupApp.SugarApiGroup.SugarTeamMembersApi
+	sugarDbConnectionsApi         = api.ApiGroupApp.SugarApiGroup.SugarDbConnectionsApi
+	sugarSemanticModelsApi        = api.ApiGroupApp.SugarApiGroup.SugarSemanticModelsApi
+	sugarAgentsApi                = api.ApiGroupApp.SugarApiGroup.SugarAgentsApi
+	sugarCityPermissionsApi       = api.ApiGroupApp.SugarApiGroup.SugarCityPermissionsApi
+	sugarRowLevelOverridesApi     = api.ApiGroupApp.SugarApiGroup.SugarRowLevelOverridesApi
+	sugarExecutionLogsApi         = api.ApiGroupApp.SugarApiGroup.SugarExecutionLogsApi
+	sugarWorkspacesApi            = api.ApiGroupApp.SugarApiGroup.SugarWorkspacesApi
+	sugarFormulaQueryApi          = api.ApiGroupApp.SugarApiGroup.SugarFormulaQueryApi
+	sugarFoldersApi               = api.ApiGroupApp.SugarApiGroup.SugarFoldersApi
+	sugarApiTokensApi             = api.ApiGroupApp.SugarApiGroup.SugarApiTokensApi
+	sugarAnonymizationSessionsApi = api.ApiGroupApp.SugarApiGroup.SugarAnonymizationSessionsApi
+	sugarPrivacyBudgetApi         = api.ApiGroupApp.SugarApiGroup.SugarPrivacyBudgetApi
+	sugarFileVersionsApi          = api.ApiGroupApp.SugarApiGroup.SugarFileVersionsApi
+	sugarWorkbookCollaborationApi = api.ApiGroupApp.SugarApiGroup.SugarWorkbookCollaborationApi
+	sugarWorkspacePermissionsApi  = api.ApiGroupApp.SugarApiGroup.SugarWorkspacePermissionsApi
+	sugarShareLinksApi            = api.ApiGroupApp.SugarApiGroup.SugarShareLinksApi
+	sugarRecycleBinApi            = api.ApiGroupApp.SugarApiGroup.SugarRecycleBinApi
+	sugarWorkbookTemplatesApi     = api.ApiGroupApp.SugarApiGroup.SugarWorkbookTemplatesApi
+	sugarWorkbookSearchApi        = api.ApiGroupApp.SugarApiGroup.SugarWorkbookSearchApi
+	sugarWorkbookLineageApi       = api.ApiGroupApp.SugarApiGroup.SugarWorkbookLineageApi
 )
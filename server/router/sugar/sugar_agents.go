@@ -18,7 +18,8 @@ func (s *SugarAgentsRouter) InitSugarAgentsRouter(Router *gin.RouterGroup, Publi
 		sugarAgentsRouter.PUT("updateSugarAgents", sugarAgentsApi.UpdateSugarAgents)              // 更新sugar智能体表
 	}
 	{
-		sugarAgentsRouterWithoutRecord.GET("findSugarAgents", sugarAgentsApi.FindSugarAgents)       // 根据ID获取sugar智能体表
-		sugarAgentsRouterWithoutRecord.GET("getSugarAgentsList", sugarAgentsApi.GetSugarAgentsList) // 获取sugar智能体表列表
+		sugarAgentsRouterWithoutRecord.GET("findSugarAgents", sugarAgentsApi.FindSugarAgents)               // 根据ID获取sugar智能体表
+		sugarAgentsRouterWithoutRecord.GET("getSugarAgentsList", sugarAgentsApi.GetSugarAgentsList)         // 获取sugar智能体表列表
+		sugarAgentsRouterWithoutRecord.GET("getAvailableAgentTools", sugarAgentsApi.GetAvailableAgentTools) // 获取Agent可用工具列表
 	}
 }
@@ -0,0 +1,16 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarAnonymizationSessionsRouter struct{}
+
+// InitSugarAnonymizationSessionsRouter 初始化 Sugar 匿名化会话 路由信息
+func (s *SugarAnonymizationSessionsRouter) InitSugarAnonymizationSessionsRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarAnonymizationSessionsRouter := Router.Group("sugarAnonymizationSessions").Use(middleware.OperationRecord())
+	{
+		sugarAnonymizationSessionsRouter.POST("redecodeExecutionLog", sugarAnonymizationSessionsApi.RedecodeExecutionLog) // 重新解码执行日志
+	}
+}
@@ -0,0 +1,21 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarApiTokensRouter struct{}
+
+// InitSugarApiTokensRouter 初始化 Sugar API令牌 路由信息
+func (s *SugarApiTokensRouter) InitSugarApiTokensRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarApiTokensRouter := Router.Group("sugarApiTokens").Use(middleware.OperationRecord())
+	sugarApiTokensRouterWithoutRecord := Router.Group("sugarApiTokens")
+	{
+		sugarApiTokensRouter.POST("createApiToken", sugarApiTokensApi.CreateApiToken)   // 创建API令牌
+		sugarApiTokensRouter.DELETE("revokeApiToken", sugarApiTokensApi.RevokeApiToken) // 吊销API令牌
+	}
+	{
+		sugarApiTokensRouterWithoutRecord.GET("getApiTokenList", sugarApiTokensApi.GetApiTokenList) // 获取API令牌列表
+	}
+}
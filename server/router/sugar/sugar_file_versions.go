@@ -0,0 +1,23 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarFileVersionsRouter struct{}
+
+// InitSugarFileVersionsRouter 初始化 Sugar 工作簿历史版本 路由信息
+func (s *SugarFileVersionsRouter) InitSugarFileVersionsRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarFileVersionsRouter := Router.Group("sugarFileVersions").Use(middleware.OperationRecord())
+	sugarFileVersionsRouterWithoutRecord := Router.Group("sugarFileVersions")
+	{
+		sugarFileVersionsRouter.POST("restoreFileVersion", sugarFileVersionsApi.RestoreFileVersion) // 恢复历史版本
+		sugarFileVersionsRouter.PUT("updateFileVersion", sugarFileVersionsApi.UpdateFileVersion)    // 命名或置顶历史版本
+	}
+	{
+		sugarFileVersionsRouterWithoutRecord.GET("getFileVersionList", sugarFileVersionsApi.GetFileVersionList) // 分页获取历史版本
+		sugarFileVersionsRouterWithoutRecord.GET("findFileVersion", sugarFileVersionsApi.FindFileVersion)       // 获取历史版本详情
+		sugarFileVersionsRouterWithoutRecord.GET("diffFileVersions", sugarFileVersionsApi.DiffFileVersions)     // 对比两个历史版本
+	}
+}
@@ -16,6 +16,7 @@ func (s *SugarFoldersRouter) InitSugarFoldersRouter(Router *gin.RouterGroup, Pub
 		sugarFoldersRouter.POST("createFolder", sugarFoldersApi.CreateFolder)        // 创建文件夹
 		sugarFoldersRouter.PUT("rename", sugarFoldersApi.RenameItem)                 // 重命名文件夹或文件
 		sugarFoldersRouter.PUT("move", sugarFoldersApi.MoveItem)                     // 移动文件夹或文件
+		sugarFoldersRouter.POST("copy", sugarFoldersApi.CopyItem)                    // 复制文件夹或文件
 		sugarFoldersRouter.DELETE("deleteItem", sugarFoldersApi.DeleteItem)          // 删除文件夹或文件
 		sugarFoldersRouter.GET("getFolderContent", sugarFoldersApi.GetFolderContent) // 获取文件夹内容
 	}
@@ -0,0 +1,15 @@
+package sugar
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type SugarPrivacyBudgetRouter struct{}
+
+// InitSugarPrivacyBudgetRouter 初始化 Sugar 隐私预算 路由信息
+func (s *SugarPrivacyBudgetRouter) InitSugarPrivacyBudgetRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarPrivacyBudgetRouterWithoutRecord := Router.Group("sugarPrivacyBudget")
+	{
+		sugarPrivacyBudgetRouterWithoutRecord.GET("getPrivacyBudgetList", sugarPrivacyBudgetApi.GetPrivacyBudgetList) // 分页获取隐私预算账本
+	}
+}
@@ -0,0 +1,21 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarRecycleBinRouter struct{}
+
+// InitSugarRecycleBinRouter 初始化 Sugar 回收站 路由信息
+func (s *SugarRecycleBinRouter) InitSugarRecycleBinRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarRecycleBinRouter := Router.Group("sugarRecycleBin").Use(middleware.OperationRecord())
+	sugarRecycleBinRouterWithoutRecord := Router.Group("sugarRecycleBin")
+	{
+		sugarRecycleBinRouter.POST("restoreItem", sugarRecycleBinApi.RestoreItem) // 从回收站恢复
+		sugarRecycleBinRouter.DELETE("purgeItem", sugarRecycleBinApi.PurgeItem)   // 彻底删除
+	}
+	{
+		sugarRecycleBinRouterWithoutRecord.GET("getRecycleBinList", sugarRecycleBinApi.GetRecycleBinList) // 获取回收站列表
+	}
+}
@@ -0,0 +1,26 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarShareLinksRouter struct{}
+
+// InitSugarShareLinksRouter 初始化 Sugar 分享链接 路由信息
+func (s *SugarShareLinksRouter) InitSugarShareLinksRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarShareLinksRouter := Router.Group("sugarShareLinks").Use(middleware.OperationRecord())
+	sugarShareLinksRouterWithoutRecord := Router.Group("sugarShareLinks")
+	sugarShareLinksRouterWithoutAuth := PublicRouter.Group("sugarShareLinks").Use(middleware.DefaultLimit())
+	{
+		sugarShareLinksRouter.POST("createShareLink", sugarShareLinksApi.CreateShareLink)   // 创建分享链接
+		sugarShareLinksRouter.DELETE("revokeShareLink", sugarShareLinksApi.RevokeShareLink) // 关闭分享链接
+	}
+	{
+		sugarShareLinksRouterWithoutRecord.GET("getShareLinkList", sugarShareLinksApi.GetShareLinkList) // 获取分享链接列表
+	}
+	{
+		sugarShareLinksRouterWithoutAuth.POST("openSharedWorkbook", sugarShareLinksApi.OpenSharedWorkbook) // 通过分享链接打开工作簿
+		sugarShareLinksRouterWithoutAuth.PUT("saveSharedWorkbook", sugarShareLinksApi.SaveSharedWorkbook)  // 通过分享链接保存工作簿
+	}
+}
@@ -21,6 +21,7 @@ func (s *SugarTeamsRouter) InitSugarTeamsRouter(Router *gin.RouterGroup,PublicRo
 	{
 		sugarTeamsRouterWithoutRecord.GET("findSugarTeams", sugarTeamsApi.FindSugarTeams)        // 根据ID获取团队信息表
 		sugarTeamsRouterWithoutRecord.GET("getSugarTeamsList", sugarTeamsApi.GetSugarTeamsList)  // 获取团队信息表列表
+		sugarTeamsRouterWithoutRecord.GET("getMyTeamPermissions", sugarTeamsApi.GetMyTeamPermissions)  // 获取当前用户在团队中的权限
 	}
 	{
 	    sugarTeamsRouterWithoutAuth.GET("getSugarTeamsPublic", sugarTeamsApi.GetSugarTeamsPublic)  // 团队信息表开放接口
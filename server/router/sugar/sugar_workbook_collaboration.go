@@ -0,0 +1,18 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarWorkbookCollaborationRouter struct{}
+
+// InitSugarWorkbookCollaborationRouter 初始化 Sugar 工作簿实时协作 路由信息
+// WebSocket 握手无法携带 x-token 请求头，注册在公共路由组上，由 WebSocketToken 从查询参数取令牌后经 JWTAuth 鉴权、CasbinHandler 校验接口权限，
+// 文件的访问权限在建立连接前由服务层校验
+func (s *SugarWorkbookCollaborationRouter) InitSugarWorkbookCollaborationRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarWorkbookCollaborationRouter := PublicRouter.Group("sugarWorkbookCollaboration").Use(middleware.WebSocketToken(), middleware.JWTAuth(), middleware.CasbinHandler())
+	{
+		sugarWorkbookCollaborationRouter.GET("connect", sugarWorkbookCollaborationApi.Connect) // 建立工作簿实时协作连接
+	}
+}
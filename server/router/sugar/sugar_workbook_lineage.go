@@ -0,0 +1,18 @@
+package sugar
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type SugarWorkbookLineageRouter struct{}
+
+// InitSugarWorkbookLineageRouter 初始化 Sugar 工作簿血缘关系 路由信息
+func (s *SugarWorkbookLineageRouter) InitSugarWorkbookLineageRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarWorkbookLineageRouterWithoutRecord := Router.Group("sugarWorkbookLineage")
+	{
+		sugarWorkbookLineageRouterWithoutRecord.GET("getModelImpact", sugarWorkbookLineageApi.GetModelImpact)                   // 语义模型影响分析
+		sugarWorkbookLineageRouterWithoutRecord.GET("getConnectionImpact", sugarWorkbookLineageApi.GetConnectionImpact)         // 数据库连接影响分析
+		sugarWorkbookLineageRouterWithoutRecord.GET("getAgentImpact", sugarWorkbookLineageApi.GetAgentImpact)                   // 智能体影响分析
+		sugarWorkbookLineageRouterWithoutRecord.GET("getWorkbookDependencies", sugarWorkbookLineageApi.GetWorkbookDependencies) // 查询工作簿依赖
+	}
+}
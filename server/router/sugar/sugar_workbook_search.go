@@ -0,0 +1,20 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarWorkbookSearchRouter struct{}
+
+// InitSugarWorkbookSearchRouter 初始化 Sugar 工作簿搜索 路由信息
+func (s *SugarWorkbookSearchRouter) InitSugarWorkbookSearchRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarWorkbookSearchRouter := Router.Group("sugarWorkbookSearch").Use(middleware.OperationRecord())
+	sugarWorkbookSearchRouterWithoutRecord := Router.Group("sugarWorkbookSearch")
+	{
+		sugarWorkbookSearchRouter.POST("rebuildSearchIndex", sugarWorkbookSearchApi.RebuildSearchIndex) // 重建团队搜索索引
+	}
+	{
+		sugarWorkbookSearchRouterWithoutRecord.GET("searchWorkbooks", sugarWorkbookSearchApi.SearchWorkbooks) // 搜索工作簿
+	}
+}
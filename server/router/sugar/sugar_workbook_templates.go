@@ -0,0 +1,22 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarWorkbookTemplatesRouter struct{}
+
+// InitSugarWorkbookTemplatesRouter 初始化 Sugar 工作簿模板 路由信息
+func (s *SugarWorkbookTemplatesRouter) InitSugarWorkbookTemplatesRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarWorkbookTemplatesRouter := Router.Group("sugarWorkbookTemplates").Use(middleware.OperationRecord())
+	sugarWorkbookTemplatesRouterWithoutRecord := Router.Group("sugarWorkbookTemplates")
+	{
+		sugarWorkbookTemplatesRouter.POST("createTemplate", sugarWorkbookTemplatesApi.CreateTemplate)           // 由工作簿生成模板
+		sugarWorkbookTemplatesRouter.DELETE("deleteTemplate", sugarWorkbookTemplatesApi.DeleteTemplate)         // 删除模板
+		sugarWorkbookTemplatesRouter.POST("instantiateTemplate", sugarWorkbookTemplatesApi.InstantiateTemplate) // 由模板新建工作簿
+	}
+	{
+		sugarWorkbookTemplatesRouterWithoutRecord.GET("getTemplateList", sugarWorkbookTemplatesApi.GetTemplateList) // 获取团队模板库
+	}
+}
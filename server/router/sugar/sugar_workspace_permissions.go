@@ -0,0 +1,22 @@
+package sugar
+
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SugarWorkspacePermissionsRouter struct{}
+
+// InitSugarWorkspacePermissionsRouter 初始化 Sugar 文件权限 路由信息
+func (s *SugarWorkspacePermissionsRouter) InitSugarWorkspacePermissionsRouter(Router *gin.RouterGroup, PublicRouter *gin.RouterGroup) {
+	sugarWorkspacePermissionsRouter := Router.Group("sugarWorkspacePermissions").Use(middleware.OperationRecord())
+	sugarWorkspacePermissionsRouterWithoutRecord := Router.Group("sugarWorkspacePermissions")
+	{
+		sugarWorkspacePermissionsRouter.POST("grantWorkspacePermission", sugarWorkspacePermissionsApi.GrantWorkspacePermission)     // 授予文件权限
+		sugarWorkspacePermissionsRouter.DELETE("revokeWorkspacePermission", sugarWorkspacePermissionsApi.RevokeWorkspacePermission) // 撤销文件权限
+	}
+	{
+		sugarWorkspacePermissionsRouterWithoutRecord.GET("getWorkspacePermissionList", sugarWorkspacePermissionsApi.GetWorkspacePermissionList) // 获取授权列表
+		sugarWorkspacePermissionsRouterWithoutRecord.GET("getWorkspaceAccess", sugarWorkspacePermissionsApi.GetWorkspaceAccess)                 // 获取有效访问权限
+	}
+}
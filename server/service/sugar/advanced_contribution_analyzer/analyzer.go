@@ -382,7 +382,7 @@ func (aca *AdvancedContributionAnalyzer) extractTopCombinations(level *Dimension
 	// 复制并排序，避免修改原始数据
 	combinations := make([]*DimensionCombination, len(level.Combinations))
 	copy(combinations, level.Combinations)
-	sort.Slice(combinations, func(i, j int) bool {
+	sort.SliceStable(combinations, func(i, j int) bool {
 		return math.Abs(combinations[i].Contribution) > math.Abs(combinations[j].Contribution)
 	})
 
@@ -623,16 +623,21 @@ func (aca *AdvancedContributionAnalyzer) aggregateToLevel(detailCombinations []*
 		}
 	}
 
-	// 将聚合结果转换为切片
+	// 将聚合结果按键排序后转换为切片，保证贡献度相同的组合顺序稳定
+	keys := make([]string, 0, len(aggregationMap))
+	for key := range aggregationMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	var result []*DimensionCombination
-	for _, combo := range aggregationMap {
-		result = append(result, combo)
+	for _, key := range keys {
+		result = append(result, aggregationMap[key])
 	}
 
 	log.Printf("聚合完成，生成了%d个%d维度的组合", len(result), targetLevel)
 
 	// 按贡献度绝对值排序
-	sort.Slice(result, func(i, j int) bool {
+	sort.SliceStable(result, func(i, j int) bool {
 		return math.Abs(result[i].Contribution) > math.Abs(result[j].Contribution)
 	})
 
@@ -35,6 +35,12 @@ func NewDataOptimizer(config *AnalysisConfig) *DataOptimizer {
 	return optimizer
 }
 
+// BalanceComparison 期初/期末对比口径，由语义模型的分析口径声明
+type BalanceComparison struct {
+	OpeningMetric string `json:"opening_metric"` // 期初指标，如"年初金额"
+	ClosingMetric string `json:"closing_metric"` // 期末指标，如"年末金额"
+}
+
 // OptimizedPromptRequest 优化后的提示词请求
 type OptimizedPromptRequest struct {
 	ModelName            string                 `json:"model_name"`
@@ -42,7 +48,7 @@ type OptimizedPromptRequest struct {
 	Metric               string                 `json:"metric"`
 	CurrentPeriodFilters map[string]interface{} `json:"current_period_filters"`
 	BasePeriodFilters    map[string]interface{} `json:"base_period_filters"`
-	IsYearEndComparison  bool                   `json:"is_year_end_comparison"`
+	BalanceComparison    *BalanceComparison     `json:"balance_comparison,omitempty"`
 	OptimizedPrompt      string                 `json:"optimized_prompt"`
 	DataUnificationHint  string                 `json:"data_unification_hint"`
 }
@@ -53,7 +59,7 @@ func (do *DataOptimizer) GenerateOptimizedPrompt(
 	dimensions []string,
 	metric string,
 	currentPeriodFilters, basePeriodFilters map[string]interface{},
-	isYearEndComparison bool,
+	balanceComparison *BalanceComparison,
 ) *OptimizedPromptRequest {
 
 	request := &OptimizedPromptRequest{
@@ -62,11 +68,11 @@ func (do *DataOptimizer) GenerateOptimizedPrompt(
 		Metric:               metric,
 		CurrentPeriodFilters: currentPeriodFilters,
 		BasePeriodFilters:    basePeriodFilters,
-		IsYearEndComparison:  isYearEndComparison,
+		BalanceComparison:    balanceComparison,
 	}
 
 	// 生成数据统一提示
-	request.DataUnificationHint = do.generateDataUnificationHint(isYearEndComparison, metric)
+	request.DataUnificationHint = do.generateDataUnificationHint(balanceComparison, metric)
 
 	// 生成优化的提示词
 	request.OptimizedPrompt = do.buildOptimizedPrompt(request)
@@ -75,15 +81,15 @@ func (do *DataOptimizer) GenerateOptimizedPrompt(
 }
 
 // generateDataUnificationHint 生成数据统一提示
-func (do *DataOptimizer) generateDataUnificationHint(isYearEndComparison bool, metric string) string {
-	if isYearEndComparison {
+func (do *DataOptimizer) generateDataUnificationHint(balanceComparison *BalanceComparison, metric string) string {
+	if balanceComparison != nil {
 		return fmt.Sprintf(`
 数据统一处理说明：
-1. 对于年初年末对比类型的数据，请直接计算变化值：%s_变化值 = 年末金额 - 年初金额
+1. 对于期初期末对比类型的数据，请直接计算变化值：%s_变化值 = %s - %s
 2. 无需区分本期和基期，直接使用计算后的变化值进行分析
 3. 确保所有维度组合都基于相同的变化值计算基础
 4. 变化值可能为正（增长）或负（减少），请保持原始符号
-`, metric)
+`, metric, balanceComparison.ClosingMetric, balanceComparison.OpeningMetric)
 	}
 
 	return fmt.Sprintf(`
@@ -137,10 +143,10 @@ func (do *DataOptimizer) buildOptimizedPrompt(request *OptimizedPromptRequest) s
 	prompt.WriteString("4. 提供足够的维度组合以支持多层级分析\n")
 
 	// 特殊处理说明
-	if request.IsYearEndComparison {
-		prompt.WriteString("\n## 年初年末对比特殊说明\n")
-		prompt.WriteString("- 直接使用表中的年初金额和年末金额字段\n")
-		prompt.WriteString("- 无需进行时间筛选，所有记录都包含完整的年初年末信息\n")
+	if request.BalanceComparison != nil {
+		prompt.WriteString("\n## 期初期末对比特殊说明\n")
+		prompt.WriteString(fmt.Sprintf("- 直接使用表中的%s和%s字段\n", request.BalanceComparison.OpeningMetric, request.BalanceComparison.ClosingMetric))
+		prompt.WriteString("- 无需进行时间筛选，所有记录都包含完整的期初期末信息\n")
 		prompt.WriteString("- 重点关注变化幅度较大的维度组合\n")
 	}
 
@@ -369,34 +375,11 @@ func (do *DataOptimizer) calculateContributionStats(contributions []float64, zer
 	}
 }
 
-// detectOutliers 使用IQR方法检测异常值
+// detectOutliers 使用稳健 z 分数（中位数/MAD）检测异常值
 func (do *DataOptimizer) detectOutliers(values []float64) []float64 {
-	if len(values) < 4 {
-		return nil
-	}
-
-	// 简化的异常值检测：使用均值±2倍标准差
-	sum := float64(0)
-	for _, v := range values {
-		sum += v
-	}
-	mean := sum / float64(len(values))
-
-	variance := float64(0)
-	for _, v := range values {
-		variance += (v - mean) * (v - mean)
-	}
-	variance /= float64(len(values))
-	stdDev := variance // 简化处理
-
 	var outliers []float64
-	threshold := 2 * stdDev
-
-	for _, v := range values {
-		if v < mean-threshold || v > mean+threshold {
-			outliers = append(outliers, v)
-		}
+	for _, index := range DetectOutlierIndexes(values, DefaultRobustZThreshold) {
+		outliers = append(outliers, values[index])
 	}
-
 	return outliers
 }
@@ -0,0 +1,329 @@
+package advanced_contribution_analyzer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 变化分解方法
+const (
+	// DecompositionAdditive 加法分解：各组合的变化值 / 总变化值，适用于可累加指标
+	DecompositionAdditive = "additive"
+	// DecompositionLMDI 对数平均迪氏指数法：目标指标 = 各因素之积，按对数平均权重分配各因素的效应
+	DecompositionLMDI = "lmdi"
+	// DecompositionShapley Shapley 值分解：目标指标 = 各因素之积，按各因素在所有替换顺序下的平均边际贡献分配效应
+	// 参与者为因素链中的因素而非维度，各组合之间仍按加法汇总
+	DecompositionShapley = "shapley"
+	// DecompositionMixRate 结构/比率分解：目标指标 = Σ分子 / Σ分母，拆分为结构（分母占比）变化和比率变化
+	DecompositionMixRate = "mix_rate"
+)
+
+// 分解效应名称
+const (
+	EffectMix       = "结构效应" // 分母占比变化带来的影响
+	EffectRate      = "比率效应" // 组合自身比率变化带来的影响
+	EffectEntryExit = "进出效应" // 某一期因素缺失（为0、为负或无法组成目标值）的组合，整体变化单独列示
+)
+
+// maxShapleyFactors Shapley 分解需要枚举 2^n 个因素子集，限制因素数量
+const maxShapleyFactors = 8
+
+// factorTolerance 判断因素之积与目标值是否一致的相对误差
+const factorTolerance = 1e-9
+
+// IsValidDecompositionMethod 是否为支持的分解方法（空字符串视为加法分解）
+func IsValidDecompositionMethod(method string) bool {
+	switch method {
+	case "", DecompositionAdditive, DecompositionLMDI, DecompositionShapley, DecompositionMixRate:
+		return true
+	}
+	return false
+}
+
+// ValidateDecomposition 校验分解方法及其所需的指标配置
+func (c *AnalysisConfig) ValidateDecomposition() error {
+	if !IsValidDecompositionMethod(c.DecompositionMethod) {
+		return fmt.Errorf("不支持的分解方法: %s，可选 additive/lmdi/shapley/mix_rate", c.DecompositionMethod)
+	}
+	switch c.DecompositionMethod {
+	case DecompositionLMDI, DecompositionShapley:
+		if len(c.FactorMetrics) == 0 {
+			return fmt.Errorf("%s 分解需要配置因素指标 factor_metrics", c.DecompositionMethod)
+		}
+		if len(c.FactorMetrics)+1 > maxShapleyFactors {
+			return fmt.Errorf("因素指标最多 %d 个", maxShapleyFactors-1)
+		}
+		seen := make(map[string]bool)
+		for _, metric := range c.FactorMetrics {
+			if metric == "" || seen[metric] {
+				return fmt.Errorf("因素指标不能为空或重复: %q", metric)
+			}
+			seen[metric] = true
+		}
+	case DecompositionMixRate:
+		if c.RatioNumerator == "" || c.RatioDenominator == "" {
+			return errors.New("mix_rate 分解需要同时配置分子指标 ratio_numerator 和分母指标 ratio_denominator")
+		}
+		if c.RatioNumerator == c.RatioDenominator {
+			return errors.New("mix_rate 分解的分子指标和分母指标不能相同")
+		}
+	}
+	return nil
+}
+
+// FactorObservation 单个组合在基期和本期的目标值及各因素取值，正常情况下目标值 = 各因素之积
+type FactorObservation struct {
+	Key          string    `json:"key"`
+	BaseValue    float64   `json:"base_value"`
+	CurrentValue float64   `json:"current_value"`
+	BaseFactors  []float64 `json:"base_factors"`
+	CurFactors   []float64 `json:"current_factors"`
+}
+
+// RatioObservation 单个组合在基期和本期的分子、分母
+type RatioObservation struct {
+	Key                string  `json:"key"`
+	BaseNumerator      float64 `json:"base_numerator"`
+	BaseDenominator    float64 `json:"base_denominator"`
+	CurrentNumerator   float64 `json:"current_numerator"`
+	CurrentDenominator float64 `json:"current_denominator"`
+}
+
+// GroupDecomposition 单个组合的分解结果，Effects 与 DecompositionResult.EffectNames 一一对应，合计等于 Change
+// mix_rate 中 BaseValue、CurrentValue 为组合自身的比率，Change 为该组合对整体比率变化的贡献，不等于两者之差
+type GroupDecomposition struct {
+	Key          string    `json:"key"`
+	BaseValue    float64   `json:"base_value"`
+	CurrentValue float64   `json:"current_value"`
+	Change       float64   `json:"change"`
+	Effects      []float64 `json:"effects"`
+}
+
+// DecompositionResult 变化分解结果，各效应合计等于 TotalChange
+type DecompositionResult struct {
+	Method       string               `json:"method"`
+	EffectNames  []string             `json:"effect_names"`
+	Effects      []float64            `json:"effects"`
+	BaseValue    float64              `json:"base_value"`
+	CurrentValue float64              `json:"current_value"`
+	TotalChange  float64              `json:"total_change"`
+	Groups       []GroupDecomposition `json:"groups"`
+}
+
+// Residual 各效应合计与总变化值之差，用于校验分解的完整性
+func (r *DecompositionResult) Residual() float64 {
+	var sum float64
+	for _, effect := range r.Effects {
+		sum += effect
+	}
+	return sum - r.TotalChange
+}
+
+// LogarithmicMean 对数平均 L(a, b) = (a - b) / (ln a - ln b)，a = b 时为 a；要求 a、b 均为正数
+func LogarithmicMean(a, b float64) float64 {
+	if a == b {
+		return a
+	}
+	return (a - b) / (math.Log(a) - math.Log(b))
+}
+
+// DecomposeMultiplicative 对乘法结构的指标做 LMDI 或 Shapley 分解
+// 因素缺失（LMDI 中因素非正，或因素之积与目标值不一致）的组合，其整体变化计入进出效应，保证各效应合计等于总变化值
+func DecomposeMultiplicative(method string, factorNames []string, observations []FactorObservation) (*DecompositionResult, error) {
+	if method != DecompositionLMDI && method != DecompositionShapley {
+		return nil, fmt.Errorf("乘法分解只支持 lmdi 和 shapley: %s", method)
+	}
+	factorCount := len(factorNames)
+	if factorCount == 0 {
+		return nil, errors.New("乘法分解至少需要一个因素")
+	}
+	if method == DecompositionShapley && factorCount > maxShapleyFactors {
+		return nil, fmt.Errorf("Shapley 分解的因素最多 %d 个", maxShapleyFactors)
+	}
+
+	result := &DecompositionResult{
+		Method:      method,
+		EffectNames: append([]string{}, factorNames...),
+		Effects:     make([]float64, factorCount+1),
+	}
+	hasEntryExit := false
+	for _, observation := range observations {
+		if len(observation.BaseFactors) != factorCount || len(observation.CurFactors) != factorCount {
+			return nil, fmt.Errorf("组合 %s 的因素数量与因素名称不一致", observation.Key)
+		}
+		group := GroupDecomposition{
+			Key:          observation.Key,
+			BaseValue:    observation.BaseValue,
+			CurrentValue: observation.CurrentValue,
+			Change:       observation.CurrentValue - observation.BaseValue,
+			Effects:      make([]float64, factorCount+1),
+		}
+		switch {
+		case !factorsComposeValue(observation.BaseFactors, observation.BaseValue) || !factorsComposeValue(observation.CurFactors, observation.CurrentValue):
+			group.Effects[factorCount] = group.Change
+		case method == DecompositionLMDI && !allPositive(observation.BaseFactors, observation.CurFactors):
+			group.Effects[factorCount] = group.Change
+		case method == DecompositionLMDI:
+			weight := LogarithmicMean(observation.CurrentValue, observation.BaseValue)
+			for k := 0; k < factorCount; k++ {
+				group.Effects[k] = weight * math.Log(observation.CurFactors[k]/observation.BaseFactors[k])
+			}
+		default:
+			copy(group.Effects, shapleyEffects(observation.BaseFactors, observation.CurFactors))
+		}
+		if group.Effects[factorCount] != 0 {
+			hasEntryExit = true
+		}
+
+		result.BaseValue += group.BaseValue
+		result.CurrentValue += group.CurrentValue
+		for k, effect := range group.Effects {
+			result.Effects[k] += effect
+		}
+		result.Groups = append(result.Groups, group)
+	}
+	result.TotalChange = result.CurrentValue - result.BaseValue
+
+	if hasEntryExit {
+		result.EffectNames = append(result.EffectNames, EffectEntryExit)
+	} else {
+		result.Effects = result.Effects[:factorCount]
+		for i := range result.Groups {
+			result.Groups[i].Effects = result.Groups[i].Effects[:factorCount]
+		}
+	}
+	return result, nil
+}
+
+// factorsComposeValue 因素之积是否等于目标值
+func factorsComposeValue(factors []float64, value float64) bool {
+	product := 1.0
+	for _, factor := range factors {
+		product *= factor
+	}
+	return math.Abs(product-value) <= factorTolerance*math.Max(1, math.Abs(value))
+}
+
+func allPositive(groups ...[]float64) bool {
+	for _, values := range groups {
+		for _, value := range values {
+			if value <= 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shapleyEffects 以"因素取本期值"为参与者计算 Shapley 值：
+// φk = Σ_{S⊆N\{k}} |S|!(n-|S|-1)!/n! · [f(S∪{k}) - f(S)]，f(S) 为 S 中因素取本期值、其余取基期值时的乘积
+func shapleyEffects(base, current []float64) []float64 {
+	n := len(base)
+	product := func(mask int) float64 {
+		value := 1.0
+		for k := 0; k < n; k++ {
+			if mask&(1<<k) != 0 {
+				value *= current[k]
+			} else {
+				value *= base[k]
+			}
+		}
+		return value
+	}
+	factorial := make([]float64, n+1)
+	factorial[0] = 1
+	for i := 1; i <= n; i++ {
+		factorial[i] = factorial[i-1] * float64(i)
+	}
+
+	effects := make([]float64, n)
+	for mask := 0; mask < 1<<n; mask++ {
+		size := 0
+		for k := 0; k < n; k++ {
+			if mask&(1<<k) != 0 {
+				size++
+			}
+		}
+		for k := 0; k < n; k++ {
+			if mask&(1<<k) != 0 {
+				continue
+			}
+			weight := factorial[size] * factorial[n-size-1] / factorial[n]
+			effects[k] += weight * (product(mask|1<<k) - product(mask))
+		}
+	}
+	return effects
+}
+
+// DecomposeMixRate 对比率指标 R = Σ分子 / Σ分母 做结构/比率分解
+// 组合 i 的分母占比 w、比率 r 取两期平均值 w̄、r̄，整体比率取两期平均值 R̄：
+//
+//	结构效应 = (r̄ - R̄)·Δw   占比向高于平均比率的组合转移时为正
+//	比率效应 = Δ(分子/Σ分母) - r̄·Δw   分母两期均不为0时等于 w̄·Δr
+//
+// 由于 ΣΔw = 0，各组合两项效应之和的合计恰好等于整体比率的变化
+func DecomposeMixRate(observations []RatioObservation) (*DecompositionResult, error) {
+	var baseNumerator, baseDenominator, currentNumerator, currentDenominator float64
+	for _, observation := range observations {
+		baseNumerator += observation.BaseNumerator
+		baseDenominator += observation.BaseDenominator
+		currentNumerator += observation.CurrentNumerator
+		currentDenominator += observation.CurrentDenominator
+	}
+	if baseDenominator == 0 || currentDenominator == 0 {
+		return nil, errors.New("基期或本期的分母合计为0，无法计算比率")
+	}
+
+	result := &DecompositionResult{
+		Method:       DecompositionMixRate,
+		EffectNames:  []string{EffectMix, EffectRate},
+		Effects:      make([]float64, 2),
+		BaseValue:    baseNumerator / baseDenominator,
+		CurrentValue: currentNumerator / currentDenominator,
+	}
+	result.TotalChange = result.CurrentValue - result.BaseValue
+	averageRatio := (result.BaseValue + result.CurrentValue) / 2
+
+	for _, observation := range observations {
+		baseShare := observation.BaseDenominator / baseDenominator
+		currentShare := observation.CurrentDenominator / currentDenominator
+		baseRatio, currentRatio := groupRatios(observation)
+
+		shareChange := currentShare - baseShare
+		averageGroupRatio := (baseRatio + currentRatio) / 2
+		termChange := observation.CurrentNumerator/currentDenominator - observation.BaseNumerator/baseDenominator
+
+		mix := (averageGroupRatio - averageRatio) * shareChange
+		rate := termChange - averageGroupRatio*shareChange
+		group := GroupDecomposition{
+			Key:          observation.Key,
+			BaseValue:    baseRatio,
+			CurrentValue: currentRatio,
+			Change:       mix + rate,
+			Effects:      []float64{mix, rate},
+		}
+		result.Effects[0] += mix
+		result.Effects[1] += rate
+		result.Groups = append(result.Groups, group)
+	}
+	return result, nil
+}
+
+// groupRatios 组合两期的比率；某一期分母为0时该期比率取另一期的值，使其只产生结构效应
+func groupRatios(observation RatioObservation) (float64, float64) {
+	var baseRatio, currentRatio float64
+	if observation.BaseDenominator != 0 {
+		baseRatio = observation.BaseNumerator / observation.BaseDenominator
+	}
+	if observation.CurrentDenominator != 0 {
+		currentRatio = observation.CurrentNumerator / observation.CurrentDenominator
+	}
+	if observation.BaseDenominator == 0 {
+		baseRatio = currentRatio
+	}
+	if observation.CurrentDenominator == 0 {
+		currentRatio = baseRatio
+	}
+	return baseRatio, currentRatio
+}
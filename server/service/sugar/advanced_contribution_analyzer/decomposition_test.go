@@ -0,0 +1,268 @@
+package advanced_contribution_analyzer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const sumTolerance = 1e-9
+
+// assertSumsToTotal 校验各效应合计、各组合效应之和均与对应的变化值一致
+func assertSumsToTotal(t *testing.T, result *DecompositionResult) {
+	t.Helper()
+	if len(result.Effects) != len(result.EffectNames) {
+		t.Fatalf("效应数量 %d 与名称数量 %d 不一致", len(result.Effects), len(result.EffectNames))
+	}
+	if math.Abs(result.Residual()) > sumTolerance*math.Max(1, math.Abs(result.TotalChange)) {
+		t.Fatalf("各效应合计与总变化不一致: effects=%v total=%v", result.Effects, result.TotalChange)
+	}
+	var groupSum float64
+	for _, group := range result.Groups {
+		var effectSum float64
+		for _, effect := range group.Effects {
+			effectSum += effect
+		}
+		if math.Abs(effectSum-group.Change) > sumTolerance*math.Max(1, math.Abs(group.Change)) {
+			t.Fatalf("组合 %s 的效应合计 %v 与变化值 %v 不一致", group.Key, effectSum, group.Change)
+		}
+		groupSum += group.Change
+	}
+	if math.Abs(groupSum-result.TotalChange) > sumTolerance*math.Max(1, math.Abs(result.TotalChange)) {
+		t.Fatalf("各组合变化合计 %v 与总变化 %v 不一致", groupSum, result.TotalChange)
+	}
+}
+
+// priceVolume 收入 = 销量 × 单价
+func priceVolume(key string, baseVolume, basePrice, currentVolume, currentPrice float64) FactorObservation {
+	return FactorObservation{
+		Key:          key,
+		BaseValue:    baseVolume * basePrice,
+		CurrentValue: currentVolume * currentPrice,
+		BaseFactors:  []float64{baseVolume, basePrice},
+		CurFactors:   []float64{currentVolume, currentPrice},
+	}
+}
+
+func TestDecomposeLMDISumsToTotal(t *testing.T) {
+	observations := []FactorObservation{
+		priceVolume("北京", 100, 10, 120, 11),
+		priceVolume("上海", 80, 12, 70, 15),
+		priceVolume("深圳", 50, 8, 50, 8),
+	}
+	result, err := DecomposeMultiplicative(DecompositionLMDI, []string{"销量效应", "单价效应"}, observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, result)
+	if len(result.EffectNames) != 2 {
+		t.Fatalf("没有进出组合时不应出现进出效应: %v", result.EffectNames)
+	}
+
+	// 与前端 LMDI 公式一致：L(V1, V0) · ln(X1 / X0)
+	beijing := result.Groups[0]
+	expected := LogarithmicMean(1320, 1000) * math.Log(120.0/100.0)
+	if math.Abs(beijing.Effects[0]-expected) > 1e-9 {
+		t.Fatalf("北京销量效应 = %v，期望 %v", beijing.Effects[0], expected)
+	}
+	// 无变化的组合效应为0
+	if result.Groups[2].Effects[0] != 0 || result.Groups[2].Effects[1] != 0 {
+		t.Fatalf("无变化组合的效应应为0: %v", result.Groups[2].Effects)
+	}
+}
+
+func TestDecomposeLMDIEntryExit(t *testing.T) {
+	observations := []FactorObservation{
+		priceVolume("北京", 100, 10, 110, 10),
+		priceVolume("广州", 0, 0, 30, 9), // 新增组合
+		priceVolume("成都", 20, 5, 0, 0), // 退出组合
+		{Key: "杭州", BaseValue: 50, CurrentValue: 60, BaseFactors: []float64{0, 0}, CurFactors: []float64{6, 10}}, // 因素无法组成目标值
+	}
+	result, err := DecomposeMultiplicative(DecompositionLMDI, []string{"销量效应", "单价效应"}, observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, result)
+	if len(result.EffectNames) != 3 || result.EffectNames[2] != EffectEntryExit {
+		t.Fatalf("应追加进出效应: %v", result.EffectNames)
+	}
+	if result.Effects[2] != 270-100+10 {
+		t.Fatalf("进出效应 = %v，期望 %v", result.Effects[2], 270-100+10)
+	}
+}
+
+func TestDecomposeShapleySumsToTotal(t *testing.T) {
+	// 两因素时 Shapley 值等于各因素变化与另一因素两期均值之积
+	result, err := DecomposeMultiplicative(DecompositionShapley, []string{"销量效应", "单价效应"}, []FactorObservation{
+		priceVolume("北京", 100, 10, 120, 11),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, result)
+	if math.Abs(result.Effects[0]-20*10.5) > 1e-9 || math.Abs(result.Effects[1]-1*110) > 1e-9 {
+		t.Fatalf("两因素 Shapley 值错误: %v", result.Effects)
+	}
+
+	// 三因素，含0和负值，Shapley 不要求因素为正
+	observations := []FactorObservation{
+		{Key: "A", BaseValue: 2 * 3 * 4, CurrentValue: 3 * 3 * 5, BaseFactors: []float64{2, 3, 4}, CurFactors: []float64{3, 3, 5}},
+		{Key: "B", BaseValue: 0, CurrentValue: 2 * 5 * 7, BaseFactors: []float64{0, 5, 7}, CurFactors: []float64{2, 5, 7}},
+		{Key: "C", BaseValue: -1 * 4 * 2, CurrentValue: 1 * 4 * 3, BaseFactors: []float64{-1, 4, 2}, CurFactors: []float64{1, 4, 3}},
+	}
+	result, err = DecomposeMultiplicative(DecompositionShapley, []string{"门店数效应", "店均销量效应", "单价效应"}, observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, result)
+	if len(result.EffectNames) != 3 {
+		t.Fatalf("Shapley 分解不应产生进出效应: %v", result.EffectNames)
+	}
+	// 未变化的因素效应为0
+	if result.Groups[0].Effects[1] != 0 || result.Groups[1].Effects[1] != 0 {
+		t.Fatalf("未变化因素的效应应为0: %v %v", result.Groups[0].Effects, result.Groups[1].Effects)
+	}
+}
+
+func TestDecomposeMultiplicativeRandomSumsToTotal(t *testing.T) {
+	random := rand.New(rand.NewSource(20240201))
+	for _, method := range []string{DecompositionLMDI, DecompositionShapley} {
+		for round := 0; round < 50; round++ {
+			factorCount := 1 + random.Intn(4)
+			names := make([]string, factorCount)
+			for k := range names {
+				names[k] = string(rune('A' + k))
+			}
+			var observations []FactorObservation
+			for g := 0; g < 1+random.Intn(20); g++ {
+				observation := FactorObservation{Key: string(rune('a' + g)), BaseValue: 1, CurrentValue: 1}
+				for k := 0; k < factorCount; k++ {
+					base, current := random.Float64()*100, random.Float64()*100
+					if random.Intn(10) == 0 {
+						base = 0
+					}
+					observation.BaseFactors = append(observation.BaseFactors, base)
+					observation.CurFactors = append(observation.CurFactors, current)
+					observation.BaseValue *= base
+					observation.CurrentValue *= current
+				}
+				observations = append(observations, observation)
+			}
+			result, err := DecomposeMultiplicative(method, names, observations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSumsToTotal(t, result)
+		}
+	}
+}
+
+func TestDecomposeMixRateSumsToTotal(t *testing.T) {
+	// 毛利率 = 毛利 / 收入
+	observations := []RatioObservation{
+		{Key: "咖啡", BaseNumerator: 40, BaseDenominator: 100, CurrentNumerator: 60, CurrentDenominator: 150},
+		{Key: "茶饮", BaseNumerator: 10, BaseDenominator: 100, CurrentNumerator: 12, CurrentDenominator: 50},
+		{Key: "甜品", BaseNumerator: 0, BaseDenominator: 0, CurrentNumerator: 15, CurrentDenominator: 30}, // 新增品类
+		{Key: "简餐", BaseNumerator: 6, BaseDenominator: 20, CurrentNumerator: 0, CurrentDenominator: 0},  // 退出品类
+	}
+	result, err := DecomposeMixRate(observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, result)
+	if math.Abs(result.BaseValue-56.0/220) > 1e-12 || math.Abs(result.CurrentValue-87.0/230) > 1e-12 {
+		t.Fatalf("整体比率错误: base=%v current=%v", result.BaseValue, result.CurrentValue)
+	}
+	// 比率不变的组合只有结构效应
+	coffee := result.Groups[0]
+	if math.Abs(coffee.Effects[1]) > 1e-12 {
+		t.Fatalf("咖啡毛利率未变化，比率效应应为0: %v", coffee.Effects)
+	}
+	// 新增组合的比率效应为0，全部为结构效应
+	dessert := result.Groups[2]
+	if math.Abs(dessert.Effects[1]) > 1e-12 {
+		t.Fatalf("新增组合的比率效应应为0: %v", dessert.Effects)
+	}
+}
+
+func TestDecomposeMixRatePureEffects(t *testing.T) {
+	// 各组合比率不变、只有占比变化时，比率效应合计为0
+	mixOnly, err := DecomposeMixRate([]RatioObservation{
+		{Key: "高毛利", BaseNumerator: 50, BaseDenominator: 100, CurrentNumerator: 75, CurrentDenominator: 150},
+		{Key: "低毛利", BaseNumerator: 10, BaseDenominator: 100, CurrentNumerator: 5, CurrentDenominator: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, mixOnly)
+	if math.Abs(mixOnly.Effects[1]) > 1e-12 || mixOnly.Effects[0] <= 0 {
+		t.Fatalf("占比向高毛利转移应只有正的结构效应: %v", mixOnly.Effects)
+	}
+
+	// 占比不变、只有比率变化时，结构效应合计为0
+	rateOnly, err := DecomposeMixRate([]RatioObservation{
+		{Key: "高毛利", BaseNumerator: 50, BaseDenominator: 100, CurrentNumerator: 60, CurrentDenominator: 100},
+		{Key: "低毛利", BaseNumerator: 10, BaseDenominator: 100, CurrentNumerator: 10, CurrentDenominator: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSumsToTotal(t, rateOnly)
+	if math.Abs(rateOnly.Effects[0]) > 1e-12 || math.Abs(rateOnly.Effects[1]-0.05) > 1e-12 {
+		t.Fatalf("占比不变时应只有比率效应: %v", rateOnly.Effects)
+	}
+}
+
+func TestDecomposeMixRateRandomSumsToTotal(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for round := 0; round < 100; round++ {
+		var observations []RatioObservation
+		for g := 0; g < 1+random.Intn(15); g++ {
+			observation := RatioObservation{
+				Key:                string(rune('a' + g)),
+				BaseNumerator:      random.Float64()*200 - 50,
+				BaseDenominator:    random.Float64() * 100,
+				CurrentNumerator:   random.Float64()*200 - 50,
+				CurrentDenominator: random.Float64() * 100,
+			}
+			if random.Intn(5) == 0 {
+				observation.BaseDenominator = 0
+			}
+			observations = append(observations, observation)
+		}
+		result, err := DecomposeMixRate(observations)
+		if err != nil {
+			continue
+		}
+		assertSumsToTotal(t, result)
+	}
+}
+
+func TestDecomposeMixRateZeroDenominator(t *testing.T) {
+	if _, err := DecomposeMixRate([]RatioObservation{{Key: "a", CurrentNumerator: 1, CurrentDenominator: 1}}); err == nil {
+		t.Fatal("基期分母合计为0时应返回错误")
+	}
+}
+
+func TestValidateDecomposition(t *testing.T) {
+	cases := []struct {
+		name   string
+		config AnalysisConfig
+		valid  bool
+	}{
+		{"默认加法分解", AnalysisConfig{}, true},
+		{"未知方法", AnalysisConfig{DecompositionMethod: "ratio"}, false},
+		{"LMDI缺少因素", AnalysisConfig{DecompositionMethod: DecompositionLMDI}, false},
+		{"LMDI", AnalysisConfig{DecompositionMethod: DecompositionLMDI, FactorMetrics: []string{"销量"}}, true},
+		{"Shapley因素重复", AnalysisConfig{DecompositionMethod: DecompositionShapley, FactorMetrics: []string{"销量", "销量"}}, false},
+		{"结构比率缺少分母", AnalysisConfig{DecompositionMethod: DecompositionMixRate, RatioNumerator: "毛利"}, false},
+		{"结构比率", AnalysisConfig{DecompositionMethod: DecompositionMixRate, RatioNumerator: "毛利", RatioDenominator: "收入"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.config.ValidateDecomposition(); (err == nil) != tc.valid {
+				t.Fatalf("valid=%v err=%v", tc.valid, err)
+			}
+		})
+	}
+}
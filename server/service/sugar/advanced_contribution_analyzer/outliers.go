@@ -0,0 +1,88 @@
+package advanced_contribution_analyzer
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultRobustZThreshold 稳健 z 分数的默认异常阈值（Iglewicz-Hoaglin 建议值）
+const DefaultRobustZThreshold = 3.5
+
+// 正态分布下 MAD 与标准差、平均绝对偏差与标准差的换算系数
+const (
+	madScale          = 0.6745
+	meanAbsDevScale   = 0.7979
+	minOutlierSamples = 4
+	// MAD 不超过最大偏差的该比例时视为退化（浮点误差），避免分数被放大到无穷
+	degenerateScaleRatio = 1e-9
+)
+
+// Median 中位数，空切片返回0，不修改输入
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// MedianAbsoluteDeviation 中位数绝对偏差
+func MedianAbsoluteDeviation(values []float64) float64 {
+	median := Median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+	}
+	return Median(deviations)
+}
+
+// RobustScale 稳健的中心和尺度：中位数与按正态换算为标准差的 MAD
+// 超过一半的值相同（MAD 为0或仅为浮点误差）时尺度改用平均绝对偏差，全部相同时尺度为0
+func RobustScale(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	median := Median(values)
+	var sum, maxDeviation float64
+	for _, v := range values {
+		sum += math.Abs(v - median)
+		maxDeviation = math.Max(maxDeviation, math.Abs(v-median))
+	}
+	scale := MedianAbsoluteDeviation(values) / madScale
+	if scale <= degenerateScaleRatio*maxDeviation {
+		scale = sum / float64(len(values)) / meanAbsDevScale
+	}
+	return median, scale
+}
+
+// RobustZScores 基于中位数和 MAD 的稳健 z 分数，不受异常值本身拉偏，尺度为0时分数均为0
+func RobustZScores(values []float64) []float64 {
+	scores := make([]float64, len(values))
+	median, scale := RobustScale(values)
+	if scale == 0 {
+		return scores
+	}
+	for i, v := range values {
+		scores[i] = (v - median) / scale
+	}
+	return scores
+}
+
+// DetectOutlierIndexes 返回稳健 z 分数绝对值超过阈值的下标，样本少于4个时不做检测
+func DetectOutlierIndexes(values []float64, threshold float64) []int {
+	if len(values) < minOutlierSamples {
+		return nil
+	}
+	var indexes []int
+	for i, score := range RobustZScores(values) {
+		if math.Abs(score) > threshold {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
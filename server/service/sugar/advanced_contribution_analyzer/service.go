@@ -62,7 +62,7 @@ type AnalysisRequest struct {
 	Dimensions           []string                `json:"dimensions"`
 	CurrentPeriodFilters map[string]interface{}  `json:"current_period_filters"`
 	BasePeriodFilters    map[string]interface{}  `json:"base_period_filters"`
-	IsYearEndComparison  bool                    `json:"is_year_end_comparison"`
+	BalanceComparison    *BalanceComparison      `json:"balance_comparison,omitempty"`
 	RawContributions     []*DimensionCombination `json:"raw_contributions"`
 	TotalChange          float64                 `json:"total_change"`
 }
@@ -97,7 +97,7 @@ func (acs *AdvancedContributionService) PerformAdvancedAnalysis(ctx context.Cont
 		request.Metric,
 		request.CurrentPeriodFilters,
 		request.BasePeriodFilters,
-		request.IsYearEndComparison,
+		request.BalanceComparison,
 	)
 	response.OptimizedPrompt = optimizedPrompt
 
@@ -187,12 +187,12 @@ func (acs *AdvancedContributionService) GetOptimizedPromptForDataFetch(
 	dimensions []string,
 	metric string,
 	currentPeriodFilters, basePeriodFilters map[string]interface{},
-	isYearEndComparison bool,
+	balanceComparison *BalanceComparison,
 ) *OptimizedPromptRequest {
 	return acs.dataOptimizer.GenerateOptimizedPrompt(
 		modelName, dimensions, metric,
 		currentPeriodFilters, basePeriodFilters,
-		isYearEndComparison,
+		balanceComparison,
 	)
 }
 
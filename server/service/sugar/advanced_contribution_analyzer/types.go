@@ -184,6 +184,16 @@ type AnalysisConfig struct {
 
 	// 区分度改善阈值：如果新层级的区分度改善小于此值，则停止下钻
 	DiscriminationImprovementThreshold float64 `json:"discrimination_improvement_threshold"`
+
+	// 变化分解方法：additive（默认）/lmdi/shapley/mix_rate，见 decomposition.go
+	DecompositionMethod string `json:"decomposition_method"`
+
+	// 乘法分解（lmdi/shapley）的因素链：可累加的中间指标，如 ["销量"] 表示 目标指标 = 销量 × (目标指标/销量)
+	FactorMetrics []string `json:"factor_metrics,omitempty"`
+
+	// 结构/比率分解（mix_rate）的分子、分母指标：目标指标 = Σ分子 / Σ分母，如 毛利率 = 毛利 / 收入
+	RatioNumerator   string `json:"ratio_numerator,omitempty"`
+	RatioDenominator string `json:"ratio_denominator,omitempty"`
 }
 
 // DefaultAnalysisConfig 默认分析配置
@@ -191,13 +201,14 @@ func DefaultAnalysisConfig() *AnalysisConfig {
 	log.Printf("DefaultAnalysisConfig: 开始创建默认分析配置")
 
 	config := &AnalysisConfig{
-		DiscriminationThreshold:            15.0, // 区分度阈值15%
-		MinContributionThreshold:           1.0,  // 最小贡献度1%
-		MaxDrillDownLevels:                 4,    // 最多4层下钻
-		TopCombinationsCount:               15,   // 最多保留前15个组合
-		MinTopCombinations:                 1,    // 至少返回1个组合
-		EnableSmartStop:                    true, // 启用智能停止
-		DiscriminationImprovementThreshold: 5.0,  // 区分度改善阈值5%
+		DiscriminationThreshold:            15.0,                  // 区分度阈值15%
+		MinContributionThreshold:           1.0,                   // 最小贡献度1%
+		MaxDrillDownLevels:                 4,                     // 最多4层下钻
+		TopCombinationsCount:               15,                    // 最多保留前15个组合
+		MinTopCombinations:                 1,                     // 至少返回1个组合
+		EnableSmartStop:                    true,                  // 启用智能停止
+		DiscriminationImprovementThreshold: 5.0,                   // 区分度改善阈值5%
+		DecompositionMethod:                DecompositionAdditive, // 默认加法分解
 	}
 
 	log.Printf("DefaultAnalysisConfig: 默认配置创建成功 - DiscriminationThreshold=%.2f, MaxDrillDownLevels=%d, EnableSmartStop=%t",
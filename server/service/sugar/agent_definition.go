@@ -0,0 +1,430 @@
+package sugar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	systemModel "github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"go.uber.org/zap"
+)
+
+// 模型调用参数的取值范围
+const (
+	agentMaxTemperature = 2.0
+	agentMaxTokensLimit = 128000
+)
+
+// defaultAgentTools 未声明工具列表的Agent默认可用的工具
+var defaultAgentTools = []string{"smart_anonymized_analyzer"}
+
+// AgentModelParams Agent声明的模型调用参数
+type AgentModelParams struct {
+	Temperature *float64 `json:"temperature,omitempty"` // 采样温度，0-2
+	MaxTokens   int      `json:"maxTokens,omitempty"`   // 最大输出Token数
+}
+
+// AgentDefinition Agent的声明式定义，由 SugarAgents 中的各配置字段解析而来
+type AgentDefinition struct {
+	Tools                []string         // 允许调用的工具
+	AllowedModels        []string         // 允许访问的语义模型，为空表示不限制
+	ModelParams          AgentModelParams // 模型调用参数
+	SystemPromptTemplate string           // 工具调用阶段的系统提示词模板
+	PromptTemplate       string           // 数据分析阶段的提示词模板（即 Prompt 字段）
+	allowedModelSet      map[string]bool
+	allowedToolSet       map[string]bool
+}
+
+// AgentPromptUser 提示词模板中可用的用户信息
+// 不包含用户ID：工具鉴权只使用请求上下文中的身份，提示词中的ID只会诱导模型回传
+type AgentPromptUser struct {
+	Name     string
+	NickName string
+}
+
+// AgentPromptTeam 提示词模板中可用的团队信息
+type AgentPromptTeam struct {
+	Id   string
+	Name string
+}
+
+// AgentPromptModel 提示词模板中可用的语义模型元数据
+type AgentPromptModel struct {
+	Name        string
+	Description string
+	Dimensions  []string
+	Metrics     []string
+	Parameters  []string
+	Semantics   string // 分析口径说明，未声明时为空
+}
+
+// AgentPromptContext 提示词模板的渲染上下文
+// 模板使用 text/template 语法，例如：
+//
+//	你好 {{.User.Name}}，当前团队为 {{.Team.Name}}，今天是 {{.Date}}。
+//	{{range .Models}}- {{.Name}}: {{join .Metrics "、"}}{{end}}
+type AgentPromptContext struct {
+	User      AgentPromptUser
+	Team      AgentPromptTeam
+	Models    []AgentPromptModel
+	Tools     []string
+	AgentName string
+	Date      string
+	Now       time.Time
+}
+
+// agentTemplateFuncs 提示词模板可用的辅助函数
+var agentTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// defaultSystemPromptTemplate 内置的工具调用阶段系统提示词模板
+const defaultSystemPromptTemplate = `你是一个专业的数据分析助手，专门负责调用数据分析工具。
+
+📋 重要工作流程指导：
+1. **使用智能匿名化分析工具**：对于贡献度分析需求，请使用 smart_anonymized_analyzer 工具，它会自动完成数据验证和匿名化分析的完整流程
+2. **精确匹配原则**：生成的筛选条件必须与用户问题中的具体实体对应，避免过于宽泛或不存在的条件
+3. **语义顺序原则**：调用工具时，groupByDimensions参数中的维度必须按照语义逻辑顺序排列（从大到小、从主要到次要），这样有利于后续匿名化还原时保持语句通顺性
+4. **数据验证策略**：工具会自动验证数据可用性，如果数据不足会给出明确提示
+5. **结果可信度评估**：基于实际数据的完整性和代表性评估结论的可信度
+
+🔧 工具使用指南：
+- **可用工具**：{{join .Tools "、"}}
+- 启用数据验证（enableDataValidation: true）以确保数据质量
+- 同比、环比、年初至今、最近N期等期间对比优先使用 timeIntelligence 参数，由系统按模型的日期字段和财年口径计算期间，不要自行推算日期范围
+- **维度排序重要示例**：
+	 * 货币资金分析：['银行名称', '账户类型', '币种']
+	 * 固定资产分析：['使用部门', '资产类型', '折旧年限'] （部门→资产→属性的逻辑顺序）
+	 * 销售分析：['地区', '产品类别', '销售渠道']
+{{if .Models}}
+📚 可访问的语义模型：
+{{range .Models}}- {{.Name}}{{if .Description}}（{{.Description}}）{{end}}
+{{if .Semantics}}  分析口径：{{.Semantics}}
+{{end}}{{end}}{{end}}
+💡 智能分析策略：
+- 优先分析数据中贡献度最高的维度组合
+- 对异常值和趋势变化提供深入洞察
+- 结合业务常识给出可操作的建议
+- 明确说明分析的局限性和数据范围`
+
+// ParseAgentDefinition 从Agent记录中解析声明式定义
+func ParseAgentDefinition(agent *sugar.SugarAgents) (*AgentDefinition, error) {
+	def := &AgentDefinition{}
+	if agent == nil {
+		def.Tools = append([]string(nil), defaultAgentTools...)
+		def.SystemPromptTemplate = defaultSystemPromptTemplate
+		def.buildIndexes()
+		return def, nil
+	}
+
+	if len(agent.AllowedTools) > 0 && string(agent.AllowedTools) != "null" {
+		if err := json.Unmarshal(agent.AllowedTools, &def.Tools); err != nil {
+			return nil, fmt.Errorf("allowedTools 格式错误，应为字符串数组: %w", err)
+		}
+	}
+	if len(def.Tools) == 0 {
+		def.Tools = append([]string(nil), defaultAgentTools...)
+	}
+
+	if len(agent.AllowedModels) > 0 && string(agent.AllowedModels) != "null" {
+		if err := json.Unmarshal(agent.AllowedModels, &def.AllowedModels); err != nil {
+			return nil, fmt.Errorf("allowedModels 格式错误，应为字符串数组: %w", err)
+		}
+	}
+
+	if len(agent.ModelParams) > 0 && string(agent.ModelParams) != "null" {
+		if err := json.Unmarshal(agent.ModelParams, &def.ModelParams); err != nil {
+			return nil, fmt.Errorf("modelParams 格式错误: %w", err)
+		}
+	}
+
+	def.SystemPromptTemplate = defaultSystemPromptTemplate
+	if agent.SystemPromptTemplate != nil && strings.TrimSpace(*agent.SystemPromptTemplate) != "" {
+		def.SystemPromptTemplate = *agent.SystemPromptTemplate
+	}
+	if agent.Prompt != nil {
+		def.PromptTemplate = *agent.Prompt
+	}
+
+	def.buildIndexes()
+	return def, nil
+}
+
+// buildIndexes 构建工具和模型的快速查找表，不可由Agent使用的工具不进入查找表
+func (d *AgentDefinition) buildIndexes() {
+	d.allowedToolSet = make(map[string]bool, len(d.Tools))
+	for _, name := range d.Tools {
+		if isAgentTool(name) {
+			d.allowedToolSet[name] = true
+		}
+	}
+	d.allowedModelSet = make(map[string]bool, len(d.AllowedModels))
+	for _, name := range d.AllowedModels {
+		d.allowedModelSet[name] = true
+	}
+}
+
+// IsToolAllowed 判断工具是否在Agent的允许列表中
+func (d *AgentDefinition) IsToolAllowed(name string) bool {
+	return d.allowedToolSet[name]
+}
+
+// AllowedTools 返回允许列表中可执行的工具，保持声明顺序；保存前未经校验的旧配置中的其他工具被忽略
+func (d *AgentDefinition) AllowedTools() []string {
+	tools := make([]string, 0, len(d.Tools))
+	for _, name := range d.Tools {
+		if d.allowedToolSet[name] {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+// IsModelAllowed 判断语义模型是否允许被该Agent访问
+func (d *AgentDefinition) IsModelAllowed(modelName string) bool {
+	if len(d.allowedModelSet) == 0 {
+		return true
+	}
+	return d.allowedModelSet[modelName]
+}
+
+// ApplyModelParams 将Agent声明的模型参数覆盖到LLM配置上
+func (d *AgentDefinition) ApplyModelParams(llmConfig *system.LLMConfig) {
+	if llmConfig == nil {
+		return
+	}
+	if d.ModelParams.Temperature != nil {
+		temperature := *d.ModelParams.Temperature
+		llmConfig.Temperature = &temperature
+	}
+	if d.ModelParams.MaxTokens > 0 {
+		llmConfig.MaxTokens = d.ModelParams.MaxTokens
+	}
+}
+
+// Validate 校验Agent定义中的静态配置（工具、参数范围、模板语法）
+func (d *AgentDefinition) Validate() error {
+	var unknown []string
+	for _, name := range d.Tools {
+		if !isAgentTool(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("未知的工具: %s，可用工具: %s", strings.Join(unknown, ", "), strings.Join(AvailableAgentTools(), ", "))
+	}
+
+	if t := d.ModelParams.Temperature; t != nil && (*t < 0 || *t > agentMaxTemperature) {
+		return fmt.Errorf("temperature 取值范围为 0-%.0f", agentMaxTemperature)
+	}
+	if d.ModelParams.MaxTokens < 0 || d.ModelParams.MaxTokens > agentMaxTokensLimit {
+		return fmt.Errorf("maxTokens 取值范围为 0-%d", agentMaxTokensLimit)
+	}
+
+	// 使用示例上下文试渲染模板，提前暴露语法错误和字段拼写错误
+	sample := sampleAgentPromptContext(d)
+	if _, err := renderAgentTemplate("systemPromptTemplate", d.SystemPromptTemplate, sample); err != nil {
+		return err
+	}
+	if _, err := renderAgentTemplate("prompt", d.PromptTemplate, sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenderSystemPrompt 渲染工具调用阶段的系统提示词
+func (d *AgentDefinition) RenderSystemPrompt(promptCtx *AgentPromptContext) (string, error) {
+	return renderAgentTemplate("systemPromptTemplate", d.SystemPromptTemplate, promptCtx)
+}
+
+// RenderPrompt 渲染数据分析阶段的提示词，为空时返回空字符串
+func (d *AgentDefinition) RenderPrompt(promptCtx *AgentPromptContext) (string, error) {
+	return renderAgentTemplate("prompt", d.PromptTemplate, promptCtx)
+}
+
+// renderAgentTemplate 渲染提示词模板；不包含模板语法的文本原样返回
+func renderAgentTemplate(name, text string, promptCtx *AgentPromptContext) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Funcs(agentTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%s 模板语法错误: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, promptCtx); err != nil {
+		return "", fmt.Errorf("%s 模板渲染失败: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// sampleAgentPromptContext 构造用于保存时校验的示例上下文
+func sampleAgentPromptContext(d *AgentDefinition) *AgentPromptContext {
+	now := time.Now()
+	models := make([]AgentPromptModel, 0, len(d.AllowedModels))
+	for _, name := range d.AllowedModels {
+		models = append(models, AgentPromptModel{Name: name})
+	}
+	return &AgentPromptContext{
+		User:      AgentPromptUser{Name: "sample", NickName: "sample"},
+		Team:      AgentPromptTeam{Id: "0", Name: "sample"},
+		Models:    models,
+		Tools:     d.Tools,
+		AgentName: "sample",
+		Date:      now.Format("2006-01-02"),
+		Now:       now,
+	}
+}
+
+// BuildAgentPromptContext 构建调用时的提示词渲染上下文
+func BuildAgentPromptContext(ctx context.Context, agent *sugar.SugarAgents, def *AgentDefinition, userId string) *AgentPromptContext {
+	now := time.Now()
+	promptCtx := &AgentPromptContext{
+		Tools: def.AllowedTools(),
+		Date:  now.Format("2006-01-02"),
+		Now:   now,
+	}
+
+	if id, err := strconv.ParseUint(userId, 10, 64); err == nil {
+		var user systemModel.SysUser
+		if err := global.GVA_DB.WithContext(ctx).Select("id", "username", "nick_name").Where("id = ?", id).First(&user).Error; err == nil {
+			promptCtx.User.Name = user.Username
+			promptCtx.User.NickName = user.NickName
+		}
+	}
+
+	if agent == nil {
+		return promptCtx
+	}
+	if agent.Name != nil {
+		promptCtx.AgentName = *agent.Name
+	}
+	if agent.TeamId != nil {
+		promptCtx.Team.Id = *agent.TeamId
+		var team sugar.SugarTeams
+		if err := global.GVA_DB.WithContext(ctx).Where("id = ?", *agent.TeamId).First(&team).Error; err == nil && team.TeamName != nil {
+			promptCtx.Team.Name = *team.TeamName
+		}
+	}
+
+	// 模型元数据：优先使用允许列表，否则使用Agent关联的语义模型
+	modelNames := def.AllowedModels
+	if len(modelNames) == 0 && agent.Semantic != nil && *agent.Semantic != "" {
+		modelNames = []string{*agent.Semantic}
+	}
+	if len(modelNames) > 0 {
+		// 只加载用户可读团队中的模型，避免提示词泄露其他团队的模型元数据
+		teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceSemanticModel, SugarActionRead)
+		if err != nil {
+			global.GVA_LOG.Warn("获取用户可读的团队失败", zap.Error(err))
+		}
+		var models []sugar.SugarSemanticModels
+		if len(teamIds) > 0 {
+			if err := global.GVA_DB.WithContext(ctx).Where("(name IN ? OR id IN ?) AND team_id IN ? AND deleted_at IS NULL", modelNames, modelNames, teamIds).
+				Order("name").Find(&models).Error; err != nil {
+				global.GVA_LOG.Warn("加载提示词模板的语义模型信息失败", zap.Error(err))
+			}
+		}
+		for _, model := range models {
+			promptCtx.Models = append(promptCtx.Models, buildAgentPromptModel(model))
+		}
+	}
+
+	return promptCtx
+}
+
+// buildAgentPromptModel 将语义模型转换为提示词模板中的元数据
+func buildAgentPromptModel(model sugar.SugarSemanticModels) AgentPromptModel {
+	result := AgentPromptModel{}
+	if model.Name != nil {
+		result.Name = *model.Name
+	}
+	if model.Description != nil {
+		result.Description = *model.Description
+	}
+
+	var params map[string]map[string]interface{}
+	if len(model.ParameterConfig) > 0 && json.Unmarshal(model.ParameterConfig, &params) == nil {
+		for name := range params {
+			result.Parameters = append(result.Parameters, name)
+		}
+		sort.Strings(result.Parameters)
+	}
+
+	var columns map[string]map[string]interface{}
+	if len(model.ReturnableColumnsConfig) > 0 && json.Unmarshal(model.ReturnableColumnsConfig, &columns) == nil {
+		for name, config := range columns {
+			if columnType, _ := config["type"].(string); columnType == "metric" || columnType == "measure" {
+				result.Metrics = append(result.Metrics, name)
+			} else {
+				result.Dimensions = append(result.Dimensions, name)
+			}
+		}
+		sort.Strings(result.Metrics)
+		sort.Strings(result.Dimensions)
+	}
+
+	if semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics); err == nil {
+		result.Semantics = strings.ReplaceAll(semantics.Describe(""), "\n", "；")
+	}
+	return result
+}
+
+// ValidateAgentDefinition 保存Agent前校验其声明式定义，包括允许访问的语义模型是否存在于团队中
+func ValidateAgentDefinition(ctx context.Context, agent *sugar.SugarAgents) error {
+	if agent == nil {
+		return errors.New("Agent 不能为空")
+	}
+	def, err := ParseAgentDefinition(agent)
+	if err != nil {
+		return err
+	}
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	if _, err := ParseAnonymizationPolicy(agent.AnonymizationPolicy); err != nil {
+		return err
+	}
+
+	if len(def.AllowedModels) > 0 {
+		query := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarSemanticModels{}).
+			Where("name IN ? AND deleted_at IS NULL", def.AllowedModels)
+		if agent.TeamId != nil && *agent.TeamId != "" {
+			query = query.Where("team_id = ?", *agent.TeamId)
+		}
+		var found []string
+		if err := query.Pluck("name", &found).Error; err != nil {
+			return fmt.Errorf("校验语义模型失败: %w", err)
+		}
+		foundSet := make(map[string]bool, len(found))
+		for _, name := range found {
+			foundSet[name] = true
+		}
+		var missing []string
+		for _, name := range def.AllowedModels {
+			if !foundSet[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("语义模型不存在或不属于当前团队: %s", strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
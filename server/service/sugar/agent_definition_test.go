@@ -0,0 +1,161 @@
+package sugar
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"gorm.io/datatypes"
+)
+
+func TestParseAgentDefinition(t *testing.T) {
+	def, err := ParseAgentDefinition(nil)
+	if err != nil || !reflect.DeepEqual(def.Tools, defaultAgentTools) || def.SystemPromptTemplate != defaultSystemPromptTemplate {
+		t.Fatalf("未配置Agent时应使用默认定义: %+v %v", def, err)
+	}
+
+	temperature := 0.3
+	systemPrompt := "  "
+	prompt := "分析 {{.Team.Name}}"
+	agent := &sugar.SugarAgents{
+		AllowedTools:         datatypes.JSON(`["data_scope_explorer", "smart_anonymized_analyzer"]`),
+		AllowedModels:        datatypes.JSON(`["销售"]`),
+		ModelParams:          datatypes.JSON(`{"temperature": 0.3, "maxTokens": 2000}`),
+		SystemPromptTemplate: &systemPrompt,
+		Prompt:               &prompt,
+	}
+	if def, err = ParseAgentDefinition(agent); err != nil {
+		t.Fatalf("解析Agent定义失败: %v", err)
+	}
+	if !reflect.DeepEqual(def.Tools, []string{"data_scope_explorer", "smart_anonymized_analyzer"}) || def.PromptTemplate != prompt {
+		t.Fatalf("工具或提示词解析错误: %+v", def)
+	}
+	// 空白的系统提示词模板使用内置模板
+	if def.SystemPromptTemplate != defaultSystemPromptTemplate {
+		t.Fatal("空白的系统提示词模板应使用内置模板")
+	}
+	if !def.IsModelAllowed("销售") || def.IsModelAllowed("成本") || !def.IsToolAllowed("data_scope_explorer") || def.IsToolAllowed("anonymized_data_analyzer") {
+		t.Fatalf("允许列表判断错误: %+v", def)
+	}
+	llmConfig := &system.LLMConfig{MaxTokens: 100}
+	def.ApplyModelParams(llmConfig)
+	if llmConfig.Temperature == nil || *llmConfig.Temperature != temperature || llmConfig.MaxTokens != 2000 {
+		t.Fatalf("模型参数未覆盖到LLM配置: %+v", llmConfig)
+	}
+
+	// 空的工具列表使用默认工具，JSON 格式错误时返回错误
+	if def, err = ParseAgentDefinition(&sugar.SugarAgents{AllowedTools: datatypes.JSON(`[]`)}); err != nil || !reflect.DeepEqual(def.Tools, defaultAgentTools) {
+		t.Fatalf("空工具列表应使用默认工具: %+v %v", def, err)
+	}
+	for _, broken := range []*sugar.SugarAgents{
+		{AllowedTools: datatypes.JSON(`"smart_anonymized_analyzer"`)},
+		{AllowedModels: datatypes.JSON(`{"name": "销售"}`)},
+		{ModelParams: datatypes.JSON(`{"maxTokens": "many"}`)},
+	} {
+		if _, err := ParseAgentDefinition(broken); err == nil {
+			t.Errorf("格式错误的配置应解析失败: %+v", broken)
+		}
+	}
+}
+
+func TestAgentDefinitionValidate(t *testing.T) {
+	if tools := AvailableAgentTools(); !reflect.DeepEqual(tools, []string{"anonymized_data_analyzer", "data_scope_explorer", "smart_anonymized_analyzer"}) {
+		t.Fatalf("Agent可用的工具应只包含AIFETCH内部工具: %v", tools)
+	}
+
+	missingField := "{{.User.Missing}}"
+	tests := []struct {
+		name  string
+		agent *sugar.SugarAgents
+		ok    bool
+	}{
+		{"默认定义", &sugar.SugarAgents{}, true},
+		{"AIFETCH工具", &sugar.SugarAgents{AllowedTools: datatypes.JSON(`["anonymized_data_analyzer"]`)}, true},
+		{"注册表中的其他MCP工具", &sugar.SugarAgents{AllowedTools: datatypes.JSON(`["currentTime"]`)}, false},
+		{"MCP语义查询工具", &sugar.SugarAgents{AllowedTools: datatypes.JSON(`["sugar_get"]`)}, false},
+		{"温度超出范围", &sugar.SugarAgents{ModelParams: datatypes.JSON(`{"temperature": 2.5}`)}, false},
+		{"最大Token为负数", &sugar.SugarAgents{ModelParams: datatypes.JSON(`{"maxTokens": -1}`)}, false},
+		{"模板引用不存在的字段", &sugar.SugarAgents{Prompt: &missingField}, false},
+	}
+	for _, tt := range tests {
+		def, err := ParseAgentDefinition(tt.agent)
+		if err != nil {
+			t.Fatalf("%s: 解析失败: %v", tt.name, err)
+		}
+		if err = def.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v，期望通过 = %v", tt.name, err, tt.ok)
+		}
+	}
+
+	// 未经校验保存的旧配置中的其他工具不会提供给模型
+	def, _ := ParseAgentDefinition(&sugar.SugarAgents{AllowedTools: datatypes.JSON(`["currentTime", "data_scope_explorer"]`)})
+	if !reflect.DeepEqual(def.AllowedTools(), []string{"data_scope_explorer"}) || def.IsToolAllowed("currentTime") {
+		t.Fatalf("非AIFETCH工具不应被允许: %v", def.AllowedTools())
+	}
+}
+
+func TestRenderAgentTemplate(t *testing.T) {
+	promptCtx := &AgentPromptContext{
+		User:   AgentPromptUser{Name: "alice"},
+		Team:   AgentPromptTeam{Name: "财务部"},
+		Models: []AgentPromptModel{{Name: "销售", Metrics: []string{"收入", "利润"}}},
+		Date:   "2024-03-01",
+	}
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"不含模板语法的文本 {原样} 返回", "不含模板语法的文本 {原样} 返回", false},
+		{"{{default .User.Name .User.NickName}}，{{.Team.Name}}，{{.Date}}", "alice，财务部，2024-03-01", false},
+		{"{{range .Models}}{{.Name}}: {{join .Metrics \"、\"}}{{end}}", "销售: 收入、利润", false},
+		{"{{.User.Id}}", "", true},
+		{"{{if .Models}}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := renderAgentTemplate("prompt", tt.template, promptCtx)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("renderAgentTemplate(%q) = (%q, %v)，期望 (%q, 出错 %v)", tt.template, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildAgentPromptContextScopesModelsToTeams(t *testing.T) {
+	setupTeamWorkbook(t)
+	seedTestData(t,
+		`INSERT INTO sugar_semantic_models (id, name, description, team_id, returnable_columns_config) VALUES
+			('model-1', '销售', '团队1的销售', 'team-1', '{"收入": {"column": "revenue", "type": "metric"}, "城市": {"column": "city"}}'),
+			('model-2', '成本', '团队2的成本', 'team-2', '{}')`,
+	)
+	name, teamId := "分析助手", "team-1"
+	agent := &sugar.SugarAgents{Name: &name, TeamId: &teamId, AllowedModels: datatypes.JSON(`["销售", "成本"]`)}
+	def, err := ParseAgentDefinition(agent)
+	if err != nil {
+		t.Fatalf("解析Agent定义失败: %v", err)
+	}
+
+	// 用户1只属于团队1，看不到团队2的模型
+	promptCtx := BuildAgentPromptContext(context.Background(), agent, def, "1")
+	if promptCtx.User.Name != "alice" || promptCtx.Team.Name != "财务部" || promptCtx.AgentName != name {
+		t.Fatalf("用户或团队信息不符合预期: %+v", promptCtx)
+	}
+	if len(promptCtx.Models) != 1 || promptCtx.Models[0].Name != "销售" || !reflect.DeepEqual(promptCtx.Models[0].Metrics, []string{"收入"}) {
+		t.Fatalf("应只包含用户可读团队的模型: %+v", promptCtx.Models)
+	}
+	prompt, err := def.RenderSystemPrompt(promptCtx)
+	if err != nil || !strings.Contains(prompt, "团队1的销售") || strings.Contains(prompt, "团队2的成本") {
+		t.Fatalf("系统提示词中的模型信息不符合预期: %v\n%s", err, prompt)
+	}
+
+	// 用户12同时属于两个团队
+	if promptCtx = BuildAgentPromptContext(context.Background(), agent, def, "12"); len(promptCtx.Models) != 2 {
+		t.Fatalf("两个团队的成员应能看到两个模型: %+v", promptCtx.Models)
+	}
+	// 不属于任何团队的用户看不到模型
+	if promptCtx = BuildAgentPromptContext(context.Background(), agent, def, "3"); len(promptCtx.Models) != 0 {
+		t.Fatalf("非团队成员不应看到模型: %+v", promptCtx.Models)
+	}
+}
@@ -0,0 +1,138 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// aiFetchTools AIFETCH流程内部的分析工具，只有这些工具可以被Agent声明使用
+var aiFetchTools = map[string]aiFetchToolHandler{
+	"smart_anonymized_analyzer": (*AiFetchProcessor).handleSmartAnonymizedAnalyzer,
+	"data_scope_explorer":       (*AiFetchProcessor).handleDataScopeExplorer,
+	"anonymized_data_analyzer":  (*AiFetchProcessor).handleAnonymizedDataAnalyzer,
+}
+
+// 为 mcpTool 中声明的 AIFETCH 分析工具绑定执行器
+func init() {
+	for name, handler := range aiFetchTools {
+		mcpTool.BindExecutor(name, aiFetchToolExecutor(handler))
+	}
+}
+
+// aiFetchInvocation 一次AIFETCH工具调用的上下文，通过 context 传递给执行器
+type aiFetchInvocation struct {
+	processor    *AiFetchProcessor
+	req          *sugarReq.SugarFormulaAiFetchRequest
+	agent        *sugar.SugarAgents
+	llmConfig    *system.LLMConfig
+	outputSchema *sugarReq.AiOutputSchema
+	logCtx       *ExecutionLogContext
+	result       *sugarRes.SugarFormulaAiResponse // 执行器产生的完整AIFETCH响应
+}
+
+type aiFetchInvocationKey struct{}
+
+// withAiFetchInvocation 将AIFETCH调用上下文写入 context
+func withAiFetchInvocation(ctx context.Context, inv *aiFetchInvocation) context.Context {
+	return context.WithValue(ctx, aiFetchInvocationKey{}, inv)
+}
+
+// aiFetchInvocationFromContext 从 context 中读取AIFETCH调用上下文
+func aiFetchInvocationFromContext(ctx context.Context) *aiFetchInvocation {
+	inv, _ := ctx.Value(aiFetchInvocationKey{}).(*aiFetchInvocation)
+	return inv
+}
+
+// withAiFetchIdentity 将AIFETCH请求的已认证用户写入 context，工具执行器只从这里获取身份
+func withAiFetchIdentity(ctx context.Context, userId string) (context.Context, error) {
+	id, err := strconv.ParseUint(userId, 10, 64)
+	if err != nil || id == 0 {
+		return ctx, fmt.Errorf("无效的用户身份: %q", userId)
+	}
+	return mcpTool.WithIdentity(ctx, &mcpTool.Identity{UserId: uint(id), AuthMethod: mcpTool.AuthMethodJWT}), nil
+}
+
+// aiFetchToolHandler AIFETCH分析工具的处理函数，userId 来自已认证的请求上下文
+type aiFetchToolHandler func(p *AiFetchProcessor, ctx context.Context, inv *aiFetchInvocation, userId string, toolCall system.OpenAIToolCall) (*sugarRes.SugarFormulaAiResponse, error)
+
+// aiFetchToolExecutor 将AIFETCH处理函数适配为 mcpTool.ToolExecutor
+func aiFetchToolExecutor(handler aiFetchToolHandler) mcpTool.ToolExecutor {
+	return mcpTool.ToolExecutorFunc(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		inv := aiFetchInvocationFromContext(ctx)
+		if inv == nil {
+			return nil, fmt.Errorf("工具 %s 仅支持在AIFETCH流程中调用", request.Params.Name)
+		}
+		// 身份只取自请求上下文，模型在参数中传入的任何用户ID都不参与鉴权
+		identity, err := mcpTool.RequireIdentity(ctx)
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := json.Marshal(request.GetArguments())
+		if err != nil {
+			return nil, fmt.Errorf("序列化工具调用参数失败: %w", err)
+		}
+		toolCall := system.OpenAIToolCall{
+			Type:     "function",
+			Function: system.OpenAIToolCallFunction{Name: request.Params.Name, Arguments: string(arguments)},
+		}
+
+		result, err := handler(inv.processor, ctx, inv, identity.UserIdString(), toolCall)
+		if err != nil {
+			return nil, err
+		}
+		inv.result = result
+		if result == nil {
+			return mcp.NewToolResultText(""), nil
+		}
+		if result.Error != "" {
+			return mcp.NewToolResultError(result.Error), nil
+		}
+		return mcp.NewToolResultText(result.Text), nil
+	})
+}
+
+// AvailableAgentTools 返回所有可供Agent声明使用的工具名称
+// 只包含AIFETCH内部工具，注册表中的其他MCP工具（如 currentTime、sugar_*）不对Agent开放
+func AvailableAgentTools() []string {
+	names := make([]string, 0, len(aiFetchTools))
+	for name := range aiFetchTools {
+		if mcpTool.IsExecutable(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isAgentTool 判断工具是否可以被Agent声明使用
+func isAgentTool(name string) bool {
+	_, ok := aiFetchTools[name]
+	return ok && mcpTool.IsExecutable(name)
+}
+
+// ToolDefinitions 根据工具名称列表获取工具定义，schema 来自 mcpTool 注册表
+func (aim *AIInteractionManager) ToolDefinitions(toolNames []string) ([]system.ToolDefinition, error) {
+	schemas, err := mcpTool.FunctionSchemas(toolNames...)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]system.ToolDefinition, 0, len(schemas))
+	for _, schema := range schemas {
+		tools = append(tools, system.ToolDefinition{
+			Name:        schema.Name,
+			Description: schema.Description,
+			Parameters:  schema.Parameters,
+		})
+	}
+	return tools, nil
+}
@@ -0,0 +1,138 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// setupAgentToolsDB 初始化内存数据库：用户1只能看北京，用户2只能看上海
+func setupAgentToolsDB(t *testing.T) {
+	t.Helper()
+	db := setupTestDB(t)
+	statements := []string{
+		`CREATE TABLE sales_facts (city_code TEXT, product TEXT, amount REAL)`,
+		`INSERT INTO sys_users (id, username, nick_name) VALUES (1, 'alice', 'Alice'), (2, 'bob', 'Bob')`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor'), ('team-1', '2', 'editor')`,
+		`INSERT INTO sugar_city_permissions (user_id, city_code) VALUES ('1', 'BJ'), ('2', 'SH')`,
+		`INSERT INTO sales_facts VALUES ('BJ', 'A', 100), ('BJ', 'B', 50), ('SH', 'C', 200)`,
+	}
+	seedTestData(t, statements...)
+	id, name, teamId, table, permissionKey := "model-1", "销售事实", "team-1", "sales_facts", "city_code"
+	model := sugar.SugarSemanticModels{
+		Id:                      &id,
+		Name:                    &name,
+		TeamId:                  &teamId,
+		SourceTableName:         &table,
+		PermissionKeyColumn:     &permissionKey,
+		ParameterConfig:         []byte(`{"城市": {"column": "city_code", "operator": "="}}`),
+		ReturnableColumnsConfig: []byte(`{"城市": {"column": "city_code", "type": "dimension"}, "产品": {"column": "product", "type": "dimension"}, "销售额": {"column": "amount", "type": "metric"}}`),
+	}
+	if err := db.Create(&model).Error; err != nil {
+		t.Fatalf("创建语义模型失败: %v", err)
+	}
+}
+
+func dataScopeToolCall(t *testing.T, args map[string]interface{}) system.OpenAIToolCall {
+	t.Helper()
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("序列化参数失败: %v", err)
+	}
+	return system.OpenAIToolCall{
+		Type:     "function",
+		Function: system.OpenAIToolCallFunction{Name: "data_scope_explorer", Arguments: string(arguments)},
+	}
+}
+
+func TestAgentToolSchemasHaveNoUserId(t *testing.T) {
+	schemas, err := mcpTool.FunctionSchemas(AvailableAgentTools()...)
+	if err != nil {
+		t.Fatalf("获取工具schema失败: %v", err)
+	}
+	for _, schema := range schemas {
+		data, _ := json.Marshal(schema.Parameters)
+		if strings.Contains(string(data), "userId") {
+			t.Errorf("工具 %s 的参数中不应包含 userId: %s", schema.Name, data)
+		}
+	}
+}
+
+func TestDefaultSystemPromptHasNoUserId(t *testing.T) {
+	setupAgentToolsDB(t)
+	def, err := ParseAgentDefinition(nil)
+	if err != nil {
+		t.Fatalf("解析默认Agent定义失败: %v", err)
+	}
+	prompt, err := def.RenderSystemPrompt(BuildAgentPromptContext(context.Background(), nil, def, "1"))
+	if err != nil {
+		t.Fatalf("渲染系统提示词失败: %v", err)
+	}
+	if strings.Contains(prompt, "用户ID") || strings.Contains(prompt, "userId") {
+		t.Fatalf("系统提示词不应向模型暴露用户ID:\n%s", prompt)
+	}
+}
+
+func TestAiFetchToolIgnoresUserIdArgument(t *testing.T) {
+	setupAgentToolsDB(t)
+	p := NewAiFetchProcessor(nil)
+	req := &sugarReq.SugarFormulaAiFetchRequest{}
+
+	// 用户1的请求中，模型在参数里伪造了用户2的ID
+	toolCall := dataScopeToolCall(t, map[string]interface{}{
+		"modelName":         "销售事实",
+		"exploreDimensions": []string{"城市"},
+		"userId":            "2",
+	})
+	resp, err := p.invokeTool(context.Background(), toolCall, "1", req, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("调用工具失败: %v", err)
+	}
+	if resp.Error != "" {
+		t.Fatalf("工具返回错误: %s", resp.Error)
+	}
+	if !strings.Contains(resp.Text, "BJ") || strings.Contains(resp.Text, "SH") {
+		t.Fatalf("工具结果应只包含用户1可见的北京数据:\n%s", resp.Text)
+	}
+
+	// 同样的参数在用户2的请求中只能看到上海
+	resp, err = p.invokeTool(context.Background(), toolCall, "2", req, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("调用工具失败: %v", err)
+	}
+	if !strings.Contains(resp.Text, "SH") || strings.Contains(resp.Text, "BJ") {
+		t.Fatalf("工具结果应只包含用户2可见的上海数据:\n%s", resp.Text)
+	}
+}
+
+func TestAiFetchToolRequiresIdentity(t *testing.T) {
+	setupAgentToolsDB(t)
+	p := NewAiFetchProcessor(nil)
+	inv := &aiFetchInvocation{processor: p, req: &sugarReq.SugarFormulaAiFetchRequest{}}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "data_scope_explorer"
+	request.Params.Arguments = map[string]interface{}{
+		"modelName":         "销售事实",
+		"exploreDimensions": []string{"城市"},
+		"userId":            "1",
+	}
+	if _, err := mcpTool.InvokeTool(withAiFetchInvocation(context.Background(), inv), "data_scope_explorer", request); err == nil {
+		t.Fatal("缺少身份时不应凭参数中的 userId 执行工具")
+	}
+
+	resp, err := p.invokeTool(context.Background(), dataScopeToolCall(t, request.GetArguments()), "", inv.req, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("调用工具失败: %v", err)
+	}
+	if resp.Error == "" {
+		t.Fatal("未认证的AIFETCH请求不应执行工具")
+	}
+}
@@ -2,15 +2,20 @@ package sugar
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
 	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
 	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
 	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"github.com/mark3labs/mcp-go/mcp"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +26,9 @@ type AiFetchProcessor struct {
 	anonymizationProcessor *AnonymizationProcessor
 	aiInteractionManager   *AIInteractionManager
 	executionLogger        *ExecutionLogger
+	structuredOutput       *StructuredOutputProcessor
+	anonymizationSessions  *SugarAnonymizationSessionsService
+	privacyBudget          *SugarPrivacyBudgetService
 }
 
 // NewAiFetchProcessor 创建AI获取处理器
@@ -31,6 +39,9 @@ func NewAiFetchProcessor(advancedAnalyzer *advanced_contribution_analyzer.Advanc
 		anonymizationProcessor: NewAnonymizationProcessor(),
 		aiInteractionManager:   NewAIInteractionManager(),
 		executionLogger:        NewExecutionLogger(),
+		structuredOutput:       NewStructuredOutputProcessor(),
+		anonymizationSessions:  &SugarAnonymizationSessionsService{},
+		privacyBudget:          &SugarPrivacyBudgetService{},
 	}
 }
 
@@ -47,6 +58,31 @@ func (p *AiFetchProcessor) ProcessRequest(ctx context.Context, req *sugarReq.Sug
 		return sugarRes.NewAiErrorResponse(err.Error()), nil
 	}
 
+	// 解析Agent声明式定义：工具允许列表、模型参数和提示词模板
+	agentDef, err := ParseAgentDefinition(agent)
+	if err != nil {
+		return sugarRes.NewAiErrorResponse("Agent定义无效: " + err.Error()), nil
+	}
+	agentDef.ApplyModelParams(llmConfig)
+
+	// 渲染提示词模板（用户、团队、模型元数据、日期），分析阶段使用渲染后的Prompt
+	promptCtx := BuildAgentPromptContext(ctx, agent, agentDef, userId)
+	if agentDef.PromptTemplate != "" {
+		renderedPrompt, err := agentDef.RenderPrompt(promptCtx)
+		if err != nil {
+			return sugarRes.NewAiErrorResponse(err.Error()), nil
+		}
+		renderedAgent := *agent
+		renderedAgent.Prompt = &renderedPrompt
+		agent = &renderedAgent
+	}
+
+	// 解析结构化输出定义（公式参数优先，其次为Agent默认配置）
+	outputSchema, err := p.structuredOutput.ResolveSchema(req, agent)
+	if err != nil {
+		return sugarRes.NewAiErrorResponse(err.Error()), nil
+	}
+
 	// 2. 创建执行日志
 	logCtx, err := p.executionLogger.CreateLog(ctx, req, userId, agent.Id)
 	if err != nil {
@@ -54,7 +90,13 @@ func (p *AiFetchProcessor) ProcessRequest(ctx context.Context, req *sugarReq.Sug
 	}
 
 	// 3. 构建系统提示词和用户消息
-	systemPrompt := p.aiInteractionManager.BuildSystemPrompt(agent, userId)
+	systemPrompt, err := p.aiInteractionManager.BuildSystemPrompt(agentDef, promptCtx)
+	if err != nil {
+		if logCtx != nil {
+			p.executionLogger.FinishWithError(ctx, logCtx, err.Error())
+		}
+		return sugarRes.NewAiErrorResponse(err.Error()), nil
+	}
 	userMessage := p.aiInteractionManager.BuildUserMessage(req.Description, agent.Semantic, req.DataRange)
 
 	// 记录到日志
@@ -63,7 +105,7 @@ func (p *AiFetchProcessor) ProcessRequest(ctx context.Context, req *sugarReq.Sug
 	}
 
 	// 4. 调用LLM获取工具调用指令
-	llmResponse, err := p.aiInteractionManager.CallLLMWithTools(ctx, llmConfig, systemPrompt, userMessage)
+	llmResponse, err := p.aiInteractionManager.CallLLMWithTools(ctx, llmConfig, systemPrompt, userMessage, agentDef.AllowedTools())
 	if err != nil {
 		if logCtx != nil {
 			p.executionLogger.FinishWithError(ctx, logCtx, "AI分析失败: "+err.Error())
@@ -77,7 +119,7 @@ func (p *AiFetchProcessor) ProcessRequest(ctx context.Context, req *sugarReq.Sug
 	}
 
 	// 5. 处理LLM响应和工具调用
-	result, err := p.processLLMResponse(ctx, llmResponse, userId, req, agent, llmConfig, logCtx)
+	result, err := p.processLLMResponse(ctx, llmResponse, userId, req, agent, agentDef, llmConfig, outputSchema, logCtx)
 	if err != nil {
 		if logCtx != nil {
 			p.executionLogger.FinishWithError(ctx, logCtx, err.Error())
@@ -87,25 +129,25 @@ func (p *AiFetchProcessor) ProcessRequest(ctx context.Context, req *sugarReq.Sug
 
 	// 6. 记录成功日志
 	if logCtx != nil && result != nil {
-		p.executionLogger.FinishWithSuccess(ctx, logCtx, result.Text)
+		p.executionLogger.FinishWithSuccess(ctx, logCtx, p.summarizeResult(result))
 	}
 
 	return result, nil
 }
 
 // processLLMResponse 处理LLM响应，可能包含工具调用
-func (p *AiFetchProcessor) processLLMResponse(ctx context.Context, llmResponse string, userId string, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, llmConfig *system.LLMConfig, logCtx *ExecutionLogContext) (*sugarRes.SugarFormulaAiResponse, error) {
+func (p *AiFetchProcessor) processLLMResponse(ctx context.Context, llmResponse string, userId string, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, agentDef *AgentDefinition, llmConfig *system.LLMConfig, outputSchema *sugarReq.AiOutputSchema, logCtx *ExecutionLogContext) (*sugarRes.SugarFormulaAiResponse, error) {
 	global.GVA_LOG.Info("开始处理LLM响应", zap.String("userId", userId))
 
 	// 解析工具调用
 	toolCallResp, err := p.aiInteractionManager.ParseToolCallResponse(llmResponse)
 	if err != nil {
 		// 不是工具调用，直接返回文本响应
-		return sugarRes.NewAiSuccessResponseWithText(llmResponse), nil
+		return p.buildTextResponse(ctx, llmResponse, req, llmConfig, outputSchema), nil
 	}
 
 	if len(toolCallResp.Content) == 0 {
-		return sugarRes.NewAiSuccessResponseWithText(llmResponse), nil
+		return p.buildTextResponse(ctx, llmResponse, req, llmConfig, outputSchema), nil
 	}
 
 	// 处理工具调用
@@ -114,21 +156,113 @@ func (p *AiFetchProcessor) processLLMResponse(ctx context.Context, llmResponse s
 		zap.String("functionName", toolCall.Function.Name),
 		zap.String("arguments", toolCall.Function.Arguments))
 
-	switch toolCall.Function.Name {
-	case "smart_anonymized_analyzer":
-		return p.handleSmartAnonymizedAnalyzer(ctx, toolCall, logCtx, req, agent, llmConfig)
-	case "data_scope_explorer":
-		return p.handleDataScopeExplorer(ctx, toolCall, logCtx)
-	case "anonymized_data_analyzer":
-		return p.handleAnonymizedDataAnalyzer(ctx, toolCall, logCtx, req, agent, llmConfig)
-	default:
-		return sugarRes.NewAiSuccessResponseWithText(llmResponse), nil
+	// 校验工具和语义模型是否在Agent的允许范围内
+	if err := p.checkToolCallAllowed(agentDef, toolCall); err != nil {
+		global.GVA_LOG.Warn("工具调用被Agent定义拒绝",
+			zap.String("functionName", toolCall.Function.Name),
+			zap.Error(err))
+		return sugarRes.NewAiErrorResponse(err.Error()), err
+	}
+
+	// 通过 mcpTool 注册表统一分发工具调用
+	return p.invokeTool(ctx, toolCall, userId, req, agent, llmConfig, outputSchema, logCtx)
+}
+
+// invokeTool 通过 mcpTool 注册表在进程内调用工具，AIFETCH上下文经由 context 传递给执行器
+func (p *AiFetchProcessor) invokeTool(ctx context.Context, toolCall system.OpenAIToolCall, userId string, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, llmConfig *system.LLMConfig, outputSchema *sugarReq.AiOutputSchema, logCtx *ExecutionLogContext) (*sugarRes.SugarFormulaAiResponse, error) {
+	toolCallStartTime := time.Now()
+
+	var arguments map[string]interface{}
+	if toolCall.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
+			if logCtx != nil {
+				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, nil, "解析工具调用参数失败: "+err.Error(), toolCallStartTime)
+			}
+			return sugarRes.NewAiErrorResponse("解析工具调用参数失败: " + err.Error()), nil
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = toolCall.Function.Name
+	request.Params.Arguments = arguments
+
+	inv := &aiFetchInvocation{
+		processor:    p,
+		req:          req,
+		agent:        agent,
+		llmConfig:    llmConfig,
+		outputSchema: outputSchema,
+		logCtx:       logCtx,
+	}
+	var toolResult *mcp.CallToolResult
+	toolCtx, err := withAiFetchIdentity(withAiFetchInvocation(ctx, inv), userId)
+	if err == nil {
+		toolResult, err = mcpTool.InvokeTool(toolCtx, toolCall.Function.Name, request)
+	}
+	if err != nil {
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, arguments, err.Error(), toolCallStartTime)
+		}
+		return sugarRes.NewAiErrorResponse("工具调用失败: " + err.Error()), nil
+	}
+
+	// AIFETCH分析工具会直接产出完整响应
+	if inv.result != nil {
+		return inv.result, nil
+	}
+
+	// 其他通用工具：将工具返回的文本内容作为结果
+	text := toolResultText(toolResult)
+	if toolResult != nil && toolResult.IsError {
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, arguments, text, toolCallStartTime)
+		}
+		return sugarRes.NewAiErrorResponse(text), nil
+	}
+	if logCtx != nil {
+		p.executionLogger.RecordToolCallSuccessWithResult(ctx, logCtx, toolCall.Function.Name, arguments, map[string]interface{}{"text": text}, toolCallStartTime)
+	}
+	result := sugarRes.NewAiSuccessResponseWithText(text)
+	if outputSchema != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("工具 %s 的结果不支持结构化输出，已返回文本结果", toolCall.Function.Name))
+	}
+	return result, nil
+}
+
+// toolResultText 拼接工具返回结果中的文本内容
+func toolResultText(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
 	}
+	return strings.Join(parts, "\n")
+}
+
+// checkToolCallAllowed 校验工具调用是否符合Agent声明的工具和语义模型允许列表
+func (p *AiFetchProcessor) checkToolCallAllowed(agentDef *AgentDefinition, toolCall system.OpenAIToolCall) error {
+	if !agentDef.IsToolAllowed(toolCall.Function.Name) {
+		return fmt.Errorf("Agent未被授权使用工具: %s", toolCall.Function.Name)
+	}
+	var args struct {
+		ModelName string `json:"modelName"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err == nil && args.ModelName != "" {
+		if !agentDef.IsModelAllowed(args.ModelName) {
+			return fmt.Errorf("Agent未被授权访问语义模型: %s", args.ModelName)
+		}
+	}
+	return nil
 }
 
 // handleSmartAnonymizedAnalyzer 处理智能匿名化分析工具调用
-func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, toolCall system.OpenAIToolCall, logCtx *ExecutionLogContext, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, llmConfig *system.LLMConfig) (*sugarRes.SugarFormulaAiResponse, error) {
+func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, inv *aiFetchInvocation, userId string, toolCall system.OpenAIToolCall) (*sugarRes.SugarFormulaAiResponse, error) {
 	toolCallStartTime := time.Now()
+	logCtx, req, agent, llmConfig, outputSchema := inv.logCtx, inv.req, inv.agent, inv.llmConfig, inv.outputSchema
 
 	// 解析工具参数
 	params, err := p.aiInteractionManager.ParseSmartAnalyzerParams(toolCall.Function.Arguments)
@@ -145,10 +279,21 @@ func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, to
 		zap.Strings("groupByDimensions", params.GroupByDimensions),
 		zap.Bool("enableDataValidation", params.EnableDataValidation))
 
+	// 时间智能函数：本期与基期的期间条件由服务端计算，不依赖模型生成的筛选条件
+	if params.TimeIntelligence != nil {
+		params.CurrentPeriodFilters, params.BasePeriodFilters, _, err = p.dataProcessor.ResolvePeriodFilters(ctx, params.ModelName, userId, params.TimeIntelligence, params.CurrentPeriodFilters, params.BasePeriodFilters)
+		if err != nil {
+			if logCtx != nil {
+				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "时间智能期间计算失败: "+err.Error(), toolCallStartTime)
+			}
+			return sugarRes.NewAiErrorResponse("时间智能期间计算失败: " + err.Error()), nil
+		}
+	}
+
 	// 数据验证
 	var validationMessage string
 	if params.EnableDataValidation {
-		validationResult, err := p.dataProcessor.ValidateDataAvailability(ctx, params.ModelName, params.GroupByDimensions, params.CurrentPeriodFilters, params.BasePeriodFilters, params.UserId)
+		validationResult, err := p.dataProcessor.ValidateDataAvailability(ctx, params.ModelName, params.TargetMetric, params.GroupByDimensions, params.CurrentPeriodFilters, params.BasePeriodFilters, userId)
 		if err != nil {
 			if logCtx != nil {
 				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "数据可用性验证失败: "+err.Error(), toolCallStartTime)
@@ -164,23 +309,75 @@ func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, to
 		}
 	}
 
+	// 同一对话的后续轮次沿用已有匿名化会话的代号
+	previousSession, sessionId, err := p.anonymizationSessions.LoadConversationSession(ctx, req.ConversationId, userId)
+	if err != nil {
+		global.GVA_LOG.Warn("加载对话匿名化会话失败，将使用新会话", zap.String("conversationId", req.ConversationId), zap.Error(err))
+		previousSession, sessionId = nil, ""
+	}
+
+	// 解析语义模型和Agent上的匿名化策略
+	policy, refusal := p.resolveAnonymizationPolicy(ctx, inv, userId, params.ModelName, toolCall.Function.Name, params, toolCallStartTime)
+	if refusal != nil {
+		return refusal, nil
+	}
+	config := policy.LiteConfig()
+
+	// advanced 策略预留隐私预算，预算耗尽时按配置降级或拒绝；之后任一步骤失败、没有结果返回给用户时退回预算
+	var budget *PrivacyBudgetGrant
+	if policy.UsesPrivacyBudget() {
+		budget, refusal = p.reservePrivacyBudget(ctx, userId, params.ModelName, policy.Epsilon, toolCall.Function.Name, params, logCtx, toolCallStartTime)
+		if refusal != nil {
+			return refusal, nil
+		}
+		budget.ApplyTo(config)
+	}
+
 	// 执行贡献度分析和匿名化处理
-	aiDataText, session, usedAdvanced, err := p.contributionAnalyzer.PerformAnalysis(ctx, params.ModelName, params.TargetMetric, params.CurrentPeriodFilters, params.BasePeriodFilters, params.GroupByDimensions, params.UserId)
+	aiDataText, session, usedAdvanced, err := p.contributionAnalyzer.PerformAnalysis(ctx, params.ModelName, params.TargetMetric, params.CurrentPeriodFilters, params.BasePeriodFilters, params.GroupByDimensions, userId, previousSession, config)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		if logCtx != nil {
 			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "分析处理失败: "+err.Error(), toolCallStartTime)
 		}
 		return sugarRes.NewAiErrorResponse("分析处理失败: " + err.Error()), nil
 	}
+	session.UserID = userId
+	p.persistAnonymizationSession(ctx, inv, userId, sessionId, session)
+
+	// 发送前检查提示词泄露，返回后按代号边界解码
+	guard := newAnonymizationGuard(session, policy.LeakPolicyValue())
 
 	// 记录匿名化信息到日志
 	if logCtx != nil {
 		p.executionLogger.RecordAnonymization(ctx, logCtx, aiDataText, toolCall.Function.Arguments, usedAdvanced)
 	}
 
+	// 结构化输出：在匿名化数据上生成表格，再逐个单元格解码
+	if outputSchema != nil {
+		result, err := p.performStructuredAnalysis(ctx, aiDataText, req, agent, llmConfig, outputSchema, logCtx, guard.Guard, guard.Decode)
+		if err != nil {
+			p.privacyBudget.ReleaseBudget(ctx, budget)
+			if logCtx != nil {
+				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, err.Error(), toolCallStartTime)
+			}
+			return sugarRes.NewAiErrorResponse(err.Error()), nil
+		}
+		result.Warnings = append(result.Warnings, guard.Warnings()...)
+		attachPrivacyBudget(result, budget)
+		if validationMessage != "" {
+			result.Warnings = append([]string{strings.TrimSpace(validationMessage)}, result.Warnings...)
+		}
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallSuccess(ctx, logCtx, toolCall.Function.Name, params, p.summarizeResult(result), len(session.AIReadyData), usedAdvanced, toolCallStartTime)
+		}
+		return result, nil
+	}
+
 	// AI分析
-	analysisResult, err := p.aiInteractionManager.PerformDataAnalysis(ctx, aiDataText, req.Description, agent, llmConfig)
+	analysisResult, err := p.aiInteractionManager.PerformDataAnalysis(ctx, aiDataText, req.Description, agent, llmConfig, guard.Guard)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		return sugarRes.NewAiErrorResponse("AI数据分析失败: " + err.Error()), nil
 	}
 
@@ -190,8 +387,9 @@ func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, to
 	}
 
 	// 解密AI分析结果
-	decodedResult, err := p.anonymizationProcessor.DecodeAIResponse(session, analysisResult)
+	decodedResult, err := guard.Decode(analysisResult)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		if logCtx != nil {
 			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "AI结果解密失败: "+err.Error(), toolCallStartTime)
 		}
@@ -206,12 +404,16 @@ func (p *AiFetchProcessor) handleSmartAnonymizedAnalyzer(ctx context.Context, to
 		p.executionLogger.RecordToolCallSuccess(ctx, logCtx, toolCall.Function.Name, params, decodedResult, len(session.AIReadyData), usedAdvanced, toolCallStartTime)
 	}
 
-	return sugarRes.NewAiSuccessResponseWithText(finalResult), nil
+	result := sugarRes.NewAiSuccessResponseWithText(finalResult)
+	result.Warnings = guard.Warnings()
+	attachPrivacyBudget(result, budget)
+	return result, nil
 }
 
 // handleDataScopeExplorer 处理数据范围探索工具调用
-func (p *AiFetchProcessor) handleDataScopeExplorer(ctx context.Context, toolCall system.OpenAIToolCall, logCtx *ExecutionLogContext) (*sugarRes.SugarFormulaAiResponse, error) {
+func (p *AiFetchProcessor) handleDataScopeExplorer(ctx context.Context, inv *aiFetchInvocation, userId string, toolCall system.OpenAIToolCall) (*sugarRes.SugarFormulaAiResponse, error) {
 	toolCallStartTime := time.Now()
+	logCtx := inv.logCtx
 
 	// 解析参数
 	params, err := p.aiInteractionManager.ParseDataScopeParams(toolCall.Function.Arguments)
@@ -223,7 +425,7 @@ func (p *AiFetchProcessor) handleDataScopeExplorer(ctx context.Context, toolCall
 	}
 
 	// 执行数据范围探索
-	scopeInfo, err := p.dataProcessor.ExploreDataScope(ctx, params.ModelName, params.ExploreDimensions, params.SampleFilters, params.UserId)
+	scopeInfo, err := p.dataProcessor.ExploreDataScope(ctx, params.ModelName, params.ExploreDimensions, params.SampleFilters, userId)
 	if err != nil {
 		if logCtx != nil {
 			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "数据范围探索失败: "+err.Error(), toolCallStartTime)
@@ -245,12 +447,17 @@ func (p *AiFetchProcessor) handleDataScopeExplorer(ctx context.Context, toolCall
 		p.executionLogger.RecordToolCallSuccessWithResult(ctx, logCtx, toolCall.Function.Name, params, toolResult, toolCallStartTime)
 	}
 
-	return sugarRes.NewAiSuccessResponseWithText(resultText), nil
+	result := sugarRes.NewAiSuccessResponseWithText(resultText)
+	if inv.outputSchema != nil {
+		result.Warnings = append(result.Warnings, "数据范围探索结果不支持结构化输出，已返回文本结果")
+	}
+	return result, nil
 }
 
 // handleAnonymizedDataAnalyzer 处理匿名化数据分析工具调用（向后兼容）
-func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, toolCall system.OpenAIToolCall, logCtx *ExecutionLogContext, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, llmConfig *system.LLMConfig) (*sugarRes.SugarFormulaAiResponse, error) {
+func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, inv *aiFetchInvocation, userId string, toolCall system.OpenAIToolCall) (*sugarRes.SugarFormulaAiResponse, error) {
 	toolCallStartTime := time.Now()
+	logCtx, req, agent, llmConfig, outputSchema := inv.logCtx, inv.req, inv.agent, inv.llmConfig, inv.outputSchema
 
 	// 解析参数
 	params, err := p.aiInteractionManager.ParseAnonymizedAnalyzerParams(toolCall.Function.Arguments)
@@ -261,9 +468,41 @@ func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, too
 		return sugarRes.NewAiErrorResponse("解析工具调用参数失败: " + err.Error()), nil
 	}
 
+	// 时间智能函数：本期与基期的期间条件由服务端计算，不依赖模型生成的筛选条件
+	if params.TimeIntelligence != nil {
+		params.CurrentPeriodFilters, params.BasePeriodFilters, _, err = p.dataProcessor.ResolvePeriodFilters(ctx, params.ModelName, userId, params.TimeIntelligence, params.CurrentPeriodFilters, params.BasePeriodFilters)
+		if err != nil {
+			if logCtx != nil {
+				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "时间智能期间计算失败: "+err.Error(), toolCallStartTime)
+			}
+			return sugarRes.NewAiErrorResponse("时间智能期间计算失败: " + err.Error()), nil
+		}
+	}
+
+	// 解析匿名化策略；旧版工具始终编码全部维度值，策略中只有隐私预算和泄露处理策略生效
+	policy, refusal := p.resolveAnonymizationPolicy(ctx, inv, userId, params.ModelName, toolCall.Function.Name, params, toolCallStartTime)
+	if refusal != nil {
+		return refusal, nil
+	}
+
+	// 预留隐私预算；旧版工具不支持定性降级，预算耗尽时一律拒绝；之后任一步骤失败时退回预算
+	var budget *PrivacyBudgetGrant
+	if policy.UsesPrivacyBudget() {
+		budget, refusal = p.reservePrivacyBudget(ctx, userId, params.ModelName, policy.Epsilon, toolCall.Function.Name, params, logCtx, toolCallStartTime)
+		if refusal != nil {
+			return refusal, nil
+		}
+		if budget.Status.Mode == sugarRes.PrivacyBudgetModeCoarse {
+			result := sugarRes.NewAiErrorResponse(budget.Warning() + "，请使用 smart_anonymized_analyzer 获取定性结论")
+			result.PrivacyBudget = budget.Status
+			return result, nil
+		}
+	}
+
 	// 执行匿名化数据处理
-	anonymizedResult, err := p.anonymizationProcessor.ProcessAnonymizedDataAnalysis(ctx, params.ModelName, params.TargetMetric, params.CurrentPeriodFilters, params.BasePeriodFilters, params.GroupByDimensions, params.UserId)
+	anonymizedResult, err := p.anonymizationProcessor.ProcessAnonymizedDataAnalysis(ctx, params.ModelName, params.TargetMetric, params.CurrentPeriodFilters, params.BasePeriodFilters, params.GroupByDimensions, userId)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		if logCtx != nil {
 			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "匿名化数据处理失败: "+err.Error(), toolCallStartTime)
 		}
@@ -273,17 +512,40 @@ func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, too
 	// 序列化匿名化数据
 	aiDataText, err := p.anonymizationProcessor.SerializeAnonymizedDataToText(anonymizedResult.AIReadyData)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		return sugarRes.NewAiErrorResponse("匿名化数据序列化失败: " + err.Error()), nil
 	}
+	liteSession := liteSessionFromLegacy(anonymizedResult)
+	liteSession.UserID = userId
+	p.persistAnonymizationSession(ctx, inv, userId, "", liteSession)
+	guard := newAnonymizationGuard(liteSession, policy.LeakPolicyValue())
 
 	// 记录匿名化信息
 	if logCtx != nil {
 		p.executionLogger.RecordAnonymization(ctx, logCtx, aiDataText, toolCall.Function.Arguments, false)
 	}
 
+	if outputSchema != nil {
+		result, err := p.performStructuredAnalysis(ctx, aiDataText, req, agent, llmConfig, outputSchema, logCtx, guard.Guard, guard.Decode)
+		if err != nil {
+			p.privacyBudget.ReleaseBudget(ctx, budget)
+			if logCtx != nil {
+				p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, err.Error(), toolCallStartTime)
+			}
+			return sugarRes.NewAiErrorResponse(err.Error()), nil
+		}
+		result.Warnings = append(result.Warnings, guard.Warnings()...)
+		attachPrivacyBudget(result, budget)
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallSuccess(ctx, logCtx, toolCall.Function.Name, params, p.summarizeResult(result), len(anonymizedResult.AIReadyData), false, toolCallStartTime)
+		}
+		return result, nil
+	}
+
 	// 进行AI分析
-	analysisResult, err := p.aiInteractionManager.PerformDataAnalysis(ctx, aiDataText, req.Description, agent, llmConfig)
+	analysisResult, err := p.aiInteractionManager.PerformDataAnalysis(ctx, aiDataText, req.Description, agent, llmConfig, guard.Guard)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		return sugarRes.NewAiErrorResponse("AI数据分析失败: " + err.Error()), nil
 	}
 
@@ -293,8 +555,9 @@ func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, too
 	}
 
 	// 解密AI分析结果
-	decodedResult, err := p.anonymizationProcessor.DecodeAIResponseLegacy(anonymizedResult, analysisResult)
+	decodedResult, err := guard.Decode(analysisResult)
 	if err != nil {
+		p.privacyBudget.ReleaseBudget(ctx, budget)
 		if logCtx != nil {
 			p.executionLogger.RecordToolCallError(ctx, logCtx, toolCall.Function.Name, params, "AI结果解密失败: "+err.Error(), toolCallStartTime)
 		}
@@ -306,5 +569,133 @@ func (p *AiFetchProcessor) handleAnonymizedDataAnalyzer(ctx context.Context, too
 		p.executionLogger.RecordToolCallSuccess(ctx, logCtx, toolCall.Function.Name, params, decodedResult, len(anonymizedResult.AIReadyData), false, toolCallStartTime)
 	}
 
-	return sugarRes.NewAiSuccessResponseWithText(decodedResult), nil
+	result := sugarRes.NewAiSuccessResponseWithText(decodedResult)
+	result.Warnings = guard.Warnings()
+	attachPrivacyBudget(result, budget)
+	return result, nil
+}
+
+// resolveAnonymizationPolicy 解析本次调用生效的匿名化策略并记录到执行日志，策略无效时返回错误响应
+func (p *AiFetchProcessor) resolveAnonymizationPolicy(ctx context.Context, inv *aiFetchInvocation, userId, modelName, toolName string, params interface{}, toolCallStartTime time.Time) (*ResolvedAnonymizationPolicy, *sugarRes.SugarFormulaAiResponse) {
+	policy, err := resolveAnonymizationPolicyForModel(ctx, modelName, userId, inv.agent)
+	if err != nil {
+		if inv.logCtx != nil {
+			p.executionLogger.RecordToolCallError(ctx, inv.logCtx, toolName, params, "解析匿名化策略失败: "+err.Error(), toolCallStartTime)
+		}
+		return nil, sugarRes.NewAiErrorResponse("解析匿名化策略失败: " + err.Error())
+	}
+	p.executionLogger.RecordAnonymizationPolicy(ctx, inv.logCtx, policy)
+
+	global.GVA_LOG.Info("匿名化策略解析完成",
+		zap.String("modelName", modelName),
+		zap.Bool("enabled", policy.Enabled),
+		zap.String("anonymizer", policy.Anonymizer),
+		zap.Strings("sensitiveDimensions", policy.SensitiveDimensions))
+	return policy, nil
+}
+
+// reservePrivacyBudget 为匿名化分析预留隐私预算，预算耗尽且策略为拒绝时返回拒绝响应
+func (p *AiFetchProcessor) reservePrivacyBudget(ctx context.Context, userId, modelName string, epsilon float64, toolName string, params interface{}, logCtx *ExecutionLogContext, toolCallStartTime time.Time) (*PrivacyBudgetGrant, *sugarRes.SugarFormulaAiResponse) {
+	budget, err := p.privacyBudget.ReserveBudget(ctx, userId, modelName, epsilon)
+	if err != nil {
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallError(ctx, logCtx, toolName, params, "隐私预算检查失败: "+err.Error(), toolCallStartTime)
+		}
+		return nil, sugarRes.NewAiErrorResponse("隐私预算检查失败: " + err.Error())
+	}
+	if budget.Refused() {
+		if logCtx != nil {
+			p.executionLogger.RecordToolCallError(ctx, logCtx, toolName, params, budget.Warning(), toolCallStartTime)
+		}
+		result := sugarRes.NewAiErrorResponse(budget.Warning())
+		result.PrivacyBudget = budget.Status
+		return nil, result
+	}
+	return budget, nil
+}
+
+// attachPrivacyBudget 将隐私预算状态写入响应元数据，预算不足时附加提示；未使用隐私预算时不做处理
+func attachPrivacyBudget(result *sugarRes.SugarFormulaAiResponse, budget *PrivacyBudgetGrant) {
+	if budget == nil {
+		return
+	}
+	result.PrivacyBudget = budget.Status
+	if warning := budget.Warning(); warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+}
+
+// persistAnonymizationSession 加密保存匿名化会话并关联到执行日志，保存失败不影响本次分析
+func (p *AiFetchProcessor) persistAnonymizationSession(ctx context.Context, inv *aiFetchInvocation, userId, sessionId string, session *anonymization_lite.LiteAnonymizationSession) {
+	var logId int64
+	if inv.logCtx != nil {
+		logId = inv.logCtx.LogID
+	}
+	var agentId *string
+	if inv.agent != nil {
+		agentId = inv.agent.Id
+	}
+	if _, err := p.anonymizationSessions.SaveSession(ctx, sessionId, inv.req.ConversationId, userId, agentId, session, logId); err != nil {
+		global.GVA_LOG.Error("持久化匿名化会话失败", zap.Int64("logId", logId), zap.Error(err))
+	}
+}
+
+// performStructuredAnalysis 在匿名化数据上执行结构化输出分析，并使用会话解码表格中的文本单元格
+func (p *AiFetchProcessor) performStructuredAnalysis(ctx context.Context, aiDataText string, req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents, llmConfig *system.LLMConfig, outputSchema *sugarReq.AiOutputSchema, logCtx *ExecutionLogContext, guard PromptGuard, decode func(string) (string, error)) (*sugarRes.SugarFormulaAiResponse, error) {
+	messages := p.aiInteractionManager.BuildAnalysisMessages(aiDataText, req.Description, agent)
+	messages[0].Content += p.structuredOutput.BuildInstruction(outputSchema)
+	if guard != nil {
+		guarded, err := guard(messages)
+		if err != nil {
+			return nil, err
+		}
+		messages = guarded
+	}
+
+	structured, err := p.structuredOutput.Generate(ctx, llmConfig, messages, outputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("AI结构化分析失败: %w", err)
+	}
+
+	// 记录解码前的原始输出，便于审计
+	if logCtx != nil {
+		p.executionLogger.RecordAnonymizationOutput(ctx, logCtx, structured.Raw)
+	}
+
+	if err := p.structuredOutput.DecodeCells(structured.Table, decode); err != nil {
+		return nil, fmt.Errorf("AI结果解密失败: %w", err)
+	}
+
+	result := sugarRes.NewAiSuccessResponseWithData(structured.Table)
+	result.Warnings = structured.Warnings
+	return result, nil
+}
+
+// buildTextResponse 构建模型直接回答（未调用工具）时的响应，配置了结构化输出时将文本转换为表格
+func (p *AiFetchProcessor) buildTextResponse(ctx context.Context, text string, req *sugarReq.SugarFormulaAiFetchRequest, llmConfig *system.LLMConfig, outputSchema *sugarReq.AiOutputSchema) *sugarRes.SugarFormulaAiResponse {
+	if outputSchema == nil {
+		return sugarRes.NewAiSuccessResponseWithText(text)
+	}
+
+	structured, err := p.structuredOutput.StructureText(ctx, llmConfig, req.Description, text, outputSchema)
+	if err != nil {
+		global.GVA_LOG.Warn("文本结果转换为结构化输出失败", zap.Error(err))
+		return sugarRes.NewAiErrorResponse(err.Error())
+	}
+
+	result := sugarRes.NewAiSuccessResponseWithBoth(structured.Table, text)
+	result.Warnings = structured.Warnings
+	return result
+}
+
+// summarizeResult 生成写入执行日志的结果摘要，结构化结果序列化为JSON
+func (p *AiFetchProcessor) summarizeResult(result *sugarRes.SugarFormulaAiResponse) string {
+	if len(result.Result) == 0 {
+		return result.Text
+	}
+	encoded, err := json.Marshal(result.Result)
+	if err != nil {
+		return result.Text
+	}
+	return string(encoded)
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
 	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
 	"go.uber.org/zap"
 )
@@ -24,39 +25,20 @@ func NewAIInteractionManager() *AIInteractionManager {
 	}
 }
 
-// BuildSystemPrompt 构建智能系统提示词
-func (aim *AIInteractionManager) BuildSystemPrompt(agent *sugar.SugarAgents, userId string) string {
-	// 构建工具调用的系统提示词（不包含具体的分析提示词）
-	enhancedPrompt := fmt.Sprintf(`你是一个专业的数据分析助手，专门负责调用数据分析工具。
-
-📋 重要工作流程指导：
-1. **使用智能匿名化分析工具**：对于贡献度分析需求，请使用 smart_anonymized_analyzer 工具，它会自动完成数据验证和匿名化分析的完整流程
-2. **精确匹配原则**：生成的筛选条件必须与用户问题中的具体实体对应，避免过于宽泛或不存在的条件
-3. **语义顺序原则**：调用工具时，groupByDimensions参数中的维度必须按照语义逻辑顺序排列（从大到小、从主要到次要），这样有利于后续匿名化还原时保持语句通顺性
-4. **数据验证策略**：工具会自动验证数据可用性，如果数据不足会给出明确提示
-5. **结果可信度评估**：基于实际数据的完整性和代表性评估结论的可信度
-
-🔧 工具使用指南：
-- **推荐工具**：smart_anonymized_analyzer - 完整的智能匿名化分析流程
-- **备用工具**：data_scope_explorer（仅数据探索）、anonymized_data_analyzer（传统匿名化）
-- 当前用户ID为 %s，调用工具时必须传递此用户ID
-- 启用数据验证（enableDataValidation: true）以确保数据质量
-- **维度排序重要示例**：
-	 * 货币资金分析：['银行名称', '账户类型', '币种']
-	 * 固定资产分析：['使用部门', '资产类型', '折旧年限'] （部门→资产→属性的逻辑顺序）
-	 * 销售分析：['地区', '产品类别', '销售渠道']
-
-💡 智能分析策略：
-- 优先分析数据中贡献度最高的维度组合
-- 对异常值和趋势变化提供深入洞察
-- 结合业务常识给出可操作的建议
-- 明确说明分析的局限性和数据范围`, userId)
-
-	return enhancedPrompt
+// BuildSystemPrompt 构建智能系统提示词（渲染Agent声明的模板，未声明时使用内置模板）
+func (aim *AIInteractionManager) BuildSystemPrompt(def *AgentDefinition, promptCtx *AgentPromptContext) (string, error) {
+	systemPrompt, err := def.RenderSystemPrompt(promptCtx)
+	if err != nil {
+		return "", err
+	}
+	global.GVA_LOG.Debug("构建工具调用系统提示词完成",
+		zap.Strings("tools", def.Tools),
+		zap.Int("promptLength", len(systemPrompt)))
+	return systemPrompt, nil
 }
 
 // BuildAnalysisSystemPrompt 构建数据分析的系统提示词（包含Agent配置的提示词）
-func (aim *AIInteractionManager) BuildAnalysisSystemPrompt(agent *sugar.SugarAgents, userId string) string {
+func (aim *AIInteractionManager) BuildAnalysisSystemPrompt(agent *sugar.SugarAgents) string {
 	// 基础提示词优先使用Agent中定义的Prompt字段
 	basePrompt := ""
 	if agent != nil && agent.Prompt != nil && *agent.Prompt != "" {
@@ -122,49 +104,11 @@ func (aim *AIInteractionManager) BuildUserMessage(description string, semantic *
 	return message
 }
 
-// CallLLMWithTools 调用LLM并传入工具定义
-func (aim *AIInteractionManager) CallLLMWithTools(ctx context.Context, llmConfig *system.LLMConfig, systemPrompt, userMessage string) (string, error) {
-	// 准备工具定义（智能匿名化数据分析工具）
-	tools := []system.ToolDefinition{
-		{
-			Name:        "smart_anonymized_analyzer",
-			Description: "智能匿名化数据分析工具，自动进行数据范围探索和匿名化分析的完整流程。该工具会先验证数据可用性，然后进行匿名化贡献度分析，确保数据安全和分析准确性。调用时请确保维度按语义逻辑顺序排列。",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"modelName": map[string]interface{}{
-						"type":        "string",
-						"description": "要分析的语义模型名称。",
-					},
-					"targetMetric": map[string]interface{}{
-						"type":        "string",
-						"description": "需要分析的核心指标列名，例如 '销售金额'、'利润' 等。",
-					},
-					"currentPeriodFilters": map[string]interface{}{
-						"type":        "object",
-						"description": "获取本期数据的筛选条件，格式为 {\"列名\": \"筛选值\"}。",
-					},
-					"basePeriodFilters": map[string]interface{}{
-						"type":        "object",
-						"description": "获取基期（如上期、预算）数据的筛选条件，格式为 {\"列名\": \"筛选值\"}。",
-					},
-					"groupByDimensions": map[string]interface{}{
-						"type":        "array",
-						"items":       map[string]string{"type": "string"},
-						"description": "进行分组和归因分析的维度列名列表，如 ['区域', '产品类别']。**重要：请按照业务逻辑的语义顺序排列维度，这样有利于后续匿名化还原时保持语句的通顺性。常见排序原则：组织机构→业务分类→具体属性。示例：固定资产分析应按 ['使用部门', '资产类型', '折旧年限'] 顺序（部门→资产→属性），货币资金分析应按 ['银行名称', '账户类型', '币种'] 顺序。**",
-					},
-					"userId": map[string]interface{}{
-						"type":        "string",
-						"description": "发起请求的用户ID，工具内部需要此参数进行鉴权。",
-					},
-					"enableDataValidation": map[string]interface{}{
-						"type":        "boolean",
-						"description": "是否启用数据范围验证，默认为true。启用后会先验证筛选条件的有效性。",
-					},
-				},
-				"required": []string{"modelName", "targetMetric", "currentPeriodFilters", "basePeriodFilters", "groupByDimensions", "userId"},
-			},
-		},
+// CallLLMWithTools 调用LLM并传入Agent允许使用的工具定义
+func (aim *AIInteractionManager) CallLLMWithTools(ctx context.Context, llmConfig *system.LLMConfig, systemPrompt, userMessage string, toolNames []string) (string, error) {
+	tools, err := aim.ToolDefinitions(toolNames)
+	if err != nil {
+		return "", err
 	}
 
 	// 构建消息列表
@@ -191,24 +135,23 @@ func (aim *AIInteractionManager) CallLLMWithTools(ctx context.Context, llmConfig
 	return llmResponse, nil
 }
 
+// PromptGuard 在消息发送给LLM之前检查并处理其内容，返回错误时取消本次调用
+type PromptGuard func(messages []system.ChatMessage) ([]system.ChatMessage, error)
+
 // PerformDataAnalysis 对获取的数据进行AI分析（上下文感知版本）
-func (aim *AIInteractionManager) PerformDataAnalysis(ctx context.Context, dataText string, userDescription string, agent *sugar.SugarAgents, llmConfig *system.LLMConfig) (string, error) {
+// guard 非空时，消息在发送前先经过检查
+func (aim *AIInteractionManager) PerformDataAnalysis(ctx context.Context, dataText string, userDescription string, agent *sugar.SugarAgents, llmConfig *system.LLMConfig, guard PromptGuard) (string, error) {
 	global.GVA_LOG.Info("开始执行上下文感知数据分析",
 		zap.String("userDescription", userDescription),
 		zap.String("dataLength", fmt.Sprintf("%d", len(dataText))))
 
-	// 使用专门的分析提示词（包含Agent配置的Prompt字段）
-	systemPrompt := aim.BuildAnalysisSystemPrompt(agent, "")
-	global.GVA_LOG.Debug("构建分析系统提示词", zap.String("systemPrompt", systemPrompt))
-
-	// 构建完整的用户消息，包含用户的原始提示词和匿名化数据
-	userMessage := aim.buildCompleteAnalysisMessage(dataText, userDescription, agent)
-	global.GVA_LOG.Debug("构建完整分析用户消息", zap.String("userMessage", userMessage))
-
-	// 构建消息列表
-	messages := []system.ChatMessage{
-		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: userMessage},
+	messages := aim.BuildAnalysisMessages(dataText, userDescription, agent)
+	if guard != nil {
+		guarded, err := guard(messages)
+		if err != nil {
+			return "", err
+		}
+		messages = guarded
 	}
 
 	// 调用LLM进行分析
@@ -229,6 +172,22 @@ func (aim *AIInteractionManager) PerformDataAnalysis(ctx context.Context, dataTe
 	return response, nil
 }
 
+// BuildAnalysisMessages 构建数据分析的消息列表（系统提示词 + 包含用户需求和匿名化数据的用户消息）
+func (aim *AIInteractionManager) BuildAnalysisMessages(dataText string, userDescription string, agent *sugar.SugarAgents) []system.ChatMessage {
+	// 使用专门的分析提示词（包含Agent配置的Prompt字段）
+	systemPrompt := aim.BuildAnalysisSystemPrompt(agent)
+	global.GVA_LOG.Debug("构建分析系统提示词", zap.String("systemPrompt", systemPrompt))
+
+	// 构建完整的用户消息，包含用户的原始提示词和匿名化数据
+	userMessage := aim.buildCompleteAnalysisMessage(dataText, userDescription, agent)
+	global.GVA_LOG.Debug("构建完整分析用户消息", zap.String("userMessage", userMessage))
+
+	return []system.ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userMessage},
+	}
+}
+
 // ParseToolCallResponse 解析工具调用响应
 func (aim *AIInteractionManager) ParseToolCallResponse(llmResponse string) (*ToolCallResponse, error) {
 	var toolCallResp ToolCallResponse
@@ -260,7 +219,6 @@ func (aim *AIInteractionManager) ParseSmartAnalyzerParams(arguments string) (*Sm
 	currentPeriodFilters, _ := args["currentPeriodFilters"].(map[string]interface{})
 	basePeriodFilters, _ := args["basePeriodFilters"].(map[string]interface{})
 	groupByDimensionsInterface, _ := args["groupByDimensions"].([]interface{})
-	userId, _ := args["userId"].(string)
 	enableDataValidation, _ := args["enableDataValidation"].(bool)
 
 	// 默认启用数据验证
@@ -275,17 +233,42 @@ func (aim *AIInteractionManager) ParseSmartAnalyzerParams(arguments string) (*Sm
 		}
 	}
 
+	timeIntelligence, err := aim.parseTimeIntelligenceArg(args)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SmartAnalyzerParams{
 		ModelName:            modelName,
 		TargetMetric:         targetMetric,
 		CurrentPeriodFilters: currentPeriodFilters,
 		BasePeriodFilters:    basePeriodFilters,
 		GroupByDimensions:    groupByDimensions,
-		UserId:               userId,
 		EnableDataValidation: enableDataValidation,
+		TimeIntelligence:     timeIntelligence,
 	}, nil
 }
 
+// parseTimeIntelligenceArg 解析工具参数中可选的时间智能函数
+func (aim *AIInteractionManager) parseTimeIntelligenceArg(args map[string]interface{}) (*sugarReq.TimeIntelligence, error) {
+	raw, ok := args["timeIntelligence"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("解析时间智能参数失败: %w", err)
+	}
+	var timeIntelligence sugarReq.TimeIntelligence
+	if err := json.Unmarshal(data, &timeIntelligence); err != nil {
+		return nil, fmt.Errorf("解析时间智能参数失败: %w", err)
+	}
+	if timeIntelligence.Function == "" {
+		return nil, nil
+	}
+	return &timeIntelligence, nil
+}
+
 // ParseDataScopeParams 解析数据范围探索工具参数
 func (aim *AIInteractionManager) ParseDataScopeParams(arguments string) (*DataScopeParams, error) {
 	var args map[string]interface{}
@@ -297,7 +280,6 @@ func (aim *AIInteractionManager) ParseDataScopeParams(arguments string) (*DataSc
 	modelName, _ := args["modelName"].(string)
 	exploreDimensionsInterface, _ := args["exploreDimensions"].([]interface{})
 	sampleFilters, _ := args["sampleFilters"].(map[string]interface{})
-	userId, _ := args["userId"].(string)
 
 	var exploreDimensions []string
 	for _, item := range exploreDimensionsInterface {
@@ -310,7 +292,6 @@ func (aim *AIInteractionManager) ParseDataScopeParams(arguments string) (*DataSc
 		ModelName:         modelName,
 		ExploreDimensions: exploreDimensions,
 		SampleFilters:     sampleFilters,
-		UserId:            userId,
 	}, nil
 }
 
@@ -327,7 +308,6 @@ func (aim *AIInteractionManager) ParseAnonymizedAnalyzerParams(arguments string)
 	currentPeriodFilters, _ := args["currentPeriodFilters"].(map[string]interface{})
 	basePeriodFilters, _ := args["basePeriodFilters"].(map[string]interface{})
 	groupByDimensionsInterface, _ := args["groupByDimensions"].([]interface{})
-	userId, _ := args["userId"].(string)
 
 	var groupByDimensions []string
 	for _, item := range groupByDimensionsInterface {
@@ -336,13 +316,18 @@ func (aim *AIInteractionManager) ParseAnonymizedAnalyzerParams(arguments string)
 		}
 	}
 
+	timeIntelligence, err := aim.parseTimeIntelligenceArg(args)
+	if err != nil {
+		return nil, err
+	}
+
 	return &AnonymizedAnalyzerParams{
 		ModelName:            modelName,
 		TargetMetric:         targetMetric,
 		CurrentPeriodFilters: currentPeriodFilters,
 		BasePeriodFilters:    basePeriodFilters,
 		GroupByDimensions:    groupByDimensions,
-		UserId:               userId,
+		TimeIntelligence:     timeIntelligence,
 	}, nil
 }
 
@@ -387,6 +372,11 @@ func (aim *AIInteractionManager) getSemanticModelInfo(semantic string) (string,
 				builder.WriteString(fmt.Sprintf("可返回字段:\n%s", columnInfo))
 			}
 		}
+		if semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics); err != nil {
+			global.GVA_LOG.Warn("解析分析口径失败", zap.Error(err))
+		} else if description := semantics.Describe(""); description != "" {
+			builder.WriteString(fmt.Sprintf("分析口径:\n%s\n", description))
+		}
 	}
 	return builder.String(), nil
 }
@@ -557,8 +547,9 @@ type SmartAnalyzerParams struct {
 	CurrentPeriodFilters map[string]interface{} `json:"currentPeriodFilters"`
 	BasePeriodFilters    map[string]interface{} `json:"basePeriodFilters"`
 	GroupByDimensions    []string               `json:"groupByDimensions"`
-	UserId               string                 `json:"userId"`
 	EnableDataValidation bool                   `json:"enableDataValidation"`
+
+	TimeIntelligence *sugarReq.TimeIntelligence `json:"timeIntelligence,omitempty"` // 提供后由服务端计算本期与基期的期间条件
 }
 
 // DataScopeParams 数据范围探索工具参数
@@ -566,7 +557,6 @@ type DataScopeParams struct {
 	ModelName         string                 `json:"modelName"`
 	ExploreDimensions []string               `json:"exploreDimensions"`
 	SampleFilters     map[string]interface{} `json:"sampleFilters"`
-	UserId            string                 `json:"userId"`
 }
 
 // AnonymizedAnalyzerParams 匿名化数据分析工具参数
@@ -576,5 +566,6 @@ type AnonymizedAnalyzerParams struct {
 	CurrentPeriodFilters map[string]interface{} `json:"currentPeriodFilters"`
 	BasePeriodFilters    map[string]interface{} `json:"basePeriodFilters"`
 	GroupByDimensions    []string               `json:"groupByDimensions"`
-	UserId               string                 `json:"userId"`
+
+	TimeIntelligence *sugarReq.TimeIntelligence `json:"timeIntelligence,omitempty"` // 提供后由服务端计算本期与基期的期间条件
 }
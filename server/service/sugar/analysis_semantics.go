@@ -0,0 +1,340 @@
+package sugar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 期间粒度
+const (
+	PeriodGranularityDay     = "day"
+	PeriodGranularityWeek    = "week"
+	PeriodGranularityMonth   = "month"
+	PeriodGranularityQuarter = "quarter"
+	PeriodGranularityYear    = "year"
+)
+
+// 指标类型
+const (
+	// MetricKindFlow 期间发生额（如销售额、费用），跨期间可累加
+	MetricKindFlow = "flow"
+	// MetricKindBalance 时点余额（如货币资金、存货），跨期间不可累加，取期末时点值
+	MetricKindBalance = "balance"
+)
+
+var periodGranularityNames = map[string]string{
+	PeriodGranularityDay:     "日",
+	PeriodGranularityWeek:    "周",
+	PeriodGranularityMonth:   "月",
+	PeriodGranularityQuarter: "季度",
+	PeriodGranularityYear:    "年",
+}
+
+// AnalysisSemantics 语义模型声明的分析口径
+// 例如：
+//
+//	{
+//	  "periodColumn": "月份",
+//	  "granularity": "month",
+//	  "dateColumn": "业务日期",
+//	  "dateFormat": "2006-01-02",
+//	  "metrics": {"销售额": "flow", "库存余额": "balance"},
+//	  "balancePairs": [{"metric": "货币资金", "opening": "年初金额", "closing": "年末金额"}],
+//	  "fiscalCalendar": {"startMonth": 4}
+//	}
+type AnalysisSemantics struct {
+	PeriodColumn   string            `json:"periodColumn,omitempty"`   // 期间维度（可返回字段名）
+	Granularity    string            `json:"granularity,omitempty"`    // 期间粒度：day/week/month/quarter/year
+	DateColumn     string            `json:"dateColumn,omitempty"`     // 时间智能使用的日期字段（可返回字段名），未声明时使用期间维度
+	DateFormat     string            `json:"dateFormat,omitempty"`     // 日期字段的 Go 时间格式，需按年月日从大到小排列，默认 2006-01-02
+	Metrics        map[string]string `json:"metrics,omitempty"`        // 指标类型：flow 期间发生额 / balance 时点余额，未声明的指标按 flow 处理
+	BalancePairs   []BalancePair     `json:"balancePairs,omitempty"`   // 期初/期末指标对，分析时以期末对比期初
+	FiscalCalendar *FiscalCalendar   `json:"fiscalCalendar,omitempty"` // 财年口径，未声明时为自然年
+}
+
+// BalancePair 期初/期末指标对
+type BalancePair struct {
+	Metric  string `json:"metric,omitempty"` // 分析时使用的指标名，未填写时使用期末指标名
+	Opening string `json:"opening"`          // 期初指标，如"年初金额"
+	Closing string `json:"closing"`          // 期末指标，如"年末金额"
+}
+
+// FiscalCalendar 财年口径
+type FiscalCalendar struct {
+	StartMonth int `json:"startMonth"` // 财年起始月份（1-12）
+}
+
+// ParseAnalysisSemantics 解析语义模型上的分析口径声明，未声明时返回空口径
+func ParseAnalysisSemantics(raw datatypes.JSON) (*AnalysisSemantics, error) {
+	semantics := &AnalysisSemantics{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return semantics, nil
+	}
+	if err := json.Unmarshal(raw, semantics); err != nil {
+		return nil, fmt.Errorf("分析口径配置格式错误: %w", err)
+	}
+	if err := semantics.Validate(); err != nil {
+		return nil, err
+	}
+	return semantics, nil
+}
+
+// Validate 校验分析口径声明本身的合法性
+func (s *AnalysisSemantics) Validate() error {
+	if s.Granularity != "" {
+		if _, ok := periodGranularityNames[s.Granularity]; !ok {
+			return fmt.Errorf("分析口径 granularity 只能为 day/week/month/quarter/year: %s", s.Granularity)
+		}
+		if s.PeriodColumn == "" {
+			return errors.New("分析口径声明了 granularity 时必须同时声明 periodColumn")
+		}
+	}
+	if s.DateFormat != "" {
+		if s.DateColumn == "" {
+			return errors.New("分析口径声明了 dateFormat 时必须同时声明 dateColumn")
+		}
+		if !strings.HasPrefix(s.DateFormat, "2006") {
+			return fmt.Errorf("分析口径 dateFormat 必须以年份开头（如 2006-01-02、2006-01），以便按字符串比较日期: %s", s.DateFormat)
+		}
+	}
+	for metric, kind := range s.Metrics {
+		if kind != MetricKindFlow && kind != MetricKindBalance {
+			return fmt.Errorf("分析口径中指标 %s 的类型只能为 flow 或 balance: %s", metric, kind)
+		}
+	}
+	names := make(map[string]bool)
+	for i, pair := range s.BalancePairs {
+		if pair.Opening == "" || pair.Closing == "" {
+			return fmt.Errorf("分析口径 balancePairs[%d] 必须同时声明 opening 和 closing", i)
+		}
+		if pair.Opening == pair.Closing {
+			return fmt.Errorf("分析口径 balancePairs[%d] 的 opening 和 closing 不能相同", i)
+		}
+		name := pair.MetricName()
+		if names[name] {
+			return fmt.Errorf("分析口径 balancePairs 中指标重复: %s", name)
+		}
+		names[name] = true
+	}
+	if s.FiscalCalendar != nil && (s.FiscalCalendar.StartMonth < 1 || s.FiscalCalendar.StartMonth > 12) {
+		return fmt.Errorf("分析口径 fiscalCalendar.startMonth 必须在 1-12 之间: %d", s.FiscalCalendar.StartMonth)
+	}
+	return nil
+}
+
+// ValidateAnalysisSemantics 校验语义模型的分析口径，并检查引用的字段均为模型的可返回字段
+func ValidateAnalysisSemantics(model *sugar.SugarSemanticModels) error {
+	semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+	if err != nil {
+		return err
+	}
+	var columns map[string]map[string]interface{}
+	if len(model.ReturnableColumnsConfig) == 0 || json.Unmarshal(model.ReturnableColumnsConfig, &columns) != nil {
+		return nil
+	}
+	for _, name := range semantics.referencedColumns() {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("分析口径引用的字段不在可返回字段中: %s", name)
+		}
+	}
+	return nil
+}
+
+// referencedColumns 分析口径引用的全部可返回字段（期初/期末指标对的分析指标名不要求存在）
+func (s *AnalysisSemantics) referencedColumns() []string {
+	var names []string
+	if s.PeriodColumn != "" {
+		names = append(names, s.PeriodColumn)
+	}
+	if s.DateColumn != "" && s.DateColumn != s.PeriodColumn {
+		names = append(names, s.DateColumn)
+	}
+	for metric := range s.Metrics {
+		names = append(names, metric)
+	}
+	for _, pair := range s.BalancePairs {
+		names = append(names, pair.Opening, pair.Closing)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// legacyBalancePairs 旧版本按字段名自动识别为期初/期末对比的字段
+var legacyBalancePairs = []BalancePair{
+	{Opening: "年初金额", Closing: "年末金额"},
+	{Opening: "beginning_balance", Closing: "ending_balance"},
+}
+
+// BackfillBalancePairs 为包含年初/年末金额字段但未声明期初/期末指标对的语义模型补充 balancePairs，返回补充的模型数
+// 旧版本按字段名自动识别这类模型，改为显式声明后未补充的模型会按期间发生额对比，结果不再是期末对比期初
+func BackfillBalancePairs(db *gorm.DB) (int, error) {
+	var models []sugar.SugarSemanticModels
+	if err := db.Select("id", "name", "returnable_columns_config", "analysis_semantics").Find(&models).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, model := range models {
+		var columns map[string]interface{}
+		if len(model.ReturnableColumnsConfig) == 0 || json.Unmarshal(model.ReturnableColumnsConfig, &columns) != nil {
+			continue
+		}
+		semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+		if err != nil {
+			global.GVA_LOG.Warn("语义模型的分析口径无效，无法补充期初/期末指标对，期初/期末对比将按期间发生额计算",
+				zap.String("model", safeDeref(model.Name)), zap.Error(err))
+			continue
+		}
+		added := false
+		for _, pair := range legacyBalancePairs {
+			_, hasOpening := columns[pair.Opening]
+			_, hasClosing := columns[pair.Closing]
+			if hasOpening && hasClosing && semantics.BalancePairFor(pair.Opening) == nil && semantics.BalancePairFor(pair.Closing) == nil {
+				semantics.BalancePairs = append(semantics.BalancePairs, pair)
+				added = true
+			}
+		}
+		if !added {
+			continue
+		}
+		if err = semantics.Validate(); err != nil {
+			global.GVA_LOG.Warn("补充期初/期末指标对后分析口径无效，请手动声明 balancePairs",
+				zap.String("model", safeDeref(model.Name)), zap.Error(err))
+			continue
+		}
+		raw, err := json.Marshal(semantics)
+		if err != nil {
+			return updated, err
+		}
+		if err = db.Model(&sugar.SugarSemanticModels{}).Where("id = ?", *model.Id).Update("analysis_semantics", datatypes.JSON(raw)).Error; err != nil {
+			return updated, err
+		}
+		updated++
+		global.GVA_LOG.Warn("已按年初/年末金额字段为语义模型补充期初/期末指标对，请确认分析口径",
+			zap.String("model", safeDeref(model.Name)), zap.String("analysisSemantics", string(raw)))
+	}
+	return updated, nil
+}
+
+// MetricName 指标对在分析中使用的指标名
+func (p BalancePair) MetricName() string {
+	if p.Metric != "" {
+		return p.Metric
+	}
+	return p.Closing
+}
+
+// BalancePairFor 返回目标指标对应的期初/期末指标对，目标指标可以是分析指标名、期初或期末指标
+func (s *AnalysisSemantics) BalancePairFor(targetMetric string) *BalancePair {
+	if s == nil || targetMetric == "" {
+		return nil
+	}
+	for i := range s.BalancePairs {
+		pair := &s.BalancePairs[i]
+		if targetMetric == pair.MetricName() || targetMetric == pair.Opening || targetMetric == pair.Closing {
+			return pair
+		}
+	}
+	return nil
+}
+
+// MetricKind 返回指标类型，期初/期末指标对中的指标为时点余额，未声明的指标为期间发生额
+func (s *AnalysisSemantics) MetricKind(metric string) string {
+	if s == nil {
+		return MetricKindFlow
+	}
+	if kind, ok := s.Metrics[metric]; ok {
+		return kind
+	}
+	if s.BalancePairFor(metric) != nil {
+		return MetricKindBalance
+	}
+	return MetricKindFlow
+}
+
+// FiscalStartMonth 财年起始月份，未声明时为 1（自然年）
+func (s *AnalysisSemantics) FiscalStartMonth() int {
+	if s == nil || s.FiscalCalendar == nil {
+		return 1
+	}
+	return s.FiscalCalendar.StartMonth
+}
+
+// periodColumnLayouts 未声明日期字段时，期间维度按粒度对应的格式参与时间智能计算
+var periodColumnLayouts = map[string]string{
+	PeriodGranularityDay:   "2006-01-02",
+	PeriodGranularityMonth: "2006-01",
+	PeriodGranularityYear:  "2006",
+}
+
+// TimeColumn 返回时间智能使用的日期字段及其格式
+// 优先使用 dateColumn；未声明时使用按日/月/年粒度的期间维度
+func (s *AnalysisSemantics) TimeColumn() (string, string, error) {
+	if s != nil && s.DateColumn != "" {
+		if s.DateFormat != "" {
+			return s.DateColumn, s.DateFormat, nil
+		}
+		return s.DateColumn, "2006-01-02", nil
+	}
+	if s != nil && s.PeriodColumn != "" {
+		if layout, ok := periodColumnLayouts[s.Granularity]; ok {
+			return s.PeriodColumn, layout, nil
+		}
+	}
+	return "", "", errors.New("语义模型未声明日期字段（分析口径 dateColumn，或按 day/month/year 粒度的 periodColumn），无法使用时间智能函数")
+}
+
+// IsEmpty 是否未声明任何分析口径
+func (s *AnalysisSemantics) IsEmpty() bool {
+	return s == nil || (s.PeriodColumn == "" && s.DateColumn == "" && len(s.Metrics) == 0 && len(s.BalancePairs) == 0 && s.FiscalCalendar == nil)
+}
+
+// Describe 生成分析口径的中文说明，供提示词和模型描述使用；targetMetric 非空时只说明该指标的口径
+func (s *AnalysisSemantics) Describe(targetMetric string) string {
+	if s.IsEmpty() {
+		return ""
+	}
+	var lines []string
+	if s.PeriodColumn != "" {
+		line := "期间维度：" + s.PeriodColumn
+		if name, ok := periodGranularityNames[s.Granularity]; ok {
+			line += "（按" + name + "）"
+		}
+		lines = append(lines, line)
+	}
+	if s.DateColumn != "" && s.DateColumn != s.PeriodColumn {
+		lines = append(lines, "日期字段："+s.DateColumn)
+	}
+	if s.FiscalCalendar != nil && s.FiscalCalendar.StartMonth != 1 {
+		lines = append(lines, fmt.Sprintf("财年口径：每年%d月开始", s.FiscalCalendar.StartMonth))
+	}
+
+	metrics := make([]string, 0, len(s.Metrics))
+	for metric := range s.Metrics {
+		if targetMetric == "" || metric == targetMetric {
+			metrics = append(metrics, metric)
+		}
+	}
+	sort.Strings(metrics)
+	for _, metric := range metrics {
+		if s.Metrics[metric] == MetricKindBalance {
+			lines = append(lines, fmt.Sprintf("%s：时点余额，跨期间不可累加", metric))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s：期间发生额，可跨期间累加", metric))
+		}
+	}
+	for _, pair := range s.BalancePairs {
+		if targetMetric == "" || targetMetric == pair.MetricName() || targetMetric == pair.Opening || targetMetric == pair.Closing {
+			lines = append(lines, fmt.Sprintf("%s：期末对比期初，本期值=%s，基期值=%s", pair.MetricName(), pair.Closing, pair.Opening))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,136 @@
+package sugar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"gorm.io/datatypes"
+)
+
+func TestParseAnalysisSemantics(t *testing.T) {
+	semantics, err := ParseAnalysisSemantics(datatypes.JSON(`{
+		"periodColumn": "月份", "granularity": "month",
+		"metrics": {"销售额": "flow", "库存余额": "balance"},
+		"balancePairs": [{"metric": "货币资金", "opening": "年初金额", "closing": "年末金额"}, {"opening": "期初数", "closing": "期末数"}],
+		"fiscalCalendar": {"startMonth": 4}
+	}`))
+	if err != nil {
+		t.Fatalf("解析分析口径失败: %v", err)
+	}
+	if semantics.FiscalStartMonth() != 4 || semantics.IsEmpty() {
+		t.Fatalf("分析口径解析错误: %+v", semantics)
+	}
+	for metric, kind := range map[string]string{"销售额": MetricKindFlow, "库存余额": MetricKindBalance, "年末金额": MetricKindBalance, "期初数": MetricKindBalance, "利润": MetricKindFlow} {
+		if got := semantics.MetricKind(metric); got != kind {
+			t.Errorf("MetricKind(%s) = %s，期望 %s", metric, got, kind)
+		}
+	}
+
+	// 未声明时返回空口径
+	for _, raw := range []string{"", "null"} {
+		if semantics, err = ParseAnalysisSemantics(datatypes.JSON(raw)); err != nil || !semantics.IsEmpty() || semantics.FiscalStartMonth() != 1 {
+			t.Fatalf("未声明的分析口径应为空: %+v %v", semantics, err)
+		}
+	}
+
+	invalid := []string{
+		`{"periodColumn": "月份", "granularity": "hour"}`,
+		`{"granularity": "month"}`,
+		`{"dateFormat": "2006-01-02"}`,
+		`{"dateColumn": "日期", "dateFormat": "01/02/2006"}`,
+		`{"metrics": {"销售额": "stock"}}`,
+		`{"balancePairs": [{"opening": "年初金额"}]}`,
+		`{"balancePairs": [{"opening": "年末金额", "closing": "年末金额"}]}`,
+		`{"balancePairs": [{"opening": "a", "closing": "b", "metric": "余额"}, {"opening": "c", "closing": "d", "metric": "余额"}]}`,
+		`{"fiscalCalendar": {"startMonth": 13}}`,
+		`{"metrics": []}`,
+	}
+	for _, raw := range invalid {
+		if _, err := ParseAnalysisSemantics(datatypes.JSON(raw)); err == nil {
+			t.Errorf("无效的分析口径应解析失败: %s", raw)
+		}
+	}
+}
+
+func TestValidateAnalysisSemanticsColumns(t *testing.T) {
+	model := &sugar.SugarSemanticModels{
+		ReturnableColumnsConfig: datatypes.JSON(`{"月份": {}, "年初金额": {}, "年末金额": {}}`),
+		AnalysisSemantics:       datatypes.JSON(`{"periodColumn": "月份", "balancePairs": [{"metric": "货币资金", "opening": "年初金额", "closing": "年末金额"}]}`),
+	}
+	// 指标对的分析指标名不要求是可返回字段
+	if err := ValidateAnalysisSemantics(model); err != nil {
+		t.Fatalf("引用的字段均存在时应校验通过: %v", err)
+	}
+	model.AnalysisSemantics = datatypes.JSON(`{"dateColumn": "日期"}`)
+	if err := ValidateAnalysisSemantics(model); err == nil {
+		t.Fatal("引用不存在的字段时应校验失败")
+	}
+}
+
+func TestBalancePairFor(t *testing.T) {
+	semantics := &AnalysisSemantics{BalancePairs: []BalancePair{
+		{Metric: "货币资金", Opening: "年初金额", Closing: "年末金额"},
+		{Opening: "期初数", Closing: "期末数"},
+	}}
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"货币资金", "货币资金"},
+		{"年初金额", "货币资金"},
+		{"年末金额", "货币资金"},
+		{"期末数", "期末数"},
+		{"期初数", "期末数"},
+		{"销售额", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if pair := semantics.BalancePairFor(tt.target); pair != nil {
+			got = pair.MetricName()
+		}
+		if got != tt.want {
+			t.Errorf("BalancePairFor(%q) = %q，期望 %q", tt.target, got, tt.want)
+		}
+	}
+	var empty *AnalysisSemantics
+	if empty.BalancePairFor("年末金额") != nil {
+		t.Fatal("空口径不应返回指标对")
+	}
+}
+
+func TestBackfillBalancePairs(t *testing.T) {
+	db := setupTestDB(t)
+	seedTestData(t, `INSERT INTO sugar_semantic_models (id, name, team_id, returnable_columns_config, analysis_semantics) VALUES
+		('model-1', '货币资金', 'team-1', '{"年初金额": {}, "年末金额": {}, "科目": {}}', NULL),
+		('model-2', '存货', 'team-1', '{"月份": {}, "beginning_balance": {}, "ending_balance": {}}', '{"periodColumn": "月份", "granularity": "month"}'),
+		('model-3', '应收账款', 'team-1', '{"年初金额": {}, "年末金额": {}}', '{"balancePairs": [{"metric": "应收", "opening": "年初金额", "closing": "年末金额"}]}'),
+		('model-4', '销售', 'team-1', '{"年末金额": {}, "销售额": {}}', NULL),
+		('model-5', '坏数据', 'team-1', '{"年初金额": {}, "年末金额": {}}', '{"granularity": "month"}')`)
+
+	updated, err := BackfillBalancePairs(db)
+	if err != nil || updated != 2 {
+		t.Fatalf("补充的模型数 = %d，期望 2: %v", updated, err)
+	}
+	expect := map[string]*AnalysisSemantics{
+		"model-1": {BalancePairs: []BalancePair{{Opening: "年初金额", Closing: "年末金额"}}},
+		"model-2": {PeriodColumn: "月份", Granularity: PeriodGranularityMonth, BalancePairs: []BalancePair{{Opening: "beginning_balance", Closing: "ending_balance"}}},
+		"model-3": {BalancePairs: []BalancePair{{Metric: "应收", Opening: "年初金额", Closing: "年末金额"}}},
+		"model-4": {},
+	}
+	for id, want := range expect {
+		var model sugar.SugarSemanticModels
+		global.GVA_DB.Where("id = ?", id).First(&model)
+		got, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s 的分析口径 = %+v，期望 %+v (%v)", id, got, want, err)
+		}
+	}
+
+	// 再次执行不会重复补充
+	if updated, err = BackfillBalancePairs(db); err != nil || updated != 0 {
+		t.Fatalf("重复执行不应再补充: %d %v", updated, err)
+	}
+}
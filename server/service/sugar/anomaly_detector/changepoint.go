@@ -0,0 +1,97 @@
+package anomaly_detector
+
+import (
+	"math"
+	"sort"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+)
+
+// segment 序列区间 [start, end)
+type segment struct {
+	start, end int
+}
+
+// detectChangePoints 二分分割检测均值变点
+// 每轮在所有区间中选取使平方误差下降最多的切分点，下降幅度超过 惩罚系数 × σ² × ln(n) 时接受，直到没有可接受的切分或达到数量上限
+// sigma 为序列噪声的标准差，为0时不检测
+func detectChangePoints(values []float64, sigma float64, config *Config) []ChangePoint {
+	if sigma == 0 {
+		return nil
+	}
+	prefix, prefixSquares := make([]float64, len(values)+1), make([]float64, len(values)+1)
+	for i, v := range values {
+		prefix[i+1] = prefix[i] + v
+		prefixSquares[i+1] = prefixSquares[i] + v*v
+	}
+	cost := func(s segment) float64 {
+		n := float64(s.end - s.start)
+		sum := prefix[s.end] - prefix[s.start]
+		return prefixSquares[s.end] - prefixSquares[s.start] - sum*sum/n
+	}
+	penalty := config.ChangePointPenalty * sigma * sigma * math.Log(float64(len(values)))
+
+	segments := []segment{{0, len(values)}}
+	var splits []int
+	for len(splits) < config.MaxChangePoints {
+		bestGain, bestSegment, bestSplit := penalty, -1, -1
+		for i, s := range segments {
+			whole := cost(s)
+			for k := s.start + config.MinSegmentLength; k <= s.end-config.MinSegmentLength; k++ {
+				if gain := whole - cost(segment{s.start, k}) - cost(segment{k, s.end}); gain > bestGain {
+					bestGain, bestSegment, bestSplit = gain, i, k
+				}
+			}
+		}
+		if bestSegment < 0 {
+			break
+		}
+		s := segments[bestSegment]
+		segments = append(segments[:bestSegment], append([]segment{{s.start, bestSplit}, {bestSplit, s.end}}, segments[bestSegment+1:]...)...)
+		splits = append(splits, bestSplit)
+	}
+	sort.Ints(splits)
+
+	mean := func(s segment) float64 {
+		return (prefix[s.end] - prefix[s.start]) / float64(s.end-s.start)
+	}
+	changePoints := make([]ChangePoint, 0, len(splits))
+	for i, split := range splits {
+		before, after := segment{0, split}, segment{split, len(values)}
+		if i > 0 {
+			before.start = splits[i-1]
+		}
+		if i < len(splits)-1 {
+			after.end = splits[i+1]
+		}
+		shift := mean(after) - mean(before)
+		changePoints = append(changePoints, ChangePoint{
+			Index:      split,
+			BeforeMean: mean(before),
+			AfterMean:  mean(after),
+			Shift:      shift,
+			Score:      shift / sigma,
+		})
+	}
+	return changePoints
+}
+
+// noiseLevel 由一阶差分的 MAD 估计序列噪声的标准差，不受均值跳变和个别异常值影响
+func noiseLevel(values []float64) float64 {
+	diffs := make([]float64, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		diffs[i-1] = values[i] - values[i-1]
+	}
+	median := advanced_contribution_analyzer.Median(diffs)
+	var sum, maxDeviation float64
+	for _, d := range diffs {
+		sum += math.Abs(d - median)
+		maxDeviation = math.Max(maxDeviation, math.Abs(d-median))
+	}
+	sigma := advanced_contribution_analyzer.MedianAbsoluteDeviation(diffs) / 0.6745 / math.Sqrt2
+	if sigma > 1e-9*maxDeviation {
+		return sigma
+	}
+	// 多数差分相同（如阶梯序列）时改用差分的平均绝对偏差
+	return sum / float64(len(diffs)) / 0.7979 / math.Sqrt2
+}
@@ -0,0 +1,173 @@
+package anomaly_detector
+
+import (
+	"math"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+)
+
+const (
+	// seasonalIterations 季节分解中季节项与趋势交替估计的轮数
+	seasonalIterations = 2
+	// minSeasonalCycles 季节分解至少需要的完整周期数，各相位至少3个值时中位数才能抵抗单个异常
+	minSeasonalCycles = 3
+)
+
+// Detect 检测等间隔时间序列中的异常值和均值变点
+// 1. 初步分解：趋势为含当期的滚动中位数，在水平跳变处不滞后，初步异常只用于清洗后续步骤的输入；
+// 2. 对去除季节项、初步异常以趋势值代替的序列做二分分割检测变点；
+// 3. 在变点划分的各段内重新拟合趋势，窗口不含当期本身和初步异常，对残差计算稳健 z 分数判定异常，异常值不会拉偏自己的预期值。
+// 噪声水平由去季节序列的一阶差分估计，同时作为变点惩罚的尺度和残差尺度的下限
+func Detect(values []float64, config *Config) (*Result, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(values) < MinSeriesLength {
+		return nil, ErrSeriesTooShort
+	}
+
+	result := &Result{Method: MethodRobust}
+	if config.SeasonLength >= 2 && len(values) >= minSeasonalCycles*config.SeasonLength {
+		result.Method = MethodSeasonal
+		result.SeasonLength = config.SeasonLength
+	}
+
+	trend, seasonal := decompose(values, result.SeasonLength, config.TrendWindow, nil, nil)
+	deseasonalized := make([]float64, len(values))
+	for i, v := range values {
+		deseasonalized[i] = v - seasonal[i]
+	}
+	sigma := noiseLevel(deseasonalized)
+	preliminary := scorePoints(values, trend, seasonal, sigma, config.Threshold)
+	var breaks []int
+	if config.MaxChangePoints > 0 {
+		result.ChangePoints = detectChangePoints(changePointInput(preliminary, trend, seasonal), sigma, config)
+		for _, changePoint := range result.ChangePoints {
+			breaks = append(breaks, changePoint.Index)
+		}
+	}
+	excluded := make([]bool, len(values))
+	for i, point := range preliminary {
+		excluded[i] = point.IsAnomaly
+	}
+	trend, seasonal = decompose(values, result.SeasonLength, config.TrendWindow, breaks, excluded)
+	result.Points = scorePoints(values, trend, seasonal, sigma, config.Threshold)
+	return result, nil
+}
+
+// decompose 分解出趋势和季节项，seasonLength 为0时季节项全为0；breaks 为变点下标，趋势不跨变点平滑
+// 季节项由一个周期长度窗口的趋势（不含季节波动）交替估计得到，各相位取中位数，个别周期受跳变影响时不改变结果；
+// 趋势在去季节序列上用较短的窗口估计，excluded 见 rollingMedian
+func decompose(values []float64, seasonLength, window int, breaks []int, excluded []bool) ([]float64, []float64) {
+	seasonal := make([]float64, len(values))
+	if seasonLength == 0 {
+		return rollingMedian(values, window, breaks, excluded), seasonal
+	}
+	deseasonalized := append([]float64(nil), values...)
+	for iteration := 0; iteration < seasonalIterations; iteration++ {
+		seasonal = seasonalComponent(values, rollingMedian(deseasonalized, seasonLength, nil, nil), seasonLength)
+		for i, v := range values {
+			deseasonalized[i] = v - seasonal[i]
+		}
+	}
+	return rollingMedian(deseasonalized, window, breaks, excluded), seasonal
+}
+
+// scorePoints 计算各期残差的稳健 z 分数并判定异常
+// 残差尺度不低于序列噪声水平 sigma：周期数较少时季节项会拟合掉部分噪声，仅用残差的 MAD 会低估尺度
+func scorePoints(values, trend, seasonal []float64, sigma, threshold float64) []Point {
+	residuals := make([]float64, len(values))
+	for i, v := range values {
+		residuals[i] = v - trend[i] - seasonal[i]
+	}
+	median, scale := advanced_contribution_analyzer.RobustScale(residuals)
+	scale = math.Max(scale, sigma)
+	points := make([]Point, len(values))
+	for i, v := range values {
+		point := Point{
+			Index:    i,
+			Value:    v,
+			Expected: trend[i] + seasonal[i],
+			Residual: residuals[i],
+		}
+		if scale > 0 {
+			point.Score = (residuals[i] - median) / scale
+			point.IsAnomaly = math.Abs(point.Score) > threshold
+		}
+		points[i] = point
+	}
+	return points
+}
+
+// changePointInput 变点检测使用的序列：去除季节项，异常期以趋势值代替，避免单期尖峰被识别为两个相邻的变点
+func changePointInput(points []Point, trend, seasonal []float64) []float64 {
+	adjusted := make([]float64, len(points))
+	for i, point := range points {
+		adjusted[i] = point.Value - seasonal[i]
+		if point.IsAnomaly {
+			adjusted[i] = trend[i]
+		}
+	}
+	return adjusted
+}
+
+// rollingMedian 居中滚动中位数，窗口为偶数时加1；序列两端和变点两侧使用截断窗口
+// excluded 非空时窗口不含当期本身和 excluded 标记的期，排除后窗口为空时使用完整窗口
+func rollingMedian(values []float64, window int, breaks []int, excluded []bool) []float64 {
+	if window%2 == 0 {
+		window++
+	}
+	half := window / 2
+	trend := make([]float64, len(values))
+	segmentStart := 0
+	for _, segmentEnd := range append(append([]int(nil), breaks...), len(values)) {
+		for i := segmentStart; i < segmentEnd; i++ {
+			start, end := i-half, i+half+1
+			if start < segmentStart {
+				start = segmentStart
+			}
+			if end > segmentEnd {
+				end = segmentEnd
+			}
+			neighbors := values[start:end]
+			if excluded != nil {
+				var kept []float64
+				for j := start; j < end; j++ {
+					if j != i && !excluded[j] {
+						kept = append(kept, values[j])
+					}
+				}
+				if len(kept) > 0 {
+					neighbors = kept
+				}
+			}
+			trend[i] = advanced_contribution_analyzer.Median(neighbors)
+		}
+		segmentStart = segmentEnd
+	}
+	return trend
+}
+
+// seasonalComponent 各相位去趋势值的中位数作为季节项，并中心化使一个周期内的季节项之和为0
+func seasonalComponent(values, trend []float64, seasonLength int) []float64 {
+	phases := make([][]float64, seasonLength)
+	for i, v := range values {
+		phases[i%seasonLength] = append(phases[i%seasonLength], v-trend[i])
+	}
+	indexes := make([]float64, seasonLength)
+	var mean float64
+	for phase, detrended := range phases {
+		indexes[phase] = advanced_contribution_analyzer.Median(detrended)
+		mean += indexes[phase]
+	}
+	mean /= float64(seasonLength)
+
+	seasonal := make([]float64, len(values))
+	for i := range values {
+		seasonal[i] = indexes[i%seasonLength] - mean
+	}
+	return seasonal
+}
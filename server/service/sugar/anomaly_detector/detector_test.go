@@ -0,0 +1,157 @@
+package anomaly_detector
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// noisy 以固定的小幅波动生成序列，避免随机数导致结果不稳定
+func noisy(level float64, n int) []float64 {
+	wiggle := []float64{0, 1, -1, 2, 0, -2, 1, -1}
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = level + wiggle[i%len(wiggle)]
+	}
+	return values
+}
+
+func anomalyIndexes(result *Result) []int {
+	var indexes []int
+	for _, point := range result.Anomalies() {
+		indexes = append(indexes, point.Index)
+	}
+	return indexes
+}
+
+func TestRollingMedian(t *testing.T) {
+	values := []float64{1, 5, 2, 8, 3}
+	excluded := []bool{false, false, false, true, false}
+	tests := []struct {
+		name     string
+		window   int
+		breaks   []int
+		excluded []bool
+		want     []float64
+	}{
+		{"偶数窗口加1，两端截断", 2, nil, nil, []float64{3, 2, 5, 3, 5.5}},
+		{"不跨变点平滑", 3, []int{2}, nil, []float64{3, 3, 5, 3, 5.5}},
+		{"排除当期和标记的期，排除后为空时使用完整窗口", 3, nil, excluded, []float64{5, 1.5, 5, 2.5, 5.5}},
+	}
+	for _, tt := range tests {
+		if got := rollingMedian(values, tt.window, tt.breaks, tt.excluded); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rollingMedian = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSeasonalComponent(t *testing.T) {
+	values := []float64{3, 1, 5, 3, 7, 5}
+	trend := []float64{2, 2, 4, 4, 6, 6}
+	if got := seasonalComponent(values, trend, 2); !reflect.DeepEqual(got, []float64{1, -1, 1, -1, 1, -1}) {
+		t.Fatalf("季节项 = %v", got)
+	}
+	// 各相位的中位数减去均值，一个周期内之和为0
+	if got := seasonalComponent([]float64{4, 1, 6, 3}, []float64{1, 1, 3, 3}, 2); !reflect.DeepEqual(got, []float64{1.5, -1.5, 1.5, -1.5}) {
+		t.Fatalf("季节项应中心化: %v", got)
+	}
+}
+
+func TestDetectChangePoints(t *testing.T) {
+	config := DefaultConfig()
+	step := []float64{0, 0, 0, 0, 0, 10, 10, 10, 10, 10}
+	want := []ChangePoint{{Index: 5, BeforeMean: 0, AfterMean: 10, Shift: 10, Score: 10}}
+	if got := detectChangePoints(step, 1, config); !reflect.DeepEqual(got, want) {
+		t.Fatalf("单个跳变 = %+v，期望 %+v", got, want)
+	}
+
+	// 两个跳变按下标排序，前后段以相邻变点为界
+	pulse := []float64{0, 0, 0, 0, 10, 10, 10, 10, 0, 0, 0, 0}
+	want = []ChangePoint{
+		{Index: 4, BeforeMean: 0, AfterMean: 10, Shift: 10, Score: 5},
+		{Index: 8, BeforeMean: 10, AfterMean: 0, Shift: -10, Score: -5},
+	}
+	if got := detectChangePoints(pulse, 2, config); !reflect.DeepEqual(got, want) {
+		t.Fatalf("两个跳变 = %+v，期望 %+v", got, want)
+	}
+
+	// 跳变不超过惩罚、噪声为0或不检测变点时没有结果
+	if got := detectChangePoints(step, 10, config); len(got) != 0 {
+		t.Fatalf("跳变小于惩罚时不应检出变点: %+v", got)
+	}
+	if got := detectChangePoints(step, 0, config); got != nil {
+		t.Fatalf("噪声为0时不应检测变点: %+v", got)
+	}
+	config.MaxChangePoints = 0
+	if got := detectChangePoints(step, 1, config); len(got) != 0 {
+		t.Fatalf("maxChangePoints 为0时不应检测变点: %+v", got)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	// 单期尖峰：只标记尖峰本身，预期值不被尖峰拉偏，也不识别为变点
+	spike := noisy(100, 24)
+	spike[12] = 160
+	result, err := Detect(spike, nil)
+	if err != nil {
+		t.Fatalf("检测失败: %v", err)
+	}
+	if result.Method != MethodRobust || !reflect.DeepEqual(anomalyIndexes(result), []int{12}) || len(result.ChangePoints) != 0 {
+		t.Fatalf("尖峰检测结果不符合预期: %s %v %+v", result.Method, anomalyIndexes(result), result.ChangePoints)
+	}
+	if point := result.Points[12]; math.Abs(point.Expected-100) > 2 || point.Score <= 0 {
+		t.Fatalf("尖峰的预期值或分数不符合预期: %+v", point)
+	}
+
+	// 水平跳变：识别为一个变点，跳变前后各期都不是异常
+	shift := append(noisy(100, 12), noisy(150, 12)...)
+	if result, err = Detect(shift, nil); err != nil {
+		t.Fatalf("检测失败: %v", err)
+	}
+	if len(result.ChangePoints) != 1 || result.ChangePoints[0].Index != 12 || math.Abs(result.ChangePoints[0].Shift-50) > 2 {
+		t.Fatalf("变点检测结果不符合预期: %+v", result.ChangePoints)
+	}
+	if anomalies := anomalyIndexes(result); len(anomalies) != 0 {
+		t.Fatalf("水平跳变前后不应出现异常: %v", anomalies)
+	}
+
+	// 季节序列：满三个周期时做季节分解，季节波动本身不是异常，偏离同相位的尖峰是异常
+	pattern := []float64{0, 30, -10, -20}
+	seasonal := make([]float64, 16)
+	for i := range seasonal {
+		seasonal[i] = 100 + pattern[i%4] + noisy(0, 16)[i]
+	}
+	config := DefaultConfig()
+	config.SeasonLength = 4
+	if result, err = Detect(seasonal, config); err != nil {
+		t.Fatalf("检测失败: %v", err)
+	}
+	if result.Method != MethodSeasonal || result.SeasonLength != 4 || len(result.Anomalies()) != 0 || len(result.ChangePoints) != 0 {
+		t.Fatalf("季节序列不应出现异常或变点: %s %v %+v", result.Method, anomalyIndexes(result), result.ChangePoints)
+	}
+	seasonal[9] += 60
+	if result, _ = Detect(seasonal, config); !reflect.DeepEqual(anomalyIndexes(result), []int{9}) {
+		t.Fatalf("应标记偏离季节模式的一期: %v", anomalyIndexes(result))
+	}
+	// 不足三个周期时退化为 robust
+	if result, _ = Detect(seasonal[:11], config); result.Method != MethodRobust || result.SeasonLength != 0 {
+		t.Fatalf("不足三个周期时不应做季节分解: %s %d", result.Method, result.SeasonLength)
+	}
+
+	// MAD 为0：常数序列没有异常；只有一期不同时以差分的平均绝对偏差作为尺度，仍能标记
+	if result, _ = Detect([]float64{5, 5, 5, 5, 5, 5, 5, 5}, nil); len(result.Anomalies()) != 0 || len(result.ChangePoints) != 0 || result.Points[3].Score != 0 {
+		t.Fatalf("常数序列不应出现异常: %+v", result)
+	}
+	if result, _ = Detect([]float64{5, 5, 5, 50, 5, 5, 5, 5}, nil); !reflect.DeepEqual(anomalyIndexes(result), []int{3}) {
+		t.Fatalf("MAD 为0时应标记唯一不同的一期: %+v", result.Points)
+	}
+
+	// 序列过短或配置无效
+	if _, err = Detect([]float64{1, 2, 3}, nil); !errors.Is(err, ErrSeriesTooShort) {
+		t.Fatalf("少于4期应返回 ErrSeriesTooShort: %v", err)
+	}
+	if _, err = Detect(spike, &Config{Threshold: 3.5, TrendWindow: 1}); err == nil {
+		t.Fatal("无效配置应返回错误")
+	}
+}
@@ -0,0 +1,93 @@
+package anomaly_detector
+
+import "errors"
+
+// MinSeriesLength 参与检测的最少期数
+const MinSeriesLength = 4
+
+// 检测方法
+const (
+	MethodRobust   = "robust"   // 滚动中位数趋势 + 稳健 z 分数
+	MethodSeasonal = "seasonal" // 季节分解（滚动中位数趋势 + 分相位中位数季节项）+ 稳健 z 分数
+)
+
+// ErrSeriesTooShort 序列期数不足
+var ErrSeriesTooShort = errors.New("时间序列少于4期，无法检测异常")
+
+// Config 异常与变点检测配置
+type Config struct {
+	Threshold          float64 `json:"threshold"`          // 残差稳健 z 分数的异常阈值
+	SeasonLength       int     `json:"seasonLength"`       // 季节周期（期数），0 表示不做季节分解；序列不足三个完整周期时自动退化为 robust
+	TrendWindow        int     `json:"trendWindow"`        // 非季节序列的滚动中位数窗口（期数，取奇数）
+	ChangePointPenalty float64 `json:"changePointPenalty"` // 变点惩罚系数，越大检出的变点越少
+	MinSegmentLength   int     `json:"minSegmentLength"`   // 变点两侧至少包含的期数
+	MaxChangePoints    int     `json:"maxChangePoints"`    // 最多检出的变点数量，0 表示不检测变点
+}
+
+// DefaultConfig 默认检测配置
+func DefaultConfig() *Config {
+	return &Config{
+		Threshold:          3.5,
+		TrendWindow:        5,
+		ChangePointPenalty: 3,
+		MinSegmentLength:   3,
+		MaxChangePoints:    5,
+	}
+}
+
+// Validate 校验检测配置
+func (c *Config) Validate() error {
+	switch {
+	case c.Threshold <= 0:
+		return errors.New("threshold 必须大于0")
+	case c.SeasonLength < 0 || c.SeasonLength == 1:
+		return errors.New("seasonLength 必须为0或不小于2")
+	case c.TrendWindow < 3:
+		return errors.New("trendWindow 不能小于3")
+	case c.ChangePointPenalty <= 0:
+		return errors.New("changePointPenalty 必须大于0")
+	case c.MinSegmentLength < 2:
+		return errors.New("minSegmentLength 不能小于2")
+	case c.MaxChangePoints < 0:
+		return errors.New("maxChangePoints 不能为负数")
+	}
+	return nil
+}
+
+// Point 单期的检测结果
+type Point struct {
+	Index     int     `json:"index"`
+	Value     float64 `json:"value"`     // 实际值
+	Expected  float64 `json:"expected"`  // 趋势 + 季节项
+	Residual  float64 `json:"residual"`  // 实际值 - 预期值
+	Score     float64 `json:"score"`     // 残差的稳健 z 分数
+	IsAnomaly bool    `json:"isAnomaly"` // 分数绝对值是否超过阈值
+}
+
+// ChangePoint 均值变点，Index 为新水平的第一期
+type ChangePoint struct {
+	Index      int     `json:"index"`
+	BeforeMean float64 `json:"beforeMean"` // 变点前一段的均值（已去除季节项）
+	AfterMean  float64 `json:"afterMean"`  // 变点后一段的均值（已去除季节项）
+	Shift      float64 `json:"shift"`      // AfterMean - BeforeMean
+	Score      float64 `json:"score"`      // Shift 与序列噪声水平之比
+}
+
+// Result 单条时间序列的检测结果
+type Result struct {
+	Method       string        `json:"method"`
+	SeasonLength int           `json:"seasonLength"` // 实际使用的季节周期，未做季节分解时为0
+	Points       []Point       `json:"points"`
+	ChangePoints []ChangePoint `json:"changePoints"`
+}
+
+// Anomalies 超过阈值的各期
+func (r *Result) Anomalies() []Point {
+	var anomalies []Point
+	for _, point := range r.Points {
+		if point.IsAnomaly {
+			anomalies = append(anomalies, point)
+		}
+	}
+	return anomalies
+}
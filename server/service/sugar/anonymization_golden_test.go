@@ -0,0 +1,235 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 下的 golden 文件")
+
+// goldenCodePattern 桩模型从提示词中识别的值代号
+var goldenCodePattern = regexp.MustCompile(`DIM\d+_V\d+`)
+
+// setupGoldenDB 初始化确定性匿名化测试数据：两个月份、三个城市、两个产品
+func setupGoldenDB(t *testing.T, policy string) {
+	t.Helper()
+	db := setupTestDB(t)
+	statements := []string{
+		`CREATE TABLE monthly_sales (month TEXT, city TEXT, product TEXT, amount REAL)`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor')`,
+		`INSERT INTO monthly_sales VALUES
+			('2024-01', '北京', '咖啡', 100), ('2024-01', '北京', '茶饮', 80),
+			('2024-01', '上海', '咖啡', 120), ('2024-01', '上海', '茶饮', 60),
+			('2024-01', '深圳', '咖啡', 40),
+			('2024-02', '北京', '咖啡', 130), ('2024-02', '北京', '茶饮', 70),
+			('2024-02', '上海', '咖啡', 150), ('2024-02', '上海', '茶饮', 90),
+			('2024-02', '深圳', '咖啡', 35)`,
+	}
+	seedTestData(t, statements...)
+	id, name, teamId, table := "model-golden", "月度销售", "team-1", "monthly_sales"
+	seed := int64(20240201)
+	model := sugar.SugarSemanticModels{
+		Id:                      &id,
+		Name:                    &name,
+		TeamId:                  &teamId,
+		SourceTableName:         &table,
+		ParameterConfig:         []byte(`{"月份": {"column": "month", "operator": "="}}`),
+		ReturnableColumnsConfig: []byte(`{"月份": {"column": "month", "type": "dimension"}, "城市": {"column": "city", "type": "dimension"}, "产品": {"column": "product", "type": "dimension"}, "销售额": {"column": "amount", "type": "metric"}}`),
+		AnonymizationPolicy:     []byte(policy),
+		AnonymizationSeed:       &seed,
+	}
+	if err := db.Create(&model).Error; err != nil {
+		t.Fatalf("创建语义模型失败: %v", err)
+	}
+
+	global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey = "golden-test-key"
+}
+
+// stubLLM 离线桩模型：按代号首次出现的顺序逐条复述，输出只取决于提示词内容
+type stubLLM struct {
+	mu       sync.Mutex
+	prompts  []string
+	response func(prompt string) string
+}
+
+func newStubLLM(t *testing.T) (*stubLLM, *system.LLMConfig) {
+	t.Helper()
+	stub := &stubLLM{response: defaultStubResponse}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request system.OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var user string
+		for _, message := range request.Messages {
+			if message.Role == "user" {
+				user = message.Content
+			}
+		}
+		stub.mu.Lock()
+		stub.prompts = append(stub.prompts, user)
+		stub.mu.Unlock()
+
+		var response system.OpenAIResponse
+		response.Choices = make([]system.OpenAIChoice, 1)
+		response.Choices[0].Message.Role = "assistant"
+		response.Choices[0].Message.Content = stub.response(user)
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return stub, &system.LLMConfig{BaseURL: server.URL, ModelName: "stub"}
+}
+
+// defaultStubResponse 列出提示词中出现的值代号，并混入模型常见的代号改写和一个不存在的代号
+func defaultStubResponse(prompt string) string {
+	var builder strings.Builder
+	builder.WriteString("分析结论：\n")
+	seen := make(map[string]bool)
+	for i, code := range goldenCodePattern.FindAllString(prompt, -1) {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		if i%2 == 1 {
+			code = strings.ToLower(code)
+		}
+		builder.WriteString(fmt.Sprintf("- %s 是本期变化的驱动因素之一\n", code))
+	}
+	builder.WriteString("- DIM98_V9999 为模型臆造的代号\n")
+	return builder.String()
+}
+
+// runGoldenPipeline 执行 贡献度分析 → 匿名化 → 桩模型 → 解码 的完整流程
+func runGoldenPipeline(t *testing.T, llmConfig *system.LLMConfig, groupBy []string) (prompt, raw, decoded string) {
+	t.Helper()
+	ctx := context.Background()
+	agentPrompt := "你是销售分析助手"
+	agent := &sugar.SugarAgents{Prompt: &agentPrompt}
+
+	policy, err := resolveAnonymizationPolicyForModel(ctx, "月度销售", "1", agent)
+	if err != nil {
+		t.Fatalf("解析匿名化策略失败: %v", err)
+	}
+	config := policy.LiteConfig()
+	if policy.UsesPrivacyBudget() {
+		// 模拟隐私预算授予的完整ε
+		config.Epsilon = defaultEpsilonPerQuery
+	}
+
+	analyzer := NewContributionAnalyzer(nil)
+	dataText, session, _, err := analyzer.PerformAnalysis(ctx, "月度销售", "销售额",
+		map[string]interface{}{"月份": "2024-02"}, map[string]interface{}{"月份": "2024-01"},
+		groupBy, "1", nil, config)
+	if err != nil {
+		t.Fatalf("贡献度分析失败: %v", err)
+	}
+
+	guard := newAnonymizationGuard(session, policy.LeakPolicyValue())
+	manager := NewAIInteractionManager()
+	raw, err = manager.PerformDataAnalysis(ctx, dataText, "分析2月销售额变化的原因", agent, llmConfig, guard.Guard)
+	if err != nil {
+		t.Fatalf("AI分析失败: %v", err)
+	}
+	decoded, err = guard.Decode(raw)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	return dataText, raw, decoded + "\n" + strings.Join(guard.Warnings(), "\n")
+}
+
+func checkGolden(t *testing.T, name, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", "anonymization_golden", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("写入 golden 文件失败: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败（使用 -update 生成）: %v", err)
+	}
+	if string(expected) != actual {
+		t.Errorf("%s 与 golden 文件不一致\n--- 期望 ---\n%s\n--- 实际 ---\n%s", name, expected, actual)
+	}
+}
+
+func TestDeterministicAnonymizationGolden(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  string
+		groupBy []string
+	}{
+		{
+			name:    "advanced_city_product",
+			policy:  `{"deterministic": true}`,
+			groupBy: []string{"城市", "产品"},
+		},
+		{
+			name:    "sensitive_city_k2",
+			policy:  `{"deterministic": true, "sensitiveDimensions": ["城市"], "kAnonymity": 2, "allowAbsoluteValues": true}`,
+			groupBy: []string{"城市", "产品"},
+		},
+		{
+			name:    "passthrough_always_code",
+			policy:  `{"deterministic": true, "enabled": false, "alwaysCodeValues": ["城市:深圳"]}`,
+			groupBy: []string{"城市"},
+		},
+		{
+			name:    "lite_noise",
+			policy:  `{"deterministic": true, "anonymizer": "lite", "noiseLevel": 0.05}`,
+			groupBy: []string{"产品"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupGoldenDB(t, tc.policy)
+			stub, llmConfig := newStubLLM(t)
+
+			dataText, raw, decoded := runGoldenPipeline(t, llmConfig, tc.groupBy)
+			checkGolden(t, tc.name+".data", dataText)
+			checkGolden(t, tc.name+".prompt", stub.prompts[0])
+			checkGolden(t, tc.name+".response", raw)
+			checkGolden(t, tc.name+".decoded", decoded)
+
+			// 同一输入再次运行，代号、噪声和解码结果完全一致
+			againText, againRaw, againDecoded := runGoldenPipeline(t, llmConfig, tc.groupBy)
+			if againText != dataText || againRaw != raw || againDecoded != decoded {
+				t.Fatalf("确定性模式下两次运行结果不一致")
+			}
+		})
+	}
+}
+
+func TestDeterministicCodesDependOnModelKey(t *testing.T) {
+	setupGoldenDB(t, `{"deterministic": true}`)
+	_, llmConfig := newStubLLM(t)
+	first, _, _ := runGoldenPipeline(t, llmConfig, []string{"城市"})
+
+	// 更换密钥后代号随之变化，不同团队/部署之间的代号不可关联
+	global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey = "another-key"
+	second, _, _ := runGoldenPipeline(t, llmConfig, []string{"城市"})
+	if first == second {
+		t.Fatal("不同密钥应派生出不同的代号")
+	}
+}
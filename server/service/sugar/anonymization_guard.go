@@ -0,0 +1,78 @@
+package sugar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+)
+
+// anonymizationGuard 围绕一次匿名化分析的出入站检查：
+// 发送前扫描提示词中泄露的原始维度值并按策略阻止或替换，返回后按代号边界解码并汇总无法解码的代号
+type anonymizationGuard struct {
+	session  *anonymization_lite.LiteAnonymizationSession
+	policy   anonymization_lite.LeakPolicy
+	findings []anonymization_lite.LeakFinding
+	unknown  map[string]bool
+}
+
+// newAnonymizationGuard 创建匿名化检查器
+func newAnonymizationGuard(session *anonymization_lite.LiteAnonymizationSession, policy anonymization_lite.LeakPolicy) *anonymizationGuard {
+	return &anonymizationGuard{
+		session: session,
+		policy:  policy,
+		unknown: make(map[string]bool),
+	}
+}
+
+// Guard 检查即将发送给LLM的消息，可作为 PromptGuard 使用
+func (g *anonymizationGuard) Guard(messages []system.ChatMessage) ([]system.ChatMessage, error) {
+	guarded := make([]system.ChatMessage, len(messages))
+	for i, message := range messages {
+		content, findings, err := g.session.GuardPrompt(message.Content, g.policy)
+		g.findings = append(g.findings, findings...)
+		if err != nil {
+			return nil, err
+		}
+		message.Content = content
+		guarded[i] = message
+	}
+	return guarded, nil
+}
+
+// Decode 解码AI输出中的代号，并记录无法解码的代号
+func (g *anonymizationGuard) Decode(text string) (string, error) {
+	report, err := g.session.DecodeAIResponseWithReport(text)
+	if err != nil {
+		return "", err
+	}
+	for _, code := range report.UnknownCodes {
+		g.unknown[code] = true
+	}
+	return report.Text, nil
+}
+
+// Warnings 返回需要提示给用户的检查结果
+func (g *anonymizationGuard) Warnings() []string {
+	var warnings []string
+	if len(g.findings) > 0 {
+		warnings = append(warnings, fmt.Sprintf("发送给AI的内容中有%d个原始维度值未被匿名化，已自动替换为代号", len(g.findings)))
+	}
+	if len(g.unknown) > 0 {
+		codes := make([]string, 0, len(g.unknown))
+		for code := range g.unknown {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		warnings = append(warnings, "AI结果中有无法解码的代号，已原样保留: "+strings.Join(codes, ", "))
+	}
+	return warnings
+}
+
+// anonymizationLeakPolicy 返回配置的提示词泄露处理策略
+func anonymizationLeakPolicy() anonymization_lite.LeakPolicy {
+	return anonymization_lite.ParseLeakPolicy(global.GVA_CONFIG.Sugar.Anonymization.LeakPolicy)
+}
@@ -0,0 +1,76 @@
+package sugar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"go.uber.org/zap"
+)
+
+func TestAnonymizationGuard(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	messages := []system.ChatMessage{
+		{Role: "system", Content: "维度 DIM01 表示城市"},
+		{Role: "user", Content: "北京和DIM01_V02相比如何？"},
+	}
+
+	// redact：替换泄露的原始值，解码时汇总无法解码的代号
+	guard := newAnonymizationGuard(testLiteSession(), anonymization_lite.LeakPolicyRedact)
+	guarded, err := guard.Guard(messages)
+	if err != nil {
+		t.Fatalf("redact 策略不应阻止发送: %v", err)
+	}
+	if guarded[0].Content != messages[0].Content || guarded[1].Content != "DIM01_V01和DIM01_V02相比如何？" || messages[1].Content != "北京和DIM01_V02相比如何？" {
+		t.Fatalf("替换结果不符合预期，且不应修改原消息: %+v %+v", guarded, messages)
+	}
+	for _, text := range []string{"DIM01_V01高于DIM01_V09", "dim1_v9 和 DIM01_V07 未知，xDIM01_V01 不是代号"} {
+		if _, err = guard.Decode(text); err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+	}
+	if decoded, _ := guard.Decode("DIM01_V01 高于 dim01-v2"); decoded != "北京 高于 上海" {
+		t.Fatalf("解码结果 = %q", decoded)
+	}
+	want := []string{
+		"发送给AI的内容中有1个原始维度值未被匿名化，已自动替换为代号",
+		"AI结果中有无法解码的代号，已原样保留: DIM01_V07, DIM01_V09, dim1_v9",
+	}
+	if warnings := guard.Warnings(); !reflect.DeepEqual(warnings, want) {
+		t.Fatalf("提示 = %q，期望 %q", warnings, want)
+	}
+
+	// block：发现泄露时不发送，也不返回部分替换的消息
+	guard = newAnonymizationGuard(testLiteSession(), anonymization_lite.LeakPolicyBlock)
+	if guarded, err = guard.Guard(messages); err == nil || guarded != nil {
+		t.Fatalf("block 策略应阻止发送: %+v %v", guarded, err)
+	}
+
+	// 没有泄露也没有未知代号时不提示
+	guard = newAnonymizationGuard(testLiteSession(), anonymization_lite.LeakPolicyBlock)
+	if _, err = guard.Guard(messages[:1]); err != nil || guard.Warnings() != nil {
+		t.Fatalf("干净的消息不应产生提示: %v %q", err, guard.Warnings())
+	}
+}
+
+func TestAnonymizationGuardLegacySession(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	// 旧版工具的会话转换后按代号边界解码：DIM01_V1 不会被当作 DIM01_V10 的前缀替换
+	legacy := &AnonymizationSession{
+		forwardMap: map[string]string{"城市": "DIM01", "城市:北京": "DIM01_V01", "城市:深圳": "DIM01_V10"},
+		reverseMap: map[string]string{"DIM01": "城市", "DIM01_V01": "北京", "DIM01_V10": "深圳"},
+	}
+	guard := newAnonymizationGuard(liteSessionFromLegacy(legacy), anonymization_lite.LeakPolicyRedact)
+	decoded, err := guard.Decode("DIM01_V10 高于 DIM01_V1，DIM01_V11 未知")
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if decoded != "深圳 高于 北京，DIM01_V11 未知" {
+		t.Fatalf("解码结果 = %q", decoded)
+	}
+	if warnings := guard.Warnings(); !reflect.DeepEqual(warnings, []string{"AI结果中有无法解码的代号，已原样保留: DIM01_V11"}) {
+		t.Fatalf("应提示无法解码的代号: %q", warnings)
+	}
+}
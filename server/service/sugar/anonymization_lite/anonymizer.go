@@ -2,7 +2,9 @@ package anonymization_lite
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
@@ -10,44 +12,57 @@ import (
 )
 
 // getOrCreateAnonymizedDimension 获取或创建维度名的匿名化代号（简化版）
-func (s *LiteAnonymizationService) getOrCreateAnonymizedDimension(session *LiteAnonymizationSession, dimName string, counters map[string]int) string {
+func (s *LiteAnonymizationService) getOrCreateAnonymizedDimension(session *LiteAnonymizationSession, dimName string, counters map[string]int) (string, error) {
 	// 检查是否已经存在匿名化代号
 	if anonymized, exists := session.ForwardMap[dimName]; exists {
-		return anonymized
+		return anonymized, nil
 	}
 
-	// 使用简单的DIM编号，但在描述中包含原始维度名称
-	counters["dimension"]++
-	anonymized := fmt.Sprintf("DIM%02d", counters["dimension"])
+	// 使用简单的DIM编号，但在描述中包含原始维度名称；确定性模式下编号由密钥派生
+	var anonymized string
+	if s.config.isDeterministic() {
+		var err error
+		if anonymized, err = session.keyedDimensionCode(s.config, dimName); err != nil {
+			return "", err
+		}
+	} else {
+		counters["dimension"]++
+		anonymized = fmt.Sprintf("DIM%02d", counters["dimension"])
+	}
 
 	// 存储映射关系
 	session.ForwardMap[dimName] = anonymized
 	session.ReverseMap[anonymized] = dimName
 
-	// 存储维度语义信息，直接使用原始维度名称作为描述
-	session.DimensionSemantics[anonymized] = &DimensionSemanticInfo{
+	// 存储维度语义信息，直接使用原始维度名称作为描述；敏感维度不向模型透露维度名称
+	semantic := &DimensionSemanticInfo{
 		AnonymizedName: anonymized,
 		OriginalName:   dimName,
 		SemanticType:   "业务维度",
 		Description:    dimName, // 直接使用原始维度名称
 	}
+	if s.config.isSensitiveDimension(dimName) {
+		semantic.SemanticType = "敏感维度"
+		semantic.Description = "敏感维度（名称已隐藏）"
+	}
+	session.DimensionSemantics[anonymized] = semantic
 
 	global.GVA_LOG.Debug("创建维度匿名映射",
 		zap.String("original", dimName),
 		zap.String("anonymized", anonymized),
 		zap.String("description", dimName))
 
-	return anonymized
+	return anonymized, nil
 }
 
 // getOrCreateAnonymizedValue 获取或创建维度值的匿名化代号（简化版）
-func (s *LiteAnonymizationService) getOrCreateAnonymizedValue(session *LiteAnonymizationSession, dimName, dimValue string, counters map[string]int) string {
+func (s *LiteAnonymizationService) getOrCreateAnonymizedValue(session *LiteAnonymizationSession, dimName, dimValue string, counters map[string]int) (string, error) {
 	// 构建完整的键（维度名+值）
 	fullKey := fmt.Sprintf("%s:%s", dimName, dimValue)
 
 	// 检查是否已经存在匿名化代号
 	if anonymized, exists := session.ForwardMap[fullKey]; exists {
-		return anonymized
+		return anonymized, nil
 	}
 
 	// 获取维度的匿名化代号
@@ -55,13 +70,24 @@ func (s *LiteAnonymizationService) getOrCreateAnonymizedValue(session *LiteAnony
 	if anonymizedDim == "" {
 		// 如果维度还没有匿名化，先创建维度代号
 		dimensionCounters := make(map[string]int)
-		anonymizedDim = s.getOrCreateAnonymizedDimension(session, dimName, dimensionCounters)
+		var err error
+		if anonymizedDim, err = s.getOrCreateAnonymizedDimension(session, dimName, dimensionCounters); err != nil {
+			return "", err
+		}
 	}
 
-	// 生成简单的值代号
-	dimKey := fmt.Sprintf("value_%s", dimName)
-	counters[dimKey]++
-	anonymized := fmt.Sprintf("%s_V%02d", anonymizedDim, counters[dimKey])
+	// 生成简单的值代号，确定性模式下编号由密钥派生
+	var anonymized string
+	if s.config.isDeterministic() {
+		var err error
+		if anonymized, err = session.keyedValueCode(s.config, anonymizedDim, dimName, dimValue); err != nil {
+			return "", err
+		}
+	} else {
+		dimKey := fmt.Sprintf("value_%s", dimName)
+		counters[dimKey]++
+		anonymized = fmt.Sprintf("%s_V%02d", anonymizedDim, counters[dimKey])
+	}
 
 	// 存储映射关系
 	session.ForwardMap[fullKey] = anonymized
@@ -72,7 +98,7 @@ func (s *LiteAnonymizationService) getOrCreateAnonymizedValue(session *LiteAnony
 		zap.String("originalValue", dimValue),
 		zap.String("anonymizedValue", anonymized))
 
-	return anonymized
+	return anonymized, nil
 }
 
 // DecodeAIResponse 解码AI响应中的匿名代号（简化版）
@@ -89,36 +115,19 @@ func (session *LiteAnonymizationSession) DecodeAIResponse(aiText string) (string
 		zap.Int("originalLength", len(aiText)),
 		zap.Int("mappingCount", len(session.ReverseMap)))
 
-	// 获取所有需要替换的代号，按长度降序排序以避免部分替换问题
-	var codes []string
-	for code := range session.ReverseMap {
-		codes = append(codes, code)
-	}
-
-	// 按字符串长度降序排序
-	sort.Slice(codes, func(i, j int) bool {
-		return len(codes[i]) > len(codes[j])
-	})
-
-	// 执行替换
-	decodedText := aiText
-	replacementCount := 0
-
-	for _, code := range codes {
-		originalValue := session.ReverseMap[code]
-		if strings.Contains(decodedText, code) {
-			decodedText = strings.ReplaceAll(decodedText, code, originalValue)
-			replacementCount++
-		}
+	report, err := session.DecodeAIResponseWithReport(aiText)
+	if err != nil {
+		return "", err
 	}
 
 	global.GVA_LOG.Info("AI响应解码完成",
-		zap.Int("totalCodes", len(codes)),
-		zap.Int("replacementCount", replacementCount),
+		zap.Int("replacementCount", report.Replacements),
+		zap.Int("mutatedCodes", len(report.MutatedCodes)),
+		zap.Int("unknownCodes", len(report.UnknownCodes)),
 		zap.Int("originalLength", len(aiText)),
-		zap.Int("decodedLength", len(decodedText)))
+		zap.Int("decodedLength", len(report.Text)))
 
-	return decodedText, nil
+	return report.Text, nil
 }
 
 // GetAIReadyData 获取准备发送给AI的匿名化数据
@@ -194,12 +203,21 @@ func (session *LiteAnonymizationSession) SerializeToText() (string, error) {
 
 	var builder strings.Builder
 	builder.WriteString("【简化匿名化贡献度分析数据】\n")
-	builder.WriteString("说明：以下数据已进行匿名化处理，专注于贡献度分析\n\n")
+	if session.Config != nil && session.Config.Passthrough {
+		builder.WriteString("说明：以下数据按匿名化策略未做整体匿名化，仅敏感维度和指定值使用代号，专注于贡献度分析\n")
+	} else {
+		builder.WriteString("说明：以下数据已进行匿名化处理，专注于贡献度分析\n")
+	}
+	if session.Config != nil && session.Config.Coarse {
+		builder.WriteString("注意：隐私预算不足，本次只提供定性字段（正向驱动、趋势方向、影响程度），请勿推测具体数值\n")
+	}
+	builder.WriteString("\n")
 
 	// 添加维度代号说明
 	if len(session.DimensionSemantics) > 0 {
 		builder.WriteString("维度代号说明：\n")
-		for anonymizedName, semanticInfo := range session.DimensionSemantics {
+		for _, anonymizedName := range sortedSemanticCodes(session.DimensionSemantics) {
+			semanticInfo := session.DimensionSemantics[anonymizedName]
 			builder.WriteString(fmt.Sprintf("- %s-%s\n",
 				anonymizedName, semanticInfo.Description))
 		}
@@ -215,14 +233,19 @@ func (session *LiteAnonymizationSession) SerializeToText() (string, error) {
 	builder.WriteString("- change_rate_percent：变化率百分比\n")
 	builder.WriteString("- trend_direction：趋势方向（增长/下降/持平）\n")
 	builder.WriteString("- impact_level：影响程度（高/中/低）\n")
-	builder.WriteString("- relative_importance：相对重要性（0-100分）\n\n")
+	builder.WriteString("- relative_importance：相对重要性（0-100分）\n")
+	if session.Config != nil && session.Config.AllowAbsoluteValues && !session.Config.Coarse {
+		builder.WriteString("- current_value / base_value / change_value：本期值、基期值、变化值\n")
+	}
+	builder.WriteString("\n")
 
 	builder.WriteString("数据内容：\n")
 	for i, item := range session.AIReadyData {
 		builder.WriteString(fmt.Sprintf("项目 %d:\n", i+1))
 
 		// 先输出维度信息
-		for key, value := range item {
+		for _, key := range sortedDimensionNames(item) {
+			value := item[key]
 			if strings.HasPrefix(key, "DIM") {
 				// 添加维度名称提示
 				dimensionHint := ""
@@ -252,6 +275,9 @@ func (session *LiteAnonymizationSession) SerializeToText() (string, error) {
 		if ri, ok := item["relative_importance"]; ok {
 			builder.WriteString(fmt.Sprintf("  相对重要性: %.1f分\n", ri))
 		}
+		if cv, ok := item["current_value"]; ok {
+			builder.WriteString(fmt.Sprintf("  本期值: %.2f，基期值: %.2f，变化值: %.2f\n", cv, item["base_value"], item["change_value"]))
+		}
 
 		builder.WriteString("\n")
 	}
@@ -263,3 +289,65 @@ func (session *LiteAnonymizationSession) SerializeToText() (string, error) {
 
 	return builder.String(), nil
 }
+
+var (
+	dimensionCodePattern = regexp.MustCompile(`^DIM(\d+)$`)
+	valueCodePattern     = regexp.MustCompile(`^(DIM\d+)_V(\d+)$`)
+)
+
+// inheritMappings 复制已有会话的映射和维度语义，并根据已有代号恢复维度和值计数器
+func (session *LiteAnonymizationSession) inheritMappings(previous *LiteAnonymizationSession, dimensionCounters, valueCounters map[string]int) {
+	for original, code := range previous.ForwardMap {
+		session.ForwardMap[original] = code
+	}
+	for code, original := range previous.ReverseMap {
+		session.ReverseMap[code] = original
+	}
+	for code, semantic := range previous.DimensionSemantics {
+		session.DimensionSemantics[code] = semantic
+	}
+	session.EpsilonSpent = previous.EpsilonSpent
+
+	for code := range session.ReverseMap {
+		if match := dimensionCodePattern.FindStringSubmatch(code); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil && n > dimensionCounters["dimension"] {
+				dimensionCounters["dimension"] = n
+			}
+			continue
+		}
+		if match := valueCodePattern.FindStringSubmatch(code); match != nil {
+			dimName, ok := session.ReverseMap[match[1]]
+			if !ok {
+				continue
+			}
+			dimKey := fmt.Sprintf("value_%s", dimName)
+			if n, err := strconv.Atoi(match[2]); err == nil && n > valueCounters[dimKey] {
+				valueCounters[dimKey] = n
+			}
+		}
+	}
+
+	global.GVA_LOG.Debug("继承已有匿名化会话映射",
+		zap.Int("mappingCount", len(session.ForwardMap)),
+		zap.Int("dimensionCounter", dimensionCounters["dimension"]))
+}
+
+// sortedDimensionNames 返回按名称排序的键，保证序列化和代号分配的顺序稳定
+func sortedDimensionNames(values map[string]interface{}) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedSemanticCodes 返回按代号排序的维度语义键
+func sortedSemanticCodes(semantics map[string]*DimensionSemanticInfo) []string {
+	codes := make([]string, 0, len(semantics))
+	for code := range semantics {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -0,0 +1,301 @@
+package anonymization_lite
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"go.uber.org/zap"
+)
+
+// codeCandidatePattern 匹配代号及模型常见的改写：大小写变化、补零差异、连字符代替下划线、分隔符两侧的空格
+// 全角字符在匹配前折叠为半角，因此全角下划线和全角字母同样可以识别
+var codeCandidatePattern = regexp.MustCompile(`(?i)DIM[ \t]*0*(\d{1,4})(?:[ \t]*[_\-][ \t]*V[ \t]*0*(\d{1,4}))?`)
+
+// minLeakValueRunes 参与泄露扫描的维度值最短字符数，过短的值（如单个字母）误报过多
+const minLeakValueRunes = 2
+
+// LeakPolicy 发现提示词泄露原始维度值时的处理策略
+type LeakPolicy string
+
+const (
+	LeakPolicyBlock  LeakPolicy = "block"  // 阻止本次LLM调用
+	LeakPolicyRedact LeakPolicy = "redact" // 将原始值替换为对应代号后继续调用
+)
+
+// ParseLeakPolicy 解析泄露处理策略，未配置或无法识别时使用 redact
+func ParseLeakPolicy(policy string) LeakPolicy {
+	if LeakPolicy(strings.ToLower(strings.TrimSpace(policy))) == LeakPolicyBlock {
+		return LeakPolicyBlock
+	}
+	return LeakPolicyRedact
+}
+
+// DecodeReport 解码报告
+type DecodeReport struct {
+	Text         string   `json:"text"`          // 解码后的文本
+	Replacements int      `json:"replacements"`  // 被还原的代号出现次数
+	MutatedCodes []string `json:"mutated_codes"` // 经容错还原的变形代号（按原文记录）
+	UnknownCodes []string `json:"unknown_codes"` // 形似代号但会话中不存在的代号（按原文记录，保留在文本中）
+}
+
+// LeakFinding 提示词中发现的未匿名化原始维度值
+type LeakFinding struct {
+	Value string `json:"value"` // 原始维度值
+	Code  string `json:"code"`  // 该值对应的代号
+	Count int    `json:"count"` // 出现次数
+}
+
+// DecodeAIResponseWithReport 按代号边界解码AI响应，并报告变形和未知的代号
+// 只有前后不与字母、数字或下划线相连的完整代号才会被替换，避免误改嵌在其他单词中的片段
+func (session *LiteAnonymizationSession) DecodeAIResponseWithReport(aiText string) (*DecodeReport, error) {
+	if session == nil {
+		return nil, NewLiteAnonymizationError("会话为空", "SESSION_NULL")
+	}
+
+	report := &DecodeReport{Text: aiText}
+	if aiText == "" {
+		return report, nil
+	}
+
+	folded, offsets := foldFullWidth(aiText)
+	matches := codeCandidatePattern.FindAllStringSubmatchIndex(folded, -1)
+
+	var builder strings.Builder
+	last := 0
+	mutated := make(map[string]bool)
+	unknown := make(map[string]bool)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if !isCodeBoundary(folded, start, end) {
+			continue
+		}
+
+		canonical := canonicalCode(folded, m)
+		raw := aiText[offsets[start]:offsets[end]]
+		original, ok := session.ReverseMap[canonical]
+		if !ok {
+			unknown[raw] = true
+			continue
+		}
+		if raw != canonical {
+			mutated[raw] = true
+		}
+
+		builder.WriteString(aiText[last:offsets[start]])
+		builder.WriteString(original)
+		last = offsets[end]
+		report.Replacements++
+	}
+	builder.WriteString(aiText[last:])
+
+	report.Text = builder.String()
+	report.MutatedCodes = sortedKeys(mutated)
+	report.UnknownCodes = sortedKeys(unknown)
+
+	if len(report.UnknownCodes) > 0 {
+		global.GVA_LOG.Warn("AI响应中存在无法解码的代号", zap.Strings("unknownCodes", report.UnknownCodes))
+	}
+	return report, nil
+}
+
+// ScanPromptLeaks 扫描即将发送给模型的文本，查找会话中已被匿名化的原始维度值
+// 维度名称本身作为语义描述提供给模型，不视为泄露；纯数字和过短的值误报过多，不参与扫描
+func (session *LiteAnonymizationSession) ScanPromptLeaks(prompt string) []LeakFinding {
+	_, findings := session.redactLeaks(prompt)
+	return findings
+}
+
+// GuardPrompt 按策略处理提示词中泄露的原始维度值
+// block 策略下发现泄露时返回错误；redact 策略下将原始值替换为对应代号
+func (session *LiteAnonymizationSession) GuardPrompt(prompt string, policy LeakPolicy) (string, []LeakFinding, error) {
+	redacted, findings := session.redactLeaks(prompt)
+	if len(findings) == 0 {
+		return prompt, nil, nil
+	}
+
+	codes := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		codes = append(codes, finding.Code)
+	}
+	global.GVA_LOG.Warn("提示词中发现未匿名化的原始维度值",
+		zap.Strings("codes", codes),
+		zap.String("policy", string(policy)))
+
+	if policy == LeakPolicyBlock {
+		return "", findings, NewLiteAnonymizationError(
+			fmt.Sprintf("提示词中包含%d个未匿名化的维度值（%s），已阻止发送", len(findings), strings.Join(codes, ", ")),
+			"PROMPT_LEAK")
+	}
+	return redacted, findings, nil
+}
+
+// redactLeaks 将文本中的原始维度值替换为代号，并返回发现的泄露
+// 长值优先处理并先行替换，避免 "北京" 重复命中 "北京朝阳" 中的片段
+func (session *LiteAnonymizationSession) redactLeaks(prompt string) (string, []LeakFinding) {
+	if session == nil || prompt == "" {
+		return prompt, nil
+	}
+
+	var findings []LeakFinding
+	redacted := prompt
+	for _, candidate := range session.leakCandidates() {
+		if count := len(findValueOccurrences(redacted, candidate.Value)); count > 0 {
+			candidate.Count = count
+			findings = append(findings, candidate)
+			redacted = replaceValueOccurrences(redacted, candidate.Value, candidate.Code)
+		}
+	}
+	return redacted, findings
+}
+
+// leakCandidates 返回参与泄露扫描的维度值，按长度降序排列
+func (session *LiteAnonymizationSession) leakCandidates() []LeakFinding {
+	var candidates []LeakFinding
+	for key, code := range session.ForwardMap {
+		// 维度值的正向映射键为 "维度名:维度值"，维度名本身的映射不参与扫描
+		if !strings.Contains(key, ":") {
+			continue
+		}
+		value, ok := session.ReverseMap[code]
+		if !ok || utf8.RuneCountInString(value) < minLeakValueRunes {
+			continue
+		}
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			continue
+		}
+		candidates = append(candidates, LeakFinding{Value: value, Code: code})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i].Value) != len(candidates[j].Value) {
+			return len(candidates[i].Value) > len(candidates[j].Value)
+		}
+		return candidates[i].Code < candidates[j].Code
+	})
+	return candidates
+}
+
+// findValueOccurrences 查找维度值在文本中的出现位置
+// 以字母或数字开头/结尾的值要求两侧不与字母数字相连，避免 "AB" 命中 "ABC"
+func findValueOccurrences(text, value string) [][2]int {
+	var occurrences [][2]int
+	for offset := 0; offset < len(text); {
+		index := strings.Index(text[offset:], value)
+		if index < 0 {
+			break
+		}
+		start := offset + index
+		end := start + len(value)
+		if valueBoundaryOk(text, value, start, end) {
+			occurrences = append(occurrences, [2]int{start, end})
+			offset = end
+		} else {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			offset = start + size
+		}
+	}
+	return occurrences
+}
+
+// replaceValueOccurrences 将文本中满足边界条件的维度值替换为代号
+func replaceValueOccurrences(text, value, code string) string {
+	occurrences := findValueOccurrences(text, value)
+	if len(occurrences) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	last := 0
+	for _, occurrence := range occurrences {
+		builder.WriteString(text[last:occurrence[0]])
+		builder.WriteString(code)
+		last = occurrence[1]
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+func valueBoundaryOk(text, value string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(value)
+	if isASCIIWordRune(first) && start > 0 {
+		if prev, _ := utf8.DecodeLastRuneInString(text[:start]); isASCIIWordRune(prev) {
+			return false
+		}
+	}
+	lastRune, _ := utf8.DecodeLastRuneInString(value)
+	if isASCIIWordRune(lastRune) && end < len(text) {
+		if next, _ := utf8.DecodeRuneInString(text[end:]); isASCIIWordRune(next) {
+			return false
+		}
+	}
+	return true
+}
+
+// isCodeBoundary 判断匹配到的代号前后是否与其他单词相连
+func isCodeBoundary(text string, start, end int) bool {
+	if start > 0 {
+		if prev, _ := utf8.DecodeLastRuneInString(text[:start]); isASCIIWordRune(prev) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if next, _ := utf8.DecodeRuneInString(text[end:]); isASCIIWordRune(next) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalCode 将匹配到的代号还原为会话中的标准写法（DIM01 / DIM01_V03）
+func canonicalCode(text string, match []int) string {
+	dimNumber, _ := strconv.Atoi(text[match[2]:match[3]])
+	code := fmt.Sprintf("DIM%02d", dimNumber)
+	if match[4] >= 0 {
+		valueNumber, _ := strconv.Atoi(text[match[4]:match[5]])
+		code = fmt.Sprintf("%s_V%02d", code, valueNumber)
+	}
+	return code
+}
+
+// foldFullWidth 将全角ASCII字符折叠为半角，并返回折叠后文本每个字节位置对应的原文字节位置
+func foldFullWidth(text string) (string, []int) {
+	var builder strings.Builder
+	builder.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		}
+		// 非法UTF-8字节由 range 解析为替换字符，同样按其编码长度记录位置
+		size := utf8.RuneLen(r)
+		builder.WriteRune(r)
+		for k := 0; k < size; k++ {
+			offsets = append(offsets, i)
+		}
+	}
+	offsets = append(offsets, len(text))
+	return builder.String(), offsets
+}
+
+func isASCIIWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
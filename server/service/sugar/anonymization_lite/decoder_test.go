@@ -0,0 +1,123 @@
+package anonymization_lite
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"go.uber.org/zap"
+)
+
+func newDecoderTestSession() *LiteAnonymizationSession {
+	global.GVA_LOG = zap.NewNop()
+	forward := map[string]string{
+		"城市": "DIM01", "城市:北京": "DIM01_V01", "城市:上海": "DIM01_V02",
+		"区域:北京朝阳": "DIM02_V01", "产品:AB": "DIM03_V01", "年份:2024": "DIM04_V01", "等级:A": "DIM05_V01",
+	}
+	reverse := make(map[string]string, len(forward))
+	for key, code := range forward {
+		value := key
+		for i := 0; i < len(key); i++ {
+			if key[i] == ':' {
+				value = key[i+1:]
+				break
+			}
+		}
+		reverse[code] = value
+	}
+	return &LiteAnonymizationSession{ForwardMap: forward, ReverseMap: reverse}
+}
+
+func TestDecodeAIResponseWithReport(t *testing.T) {
+	session := newDecoderTestSession()
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		mutated []string
+		unknown []string
+	}{
+		{name: "标准代号", text: "DIM01 中 DIM01_V02 最高", want: "城市 中 上海 最高"},
+		{name: "与中文相连", text: "其中DIM01_V02增长最快", want: "其中上海增长最快"},
+		{name: "前面与字母相连", text: "xDIM01_V02 和 ID_DIM01_V01", want: "xDIM01_V02 和 ID_DIM01_V01"},
+		{name: "后面与字母或下划线相连", text: "DIM01_V02abc、DIM01_V01_x", want: "DIM01_V02abc、DIM01_V01_x"},
+		{name: "补零差异", text: "DIM001_V0002 与 dim1_v1", want: "上海 与 北京", mutated: []string{"DIM001_V0002", "dim1_v1"}},
+		{name: "带空格和连字符", text: "DIM 01 _ V 02、DIM01-V01", want: "上海、北京", mutated: []string{"DIM 01 _ V 02", "DIM01-V01"}},
+		{name: "全角字符", text: "ＤＩＭ０１＿Ｖ０２领先", want: "上海领先", mutated: []string{"ＤＩＭ０１＿Ｖ０２"}},
+		{name: "未知代号原样保留并报告", text: "DIM09_V99 与 DIM01_V01、dim9_v99", want: "DIM09_V99 与 北京、dim9_v99", unknown: []string{"DIM09_V99", "dim9_v99"}},
+	}
+	for _, tt := range tests {
+		report, err := session.DecodeAIResponseWithReport(tt.text)
+		if err != nil {
+			t.Fatalf("%s: 解码失败: %v", tt.name, err)
+		}
+		if report.Text != tt.want || !reflect.DeepEqual(report.MutatedCodes, tt.mutated) || !reflect.DeepEqual(report.UnknownCodes, tt.unknown) {
+			t.Errorf("%s: 解码结果 = %q 变形 %q 未知 %q，期望 %q 变形 %q 未知 %q",
+				tt.name, report.Text, report.MutatedCodes, report.UnknownCodes, tt.want, tt.mutated, tt.unknown)
+		}
+	}
+
+	var empty *LiteAnonymizationSession
+	if _, err := empty.DecodeAIResponseWithReport("DIM01"); err == nil {
+		t.Fatal("空会话应返回错误")
+	}
+}
+
+func TestGuardPrompt(t *testing.T) {
+	session := newDecoderTestSession()
+	prompt := "请分析北京朝阳和北京的数据，忽略 ABC、2024 和等级A，城市 维度不算泄露；AB 除外"
+
+	// redact：长值优先替换，维度名、数字、过短的值以及嵌在单词中的值不处理
+	redacted, findings, err := session.GuardPrompt(prompt, LeakPolicyRedact)
+	if err != nil {
+		t.Fatalf("redact 策略不应返回错误: %v", err)
+	}
+	if want := "请分析DIM02_V01和DIM01_V01的数据，忽略 ABC、2024 和等级A，城市 维度不算泄露；DIM03_V01 除外"; redacted != want {
+		t.Fatalf("替换结果 = %q，期望 %q", redacted, want)
+	}
+	want := []LeakFinding{{Value: "北京朝阳", Code: "DIM02_V01", Count: 1}, {Value: "北京", Code: "DIM01_V01", Count: 1}, {Value: "AB", Code: "DIM03_V01", Count: 1}}
+	if !reflect.DeepEqual(findings, want) {
+		t.Fatalf("泄露报告 = %+v，期望 %+v", findings, want)
+	}
+
+	// block：发现泄露时拒绝发送
+	blocked, findings, err := session.GuardPrompt(prompt, LeakPolicyBlock)
+	var liteErr *LiteAnonymizationError
+	if !errors.As(err, &liteErr) || liteErr.Code != "PROMPT_LEAK" || blocked != "" || len(findings) != 3 {
+		t.Fatalf("block 策略应阻止发送: %q %+v %v", blocked, findings, err)
+	}
+
+	// 没有泄露时原样返回
+	clean := "请分析 DIM01_V01 的数据"
+	if guarded, findings, err := session.GuardPrompt(clean, LeakPolicyBlock); err != nil || guarded != clean || findings != nil {
+		t.Fatalf("没有泄露时应原样返回: %q %+v %v", guarded, findings, err)
+	}
+}
+
+func TestParseLeakPolicy(t *testing.T) {
+	for input, want := range map[string]LeakPolicy{"block": LeakPolicyBlock, " BLOCK ": LeakPolicyBlock, "redact": LeakPolicyRedact, "": LeakPolicyRedact, "drop": LeakPolicyRedact} {
+		if got := ParseLeakPolicy(input); got != want {
+			t.Errorf("ParseLeakPolicy(%q) = %s，期望 %s", input, got, want)
+		}
+	}
+}
+
+func TestKeyedValueCodeExhausted(t *testing.T) {
+	session := newDecoderTestSession()
+	config := &LiteConfig{Deterministic: true, CodeKey: []byte("test-key")}
+	code, err := session.keyedValueCode(config, "DIM01", "城市", "广州")
+	if err != nil || !codeCandidatePattern.MatchString(code) {
+		t.Fatalf("编号未耗尽时应分配代号: %q, %v", code, err)
+	}
+
+	// 编号用尽后报错，不生成解码器无法识别的 V10000
+	for n := 1; n <= maxKeyedValueNumber; n++ {
+		session.ReverseMap[fmt.Sprintf("DIM01_V%02d", n)] = fmt.Sprintf("城市%d", n)
+	}
+	var liteErr *LiteAnonymizationError
+	if code, err = session.keyedValueCode(config, "DIM01", "城市", "广州"); !errors.As(err, &liteErr) || liteErr.Code != "CODE_SPACE_EXHAUSTED" {
+		t.Fatalf("编号耗尽时应返回错误: %q, %v", code, err)
+	}
+}
@@ -0,0 +1,68 @@
+package anonymization_lite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+const (
+	// maxKeyedDimensionNumber 确定性模式下维度代号编号上限（DIM01-DIM99）
+	maxKeyedDimensionNumber = 99
+	// maxKeyedValueNumber 确定性模式下值代号编号上限（V01-V9999），与解码器可识别的位数一致
+	maxKeyedValueNumber = 9999
+)
+
+// isDeterministic 是否启用确定性模式
+func (c *LiteConfig) isDeterministic() bool {
+	return c.Deterministic && len(c.CodeKey) > 0
+}
+
+// keyedNumber 使用 HMAC-SHA256 将原始名称映射为 1..limit 的编号
+func (c *LiteConfig) keyedNumber(kind, original string, limit int) int {
+	mac := hmac.New(sha256.New, c.CodeKey)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(original))
+	sum := mac.Sum(nil)
+	return int(binary.BigEndian.Uint64(sum[:8])%uint64(limit)) + 1
+}
+
+// keyedDimensionCode 确定性模式下的维度代号，编号冲突时顺延到下一个未使用的编号
+func (session *LiteAnonymizationSession) keyedDimensionCode(config *LiteConfig, dimName string) (string, error) {
+	return session.probeCode(config.keyedNumber("dimension", dimName, maxKeyedDimensionNumber), maxKeyedDimensionNumber, func(n int) string {
+		return fmt.Sprintf("DIM%02d", n)
+	})
+}
+
+// keyedValueCode 确定性模式下的值代号，编号冲突时顺延到下一个未使用的编号
+func (session *LiteAnonymizationSession) keyedValueCode(config *LiteConfig, dimCode, dimName, dimValue string) (string, error) {
+	return session.probeCode(config.keyedNumber("value", dimName+":"+dimValue, maxKeyedValueNumber), maxKeyedValueNumber, func(n int) string {
+		return fmt.Sprintf("%s_V%02d", dimCode, n)
+	})
+}
+
+// probeCode 从 start 开始查找会话中未使用的代号；编号耗尽时返回错误，不生成解码器无法识别的超限代号
+func (session *LiteAnonymizationSession) probeCode(start, limit int, format func(int) string) (string, error) {
+	for i := 0; i < limit; i++ {
+		code := format((start-1+i)%limit + 1)
+		if _, used := session.ReverseMap[code]; !used {
+			return code, nil
+		}
+	}
+	return "", NewLiteAnonymizationError(fmt.Sprintf("确定性代号已用尽（上限 %d 个）", limit), "CODE_SPACE_EXHAUSTED")
+}
+
+// noiseSource 返回贡献项数值加噪使用的均匀随机数来源
+// 确定性模式下以 RandomSeed 和维度值组合播种，同一贡献项在每次分析中得到相同的噪声，与处理顺序无关
+func (s *LiteAnonymizationService) noiseSource(dimensionValues map[string]interface{}) func() float64 {
+	if !s.config.Deterministic {
+		return rand.Float64
+	}
+	hasher := fnv.New64a()
+	hasher.Write([]byte(dimensionTupleKey(dimensionValues)))
+	return rand.New(rand.NewPCG(uint64(s.config.RandomSeed), hasher.Sum64())).Float64
+}
@@ -0,0 +1,205 @@
+package anonymization_lite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"go.uber.org/zap"
+)
+
+// GeneralizedValue 不满足 k/l 阈值的敏感维度取值合并后使用的取值
+const GeneralizedValue = "其他（已合并）"
+
+// isSensitiveDimension 判断维度是否被策略标记为敏感
+func (c *LiteConfig) isSensitiveDimension(dimName string) bool {
+	for _, name := range c.SensitiveDimensions {
+		if name == dimName {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldCodeValue 判断维度值是否需要编码
+// 敏感维度和必须编码的值始终编码；其余维度值只在未关闭匿名化时编码
+func (c *LiteConfig) shouldCodeValue(dimName, value string) bool {
+	if c.isSensitiveDimension(dimName) {
+		return true
+	}
+	for _, item := range c.AlwaysCodeValues {
+		if item == value || item == dimName+":"+value {
+			return true
+		}
+	}
+	return !c.Passthrough
+}
+
+// generalizeContributions 按 k/l 阈值泛化敏感维度
+// 某个取值对应的贡献项少于 k 个，或其贡献项的趋势方向少于 l 种时，该取值并入"其他"，泛化后维度组合相同的贡献项合并为一项；
+// 未声明敏感维度时检查全部维度
+func (c *LiteConfig) generalizeContributions(contributions []ContributionItem) []ContributionItem {
+	if c.KAnonymity <= 0 && c.LDiversity <= 0 {
+		return contributions
+	}
+
+	dimensions := c.generalizationDimensions(contributions)
+	items := make([]ContributionItem, len(contributions))
+	for i, item := range contributions {
+		values := make(map[string]interface{}, len(item.DimensionValues))
+		for k, v := range item.DimensionValues {
+			values[k] = v
+		}
+		item.DimensionValues = values
+		items[i] = item
+	}
+
+	generalized := 0
+	for _, dimName := range dimensions {
+		counts := make(map[string]int)
+		directions := make(map[string]map[string]bool)
+		for _, item := range items {
+			value, ok := item.DimensionValues[dimName]
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%v", value)
+			counts[key]++
+			if directions[key] == nil {
+				directions[key] = make(map[string]bool)
+			}
+			directions[key][item.TrendDirection] = true
+		}
+
+		rare := make(map[string]bool)
+		for value, count := range counts {
+			if value == GeneralizedValue {
+				continue
+			}
+			if count < c.KAnonymity || len(directions[value]) < c.LDiversity {
+				rare[value] = true
+			}
+		}
+		if len(rare) == 0 {
+			continue
+		}
+		for i := range items {
+			if value, ok := items[i].DimensionValues[dimName]; ok && rare[fmt.Sprintf("%v", value)] {
+				items[i].DimensionValues[dimName] = GeneralizedValue
+			}
+		}
+		generalized += len(rare)
+	}
+
+	if generalized == 0 {
+		return contributions
+	}
+	merged := mergeContributions(items)
+
+	global.GVA_LOG.Info("按k/l阈值泛化敏感维度",
+		zap.Int("kAnonymity", c.KAnonymity),
+		zap.Int("lDiversity", c.LDiversity),
+		zap.Int("generalizedValues", generalized),
+		zap.Int("originalCount", len(contributions)),
+		zap.Int("mergedCount", len(merged)))
+
+	return merged
+}
+
+// generalizationDimensions 返回参与 k/l 检查的维度（按名称排序，保证泛化结果确定）
+func (c *LiteConfig) generalizationDimensions(contributions []ContributionItem) []string {
+	present := make(map[string]bool)
+	for _, item := range contributions {
+		for dimName := range item.DimensionValues {
+			present[dimName] = true
+		}
+	}
+
+	var dimensions []string
+	for dimName := range present {
+		if len(c.SensitiveDimensions) == 0 || c.isSensitiveDimension(dimName) {
+			dimensions = append(dimensions, dimName)
+		}
+	}
+	sort.Strings(dimensions)
+	return dimensions
+}
+
+// mergeContributions 合并维度组合相同的贡献项，保持首次出现的顺序
+func mergeContributions(items []ContributionItem) []ContributionItem {
+	var merged []ContributionItem
+	index := make(map[string]int)
+	groups := make(map[string][]ContributionItem)
+	for _, item := range items {
+		key := dimensionTupleKey(item.DimensionValues)
+		if _, ok := index[key]; !ok {
+			index[key] = len(merged)
+			merged = append(merged, item)
+		}
+		groups[key] = append(groups[key], item)
+	}
+
+	for key, group := range groups {
+		if len(group) > 1 {
+			merged[index[key]] = mergeContributionGroup(group)
+		}
+	}
+	return merged
+}
+
+// mergeContributionGroup 将一组贡献项合并为一项：贡献度相加，变化率在有绝对值时按合计重新计算，否则取平均
+func mergeContributionGroup(group []ContributionItem) ContributionItem {
+	result := ContributionItem{DimensionValues: group[0].DimensionValues}
+	absolute := &AbsoluteValues{}
+	var changeRateSum float64
+	for _, item := range group {
+		result.ContributionPercent += item.ContributionPercent
+		result.RelativeImportance = math.Max(result.RelativeImportance, item.RelativeImportance)
+		changeRateSum += item.ChangeRatePercent
+		if absolute != nil && item.AbsoluteValues != nil {
+			absolute.CurrentValue += item.AbsoluteValues.CurrentValue
+			absolute.BaseValue += item.AbsoluteValues.BaseValue
+			absolute.ChangeValue += item.AbsoluteValues.ChangeValue
+		} else {
+			absolute = nil
+		}
+	}
+	result.AbsoluteValues = absolute
+	result.IsPositiveDriver = result.ContributionPercent >= 0
+
+	result.ChangeRatePercent = changeRateSum / float64(len(group))
+	direction := result.ChangeRatePercent
+	if absolute != nil {
+		direction = absolute.ChangeValue
+		if absolute.BaseValue != 0 {
+			result.ChangeRatePercent = absolute.ChangeValue / absolute.BaseValue * 100
+		}
+	}
+	switch {
+	case direction > 0:
+		result.TrendDirection = "增长"
+	case direction < 0:
+		result.TrendDirection = "下降"
+	default:
+		result.TrendDirection = "持平"
+	}
+
+	result.ImpactLevel = "低"
+	if absContribution := math.Abs(result.ContributionPercent); absContribution >= 10.0 {
+		result.ImpactLevel = "高"
+	} else if absContribution >= 3.0 {
+		result.ImpactLevel = "中"
+	}
+	return result
+}
+
+func dimensionTupleKey(values map[string]interface{}) string {
+	parts := make([]string, 0, len(values))
+	for dimName, value := range values {
+		parts = append(parts, fmt.Sprintf("%s=%v", dimName, value))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
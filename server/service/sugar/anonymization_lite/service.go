@@ -3,8 +3,8 @@ package anonymization_lite
 import (
 	"fmt"
 	"math"
-	"math/rand/v2"
 	"strings"
+	"time"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"go.uber.org/zap"
@@ -30,7 +30,15 @@ func NewLiteAnonymizationService(config *LiteConfig) *LiteAnonymizationService {
 // 输入：贡献度分析结果（已计算好的数据）
 // 输出：匿名化后的AI可读文本
 func (s *LiteAnonymizationService) ProcessContributionData(contributions []ContributionItem) (*LiteAnonymizationSession, error) {
-	global.GVA_LOG.Info("开始处理匿名化贡献度数据", zap.Int("itemCount", len(contributions)))
+	return s.ProcessContributionDataWithSession(nil, contributions)
+}
+
+// ProcessContributionDataWithSession 在已有会话的代号基础上处理贡献度数据
+// 同一对话的多轮分析沿用相同的维度和值代号，新出现的维度值在已有编号之后继续编号；previous 为空时等同于 ProcessContributionData
+func (s *LiteAnonymizationService) ProcessContributionDataWithSession(previous *LiteAnonymizationSession, contributions []ContributionItem) (*LiteAnonymizationSession, error) {
+	global.GVA_LOG.Info("开始处理匿名化贡献度数据",
+		zap.Int("itemCount", len(contributions)),
+		zap.Bool("reuseSession", previous != nil))
 
 	if len(contributions) == 0 {
 		return nil, fmt.Errorf("贡献度数据为空")
@@ -43,31 +51,58 @@ func (s *LiteAnonymizationService) ProcessContributionData(contributions []Contr
 		AIReadyData:        make([]map[string]interface{}, 0),
 		DimensionSemantics: make(map[string]*DimensionSemanticInfo),
 		Config:             s.config,
+		CreatedAt:          time.Now(),
 	}
 
+	// 按策略的 k/l 阈值泛化敏感维度中过于稀少或单一的取值
+	contributions = s.config.generalizeContributions(contributions)
+
 	// 维度计数器，用于生成唯一代号
 	dimensionCounters := make(map[string]int)
 	valueCounters := make(map[string]int)
 
+	// 继承已有会话的映射，并从已有代号恢复计数器，避免新代号与旧代号冲突
+	if previous != nil {
+		session.inheritMappings(previous, dimensionCounters, valueCounters)
+	}
+
 	// 处理每个贡献项
 	for i, contribution := range contributions {
 		aiItem := make(map[string]interface{})
 
-		// 处理维度值的匿名化
-		for dimName, dimValue := range contribution.DimensionValues {
-			anonymizedDimName := s.getOrCreateAnonymizedDimension(session, dimName, dimensionCounters)
-			anonymizedDimValue := s.getOrCreateAnonymizedValue(session, dimName, fmt.Sprintf("%v", dimValue), valueCounters)
-
-			aiItem[anonymizedDimName] = anonymizedDimValue
+		// 处理维度值的匿名化，策略不要求编码的维度值原样输出；按维度名顺序处理，保证代号分配与输入顺序一致
+		for _, dimName := range sortedDimensionNames(contribution.DimensionValues) {
+			dimValue := contribution.DimensionValues[dimName]
+			anonymizedDimName, err := s.getOrCreateAnonymizedDimension(session, dimName, dimensionCounters)
+			if err != nil {
+				return nil, err
+			}
+			value := fmt.Sprintf("%v", dimValue)
+			if s.config.shouldCodeValue(dimName, value) {
+				if aiItem[anonymizedDimName], err = s.getOrCreateAnonymizedValue(session, dimName, value, valueCounters); err != nil {
+					return nil, err
+				}
+			} else {
+				aiItem[anonymizedDimName] = value
+			}
 		}
 
-		// 添加增强的数值数据（包含变化率等信息）
-		aiItem["contribution_percent"] = s.anonymizeNumericValue(contribution.ContributionPercent)
+		// 添加增强的数值数据（包含变化率等信息），粗化输出时只保留定性字段
+		uniform := s.noiseSource(contribution.DimensionValues)
 		aiItem["is_positive_driver"] = contribution.IsPositiveDriver
-		aiItem["change_rate_percent"] = s.anonymizeNumericValue(contribution.ChangeRatePercent)
 		aiItem["trend_direction"] = contribution.TrendDirection
 		aiItem["impact_level"] = contribution.ImpactLevel
-		aiItem["relative_importance"] = s.anonymizeNumericValue(contribution.RelativeImportance)
+		if !s.config.Coarse {
+			aiItem["contribution_percent"] = s.anonymizeNumericValue(contribution.ContributionPercent, uniform)
+			aiItem["change_rate_percent"] = s.anonymizeNumericValue(contribution.ChangeRatePercent, uniform)
+			aiItem["relative_importance"] = s.anonymizeNumericValue(contribution.RelativeImportance, uniform)
+			// 绝对值不加噪，只在策略明确允许时输出
+			if s.config.AllowAbsoluteValues && contribution.AbsoluteValues != nil {
+				aiItem["current_value"] = math.Round(contribution.AbsoluteValues.CurrentValue*100) / 100
+				aiItem["base_value"] = math.Round(contribution.AbsoluteValues.BaseValue*100) / 100
+				aiItem["change_value"] = math.Round(contribution.AbsoluteValues.ChangeValue*100) / 100
+			}
+		}
 
 		session.AIReadyData = append(session.AIReadyData, aiItem)
 
@@ -80,6 +115,10 @@ func (s *LiteAnonymizationService) ProcessContributionData(contributions []Contr
 		}
 	}
 
+	session.MappingCount = len(session.ForwardMap)
+	session.ContributionCount = len(contributions)
+	session.EpsilonSpent += s.epsilonCost()
+
 	global.GVA_LOG.Info("匿名化处理完成",
 		zap.Int("forwardMapSize", len(session.ForwardMap)),
 		zap.Int("reverseMapSize", len(session.ReverseMap)),
@@ -139,11 +178,38 @@ func (s *LiteAnonymizationService) generateSemanticValueCode(dimCode, value stri
 	return fmt.Sprintf("%s_%s%02d", dimCode, suffix, sequence)
 }
 
-// anonymizeNumericValue 对数值进行轻微匿名化处理
-func (s *LiteAnonymizationService) anonymizeNumericValue(value float64) float64 {
-	// 使用配置的噪声级别
-	maxPerturbation := s.config.NoiseLevel * 100 // 转换为百分比
-	perturbation := (rand.Float64() - 0.5) * 2 * maxPerturbation
+// numericFieldCount 每个贡献项中加噪的数值字段数，ε 在这些字段间平均分配
+const numericFieldCount = 3
+
+// epsilonCost 本次处理消耗的隐私预算
+// 各贡献项对应互不相交的维度组合（并行组合），同一贡献项的数值字段按顺序组合分摊 ε，因此整次处理消耗 ε
+func (s *LiteAnonymizationService) epsilonCost() float64 {
+	if s.config.Coarse || s.config.Passthrough || s.config.Epsilon <= 0 {
+		return 0
+	}
+	return s.config.Epsilon
+}
+
+// anonymizeNumericValue 对数值进行轻微匿名化处理，uniform 为 [0,1) 均匀分布的随机数来源
+func (s *LiteAnonymizationService) anonymizeNumericValue(value float64, uniform func() float64) float64 {
+	// 策略关闭匿名化时原样输出
+	if s.config.Passthrough {
+		return math.Round(value*100) / 100
+	}
+
+	var perturbation float64
+	if s.config.Epsilon > 0 {
+		// 拉普拉斯机制：scale = 敏感度 / 单字段分得的 ε
+		sensitivity := s.config.Sensitivity
+		if sensitivity <= 0 {
+			sensitivity = 1.0
+		}
+		perturbation = laplaceNoise(sensitivity/(s.config.Epsilon/numericFieldCount), uniform)
+	} else {
+		// 使用配置的噪声级别
+		maxPerturbation := s.config.NoiseLevel * 100 // 转换为百分比
+		perturbation = (uniform() - 0.5) * 2 * maxPerturbation
+	}
 	anonymizedValue := value + perturbation
 
 	// 确保百分比在合理范围内
@@ -157,6 +223,15 @@ func (s *LiteAnonymizationService) anonymizeNumericValue(value float64) float64
 	return math.Round(anonymizedValue*100) / 100
 }
 
+// laplaceNoise 使用反函数法生成拉普拉斯噪声
+func laplaceNoise(scale float64, uniform func() float64) float64 {
+	u := uniform() - 0.5
+	for u == -0.5 {
+		u = uniform() - 0.5
+	}
+	return -scale * math.Copysign(math.Log(1-2*math.Abs(u)), u)
+}
+
 // ProcessAndSerialize 处理贡献度数据并序列化为AI可读文本
 func (s *LiteAnonymizationService) ProcessAndSerialize(contributions []ContributionItem) (string, *LiteAnonymizationSession, error) {
 	session, err := s.ProcessContributionData(contributions)
@@ -172,6 +247,21 @@ func (s *LiteAnonymizationService) ProcessAndSerialize(contributions []Contribut
 	return text, session, nil
 }
 
+// ProcessAndSerializeWithSession 在已有会话基础上处理贡献度数据并序列化为AI可读文本
+func (s *LiteAnonymizationService) ProcessAndSerializeWithSession(previous *LiteAnonymizationSession, contributions []ContributionItem) (string, *LiteAnonymizationSession, error) {
+	session, err := s.ProcessContributionDataWithSession(previous, contributions)
+	if err != nil {
+		return "", nil, err
+	}
+
+	text, err := session.SerializeToText()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return text, session, nil
+}
+
 // DecodeResponse 解码AI响应（使用会话方法）
 func (s *LiteAnonymizationService) DecodeResponse(session *LiteAnonymizationSession, aiResponse string) (string, error) {
 	return session.DecodeAIResponse(aiResponse)
@@ -10,8 +10,27 @@ type LiteConfig struct {
 	// 噪声控制
 	NoiseLevel float64 `json:"noise_level"` // 统一的噪声级别 (0.0-1.0)
 
-	// 随机种子
+	// 随机种子，确定性模式下用于播种数值噪声
 	RandomSeed int64 `json:"random_seed"`
+
+	// 确定性模式：代号由 CodeKey 经 HMAC 派生，噪声由 RandomSeed 按贡献项播种，相同输入得到相同的代号和数值
+	Deterministic bool   `json:"deterministic"`
+	CodeKey       []byte `json:"-"` // 代号派生密钥（按团队/语义模型派生），不随会话序列化
+
+	// 差分隐私：Epsilon > 0 时数值字段使用按 Sensitivity/ε 校准的拉普拉斯噪声并计入预算，为0时使用 NoiseLevel 均匀噪声
+	Epsilon     float64 `json:"epsilon"`
+	Sensitivity float64 `json:"sensitivity"` // 百分比字段的敏感度（百分点）
+
+	// 粗化输出：隐私预算耗尽时只保留趋势方向、影响程度等定性字段，不输出数值
+	Coarse bool `json:"coarse"`
+
+	// 匿名化策略（由语义模型和Agent上的匿名化策略解析得到）
+	Passthrough         bool     `json:"passthrough"`           // 不做匿名化：普通维度值原样输出、数值不加噪；敏感维度和必须编码的值仍然编码
+	SensitiveDimensions []string `json:"sensitive_dimensions"`  // 敏感维度：值一律编码，维度说明中不出现原始维度名
+	AlwaysCodeValues    []string `json:"always_code_values"`    // 必须编码的维度值，格式为 "维度名:值" 或 "值"
+	KAnonymity          int      `json:"k_anonymity"`           // 敏感维度的每个取值至少对应的贡献项数，不足时并入"其他"，0 表示不检查
+	LDiversity          int      `json:"l_diversity"`           // 敏感维度的每个取值下至少出现的趋势方向种数，不足时并入"其他"，0 表示不检查
+	AllowAbsoluteValues bool     `json:"allow_absolute_values"` // 是否允许输出本期值、基期值和变化值等绝对值
 }
 
 // DefaultLiteConfig 返回默认的简化配置
@@ -20,6 +39,7 @@ func DefaultLiteConfig() *LiteConfig {
 		UseSemanticMapping: true,
 		NoiseLevel:         0.1, // 10%的轻微噪声
 		RandomSeed:         time.Now().UnixNano(),
+		Sensitivity:        1.0,
 	}
 }
 
@@ -34,6 +54,16 @@ type ContributionItem struct {
 	TrendDirection     string  `json:"trend_direction"`     // 趋势方向："增长"、"下降"、"持平"
 	ImpactLevel        string  `json:"impact_level"`        // 影响程度："高"、"中"、"低"
 	RelativeImportance float64 `json:"relative_importance"` // 相对重要性（0-100，基于贡献度绝对值的排名百分位）
+
+	// 绝对值，仅在匿名化策略允许时输出；无法得到准确绝对值的数据源（如下钻汇总结果）为空
+	AbsoluteValues *AbsoluteValues `json:"absolute_values,omitempty"`
+}
+
+// AbsoluteValues 贡献项的绝对值
+type AbsoluteValues struct {
+	CurrentValue float64 `json:"current_value"` // 本期值
+	BaseValue    float64 `json:"base_value"`    // 基期值
+	ChangeValue  float64 `json:"change_value"`  // 变化值
 }
 
 // DimensionSemanticInfo 维度语义信息
@@ -62,6 +92,9 @@ type LiteAnonymizationSession struct {
 	// 统计信息
 	MappingCount      int `json:"mapping_count"`
 	ContributionCount int `json:"contribution_count"`
+
+	// 差分隐私预算消耗（同一对话复用会话时累计）；均匀噪声和粗化输出不计入预算
+	EpsilonSpent float64 `json:"epsilon_spent"`
 }
 
 // AIAnalysisRequest AI分析请求（简化版）
@@ -0,0 +1,300 @@
+package sugar
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"gorm.io/datatypes"
+)
+
+const (
+	// AnonymizerLite 代号编码加均匀噪声，不消耗隐私预算
+	AnonymizerLite = "lite"
+	// AnonymizerAdvanced 代号编码加 k-匿名/l-多样性泛化和按隐私预算校准的拉普拉斯噪声
+	AnonymizerAdvanced = "advanced"
+
+	// 策略字段的来源
+	policySourceDefault = "default"
+	policySourceModel   = "model"
+	policySourceAgent   = "agent"
+)
+
+// AnonymizationPolicy 匿名化策略，配置在语义模型上，Agent 可在其基础上进一步收紧
+// 未设置的字段（nil 或空字符串）沿用上一层的取值
+type AnonymizationPolicy struct {
+	Enabled             *bool    `json:"enabled,omitempty"`             // 是否匿名化，false 时普通维度值原样发送且数值不加噪
+	Anonymizer          string   `json:"anonymizer,omitempty"`          // 匿名化方式：lite / advanced
+	SensitiveDimensions []string `json:"sensitiveDimensions,omitempty"` // 敏感维度，值始终编码且不向模型透露维度名称
+	AlwaysCodeValues    []string `json:"alwaysCodeValues,omitempty"`    // 始终编码的维度值，格式为 "维度名:值" 或 "值"
+	KAnonymity          *int     `json:"kAnonymity,omitempty"`          // k-匿名阈值，仅 advanced 生效
+	LDiversity          *int     `json:"lDiversity,omitempty"`          // l-多样性阈值，仅 advanced 生效
+	NoiseLevel          *float64 `json:"noiseLevel,omitempty"`          // 均匀噪声级别 (0.0-1.0)，仅 lite 生效
+	Epsilon             *float64 `json:"epsilon,omitempty"`             // 每次分析消耗的ε，仅 advanced 生效，为空时使用全局隐私预算配置
+	AllowAbsoluteValues *bool    `json:"allowAbsoluteValues,omitempty"` // 是否允许向模型发送绝对值
+	LeakPolicy          string   `json:"leakPolicy,omitempty"`          // 提示词泄露处理策略：block / redact
+	Deterministic       *bool    `json:"deterministic,omitempty"`       // 确定性模式：代号由团队/语义模型密钥派生，噪声由语义模型的种子播种
+}
+
+// ResolvedAnonymizationPolicy 解析后生效的匿名化策略，随每次AI调用记录到执行日志
+type ResolvedAnonymizationPolicy struct {
+	ModelName           string            `json:"modelName"`
+	AgentId             string            `json:"agentId,omitempty"`
+	Enabled             bool              `json:"enabled"`
+	Anonymizer          string            `json:"anonymizer"`
+	SensitiveDimensions []string          `json:"sensitiveDimensions"`
+	AlwaysCodeValues    []string          `json:"alwaysCodeValues"`
+	KAnonymity          int               `json:"kAnonymity"`
+	LDiversity          int               `json:"lDiversity"`
+	NoiseLevel          float64           `json:"noiseLevel"`
+	Epsilon             float64           `json:"epsilon"`
+	AllowAbsoluteValues bool              `json:"allowAbsoluteValues"`
+	LeakPolicy          string            `json:"leakPolicy"`
+	Deterministic       bool              `json:"deterministic"`
+	Seed                int64             `json:"-"`       // 确定性模式下使用的噪声种子，与代号密钥一样不记录到日志
+	Sources             map[string]string `json:"sources"` // 各字段的取值来源：default / model / agent
+
+	codeKey []byte // 确定性模式下的代号派生密钥，不记录到日志
+}
+
+// ParseAnonymizationPolicy 解析并校验匿名化策略，空值返回 nil
+func ParseAnonymizationPolicy(raw datatypes.JSON) (*AnonymizationPolicy, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" || trimmed == "{}" {
+		return nil, nil
+	}
+	var policy AnonymizationPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, fmt.Errorf("匿名化策略格式错误: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate 校验匿名化策略的取值范围
+func (p *AnonymizationPolicy) Validate() error {
+	switch p.Anonymizer {
+	case "", AnonymizerLite, AnonymizerAdvanced:
+	default:
+		return fmt.Errorf("匿名化策略的 anonymizer 只能为 %s 或 %s", AnonymizerLite, AnonymizerAdvanced)
+	}
+	if p.KAnonymity != nil && *p.KAnonymity < 0 {
+		return errors.New("匿名化策略的 kAnonymity 不能为负数")
+	}
+	if p.LDiversity != nil && *p.LDiversity < 0 {
+		return errors.New("匿名化策略的 lDiversity 不能为负数")
+	}
+	if p.NoiseLevel != nil && (*p.NoiseLevel < 0 || *p.NoiseLevel > 1) {
+		return errors.New("匿名化策略的 noiseLevel 必须在 0 到 1 之间")
+	}
+	if p.Epsilon != nil && *p.Epsilon <= 0 {
+		return errors.New("匿名化策略的 epsilon 必须大于 0")
+	}
+	switch anonymization_lite.LeakPolicy(strings.ToLower(strings.TrimSpace(p.LeakPolicy))) {
+	case "", anonymization_lite.LeakPolicyBlock, anonymization_lite.LeakPolicyRedact:
+	default:
+		return fmt.Errorf("匿名化策略的 leakPolicy 只能为 %s 或 %s", anonymization_lite.LeakPolicyBlock, anonymization_lite.LeakPolicyRedact)
+	}
+	return nil
+}
+
+// ResolveAnonymizationPolicy 按 默认值 → 语义模型 → Agent 的顺序解析匿名化策略
+// 语义模型的标量字段覆盖默认值，Agent 的标量字段只在更严格时生效；敏感维度和始终编码的值取各层并集，Agent 只能追加不能移除
+func ResolveAnonymizationPolicy(model *sugar.SugarSemanticModels, agent *sugar.SugarAgents) (*ResolvedAnonymizationPolicy, error) {
+	resolved := &ResolvedAnonymizationPolicy{
+		Enabled:       true,
+		Anonymizer:    AnonymizerAdvanced,
+		NoiseLevel:    anonymization_lite.DefaultLiteConfig().NoiseLevel,
+		LeakPolicy:    string(anonymizationLeakPolicy()),
+		Deterministic: global.GVA_CONFIG.Sugar.Anonymization.Deterministic,
+		Sources:       make(map[string]string),
+	}
+	for _, field := range []string{"enabled", "anonymizer", "kAnonymity", "lDiversity", "noiseLevel", "epsilon", "allowAbsoluteValues", "leakPolicy", "deterministic"} {
+		resolved.Sources[field] = policySourceDefault
+	}
+
+	if model != nil {
+		resolved.ModelName = safeDeref(model.Name)
+		policy, err := ParseAnonymizationPolicy(model.AnonymizationPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("语义模型「%s」%w", resolved.ModelName, err)
+		}
+		resolved.apply(policy, policySourceModel)
+	}
+	if agent != nil {
+		resolved.AgentId = safeDeref(agent.Id)
+		policy, err := ParseAnonymizationPolicy(agent.AnonymizationPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("Agent %w", err)
+		}
+		resolved.apply(policy, policySourceAgent)
+	}
+	return resolved, nil
+}
+
+// apply 将一层策略叠加到已解析的策略上
+// Agent 层只能收紧语义模型的保护：不能关闭匿名化或从 advanced 切换为 lite，
+// 不能调大ε、调小 k/l/噪声、允许绝对值或将泄露策略从 block 改为 redact，放宽的取值被忽略
+func (r *ResolvedAnonymizationPolicy) apply(policy *AnonymizationPolicy, source string) {
+	if policy == nil {
+		return
+	}
+	tightenOnly := source == policySourceAgent
+	if policy.Enabled != nil && (!tightenOnly || *policy.Enabled) {
+		r.Enabled, r.Sources["enabled"] = *policy.Enabled, source
+	}
+	if policy.Anonymizer != "" && (!tightenOnly || r.Anonymizer != AnonymizerAdvanced || policy.Anonymizer == AnonymizerAdvanced) {
+		r.Anonymizer, r.Sources["anonymizer"] = policy.Anonymizer, source
+	}
+	if policy.KAnonymity != nil && (!tightenOnly || *policy.KAnonymity >= r.KAnonymity) {
+		r.KAnonymity, r.Sources["kAnonymity"] = *policy.KAnonymity, source
+	}
+	if policy.LDiversity != nil && (!tightenOnly || *policy.LDiversity >= r.LDiversity) {
+		r.LDiversity, r.Sources["lDiversity"] = *policy.LDiversity, source
+	}
+	if policy.NoiseLevel != nil && (!tightenOnly || *policy.NoiseLevel >= r.NoiseLevel) {
+		r.NoiseLevel, r.Sources["noiseLevel"] = *policy.NoiseLevel, source
+	}
+	if policy.Epsilon != nil && (!tightenOnly || *policy.Epsilon <= r.effectiveEpsilon()) {
+		r.Epsilon, r.Sources["epsilon"] = *policy.Epsilon, source
+	}
+	if policy.AllowAbsoluteValues != nil && (!tightenOnly || !*policy.AllowAbsoluteValues) {
+		r.AllowAbsoluteValues, r.Sources["allowAbsoluteValues"] = *policy.AllowAbsoluteValues, source
+	}
+	if leakPolicy := anonymization_lite.ParseLeakPolicy(policy.LeakPolicy); policy.LeakPolicy != "" && (!tightenOnly || leakPolicy == anonymization_lite.LeakPolicyBlock) {
+		r.LeakPolicy, r.Sources["leakPolicy"] = string(leakPolicy), source
+	}
+	if policy.Deterministic != nil {
+		r.Deterministic, r.Sources["deterministic"] = *policy.Deterministic, source
+	}
+	r.SensitiveDimensions = mergePolicyList(r.SensitiveDimensions, policy.SensitiveDimensions)
+	r.AlwaysCodeValues = mergePolicyList(r.AlwaysCodeValues, policy.AlwaysCodeValues)
+}
+
+// effectiveEpsilon 当前生效的单次ε，策略未设置时为全局隐私预算配置
+func (r *ResolvedAnonymizationPolicy) effectiveEpsilon() float64 {
+	if r.Epsilon > 0 {
+		return r.Epsilon
+	}
+	perQuery, _, _, _ := privacyBudgetSettings()
+	return perQuery
+}
+
+// UsesPrivacyBudget 是否需要预留差分隐私预算
+func (r *ResolvedAnonymizationPolicy) UsesPrivacyBudget() bool {
+	return r.Enabled && r.Anonymizer == AnonymizerAdvanced
+}
+
+// LeakPolicyValue 提示词泄露处理策略
+func (r *ResolvedAnonymizationPolicy) LeakPolicyValue() anonymization_lite.LeakPolicy {
+	return anonymization_lite.ParseLeakPolicy(r.LeakPolicy)
+}
+
+// LiteConfig 按策略生成匿名化配置，隐私预算相关字段由 PrivacyBudgetGrant.ApplyTo 填充
+func (r *ResolvedAnonymizationPolicy) LiteConfig() *anonymization_lite.LiteConfig {
+	config := anonymization_lite.DefaultLiteConfig()
+	config.Passthrough = !r.Enabled
+	config.NoiseLevel = r.NoiseLevel
+	config.SensitiveDimensions = r.SensitiveDimensions
+	config.AlwaysCodeValues = r.AlwaysCodeValues
+	config.AllowAbsoluteValues = r.AllowAbsoluteValues
+	if r.Deterministic {
+		config.Deterministic = true
+		config.CodeKey = r.codeKey
+		config.RandomSeed = r.Seed
+	}
+	if r.Anonymizer == AnonymizerAdvanced {
+		config.KAnonymity = r.KAnonymity
+		config.LDiversity = r.LDiversity
+	}
+	return config
+}
+
+// mergePolicyList 合并策略中的列表字段，去重去空后排序，保证解析结果与配置顺序无关
+func mergePolicyList(base, extra []string) []string {
+	set := make(map[string]bool, len(base)+len(extra))
+	for _, item := range append(append([]string{}, base...), extra...) {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	merged := make([]string, 0, len(set))
+	for item := range set {
+		merged = append(merged, item)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// resolveAnonymizationPolicyForModel 读取语义模型并解析本次调用生效的匿名化策略
+// 确定性模式下同时准备语义模型的噪声种子和代号派生密钥
+func resolveAnonymizationPolicyForModel(ctx context.Context, modelName, userId string, agent *sugar.SugarAgents) (*ResolvedAnonymizationPolicy, error) {
+	model, err := (&SugarFormulaQueryService{}).getSemanticModel(ctx, modelName, userId)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ResolveAnonymizationPolicy(model, agent)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Deterministic {
+		if policy.Seed, err = ensureAnonymizationSeed(ctx, model); err != nil {
+			return nil, err
+		}
+		if policy.codeKey, err = anonymizationCodeKey(model); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// anonymizationCodeKey 按团队和语义模型派生确定性代号的 HMAC 密钥，不同模型的同一维度值得到不同代号
+func anonymizationCodeKey(model *sugar.SugarSemanticModels) ([]byte, error) {
+	secret, err := anonymizationSecret()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte("sugar-anonymization-codes:"+secret))
+	mac.Write([]byte(safeDeref(model.TeamId) + "/" + safeDeref(model.Id)))
+	return mac.Sum(nil), nil
+}
+
+// ensureAnonymizationSeed 返回语义模型的噪声种子，尚未生成时生成并保存；并发生成时以先写入的为准
+func ensureAnonymizationSeed(ctx context.Context, model *sugar.SugarSemanticModels) (int64, error) {
+	if model.AnonymizationSeed != nil {
+		return *model.AnonymizationSeed, nil
+	}
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("生成匿名化种子失败: %w", err)
+	}
+	seed := int64(binary.BigEndian.Uint64(buf[:]) >> 1)
+
+	err := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarSemanticModels{}).
+		Where("id = ? AND anonymization_seed IS NULL", *model.Id).
+		Update("anonymization_seed", seed).Error
+	if err != nil {
+		return 0, fmt.Errorf("保存匿名化种子失败: %w", err)
+	}
+	var stored sugar.SugarSemanticModels
+	if err := global.GVA_DB.WithContext(ctx).Select("anonymization_seed").Where("id = ?", *model.Id).First(&stored).Error; err != nil {
+		return 0, fmt.Errorf("读取匿名化种子失败: %w", err)
+	}
+	if stored.AnonymizationSeed == nil {
+		return 0, errors.New("读取匿名化种子失败")
+	}
+	model.AnonymizationSeed = stored.AnonymizationSeed
+	return *stored.AnonymizationSeed, nil
+}
@@ -0,0 +1,208 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/config"
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// policyView 解析结果中参与优先级判断的字段
+type policyView struct {
+	Enabled             bool
+	Anonymizer          string
+	KAnonymity          int
+	NoiseLevel          float64
+	Epsilon             float64
+	AllowAbsoluteValues bool
+	LeakPolicy          string
+	UsesPrivacyBudget   bool
+}
+
+func viewPolicy(r *ResolvedAnonymizationPolicy) policyView {
+	return policyView{r.Enabled, r.Anonymizer, r.KAnonymity, r.NoiseLevel, r.Epsilon, r.AllowAbsoluteValues, r.LeakPolicy, r.UsesPrivacyBudget()}
+}
+
+func TestResolveAnonymizationPolicy(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	previous := global.GVA_CONFIG.Sugar
+	defer func() { global.GVA_CONFIG.Sugar = previous }()
+	global.GVA_CONFIG.Sugar.Anonymization = config.Anonymization{}
+	global.GVA_CONFIG.Sugar.PrivacyBudget = config.PrivacyBudget{EpsilonPerQuery: 0.5}
+
+	defaults := policyView{Enabled: true, Anonymizer: AnonymizerAdvanced, NoiseLevel: 0.1, LeakPolicy: "redact", UsesPrivacyBudget: true}
+	strictModel := `{"anonymizer": "advanced", "kAnonymity": 5, "noiseLevel": 0.2, "epsilon": 0.3, "allowAbsoluteValues": false, "leakPolicy": "block"}`
+	strict := policyView{Enabled: true, Anonymizer: AnonymizerAdvanced, KAnonymity: 5, NoiseLevel: 0.2, Epsilon: 0.3, LeakPolicy: "block", UsesPrivacyBudget: true}
+	tests := []struct {
+		name    string
+		model   string
+		agent   string
+		want    policyView
+		sources map[string]string
+	}{
+		{name: "均未配置时使用默认值", want: defaults, sources: map[string]string{"enabled": policySourceDefault, "epsilon": policySourceDefault}},
+		{
+			name: "语义模型可以放宽默认值", model: `{"enabled": false, "anonymizer": "lite", "leakPolicy": "redact"}`,
+			want:    policyView{Anonymizer: AnonymizerLite, NoiseLevel: 0.1, LeakPolicy: "redact"},
+			sources: map[string]string{"enabled": policySourceModel, "anonymizer": policySourceModel},
+		},
+		{
+			name: "Agent不能放宽语义模型的保护", model: strictModel,
+			agent: `{"enabled": false, "anonymizer": "lite", "kAnonymity": 2, "noiseLevel": 0.1, "epsilon": 1, "allowAbsoluteValues": true, "leakPolicy": "redact"}`,
+			want:  strict,
+			sources: map[string]string{"enabled": policySourceDefault, "anonymizer": policySourceModel, "kAnonymity": policySourceModel,
+				"epsilon": policySourceModel, "allowAbsoluteValues": policySourceModel, "leakPolicy": policySourceModel},
+		},
+		{
+			name: "Agent可以收紧语义模型的保护", model: strictModel,
+			agent:   `{"kAnonymity": 10, "noiseLevel": 0.5, "epsilon": 0.1, "leakPolicy": "BLOCK"}`,
+			want:    policyView{Enabled: true, Anonymizer: AnonymizerAdvanced, KAnonymity: 10, NoiseLevel: 0.5, Epsilon: 0.1, LeakPolicy: "block", UsesPrivacyBudget: true},
+			sources: map[string]string{"kAnonymity": policySourceAgent, "epsilon": policySourceAgent, "leakPolicy": policySourceAgent},
+		},
+		{
+			name: "Agent可以重新开启匿名化并切换为advanced", model: `{"enabled": false, "anonymizer": "lite", "allowAbsoluteValues": true}`,
+			agent:   `{"enabled": true, "anonymizer": "advanced", "allowAbsoluteValues": false}`,
+			want:    defaults,
+			sources: map[string]string{"enabled": policySourceAgent, "anonymizer": policySourceAgent, "allowAbsoluteValues": policySourceAgent},
+		},
+		{
+			name: "Agent的ε不能超过全局配置", agent: `{"epsilon": 0.8}`,
+			want: defaults, sources: map[string]string{"epsilon": policySourceDefault},
+		},
+		{
+			name: "Agent的ε小于全局配置时生效", agent: `{"epsilon": 0.2}`,
+			want:    policyView{Enabled: true, Anonymizer: AnonymizerAdvanced, NoiseLevel: 0.1, Epsilon: 0.2, LeakPolicy: "redact", UsesPrivacyBudget: true},
+			sources: map[string]string{"epsilon": policySourceAgent},
+		},
+	}
+	for _, tt := range tests {
+		modelName, agentId := "销售", "agent-1"
+		model := &sugar.SugarSemanticModels{Name: &modelName, AnonymizationPolicy: datatypes.JSON(tt.model)}
+		var agent *sugar.SugarAgents
+		if tt.agent != "" {
+			agent = &sugar.SugarAgents{Id: &agentId, AnonymizationPolicy: datatypes.JSON(tt.agent)}
+		}
+		resolved, err := ResolveAnonymizationPolicy(model, agent)
+		if err != nil {
+			t.Fatalf("%s: 解析失败: %v", tt.name, err)
+		}
+		if got := viewPolicy(resolved); got != tt.want {
+			t.Errorf("%s: 解析结果 = %+v，期望 %+v", tt.name, got, tt.want)
+		}
+		for field, source := range tt.sources {
+			if resolved.Sources[field] != source {
+				t.Errorf("%s: %s 的来源 = %s，期望 %s", tt.name, field, resolved.Sources[field], source)
+			}
+		}
+	}
+}
+
+func TestResolveAnonymizationPolicyLists(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	modelName, agentId := "销售", "agent-1"
+	model := &sugar.SugarSemanticModels{Name: &modelName, AnonymizationPolicy: datatypes.JSON(`{"sensitiveDimensions": ["城市", " "], "alwaysCodeValues": ["城市:北京"]}`)}
+	agent := &sugar.SugarAgents{Id: &agentId, AnonymizationPolicy: datatypes.JSON(`{"sensitiveDimensions": ["区域", " 城市 "]}`)}
+
+	// 列表字段取并集，Agent 只能追加
+	resolved, err := ResolveAnonymizationPolicy(model, agent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if !reflect.DeepEqual(resolved.SensitiveDimensions, []string{"区域", "城市"}) || !reflect.DeepEqual(resolved.AlwaysCodeValues, []string{"城市:北京"}) {
+		t.Fatalf("列表字段合并错误: %v %v", resolved.SensitiveDimensions, resolved.AlwaysCodeValues)
+	}
+	if resolved.ModelName != modelName || resolved.AgentId != agentId {
+		t.Fatalf("未记录策略来源的模型和Agent: %+v", resolved)
+	}
+
+	// 任一层的策略无效时返回错误
+	for _, broken := range []struct{ model, agent string }{
+		{model: `{"anonymizer": "strong"}`},
+		{model: `{"noiseLevel": 2}`},
+		{agent: `{"epsilon": 0}`},
+		{agent: `{"leakPolicy": "drop"}`},
+		{agent: `[]`},
+	} {
+		model.AnonymizationPolicy, agent.AnonymizationPolicy = datatypes.JSON(broken.model), datatypes.JSON(broken.agent)
+		if _, err := ResolveAnonymizationPolicy(model, agent); err == nil {
+			t.Errorf("无效的策略应解析失败: %+v", broken)
+		}
+	}
+}
+
+func TestAnonymizationSeedNotEditable(t *testing.T) {
+	setupTeamWorkbook(t)
+	seedTestData(t, `INSERT INTO sugar_semantic_models (id, name, team_id) VALUES ('model-1', '销售', 'team-1')`)
+	ctx := context.Background()
+	var model sugar.SugarSemanticModels
+	global.GVA_DB.Where("id = ?", "model-1").First(&model)
+
+	// 首次使用时生成种子，之后保持不变
+	seed, err := ensureAnonymizationSeed(ctx, &model)
+	if err != nil {
+		t.Fatalf("生成种子失败: %v", err)
+	}
+	var reloaded sugar.SugarSemanticModels
+	global.GVA_DB.Where("id = ?", "model-1").First(&reloaded)
+	if again, _ := ensureAnonymizationSeed(ctx, &reloaded); again != seed {
+		t.Fatalf("种子应保持不变: %d != %d", again, seed)
+	}
+
+	// 种子不出现在接口数据中，也不能通过接口修改
+	raw, _ := json.Marshal(reloaded)
+	if strings.Contains(string(raw), "nonymizationSeed") || strings.Contains(string(raw), strconv.FormatInt(seed, 10)) {
+		t.Fatalf("接口数据中不应包含种子: %s", raw)
+	}
+	var edited sugar.SugarSemanticModels
+	if err = json.Unmarshal([]byte(`{"id": "model-1", "name": "销售", "teamId": "team-1", "anonymizationSeed": 1}`), &edited); err != nil {
+		t.Fatalf("解析请求失败: %v", err)
+	}
+	forged := int64(2)
+	edited.AnonymizationSeed = &forged
+	if err = (&SugarSemanticModelsService{}).UpdateSugarSemanticModels(ctx, edited, "11", true); err != nil {
+		t.Fatalf("更新语义模型失败: %v", err)
+	}
+	global.GVA_DB.Where("id = ?", "model-1").First(&reloaded)
+	if reloaded.AnonymizationSeed == nil || *reloaded.AnonymizationSeed != seed {
+		t.Fatalf("更新语义模型不应修改种子: %v", reloaded.AnonymizationSeed)
+	}
+}
+
+func TestAnonymizationPolicyLogOmitsSeed(t *testing.T) {
+	setupGoldenDB(t, `{"deterministic": true}`)
+	ctx := context.Background()
+	seedTestData(t, `INSERT INTO sugar_execution_logs (id, log_type, user_id, status) VALUES (1, 'ai_agent', '1', 'running')`)
+
+	policy, err := resolveAnonymizationPolicyForModel(ctx, "月度销售", "1", nil)
+	if err != nil {
+		t.Fatalf("解析匿名化策略失败: %v", err)
+	}
+	if policy.Seed != 20240201 || policy.LiteConfig().RandomSeed != policy.Seed {
+		t.Fatalf("确定性模式应使用语义模型的种子: %d", policy.Seed)
+	}
+
+	// 执行日志和接口中只保留策略本身，种子与代号密钥一样不落库
+	if err = (&SugarExecutionLogService{}).RecordAnonymizationPolicy(ctx, &ExecutionLogContext{LogID: 1}, policy); err != nil {
+		t.Fatalf("记录匿名化策略失败: %v", err)
+	}
+	var stored sugar.SugarExecutionLogs
+	global.GVA_DB.Where("id = ?", 1).First(&stored)
+	var fields map[string]interface{}
+	if err = json.Unmarshal(stored.AnonymizationPolicy, &fields); err != nil {
+		t.Fatalf("解析已记录的策略失败: %v", err)
+	}
+	if _, ok := fields["seed"]; ok || strings.Contains(string(stored.AnonymizationPolicy), "20240201") {
+		t.Fatalf("执行日志中不应包含噪声种子: %s", stored.AnonymizationPolicy)
+	}
+	if fields["deterministic"] != true {
+		t.Fatalf("执行日志应记录确定性模式: %s", stored.AnonymizationPolicy)
+	}
+}
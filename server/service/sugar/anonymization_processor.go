@@ -147,76 +147,6 @@ func (ap *AnonymizationProcessor) DecodeAIResponse(session *anonymization_lite.L
 	return decodedResult, nil
 }
 
-// DecodeAIResponseLegacy 解码AI响应中的匿名代号（使用旧版本会话，向后兼容）
-func (ap *AnonymizationProcessor) DecodeAIResponseLegacy(session *AnonymizationSession, aiText string) (string, error) {
-	if session == nil {
-		return "", errors.New("匿名化会话为空")
-	}
-
-	if aiText == "" {
-		global.GVA_LOG.Warn("AI响应为空，无需解码")
-		return "", nil
-	}
-
-	global.GVA_LOG.Info("开始解码AI响应",
-		zap.Int("originalLength", len(aiText)),
-		zap.Int("mappingCount", len(session.reverseMap)))
-
-	// 获取所有需要替换的代号，按长度降序排序以避免部分替换问题
-	var codes []string
-	for code := range session.reverseMap {
-		codes = append(codes, code)
-	}
-
-	// 按字符串长度降序排序，确保长代号先被替换
-	for i := 0; i < len(codes); i++ {
-		for j := i + 1; j < len(codes); j++ {
-			if len(codes[i]) < len(codes[j]) {
-				codes[i], codes[j] = codes[j], codes[i]
-			}
-		}
-	}
-
-	// 执行替换
-	decodedText := aiText
-	replacementCount := 0
-	replacementDetails := make(map[string]string)
-
-	for _, code := range codes {
-		originalValue := session.reverseMap[code]
-		if strings.Contains(decodedText, code) {
-			oldText := decodedText
-			decodedText = strings.ReplaceAll(decodedText, code, originalValue)
-
-			// 统计实际替换次数
-			occurrences := strings.Count(oldText, code)
-			if occurrences > 0 {
-				replacementCount += occurrences
-				replacementDetails[code] = originalValue
-
-				global.GVA_LOG.Debug("执行代号替换",
-					zap.String("code", code),
-					zap.String("originalValue", originalValue),
-					zap.Int("occurrences", occurrences))
-			}
-		}
-	}
-
-	// 验证解码结果
-	if replacementCount == 0 {
-		global.GVA_LOG.Warn("未发现需要解码的匿名代号", zap.String("aiText", aiText))
-	}
-
-	global.GVA_LOG.Info("AI响应解码完成",
-		zap.Int("totalCodes", len(codes)),
-		zap.Int("foundCodes", len(replacementDetails)),
-		zap.Int("totalReplacements", replacementCount),
-		zap.Int("originalLength", len(aiText)),
-		zap.Int("decodedLength", len(decodedText)))
-
-	return decodedText, nil
-}
-
 // 私有方法
 
 // calculateContributions 计算贡献度分析（向后兼容的旧版本方法）
@@ -30,34 +30,55 @@ func NewContributionAnalyzer(advancedAnalyzer *advanced_contribution_analyzer.Ad
 }
 
 // PerformAnalysis 执行贡献度分析（优先使用增强版分析器）
-func (ca *ContributionAnalyzer) PerformAnalysis(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (string, *anonymization_lite.LiteAnonymizationSession, bool, error) {
+// previous 为同一对话中已有的匿名化会话，非空时沿用其代号，使多轮对话中同一实体的代号保持一致
+// config 为匿名化配置（噪声、隐私预算），为空时使用默认配置
+func (ca *ContributionAnalyzer) PerformAnalysis(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string, previous *anonymization_lite.LiteAnonymizationSession, config *anonymization_lite.LiteConfig) (string, *anonymization_lite.LiteAnonymizationSession, bool, error) {
 	global.GVA_LOG.Info("开始执行贡献度分析",
 		zap.String("modelName", modelName),
 		zap.String("targetMetric", targetMetric),
 		zap.Strings("groupByDimensions", groupByDimensions))
 
+	// 语义模型声明的分析口径
+	semantics, err := ca.dataProcessor.GetAnalysisSemantics(ctx, modelName, userId)
+	if err != nil {
+		return "", nil, false, err
+	}
+	semanticsText := ca.buildAnalysisSemanticsText(semantics, targetMetric)
+
 	// 检查增强版分析器状态
 	if ca.advancedAnalyzer == nil {
 		global.GVA_LOG.Warn("增强版分析器为nil，直接使用lite版本")
 	} else {
 		global.GVA_LOG.Info("增强版分析器可用，开始使用增强版分析")
-		aiDataText, session, err := ca.processAdvancedAnalysis(ctx, ca.advancedAnalyzer, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+		aiDataText, session, err := ca.processAdvancedAnalysis(ctx, ca.advancedAnalyzer, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId, previous, config, semantics)
 		if err != nil {
 			global.GVA_LOG.Warn("增强版分析器处理失败，回退到lite版本", zap.Error(err))
 		} else {
 			global.GVA_LOG.Info("增强版分析器处理成功")
-			return aiDataText, session, true, nil
+			return semanticsText + aiDataText, session, true, nil
 		}
 	}
 
 	// 回退到lite版本分析
 	global.GVA_LOG.Info("使用lite版本进行贡献度分析")
-	aiDataText, session, err := ca.processLiteAnalysis(ctx, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
-	return aiDataText, session, false, err
+	aiDataText, session, err := ca.processLiteAnalysis(ctx, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId, previous, config)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return semanticsText + aiDataText, session, false, nil
+}
+
+// buildAnalysisSemanticsText 将语义模型声明的分析口径整理为数据文本的开头部分，未声明时返回空字符串
+func (ca *ContributionAnalyzer) buildAnalysisSemanticsText(semantics *AnalysisSemantics, targetMetric string) string {
+	description := semantics.Describe(targetMetric)
+	if description == "" {
+		return ""
+	}
+	return "【分析口径】\n" + description + "\n\n"
 }
 
 // processAdvancedAnalysis 使用增强版分析器进行智能分析
-func (ca *ContributionAnalyzer) processAdvancedAnalysis(ctx context.Context, advancedService *advanced_contribution_analyzer.AdvancedContributionService, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (string, *anonymization_lite.LiteAnonymizationSession, error) {
+func (ca *ContributionAnalyzer) processAdvancedAnalysis(ctx context.Context, advancedService *advanced_contribution_analyzer.AdvancedContributionService, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string, previous *anonymization_lite.LiteAnonymizationSession, config *anonymization_lite.LiteConfig, semantics *AnalysisSemantics) (string, *anonymization_lite.LiteAnonymizationSession, error) {
 	global.GVA_LOG.Info("使用增强版分析器进行智能分析")
 
 	// 验证增强版分析器服务
@@ -77,20 +98,24 @@ func (ca *ContributionAnalyzer) processAdvancedAnalysis(ctx context.Context, adv
 		return "", nil, fmt.Errorf("获取数据失败: %w", err)
 	}
 
-	// 2. 计算基础贡献度
-	contributions, err := ca.calculateContributions(currentData, baseData, targetMetric, groupByDimensions)
+	// 2. 按配置的分解方法计算基础贡献度
+	contributions, decomposition, err := ca.calculateDecomposedContributions(ctx, currentConfig, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId, currentData, baseData)
 	if err != nil {
 		return "", nil, fmt.Errorf("计算贡献度失败: %w", err)
 	}
 
 	// 3. 构建增强版分析请求
+	var balanceComparison *advanced_contribution_analyzer.BalanceComparison
+	if pair := semantics.BalancePairFor(targetMetric); pair != nil {
+		balanceComparison = &advanced_contribution_analyzer.BalanceComparison{OpeningMetric: pair.Opening, ClosingMetric: pair.Closing}
+	}
 	analysisRequest := &advanced_contribution_analyzer.AnalysisRequest{
 		ModelName:            modelName,
 		Metric:               targetMetric,
 		Dimensions:           groupByDimensions,
 		CurrentPeriodFilters: currentPeriodFilters,
 		BasePeriodFilters:    basePeriodFilters,
-		IsYearEndComparison:  ca.dataProcessor.isYearEndComparisonModel(modelName, targetMetric),
+		BalanceComparison:    balanceComparison,
 		RawContributions:     ca.convertToAdvancedContributions(contributions),
 		TotalChange:          ca.calculateTotalChange(contributions),
 	}
@@ -116,16 +141,18 @@ func (ca *ContributionAnalyzer) processAdvancedAnalysis(ctx context.Context, adv
 	}
 
 	// 6. 使用 anonymization_lite 进行匿名化处理
-	config := anonymization_lite.DefaultLiteConfig()
+	if config == nil {
+		config = anonymization_lite.DefaultLiteConfig()
+	}
 	liteService := anonymization_lite.NewLiteAnonymizationService(config)
 
-	aiDataText, session, err := liteService.ProcessAndSerialize(contributionData)
+	aiDataText, session, err := liteService.ProcessAndSerializeWithSession(previous, contributionData)
 	if err != nil {
 		return "", nil, fmt.Errorf("增强版匿名化处理失败: %w", err)
 	}
 
 	// 7. 构建增强的AI数据文本
-	enhancedAiDataText := ca.buildAdvancedAnalysisText(analysisResponse, aiDataText)
+	enhancedAiDataText := ca.buildDecompositionText(decomposition) + ca.buildAdvancedAnalysisText(analysisResponse, aiDataText)
 
 	global.GVA_LOG.Info("增强版分析处理完成",
 		zap.Int("originalDataCount", len(contributionData)),
@@ -136,7 +163,7 @@ func (ca *ContributionAnalyzer) processAdvancedAnalysis(ctx context.Context, adv
 }
 
 // processLiteAnalysis 使用lite版本进行分析
-func (ca *ContributionAnalyzer) processLiteAnalysis(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (string, *anonymization_lite.LiteAnonymizationSession, error) {
+func (ca *ContributionAnalyzer) processLiteAnalysis(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string, previous *anonymization_lite.LiteAnonymizationSession, config *anonymization_lite.LiteConfig) (string, *anonymization_lite.LiteAnonymizationSession, error) {
 	global.GVA_LOG.Info("使用lite版本进行贡献度分析")
 
 	// 1. 获取数据
@@ -152,10 +179,12 @@ func (ca *ContributionAnalyzer) processLiteAnalysis(ctx context.Context, modelNa
 	}
 
 	// 3. 使用 anonymization_lite 进行匿名化处理
-	config := anonymization_lite.DefaultLiteConfig()
+	if config == nil {
+		config = anonymization_lite.DefaultLiteConfig()
+	}
 	liteService := anonymization_lite.NewLiteAnonymizationService(config)
 
-	aiDataText, session, err := liteService.ProcessAndSerialize(contributionData)
+	aiDataText, session, err := liteService.ProcessAndSerializeWithSession(previous, contributionData)
 	if err != nil {
 		return "", nil, fmt.Errorf("lite版本匿名化处理失败: %w", err)
 	}
@@ -201,6 +230,17 @@ func (ca *ContributionAnalyzer) calculateContributions(currentData, baseData *su
 	}
 
 	// 第二轮：计算贡献度百分比和正负向判断
+	ca.assignContributionPercents(contributions, totalChange)
+
+	global.GVA_LOG.Info("贡献度计算完成",
+		zap.Float64("totalChange", totalChange),
+		zap.Int("contributionCount", len(contributions)))
+
+	return contributions, nil
+}
+
+// assignContributionPercents 根据总变化值计算各贡献项的贡献度百分比和正负向
+func (ca *ContributionAnalyzer) assignContributionPercents(contributions []ContributionItem, totalChange float64) {
 	for i := range contributions {
 		if totalChange != 0 {
 			contributions[i].ContributionPercent = (contributions[i].ChangeValue / totalChange) * 100
@@ -211,12 +251,6 @@ func (ca *ContributionAnalyzer) calculateContributions(currentData, baseData *su
 		// 判断是否为正向驱动因子
 		contributions[i].IsPositiveDriver = (contributions[i].ChangeValue * totalChange) >= 0
 	}
-
-	global.GVA_LOG.Info("贡献度计算完成",
-		zap.Float64("totalChange", totalChange),
-		zap.Int("contributionCount", len(contributions)))
-
-	return contributions, nil
 }
 
 // convertToContributionData 转换数据为anonymization_lite包需要的ContributionItem格式
@@ -300,6 +334,11 @@ func (ca *ContributionAnalyzer) convertToContributionData(currentData, baseData
 			TrendDirection:      trendDirection,
 			ImpactLevel:         impactLevel,
 			RelativeImportance:  0, // 将在第三轮计算
+			AbsoluteValues: &anonymization_lite.AbsoluteValues{
+				CurrentValue: currentValue,
+				BaseValue:    baseValue,
+				ChangeValue:  changeValue,
+			},
 		})
 	}
 
@@ -526,6 +565,8 @@ func (ca *ContributionAnalyzer) getAllUniqueKeys(groups1, groups2 map[string]flo
 	for key := range keySet {
 		keys = append(keys, key)
 	}
+	// 排序保证贡献项顺序稳定，确定性模式下相同输入得到相同输出
+	sort.Strings(keys)
 
 	return keys
 }
@@ -577,7 +618,7 @@ func (ca *ContributionAnalyzer) calculateRelativeImportance(contributions []anon
 	}
 
 	// 按贡献度绝对值降序排序
-	sort.Slice(indexed, func(i, j int) bool {
+	sort.SliceStable(indexed, func(i, j int) bool {
 		return indexed[i].absContribution > indexed[j].absContribution
 	})
 
@@ -0,0 +1,218 @@
+package sugar
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+	"go.uber.org/zap"
+)
+
+var decompositionMethodNames = map[string]string{
+	advanced_contribution_analyzer.DecompositionAdditive: "加法分解",
+	advanced_contribution_analyzer.DecompositionLMDI:     "LMDI（对数平均迪氏指数法）",
+	advanced_contribution_analyzer.DecompositionShapley:  "Shapley 值分解",
+	advanced_contribution_analyzer.DecompositionMixRate:  "结构/比率分解",
+}
+
+// calculateDecomposedContributions 按分析配置的分解方法计算各组合的贡献
+// additive 时等同于 calculateContributions，返回的分解结果为 nil；
+// lmdi/shapley 额外获取因素链指标，mix_rate 额外获取分子、分母指标
+func (ca *ContributionAnalyzer) calculateDecomposedContributions(ctx context.Context, config *advanced_contribution_analyzer.AnalysisConfig, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string, currentData, baseData *sugarRes.SugarFormulaGetResponse) ([]ContributionItem, *advanced_contribution_analyzer.DecompositionResult, error) {
+	method := advanced_contribution_analyzer.DecompositionAdditive
+	if config != nil && config.DecompositionMethod != "" {
+		if err := config.ValidateDecomposition(); err != nil {
+			return nil, nil, err
+		}
+		method = config.DecompositionMethod
+	}
+
+	switch method {
+	case advanced_contribution_analyzer.DecompositionLMDI, advanced_contribution_analyzer.DecompositionShapley:
+		return ca.calculateMultiplicativeContributions(ctx, method, config.FactorMetrics, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId, currentData, baseData)
+	case advanced_contribution_analyzer.DecompositionMixRate:
+		return ca.calculateMixRateContributions(ctx, config.RatioNumerator, config.RatioDenominator, modelName, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+	default:
+		contributions, err := ca.calculateContributions(currentData, baseData, targetMetric, groupByDimensions)
+		return contributions, nil, err
+	}
+}
+
+// calculateMultiplicativeContributions 目标指标 = F1 × (F2/F1) × … × (目标指标/Fn)，对各组合做 LMDI 或 Shapley 分解
+func (ca *ContributionAnalyzer) calculateMultiplicativeContributions(ctx context.Context, method string, factorMetrics []string, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string, currentData, baseData *sugarRes.SugarFormulaGetResponse) ([]ContributionItem, *advanced_contribution_analyzer.DecompositionResult, error) {
+	currentTarget := ca.groupDataByDimensions(currentData.Results, groupByDimensions, targetMetric)
+	baseTarget := ca.groupDataByDimensions(baseData.Results, groupByDimensions, targetMetric)
+
+	allGroups := []map[string]float64{currentTarget, baseTarget}
+	currentFactors := make([]map[string]float64, len(factorMetrics))
+	baseFactors := make([]map[string]float64, len(factorMetrics))
+	for i, metric := range factorMetrics {
+		current, base, err := ca.fetchMetricGroups(ctx, modelName, metric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+		currentFactors[i], baseFactors[i] = current, base
+		allGroups = append(allGroups, current, base)
+	}
+
+	// 因素链：第一个因素为 F1，其后依次为相邻指标之比，最后一个因素为 目标指标/Fn
+	effectNames := make([]string, 0, len(factorMetrics)+1)
+	effectNames = append(effectNames, factorMetrics[0]+"效应")
+	for i := 1; i < len(factorMetrics); i++ {
+		effectNames = append(effectNames, fmt.Sprintf("%s/%s效应", factorMetrics[i], factorMetrics[i-1]))
+	}
+	effectNames = append(effectNames, fmt.Sprintf("%s/%s效应", targetMetric, factorMetrics[len(factorMetrics)-1]))
+
+	keys := unionGroupKeys(allGroups...)
+	observations := make([]advanced_contribution_analyzer.FactorObservation, 0, len(keys))
+	for _, key := range keys {
+		observations = append(observations, advanced_contribution_analyzer.FactorObservation{
+			Key:          key,
+			BaseValue:    baseTarget[key],
+			CurrentValue: currentTarget[key],
+			BaseFactors:  factorChain(key, baseFactors, baseTarget[key]),
+			CurFactors:   factorChain(key, currentFactors, currentTarget[key]),
+		})
+	}
+	result, err := advanced_contribution_analyzer.DecomposeMultiplicative(method, effectNames, observations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca.contributionsFromDecomposition(result, groupByDimensions, nil), result, nil
+}
+
+// factorChain 计算单个组合在某一期的因素链取值，分母为0时该因素记为0（由分解算法计入进出效应）
+func factorChain(key string, factorGroups []map[string]float64, targetValue float64) []float64 {
+	chain := make([]float64, 0, len(factorGroups)+1)
+	ratio := func(numerator, denominator float64) float64 {
+		if denominator == 0 {
+			return 0
+		}
+		return numerator / denominator
+	}
+	chain = append(chain, factorGroups[0][key])
+	for i := 1; i < len(factorGroups); i++ {
+		chain = append(chain, ratio(factorGroups[i][key], factorGroups[i-1][key]))
+	}
+	return append(chain, ratio(targetValue, factorGroups[len(factorGroups)-1][key]))
+}
+
+// calculateMixRateContributions 目标指标 = Σ分子 / Σ分母，对各组合做结构/比率分解
+func (ca *ContributionAnalyzer) calculateMixRateContributions(ctx context.Context, numeratorMetric, denominatorMetric, modelName string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) ([]ContributionItem, *advanced_contribution_analyzer.DecompositionResult, error) {
+	currentNumerator, baseNumerator, err := ca.fetchMetricGroups(ctx, modelName, numeratorMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentDenominator, baseDenominator, err := ca.fetchMetricGroups(ctx, modelName, denominatorMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := unionGroupKeys(currentNumerator, baseNumerator, currentDenominator, baseDenominator)
+	observations := make([]advanced_contribution_analyzer.RatioObservation, 0, len(keys))
+	components := make(map[string]*advanced_contribution_analyzer.RatioObservation, len(keys))
+	for _, key := range keys {
+		observation := advanced_contribution_analyzer.RatioObservation{
+			Key:                key,
+			BaseNumerator:      baseNumerator[key],
+			BaseDenominator:    baseDenominator[key],
+			CurrentNumerator:   currentNumerator[key],
+			CurrentDenominator: currentDenominator[key],
+		}
+		observations = append(observations, observation)
+		components[key] = &observation
+	}
+	result, err := advanced_contribution_analyzer.DecomposeMixRate(observations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca.contributionsFromDecomposition(result, groupByDimensions, components), result, nil
+}
+
+// fetchMetricGroups 获取指定指标两期的数据并按维度组合汇总
+func (ca *ContributionAnalyzer) fetchMetricGroups(ctx context.Context, modelName, metric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (map[string]float64, map[string]float64, error) {
+	currentData, baseData, err := ca.dataProcessor.FetchDataConcurrently(ctx, modelName, metric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取分解指标 %s 的数据失败: %w", metric, err)
+	}
+	return ca.groupDataByDimensions(currentData.Results, groupByDimensions, metric),
+		ca.groupDataByDimensions(baseData.Results, groupByDimensions, metric), nil
+}
+
+// contributionsFromDecomposition 将分解结果转换为贡献项，变化值为各效应之和
+func (ca *ContributionAnalyzer) contributionsFromDecomposition(result *advanced_contribution_analyzer.DecompositionResult, groupByDimensions []string, components map[string]*advanced_contribution_analyzer.RatioObservation) []ContributionItem {
+	contributions := make([]ContributionItem, 0, len(result.Groups))
+	for _, group := range result.Groups {
+		effects := make(map[string]float64, len(group.Effects))
+		for i, effect := range group.Effects {
+			effects[result.EffectNames[i]] = effect
+		}
+		contributions = append(contributions, ContributionItem{
+			DimensionValues: ca.parseDimensionKey(group.Key, groupByDimensions),
+			CurrentValue:    group.CurrentValue,
+			BaseValue:       group.BaseValue,
+			ChangeValue:     group.Change,
+			Effects:         effects,
+			Components:      components[group.Key],
+		})
+	}
+	ca.assignContributionPercents(contributions, result.TotalChange)
+
+	global.GVA_LOG.Info("变化分解完成",
+		zap.String("method", result.Method),
+		zap.Strings("effects", result.EffectNames),
+		zap.Float64("totalChange", result.TotalChange),
+		zap.Float64("residual", result.Residual()))
+	return contributions
+}
+
+// buildDecompositionText 生成变化分解的说明，只包含各效应占总变化的比例，不暴露绝对值
+func (ca *ContributionAnalyzer) buildDecompositionText(result *advanced_contribution_analyzer.DecompositionResult) string {
+	if result == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("【变化分解】\n")
+	builder.WriteString("分解方法：" + decompositionMethodNames[result.Method] + "\n")
+	for i, name := range result.EffectNames {
+		if result.TotalChange == 0 {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("%s：占总变化 %.1f%%\n", name, result.Effects[i]/result.TotalChange*100))
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+// unionGroupKeys 多组汇总结果的全部维度组合键，按字典序排列
+func unionGroupKeys(groups ...map[string]float64) []string {
+	keySet := make(map[string]bool)
+	for _, group := range groups {
+		for key := range group {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decompositionEffectTotals 分解结果中各效应的合计及占总变化的比例
+func decompositionEffectTotals(result *advanced_contribution_analyzer.DecompositionResult) []sugarRes.DecompositionEffect {
+	effects := make([]sugarRes.DecompositionEffect, 0, len(result.EffectNames))
+	for i, name := range result.EffectNames {
+		effect := sugarRes.DecompositionEffect{Name: name, Value: result.Effects[i]}
+		if result.TotalChange != 0 {
+			effect.Percent = result.Effects[i] / result.TotalChange * 100
+		}
+		effects = append(effects, effect)
+	}
+	return effects
+}
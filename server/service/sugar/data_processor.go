@@ -56,9 +56,11 @@ func (dp *DataProcessor) GetAgentAndLLMConfig(ctx context.Context, agentName, us
 }
 
 // ValidateDataAvailability 验证数据可用性
-func (dp *DataProcessor) ValidateDataAvailability(ctx context.Context, modelName string, groupByDimensions []string, currentPeriodFilters, basePeriodFilters map[string]interface{}, userId string) (*DataValidationResult, error) {
+// 目标指标在语义模型中声明为期初/期末指标对时，验证期初期末数据；否则验证本期和基期数据
+func (dp *DataProcessor) ValidateDataAvailability(ctx context.Context, modelName, targetMetric string, groupByDimensions []string, currentPeriodFilters, basePeriodFilters map[string]interface{}, userId string) (*DataValidationResult, error) {
 	global.GVA_LOG.Info("开始验证数据可用性",
 		zap.String("modelName", modelName),
+		zap.String("targetMetric", targetMetric),
 		zap.Strings("groupByDimensions", groupByDimensions))
 
 	result := &DataValidationResult{
@@ -68,16 +70,30 @@ func (dp *DataProcessor) ValidateDataAvailability(ctx context.Context, modelName
 		MissingDimensions: make([]string, 0),
 	}
 
-	// 检查是否为年初年末对比类型的表
-	isYearEndComparison := dp.isYearEndComparisonModel(modelName, "")
-
-	if isYearEndComparison {
-		return dp.validateYearEndComparisonData(ctx, modelName, groupByDimensions, currentPeriodFilters, userId, result)
+	semantics, err := dp.GetAnalysisSemantics(ctx, modelName, userId)
+	if err != nil {
+		return nil, err
+	}
+	if pair := semantics.BalancePairFor(targetMetric); pair != nil {
+		return dp.validateBalancePairData(ctx, modelName, *pair, groupByDimensions, currentPeriodFilters, userId, result)
 	}
 
 	return dp.validateTimeBasedData(ctx, modelName, groupByDimensions, currentPeriodFilters, basePeriodFilters, userId, result)
 }
 
+// GetAnalysisSemantics 获取语义模型声明的分析口径
+func (dp *DataProcessor) GetAnalysisSemantics(ctx context.Context, modelName, userId string) (*AnalysisSemantics, error) {
+	model, err := dp.formulaQueryService.getSemanticModel(ctx, modelName, userId)
+	if err != nil {
+		return nil, err
+	}
+	semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+	if err != nil {
+		return nil, fmt.Errorf("语义模型 %s 的%w", modelName, err)
+	}
+	return semantics, nil
+}
+
 // ExploreDataScope 执行数据范围探索
 func (dp *DataProcessor) ExploreDataScope(ctx context.Context, modelName string, exploreDimensions []string, sampleFilters map[string]interface{}, userId string) (*DataScopeInfo, error) {
 	global.GVA_LOG.Info("开始执行数据范围探索",
@@ -200,15 +216,22 @@ func (dp *DataProcessor) FormatDataScopeResult(scopeInfo *DataScopeInfo) string
 }
 
 // FetchDataConcurrently 并发获取本期和基期数据
+// 目标指标在语义模型中声明为期初/期末指标对时，以期末值为本期、期初值为基期；
+// 目标指标为时点余额且声明了期间维度时，本期和基期各取筛选范围内最后一个期间的余额
 func (dp *DataProcessor) FetchDataConcurrently(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (*sugarRes.SugarFormulaGetResponse, *sugarRes.SugarFormulaGetResponse, error) {
-	// 检查是否为年初年末对比类型的表
-	isYearEndComparison := dp.isYearEndComparisonModel(modelName, targetMetric)
-
-	if isYearEndComparison {
-		return dp.fetchYearEndComparisonData(ctx, modelName, targetMetric, currentPeriodFilters, groupByDimensions, userId)
+	semantics, err := dp.GetAnalysisSemantics(ctx, modelName, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pair := semantics.BalancePairFor(targetMetric); pair != nil {
+		return dp.fetchBalancePairData(ctx, modelName, targetMetric, *pair, currentPeriodFilters, groupByDimensions, userId)
 	}
 
-	return dp.fetchTimeBasedData(ctx, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, userId)
+	periodColumn := ""
+	if semantics.MetricKind(targetMetric) == MetricKindBalance && semantics.PeriodColumn != "" && !containsString(groupByDimensions, semantics.PeriodColumn) {
+		periodColumn = semantics.PeriodColumn
+	}
+	return dp.fetchTimeBasedData(ctx, modelName, targetMetric, currentPeriodFilters, basePeriodFilters, groupByDimensions, periodColumn, userId)
 }
 
 // 私有方法
@@ -217,9 +240,8 @@ func (dp *DataProcessor) FetchDataConcurrently(ctx context.Context, modelName, t
 func (dp *DataProcessor) getAgentByName(ctx context.Context, agentName, userId string) (*sugar.SugarAgents, error) {
 	var agent sugar.SugarAgents
 
-	// 获取用户所属团队
-	var teamIds []string
-	err := global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
+	// 获取用户可使用智能体的团队
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceAgent, SugarActionRead)
 	if err != nil {
 		return nil, errors.New("获取用户团队信息失败")
 	}
@@ -246,33 +268,9 @@ func (dp *DataProcessor) getAgentByName(ctx context.Context, agentName, userId s
 	return &agent, nil
 }
 
-// isYearEndComparisonModel 判断是否为年初年末对比类型的模型
-func (dp *DataProcessor) isYearEndComparisonModel(modelName, targetMetric string) bool {
-	modelNameLower := strings.ToLower(modelName)
-	targetMetricLower := strings.ToLower(targetMetric)
-
-	yearEndModels := []string{"货币资金", "cash", "应收账款", "receivable", "存货", "inventory",
-		"固定资产", "fixed", "无形资产", "intangible", "应付账款", "payable",
-		"短期借款", "short_term", "长期借款", "long_term", "实收资本", "capital", "未分配利润", "retained"}
-
-	yearEndMetrics := []string{"年末金额", "ending_balance", "年初金额", "beginning_balance"}
-
-	for _, keyword := range yearEndModels {
-		if strings.Contains(modelNameLower, keyword) {
-			for _, metric := range yearEndMetrics {
-				if strings.Contains(targetMetricLower, metric) {
-					return true
-				}
-			}
-		}
-	}
-
-	return false
-}
-
-// validateYearEndComparisonData 验证年初年末对比数据的可用性
-func (dp *DataProcessor) validateYearEndComparisonData(ctx context.Context, modelName string, groupByDimensions []string, filters map[string]interface{}, userId string, result *DataValidationResult) (*DataValidationResult, error) {
-	returnColumns := append([]string{"年末金额", "年初金额"}, groupByDimensions...)
+// validateBalancePairData 验证期初/期末对比数据的可用性
+func (dp *DataProcessor) validateBalancePairData(ctx context.Context, modelName string, pair BalancePair, groupByDimensions []string, filters map[string]interface{}, userId string, result *DataValidationResult) (*DataValidationResult, error) {
+	returnColumns := append([]string{pair.Closing, pair.Opening}, groupByDimensions...)
 	cleanedFilters := dp.filterWildcardConditions(filters)
 
 	validateReq := &sugarReq.SugarFormulaGetRequest{
@@ -283,10 +281,10 @@ func (dp *DataProcessor) validateYearEndComparisonData(ctx context.Context, mode
 
 	validateData, err := dp.formulaQueryService.ExecuteGetFormula(ctx, validateReq, userId)
 	if err != nil {
-		return nil, fmt.Errorf("执行年初年末验证查询失败: %w", err)
+		return nil, fmt.Errorf("执行期初期末验证查询失败: %w", err)
 	}
 	if validateData.Error != "" {
-		return nil, fmt.Errorf("年初年末验证查询错误: %s", validateData.Error)
+		return nil, fmt.Errorf("期初期末验证查询错误: %s", validateData.Error)
 	}
 
 	result.RecordCount = len(validateData.Results)
@@ -294,8 +292,8 @@ func (dp *DataProcessor) validateYearEndComparisonData(ctx context.Context, mode
 	// 验证数据质量
 	validRecordCount := 0
 	for _, record := range validateData.Results {
-		beginningBalance := dp.extractFloatValue(record["年初金额"])
-		endingBalance := dp.extractFloatValue(record["年末金额"])
+		beginningBalance := dp.extractFloatValue(record[pair.Opening])
+		endingBalance := dp.extractFloatValue(record[pair.Closing])
 
 		if beginningBalance != 0 || endingBalance != 0 {
 			validRecordCount++
@@ -305,16 +303,16 @@ func (dp *DataProcessor) validateYearEndComparisonData(ctx context.Context, mode
 	// 判断数据可用性
 	if result.RecordCount == 0 {
 		result.IsDataAvailable = false
-		result.ValidationMessage = "根据您提供的筛选条件，未找到匹配的年初年末对比数据记录。建议检查筛选条件是否正确。"
+		result.ValidationMessage = "根据您提供的筛选条件，未找到匹配的期初期末对比数据记录。建议检查筛选条件是否正确。"
 	} else if validRecordCount == 0 {
 		result.IsDataAvailable = false
-		result.ValidationMessage = fmt.Sprintf("找到%d条记录，但年初和年末金额字段均为空。请检查数据完整性。", result.RecordCount)
+		result.ValidationMessage = fmt.Sprintf("找到%d条记录，但%s和%s字段均为空。请检查数据完整性。", result.RecordCount, pair.Opening, pair.Closing)
 	} else if validRecordCount < 3 {
 		result.IsDataAvailable = false
 		result.ValidationMessage = fmt.Sprintf("找到%d条记录，但只有%d条有效记录。数据量过少，无法进行可靠的贡献度分析。建议调整筛选条件。", result.RecordCount, validRecordCount)
 	} else {
 		result.IsDataAvailable = true
-		result.ValidationMessage = fmt.Sprintf("数据验证通过：找到%d条记录，其中%d条有效记录，可以进行年初年末对比分析。", result.RecordCount, validRecordCount)
+		result.ValidationMessage = fmt.Sprintf("数据验证通过：找到%d条记录，其中%d条有效记录，可以进行期初期末对比分析。", result.RecordCount, validRecordCount)
 	}
 
 	return result, nil
@@ -377,9 +375,9 @@ func (dp *DataProcessor) validateTimeBasedData(ctx context.Context, modelName st
 	return result, nil
 }
 
-// fetchYearEndComparisonData 获取年初年末对比数据
-func (dp *DataProcessor) fetchYearEndComparisonData(ctx context.Context, modelName, targetMetric string, filters map[string]interface{}, groupByDimensions []string, userId string) (*sugarRes.SugarFormulaGetResponse, *sugarRes.SugarFormulaGetResponse, error) {
-	returnColumns := append([]string{"年末金额", "年初金额"}, groupByDimensions...)
+// fetchBalancePairData 获取期初/期末对比数据
+func (dp *DataProcessor) fetchBalancePairData(ctx context.Context, modelName, targetMetric string, pair BalancePair, filters map[string]interface{}, groupByDimensions []string, userId string) (*sugarRes.SugarFormulaGetResponse, *sugarRes.SugarFormulaGetResponse, error) {
+	returnColumns := append([]string{pair.Closing, pair.Opening}, groupByDimensions...)
 	cleanedFilters := dp.filterWildcardConditions(filters)
 
 	req := &sugarReq.SugarFormulaGetRequest{
@@ -390,13 +388,13 @@ func (dp *DataProcessor) fetchYearEndComparisonData(ctx context.Context, modelNa
 
 	fullData, err := dp.formulaQueryService.ExecuteGetFormula(ctx, req, userId)
 	if err != nil {
-		return nil, nil, fmt.Errorf("获取年初年末数据失败: %w", err)
+		return nil, nil, fmt.Errorf("获取期初期末数据失败: %w", err)
 	}
 	if fullData.Error != "" {
-		return nil, nil, fmt.Errorf("年初年末数据查询错误: %s", fullData.Error)
+		return nil, nil, fmt.Errorf("期初期末数据查询错误: %s", fullData.Error)
 	}
 
-	// 构造当前期数据（年末金额）和基期数据（年初金额）
+	// 构造当前期数据（期末值）和基期数据（期初值）
 	currentData := &sugarRes.SugarFormulaGetResponse{
 		Results: make([]map[string]interface{}, len(fullData.Results)),
 		Error:   "",
@@ -409,23 +407,23 @@ func (dp *DataProcessor) fetchYearEndComparisonData(ctx context.Context, modelNa
 
 	// 转换数据格式
 	for i, row := range fullData.Results {
-		// 当前期数据：使用年末金额作为目标指标值
+		// 当前期数据：使用期末值作为目标指标值
 		currentRow := make(map[string]interface{})
 		for key, value := range row {
-			if key == "年末金额" {
+			if key == pair.Closing {
 				currentRow[targetMetric] = value
-			} else if key != "年初金额" {
+			} else if key != pair.Opening {
 				currentRow[key] = value
 			}
 		}
 		currentData.Results[i] = currentRow
 
-		// 基期数据：使用年初金额作为目标指标值
+		// 基期数据：使用期初值作为目标指标值
 		baseRow := make(map[string]interface{})
 		for key, value := range row {
-			if key == "年初金额" {
+			if key == pair.Opening {
 				baseRow[targetMetric] = value
-			} else if key != "年末金额" {
+			} else if key != pair.Closing {
 				baseRow[key] = value
 			}
 		}
@@ -436,8 +434,12 @@ func (dp *DataProcessor) fetchYearEndComparisonData(ctx context.Context, modelNa
 }
 
 // fetchTimeBasedData 获取基于时间维度的数据
-func (dp *DataProcessor) fetchTimeBasedData(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, userId string) (*sugarRes.SugarFormulaGetResponse, *sugarRes.SugarFormulaGetResponse, error) {
+// periodColumn 非空时额外返回期间维度，并只保留每期筛选范围内最后一个期间的记录（用于时点余额指标）
+func (dp *DataProcessor) fetchTimeBasedData(ctx context.Context, modelName, targetMetric string, currentPeriodFilters, basePeriodFilters map[string]interface{}, groupByDimensions []string, periodColumn string, userId string) (*sugarRes.SugarFormulaGetResponse, *sugarRes.SugarFormulaGetResponse, error) {
 	returnColumns := append([]string{targetMetric}, groupByDimensions...)
+	if periodColumn != "" {
+		returnColumns = append(returnColumns, periodColumn)
+	}
 	cleanedCurrentFilters := dp.filterWildcardConditions(currentPeriodFilters)
 	cleanedBaseFilters := dp.filterWildcardConditions(basePeriodFilters)
 
@@ -498,9 +500,67 @@ func (dp *DataProcessor) fetchTimeBasedData(ctx context.Context, modelName, targ
 		return nil, nil, baseResult.err
 	}
 
+	if periodColumn != "" {
+		dp.keepLatestPeriod(currentResult.data, periodColumn)
+		dp.keepLatestPeriod(baseResult.data, periodColumn)
+	}
+
 	return currentResult.data, baseResult.data, nil
 }
 
+// keepLatestPeriod 只保留最后一个期间的记录并移除期间字段，时点余额指标跨期间不可累加
+func (dp *DataProcessor) keepLatestPeriod(data *sugarRes.SugarFormulaGetResponse, periodColumn string) {
+	latest := ""
+	for _, row := range data.Results {
+		if period := fmt.Sprintf("%v", row[periodColumn]); comparePeriods(period, latest) > 0 {
+			latest = period
+		}
+	}
+	kept := make([]map[string]interface{}, 0, len(data.Results))
+	for _, row := range data.Results {
+		if fmt.Sprintf("%v", row[periodColumn]) == latest {
+			delete(row, periodColumn)
+			kept = append(kept, row)
+		}
+	}
+	if len(kept) < len(data.Results) {
+		global.GVA_LOG.Debug("时点余额指标只保留最后一个期间",
+			zap.String("periodColumn", periodColumn),
+			zap.String("period", latest),
+			zap.Int("originalCount", len(data.Results)),
+			zap.Int("keptCount", len(kept)))
+	}
+	data.Results = kept
+	data.Count = len(kept)
+}
+
+// comparePeriods 比较两个期间值，均为数字时按数值比较，否则按字符串比较（适用于 2024-01、2024Q1 等格式）
+func comparePeriods(a, b string) int {
+	var x, y float64
+	if _, errA := fmt.Sscanf(a, "%g", &x); errA == nil && fmt.Sprintf("%g", x) == a {
+		if _, errB := fmt.Sscanf(b, "%g", &y); errB == nil && fmt.Sprintf("%g", y) == b {
+			switch {
+			case x > y:
+				return 1
+			case x < y:
+				return -1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
 // filterWildcardConditions 过滤掉通配符条件
 func (dp *DataProcessor) filterWildcardConditions(filters map[string]interface{}) map[string]interface{} {
 	if filters == nil {
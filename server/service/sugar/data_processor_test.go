@@ -0,0 +1,68 @@
+package sugar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+)
+
+func TestKeepLatestPeriod(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	dp := NewDataProcessor()
+	tests := []struct {
+		name   string
+		period string
+		rows   []map[string]interface{}
+		want   []map[string]interface{}
+	}{
+		{
+			name: "按字符串比较的月份", period: "月份",
+			rows: []map[string]interface{}{
+				{"月份": "2024-11", "科目": "现金", "年末金额": 1.0},
+				{"月份": "2024-12", "科目": "现金", "年末金额": 2.0},
+				{"月份": "2024-12", "科目": "存款", "年末金额": 3.0},
+				{"月份": "2024-02", "科目": "存款", "年末金额": 4.0},
+			},
+			want: []map[string]interface{}{{"科目": "现金", "年末金额": 2.0}, {"科目": "存款", "年末金额": 3.0}},
+		},
+		{
+			name: "数字期间按数值比较", period: "期间",
+			rows: []map[string]interface{}{{"期间": 9, "余额": 1.0}, {"期间": 10, "余额": 2.0}},
+			want: []map[string]interface{}{{"余额": 2.0}},
+		},
+		{
+			name: "季度", period: "期间",
+			rows: []map[string]interface{}{{"期间": "2024Q4", "余额": 1.0}, {"期间": "2025Q1", "余额": 2.0}},
+			want: []map[string]interface{}{{"余额": 2.0}},
+		},
+		{name: "没有数据", period: "期间", rows: []map[string]interface{}{}, want: []map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		data := &sugarRes.SugarFormulaGetResponse{Results: tt.rows, Count: len(tt.rows)}
+		dp.keepLatestPeriod(data, tt.period)
+		if !reflect.DeepEqual(data.Results, tt.want) || data.Count != len(tt.want) {
+			t.Errorf("%s: 结果 = %v (%d)，期望 %v", tt.name, data.Results, data.Count, tt.want)
+		}
+	}
+}
+
+func TestComparePeriods(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"10", "9", 1},
+		{"2024", "2024", 0},
+		{"2024-02", "2024-11", -1},
+		{"2025Q1", "2024Q4", 1},
+		{"2024-01", "", 1},
+	}
+	for _, tt := range tests {
+		if got := comparePeriods(tt.a, tt.b); got != tt.want {
+			t.Errorf("comparePeriods(%q, %q) = %d，期望 %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -13,6 +13,18 @@ type ServiceGroup struct {
 	SugarFormulaQueryService
 	SugarFormulaAiService
 	SugarFoldersService
+	SugarApiTokensService
+	SugarAnonymizationSessionsService
+	SugarPrivacyBudgetService
+	SugarFileVersionsService
+	SugarWorkbookCollaborationService
+	SugarAuthorizationService
+	SugarWorkspacePermissionsService
+	SugarShareLinksService
+	SugarRecycleBinService
+	SugarWorkbookTemplatesService
+	SugarWorkbookSearchService
+	SugarWorkbookLineageService
 }
 
 // GetSugarFormulaAiService 获取AI服务单例实例
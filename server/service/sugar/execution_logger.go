@@ -67,6 +67,15 @@ func (el *ExecutionLogger) RecordAnonymization(ctx context.Context, logCtx *Exec
 	_ = el.executionLogService.UpdateExecutionLogWithAnonymization(ctx, logCtx, anonymizedInputData, nil)
 }
 
+// RecordAnonymizationPolicy 记录本次调用生效的匿名化策略
+func (el *ExecutionLogger) RecordAnonymizationPolicy(ctx context.Context, logCtx *ExecutionLogContext, policy *ResolvedAnonymizationPolicy) {
+	if logCtx == nil || policy == nil {
+		return
+	}
+
+	_ = el.executionLogService.RecordAnonymizationPolicy(ctx, logCtx, policy)
+}
+
 // RecordAnonymizationOutput 记录匿名化输出
 func (el *ExecutionLogger) RecordAnonymizationOutput(ctx context.Context, logCtx *ExecutionLogContext, analysisResult string) {
 	if logCtx == nil {
@@ -0,0 +1,76 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+)
+
+// 为MCP服务绑定Sugar认证器
+func init() {
+	mcpTool.SetAuthenticator(&McpAuthenticator{})
+}
+
+// McpAuthenticator MCP会话认证器，支持Sugar登录JWT和API令牌
+type McpAuthenticator struct {
+	apiTokenService SugarApiTokensService
+}
+
+// Authenticate 校验令牌并返回对应的用户身份
+func (a *McpAuthenticator) Authenticate(ctx context.Context, token string) (*mcpTool.Identity, error) {
+	if strings.HasPrefix(token, ApiTokenPrefix) {
+		return a.authenticateApiToken(ctx, token)
+	}
+	return a.authenticateJWT(token)
+}
+
+// authenticateJWT 使用与 JWTAuth 中间件相同的规则校验登录令牌
+func (a *McpAuthenticator) authenticateJWT(token string) (*mcpTool.Identity, error) {
+	if _, ok := global.BlackCache.Get(token); ok {
+		return nil, errors.New("您的帐户异地登陆或令牌失效")
+	}
+	claims, err := utils.NewJWT().ParseToken(token)
+	if err != nil {
+		if errors.Is(err, utils.TokenExpired) {
+			return nil, errors.New("授权已过期")
+		}
+		return nil, err
+	}
+	return &mcpTool.Identity{
+		UserId:      claims.BaseClaims.ID,
+		Username:    claims.Username,
+		AuthorityId: claims.AuthorityId,
+		AuthMethod:  mcpTool.AuthMethodJWT,
+	}, nil
+}
+
+// authenticateApiToken 校验API令牌，并确认令牌所属用户仍然有效
+func (a *McpAuthenticator) authenticateApiToken(ctx context.Context, token string) (*mcpTool.Identity, error) {
+	record, err := a.apiTokenService.VerifyApiToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	userId, err := strconv.ParseUint(*record.UserId, 10, 64)
+	if err != nil {
+		return nil, errors.New("API令牌所属用户无效")
+	}
+	var user system.SysUser
+	if err := global.GVA_DB.WithContext(ctx).Select("id", "username", "authority_id", "enable").Where("id = ?", userId).First(&user).Error; err != nil {
+		return nil, errors.New("API令牌所属用户不存在")
+	}
+	if user.Enable == 2 {
+		return nil, errors.New("用户已被冻结")
+	}
+	return &mcpTool.Identity{
+		UserId:      user.ID,
+		Username:    user.Username,
+		AuthorityId: user.AuthorityId,
+		AuthMethod:  mcpTool.AuthMethodApiToken,
+	}, nil
+}
@@ -0,0 +1,319 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	mcpTool "github.com/flipped-aurora/gin-vue-admin/server/mcp"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// 为 mcpTool 中声明的 Sugar 语义查询工具绑定执行器
+func init() {
+	tools := &McpSugarTools{}
+	mcpTool.BindExecutor("sugar_list_models", authenticatedExecutor(tools.ListModels))
+	mcpTool.BindExecutor("sugar_describe_model", authenticatedExecutor(tools.DescribeModel))
+	mcpTool.BindExecutor("sugar_get", authenticatedExecutor(tools.Get))
+	mcpTool.BindExecutor("sugar_calc", authenticatedExecutor(tools.Calc))
+	mcpTool.BindExecutor("sugar_contribution_analysis", authenticatedExecutor(tools.ContributionAnalysis))
+}
+
+// defaultContributionTopN 贡献度分析默认返回的维度组合数量
+const defaultContributionTopN = 20
+
+// McpSugarTools 面向外部MCP客户端的Sugar语义查询工具，所有查询均以会话身份执行
+type McpSugarTools struct {
+	formulaQueryService SugarFormulaQueryService
+}
+
+// McpModelSummary 语义模型概要
+type McpModelSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	TeamId      string `json:"teamId,omitempty"`
+}
+
+// McpModelColumn 语义模型的可返回列
+type McpModelColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// McpModelParameter 语义模型的筛选参数
+type McpModelParameter struct {
+	Name        string `json:"name"`
+	Operator    string `json:"operator"`
+	Description string `json:"description,omitempty"`
+}
+
+// McpModelDescription 语义模型结构说明
+type McpModelDescription struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Dimensions  []McpModelColumn    `json:"dimensions"`
+	Metrics     []McpModelColumn    `json:"metrics"`
+	Parameters  []McpModelParameter `json:"parameters"`
+	Semantics   *AnalysisSemantics  `json:"semantics,omitempty"` // 分析口径声明，未声明时省略
+}
+
+// McpContributionItem 贡献度分析结果项
+type McpContributionItem struct {
+	Dimensions          map[string]interface{} `json:"dimensions"`
+	CurrentValue        float64                `json:"currentValue"`
+	BaseValue           float64                `json:"baseValue"`
+	ChangeValue         float64                `json:"changeValue"`
+	ContributionPercent float64                `json:"contributionPercent"`
+	IsPositiveDriver    bool                   `json:"isPositiveDriver"`
+}
+
+// McpContributionResult 贡献度分析结果
+type McpContributionResult struct {
+	TargetMetric string                `json:"targetMetric"`
+	CurrentTotal float64               `json:"currentTotal"`
+	BaseTotal    float64               `json:"baseTotal"`
+	TotalChange  float64               `json:"totalChange"`
+	ItemCount    int                   `json:"itemCount"`
+	Items        []McpContributionItem `json:"items"`
+
+	TimeIntelligence *sugarRes.TimeIntelligenceInfo `json:"timeIntelligence,omitempty"` // 按时间智能函数计算的本期与基期
+}
+
+// mcpSugarToolFunc 以已认证用户身份执行的工具函数
+type mcpSugarToolFunc func(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// authenticatedExecutor 要求会话已认证，并将身份中的用户ID传给工具函数
+func authenticatedExecutor(fn mcpSugarToolFunc) mcpTool.ToolExecutor {
+	return mcpTool.ToolExecutorFunc(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		identity, err := mcpTool.RequireIdentity(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		global.GVA_LOG.Info("MCP工具被调用",
+			zap.String("tool", request.Params.Name),
+			zap.Uint("userId", identity.UserId),
+			zap.String("authMethod", identity.AuthMethod))
+		return fn(ctx, identity.UserIdString(), request)
+	})
+}
+
+// ListModels 列出用户可访问的语义模型
+func (t *McpSugarTools) ListModels(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceSemanticModel, SugarActionRead)
+	if err != nil {
+		return mcp.NewToolResultError("获取用户团队信息失败"), nil
+	}
+	summaries := make([]McpModelSummary, 0)
+	if len(teamIds) == 0 {
+		return jsonToolResult(summaries)
+	}
+
+	query := global.GVA_DB.WithContext(ctx).Where("team_id IN ? AND deleted_at IS NULL", teamIds)
+	if keyword := strings.TrimSpace(request.GetString("keyword", "")); keyword != "" {
+		query = query.Where("(name LIKE ? OR description LIKE ?)", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	var models []sugar.SugarSemanticModels
+	if err := query.Order("name").Find(&models).Error; err != nil {
+		return mcp.NewToolResultError("获取语义模型失败: " + err.Error()), nil
+	}
+	for _, model := range models {
+		summaries = append(summaries, McpModelSummary{
+			Name:        safeDeref(model.Name),
+			Description: safeDeref(model.Description),
+			TeamId:      safeDeref(model.TeamId),
+		})
+	}
+	return jsonToolResult(summaries)
+}
+
+// DescribeModel 描述语义模型的维度、指标和参数
+func (t *McpSugarTools) DescribeModel(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	modelName, err := request.RequireString("modelName")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	model, err := t.formulaQueryService.getSemanticModel(ctx, modelName, userId)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	description := McpModelDescription{
+		Name:        safeDeref(model.Name),
+		Description: safeDeref(model.Description),
+		Dimensions:  make([]McpModelColumn, 0),
+		Metrics:     make([]McpModelColumn, 0),
+		Parameters:  make([]McpModelParameter, 0),
+	}
+
+	var columns map[string]map[string]interface{}
+	if len(model.ReturnableColumnsConfig) > 0 && json.Unmarshal(model.ReturnableColumnsConfig, &columns) == nil {
+		for name, config := range columns {
+			column := McpModelColumn{Name: name}
+			column.Type, _ = config["type"].(string)
+			column.Description, _ = config["description"].(string)
+			if column.Type == "metric" || column.Type == "measure" {
+				description.Metrics = append(description.Metrics, column)
+			} else {
+				description.Dimensions = append(description.Dimensions, column)
+			}
+		}
+	}
+	var params map[string]map[string]interface{}
+	if len(model.ParameterConfig) > 0 && json.Unmarshal(model.ParameterConfig, &params) == nil {
+		for name, config := range params {
+			param := McpModelParameter{Name: name, Operator: "="}
+			if operator, ok := config["operator"].(string); ok && operator != "" {
+				param.Operator = operator
+			}
+			param.Description, _ = config["description"].(string)
+			description.Parameters = append(description.Parameters, param)
+		}
+	}
+	sort.Slice(description.Dimensions, func(i, j int) bool { return description.Dimensions[i].Name < description.Dimensions[j].Name })
+	sort.Slice(description.Metrics, func(i, j int) bool { return description.Metrics[i].Name < description.Metrics[j].Name })
+	sort.Slice(description.Parameters, func(i, j int) bool { return description.Parameters[i].Name < description.Parameters[j].Name })
+	if semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics); err == nil && !semantics.IsEmpty() {
+		description.Semantics = semantics
+	}
+
+	return jsonToolResult(description)
+}
+
+// Get 执行 SUGAR.GET 查询
+func (t *McpSugarTools) Get(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req sugarReq.SugarFormulaGetRequest
+	if err := request.BindArguments(&req); err != nil {
+		return mcp.NewToolResultError("解析工具调用参数失败: " + err.Error()), nil
+	}
+	if req.ModelName == "" || len(req.ReturnColumns) == 0 {
+		return mcp.NewToolResultError("modelName 和 returnColumns 不能为空"), nil
+	}
+	resp, err := t.formulaQueryService.ExecuteGetFormula(ctx, &req, userId)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return mcp.NewToolResultError(resp.Error), nil
+	}
+	return jsonToolResult(resp)
+}
+
+// Calc 执行 SUGAR.CALC 计算
+func (t *McpSugarTools) Calc(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req sugarReq.SugarFormulaCalcRequest
+	if err := request.BindArguments(&req); err != nil {
+		return mcp.NewToolResultError("解析工具调用参数失败: " + err.Error()), nil
+	}
+	req.CalcMethod = strings.ToUpper(req.CalcMethod)
+	if req.ModelName == "" || req.CalcColumn == "" {
+		return mcp.NewToolResultError("modelName 和 calcColumn 不能为空"), nil
+	}
+	resp, err := t.formulaQueryService.ExecuteCalcFormula(ctx, &req, userId)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return mcp.NewToolResultError(resp.Error), nil
+	}
+	return jsonToolResult(resp)
+}
+
+// ContributionAnalysis 执行贡献度分析，返回未经匿名化的结果（调用方即数据所属用户）
+func (t *McpSugarTools) ContributionAnalysis(ctx context.Context, userId string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var params struct {
+		ModelName            string                 `json:"modelName"`
+		TargetMetric         string                 `json:"targetMetric"`
+		CurrentPeriodFilters map[string]interface{} `json:"currentPeriodFilters"`
+		BasePeriodFilters    map[string]interface{} `json:"basePeriodFilters"`
+		GroupByDimensions    []string               `json:"groupByDimensions"`
+		TopN                 int                    `json:"topN"`
+
+		TimeIntelligence *sugarReq.TimeIntelligence `json:"timeIntelligence"`
+	}
+	if err := request.BindArguments(&params); err != nil {
+		return mcp.NewToolResultError("解析工具调用参数失败: " + err.Error()), nil
+	}
+	if params.ModelName == "" || params.TargetMetric == "" || len(params.GroupByDimensions) == 0 {
+		return mcp.NewToolResultError("modelName、targetMetric 和 groupByDimensions 不能为空"), nil
+	}
+	if params.TopN <= 0 {
+		params.TopN = defaultContributionTopN
+	}
+
+	dataProcessor := NewDataProcessor()
+	var windows *TimeWindows
+	if params.TimeIntelligence != nil && params.TimeIntelligence.Function != "" {
+		var err error
+		params.CurrentPeriodFilters, params.BasePeriodFilters, windows, err = dataProcessor.ResolvePeriodFilters(ctx, params.ModelName, userId, params.TimeIntelligence, params.CurrentPeriodFilters, params.BasePeriodFilters)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	currentData, baseData, err := dataProcessor.FetchDataConcurrently(ctx, params.ModelName, params.TargetMetric, params.CurrentPeriodFilters, params.BasePeriodFilters, params.GroupByDimensions, userId)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	analyzer := NewContributionAnalyzer(nil)
+	contributions, err := analyzer.calculateContributions(currentData, baseData, params.TargetMetric, params.GroupByDimensions)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("贡献度计算失败: %v", err)), nil
+	}
+
+	result := McpContributionResult{
+		TargetMetric: params.TargetMetric,
+		ItemCount:    len(contributions),
+		Items:        make([]McpContributionItem, 0, len(contributions)),
+	}
+	if windows != nil {
+		result.TimeIntelligence = windows.Info()
+	}
+	for _, item := range contributions {
+		result.CurrentTotal += item.CurrentValue
+		result.BaseTotal += item.BaseValue
+		result.TotalChange += item.ChangeValue
+	}
+	sort.SliceStable(contributions, func(i, j int) bool {
+		return math.Abs(contributions[i].ChangeValue) > math.Abs(contributions[j].ChangeValue)
+	})
+	if len(contributions) > params.TopN {
+		contributions = contributions[:params.TopN]
+	}
+	for _, item := range contributions {
+		result.Items = append(result.Items, McpContributionItem{
+			Dimensions:          item.DimensionValues,
+			CurrentValue:        item.CurrentValue,
+			BaseValue:           item.BaseValue,
+			ChangeValue:         item.ChangeValue,
+			ContributionPercent: item.ContributionPercent,
+			IsPositiveDriver:    item.IsPositiveDriver,
+		})
+	}
+	return jsonToolResult(result)
+}
+
+// jsonToolResult 将结果序列化为JSON文本返回
+func jsonToolResult(value interface{}) (*mcp.CallToolResult, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("序列化工具结果失败: %w", err)
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// safeDeref 安全解引用字符串指针
+func safeDeref(str *string) string {
+	if str == nil {
+		return ""
+	}
+	return *str
+}
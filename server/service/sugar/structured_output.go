@@ -0,0 +1,493 @@
+package sugar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"go.uber.org/zap"
+)
+
+// StructuredOutputProcessor 结构化输出处理器 - 负责AIFETCH结构化输出的schema解析、模型调用、校验与修复
+type StructuredOutputProcessor struct {
+	llmService system.SysLLMService
+}
+
+// StructuredOutputResult 结构化输出结果
+type StructuredOutputResult struct {
+	Table    [][]interface{} // 第一行为表头的二维表格
+	Warnings []string        // 校验和修复过程中的提示
+	Raw      string          // 模型原始返回
+}
+
+// NewStructuredOutputProcessor 创建结构化输出处理器
+func NewStructuredOutputProcessor() *StructuredOutputProcessor {
+	return &StructuredOutputProcessor{
+		llmService: system.SysLLMService{},
+	}
+}
+
+// 结构化输出解析失败后允许的修复重试次数
+const structuredOutputRepairAttempts = 1
+
+var (
+	trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
+	numberCleanPattern   = regexp.MustCompile(`[,\s￥¥$]`)
+	structuredDateLayout = []string{
+		"2006-01-02",
+		"2006/01/02",
+		"2006.01.02",
+		"2006-1-2",
+		"2006/1/2",
+		"2006年1月2日",
+		"2006-01",
+		"2006/01",
+		"2006年1月",
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+	}
+)
+
+// ResolveSchema 解析本次调用的结构化输出定义，公式参数优先于Agent默认配置，均未配置时返回nil
+func (sp *StructuredOutputProcessor) ResolveSchema(req *sugarReq.SugarFormulaAiFetchRequest, agent *sugar.SugarAgents) (*sugarReq.AiOutputSchema, error) {
+	schema := req.OutputSchema
+	if schema == nil && agent != nil && len(agent.OutputSchema) > 0 && string(agent.OutputSchema) != "null" {
+		schema = &sugarReq.AiOutputSchema{}
+		if err := json.Unmarshal(agent.OutputSchema, schema); err != nil {
+			return nil, fmt.Errorf("解析Agent结构化输出配置失败: %w", err)
+		}
+	}
+	if schema == nil {
+		return nil, nil
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, fmt.Errorf("结构化输出定义无效: %w", err)
+	}
+	return schema, nil
+}
+
+// BuildJSONSchema 将列定义转换为提供给模型的JSON Schema，统一包装为 {"rows": [...]} 结构
+func (sp *StructuredOutputProcessor) BuildJSONSchema(schema *sugarReq.AiOutputSchema) map[string]interface{} {
+	properties := make(map[string]interface{}, len(schema.Columns))
+	required := make([]string, 0, len(schema.Columns))
+	for _, column := range schema.Columns {
+		var prop map[string]interface{}
+		switch column.Type {
+		case "date":
+			prop = map[string]interface{}{"type": []string{"string", "null"}, "description": "日期，格式 YYYY-MM-DD"}
+		default:
+			prop = map[string]interface{}{"type": []string{column.Type, "null"}}
+		}
+		if column.Description != "" {
+			if desc, ok := prop["description"].(string); ok {
+				prop["description"] = column.Description + "；" + desc
+			} else {
+				prop["description"] = column.Description
+			}
+		}
+		properties[column.Name] = prop
+		required = append(required, column.Name)
+	}
+
+	rows := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		},
+	}
+	if schema.MaxRows > 0 {
+		rows["maxItems"] = schema.MaxRows
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           map[string]interface{}{"rows": rows},
+		"required":             []string{"rows"},
+		"additionalProperties": false,
+	}
+}
+
+// BuildInstruction 构建结构化输出的提示词约束，对不支持 response_format 的模型同样生效
+func (sp *StructuredOutputProcessor) BuildInstruction(schema *sugarReq.AiOutputSchema) string {
+	var builder strings.Builder
+	builder.WriteString("\n\n---\n**输出格式要求（必须严格遵守）:**\n")
+	builder.WriteString("- 只输出一个JSON对象，不要输出任何解释、Markdown或代码块标记\n")
+	builder.WriteString("- JSON格式为 {\"rows\": [ {...}, {...} ]}，rows中的每个对象代表表格中的一行\n")
+	builder.WriteString("- 每行必须且只能包含以下字段：\n")
+	for _, column := range schema.Columns {
+		builder.WriteString(fmt.Sprintf("  * \"%s\" (%s)", column.Name, sp.describeColumnType(column.Type)))
+		if column.Description != "" {
+			builder.WriteString("：" + column.Description)
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("- 数值字段直接输出数字，不要带单位、千分位或百分号；无法确定的值输出 null\n")
+	if schema.MaxRows > 0 {
+		builder.WriteString(fmt.Sprintf("- 最多输出 %d 行\n", schema.MaxRows))
+	}
+	return builder.String()
+}
+
+// Generate 调用模型生成结构化输出，并在解析失败时将错误反馈给模型进行修复
+func (sp *StructuredOutputProcessor) Generate(ctx context.Context, llmConfig *system.LLMConfig, messages []system.ChatMessage, schema *sugarReq.AiOutputSchema) (*StructuredOutputResult, error) {
+	jsonSchema := sp.BuildJSONSchema(schema)
+
+	raw, err := sp.llmService.ChatStructured(ctx, *llmConfig, messages, schema.Name, jsonSchema)
+	if err != nil {
+		return nil, fmt.Errorf("结构化输出调用失败: %w", err)
+	}
+
+	table, warnings, parseErr := sp.ParseTable(raw, schema)
+	for attempt := 0; parseErr != nil && attempt < structuredOutputRepairAttempts; attempt++ {
+		global.GVA_LOG.Warn("结构化输出解析失败，尝试让模型修复",
+			zap.Int("attempt", attempt+1),
+			zap.Error(parseErr))
+
+		repairMessages := append(append([]system.ChatMessage{}, messages...),
+			system.ChatMessage{Role: "assistant", Content: raw},
+			system.ChatMessage{Role: "user", Content: sp.buildRepairMessage(parseErr, schema)},
+		)
+		raw, err = sp.llmService.ChatStructured(ctx, *llmConfig, repairMessages, schema.Name, jsonSchema)
+		if err != nil {
+			return nil, fmt.Errorf("结构化输出修复调用失败: %w", err)
+		}
+		table, warnings, parseErr = sp.ParseTable(raw, schema)
+		if parseErr == nil {
+			warnings = append([]string{"模型首次返回的结果不符合输出格式，已自动修复"}, warnings...)
+		}
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("模型返回的结果不符合结构化输出定义: %w", parseErr)
+	}
+
+	return &StructuredOutputResult{Table: table, Warnings: warnings, Raw: raw}, nil
+}
+
+// StructureText 将模型已生成的自由文本回答转换为结构化表格
+func (sp *StructuredOutputProcessor) StructureText(ctx context.Context, llmConfig *system.LLMConfig, description, text string, schema *sugarReq.AiOutputSchema) (*StructuredOutputResult, error) {
+	// 模型可能已经按要求输出了JSON，直接解析成功则无需再次调用
+	if table, warnings, err := sp.ParseTable(text, schema); err == nil {
+		return &StructuredOutputResult{Table: table, Warnings: warnings, Raw: text}, nil
+	}
+
+	messages := []system.ChatMessage{
+		{Role: "system", Content: "你是一个数据整理助手，负责把分析结论整理为表格数据。" + sp.BuildInstruction(schema)},
+		{Role: "user", Content: fmt.Sprintf("用户需求：%s\n\n待整理的内容：\n%s", description, text)},
+	}
+	return sp.Generate(ctx, llmConfig, messages, schema)
+}
+
+// DecodeCells 对表格中的字符串单元格执行解码（例如匿名化代号还原），表头保持不变
+func (sp *StructuredOutputProcessor) DecodeCells(table [][]interface{}, decode func(string) (string, error)) error {
+	for i := 1; i < len(table); i++ {
+		for j, cell := range table[i] {
+			str, ok := cell.(string)
+			if !ok || str == "" {
+				continue
+			}
+			decoded, err := decode(str)
+			if err != nil {
+				return err
+			}
+			table[i][j] = decoded
+		}
+	}
+	return nil
+}
+
+// ParseTable 解析并按schema校验模型返回的JSON，返回带表头的二维表格
+func (sp *StructuredOutputProcessor) ParseTable(raw string, schema *sugarReq.AiOutputSchema) ([][]interface{}, []string, error) {
+	payload, err := sp.extractJSON(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := sp.extractRows(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	if schema.MaxRows > 0 && len(rows) > schema.MaxRows {
+		warnings = append(warnings, fmt.Sprintf("模型返回了%d行，已按maxRows截断为%d行", len(rows), schema.MaxRows))
+		rows = rows[:schema.MaxRows]
+	}
+
+	header := make([]interface{}, len(schema.Columns))
+	columnIndex := make(map[string]int, len(schema.Columns))
+	for i, column := range schema.Columns {
+		header[i] = column.Name
+		columnIndex[sp.normalizeKey(column.Name)] = i
+	}
+
+	table := make([][]interface{}, 0, len(rows)+1)
+	table = append(table, header)
+	unknownKeys := make(map[string]bool)
+
+	for rowIndex, row := range rows {
+		values := make([]interface{}, len(schema.Columns))
+		switch r := row.(type) {
+		case map[string]interface{}:
+			for key, value := range r {
+				idx, ok := columnIndex[sp.normalizeKey(key)]
+				if !ok {
+					unknownKeys[key] = true
+					continue
+				}
+				values[idx] = value
+			}
+		case []interface{}:
+			if len(r) != len(schema.Columns) {
+				warnings = append(warnings, fmt.Sprintf("第%d行包含%d个值，与定义的%d列不一致", rowIndex+1, len(r), len(schema.Columns)))
+			}
+			for i := 0; i < len(r) && i < len(values); i++ {
+				values[i] = r[i]
+			}
+		default:
+			return nil, nil, fmt.Errorf("第%d行不是对象或数组", rowIndex+1)
+		}
+
+		for i, column := range schema.Columns {
+			coerced, ok := sp.coerceValue(values[i], column.Type)
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("第%d行 %s 的值 %v 无法转换为%s，已置空", rowIndex+1, column.Name, values[i], sp.describeColumnType(column.Type)))
+			}
+			values[i] = coerced
+		}
+		table = append(table, values)
+	}
+
+	if len(unknownKeys) > 0 {
+		var keys []string
+		for key := range unknownKeys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		warnings = append(warnings, "已忽略未定义的字段: "+strings.Join(keys, ", "))
+	}
+
+	return table, warnings, nil
+}
+
+// extractJSON 从模型返回中提取JSON，兼容代码块包裹、前后说明文字和多余的尾逗号
+func (sp *StructuredOutputProcessor) extractJSON(raw string) (interface{}, error) {
+	text := strings.TrimSpace(raw)
+	if text == "" {
+		return nil, errors.New("模型返回为空")
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return nil, errors.New("返回内容中未找到JSON")
+	}
+	text = text[start:]
+	if end := sp.matchingBracket(text); end > 0 {
+		text = text[:end+1]
+	}
+
+	candidates := []string{text, trailingCommaPattern.ReplaceAllString(text, "$1")}
+	var lastErr error
+	for _, candidate := range candidates {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(candidate)))
+		decoder.UseNumber()
+		var payload interface{}
+		if err := decoder.Decode(&payload); err != nil {
+			lastErr = err
+			continue
+		}
+		return payload, nil
+	}
+	return nil, fmt.Errorf("JSON解析失败: %w", lastErr)
+}
+
+// matchingBracket 返回与首个括号匹配的闭合括号位置，忽略字符串中的括号
+func (sp *StructuredOutputProcessor) matchingBracket(text string) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for i, ch := range text {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// extractRows 从解析后的JSON中找出行数组
+func (sp *StructuredOutputProcessor) extractRows(payload interface{}) ([]interface{}, error) {
+	switch p := payload.(type) {
+	case []interface{}:
+		return p, nil
+	case map[string]interface{}:
+		if rows, ok := p["rows"].([]interface{}); ok {
+			return rows, nil
+		}
+		// 兼容模型使用了其他键名（如 data、result）包裹唯一的数组
+		var arrays [][]interface{}
+		for _, value := range p {
+			if arr, ok := value.([]interface{}); ok {
+				arrays = append(arrays, arr)
+			}
+		}
+		if len(arrays) == 1 {
+			return arrays[0], nil
+		}
+		if len(arrays) == 0 {
+			// 单个对象视为只有一行
+			return []interface{}{p}, nil
+		}
+		return nil, errors.New("JSON中包含多个数组，无法确定表格数据")
+	default:
+		return nil, errors.New("JSON顶层必须是对象或数组")
+	}
+}
+
+// coerceValue 将单元格的值转换为列定义的类型，无法转换时返回nil和false
+func (sp *StructuredOutputProcessor) coerceValue(value interface{}, columnType string) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	if str, ok := value.(string); ok {
+		trimmed := strings.TrimSpace(str)
+		if trimmed == "" || strings.EqualFold(trimmed, "null") || trimmed == "-" || strings.EqualFold(trimmed, "N/A") {
+			return nil, true
+		}
+	}
+
+	switch columnType {
+	case "number":
+		number, ok := sp.toFloat(value)
+		if !ok {
+			return nil, false
+		}
+		return number, true
+	case "integer":
+		number, ok := sp.toFloat(value)
+		if !ok {
+			return nil, false
+		}
+		return int64(math.Round(number)), true
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case json.Number:
+			return v.String() != "0", true
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "y", "1", "是", "对":
+				return true, true
+			case "false", "no", "n", "0", "否", "不是":
+				return false, true
+			}
+		}
+		return nil, false
+	case "date":
+		str := strings.TrimSpace(fmt.Sprintf("%v", value))
+		for _, layout := range structuredDateLayout {
+			if parsed, err := time.Parse(layout, str); err == nil {
+				return parsed.Format("2006-01-02"), true
+			}
+		}
+		return nil, false
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case json.Number:
+			return v.String(), true
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, false
+			}
+			return string(encoded), true
+		default:
+			return fmt.Sprintf("%v", v), true
+		}
+	}
+}
+
+// toFloat 将模型返回的数值转换为float64，兼容带千分位、货币符号和百分号的字符串
+func (sp *StructuredOutputProcessor) toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case bool:
+		return 0, false
+	case string:
+		cleaned := numberCleanPattern.ReplaceAllString(strings.TrimSpace(v), "")
+		// 百分数按比例转换，"12%" 为 0.12
+		percent := strings.HasSuffix(cleaned, "%")
+		f, err := strconv.ParseFloat(strings.TrimSuffix(cleaned, "%"), 64)
+		if percent {
+			f /= 100
+		}
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// normalizeKey 规范化字段名，用于容忍模型输出的大小写和空白差异
+func (sp *StructuredOutputProcessor) normalizeKey(key string) string {
+	return strings.ToLower(strings.Join(strings.Fields(key), ""))
+}
+
+// describeColumnType 返回列类型的中文描述
+func (sp *StructuredOutputProcessor) describeColumnType(columnType string) string {
+	switch columnType {
+	case "number":
+		return "数字"
+	case "integer":
+		return "整数"
+	case "boolean":
+		return "布尔值"
+	case "date":
+		return "日期 YYYY-MM-DD"
+	default:
+		return "文本"
+	}
+}
+
+// buildRepairMessage 构建让模型修复输出格式的提示
+func (sp *StructuredOutputProcessor) buildRepairMessage(parseErr error, schema *sugarReq.AiOutputSchema) string {
+	return fmt.Sprintf("你上一次的输出无法解析：%s。请只输出修正后的JSON，不要包含其他内容。%s", parseErr.Error(), sp.BuildInstruction(schema))
+}
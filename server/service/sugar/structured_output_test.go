@@ -0,0 +1,164 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"go.uber.org/zap"
+)
+
+func salesOutputSchema(t *testing.T, maxRows int) *sugarReq.AiOutputSchema {
+	t.Helper()
+	schema := &sugarReq.AiOutputSchema{MaxRows: maxRows, Columns: []sugarReq.AiOutputColumn{
+		{Name: "城市"}, {Name: "销售额", Type: "number"}, {Name: "订单数", Type: "integer"}, {Name: "达标", Type: "boolean"}, {Name: "日期", Type: "date"},
+	}}
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("结构化输出定义无效: %v", err)
+	}
+	return schema
+}
+
+func TestStructuredOutputCoerceValue(t *testing.T) {
+	sp := NewStructuredOutputProcessor()
+	tests := []struct {
+		value      interface{}
+		columnType string
+		want       interface{}
+		ok         bool
+	}{
+		{nil, "number", nil, true},
+		{" N/A ", "number", nil, true},
+		{"-", "date", nil, true},
+		{json.Number("12.5"), "number", 12.5, true},
+		{"￥1,234.50", "number", 1234.5, true},
+		{"12%", "number", 0.12, true},
+		{"-3.5 %", "number", -0.035, true},
+		{"十二", "number", nil, false},
+		{true, "number", nil, false},
+		{"1,234.6", "integer", int64(1235), true},
+		{"50%", "integer", int64(1), true},
+		{"是", "boolean", true, true},
+		{"No", "boolean", false, true},
+		{json.Number("0"), "boolean", false, true},
+		{"也许", "boolean", nil, false},
+		{"2024/3/5", "date", "2024-03-05", true},
+		{"2024年3月", "date", "2024-03-01", true},
+		{"三月五日", "date", nil, false},
+		{json.Number("42"), "string", "42", true},
+		{map[string]interface{}{"a": json.Number("1")}, "string", `{"a":1}`, true},
+	}
+	for _, tt := range tests {
+		got, ok := sp.coerceValue(tt.value, tt.columnType)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerceValue(%#v, %s) = (%#v, %v)，期望 (%#v, %v)", tt.value, tt.columnType, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStructuredOutputParseTable(t *testing.T) {
+	sp := NewStructuredOutputProcessor()
+	header := []interface{}{"城市", "销售额", "订单数", "达标", "日期"}
+	tests := []struct {
+		name     string
+		raw      string
+		maxRows  int
+		want     [][]interface{}
+		warnings []string
+		wantErr  bool
+	}{
+		{
+			name: "代码块包裹且带尾逗号",
+			raw:  "结果如下：\n```json\n{\"rows\": [{\"城市\": \"北京\", \"销售额\": \"1,200\", \"订单数\": 3, \"达标\": \"是\", \"日期\": \"2024/1/2\"},]}\n```",
+			want: [][]interface{}{header, {"北京", 1200.0, int64(3), true, "2024-01-02"}},
+		},
+		{
+			name: "字段名忽略大小写和空白，未定义的字段给出提示",
+			raw:  `[{" 城市 ": "上海", "销售额": 12.5, "备注": "x"}]`,
+			want: [][]interface{}{header, {"上海", 12.5, nil, nil, nil}},
+			warnings: []string{
+				"已忽略未定义的字段: 备注",
+			},
+		},
+		{
+			name:    "数组形式的行按列顺序取值并截断到 maxRows",
+			raw:     `{"data": [["北京", "10%", 1, false, "2024-01"], ["上海", 2, 2, true, null], ["深圳", 3, 3, true, null]]}`,
+			maxRows: 2,
+			want:    [][]interface{}{header, {"北京", 0.1, int64(1), false, "2024-01-01"}, {"上海", 2.0, int64(2), true, nil}},
+			warnings: []string{
+				"模型返回了3行，已按maxRows截断为2行",
+			},
+		},
+		{
+			name: "无法转换的值置空",
+			raw:  `{"rows": [{"城市": "广州", "销售额": "很多"}]}`,
+			want: [][]interface{}{header, {"广州", nil, nil, nil, nil}},
+			warnings: []string{
+				"第1行 销售额 的值 很多 无法转换为数字，已置空",
+			},
+		},
+		{name: "没有JSON", raw: "无法回答", wantErr: true},
+		{name: "多个数组", raw: `{"a": [1], "b": [2]}`, wantErr: true},
+		{name: "行不是对象或数组", raw: `{"rows": [1]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		table, warnings, err := sp.ParseTable(tt.raw, salesOutputSchema(t, tt.maxRows))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: 期望解析失败，实际 %v", tt.name, table)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: 解析失败: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(table, tt.want) {
+			t.Errorf("%s: 表格 = %#v，期望 %#v", tt.name, table, tt.want)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s: 提示 = %q，期望 %q", tt.name, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestStructuredOutputGenerateRepair(t *testing.T) {
+	global.GVA_LOG = zap.NewNop()
+	ctx := context.Background()
+	schema := salesOutputSchema(t, 0)
+	messages := []system.ChatMessage{{Role: "user", Content: "列出各城市销售额"}}
+
+	// 首次返回无法解析的内容，修复请求中带上解析错误后返回正确的JSON
+	stub, llmConfig := newStubLLM(t)
+	stub.response = func(prompt string) string {
+		if strings.Contains(prompt, "无法解析") {
+			return `{"rows": [{"城市": "北京", "销售额": 100}]}`
+		}
+		return "抱歉，我无法确定"
+	}
+	result, err := NewStructuredOutputProcessor().Generate(ctx, llmConfig, messages, schema)
+	if err != nil {
+		t.Fatalf("修复后应解析成功: %v", err)
+	}
+	if len(stub.prompts) != 2 || len(result.Table) != 2 || result.Table[1][1] != 100.0 {
+		t.Fatalf("修复结果不符合预期: %d 次调用, %v", len(stub.prompts), result.Table)
+	}
+	if len(result.Warnings) == 0 || !strings.Contains(result.Warnings[0], "已自动修复") {
+		t.Fatalf("应提示已自动修复: %v", result.Warnings)
+	}
+
+	// 只修复一次，仍无法解析时返回错误
+	stub, llmConfig = newStubLLM(t)
+	stub.response = func(string) string { return "仍然不是JSON" }
+	if _, err = NewStructuredOutputProcessor().Generate(ctx, llmConfig, messages, schema); err == nil {
+		t.Fatal("修复后仍无法解析时应返回错误")
+	}
+	if len(stub.prompts) != 1+structuredOutputRepairAttempts {
+		t.Fatalf("调用次数 = %d，期望 %d", len(stub.prompts), 1+structuredOutputRepairAttempts)
+	}
+}
@@ -11,22 +11,32 @@ import (
 
 type SugarAgentsService struct{}
 
+// GetAvailableAgentTools 获取Agent可声明使用的工具列表
+func (s *SugarAgentsService) GetAvailableAgentTools(ctx context.Context) []string {
+	return AvailableAgentTools()
+}
+
 // CreateSugarAgents 创建sugar智能体表记录
-func (s *SugarAgentsService) CreateSugarAgents(ctx context.Context, sugarAgents *sugar.SugarAgents) (err error) {
+func (s *SugarAgentsService) CreateSugarAgents(ctx context.Context, sugarAgents *sugar.SugarAgents, userId string) (err error) {
+	if err = authorizeTeamResource(ctx, sugarAgents.TeamId, userId, SugarResourceAgent, SugarActionEdit); err != nil {
+		return err
+	}
+	// 校验声明式定义：工具、模型参数、允许的语义模型和提示词模板
+	if err = ValidateAgentDefinition(ctx, sugarAgents); err != nil {
+		return err
+	}
 	err = global.GVA_DB.Create(sugarAgents).Error
 	return err
 }
 
 // DeleteSugarAgents 删除sugar智能体表记录
 func (s *SugarAgentsService) DeleteSugarAgents(ctx context.Context, id string, userId string) (err error) {
-	// 增加权限校验：只允许创建者或团队管理员删除
 	var agent sugar.SugarAgents
 	if err = global.GVA_DB.Where("id = ?", id).First(&agent).Error; err != nil {
 		return errors.New("记录不存在")
 	}
-	// 此处应有更复杂的团队角色权限判断，暂时简化为只判断创建者
-	if *agent.CreatedBy != userId {
-		return errors.New("无权删除")
+	if err = authorizeTeamResource(ctx, agent.TeamId, userId, SugarResourceAgent, SugarActionDelete); err != nil {
+		return err
 	}
 	err = global.GVA_DB.Delete(&sugar.SugarAgents{}, "id = ?", id).Error
 	return err
@@ -34,21 +44,37 @@ func (s *SugarAgentsService) DeleteSugarAgents(ctx context.Context, id string, u
 
 // DeleteSugarAgentsByIds 批量删除sugar智能体表记录
 func (s *SugarAgentsService) DeleteSugarAgentsByIds(ctx context.Context, ids []string, userId string) (err error) {
-	// 增加权限校验：只允许用户删除自己创建的记录
-	err = global.GVA_DB.Where("id IN ? AND created_by = ?", ids, userId).Delete(&[]sugar.SugarAgents{}).Error
+	// 只批量删除用户有删除权限的团队中的智能体
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceAgent, SugarActionDelete)
+	if err != nil || len(teamIds) == 0 {
+		return err
+	}
+	err = global.GVA_DB.Where("id IN ? AND team_id IN ?", ids, teamIds).Delete(&[]sugar.SugarAgents{}).Error
 	return err
 }
 
 // UpdateSugarAgents 更新sugar智能体表记录
 func (s *SugarAgentsService) UpdateSugarAgents(ctx context.Context, agent sugar.SugarAgents, userId string) (err error) {
-	// 增加权限校验：只允许创建者或团队管理员更新
 	var oldAgent sugar.SugarAgents
 	if err = global.GVA_DB.Where("id = ?", agent.Id).First(&oldAgent).Error; err != nil {
 		return errors.New("记录不存在")
 	}
-	// 此处应有更复杂的团队角色权限判断，暂时简化为只判断创建者
-	if *oldAgent.CreatedBy != userId {
-		return errors.New("无权更新")
+	if err = authorizeTeamResource(ctx, oldAgent.TeamId, userId, SugarResourceAgent, SugarActionEdit); err != nil {
+		return err
+	}
+	if agent.TeamId == nil {
+		agent.TeamId = oldAgent.TeamId
+	} else if oldAgent.TeamId == nil || *agent.TeamId != *oldAgent.TeamId {
+		// 修改所属团队需要原团队和目标团队的管理权限
+		if err = authorizeTeamResource(ctx, oldAgent.TeamId, userId, SugarResourceAgent, SugarActionManage); err != nil {
+			return err
+		}
+		if err = authorizeTeamAction(ctx, *agent.TeamId, userId, SugarResourceAgent, SugarActionManage); err != nil {
+			return err
+		}
+	}
+	if err = ValidateAgentDefinition(ctx, &agent); err != nil {
+		return err
 	}
 	err = global.GVA_DB.Model(&sugar.SugarAgents{}).Where("id = ?", agent.Id).Updates(&agent).Error
 	return err
@@ -59,10 +85,8 @@ func (s *SugarAgentsService) GetSugarAgents(ctx context.Context, id string, user
 	if err = global.GVA_DB.Where("id = ?", id).First(&agent).Error; err != nil {
 		return agent, errors.New("记录不存在")
 	}
-	// 此处应有更复杂的团队角色权限判断，暂时简化为只判断创建者或团队成员
-	if *agent.CreatedBy != userId {
-		// 可以在这里查询 team_members 表确认用户是否是团队成员
-		// return agent, errors.New("无权查看")
+	if err = authorizeTeamResource(ctx, agent.TeamId, userId, SugarResourceAgent, SugarActionRead); err != nil {
+		return agent, err
 	}
 	return agent, nil
 }
@@ -72,9 +96,8 @@ func (s *SugarAgentsService) GetSugarAgentsListByUser(ctx context.Context, info
 	limit := info.PageSize
 	offset := info.PageSize * (info.Page - 1)
 
-	// 1. 查找用户所在的所有团队
-	var teamIds []string
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
+	// 1. 查找用户可查看智能体的所有团队
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceAgent, SugarActionRead)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,341 @@
+package sugar
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultAnonymizationRetentionDays 未配置时加密映射的保留天数
+const defaultAnonymizationRetentionDays = 30
+
+// defaultReviewerAuthorityId 未配置审计角色时允许重新解码他人日志的角色（超级管理员）
+const defaultReviewerAuthorityId uint = 888
+
+type SugarAnonymizationSessionsService struct{}
+
+// anonymizationMapping 加密存储的代号映射
+type anonymizationMapping struct {
+	ForwardMap         map[string]string                                    `json:"forward_map"`
+	ReverseMap         map[string]string                                    `json:"reverse_map"`
+	DimensionSemantics map[string]*anonymization_lite.DimensionSemanticInfo `json:"dimension_semantics,omitempty"`
+}
+
+// LoadConversationSession 加载对话中可复用的匿名化会话，只查找当前用户创建、未过期且映射未销毁的会话
+// 未找到时返回 (nil, "", nil)
+func (s *SugarAnonymizationSessionsService) LoadConversationSession(ctx context.Context, conversationId, userId string) (*anonymization_lite.LiteAnonymizationSession, string, error) {
+	if conversationId == "" {
+		return nil, "", nil
+	}
+	var record sugar.SugarAnonymizationSessions
+	err := global.GVA_DB.WithContext(ctx).
+		Where("conversation_id = ? AND user_id = ? AND purged_at IS NULL AND expires_at > ?", conversationId, userId, time.Now()).
+		Order("updated_at DESC").
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("查询对话匿名化会话失败: %w", err)
+	}
+	session, err := s.restoreSession(&record)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, *record.Id, nil
+}
+
+// SaveSession 持久化匿名化会话并关联执行日志
+// sessionId 非空时更新该会话（同一对话的后续轮次），否则新建；每次保存都会顺延映射保留期限
+func (s *SugarAnonymizationSessionsService) SaveSession(ctx context.Context, sessionId, conversationId, userId string, agentId *string, session *anonymization_lite.LiteAnonymizationSession, logId int64) (string, error) {
+	if session == nil {
+		return "", errors.New("匿名化会话为空")
+	}
+	encrypted, err := encryptAnonymizationMapping(anonymizationMapping{
+		ForwardMap:         session.ForwardMap,
+		ReverseMap:         session.ReverseMap,
+		DimensionSemantics: session.DimensionSemantics,
+	})
+	if err != nil {
+		return "", err
+	}
+	configJSON, err := json.Marshal(session.Config)
+	if err != nil {
+		return "", fmt.Errorf("序列化匿名化配置失败: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.AddDate(0, 0, anonymizationRetentionDays())
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if sessionId != "" {
+			result := tx.Model(&sugar.SugarAnonymizationSessions{}).
+				Where("id = ? AND user_id = ? AND purged_at IS NULL", sessionId, userId).
+				Updates(map[string]interface{}{
+					"encrypted_mapping": encrypted,
+					"config":            configJSON,
+					"epsilon_spent":     session.EpsilonSpent,
+					"mapping_count":     len(session.ForwardMap),
+					"turn_count":        gorm.Expr("turn_count + 1"),
+					"expires_at":        expiresAt,
+					"updated_at":        now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("匿名化会话不存在或已销毁")
+			}
+		} else {
+			sessionId = uuid.New().String()
+			record := sugar.SugarAnonymizationSessions{
+				Id:               &sessionId,
+				UserId:           &userId,
+				AgentId:          agentId,
+				EncryptedMapping: &encrypted,
+				Config:           configJSON,
+				EpsilonSpent:     session.EpsilonSpent,
+				MappingCount:     len(session.ForwardMap),
+				TurnCount:        1,
+				ExpiresAt:        &expiresAt,
+				CreatedAt:        &now,
+				UpdatedAt:        &now,
+			}
+			if conversationId != "" {
+				record.ConversationId = &conversationId
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		if logId > 0 {
+			return tx.Model(&sugar.SugarExecutionLogs{}).Where("id = ?", logId).Update("anonymization_session_id", sessionId).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("保存匿名化会话失败: %w", err)
+	}
+
+	global.GVA_LOG.Info("匿名化会话已持久化",
+		zap.String("sessionId", sessionId),
+		zap.String("conversationId", conversationId),
+		zap.Int64("logId", logId),
+		zap.Int("mappingCount", len(session.ForwardMap)))
+	return sessionId, nil
+}
+
+// RedecodeExecutionLog 使用持久化的映射重新解码执行日志中的匿名化输出
+// 日志所属用户可以解码自己的日志，其他用户需要具备配置的审计角色
+func (s *SugarAnonymizationSessionsService) RedecodeExecutionLog(ctx context.Context, logId int64, userId string, authorityId uint) (*sugarRes.SugarAnonymizationRedecodeResponse, error) {
+	var log sugar.SugarExecutionLogs
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", logId).First(&log).Error; err != nil {
+		return nil, errors.New("执行日志不存在")
+	}
+	isOwner := log.UserId != nil && *log.UserId == userId
+	if !isOwner && !isAnonymizationReviewer(authorityId) {
+		return nil, errors.New("无权解码其他用户的执行日志")
+	}
+	if log.AnonymizationSessionId == nil || *log.AnonymizationSessionId == "" {
+		return nil, errors.New("该执行日志未关联匿名化会话")
+	}
+	if log.AnonymizedOutput == nil || *log.AnonymizedOutput == "" {
+		return nil, errors.New("该执行日志没有匿名化输出")
+	}
+
+	var record sugar.SugarAnonymizationSessions
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", *log.AnonymizationSessionId).First(&record).Error; err != nil {
+		return nil, errors.New("匿名化会话不存在")
+	}
+	if record.PurgedAt != nil || record.EncryptedMapping == nil {
+		return nil, errors.New("匿名化映射已超过保留期限并被销毁，无法重新解码")
+	}
+	session, err := s.restoreSession(&record)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := session.DecodeAIResponse(*log.AnonymizedOutput)
+	if err != nil {
+		return nil, fmt.Errorf("重新解码失败: %w", err)
+	}
+
+	global.GVA_LOG.Info("重新解码执行日志匿名化输出",
+		zap.Int64("logId", logId),
+		zap.String("sessionId", *record.Id),
+		zap.String("reviewerId", userId),
+		zap.Uint("reviewerAuthorityId", authorityId),
+		zap.Bool("isOwner", isOwner))
+
+	result := &sugarRes.SugarAnonymizationRedecodeResponse{
+		LogId:            logId,
+		SessionId:        *record.Id,
+		SessionCreatedBy: safeDeref(record.UserId),
+		ConversationId:   safeDeref(record.ConversationId),
+		AnonymizedOutput: *log.AnonymizedOutput,
+		DecodedOutput:    decoded,
+		FinalResult:      safeDeref(log.FinalResult),
+		MappingCount:     record.MappingCount,
+		EpsilonSpent:     record.EpsilonSpent,
+		ExpiresAt:        record.ExpiresAt,
+	}
+	result.MatchesFinalResult = result.FinalResult != "" && strings.Contains(result.FinalResult, decoded)
+	return result, nil
+}
+
+// restoreSession 解密持久化映射并还原为可用于编码和解码的会话
+func (s *SugarAnonymizationSessionsService) restoreSession(record *sugar.SugarAnonymizationSessions) (*anonymization_lite.LiteAnonymizationSession, error) {
+	if record.EncryptedMapping == nil || *record.EncryptedMapping == "" {
+		return nil, errors.New("匿名化映射已销毁")
+	}
+	mapping, err := decryptAnonymizationMapping(*record.EncryptedMapping)
+	if err != nil {
+		return nil, err
+	}
+	session := &anonymization_lite.LiteAnonymizationSession{
+		ForwardMap:         mapping.ForwardMap,
+		ReverseMap:         mapping.ReverseMap,
+		AIReadyData:        make([]map[string]interface{}, 0),
+		DimensionSemantics: mapping.DimensionSemantics,
+		UserID:             safeDeref(record.UserId),
+		MappingCount:       len(mapping.ForwardMap),
+		EpsilonSpent:       record.EpsilonSpent,
+	}
+	if session.ForwardMap == nil {
+		session.ForwardMap = make(map[string]string)
+	}
+	if session.ReverseMap == nil {
+		session.ReverseMap = make(map[string]string)
+	}
+	if session.DimensionSemantics == nil {
+		session.DimensionSemantics = make(map[string]*anonymization_lite.DimensionSemanticInfo)
+	}
+	if record.CreatedAt != nil {
+		session.CreatedAt = *record.CreatedAt
+	}
+	if len(record.Config) > 0 {
+		var config anonymization_lite.LiteConfig
+		if err := json.Unmarshal(record.Config, &config); err == nil {
+			session.Config = &config
+		}
+	}
+	return session, nil
+}
+
+// liteSessionFromLegacy 将旧版本匿名化会话转换为lite会话，以便统一持久化
+func liteSessionFromLegacy(session *AnonymizationSession) *anonymization_lite.LiteAnonymizationSession {
+	if session == nil {
+		return nil
+	}
+	return &anonymization_lite.LiteAnonymizationSession{
+		ForwardMap:         session.forwardMap,
+		ReverseMap:         session.reverseMap,
+		AIReadyData:        session.AIReadyData,
+		DimensionSemantics: make(map[string]*anonymization_lite.DimensionSemanticInfo),
+		CreatedAt:          time.Now(),
+		MappingCount:       len(session.forwardMap),
+	}
+}
+
+// anonymizationRetentionDays 加密映射的保留天数
+func anonymizationRetentionDays() int {
+	if days := global.GVA_CONFIG.Sugar.Anonymization.RetentionDays; days > 0 {
+		return days
+	}
+	return defaultAnonymizationRetentionDays
+}
+
+// isAnonymizationReviewer 判断角色是否可以重新解码他人的执行日志
+func isAnonymizationReviewer(authorityId uint) bool {
+	reviewers := global.GVA_CONFIG.Sugar.Anonymization.ReviewerAuthorityIds
+	if len(reviewers) == 0 {
+		return authorityId == defaultReviewerAuthorityId
+	}
+	for _, id := range reviewers {
+		if id == authorityId {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymizationSecret 匿名化密钥材料：配置的密钥，未配置时为JWT签名密钥
+func anonymizationSecret() (string, error) {
+	secret := global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey
+	if secret == "" {
+		secret = global.GVA_CONFIG.JWT.SigningKey
+	}
+	if secret == "" {
+		return "", errors.New("未配置匿名化映射加密密钥")
+	}
+	return secret, nil
+}
+
+// anonymizationCipher 构造映射加密使用的AES-256-GCM，密钥由匿名化密钥材料经SHA-256派生
+func anonymizationCipher() (cipher.AEAD, error) {
+	secret, err := anonymizationSecret()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte("sugar-anonymization:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptAnonymizationMapping 加密代号映射，结果为 base64(nonce || ciphertext)
+func encryptAnonymizationMapping(mapping anonymizationMapping) (string, error) {
+	plaintext, err := json.Marshal(mapping)
+	if err != nil {
+		return "", fmt.Errorf("序列化匿名化映射失败: %w", err)
+	}
+	gcm, err := anonymizationCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.New("生成加密随机数失败")
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// decryptAnonymizationMapping 解密代号映射
+func decryptAnonymizationMapping(encrypted string) (*anonymizationMapping, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, errors.New("匿名化映射格式错误")
+	}
+	gcm, err := anonymizationCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("匿名化映射格式错误")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("匿名化映射解密失败，加密密钥可能已变更")
+	}
+	var mapping anonymizationMapping
+	if err := json.Unmarshal(plaintext, &mapping); err != nil {
+		return nil, fmt.Errorf("解析匿名化映射失败: %w", err)
+	}
+	return &mapping, nil
+}
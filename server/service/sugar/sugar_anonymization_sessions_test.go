@@ -0,0 +1,171 @@
+package sugar
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+)
+
+// setAnonymizationKey 设置映射加密密钥，测试结束后恢复
+func setAnonymizationKey(t *testing.T, key string) {
+	t.Helper()
+	previous := global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey
+	global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey = key
+	t.Cleanup(func() { global.GVA_CONFIG.Sugar.Anonymization.EncryptionKey = previous })
+}
+
+func testLiteSession() *anonymization_lite.LiteAnonymizationSession {
+	return &anonymization_lite.LiteAnonymizationSession{
+		ForwardMap: map[string]string{"城市": "DIM01", "城市:北京": "DIM01_V01", "城市:上海": "DIM01_V02"},
+		ReverseMap: map[string]string{"DIM01": "城市", "DIM01_V01": "北京", "DIM01_V02": "上海"},
+		Config:     &anonymization_lite.LiteConfig{Epsilon: 0.5},
+	}
+}
+
+func TestAnonymizationMappingEncryption(t *testing.T) {
+	setAnonymizationKey(t, "key-1")
+	mapping := anonymizationMapping{ForwardMap: map[string]string{"城市:北京": "DIM01_V01"}, ReverseMap: map[string]string{"DIM01_V01": "北京"}}
+
+	first, err := encryptAnonymizationMapping(mapping)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	second, _ := encryptAnonymizationMapping(mapping)
+	if first == second {
+		t.Fatal("每次加密应使用不同的随机数")
+	}
+	if raw, _ := base64.StdEncoding.DecodeString(first); strings.Contains(string(raw), "北京") {
+		t.Fatal("密文中不应出现原始维度值")
+	}
+	decrypted, err := decryptAnonymizationMapping(first)
+	if err != nil || !reflect.DeepEqual(*decrypted, mapping) {
+		t.Fatalf("解密结果不一致: %+v %v", decrypted, err)
+	}
+
+	// 密文被篡改或格式错误
+	tampered := []byte(first)
+	tampered[len(tampered)-3] ^= 1
+	if _, err := decryptAnonymizationMapping(string(tampered)); err == nil {
+		t.Fatal("篡改的密文不应解密成功")
+	}
+	if _, err := decryptAnonymizationMapping("不是base64"); err == nil {
+		t.Fatal("格式错误的密文不应解密成功")
+	}
+
+	// 更换密钥后无法解密
+	setAnonymizationKey(t, "key-2")
+	if _, err := decryptAnonymizationMapping(first); err == nil || !strings.Contains(err.Error(), "密钥可能已变更") {
+		t.Fatalf("更换密钥后应解密失败: %v", err)
+	}
+
+	// 未配置密钥时使用JWT签名密钥，两者都为空时拒绝加密
+	setAnonymizationKey(t, "")
+	signingKey := global.GVA_CONFIG.JWT.SigningKey
+	defer func() { global.GVA_CONFIG.JWT.SigningKey = signingKey }()
+	global.GVA_CONFIG.JWT.SigningKey = ""
+	if _, err := encryptAnonymizationMapping(mapping); err == nil {
+		t.Fatal("未配置任何密钥时不应加密")
+	}
+}
+
+func TestAnonymizationConversationReuse(t *testing.T) {
+	setupTestDB(t)
+	setAnonymizationKey(t, "session-key")
+	ctx := context.Background()
+	service := &SugarAnonymizationSessionsService{}
+
+	sessionId, err := service.SaveSession(ctx, "", "conv-1", "1", nil, testLiteSession(), 0)
+	if err != nil {
+		t.Fatalf("保存会话失败: %v", err)
+	}
+
+	// 同一用户在同一对话中取回相同的映射
+	restored, restoredId, err := service.LoadConversationSession(ctx, "conv-1", "1")
+	if err != nil || restoredId != sessionId || !reflect.DeepEqual(restored.ReverseMap, testLiteSession().ReverseMap) || restored.Config.Epsilon != 0.5 {
+		t.Fatalf("应复用对话的会话: %+v %s %v", restored, restoredId, err)
+	}
+	// 其他用户或其他对话取不到
+	for _, lookup := range [][2]string{{"conv-1", "2"}, {"conv-2", "1"}, {"", "1"}} {
+		if restored, _, err = service.LoadConversationSession(ctx, lookup[0], lookup[1]); err != nil || restored != nil {
+			t.Fatalf("对话 %s 的用户 %s 不应取到会话: %+v %v", lookup[0], lookup[1], restored, err)
+		}
+	}
+
+	// 后续轮次更新同一会话；其他用户不能更新
+	next := testLiteSession()
+	next.ForwardMap["城市:深圳"], next.ReverseMap["DIM01_V03"] = "DIM01_V03", "深圳"
+	if _, err = service.SaveSession(ctx, sessionId, "conv-1", "2", nil, next, 0); err == nil {
+		t.Fatal("其他用户不应更新会话")
+	}
+	if _, err = service.SaveSession(ctx, sessionId, "conv-1", "1", nil, next, 0); err != nil {
+		t.Fatalf("更新会话失败: %v", err)
+	}
+	var record sugar.SugarAnonymizationSessions
+	global.GVA_DB.Where("id = ?", sessionId).First(&record)
+	if record.TurnCount != 2 || record.MappingCount != 4 {
+		t.Fatalf("会话轮数或映射数不符合预期: %+v", record)
+	}
+
+	// 过期或已销毁的会话不再复用
+	global.GVA_DB.Model(&sugar.SugarAnonymizationSessions{}).Where("id = ?", sessionId).Update("expires_at", time.Now().Add(-time.Minute))
+	if restored, _, _ = service.LoadConversationSession(ctx, "conv-1", "1"); restored != nil {
+		t.Fatal("过期的会话不应复用")
+	}
+	global.GVA_DB.Model(&sugar.SugarAnonymizationSessions{}).Where("id = ?", sessionId).
+		Updates(map[string]interface{}{"expires_at": time.Now().Add(time.Hour), "purged_at": time.Now(), "encrypted_mapping": nil})
+	if restored, _, _ = service.LoadConversationSession(ctx, "conv-1", "1"); restored != nil {
+		t.Fatal("已销毁的会话不应复用")
+	}
+}
+
+func TestRedecodeExecutionLog(t *testing.T) {
+	setupTestDB(t)
+	setAnonymizationKey(t, "session-key")
+	ctx := context.Background()
+	service := &SugarAnonymizationSessionsService{}
+	seedTestData(t, `INSERT INTO sugar_execution_logs (id, log_type, user_id, status, anonymized_output, final_result) VALUES
+		(1, 'ai_agent', '1', 'success', 'dim01_v1 增长最多，DIM01_V02 次之', '北京 增长最多，上海 次之'),
+		(2, 'ai_agent', '1', 'success', NULL, NULL)`)
+	sessionId, err := service.SaveSession(ctx, "", "", "1", nil, testLiteSession(), 1)
+	if err != nil {
+		t.Fatalf("保存会话失败: %v", err)
+	}
+
+	// 日志所属用户可以解码，变形的代号同样还原
+	result, err := service.RedecodeExecutionLog(ctx, 1, "1", 0)
+	if err != nil || result.SessionId != sessionId || result.DecodedOutput != "北京 增长最多，上海 次之" || !result.MatchesFinalResult {
+		t.Fatalf("重新解码结果不符合预期: %+v %v", result, err)
+	}
+
+	// 其他用户需要审计角色：默认只有超级管理员，配置后以配置为准
+	if _, err = service.RedecodeExecutionLog(ctx, 1, "2", 100); err == nil {
+		t.Fatal("普通用户不应解码他人的日志")
+	}
+	if _, err = service.RedecodeExecutionLog(ctx, 1, "2", defaultReviewerAuthorityId); err != nil {
+		t.Fatalf("超级管理员应能解码: %v", err)
+	}
+	global.GVA_CONFIG.Sugar.Anonymization.ReviewerAuthorityIds = []uint{9528}
+	defer func() { global.GVA_CONFIG.Sugar.Anonymization.ReviewerAuthorityIds = nil }()
+	if _, err = service.RedecodeExecutionLog(ctx, 1, "2", defaultReviewerAuthorityId); err == nil {
+		t.Fatal("配置审计角色后超级管理员不再默认具备权限")
+	}
+	if _, err = service.RedecodeExecutionLog(ctx, 1, "2", 9528); err != nil {
+		t.Fatalf("配置的审计角色应能解码: %v", err)
+	}
+
+	// 未关联会话的日志、映射已销毁的会话
+	if _, err = service.RedecodeExecutionLog(ctx, 2, "1", 0); err == nil {
+		t.Fatal("未关联会话的日志不能解码")
+	}
+	global.GVA_DB.Model(&sugar.SugarAnonymizationSessions{}).Where("id = ?", sessionId).Updates(map[string]interface{}{"purged_at": time.Now(), "encrypted_mapping": nil})
+	if _, err = service.RedecodeExecutionLog(ctx, 1, "1", 0); err == nil || !strings.Contains(err.Error(), "销毁") {
+		t.Fatalf("映射销毁后不能解码: %v", err)
+	}
+}
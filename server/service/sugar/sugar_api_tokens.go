@@ -0,0 +1,107 @@
+package sugar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/google/uuid"
+)
+
+// ApiTokenPrefix Sugar API令牌的固定前缀，用于和JWT区分
+const ApiTokenPrefix = "sgr_"
+
+type SugarApiTokensService struct{}
+
+// CreateApiToken 为用户创建API令牌，明文令牌只返回这一次
+func (s *SugarApiTokensService) CreateApiToken(ctx context.Context, req *sugarReq.SugarApiTokensCreateRequest, userId string) (*sugarRes.SugarApiTokensCreateResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("令牌名称不能为空")
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, errors.New("有效天数不能为负数")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.New("生成令牌失败")
+	}
+	token := ApiTokenPrefix + hex.EncodeToString(secret)
+
+	id := uuid.New().String()
+	now := time.Now()
+	record := sugar.SugarApiTokens{
+		Id:          &id,
+		UserId:      &userId,
+		Name:        &name,
+		TokenHash:   hashApiToken(token),
+		TokenPrefix: token[:len(ApiTokenPrefix)+8],
+		CreatedAt:   &now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		record.ExpiresAt = &expiresAt
+	}
+	if err := global.GVA_DB.WithContext(ctx).Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &sugarRes.SugarApiTokensCreateResponse{Token: token, ApiToken: record}, nil
+}
+
+// GetApiTokenList 获取用户的API令牌列表
+func (s *SugarApiTokensService) GetApiTokenList(ctx context.Context, userId string) (list []sugar.SugarApiTokens, err error) {
+	err = global.GVA_DB.WithContext(ctx).
+		Where("user_id = ? AND deleted_at IS NULL", userId).
+		Order("created_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// RevokeApiToken 吊销API令牌，只允许令牌所属用户操作
+func (s *SugarApiTokensService) RevokeApiToken(ctx context.Context, id string, userId string) error {
+	result := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarApiTokens{}).
+		Where("id = ? AND user_id = ? AND deleted_at IS NULL", id, userId).
+		Update("deleted_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌不存在或无权操作")
+	}
+	return nil
+}
+
+// VerifyApiToken 校验API令牌，返回令牌记录并更新最近使用时间
+func (s *SugarApiTokensService) VerifyApiToken(ctx context.Context, token string) (*sugar.SugarApiTokens, error) {
+	if !strings.HasPrefix(token, ApiTokenPrefix) {
+		return nil, errors.New("无效的API令牌")
+	}
+	var record sugar.SugarApiTokens
+	err := global.GVA_DB.WithContext(ctx).
+		Where("token_hash = ? AND deleted_at IS NULL", hashApiToken(token)).
+		First(&record).Error
+	if err != nil {
+		return nil, errors.New("无效的API令牌")
+	}
+	now := time.Now()
+	if record.ExpiresAt != nil && record.ExpiresAt.Before(now) {
+		return nil, errors.New("API令牌已过期")
+	}
+	global.GVA_DB.WithContext(ctx).Model(&sugar.SugarApiTokens{}).Where("id = ?", *record.Id).Update("last_used_at", now)
+	return &record, nil
+}
+
+// hashApiToken 计算令牌的SHA-256摘要
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+)
+
+// 团队角色，权限由高到低
+const (
+	SugarTeamRoleOwner  = "owner"
+	SugarTeamRoleAdmin  = "admin"
+	SugarTeamRoleEditor = "editor"
+	SugarTeamRoleViewer = "viewer"
+)
+
+// SugarResource 受团队角色控制的资源类型
+type SugarResource string
+
+const (
+	SugarResourceWorkspace     SugarResource = "workspace"     // 工作区文件和文件夹
+	SugarResourceSemanticModel SugarResource = "semanticModel" // 语义模型
+	SugarResourceAgent         SugarResource = "agent"         // 智能体
+	SugarResourceDbConnection  SugarResource = "dbConnection"  // 数据库连接
+	SugarResourceTeam          SugarResource = "team"          // 团队信息和成员
+)
+
+// SugarAction 对资源的操作
+type SugarAction string
+
+const (
+	SugarActionRead   SugarAction = "read"   // 查看和使用
+	SugarActionEdit   SugarAction = "edit"   // 创建和修改内容
+	SugarActionManage SugarAction = "manage" // 修改归属、配置等管理操作
+	SugarActionShare  SugarAction = "share"  // 分享给其他用户或团队
+	SugarActionDelete SugarAction = "delete" // 删除
+)
+
+// SugarActions 全部操作，按权限由低到高排列
+var SugarActions = []SugarAction{SugarActionRead, SugarActionEdit, SugarActionShare, SugarActionDelete, SugarActionManage}
+
+// sugarRoleRank 角色等级，高等级角色拥有低等级角色的全部权限
+var sugarRoleRank = map[string]int{
+	SugarTeamRoleViewer: 1,
+	SugarTeamRoleEditor: 2,
+	SugarTeamRoleAdmin:  3,
+	SugarTeamRoleOwner:  4,
+}
+
+// sugarPermissionMatrix 各资源上每种操作要求的最低团队角色
+// 工作区文件由编辑者日常维护；语义模型和数据库连接决定团队能访问哪些数据，只允许管理员修改；
+// 智能体可由编辑者调整，删除需要管理员；团队信息和成员由管理员维护，解散团队只允许所有者
+var sugarPermissionMatrix = map[SugarResource]map[SugarAction]string{
+	SugarResourceWorkspace: {
+		SugarActionRead:   SugarTeamRoleViewer,
+		SugarActionEdit:   SugarTeamRoleEditor,
+		SugarActionShare:  SugarTeamRoleEditor,
+		SugarActionDelete: SugarTeamRoleEditor,
+		SugarActionManage: SugarTeamRoleAdmin,
+	},
+	SugarResourceSemanticModel: {
+		SugarActionRead:   SugarTeamRoleViewer,
+		SugarActionEdit:   SugarTeamRoleAdmin,
+		SugarActionShare:  SugarTeamRoleAdmin,
+		SugarActionDelete: SugarTeamRoleAdmin,
+		SugarActionManage: SugarTeamRoleAdmin,
+	},
+	SugarResourceAgent: {
+		SugarActionRead:   SugarTeamRoleViewer,
+		SugarActionEdit:   SugarTeamRoleEditor,
+		SugarActionShare:  SugarTeamRoleEditor,
+		SugarActionDelete: SugarTeamRoleAdmin,
+		SugarActionManage: SugarTeamRoleAdmin,
+	},
+	SugarResourceDbConnection: {
+		SugarActionRead:   SugarTeamRoleEditor,
+		SugarActionEdit:   SugarTeamRoleAdmin,
+		SugarActionShare:  SugarTeamRoleOwner,
+		SugarActionDelete: SugarTeamRoleAdmin,
+		SugarActionManage: SugarTeamRoleOwner,
+	},
+	SugarResourceTeam: {
+		SugarActionRead:   SugarTeamRoleViewer,
+		SugarActionEdit:   SugarTeamRoleAdmin,
+		SugarActionShare:  SugarTeamRoleAdmin,
+		SugarActionDelete: SugarTeamRoleOwner,
+		SugarActionManage: SugarTeamRoleAdmin,
+	},
+}
+
+var (
+	sugarRoleLabels     = map[string]string{SugarTeamRoleOwner: "所有者", SugarTeamRoleAdmin: "管理员", SugarTeamRoleEditor: "编辑者", SugarTeamRoleViewer: "查看者"}
+	sugarResourceLabels = map[SugarResource]string{SugarResourceWorkspace: "文件", SugarResourceSemanticModel: "语义模型", SugarResourceAgent: "智能体", SugarResourceDbConnection: "数据库连接", SugarResourceTeam: "团队"}
+	sugarActionLabels   = map[SugarAction]string{SugarActionRead: "查看", SugarActionEdit: "编辑", SugarActionManage: "管理", SugarActionShare: "分享", SugarActionDelete: "删除"}
+)
+
+// SugarPermissionError 用户不是团队成员或其团队角色不允许执行该操作
+type SugarPermissionError struct {
+	Resource   SugarResource
+	Action     SugarAction
+	Role       string // 用户在团队中的角色，非团队成员为空
+	GrantLevel string // 通过文件授权获得的权限级别，没有授权为空
+}
+
+func (e *SugarPermissionError) Error() string {
+	if e.Role == "" && e.GrantLevel != "" {
+		return fmt.Sprintf("只有%s权限，无法%s%s", sugarGrantLevelLabels[e.GrantLevel], sugarActionLabels[e.Action], sugarResourceLabels[e.Resource])
+	}
+	if e.Role == "" {
+		return fmt.Sprintf("不是该团队成员，无权限%s%s", sugarActionLabels[e.Action], sugarResourceLabels[e.Resource])
+	}
+	return fmt.Sprintf("团队%s无权限%s%s", sugarRoleLabels[e.Role], sugarActionLabels[e.Action], sugarResourceLabels[e.Resource])
+}
+
+// SugarRoleAllows 判断团队角色能否对资源执行操作，未知的角色、资源或操作一律拒绝
+func SugarRoleAllows(role string, resource SugarResource, action SugarAction) bool {
+	rank, ok := sugarRoleRank[role]
+	if !ok {
+		return false
+	}
+	required, ok := sugarPermissionMatrix[resource][action]
+	if !ok {
+		return false
+	}
+	return rank >= sugarRoleRank[required]
+}
+
+// sugarRolesAllowing 返回能对资源执行操作的全部角色
+func sugarRolesAllowing(resource SugarResource, action SugarAction) []string {
+	roles := make([]string, 0, len(sugarRoleRank))
+	for _, role := range []string{SugarTeamRoleOwner, SugarTeamRoleAdmin, SugarTeamRoleEditor, SugarTeamRoleViewer} {
+		if SugarRoleAllows(role, resource, action) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// getTeamRole 获取用户在团队中的角色，不是团队成员时返回空字符串
+func getTeamRole(ctx context.Context, teamId, userId string) (string, error) {
+	var members []sugar.SugarTeamMembers
+	err := global.GVA_DB.WithContext(ctx).Where("team_id = ? AND user_id = ?", teamId, userId).Find(&members).Error
+	if err != nil {
+		return "", err
+	}
+	// 同一用户重复加入团队时取最高的角色
+	role := ""
+	for _, member := range members {
+		if sugarRoleRank[member.Role] > sugarRoleRank[role] {
+			role = member.Role
+		}
+	}
+	return role, nil
+}
+
+// authorizeTeamAction 校验用户在团队中的角色能否对资源执行操作，不能时返回 SugarPermissionError
+func authorizeTeamAction(ctx context.Context, teamId, userId string, resource SugarResource, action SugarAction) error {
+	role, err := getTeamRole(ctx, teamId, userId)
+	if err != nil {
+		global.GVA_LOG.Error("查询团队角色失败", zap.String("teamId", teamId), zap.String("userId", userId), zap.Error(err))
+		return errors.New("校验权限失败")
+	}
+	if !SugarRoleAllows(role, resource, action) {
+		return &SugarPermissionError{Resource: resource, Action: action, Role: role}
+	}
+	return nil
+}
+
+// authorizeTeamMemberRoles 校验用户能否管理团队成员：需要团队的 manage 权限，
+// 且 roles（被修改成员的原角色和新角色）都不能高于用户自己的角色
+func authorizeTeamMemberRoles(ctx context.Context, teamId, userId string, roles ...string) error {
+	role, err := getTeamRole(ctx, teamId, userId)
+	if err != nil {
+		global.GVA_LOG.Error("查询团队角色失败", zap.String("teamId", teamId), zap.String("userId", userId), zap.Error(err))
+		return errors.New("校验权限失败")
+	}
+	if !SugarRoleAllows(role, SugarResourceTeam, SugarActionManage) {
+		return &SugarPermissionError{Resource: SugarResourceTeam, Action: SugarActionManage, Role: role}
+	}
+	for _, target := range roles {
+		if _, ok := sugarRoleRank[target]; !ok {
+			return errors.New("未知的团队角色: " + target)
+		}
+		if sugarRoleRank[target] > sugarRoleRank[role] {
+			return fmt.Errorf("团队%s不能授予或修改%s角色", sugarRoleLabels[role], sugarRoleLabels[target])
+		}
+	}
+	return nil
+}
+
+// authorizeTeamResource 校验资源所属团队，资源未归属任何团队时拒绝
+func authorizeTeamResource(ctx context.Context, teamId *string, userId string, resource SugarResource, action SugarAction) error {
+	if teamId == nil || *teamId == "" {
+		return &SugarPermissionError{Resource: resource, Action: action}
+	}
+	return authorizeTeamAction(ctx, *teamId, userId, resource, action)
+}
+
+// authorizedTeamIds 获取用户能对资源执行操作的全部团队
+func authorizedTeamIds(ctx context.Context, userId string, resource SugarResource, action SugarAction) ([]string, error) {
+	teamIds := make([]string, 0)
+	err := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarTeamMembers{}).
+		Where("user_id = ? AND role IN ?", userId, sugarRolesAllowing(resource, action)).
+		Distinct().Pluck("team_id", &teamIds).Error
+	return teamIds, err
+}
+
+type SugarAuthorizationService struct{}
+
+// Authorize 校验用户能否对团队中的资源执行操作
+func (s *SugarAuthorizationService) Authorize(ctx context.Context, teamId, userId string, resource SugarResource, action SugarAction) error {
+	return authorizeTeamAction(ctx, teamId, userId, resource, action)
+}
+
+// GetTeamPermissions 获取用户在团队中的角色及其对各类资源允许的操作，供前端控制按钮的可用状态
+func (s *SugarAuthorizationService) GetTeamPermissions(ctx context.Context, teamId, userId string) (*sugarRes.SugarTeamPermissionsResponse, error) {
+	role, err := getTeamRole(ctx, teamId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, errors.New("不是该团队成员")
+	}
+	result := &sugarRes.SugarTeamPermissionsResponse{Role: role, Permissions: make(map[string][]string, len(sugarPermissionMatrix))}
+	for resource := range sugarPermissionMatrix {
+		actions := make([]string, 0, len(SugarActions))
+		for _, action := range SugarActions {
+			if SugarRoleAllows(role, resource, action) {
+				actions = append(actions, string(action))
+			}
+		}
+		result.Permissions[string(resource)] = actions
+	}
+	return result, nil
+}
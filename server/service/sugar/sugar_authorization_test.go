@@ -0,0 +1,288 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+func TestSugarPermissionMatrix(t *testing.T) {
+	// 每行依次为 read/edit/share/delete/manage 是否允许
+	type row [5]bool
+	expected := map[SugarResource]map[string]row{
+		SugarResourceWorkspace: {
+			SugarTeamRoleOwner:  {true, true, true, true, true},
+			SugarTeamRoleAdmin:  {true, true, true, true, true},
+			SugarTeamRoleEditor: {true, true, true, true, false},
+			SugarTeamRoleViewer: {true, false, false, false, false},
+			"":                  {false, false, false, false, false},
+		},
+		SugarResourceSemanticModel: {
+			SugarTeamRoleOwner:  {true, true, true, true, true},
+			SugarTeamRoleAdmin:  {true, true, true, true, true},
+			SugarTeamRoleEditor: {true, false, false, false, false},
+			SugarTeamRoleViewer: {true, false, false, false, false},
+			"":                  {false, false, false, false, false},
+		},
+		SugarResourceAgent: {
+			SugarTeamRoleOwner:  {true, true, true, true, true},
+			SugarTeamRoleAdmin:  {true, true, true, true, true},
+			SugarTeamRoleEditor: {true, true, true, false, false},
+			SugarTeamRoleViewer: {true, false, false, false, false},
+			"":                  {false, false, false, false, false},
+		},
+		SugarResourceDbConnection: {
+			SugarTeamRoleOwner:  {true, true, true, true, true},
+			SugarTeamRoleAdmin:  {true, true, false, true, false},
+			SugarTeamRoleEditor: {true, false, false, false, false},
+			SugarTeamRoleViewer: {false, false, false, false, false},
+			"":                  {false, false, false, false, false},
+		},
+		SugarResourceTeam: {
+			SugarTeamRoleOwner:  {true, true, true, true, true},
+			SugarTeamRoleAdmin:  {true, true, true, false, true},
+			SugarTeamRoleEditor: {true, false, false, false, false},
+			SugarTeamRoleViewer: {true, false, false, false, false},
+			"":                  {false, false, false, false, false},
+		},
+	}
+	actions := []SugarAction{SugarActionRead, SugarActionEdit, SugarActionShare, SugarActionDelete, SugarActionManage}
+	for resource, roles := range expected {
+		for role, allowed := range roles {
+			for i, action := range actions {
+				if got := SugarRoleAllows(role, resource, action); got != allowed[i] {
+					t.Errorf("角色 %q 对 %s 执行 %s: 期望 %v，实际 %v", role, resource, action, allowed[i], got)
+				}
+			}
+		}
+	}
+	if SugarRoleAllows(SugarTeamRoleOwner, "unknown", SugarActionRead) || SugarRoleAllows("guest", SugarResourceWorkspace, SugarActionRead) {
+		t.Error("未知的资源或角色应一律拒绝")
+	}
+}
+
+func expectPermissionDenied(t *testing.T, err error, role string) {
+	t.Helper()
+	var permissionErr *SugarPermissionError
+	if !errors.As(err, &permissionErr) {
+		t.Fatalf("期望权限错误，实际: %v", err)
+	}
+	if permissionErr.Role != role {
+		t.Fatalf("权限错误中的角色应为 %q，实际 %q", role, permissionErr.Role)
+	}
+}
+
+func TestWorkspaceRoleEnforcement(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	workspaces := &SugarWorkspacesService{}
+	folders := &SugarFoldersService{}
+
+	// 查看者可以打开文件，但不能保存、创建、重命名、移动或删除
+	current, err := workspaces.GetWorkbookContent(ctx, testFileId, "12")
+	if err != nil {
+		t.Fatalf("查看者应能打开文件: %v", err)
+	}
+	_, err = workspaces.SaveWorkbookContent(ctx, testFileId, []byte(workbookJSON(`"0": {"0": {"v": "改"}}`)), nil, current.Revision, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = workspaces.CreateWorkbookFile(ctx, "新文件", nil, "team-1", "12", nil)
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = folders.CreateFolder(ctx, &sugarReq.SugarFoldersCreateFolderRequest{Name: "新文件夹", TeamId: "team-1", Type: "folder"}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = folders.RenameItem(ctx, &sugarReq.SugarFoldersRenameRequest{Id: testFileId, Name: "改名"}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = folders.MoveItem(ctx, &sugarReq.SugarFoldersMoveRequest{Id: testFileId, TeamId: "team-2"}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = folders.DeleteItem(ctx, &sugarReq.SugarFoldersDeleteRequest{Id: testFileId}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, _, err = (&SugarFileVersionsService{}).GetFileVersionList(ctx, sugarReq.SugarFileVersionsSearch{FileId: testFileId}, "12")
+	if err != nil {
+		t.Fatalf("查看者应能查看历史版本: %v", err)
+	}
+
+	// 非团队成员不能查看
+	_, err = workspaces.GetWorkbookContent(ctx, testFileId, "3")
+	expectPermissionDenied(t, err, "")
+
+	// 编辑者可以保存和重命名；编辑者不能把文件移到自己只是查看者的团队
+	saveWorkbook(t, "1", `"0": {"0": {"v": "收入"}, "1": {"v": 200}}`)
+	if _, err = folders.RenameItem(ctx, &sugarReq.SugarFoldersRenameRequest{Id: testFileId, Name: "预算2"}, "1"); err != nil {
+		t.Fatalf("编辑者应能重命名: %v", err)
+	}
+	_, err = folders.MoveItem(ctx, &sugarReq.SugarFoldersMoveRequest{Id: testFileId, TeamId: "team-2"}, "1")
+	expectPermissionDenied(t, err, "")
+
+	// 用户可删除文件的团队只包含其角色允许删除的团队
+	if teamIds, err := authorizedTeamIds(ctx, "12", SugarResourceWorkspace, SugarActionDelete); err != nil || len(teamIds) != 1 || teamIds[0] != "team-2" {
+		t.Fatalf("查看者只能在自己是编辑者的团队删除文件: %v %v", teamIds, err)
+	}
+
+	// 管理员可以删除
+	if _, err = folders.DeleteItem(ctx, &sugarReq.SugarFoldersDeleteRequest{Id: testFileId}, "11"); err != nil {
+		t.Fatalf("管理员应能删除文件: %v", err)
+	}
+
+	permissions, err := (&SugarAuthorizationService{}).GetTeamPermissions(ctx, "team-1", "12")
+	if err != nil {
+		t.Fatalf("获取团队权限失败: %v", err)
+	}
+	if permissions.Role != SugarTeamRoleViewer || len(permissions.Permissions[string(SugarResourceWorkspace)]) != 1 {
+		t.Fatalf("查看者的团队权限不符合预期: %+v", permissions)
+	}
+}
+
+func TestWorkbookCollaborationReadOnly(t *testing.T) {
+	setupTeamWorkbook(t)
+	service := &SugarWorkbookCollaborationService{}
+	if err := service.Authorize(context.Background(), testFileId, "12"); err != nil {
+		t.Fatalf("查看者应能加入协作: %v", err)
+	}
+
+	viewer, welcome := connectCollab(t, "12")
+	if !welcome.ReadOnly {
+		t.Fatalf("查看者的协作连接应为只读: %+v", welcome)
+	}
+	editor, welcome := connectCollab(t, "1")
+	if welcome.ReadOnly {
+		t.Fatalf("编辑者的协作连接不应为只读: %+v", welcome)
+	}
+
+	// 查看者提交的命令被拒绝，不写入操作日志
+	sendCollab(t, viewer, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 1, Command: collabCommand(1)})
+	if message := nextCollabMessage(t, viewer); message.Type != sugarRes.WorkbookCollabTypeError {
+		t.Fatalf("查看者提交命令应收到错误: %+v", message)
+	}
+
+	// 编辑者的修改广播给查看者
+	sendCollab(t, editor, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 1, Command: collabCommand(2)})
+	if ack := expectCollab(t, editor, sugarRes.WorkbookCollabTypeAck); ack.Revision != 1 {
+		t.Fatalf("编辑者的命令应从修订号 1 开始: %+v", ack)
+	}
+	if op := expectCollab(t, viewer, sugarRes.WorkbookCollabTypeOperation); op.Revision != 1 {
+		t.Fatalf("查看者应收到编辑者的修改: %+v", op)
+	}
+
+	_ = viewer.Close()
+	_ = editor.Close()
+	waitCollabRoomClosed(t)
+}
+
+// nextCollabMessage 读取服务器发来的下一条非 presence 消息
+func nextCollabMessage(t *testing.T, conn *fakeCollabConn) sugarRes.WorkbookCollabMessage {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-conn.outgoing:
+			var message sugarRes.WorkbookCollabMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("解析消息失败: %v", err)
+			}
+			if message.Type != sugarRes.WorkbookCollabTypePresence {
+				return message
+			}
+		case <-timeout:
+			t.Fatal("等待消息超时")
+		}
+	}
+}
+
+func TestTeamManagementRoleEnforcement(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	members := &SugarTeamMembersService{}
+	teams := &SugarTeamsService{}
+	teamId, userId := "team-1", "3"
+	member := func(role string) *sugar.SugarTeamMembers {
+		return &sugar.SugarTeamMembers{TeamId: &teamId, UserId: &userId, Role: role}
+	}
+	memberId := func(teamId, userId string) string {
+		var record sugar.SugarTeamMembers
+		global.GVA_DB.Where("team_id = ? AND user_id = ?", teamId, userId).First(&record)
+		return strconv.Itoa(*record.Id)
+	}
+	updateRole := func(id, role, operator string) error {
+		memberId, _ := strconv.Atoi(id)
+		return members.UpdateSugarTeamMembers(ctx, sugar.SugarTeamMembers{Id: &memberId, Role: role}, operator)
+	}
+
+	// 编辑者和其他团队的成员不能管理团队成员
+	expectPermissionDenied(t, members.CreateSugarTeamMembers(ctx, member(SugarTeamRoleViewer), "1"), SugarTeamRoleEditor)
+	expectPermissionDenied(t, members.CreateSugarTeamMembers(ctx, member(SugarTeamRoleViewer), "3"), "")
+
+	// 管理员可以添加不高于自己的角色，不能添加所有者
+	if err := members.CreateSugarTeamMembers(ctx, member(SugarTeamRoleOwner), "11"); err == nil {
+		t.Fatal("管理员不应能添加所有者")
+	}
+	if err := members.CreateSugarTeamMembers(ctx, member("guest"), "11"); err == nil {
+		t.Fatal("未知的角色应被拒绝")
+	}
+	if err := members.CreateSugarTeamMembers(ctx, member(SugarTeamRoleEditor), "11"); err != nil {
+		t.Fatalf("管理员应能添加编辑者: %v", err)
+	}
+	carol := memberId("team-1", "3")
+
+	// 修改角色：新旧角色都不能高于操作者，不能修改成员所属的团队
+	expectPermissionDenied(t, updateRole(carol, SugarTeamRoleAdmin, "1"), SugarTeamRoleEditor)
+	if err := updateRole(carol, SugarTeamRoleOwner, "11"); err == nil {
+		t.Fatal("管理员不应能把成员提升为所有者")
+	}
+	if err := updateRole(memberId("team-1", "10"), SugarTeamRoleViewer, "11"); err == nil {
+		t.Fatal("管理员不应能降级所有者")
+	}
+	if err := updateRole(carol, SugarTeamRoleAdmin, "11"); err != nil {
+		t.Fatalf("管理员应能把成员提升为管理员: %v", err)
+	}
+	otherTeam := "team-2"
+	carolId, _ := strconv.Atoi(carol)
+	if err := members.UpdateSugarTeamMembers(ctx, sugar.SugarTeamMembers{Id: &carolId, TeamId: &otherTeam, Role: SugarTeamRoleViewer}, "11"); err == nil {
+		t.Fatal("不应能修改成员所属的团队")
+	}
+	if err := updateRole(carol, SugarTeamRoleOwner, "10"); err != nil {
+		t.Fatalf("所有者应能把成员提升为所有者: %v", err)
+	}
+	if role, _ := getTeamRole(ctx, "team-1", "3"); role != SugarTeamRoleOwner {
+		t.Fatalf("角色应已更新为所有者，实际 %q", role)
+	}
+
+	// 移除成员：不能移除角色高于自己的成员；批量删除中任一条不允许时都不删除
+	expectPermissionDenied(t, members.DeleteSugarTeamMembers(ctx, memberId("team-1", "2"), "12"), SugarTeamRoleViewer)
+	if err := members.DeleteSugarTeamMembersByIds(ctx, []string{memberId("team-1", "2"), carol}, "11"); err == nil {
+		t.Fatal("管理员不应能移除所有者")
+	}
+	if role, _ := getTeamRole(ctx, "team-1", "2"); role != SugarTeamRoleEditor {
+		t.Fatal("批量删除被拒绝时不应删除任何成员")
+	}
+	if err := members.DeleteSugarTeamMembers(ctx, memberId("team-1", "2"), "11"); err != nil {
+		t.Fatalf("管理员应能移除编辑者: %v", err)
+	}
+
+	// 修改团队信息需要管理员，解散团队需要所有者
+	name, editor, admin := "新名称", "1", "11"
+	expectPermissionDenied(t, teams.UpdateSugarTeams(ctx, sugar.SugarTeams{Id: &teamId, TeamName: &name, UpdatedBy: &editor}), SugarTeamRoleEditor)
+	if err := teams.UpdateSugarTeams(ctx, sugar.SugarTeams{Id: &teamId, TeamName: &name, UpdatedBy: &admin}); err != nil {
+		t.Fatalf("管理员应能修改团队信息: %v", err)
+	}
+	expectPermissionDenied(t, teams.DeleteSugarTeams(ctx, teamId, "11"), SugarTeamRoleAdmin)
+	if err := teams.DeleteSugarTeams(ctx, teamId, "10"); err != nil {
+		t.Fatalf("所有者应能解散团队: %v", err)
+	}
+
+	// 创建团队时创建者成为所有者，可以继续管理成员
+	owner := "3"
+	created := &sugar.SugarTeams{TeamName: &name, OwnerId: &owner}
+	if err := teams.CreateSugarTeams(ctx, created); err != nil {
+		t.Fatalf("创建团队失败: %v", err)
+	}
+	if role, _ := getTeamRole(ctx, *created.Id, owner); role != SugarTeamRoleOwner {
+		t.Fatalf("创建者应成为团队所有者，实际 %q", role)
+	}
+}
@@ -1,8 +1,8 @@
-
 package sugar
 
 import (
 	"context"
+	"errors"
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
     sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
@@ -11,45 +11,99 @@ import (
 type SugarDbConnectionsService struct {}
 // CreateSugarDbConnections 创建Sugar数据库配置表记录
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService) CreateSugarDbConnections(ctx context.Context, sugarDbConnections *sugar.SugarDbConnections) (err error) {
+func (sugarDbConnectionsService *SugarDbConnectionsService) CreateSugarDbConnections(ctx context.Context, sugarDbConnections *sugar.SugarDbConnections, userId string) (err error) {
+	if err = authorizeTeamResource(ctx, sugarDbConnections.TeamId, userId, SugarResourceDbConnection, SugarActionEdit); err != nil {
+		return err
+	}
 	err = global.GVA_DB.Create(sugarDbConnections).Error
 	return err
 }
 
-// DeleteSugarDbConnections 删除Sugar数据库配置表记录
+// DeleteSugarDbConnections 删除Sugar数据库配置表记录，使用该连接的语义模型仍被工作簿引用时需 force 确认
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService)DeleteSugarDbConnections(ctx context.Context, id string) (err error) {
+func (sugarDbConnectionsService *SugarDbConnectionsService)DeleteSugarDbConnections(ctx context.Context, id string, userId string, force bool) (err error) {
+	var connection sugar.SugarDbConnections
+	if err = global.GVA_DB.Where("id = ?", id).First(&connection).Error; err != nil {
+		return errors.New("记录不存在")
+	}
+	if err = authorizeTeamResource(ctx, connection.TeamId, userId, SugarResourceDbConnection, SugarActionDelete); err != nil {
+		return err
+	}
+	if !force {
+		if err = checkConnectionDeletion(ctx, []sugar.SugarDbConnections{connection}, userId); err != nil {
+			return err
+		}
+	}
 	err = global.GVA_DB.Delete(&sugar.SugarDbConnections{},"id = ?",id).Error
 	return err
 }
 
-// DeleteSugarDbConnectionsByIds 批量删除Sugar数据库配置表记录
+// DeleteSugarDbConnectionsByIds 批量删除Sugar数据库配置表记录，只删除用户有删除权限的团队中的连接，仍有依赖的工作簿时需 force 确认
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService)DeleteSugarDbConnectionsByIds(ctx context.Context, ids []string) (err error) {
-	err = global.GVA_DB.Delete(&[]sugar.SugarDbConnections{},"id in ?",ids).Error
+func (sugarDbConnectionsService *SugarDbConnectionsService)DeleteSugarDbConnectionsByIds(ctx context.Context, ids []string, userId string, force bool) (err error) {
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceDbConnection, SugarActionDelete)
+	if err != nil || len(teamIds) == 0 {
+		return err
+	}
+	if !force {
+		var connections []sugar.SugarDbConnections
+		if err = global.GVA_DB.Where("id IN ? AND team_id IN ?", ids, teamIds).Find(&connections).Error; err != nil {
+			return err
+		}
+		if err = checkConnectionDeletion(ctx, connections, userId); err != nil {
+			return err
+		}
+	}
+	err = global.GVA_DB.Delete(&[]sugar.SugarDbConnections{},"id in ? AND team_id IN ?",ids,teamIds).Error
 	return err
 }
 
 // UpdateSugarDbConnections 更新Sugar数据库配置表记录
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService)UpdateSugarDbConnections(ctx context.Context, sugarDbConnections sugar.SugarDbConnections) (err error) {
+func (sugarDbConnectionsService *SugarDbConnectionsService)UpdateSugarDbConnections(ctx context.Context, sugarDbConnections sugar.SugarDbConnections, userId string) (err error) {
+	var oldConnection sugar.SugarDbConnections
+	if err = global.GVA_DB.Where("id = ?", sugarDbConnections.Id).First(&oldConnection).Error; err != nil {
+		return errors.New("记录不存在")
+	}
+	if err = authorizeTeamResource(ctx, oldConnection.TeamId, userId, SugarResourceDbConnection, SugarActionEdit); err != nil {
+		return err
+	}
+	// 修改所属团队需要原团队和目标团队的管理权限
+	if sugarDbConnections.TeamId != nil && (oldConnection.TeamId == nil || *sugarDbConnections.TeamId != *oldConnection.TeamId) {
+		if err = authorizeTeamResource(ctx, oldConnection.TeamId, userId, SugarResourceDbConnection, SugarActionManage); err != nil {
+			return err
+		}
+		if err = authorizeTeamAction(ctx, *sugarDbConnections.TeamId, userId, SugarResourceDbConnection, SugarActionManage); err != nil {
+			return err
+		}
+	}
 	err = global.GVA_DB.Model(&sugar.SugarDbConnections{}).Where("id = ?",sugarDbConnections.Id).Updates(&sugarDbConnections).Error
 	return err
 }
 
 // GetSugarDbConnections 根据id获取Sugar数据库配置表记录
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService)GetSugarDbConnections(ctx context.Context, id string) (sugarDbConnections sugar.SugarDbConnections, err error) {
-	err = global.GVA_DB.Where("id = ?", id).First(&sugarDbConnections).Error
+func (sugarDbConnectionsService *SugarDbConnectionsService)GetSugarDbConnections(ctx context.Context, id string, userId string) (sugarDbConnections sugar.SugarDbConnections, err error) {
+	if err = global.GVA_DB.Where("id = ?", id).First(&sugarDbConnections).Error; err != nil {
+		return
+	}
+	err = authorizeTeamResource(ctx, sugarDbConnections.TeamId, userId, SugarResourceDbConnection, SugarActionRead)
 	return
 }
-// GetSugarDbConnectionsInfoList 分页获取Sugar数据库配置表记录
+// GetSugarDbConnectionsInfoList 分页获取用户有权查看的团队中的Sugar数据库配置表记录
 // Author [yourname](https://github.com/yourname)
-func (sugarDbConnectionsService *SugarDbConnectionsService)GetSugarDbConnectionsInfoList(ctx context.Context, info sugarReq.SugarDbConnectionsSearch) (list []sugar.SugarDbConnections, total int64, err error) {
+func (sugarDbConnectionsService *SugarDbConnectionsService)GetSugarDbConnectionsInfoList(ctx context.Context, info sugarReq.SugarDbConnectionsSearch, userId string) (list []sugar.SugarDbConnections, total int64, err error) {
 	limit := info.PageSize
 	offset := info.PageSize * (info.Page - 1)
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceDbConnection, SugarActionRead)
+	if err != nil {
+		return
+	}
+	if len(teamIds) == 0 {
+		return []sugar.SugarDbConnections{}, 0, nil
+	}
     // 创建db
-	db := global.GVA_DB.Model(&sugar.SugarDbConnections{})
+	db := global.GVA_DB.Model(&sugar.SugarDbConnections{}).Where("team_id IN ?", teamIds)
     var sugarDbConnectionss []sugar.SugarDbConnections
     // 如果有条件搜索 下方会自动创建搜索语句
     
@@ -24,6 +24,7 @@ type ExecutionLogContext struct {
 	AnonymizationEnabled bool
 	AnonymizedInput      datatypes.JSON
 	AnonymizedOutput     *string
+	AnonymizationPolicy  datatypes.JSON
 	// AI交互相关字段
 	SystemPrompt   *string
 	UserMessage    *string
@@ -297,6 +298,32 @@ func (s *SugarExecutionLogService) RecordLLMConfig(ctx context.Context, logCtx *
 	return nil
 }
 
+// RecordAnonymizationPolicy 记录本次调用生效的匿名化策略
+func (s *SugarExecutionLogService) RecordAnonymizationPolicy(ctx context.Context, logCtx *ExecutionLogContext, policy interface{}) error {
+	if logCtx == nil {
+		return nil
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		global.GVA_LOG.Error("序列化匿名化策略失败", zap.Error(err))
+		return fmt.Errorf("序列化匿名化策略失败: %w", err)
+	}
+
+	// 立即写入，保证调用失败时同样可以追溯所用策略
+	logCtx.AnonymizationPolicy = datatypes.JSON(policyJSON)
+	if err := global.GVA_DB.Model(&sugar.SugarExecutionLogs{}).Where("id = ?", logCtx.LogID).Update("anonymization_policy", logCtx.AnonymizationPolicy).Error; err != nil {
+		global.GVA_LOG.Error("记录匿名化策略失败", zap.Error(err), zap.Int64("logId", logCtx.LogID))
+		return fmt.Errorf("记录匿名化策略失败: %w", err)
+	}
+
+	global.GVA_LOG.Debug("记录匿名化策略",
+		zap.Int64("logId", logCtx.LogID),
+		zap.String("policy", string(policyJSON)))
+
+	return nil
+}
+
 // RecordLLMResponse 记录LLM响应
 func (s *SugarExecutionLogService) RecordLLMResponse(ctx context.Context, logCtx *ExecutionLogContext, response string, model *string, tokens *int) {
 	if logCtx == nil {
@@ -0,0 +1,420 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	systemModel "github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultFileVersionCoalesceWindow = 5 * time.Minute
+	defaultMaxFileVersions           = 100
+	defaultFileVersionRetention      = 90 * 24 * time.Hour
+
+	// maxFileVersionNameLength 版本名称的最大字符数
+	maxFileVersionNameLength = 100
+)
+
+type SugarFileVersionsService struct{}
+
+// fileVersionSettings 读取历史版本配置并补全默认值
+func fileVersionSettings() (coalesce time.Duration, maxVersions int, retention time.Duration) {
+	config := global.GVA_CONFIG.Sugar.FileVersions
+	coalesce = time.Duration(config.CoalesceMinutes) * time.Minute
+	maxVersions = config.MaxVersions
+	retention = time.Duration(config.RetentionDays) * 24 * time.Hour
+	if coalesce <= 0 {
+		coalesce = defaultFileVersionCoalesceWindow
+	}
+	if maxVersions <= 0 {
+		maxVersions = defaultMaxFileVersions
+	}
+	if retention <= 0 {
+		retention = defaultFileVersionRetention
+	}
+	return
+}
+
+// lockWorkbookFile 在事务中锁定工作簿文件记录，串行化同一文件的并发写入，保证版本号连续
+func lockWorkbookFile(tx *gorm.DB, id string) (*sugar.SugarWorkspaces, error) {
+	var workspace sugar.SugarWorkspaces
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND type = ? AND deleted_at IS NULL", id, "file").First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	return &workspace, nil
+}
+
+// snapshotWorkbookContent 在事务中为即将写入的工作簿内容记录历史版本
+// workspace 为写入前的文件记录：文件还没有任何版本时，先把已有内容补记为基线版本，保证第一次保存也可回退
+// 内容与最新版本相同时不生成新版本；同一用户在合并窗口内的连续保存更新最新版本，命名或置顶的版本不参与合并
+func snapshotWorkbookContent(tx *gorm.DB, workspace *sugar.SugarWorkspaces, content datatypes.JSON, userId, source string, restoredFrom *int) (*sugar.SugarFileVersions, error) {
+	coalesce, _, _ := fileVersionSettings()
+	now := time.Now()
+
+	var latest *sugar.SugarFileVersions
+	var found sugar.SugarFileVersions
+	err := tx.Where("file_id = ?", *workspace.Id).Order("version_number DESC").First(&found).Error
+	switch {
+	case err == nil:
+		latest = &found
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	if latest == nil && len(workspace.Content) > 0 && !sameWorkbookContent(workspace.Content, content) {
+		author := workspace.UpdatedBy
+		if author == nil {
+			author = workspace.CreatedBy
+		}
+		createdAt := workspace.UpdatedAt
+		if createdAt == nil {
+			createdAt = &now
+		}
+		baseline := &sugar.SugarFileVersions{
+			FileId:        workspace.Id,
+			VersionNumber: 1,
+			Content:       workspace.Content,
+			Source:        sugar.FileVersionSourceBaseline,
+			SaveCount:     1,
+			CreatedBy:     author,
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
+		}
+		if err := tx.Create(baseline).Error; err != nil {
+			return nil, err
+		}
+		latest = baseline
+	}
+
+	if latest != nil && sameWorkbookContent(latest.Content, content) {
+		return latest, nil
+	}
+
+	if latest != nil && source == sugar.FileVersionSourceSave && latest.Source == sugar.FileVersionSourceSave &&
+		latest.CreatedBy != nil && *latest.CreatedBy == userId && (latest.Name == nil || *latest.Name == "") && !latest.IsPinned &&
+		latest.CreatedAt != nil && now.Sub(*latest.CreatedAt) < coalesce {
+		err := tx.Model(latest).Updates(map[string]interface{}{
+			"content":    content,
+			"save_count": gorm.Expr("save_count + 1"),
+			"updated_at": now,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		latest.Content, latest.UpdatedAt = content, &now
+		latest.SaveCount++
+		return latest, nil
+	}
+
+	versionNumber := 1
+	if latest != nil {
+		versionNumber = latest.VersionNumber + 1
+	}
+	version := &sugar.SugarFileVersions{
+		FileId:        workspace.Id,
+		VersionNumber: versionNumber,
+		Content:       content,
+		Source:        source,
+		RestoredFrom:  restoredFrom,
+		SaveCount:     1,
+		CreatedBy:     &userId,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return nil, err
+	}
+	if err := pruneFileVersions(tx, *workspace.Id, versionNumber, now); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// pruneFileVersions 按保留策略清理旧版本：超出数量上限或保留期限的未命名、未置顶版本被删除，最新版本始终保留
+func pruneFileVersions(tx *gorm.DB, fileId string, latestVersion int, now time.Time) error {
+	_, maxVersions, retention := fileVersionSettings()
+	var candidates []sugar.SugarFileVersions
+	err := tx.Select("id", "version_number", "created_at").
+		Where("file_id = ? AND is_pinned = ? AND (name IS NULL OR name = '')", fileId, false).
+		Order("version_number DESC").Find(&candidates).Error
+	if err != nil {
+		return err
+	}
+
+	var expired []int64
+	kept := 0
+	for _, candidate := range candidates {
+		if candidate.VersionNumber == latestVersion {
+			kept++
+			continue
+		}
+		if kept >= maxVersions || (candidate.CreatedAt != nil && now.Sub(*candidate.CreatedAt) > retention) {
+			expired = append(expired, candidate.Id)
+			continue
+		}
+		kept++
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ?", expired).Delete(&sugar.SugarFileVersions{}).Error
+}
+
+// sameWorkbookContent 按JSON语义比较两份工作簿内容，忽略键顺序和空白差异
+func sameWorkbookContent(a, b []byte) bool {
+	left, err := decodeUniverWorkbook(a)
+	if err != nil {
+		return false
+	}
+	right, err := decodeUniverWorkbook(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// getAccessibleWorkbook 获取工作簿文件，并校验用户的有效权限允许执行 action
+func (s *SugarFileVersionsService) getAccessibleWorkbook(ctx context.Context, fileId string, userId string, action SugarAction) (*sugar.SugarWorkspaces, error) {
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND type = ? AND deleted_at IS NULL", fileId, "file").First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, action); err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// getAccessibleVersion 获取历史版本及其所属文件，并校验用户允许执行 action
+func (s *SugarFileVersionsService) getAccessibleVersion(ctx context.Context, id int64, userId string, action SugarAction) (*sugar.SugarFileVersions, *sugar.SugarWorkspaces, error) {
+	var version sugar.SugarFileVersions
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("历史版本不存在")
+		}
+		return nil, nil, errors.New("查询历史版本失败")
+	}
+	workspace, err := s.getAccessibleWorkbook(ctx, *version.FileId, userId, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &version, workspace, nil
+}
+
+// GetFileVersionList 分页获取工作簿的历史版本，按版本号倒序，不含内容快照
+func (s *SugarFileVersionsService) GetFileVersionList(ctx context.Context, info sugarReq.SugarFileVersionsSearch, userId string) (list []sugarRes.SugarFileVersionItem, total int64, err error) {
+	if _, err = s.getAccessibleWorkbook(ctx, info.FileId, userId, SugarActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarFileVersions{}).Where("file_id = ?", info.FileId)
+	if info.OnlyNamed {
+		db = db.Where("is_pinned = ? OR (name IS NOT NULL AND name <> '')", true)
+	}
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if info.PageSize > 0 {
+		db = db.Limit(info.PageSize).Offset(info.PageSize * (info.Page - 1))
+	}
+
+	var versions []sugar.SugarFileVersions
+	err = db.Omit("content").Order("version_number DESC").Find(&versions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	authorIds := make([]string, 0, len(versions))
+	for _, version := range versions {
+		if version.CreatedBy != nil {
+			authorIds = append(authorIds, *version.CreatedBy)
+		}
+	}
+	authorNames := lookupUserNames(ctx, authorIds)
+
+	list = make([]sugarRes.SugarFileVersionItem, 0, len(versions))
+	for _, version := range versions {
+		item := sugarRes.SugarFileVersionItem{
+			Id:            version.Id,
+			FileId:        *version.FileId,
+			VersionNumber: version.VersionNumber,
+			IsPinned:      version.IsPinned,
+			Source:        version.Source,
+			RestoredFrom:  version.RestoredFrom,
+			SaveCount:     version.SaveCount,
+			CreatedAt:     version.CreatedAt,
+			UpdatedAt:     version.UpdatedAt,
+		}
+		if version.Name != nil {
+			item.Name = *version.Name
+		}
+		if version.CreatedBy != nil {
+			item.CreatedBy = *version.CreatedBy
+			item.AuthorName = authorNames[*version.CreatedBy]
+		}
+		list = append(list, item)
+	}
+	return list, total, nil
+}
+
+// lookupUserNames 查询用户昵称，昵称为空时使用用户名
+func lookupUserNames(ctx context.Context, userIds []string) map[string]string {
+	names := map[string]string{}
+	ids := make([]uint64, 0, len(userIds))
+	for _, userId := range userIds {
+		if id, err := strconv.ParseUint(userId, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return names
+	}
+	var users []systemModel.SysUser
+	if err := global.GVA_DB.WithContext(ctx).Select("id", "username", "nick_name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		global.GVA_LOG.Warn("查询用户昵称失败", zap.Error(err))
+		return names
+	}
+	for _, user := range users {
+		name := user.NickName
+		if name == "" {
+			name = user.Username
+		}
+		names[strconv.FormatUint(uint64(user.ID), 10)] = name
+	}
+	return names
+}
+
+// GetFileVersion 获取历史版本详情，包含内容快照
+func (s *SugarFileVersionsService) GetFileVersion(ctx context.Context, id int64, userId string) (*sugar.SugarFileVersions, error) {
+	version, _, err := s.getAccessibleVersion(ctx, id, userId, SugarActionRead)
+	return version, err
+}
+
+// DiffFileVersions 在工作表/单元格级别对比两个历史版本，ToVersion 为0时与当前内容对比
+func (s *SugarFileVersionsService) DiffFileVersions(ctx context.Context, req sugarReq.SugarFileVersionDiffRequest, userId string) (*sugarRes.SugarFileVersionDiffResponse, error) {
+	workspace, err := s.getAccessibleWorkbook(ctx, req.FileId, userId, SugarActionRead)
+	if err != nil {
+		return nil, err
+	}
+	loadContent := func(versionNumber int) (datatypes.JSON, error) {
+		if versionNumber == 0 {
+			return workspace.Content, nil
+		}
+		var version sugar.SugarFileVersions
+		err := global.GVA_DB.WithContext(ctx).Where("file_id = ? AND version_number = ?", req.FileId, versionNumber).First(&version).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("版本 " + strconv.Itoa(versionNumber) + " 不存在")
+			}
+			return nil, errors.New("查询历史版本失败")
+		}
+		return version.Content, nil
+	}
+
+	before, err := loadContent(req.FromVersion)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadContent(req.ToVersion)
+	if err != nil {
+		return nil, err
+	}
+	result, err := diffUniverWorkbooks(before, after)
+	if err != nil {
+		return nil, err
+	}
+	result.FileId, result.FromVersion, result.ToVersion = req.FileId, req.FromVersion, req.ToVersion
+	return result, nil
+}
+
+// RestoreFileVersion 将工作簿恢复为指定历史版本的内容
+// 恢复不会删除任何版本，而是以该版本内容生成一个新版本，因此恢复操作本身也可以撤销
+func (s *SugarFileVersionsService) RestoreFileVersion(ctx context.Context, id int64, userId string) (*sugar.SugarFileVersions, error) {
+	version, workspace, err := s.getAccessibleVersion(ctx, id, userId, SugarActionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored *sugar.SugarFileVersions
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockWorkbookFile(tx, *workspace.Id)
+		if err != nil {
+			return err
+		}
+		restored, err = snapshotWorkbookContent(tx, locked, version.Content, userId, sugar.FileVersionSourceRestore, &version.VersionNumber)
+		if err != nil {
+			return err
+		}
+		revision := locked.Revision + 1
+		err = tx.Model(locked).Updates(map[string]interface{}{
+			"content":    version.Content,
+			"revision":   revision,
+			"updated_by": userId,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return indexWorkbookContent(tx, *locked.Id, version.Content, revision)
+	})
+	if err != nil {
+		global.GVA_LOG.Error("恢复历史版本失败", zap.Int64("versionId", id), zap.Error(err))
+		return nil, errors.New("恢复历史版本失败")
+	}
+
+	global.GVA_LOG.Info("工作簿已恢复为历史版本", zap.String("fileId", *workspace.Id),
+		zap.Int("fromVersion", version.VersionNumber), zap.Int("newVersion", restored.VersionNumber))
+	restored.Content = nil
+	return restored, nil
+}
+
+// UpdateFileVersion 命名或置顶历史版本，命名或置顶的版本不会被合并或按保留策略清理
+func (s *SugarFileVersionsService) UpdateFileVersion(ctx context.Context, req sugarReq.SugarFileVersionUpdateRequest, userId string) error {
+	version, _, err := s.getAccessibleVersion(ctx, req.Id, userId, SugarActionEdit)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if utf8.RuneCountInString(name) > maxFileVersionNameLength {
+			return errors.New("版本名称不能超过100个字符")
+		}
+		if name == "" {
+			updates["name"] = nil
+		} else {
+			updates["name"] = name
+		}
+	}
+	if req.IsPinned != nil {
+		updates["is_pinned"] = *req.IsPinned
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return global.GVA_DB.WithContext(ctx).Model(version).Updates(updates).Error
+}
@@ -0,0 +1,164 @@
+package sugar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+func listVersions(t *testing.T) []sugarRes.SugarFileVersionItem {
+	t.Helper()
+	list, _, err := (&SugarFileVersionsService{}).GetFileVersionList(context.Background(), sugarReq.SugarFileVersionsSearch{FileId: testFileId}, "1")
+	if err != nil {
+		t.Fatalf("获取历史版本失败: %v", err)
+	}
+	return list
+}
+
+func TestSaveWorkbookContent_SnapshotsAndCoalesces(t *testing.T) {
+	setupTeamWorkbook(t)
+
+	// 第一次保存：补记基线版本，再生成保存版本
+	saveWorkbook(t, "1", `"0": {"0": {"v": "收入"}, "1": {"v": 120}}`)
+	// 同一用户在合并窗口内再次保存：合并到版本2
+	saveWorkbook(t, "1", `"0": {"0": {"v": "收入"}, "1": {"v": 130}}`)
+	// 内容未变化：不生成版本
+	saveWorkbook(t, "1", `"0": {"0": {"v": "收入"}, "1": {"v": 130}}`)
+	// 其他用户保存：生成新版本
+	saveWorkbook(t, "2", `"0": {"0": {"v": "收入"}, "1": {"v": 140}}`)
+
+	list := listVersions(t)
+	if len(list) != 3 {
+		t.Fatalf("版本数 = %d，期望 3: %+v", len(list), list)
+	}
+	if list[2].Source != sugar.FileVersionSourceBaseline || list[1].SaveCount != 2 || list[0].VersionNumber != 3 {
+		t.Fatalf("版本记录不符合预期: %+v", list)
+	}
+	if list[1].AuthorName != "Alice" || list[0].AuthorName != "bob" {
+		t.Fatalf("作者名称 = (%s, %s)，期望 (Alice, bob)", list[1].AuthorName, list[0].AuthorName)
+	}
+
+	// 命名的版本不再参与合并
+	name := "定稿"
+	if err := (&SugarFileVersionsService{}).UpdateFileVersion(context.Background(), sugarReq.SugarFileVersionUpdateRequest{Id: list[0].Id, Name: &name}, "2"); err != nil {
+		t.Fatalf("命名版本失败: %v", err)
+	}
+	saveWorkbook(t, "2", `"0": {"0": {"v": "收入"}, "1": {"v": 150}}`)
+	if list = listVersions(t); len(list) != 4 || list[1].Name != "定稿" {
+		t.Fatalf("命名后保存应生成新版本: %+v", list)
+	}
+
+	// 超出合并窗口后生成新版本
+	global.GVA_DB.Model(&sugar.SugarFileVersions{}).Where("version_number = ?", 4).Update("created_at", time.Now().Add(-time.Hour))
+	saveWorkbook(t, "2", `"0": {"0": {"v": "收入"}, "1": {"v": 160}}`)
+	if list = listVersions(t); len(list) != 5 {
+		t.Fatalf("超出合并窗口应生成新版本: %+v", list)
+	}
+}
+
+func TestRestoreFileVersion(t *testing.T) {
+	setupTeamWorkbook(t)
+	saveWorkbook(t, "1", `"0": {"0": {"v": "收入"}, "1": {"v": 120}}`)
+
+	service := &SugarFileVersionsService{}
+	baseline := listVersions(t)[1]
+	if _, err := service.RestoreFileVersion(context.Background(), baseline.Id, "3"); err == nil {
+		t.Fatal("非团队成员不应能恢复版本")
+	}
+	restored, err := service.RestoreFileVersion(context.Background(), baseline.Id, "2")
+	if err != nil {
+		t.Fatalf("恢复版本失败: %v", err)
+	}
+	if restored.VersionNumber != 3 || restored.Source != sugar.FileVersionSourceRestore || *restored.RestoredFrom != 1 {
+		t.Fatalf("恢复生成的版本不符合预期: %+v", restored)
+	}
+
+	current, err := (&SugarWorkspacesService{}).GetWorkbookContent(context.Background(), testFileId, "1")
+	if err != nil {
+		t.Fatalf("获取工作簿内容失败: %v", err)
+	}
+	if current.Revision != 2 {
+		t.Fatalf("恢复后修订号 = %d，期望 2", current.Revision)
+	}
+	if !sameWorkbookContent(current.Content, []byte(workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 100}}`))) {
+		t.Fatalf("恢复后的内容不正确: %s", current.Content)
+	}
+}
+
+func TestPruneFileVersions(t *testing.T) {
+	setupTeamWorkbook(t)
+	global.GVA_CONFIG.Sugar.FileVersions.MaxVersions = 2
+	defer func() { global.GVA_CONFIG.Sugar.FileVersions.MaxVersions = 0 }()
+
+	for i, value := range []string{"1", "2", "3", "4"} {
+		saveWorkbook(t, "1", `"0": {"0": {"v": `+value+`}}`)
+		if i == 0 {
+			pinned := true
+			if err := (&SugarFileVersionsService{}).UpdateFileVersion(context.Background(), sugarReq.SugarFileVersionUpdateRequest{Id: listVersions(t)[0].Id, IsPinned: &pinned}, "1"); err != nil {
+				t.Fatalf("置顶版本失败: %v", err)
+			}
+		}
+		// 每次保存都超出合并窗口
+		global.GVA_DB.Model(&sugar.SugarFileVersions{}).Where("1 = 1").Update("created_at", time.Now().Add(-time.Hour))
+	}
+
+	var numbers []int
+	global.GVA_DB.Model(&sugar.SugarFileVersions{}).Order("version_number").Pluck("version_number", &numbers)
+	// 基线版本1被清理；置顶的版本2保留；未置顶版本只保留最新的两个
+	expected := []int{2, 4, 5}
+	if len(numbers) != len(expected) {
+		t.Fatalf("保留的版本 = %v，期望 %v", numbers, expected)
+	}
+	for i := range expected {
+		if numbers[i] != expected[i] {
+			t.Fatalf("保留的版本 = %v，期望 %v", numbers, expected)
+		}
+	}
+}
+
+func TestDiffUniverWorkbooks(t *testing.T) {
+	before := `{"sheetOrder": ["s1", "s2"], "styles": {}, "sheets": {
+		"s1": {"id": "s1", "name": "收入", "cellData": {"0": {"0": {"v": "城市"}, "1": {"v": 10}}, "2": {"27": {"v": 1, "f": "=A1"}}}},
+		"s2": {"id": "s2", "name": "旧表", "cellData": {}}}}`
+	after := `{"sheetOrder": ["s1", "s3"], "styles": {"bold": {"bl": 1}}, "sheets": {
+		"s1": {"id": "s1", "name": "收入明细", "mergeData": [{"startRow": 0}], "cellData": {"0": {"0": {"v": "城市"}, "1": {"v": 12}, "2": {}}, "1": {"0": {"v": "北京"}}}},
+		"s3": {"id": "s3", "name": "新表", "cellData": {"0": {"0": {"v": 1}}}}}}`
+
+	result, err := diffUniverWorkbooks([]byte(before), []byte(after))
+	if err != nil {
+		t.Fatalf("对比失败: %v", err)
+	}
+	if len(result.WorkbookChanges) != 2 || result.WorkbookChanges[0] != "sheetOrder" || result.WorkbookChanges[1] != "styles" {
+		t.Fatalf("工作簿级变化 = %v", result.WorkbookChanges)
+	}
+	summary := sugarRes.WorkbookDiffSummary{SheetsAdded: 1, SheetsRemoved: 1, SheetsModified: 1, CellsAdded: 2, CellsRemoved: 1, CellsModified: 1}
+	if result.Summary != summary {
+		t.Fatalf("变化统计 = %+v，期望 %+v", result.Summary, summary)
+	}
+
+	sheet := result.Sheets[0]
+	if sheet.SheetId != "s1" || sheet.OldName != "收入" || len(sheet.PropertyChanges) != 1 || sheet.PropertyChanges[0] != "mergeData" {
+		t.Fatalf("工作表差异不符合预期: %+v", sheet)
+	}
+	cells := []string{}
+	for _, cell := range sheet.Cells {
+		cells = append(cells, cell.Cell+":"+cell.Type)
+	}
+	expected := []string{"B1:modified", "A2:added", "AB3:removed"}
+	if len(cells) != len(expected) {
+		t.Fatalf("单元格变化 = %v，期望 %v", cells, expected)
+	}
+	for i := range expected {
+		if cells[i] != expected[i] {
+			t.Fatalf("单元格变化 = %v，期望 %v", cells, expected)
+		}
+	}
+	if result.Sheets[1].SheetId != "s3" || result.Sheets[2].SheetId != "s2" || result.Sheets[2].Status != sugarRes.WorkbookDiffRemoved {
+		t.Fatalf("工作表顺序不符合预期: %+v", result.Sheets)
+	}
+}
@@ -0,0 +1,106 @@
+package sugar
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// testFileId 公共测试数据中的工作簿
+const testFileId = "file-1"
+
+// sugarTestModels 测试库的表结构，与 initialize/gorm_biz.go 中迁移的模型保持一致
+var sugarTestModels = []interface{}{
+	&system.SysUser{},
+	&sugar.SugarTeams{}, &sugar.SugarTeamMembers{}, &sugar.SugarDbConnections{}, &sugar.SugarSemanticModels{}, &sugar.SugarAgents{},
+	&sugar.SugarCityPermissions{}, &sugar.SugarRowLevelOverrides{}, &sugar.SugarExecutionLogs{}, &sugar.SugarWorkspaces{},
+	&sugar.SugarApiTokens{}, &sugar.SugarAnonymizationSessions{}, &sugar.SugarPrivacyBudgetLedgers{}, &sugar.SugarFileVersions{},
+	&sugar.SugarWorkbookOperations{}, &sugar.SugarWorkspacePermissions{}, &sugar.SugarShareLinks{}, &sugar.SugarWorkbookTemplates{},
+	&sugar.SugarWorkbookSearchDocuments{}, &sugar.SugarWorkbookSearchTerms{}, &sugar.SugarWorkbookReferences{},
+}
+
+// setupTestDB 创建内存数据库并按模型定义建表，各功能的测试只需写入自己的数据
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	for _, model := range sugarTestModels {
+		// SQLite 不支持 enum 类型，建表前按文本处理
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			t.Fatalf("解析模型失败: %v", err)
+		}
+		for _, field := range statement.Schema.Fields {
+			if strings.HasPrefix(strings.ToLower(string(field.DataType)), "enum") {
+				field.DataType = schema.String
+			}
+		}
+		if err := db.AutoMigrate(model); err != nil {
+			t.Fatalf("创建表 %T 失败: %v", model, err)
+		}
+	}
+
+	global.GVA_DB = db
+	global.GVA_LOG = zap.NewNop()
+	return db
+}
+
+// seedTestData 依次执行初始化数据的语句
+func seedTestData(t *testing.T, statements ...string) {
+	t.Helper()
+	for _, statement := range statements {
+		if err := global.GVA_DB.Exec(statement).Error; err != nil {
+			t.Fatalf("初始化数据失败: %v\n%s", err, statement)
+		}
+	}
+}
+
+// setupTeamWorkbook 初始化团队和工作簿测试数据：
+// 团队1中用户1、2为编辑者，10为所有者，11为管理员，12为查看者（12同时是团队2的编辑者）；用户3不属于任何团队；
+// 团队1的文件 file-1 已有内容但还没有任何版本
+func setupTeamWorkbook(t *testing.T) {
+	t.Helper()
+	setupTestDB(t)
+	seedTestData(t,
+		`INSERT INTO sys_users (id, username, nick_name) VALUES (1, 'alice', 'Alice'), (2, 'bob', ''), (3, 'carol', 'Carol')`,
+		`INSERT INTO sugar_teams (id, team_name) VALUES ('team-1', '财务部'), ('team-2', '销售部')`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor'), ('team-1', '2', 'editor'),
+			('team-1', '10', 'owner'), ('team-1', '11', 'admin'), ('team-1', '12', 'viewer'), ('team-2', '12', 'editor')`,
+		`INSERT INTO sugar_workspaces (id, name, type, team_id, content, created_by, updated_by) VALUES
+			('file-1', '预算', 'file', 'team-1', '`+workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 100}}`)+`', '1', '1')`,
+	)
+}
+
+// workbookJSON 构造只有一个工作表的 Univer 工作簿内容
+func workbookJSON(cellData string) string {
+	return `{"id": "wb", "sheetOrder": ["sheet-1"], "sheets": {"sheet-1": {"id": "sheet-1", "name": "Sheet1", "cellData": {` + cellData + `}}}}`
+}
+
+// saveWorkbook 以当前修订号保存测试工作簿
+func saveWorkbook(t *testing.T, userId, cellData string) {
+	t.Helper()
+	service := &SugarWorkspacesService{}
+	current, err := service.GetWorkbookContent(context.Background(), testFileId, userId)
+	if err != nil {
+		t.Fatalf("获取工作簿内容失败: %v", err)
+	}
+	if _, err := service.SaveWorkbookContent(context.Background(), testFileId, []byte(workbookJSON(cellData)), nil, current.Revision, userId); err != nil {
+		t.Fatalf("保存工作簿失败: %v", err)
+	}
+}
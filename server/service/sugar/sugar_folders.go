@@ -3,6 +3,7 @@ package sugar
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
@@ -12,43 +13,67 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SugarFoldersService struct{}
 
 // GetWorkspaceTree 获取工作空间文件夹树形结构
+// 包含用户所在团队中可查看的全部内容，以及单独分享给用户或其团队的文件和文件夹
 func (s *SugarFoldersService) GetWorkspaceTree(ctx context.Context, req *sugarReq.SugarFoldersGetWorkspaceTreeRequest, userId string) (*sugarRes.SugarFoldersGetWorkspaceTreeResponse, error) {
 	var workspaces []sugar.SugarWorkspaces
 
+	roles, err := userTeamRoles(ctx, userId)
+	if err != nil {
+		global.GVA_LOG.Error("获取用户团队信息失败", zap.Error(err))
+		return nil, errors.New("获取用户团队信息失败")
+	}
+	grants, err := userGrants(ctx, userId, teamIdsOf(roles), nil)
+	if err != nil {
+		global.GVA_LOG.Error("获取用户授权信息失败", zap.Error(err))
+		return nil, errors.New("获取用户授权信息失败")
+	}
+	levels := maxGrantLevels(grants)
+
 	// 构建查询条件
 	query := global.GVA_DB.Where("deleted_at IS NULL")
 
+	teamIds := readableTeamIds(roles)
 	if req.TeamId != nil && *req.TeamId != "" {
-		// 指定团队ID
+		// 指定团队ID，不是团队成员时只返回该团队分享给用户的内容
 		query = query.Where("team_id = ?", *req.TeamId)
-	} else {
-		// 获取用户所属的所有团队
-		var teamIds []string
-		err := global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
-		if err != nil {
-			global.GVA_LOG.Error("获取用户团队信息失败", zap.Error(err))
-			return nil, errors.New("获取用户团队信息失败")
-		}
-		if len(teamIds) == 0 {
-			return sugarRes.NewWorkspaceTreeSuccessResponse([]*sugarRes.SugarFoldersWorkspaceTreeNode{}), nil
+		if SugarRoleAllows(roles[*req.TeamId], SugarResourceWorkspace, SugarActionRead) {
+			teamIds = []string{*req.TeamId}
+		} else {
+			teamIds = []string{}
 		}
+	}
+
+	sharedIds, err := sharedWorkspaceIds(ctx, levels, readableTeamIds(roles))
+	if err != nil {
+		global.GVA_LOG.Error("查询分享的文件失败", zap.Error(err))
+		return nil, errors.New("查询工作空间失败")
+	}
+	switch {
+	case len(teamIds) == 0 && len(sharedIds) == 0:
+		return sugarRes.NewWorkspaceTreeSuccessResponse([]*sugarRes.SugarFoldersWorkspaceTreeNode{}), nil
+	case len(sharedIds) == 0:
 		query = query.Where("team_id IN ?", teamIds)
+	case len(teamIds) == 0:
+		query = query.Where("id IN ?", sharedIds)
+	default:
+		query = query.Where("team_id IN ? OR id IN ?", teamIds, sharedIds)
 	}
 
 	// 查询所有工作空间项目
-	err := query.Order("created_at ASC").Find(&workspaces).Error
+	err = query.Order("created_at ASC").Find(&workspaces).Error
 	if err != nil {
 		global.GVA_LOG.Error("查询工作空间失败", zap.Error(err))
 		return nil, errors.New("查询工作空间失败")
 	}
 
 	// 构建树形结构
-	tree := s.buildTree(workspaces)
+	tree := s.buildTree(workspaces, roles, levels)
 
 	return sugarRes.NewWorkspaceTreeSuccessResponse(tree), nil
 }
@@ -60,17 +85,11 @@ func (s *SugarFoldersService) CreateFolder(ctx context.Context, req *sugarReq.Su
 		return nil, errors.New("无效的类型，只支持 folder 或 file")
 	}
 
-	// 验证用户是否有权限在该团队创建文件夹
-	var count int64
-	err := global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, req.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限在该团队创建文件夹")
-	}
-
 	// 如果指定了父文件夹，验证父文件夹是否存在且为文件夹类型
+	var parent *sugar.SugarWorkspaces
 	if req.ParentId != nil && *req.ParentId != "" {
-		var parent sugar.SugarWorkspaces
-		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *req.ParentId, req.TeamId, "folder").First(&parent).Error
+		parent = &sugar.SugarWorkspaces{}
+		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *req.ParentId, req.TeamId, "folder").First(parent).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return nil, errors.New("父文件夹不存在")
@@ -79,6 +98,12 @@ func (s *SugarFoldersService) CreateFolder(ctx context.Context, req *sugarReq.Su
 		}
 	}
 
+	// 验证用户是否有权限在团队根目录或父文件夹下创建
+	err := authorizeWorkspaceCreate(ctx, req.TeamId, parent, userId)
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查同级目录下是否已存在同名项目
 	var existCount int64
 	query := global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("name = ? AND team_id = ? AND deleted_at IS NULL", req.Name, req.TeamId)
@@ -131,11 +156,9 @@ func (s *SugarFoldersService) RenameItem(ctx context.Context, req *sugarReq.Suga
 		return nil, errors.New("查询项目失败")
 	}
 
-	// 验证用户是否有权限
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *workspace.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限操作该项目")
+	// 验证用户是否有权限编辑
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionEdit); err != nil {
+		return nil, err
 	}
 
 	// 检查同级目录下是否已存在同名项目
@@ -187,20 +210,17 @@ func (s *SugarFoldersService) MoveItem(ctx context.Context, req *sugarReq.SugarF
 		return nil, errors.New("查询项目失败")
 	}
 
-	// 验证用户是否有权限操作原项目
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *workspace.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限操作该项目")
+	// 验证用户是否有权限操作原项目：移出团队相当于在原团队删除
+	sourceAction := SugarActionEdit
+	if workspace.TeamId == nil || *workspace.TeamId != req.TeamId {
+		sourceAction = SugarActionDelete
 	}
-
-	// 验证用户是否有权限操作目标团队
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, req.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限移动到目标团队")
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, sourceAction); err != nil {
+		return nil, err
 	}
 
 	// 如果指定了目标父文件夹，验证父文件夹是否存在且为文件夹类型
+	var target *sugar.SugarWorkspaces
 	if req.ParentId != nil && *req.ParentId != "" {
 		// 不能移动到自己或自己的子目录
 		if *req.ParentId == req.Id {
@@ -218,8 +238,8 @@ func (s *SugarFoldersService) MoveItem(ctx context.Context, req *sugarReq.SugarF
 			}
 		}
 
-		var parent sugar.SugarWorkspaces
-		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *req.ParentId, req.TeamId, "folder").First(&parent).Error
+		target = &sugar.SugarWorkspaces{}
+		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *req.ParentId, req.TeamId, "folder").First(target).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return nil, errors.New("目标父文件夹不存在")
@@ -228,6 +248,11 @@ func (s *SugarFoldersService) MoveItem(ctx context.Context, req *sugarReq.SugarF
 		}
 	}
 
+	// 验证用户是否有权限在目标团队根目录或目标文件夹下创建
+	if err = authorizeWorkspaceCreate(ctx, req.TeamId, target, userId); err != nil {
+		return nil, err
+	}
+
 	// 检查目标位置是否已存在同名项目
 	var existCount int64
 	query := global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("name = ? AND team_id = ? AND id != ? AND deleted_at IS NULL", *workspace.Name, req.TeamId, req.Id)
@@ -266,6 +291,179 @@ func (s *SugarFoldersService) MoveItem(ctx context.Context, req *sugarReq.SugarF
 	return sugarRes.NewMoveSuccessResponse(workspace), nil
 }
 
+// workspaceCopyMaxItems 单次复制允许的最大项目数
+const workspaceCopyMaxItems = 1000
+
+// CopyItem 复制文件夹或文件，文件夹连同其下全部内容一起复制
+// 需要对原项目有查看权限、对目标位置有创建权限；可复制到其他团队，授权、分享链接和历史版本不随之复制
+func (s *SugarFoldersService) CopyItem(ctx context.Context, req *sugarReq.SugarFoldersCopyRequest, userId string) (*sugarRes.SugarFoldersCopyResponse, error) {
+	// 查找要复制的项目
+	var source sugar.SugarWorkspaces
+	err := global.GVA_DB.Where("id = ? AND deleted_at IS NULL", req.Id).First(&source).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("项目不存在")
+		}
+		return nil, errors.New("查询项目失败")
+	}
+
+	// 验证用户是否有权限查看原项目
+	if err = authorizeWorkspaceItem(ctx, &source, userId, SugarActionRead); err != nil {
+		return nil, err
+	}
+
+	// 未指定目标团队时在原位置创建副本
+	teamId, parentId := req.TeamId, req.ParentId
+	if teamId == "" {
+		teamId, parentId = *source.TeamId, source.ParentId
+	}
+	if parentId != nil && *parentId == "" {
+		parentId = nil
+	}
+
+	// 如果指定了目标父文件夹，验证父文件夹是否存在且为文件夹类型
+	var target *sugar.SugarWorkspaces
+	if parentId != nil {
+		if source.Type == "folder" {
+			if *parentId == req.Id {
+				return nil, errors.New("不能复制到自己")
+			}
+			isChild, err := s.isChildFolder(req.Id, *parentId)
+			if err != nil {
+				return nil, err
+			}
+			if isChild {
+				return nil, errors.New("不能复制到自己的子目录")
+			}
+		}
+
+		target = &sugar.SugarWorkspaces{}
+		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *parentId, teamId, "folder").First(target).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("目标父文件夹不存在")
+			}
+			return nil, errors.New("查询目标父文件夹失败")
+		}
+	}
+
+	// 验证用户是否有权限在目标团队根目录或目标文件夹下创建
+	if err = authorizeWorkspaceCreate(ctx, teamId, target, userId); err != nil {
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = *source.Name
+	}
+
+	items, err := s.collectSubtree(&source)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按层次顺序生成副本，父项目总在子项目之前，子项目指向父项目的副本
+	now := time.Now()
+	copiedIds := make(map[string]string, len(items))
+	duplicates := make([]sugar.SugarWorkspaces, 0, len(items))
+	for _, item := range items {
+		id := uuid.New().String()
+		copiedIds[*item.Id] = id
+		duplicate := sugar.SugarWorkspaces{
+			Id:        &id,
+			Name:      item.Name,
+			Type:      item.Type,
+			TeamId:    &teamId,
+			Content:   item.Content,
+			CreatedBy: &userId,
+			CreatedAt: &now,
+			UpdatedBy: &userId,
+			UpdatedAt: &now,
+		}
+		if *item.Id == req.Id {
+			duplicate.ParentId = parentId
+		} else {
+			copiedParentId := copiedIds[*item.ParentId]
+			duplicate.ParentId = &copiedParentId
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+
+	// 目标位置已有同名项目时自动追加序号；名称检查与写入在同一事务中进行，目标文件夹行加锁，避免并发复制后仍写入重复名称
+	renamed := false
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if parentId != nil {
+			var locked sugar.SugarWorkspaces
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND deleted_at IS NULL", *parentId).First(&locked).Error
+			if err != nil {
+				return err
+			}
+		}
+		existingId, err := siblingWithName(tx, teamId, parentId, name, "")
+		if err != nil {
+			return err
+		}
+		if existingId != "" {
+			if name, err = availableName(tx, teamId, parentId, name, ""); err != nil {
+				return err
+			}
+			renamed = true
+		}
+		duplicates[0].Name = &name
+
+		if err := tx.CreateInBatches(&duplicates, 100).Error; err != nil {
+			return err
+		}
+		for _, duplicate := range duplicates {
+			if duplicate.Type != "file" {
+				continue
+			}
+			if err := indexWorkbookContent(tx, *duplicate.Id, duplicate.Content, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("目标父文件夹不存在")
+	}
+	if errors.Is(err, ErrNoAvailableName) {
+		return nil, err
+	}
+	if err != nil {
+		global.GVA_LOG.Error("复制失败", zap.Error(err))
+		return nil, errors.New("复制失败")
+	}
+
+	global.GVA_LOG.Info("复制项目", zap.String("source", req.Id), zap.String("id", *duplicates[0].Id), zap.Int("count", len(duplicates)), zap.String("by", userId))
+	return &sugarRes.SugarFoldersCopyResponse{SugarWorkspace: duplicates[0], CopiedCount: len(duplicates), Renamed: renamed}, nil
+}
+
+// collectSubtree 按层次顺序收集项目及其下全部未删除的内容（含工作簿内容）
+// 层级超过 workspaceMaxDepth 时返回 ErrWorkspaceTooDeep，不复制不完整的副本
+func (s *SugarFoldersService) collectSubtree(root *sugar.SugarWorkspaces) ([]sugar.SugarWorkspaces, error) {
+	items := []sugar.SugarWorkspaces{*root}
+	frontier := []string{*root.Id}
+	for depth := 0; root.Type == "folder" && len(frontier) > 0 && depth < workspaceMaxDepth; depth++ {
+		var children []sugar.SugarWorkspaces
+		if err := global.GVA_DB.Where("parent_id IN ? AND deleted_at IS NULL", frontier).Order("type asc, name asc").Find(&children).Error; err != nil {
+			return nil, errors.New("查询子项目失败")
+		}
+		if len(items)+len(children) > workspaceCopyMaxItems {
+			return nil, fmt.Errorf("文件夹内容超过 %d 项，无法一次复制", workspaceCopyMaxItems)
+		}
+		frontier = make([]string, 0, len(children))
+		for _, child := range children {
+			frontier = append(frontier, *child.Id)
+		}
+		items = append(items, children...)
+	}
+	if root.Type == "folder" && len(frontier) > 0 {
+		return nil, ErrWorkspaceTooDeep
+	}
+	return items, nil
+}
+
 // DeleteItem 删除文件夹或文件
 func (s *SugarFoldersService) DeleteItem(ctx context.Context, req *sugarReq.SugarFoldersDeleteRequest, userId string) (*sugarRes.SugarFoldersDeleteResponse, error) {
 	// 查找要删除的项目
@@ -278,36 +476,25 @@ func (s *SugarFoldersService) DeleteItem(ctx context.Context, req *sugarReq.Suga
 		return nil, errors.New("查询项目失败")
 	}
 
-	// 验证用户是否有权限
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *workspace.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限操作该项目")
+	// 验证用户是否有权限删除
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionDelete); err != nil {
+		return nil, err
 	}
 
-	// 如果是文件夹，检查是否有子项目
-	if workspace.Type == "folder" {
-		var childCount int64
-		err = global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("parent_id = ? AND deleted_at IS NULL", req.Id).Count(&childCount).Error
-		if err != nil {
-			return nil, errors.New("检查子项目失败")
-		}
-		if childCount > 0 {
-			return nil, errors.New("文件夹不为空，无法删除")
-		}
+	// 软删除到回收站，文件夹连同其下全部内容在同一事务中删除
+	var deleted int
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted, err = softDeleteWorkspaceTree(tx, &workspace, userId, time.Now())
+		return err
+	})
+	if errors.Is(err, ErrWorkspaceTooDeep) {
+		return nil, err
 	}
-
-	// 软删除
-	now := time.Now()
-	err = global.GVA_DB.Model(&workspace).Updates(map[string]interface{}{
-		"deleted_at": now,
-		"updated_by": userId,
-		"updated_at": now,
-	}).Error
 	if err != nil {
 		global.GVA_LOG.Error("删除失败", zap.Error(err))
 		return nil, errors.New("删除失败")
 	}
+	global.GVA_LOG.Info("项目已移入回收站", zap.String("id", req.Id), zap.Int("count", deleted), zap.String("by", userId))
 
 	return sugarRes.NewDeleteSuccessResponse("删除成功"), nil
 }
@@ -324,11 +511,9 @@ func (s *SugarFoldersService) GetFolderContent(ctx context.Context, req *sugarRe
 		return nil, errors.New("查询文件夹失败")
 	}
 
-	// 验证用户是否有权限
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *folder.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限访问该文件夹")
+	// 验证用户是否有权限查看，文件夹的权限继承到其下全部内容
+	if err = authorizeWorkspaceItem(ctx, &folder, userId, SugarActionRead); err != nil {
+		return nil, err
 	}
 
 	// 查询文件夹内容
@@ -354,8 +539,9 @@ func (s *SugarFoldersService) GetFolderContent(ctx context.Context, req *sugarRe
 	return sugarRes.NewGetFolderContentSuccessResponse(workspaces, total, req.Page, req.PageSize), nil
 }
 
-// buildTree 构建树形结构
-func (s *SugarFoldersService) buildTree(workspaces []sugar.SugarWorkspaces) []*sugarRes.SugarFoldersWorkspaceTreeNode {
+// buildTree 构建树形结构，并计算用户对每个节点允许的操作
+// roles 为用户的团队角色，levels 为用户在各节点上被直接授予的权限级别，授权沿树向下继承
+func (s *SugarFoldersService) buildTree(workspaces []sugar.SugarWorkspaces, roles map[string]string, levels map[string]string) []*sugarRes.SugarFoldersWorkspaceTreeNode {
 	// 创建节点映射
 	nodeMap := make(map[string]*sugarRes.SugarFoldersWorkspaceTreeNode)
 	var rootNodes []*sugarRes.SugarFoldersWorkspaceTreeNode
@@ -368,6 +554,7 @@ func (s *SugarFoldersService) buildTree(workspaces []sugar.SugarWorkspaces) []*s
 			Type:     workspace.Type,
 			ParentId: workspace.ParentId,
 			TeamId:   *workspace.TeamId,
+			Shared:   roles[*workspace.TeamId] == "",
 			Children: []*sugarRes.SugarFoldersWorkspaceTreeNode{},
 		}
 		nodeMap[*workspace.Id] = node
@@ -379,17 +566,34 @@ func (s *SugarFoldersService) buildTree(workspaces []sugar.SugarWorkspaces) []*s
 		if workspace.ParentId == nil {
 			// 根节点
 			rootNodes = append(rootNodes, node)
-		} else {
+		} else if parent, exists := nodeMap[*workspace.ParentId]; exists {
 			// 子节点
-			if parent, exists := nodeMap[*workspace.ParentId]; exists {
-				parent.Children = append(parent.Children, node)
-			}
+			parent.Children = append(parent.Children, node)
+		} else if node.Shared {
+			// 被分享的内容，其上级文件夹对用户不可见，作为根节点展示
+			rootNodes = append(rootNodes, node)
 		}
 	}
 
+	for _, node := range rootNodes {
+		s.fillActions(node, roles, levels, "")
+	}
+
 	return rootNodes
 }
 
+// fillActions 按团队角色和继承的授权级别计算节点及其子节点允许的操作
+func (s *SugarFoldersService) fillActions(node *sugarRes.SugarFoldersWorkspaceTreeNode, roles map[string]string, levels map[string]string, inherited string) {
+	level := inherited
+	if sugarGrantLevelRank[levels[node.Id]] > sugarGrantLevelRank[level] {
+		level = levels[node.Id]
+	}
+	node.Actions = workspaceAccess{role: roles[node.TeamId], level: level}.actions()
+	for _, child := range node.Children {
+		s.fillActions(child, roles, levels, level)
+	}
+}
+
 // isChildFolder 检查 childId 是否是 parentId 的子文件夹
 func (s *SugarFoldersService) isChildFolder(parentId, childId string) (bool, error) {
 	var workspace sugar.SugarWorkspaces
@@ -0,0 +1,102 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+func copyItem(t *testing.T, req sugarReq.SugarFoldersCopyRequest, userId string) *sugarRes.SugarFoldersCopyResponse {
+	t.Helper()
+	result, err := (&SugarFoldersService{}).CopyItem(context.Background(), &req, userId)
+	if err != nil {
+		t.Fatalf("复制 %s 失败: %v", req.Id, err)
+	}
+	return result
+}
+
+func TestCopyWorkspaceItems(t *testing.T) {
+	setupSharedFolder(t)
+	ctx := context.Background()
+	folders := &SugarFoldersService{}
+
+	// 原位置创建副本：文件夹连同其中的文件一起复制，重名时追加序号
+	duplicated := copyItem(t, sugarReq.SugarFoldersCopyRequest{Id: "folder-1"}, "1")
+	if !duplicated.Renamed || *duplicated.SugarWorkspace.Name != "报表 (1)" || duplicated.CopiedCount != 2 || duplicated.SugarWorkspace.ParentId != nil {
+		t.Fatalf("副本不符合预期: %+v", duplicated)
+	}
+	var source, copied sugar.SugarWorkspaces
+	global.GVA_DB.Where("id = ?", testFileId).First(&source)
+	global.GVA_DB.Where("parent_id = ?", *duplicated.SugarWorkspace.Id).First(&copied)
+	if *copied.Id == testFileId || *copied.Name != "预算" || string(copied.Content) != string(source.Content) || *copied.CreatedBy != "1" {
+		t.Fatalf("文件应被深拷贝到副本文件夹中: %+v", copied)
+	}
+
+	// 复制到指定文件夹
+	parentId := "folder-1"
+	if result := copyItem(t, sugarReq.SugarFoldersCopyRequest{Id: testFileId, TeamId: "team-1", ParentId: &parentId}, "1"); *result.SugarWorkspace.Name != "预算 (1)" {
+		t.Fatalf("同一文件夹中的副本应重命名: %+v", result)
+	}
+
+	// 不能复制到自己或自己的子目录
+	_, err := folders.CopyItem(ctx, &sugarReq.SugarFoldersCopyRequest{Id: "folder-1", TeamId: "team-1", ParentId: &parentId}, "1")
+	if err == nil || !strings.Contains(err.Error(), "自己") {
+		t.Fatalf("不应复制到自己: %v", err)
+	}
+	sub, err := folders.CreateFolder(ctx, &sugarReq.SugarFoldersCreateFolderRequest{Name: "子文件夹", TeamId: "team-1", Type: "folder", ParentId: &parentId}, "1")
+	if err != nil {
+		t.Fatalf("创建子文件夹失败: %v", err)
+	}
+	_, err = folders.CopyItem(ctx, &sugarReq.SugarFoldersCopyRequest{Id: "folder-1", TeamId: "team-1", ParentId: sub.SugarWorkspace.Id}, "1")
+	if err == nil || !strings.Contains(err.Error(), "子目录") {
+		t.Fatalf("不应复制到自己的子目录: %v", err)
+	}
+
+	// 跨团队复制：团队 1 的查看者可以把文件夹复制到自己能编辑的团队 2，但不能复制到团队 1
+	_, err = folders.CopyItem(ctx, &sugarReq.SugarFoldersCopyRequest{Id: "folder-1", TeamId: "team-1"}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	crossTeam := copyItem(t, sugarReq.SugarFoldersCopyRequest{Id: "folder-1", TeamId: "team-2"}, "12")
+	if crossTeam.Renamed || crossTeam.CopiedCount != 4 {
+		t.Fatalf("跨团队副本不符合预期: %+v", crossTeam)
+	}
+	if count := countWhere(t, &sugar.SugarWorkspaces{}, "team_id = ?", "team-2"); count != 4 {
+		t.Fatalf("副本中的全部项目都应属于目标团队，实际 %d", count)
+	}
+
+	// 没有查看权限不能复制
+	_, err = folders.CopyItem(ctx, &sugarReq.SugarFoldersCopyRequest{Id: "folder-1"}, "3")
+	expectPermissionDenied(t, err, "")
+}
+
+func TestCopyWorkspaceItemsTooDeep(t *testing.T) {
+	setupSharedFolder(t)
+
+	// 层级超过上限时拒绝复制，不生成缺少深层内容的副本
+	parentId := "folder-1"
+	for depth := 1; depth <= workspaceMaxDepth; depth++ {
+		id := fmt.Sprintf("deep-%d", depth)
+		seedTestData(t, fmt.Sprintf(`INSERT INTO sugar_workspaces (id, name, type, team_id, parent_id) VALUES ('%s', '%s', 'folder', 'team-1', '%s')`, id, id, parentId))
+		parentId = id
+	}
+	before := countWhere(t, &sugar.SugarWorkspaces{}, "deleted_at IS NULL")
+	_, err := (&SugarFoldersService{}).CopyItem(context.Background(), &sugarReq.SugarFoldersCopyRequest{Id: "folder-1"}, "1")
+	if !errors.Is(err, ErrWorkspaceTooDeep) {
+		t.Fatalf("层级过深时应拒绝复制: %v", err)
+	}
+	if count := countWhere(t, &sugar.SugarWorkspaces{}, "deleted_at IS NULL"); count != before {
+		t.Fatalf("拒绝复制时不应创建任何项目: %d -> %d", before, count)
+	}
+
+	// 去掉最深的一层后可以完整复制
+	seedTestData(t, fmt.Sprintf(`DELETE FROM sugar_workspaces WHERE id = 'deep-%d'`, workspaceMaxDepth))
+	if result := copyItem(t, sugarReq.SugarFoldersCopyRequest{Id: "folder-1"}, "1"); result.CopiedCount != workspaceMaxDepth+1 {
+		t.Fatalf("副本应包含全部内容: %+v", result)
+	}
+}
@@ -156,7 +156,7 @@ func (s *SugarFormulaAiService) ExecuteAiExplainFormula(ctx context.Context, req
 		} else {
 			llmConfig = llmService.GetDefaultLLMConfig()
 		}
-		systemPrompt = s.buildSystemPrompt(agent, userId)
+		systemPrompt = s.buildSystemPrompt(agent)
 	}
 
 	// 记录日志
@@ -215,8 +215,8 @@ func (s *SugarFormulaAiService) getAiExplainPrompt() (*sugar.SugarAgents, error)
 }
 
 // buildSystemPrompt 构建系统提示词
-func (s *SugarFormulaAiService) buildSystemPrompt(agent *sugar.SugarAgents, userId string) string {
-	enhancedPrompt := fmt.Sprintf(`你是一个专业的数据分析助手，专门负责调用数据分析工具。
+func (s *SugarFormulaAiService) buildSystemPrompt(agent *sugar.SugarAgents) string {
+	enhancedPrompt := `你是一个专业的数据分析助手，专门负责调用数据分析工具。
 
 📋 重要工作流程指导：
 1. **使用智能匿名化分析工具**：对于贡献度分析需求，请使用 smart_anonymized_analyzer 工具
@@ -226,14 +226,13 @@ func (s *SugarFormulaAiService) buildSystemPrompt(agent *sugar.SugarAgents, user
 
 🔧 工具使用指南：
 - **推荐工具**：smart_anonymized_analyzer - 完整的智能匿名化分析流程
-- 当前用户ID为 %s，调用工具时必须传递此用户ID
 - 启用数据验证（enableDataValidation: true）以确保数据质量
 
 💡 智能分析策略：
 - 优先分析数据中贡献度最高的维度组合
 - 对异常值和趋势变化提供深入洞察
 - 结合业务常识给出可操作的建议
-- 明确说明分析的局限性和数据范围`, userId)
+- 明确说明分析的局限性和数据范围`
 
 	return enhancedPrompt
 }
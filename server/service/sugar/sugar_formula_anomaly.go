@@ -0,0 +1,622 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anomaly_detector"
+	"go.uber.org/zap"
+)
+
+// 异常检测结果表中维度列之后的固定列
+const (
+	anomalyColumnPeriod    = "期间"
+	anomalyColumnType      = "类型"
+	anomalyColumnValue     = "实际值"
+	anomalyColumnExpected  = "预期值"
+	anomalyColumnDeviation = "偏离值"
+	anomalyColumnScore     = "异常分数"
+)
+
+// 异常检测结果的类型
+const (
+	anomalyTypeOutlier     = "异常值"
+	anomalyTypeChangePoint = "变点"
+)
+
+// 单次检测的取值上限，避免拆分维度或日粒度下生成过多的序列和期间
+const (
+	maxAnomalyDimensions      = 3
+	maxAnomalySeries          = 50
+	maxAnomalyPeriods         = 3660
+	maxAnomalySeasonLength    = 366
+	defaultAnomalyExplanation = 3
+	maxAnomalyExplanations    = 10
+)
+
+// defaultAnomalySeasonLengths 各期间粒度默认的季节周期
+var defaultAnomalySeasonLengths = map[string]int{
+	PeriodGranularityDay:     7,
+	PeriodGranularityWeek:    52,
+	PeriodGranularityMonth:   12,
+	PeriodGranularityQuarter: 4,
+}
+
+// periodGranularityRanks 期间粒度由细到粗的顺序
+var periodGranularityRanks = map[string]int{
+	PeriodGranularityDay:     0,
+	PeriodGranularityWeek:    1,
+	PeriodGranularityMonth:   2,
+	PeriodGranularityQuarter: 3,
+	PeriodGranularityYear:    4,
+}
+
+// anomalySeries 单个维度组合按期汇总后的时间序列
+type anomalySeries struct {
+	key        string
+	dimensions map[string]interface{}
+	periods    map[time.Time]*anomalyPeriodValue
+	total      float64
+}
+
+// anomalyPeriodValue 单期的汇总值，时点余额指标取期内最后一个日期的值
+type anomalyPeriodValue struct {
+	value  float64
+	latest time.Time
+}
+
+// ExecuteAnomalyFormula 执行 SUGAR.ANOMALY 公式
+// 按语义模型声明的日期字段和期间粒度汇总指标，对每个维度组合的时间序列检测异常值和均值变点；
+// 提供 explain 时对异常分数最高的几期执行贡献度分析，解释异常的来源
+func (s *SugarFormulaQueryService) ExecuteAnomalyFormula(ctx context.Context, req *sugarReq.SugarFormulaAnomalyRequest, userId string) (*sugarRes.SugarFormulaAnomalyResponse, error) {
+	dimensions, err := normalizeAnomalyDimensions(req.Dimensions, req.Metric)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+
+	model, err := s.getSemanticModel(ctx, req.ModelName, userId)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	dateColumn, layout, err := semantics.TimeColumn()
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	grain, err := resolveAnomalyGrain(semantics, dateColumn, req.Grain)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	config, err := buildAnomalyDetectionConfig(req.Config, grain)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	calendar := periodCalendar{grain: grain, fiscalStartMonth: semantics.FiscalStartMonth()}
+
+	filters := req.Filters
+	var firstPeriod, lastPeriod time.Time
+	if req.StartDate != "" || req.EndDate != "" {
+		dateRange := sugarReq.DateRange{Start: req.StartDate, End: req.EndDate}
+		start, end, err := parseAnomalyDateRange(dateRange)
+		if err != nil {
+			return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+		}
+		filters = withDateRange(filters, dateColumn, dateRange)
+		firstPeriod, lastPeriod = calendar.periodStart(start), calendar.periodStart(end)
+	}
+
+	groupBy := append([]string{dateColumn}, dimensions...)
+	data, err := s.ExecuteGetFormula(ctx, &sugarReq.SugarFormulaGetRequest{
+		ModelName:     req.ModelName,
+		ReturnColumns: append([]string{req.Metric}, groupBy...),
+		GroupBy:       groupBy,
+		Filters:       filters,
+	}, userId)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse("获取数据失败: " + err.Error()), nil
+	}
+	if data.Error != "" {
+		return sugarRes.NewAnomalyErrorResponse("获取数据失败: " + data.Error), nil
+	}
+	if len(data.Results) == 0 {
+		return sugarRes.NewAnomalyErrorResponse("检测区间内没有数据"), nil
+	}
+
+	balance := semantics.MetricKind(req.Metric) == MetricKindBalance
+	seriesList, err := groupAnomalySeries(data.Results, req.Metric, dateColumn, layout, dimensions, calendar, balance)
+	if err != nil {
+		return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+	}
+	if firstPeriod.IsZero() {
+		firstPeriod, lastPeriod = anomalyPeriodBounds(seriesList)
+	}
+	periods := make([]time.Time, 0)
+	for period := firstPeriod; !period.After(lastPeriod); period = calendar.addPeriods(period, 1) {
+		if len(periods) == maxAnomalyPeriods {
+			return sugarRes.NewAnomalyErrorResponse(fmt.Sprintf("检测区间超过 %d 期，请缩小日期范围或使用更粗的期间粒度", maxAnomalyPeriods)), nil
+		}
+		periods = append(periods, period)
+	}
+
+	result := &sugarRes.SugarFormulaAnomalyResponse{
+		DateColumn: dateColumn,
+		Grain:      grain,
+		Config: sugarRes.AnomalyDetectionSettings{
+			Threshold:          config.Threshold,
+			SeasonLength:       config.SeasonLength,
+			TrendWindow:        config.TrendWindow,
+			ChangePointPenalty: config.ChangePointPenalty,
+			MinSegmentLength:   config.MinSegmentLength,
+			MaxChangePoints:    config.MaxChangePoints,
+		},
+		Series: []sugarRes.AnomalySeries{},
+	}
+	if len(seriesList) > maxAnomalySeries {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("维度组合共 %d 个，只检测指标合计绝对值最大的 %d 个", len(seriesList), maxAnomalySeries))
+		seriesList = seriesList[:maxAnomalySeries]
+	}
+
+	var detections []*anomaly_detector.Result
+	var detectedSeries []*anomalySeries
+	var seriesPeriods [][]time.Time
+	for _, series := range seriesList {
+		values, valuePeriods := series.values(periods, balance)
+		detection, err := anomaly_detector.Detect(values, config)
+		if errors.Is(err, anomaly_detector.ErrSeriesTooShort) {
+			result.Warnings = append(result.Warnings, describeAnomalySeries(series)+"时间序列少于4期，未检测")
+			continue
+		}
+		if err != nil {
+			return sugarRes.NewAnomalyErrorResponse(err.Error()), nil
+		}
+		detections = append(detections, detection)
+		detectedSeries = append(detectedSeries, series)
+		seriesPeriods = append(seriesPeriods, valuePeriods)
+		result.Series = append(result.Series, buildAnomalySeriesResponse(series, detection, valuePeriods, calendar))
+	}
+
+	result.Columns = append(append([]string{}, dimensions...),
+		anomalyColumnPeriod, anomalyColumnType, anomalyColumnValue, anomalyColumnExpected, anomalyColumnDeviation, anomalyColumnScore)
+	result.Results = buildAnomalyRows(result.Series, dimensions)
+	result.Count = len(result.Results)
+
+	if req.Explain != nil {
+		explanations, warnings := s.explainAnomalies(ctx, req, model.ParameterConfig, dateColumn, dimensions, detectedSeries, detections, seriesPeriods, calendar, userId)
+		result.Explanations = explanations
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+
+	global.GVA_LOG.Info("异常检测公式执行完成",
+		zap.String("modelName", req.ModelName),
+		zap.String("metric", req.Metric),
+		zap.String("grain", grain),
+		zap.Strings("dimensions", dimensions),
+		zap.Int("series", len(result.Series)),
+		zap.Int("periods", len(periods)),
+		zap.Int("findings", result.Count),
+		zap.String("userId", userId))
+
+	return result, nil
+}
+
+// normalizeAnomalyDimensions 去除空白和重复的拆分维度，并检查维度数量
+func normalizeAnomalyDimensions(dimensions []string, metric string) ([]string, error) {
+	var result []string
+	for _, dimension := range dimensions {
+		dimension = strings.TrimSpace(dimension)
+		if dimension == "" || containsString(result, dimension) {
+			continue
+		}
+		if dimension == metric {
+			return nil, fmt.Errorf("检测指标 %s 不能同时作为拆分维度", metric)
+		}
+		result = append(result, dimension)
+	}
+	if len(result) > maxAnomalyDimensions {
+		return nil, fmt.Errorf("拆分维度最多 %d 个，当前为 %d 个", maxAnomalyDimensions, len(result))
+	}
+	return result, nil
+}
+
+// resolveAnomalyGrain 确定检测使用的期间粒度：请求指定 > 模型声明 > 月
+// 以期间维度作为日期字段时，粒度不能细于模型声明的期间粒度
+func resolveAnomalyGrain(semantics *AnalysisSemantics, dateColumn, grain string) (string, error) {
+	if grain == "" {
+		grain = semantics.Granularity
+	}
+	if grain == "" {
+		grain = PeriodGranularityMonth
+	}
+	if _, ok := periodGranularityNames[grain]; !ok {
+		return "", fmt.Errorf("不支持的期间粒度: %s", grain)
+	}
+	if dateColumn == semantics.PeriodColumn && semantics.Granularity != "" &&
+		periodGranularityRanks[grain] < periodGranularityRanks[semantics.Granularity] {
+		return "", fmt.Errorf("期间粒度 %s 不能细于模型声明的期间粒度 %s", grain, semantics.Granularity)
+	}
+	return grain, nil
+}
+
+// buildAnomalyDetectionConfig 以默认配置为基础合并请求中的配置项，季节周期默认按粒度确定
+func buildAnomalyDetectionConfig(override *sugarReq.AnomalyDetectionConfig, grain string) (*anomaly_detector.Config, error) {
+	config := anomaly_detector.DefaultConfig()
+	config.SeasonLength = defaultAnomalySeasonLengths[grain]
+	if override != nil {
+		if override.Threshold != nil {
+			config.Threshold = *override.Threshold
+		}
+		if override.SeasonLength != nil {
+			config.SeasonLength = *override.SeasonLength
+		}
+		if override.TrendWindow != nil {
+			config.TrendWindow = *override.TrendWindow
+		}
+		if override.ChangePointPenalty != nil {
+			config.ChangePointPenalty = *override.ChangePointPenalty
+		}
+		if override.MinSegmentLength != nil {
+			config.MinSegmentLength = *override.MinSegmentLength
+		}
+		if override.MaxChangePoints != nil {
+			config.MaxChangePoints = *override.MaxChangePoints
+		}
+	}
+	if config.SeasonLength > maxAnomalySeasonLength {
+		return nil, fmt.Errorf("seasonLength 不能超过 %d", maxAnomalySeasonLength)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseAnomalyDateRange 解析检测区间，起止日期需同时提供
+func parseAnomalyDateRange(dateRange sugarReq.DateRange) (time.Time, time.Time, error) {
+	if dateRange.Start == "" || dateRange.End == "" {
+		return time.Time{}, time.Time{}, errors.New("检测区间的起始日期和结束日期需同时提供")
+	}
+	start, err := time.Parse(dateLayout, dateRange.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("起始日期格式错误，应为 YYYY-MM-DD: %s", dateRange.Start)
+	}
+	end, err := time.Parse(dateLayout, dateRange.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("结束日期格式错误，应为 YYYY-MM-DD: %s", dateRange.End)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("结束日期早于起始日期: %s ~ %s", dateRange.Start, dateRange.End)
+	}
+	return start, end, nil
+}
+
+// groupAnomalySeries 按维度组合和期间汇总查询结果，序列按指标合计绝对值降序、维度值升序排列
+// 期间发生额指标按期求和；时点余额指标取期内最后一个日期的值
+func groupAnomalySeries(rows []map[string]interface{}, metric, dateColumn, layout string, dimensions []string, calendar periodCalendar, balance bool) ([]*anomalySeries, error) {
+	processor := NewDataProcessor()
+	seriesByKey := make(map[string]*anomalySeries)
+	for _, row := range rows {
+		day, err := parseAnomalyDate(row[dateColumn], layout)
+		if err != nil {
+			return nil, fmt.Errorf("日期字段 %s 的值无法解析: %w", dateColumn, err)
+		}
+		values := make([]string, len(dimensions))
+		dimensionValues := make(map[string]interface{}, len(dimensions))
+		for i, dimension := range dimensions {
+			values[i] = fmt.Sprintf("%v", row[dimension])
+			dimensionValues[dimension] = row[dimension]
+		}
+		key := strings.Join(values, "|")
+		series, exists := seriesByKey[key]
+		if !exists {
+			series = &anomalySeries{key: key, dimensions: dimensionValues, periods: make(map[time.Time]*anomalyPeriodValue)}
+			seriesByKey[key] = series
+		}
+
+		value := processor.extractFloatValue(row[metric])
+		period := calendar.periodStart(day)
+		current, exists := series.periods[period]
+		switch {
+		case !exists:
+			series.periods[period] = &anomalyPeriodValue{value: value, latest: day}
+		case !balance || day.Equal(current.latest):
+			current.value += value
+		case day.After(current.latest):
+			current.value, current.latest = value, day
+		}
+		series.total += value
+	}
+
+	seriesList := make([]*anomalySeries, 0, len(seriesByKey))
+	for _, series := range seriesByKey {
+		seriesList = append(seriesList, series)
+	}
+	sort.Slice(seriesList, func(i, j int) bool {
+		if math.Abs(seriesList[i].total) != math.Abs(seriesList[j].total) {
+			return math.Abs(seriesList[i].total) > math.Abs(seriesList[j].total)
+		}
+		return seriesList[i].key < seriesList[j].key
+	})
+	return seriesList, nil
+}
+
+// parseAnomalyDate 解析日期字段的值：数据库日期类型、按模型声明格式的字符串，或带时间的日期字符串
+func parseAnomalyDate(value interface{}, layout string) (time.Time, error) {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC), nil
+	case []byte:
+		text = string(v)
+	case nil:
+		return time.Time{}, errors.New("日期为空")
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+	text = strings.TrimSpace(text)
+	for _, candidate := range []string{layout, dateLayout, time.RFC3339, "2006-01-02 15:04:05"} {
+		if day, err := time.Parse(candidate, text); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s 不符合格式 %s", text, layout)
+}
+
+// anomalyPeriodBounds 全部序列中最早和最晚的期间
+func anomalyPeriodBounds(seriesList []*anomalySeries) (time.Time, time.Time) {
+	var first, last time.Time
+	for _, series := range seriesList {
+		for period := range series.periods {
+			if first.IsZero() || period.Before(first) {
+				first = period
+			}
+			if period.After(last) {
+				last = period
+			}
+		}
+	}
+	return first, last
+}
+
+// values 按期间顺序展开序列：期间发生额指标缺失的期记为0；
+// 时点余额指标从第一个有值的期开始，缺失的期沿用上一期的余额
+func (a *anomalySeries) values(periods []time.Time, balance bool) ([]float64, []time.Time) {
+	var values []float64
+	var valuePeriods []time.Time
+	for _, period := range periods {
+		current, exists := a.periods[period]
+		switch {
+		case exists:
+			values = append(values, current.value)
+		case !balance:
+			values = append(values, 0)
+		case len(values) > 0:
+			values = append(values, values[len(values)-1])
+		default:
+			continue
+		}
+		valuePeriods = append(valuePeriods, period)
+	}
+	return values, valuePeriods
+}
+
+// describeAnomalySeries 警告信息中序列的前缀，未拆分维度时为空
+func describeAnomalySeries(series *anomalySeries) string {
+	if len(series.dimensions) == 0 {
+		return ""
+	}
+	return "维度组合 " + series.key + " 的"
+}
+
+// anomalyPeriodLabel 期间标签：日、周为起始日期，月为 2024-03，自然年口径的季、年为 2024Q1、2024，财年口径的季、年为起止月份
+func anomalyPeriodLabel(calendar periodCalendar, start time.Time) string {
+	switch calendar.grain {
+	case PeriodGranularityMonth:
+		return start.Format("2006-01")
+	case PeriodGranularityQuarter, PeriodGranularityYear:
+		if calendar.fiscalStartMonth != 1 {
+			return start.Format("2006-01") + "~" + calendar.periodEnd(start).Format("2006-01")
+		}
+		if calendar.grain == PeriodGranularityYear {
+			return start.Format("2006")
+		}
+		return fmt.Sprintf("%dQ%d", start.Year(), (int(start.Month())-1)/3+1)
+	default:
+		return start.Format(dateLayout)
+	}
+}
+
+// anomalyPeriodRange 期间的起止日期
+func anomalyPeriodRange(calendar periodCalendar, start time.Time) sugarRes.PeriodRange {
+	return sugarRes.PeriodRange{Start: start.Format(dateLayout), End: calendar.periodEnd(start).Format(dateLayout)}
+}
+
+// buildAnomalySeriesResponse 将检测结果转换为响应中的序列
+func buildAnomalySeriesResponse(series *anomalySeries, detection *anomaly_detector.Result, periods []time.Time, calendar periodCalendar) sugarRes.AnomalySeries {
+	response := sugarRes.AnomalySeries{
+		Method:       detection.Method,
+		SeasonLength: detection.SeasonLength,
+		Points:       make([]sugarRes.AnomalyPoint, 0, len(detection.Points)),
+		ChangePoints: make([]sugarRes.AnomalyChangePoint, 0, len(detection.ChangePoints)),
+	}
+	if len(series.dimensions) > 0 {
+		response.Dimensions = series.dimensions
+	}
+	for _, point := range detection.Points {
+		response.Points = append(response.Points, sugarRes.AnomalyPoint{
+			Period:    anomalyPeriodLabel(calendar, periods[point.Index]),
+			Range:     anomalyPeriodRange(calendar, periods[point.Index]),
+			Value:     point.Value,
+			Expected:  point.Expected,
+			Score:     point.Score,
+			IsAnomaly: point.IsAnomaly,
+		})
+	}
+	for _, changePoint := range detection.ChangePoints {
+		response.ChangePoints = append(response.ChangePoints, sugarRes.AnomalyChangePoint{
+			Period:     anomalyPeriodLabel(calendar, periods[changePoint.Index]),
+			Range:      anomalyPeriodRange(calendar, periods[changePoint.Index]),
+			BeforeMean: changePoint.BeforeMean,
+			AfterMean:  changePoint.AfterMean,
+			Shift:      changePoint.Shift,
+			Score:      changePoint.Score,
+		})
+	}
+	return response
+}
+
+// buildAnomalyRows 生成结果表：按序列顺序列出各序列的异常值和变点，同一序列内按期间排序，同一期间异常值在前
+func buildAnomalyRows(seriesList []sugarRes.AnomalySeries, dimensions []string) []map[string]interface{} {
+	type periodRow struct {
+		start string
+		row   map[string]interface{}
+	}
+	rows := make([]map[string]interface{}, 0)
+	for _, series := range seriesList {
+		var seriesRows []periodRow
+		newRow := func(period sugarRes.PeriodRange, label, kind string, value, expected, score float64) periodRow {
+			row := make(map[string]interface{}, len(dimensions)+6)
+			for _, dimension := range dimensions {
+				row[dimension] = series.Dimensions[dimension]
+			}
+			row[anomalyColumnPeriod] = label
+			row[anomalyColumnType] = kind
+			row[anomalyColumnValue] = value
+			row[anomalyColumnExpected] = expected
+			row[anomalyColumnDeviation] = value - expected
+			row[anomalyColumnScore] = score
+			return periodRow{start: period.Start, row: row}
+		}
+		for _, point := range series.Points {
+			if point.IsAnomaly {
+				seriesRows = append(seriesRows, newRow(point.Range, point.Period, anomalyTypeOutlier, point.Value, point.Expected, point.Score))
+			}
+		}
+		for _, changePoint := range series.ChangePoints {
+			seriesRows = append(seriesRows, newRow(changePoint.Range, changePoint.Period, anomalyTypeChangePoint, changePoint.AfterMean, changePoint.BeforeMean, changePoint.Score))
+		}
+		sort.SliceStable(seriesRows, func(i, j int) bool {
+			return seriesRows[i].start < seriesRows[j].start
+		})
+		for _, seriesRow := range seriesRows {
+			rows = append(rows, seriesRow.row)
+		}
+	}
+	return rows
+}
+
+// anomalyCandidate 待解释的异常
+type anomalyCandidate struct {
+	seriesIndex int
+	pointIndex  int
+	score       float64
+}
+
+// explainAnomalies 对异常分数绝对值最大的几期执行贡献度分析
+// 本期为异常期；基期为上一周期的同期（做了季节分解时）或上一期。拆分维度为筛选参数时，贡献度分析限定在异常所在的维度组合内
+func (s *SugarFormulaQueryService) explainAnomalies(ctx context.Context, req *sugarReq.SugarFormulaAnomalyRequest, rawParameterConfig []byte, dateColumn string, dimensions []string, seriesList []*anomalySeries, detections []*anomaly_detector.Result, seriesPeriods [][]time.Time, calendar periodCalendar, userId string) ([]sugarRes.AnomalyExplanation, []string) {
+	var warnings []string
+	limit := req.Explain.MaxAnomalies
+	if limit <= 0 {
+		limit = defaultAnomalyExplanation
+	}
+	if limit > maxAnomalyExplanations {
+		limit = maxAnomalyExplanations
+		warnings = append(warnings, fmt.Sprintf("异常解释最多 %d 个", maxAnomalyExplanations))
+	}
+
+	var candidates []anomalyCandidate
+	for seriesIndex, detection := range detections {
+		for _, point := range detection.Anomalies() {
+			candidates = append(candidates, anomalyCandidate{seriesIndex: seriesIndex, pointIndex: point.Index, score: point.Score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(candidates[i].score) > math.Abs(candidates[j].score)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	var parameterConfig map[string]map[string]interface{}
+	if len(rawParameterConfig) > 0 {
+		if err := json.Unmarshal(rawParameterConfig, &parameterConfig); err != nil {
+			return nil, append(warnings, "解析筛选参数配置失败，未执行异常解释")
+		}
+	}
+	var unfilterable []string
+	for _, dimension := range dimensions {
+		if _, exists := parameterConfig[dimension]; !exists {
+			unfilterable = append(unfilterable, dimension)
+		}
+	}
+	if len(candidates) > 0 && len(unfilterable) > 0 {
+		warnings = append(warnings, fmt.Sprintf("拆分维度 %s 不是模型的筛选参数，异常解释按全部数据计算", strings.Join(unfilterable, "、")))
+	}
+
+	explanations := make([]sugarRes.AnomalyExplanation, 0, len(candidates))
+	for _, candidate := range candidates {
+		series := seriesList[candidate.seriesIndex]
+		periods := seriesPeriods[candidate.seriesIndex]
+		baseIndex := candidate.pointIndex - 1
+		if seasonLength := detections[candidate.seriesIndex].SeasonLength; seasonLength > 0 && candidate.pointIndex >= seasonLength {
+			baseIndex = candidate.pointIndex - seasonLength
+		}
+		period := anomalyPeriodLabel(calendar, periods[candidate.pointIndex])
+		if baseIndex < 0 {
+			warnings = append(warnings, fmt.Sprintf("%s期间 %s 没有可对比的基期，未解释", describeAnomalySeries(series), period))
+			continue
+		}
+
+		filters := make(map[string]interface{}, len(req.Filters)+len(series.dimensions))
+		for key, value := range req.Filters {
+			filters[key] = value
+		}
+		for dimension, value := range series.dimensions {
+			if !containsString(unfilterable, dimension) {
+				filters[dimension] = value
+			}
+		}
+		current := anomalyPeriodRange(calendar, periods[candidate.pointIndex])
+		base := anomalyPeriodRange(calendar, periods[baseIndex])
+		contribution, err := s.ExecuteContributionFormula(ctx, &sugarReq.SugarFormulaContributionRequest{
+			ModelName:            req.ModelName,
+			TargetMetric:         req.Metric,
+			Dimensions:           req.Explain.Dimensions,
+			CurrentPeriodFilters: withDateRange(filters, dateColumn, sugarReq.DateRange{Start: current.Start, End: current.End}),
+			BasePeriodFilters:    withDateRange(filters, dateColumn, sugarReq.DateRange{Start: base.Start, End: base.End}),
+			Config:               req.Explain.Config,
+		}, userId)
+		if err == nil && contribution.Error != "" {
+			err = errors.New(contribution.Error)
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s期间 %s 的贡献度分析失败: %v", describeAnomalySeries(series), period, err))
+			continue
+		}
+
+		explanation := sugarRes.AnomalyExplanation{
+			Period:       period,
+			Score:        candidate.score,
+			Current:      current,
+			Base:         base,
+			Contribution: contribution,
+		}
+		if len(series.dimensions) > 0 {
+			explanation.Dimensions = series.dimensions
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations, warnings
+}
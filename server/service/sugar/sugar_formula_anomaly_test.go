@@ -0,0 +1,119 @@
+package sugar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// anomalyStoreRevenue 各门店的月收入：一店7月突增，其余各月在基准值上小幅波动
+func anomalyStoreRevenue(store string, month int) float64 {
+	base := map[string]float64{"一店": 100, "二店": 50, "三店": 80}[store]
+	if store == "一店" && month == 7 {
+		return 300
+	}
+	return base + float64([]int{0, 1, -1, 2, 0, -2}[month%6])
+}
+
+// setupAnomalyDB 初始化异常检测测试数据：2024年每月一行，北京两家门店、上海一家门店，城市是筛选参数，门店不是
+func setupAnomalyDB(t *testing.T) {
+	t.Helper()
+	db := setupTestDB(t)
+	var rows []string
+	for month := 1; month <= 12; month++ {
+		for _, store := range [][2]string{{"北京", "一店"}, {"北京", "二店"}, {"上海", "三店"}} {
+			rows = append(rows, fmt.Sprintf("('2024-%02d-15', '%s', '%s', %v)", month, store[0], store[1], anomalyStoreRevenue(store[1], month)))
+		}
+	}
+	seedTestData(t,
+		`CREATE TABLE monthly_sales (day TEXT, city TEXT, store TEXT, revenue REAL)`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor')`,
+		`INSERT INTO monthly_sales VALUES `+strings.Join(rows, ", "),
+	)
+	id, name, teamId, table := "model-anomaly", "月度销售", "team-1", "monthly_sales"
+	model := sugar.SugarSemanticModels{
+		Id:                      &id,
+		Name:                    &name,
+		TeamId:                  &teamId,
+		SourceTableName:         &table,
+		ParameterConfig:         []byte(`{"日期": {"column": "day", "operator": "="}, "城市": {"column": "city", "operator": "="}}`),
+		ReturnableColumnsConfig: []byte(`{"日期": {"column": "day", "type": "dimension"}, "城市": {"column": "city", "type": "dimension"}, "门店": {"column": "store", "type": "dimension"}, "收入": {"column": "revenue", "type": "metric"}}`),
+		AnalysisSemantics:       []byte(`{"dateColumn": "日期"}`),
+	}
+	if err := db.Create(&model).Error; err != nil {
+		t.Fatalf("创建语义模型失败: %v", err)
+	}
+}
+
+func executeAnomalyFormula(t *testing.T, dimensions []string, seasonLength int) *sugarRes.SugarFormulaAnomalyResponse {
+	t.Helper()
+	result, err := (&SugarFormulaQueryService{}).ExecuteAnomalyFormula(context.Background(), &sugarReq.SugarFormulaAnomalyRequest{
+		ModelName:  "月度销售",
+		Metric:     "收入",
+		Grain:      PeriodGranularityMonth,
+		Dimensions: dimensions,
+		Config:     &sugarReq.AnomalyDetectionConfig{SeasonLength: &seasonLength},
+		Explain:    &sugarReq.AnomalyExplainConfig{Dimensions: []string{"门店"}, MaxAnomalies: 1},
+	}, "1")
+	if err != nil {
+		t.Fatalf("执行异常检测公式失败: %v", err)
+	}
+	if result.Error != "" {
+		t.Fatalf("异常检测公式返回错误: %s", result.Error)
+	}
+	if len(result.Explanations) != 1 {
+		t.Fatalf("应解释一个异常: %+v %v", result.Explanations, result.Warnings)
+	}
+	return result
+}
+
+// monthlyRevenue 按城市汇总某月的收入，城市为空时汇总全部门店
+func monthlyRevenue(month int, city string) float64 {
+	var total float64
+	for _, store := range [][2]string{{"北京", "一店"}, {"北京", "二店"}, {"上海", "三店"}} {
+		if city == "" || store[0] == city {
+			total += anomalyStoreRevenue(store[1], month)
+		}
+	}
+	return total
+}
+
+func TestExplainAnomaliesPeriodFilters(t *testing.T) {
+	setupAnomalyDB(t)
+
+	// 本期为异常所在月份，基期为上一期，贡献度分析限定在异常所在的城市内
+	result := executeAnomalyFormula(t, []string{"城市"}, 0)
+	explanation := result.Explanations[0]
+	if explanation.Period != "2024-07" || explanation.Dimensions["城市"] != "北京" {
+		t.Fatalf("应解释北京7月的异常: %+v", explanation)
+	}
+	if explanation.Current != (sugarRes.PeriodRange{Start: "2024-07-01", End: "2024-07-31"}) || explanation.Base != (sugarRes.PeriodRange{Start: "2024-06-01", End: "2024-06-30"}) {
+		t.Fatalf("本期或基期区间不符合预期: %+v %+v", explanation.Current, explanation.Base)
+	}
+	contribution := explanation.Contribution
+	if contribution.CurrentTotal != monthlyRevenue(7, "北京") || contribution.BaseTotal != monthlyRevenue(6, "北京") {
+		t.Fatalf("贡献度分析的合计 = (%v, %v)，期望北京7月和6月 (%v, %v)", contribution.CurrentTotal, contribution.BaseTotal, monthlyRevenue(7, "北京"), monthlyRevenue(6, "北京"))
+	}
+
+	// 做季节分解时基期为上一周期的同期
+	result = executeAnomalyFormula(t, []string{"城市"}, 3)
+	explanation = result.Explanations[0]
+	if explanation.Base != (sugarRes.PeriodRange{Start: "2024-04-01", End: "2024-04-30"}) || explanation.Contribution.BaseTotal != monthlyRevenue(4, "北京") {
+		t.Fatalf("季节分解时基期应为上一周期同期: %+v %v", explanation.Base, explanation.Contribution.BaseTotal)
+	}
+
+	// 拆分维度不是筛选参数时按全部数据计算并给出提示
+	result = executeAnomalyFormula(t, []string{"门店"}, 0)
+	explanation = result.Explanations[0]
+	if explanation.Dimensions["门店"] != "一店" || explanation.Contribution.CurrentTotal != monthlyRevenue(7, "") || explanation.Contribution.BaseTotal != monthlyRevenue(6, "") {
+		t.Fatalf("无法筛选的拆分维度应按全部数据计算: %+v", explanation)
+	}
+	if !strings.Contains(strings.Join(result.Warnings, "\n"), "门店 不是模型的筛选参数") {
+		t.Fatalf("应提示拆分维度无法筛选: %v", result.Warnings)
+	}
+}
@@ -0,0 +1,385 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+	"go.uber.org/zap"
+)
+
+// 贡献度分析结果表中维度列之后的固定列
+const (
+	contributionColumnCurrent   = "本期值"
+	contributionColumnBase      = "基期值"
+	contributionColumnChange    = "变化值"
+	contributionColumnPercent   = "贡献度"
+	contributionColumnDirection = "驱动方向"
+	// contributionColumnRatioShare 结构/比率分解时各组合对整体比率变化的贡献（结构效应 + 比率效应），
+	// 与组合自身比率的变化值不同，单独成列
+	contributionColumnRatioShare = "整体比率变化贡献"
+)
+
+// 分析配置的取值上限，避免单次请求生成过多的维度组合
+const (
+	maxContributionDimensions   = 6
+	maxContributionDrillLevels  = 6
+	maxContributionCombinations = 200
+)
+
+// ExecuteContributionFormula 执行 SUGAR.CONTRIBUTION 公式
+// 直接调用贡献度计算与智能下钻引擎，不经过大模型，相同输入得到相同输出
+func (s *SugarFormulaQueryService) ExecuteContributionFormula(ctx context.Context, req *sugarReq.SugarFormulaContributionRequest, userId string) (*sugarRes.SugarFormulaContributionResponse, error) {
+	dimensions, err := normalizeContributionDimensions(req.Dimensions, req.TargetMetric)
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse(err.Error()), nil
+	}
+	config, err := buildContributionAnalysisConfig(req.Config)
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse(err.Error()), nil
+	}
+	if containsString(config.FactorMetrics, req.TargetMetric) {
+		return sugarRes.NewContributionErrorResponse("因素指标不能包含目标指标本身"), nil
+	}
+
+	dataProcessor := NewDataProcessor()
+	currentPeriodFilters, basePeriodFilters := req.CurrentPeriodFilters, req.BasePeriodFilters
+	var windows *TimeWindows
+	if req.TimeIntelligence != nil {
+		currentPeriodFilters, basePeriodFilters, windows, err = dataProcessor.ResolvePeriodFilters(ctx, req.ModelName, userId, req.TimeIntelligence, currentPeriodFilters, basePeriodFilters)
+		if err != nil {
+			return sugarRes.NewContributionErrorResponse(err.Error()), nil
+		}
+	}
+
+	semantics, err := dataProcessor.GetAnalysisSemantics(ctx, req.ModelName, userId)
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse(err.Error()), nil
+	}
+
+	currentData, baseData, err := dataProcessor.FetchDataConcurrently(ctx, req.ModelName, req.TargetMetric, currentPeriodFilters, basePeriodFilters, dimensions, userId)
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse("获取数据失败: " + err.Error()), nil
+	}
+
+	analyzer := NewContributionAnalyzer(nil)
+	contributions, decomposition, err := analyzer.calculateDecomposedContributions(ctx, config, req.ModelName, req.TargetMetric, currentPeriodFilters, basePeriodFilters, dimensions, userId, currentData, baseData)
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse("计算贡献度失败: " + err.Error()), nil
+	}
+
+	var balanceComparison *advanced_contribution_analyzer.BalanceComparison
+	if pair := semantics.BalancePairFor(req.TargetMetric); pair != nil {
+		balanceComparison = &advanced_contribution_analyzer.BalanceComparison{OpeningMetric: pair.Opening, ClosingMetric: pair.Closing}
+	}
+	advancedService := advanced_contribution_analyzer.NewAdvancedContributionService(config)
+	if advancedService == nil {
+		return sugarRes.NewContributionErrorResponse("贡献度分析服务初始化失败"), nil
+	}
+	analysisResponse, err := advancedService.PerformAdvancedAnalysis(ctx, &advanced_contribution_analyzer.AnalysisRequest{
+		ModelName:            req.ModelName,
+		Metric:               req.TargetMetric,
+		Dimensions:           dimensions,
+		CurrentPeriodFilters: currentPeriodFilters,
+		BasePeriodFilters:    basePeriodFilters,
+		BalanceComparison:    balanceComparison,
+		RawContributions:     analyzer.convertToAdvancedContributions(contributions),
+		TotalChange:          analyzer.calculateTotalChange(contributions),
+	})
+	if err != nil {
+		return sugarRes.NewContributionErrorResponse(err.Error()), nil
+	}
+
+	result := buildContributionResponse(analysisResponse, contributions, config, decomposition)
+	if windows != nil {
+		result.TimeIntelligence = windows.Info()
+	}
+
+	global.GVA_LOG.Info("贡献度分析公式执行完成",
+		zap.String("modelName", req.ModelName),
+		zap.String("targetMetric", req.TargetMetric),
+		zap.Strings("dimensions", dimensions),
+		zap.Int("levels", len(result.Levels)),
+		zap.Int("topCombinations", result.Count),
+		zap.String("userId", userId))
+
+	return result, nil
+}
+
+// normalizeContributionDimensions 去除空白和重复的候选维度，并检查维度数量
+func normalizeContributionDimensions(dimensions []string, targetMetric string) ([]string, error) {
+	var result []string
+	for _, dimension := range dimensions {
+		dimension = strings.TrimSpace(dimension)
+		if dimension == "" || containsString(result, dimension) {
+			continue
+		}
+		if dimension == targetMetric {
+			return nil, fmt.Errorf("目标指标 %s 不能同时作为分析维度", targetMetric)
+		}
+		result = append(result, dimension)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("至少需要一个分析维度")
+	}
+	if len(result) > maxContributionDimensions {
+		return nil, fmt.Errorf("分析维度最多 %d 个，当前为 %d 个", maxContributionDimensions, len(result))
+	}
+	return result, nil
+}
+
+// buildContributionAnalysisConfig 以默认配置为基础合并请求中的配置项
+func buildContributionAnalysisConfig(override *sugarReq.ContributionAnalysisConfig) (*advanced_contribution_analyzer.AnalysisConfig, error) {
+	config := advanced_contribution_analyzer.DefaultAnalysisConfig()
+	if override == nil {
+		return config, nil
+	}
+	if override.DiscriminationThreshold != nil {
+		config.DiscriminationThreshold = *override.DiscriminationThreshold
+	}
+	if override.MinContributionThreshold != nil {
+		config.MinContributionThreshold = *override.MinContributionThreshold
+	}
+	if override.MaxDrillDownLevels != nil {
+		config.MaxDrillDownLevels = *override.MaxDrillDownLevels
+	}
+	if override.TopCombinationsCount != nil {
+		config.TopCombinationsCount = *override.TopCombinationsCount
+	}
+	if override.MinTopCombinations != nil {
+		config.MinTopCombinations = *override.MinTopCombinations
+	}
+	if override.EnableSmartStop != nil {
+		config.EnableSmartStop = *override.EnableSmartStop
+	}
+	if override.DiscriminationImprovementThreshold != nil {
+		config.DiscriminationImprovementThreshold = *override.DiscriminationImprovementThreshold
+	}
+	if override.DecompositionMethod != "" {
+		config.DecompositionMethod = override.DecompositionMethod
+	}
+	config.FactorMetrics = override.FactorMetrics
+	config.RatioNumerator = override.RatioNumerator
+	config.RatioDenominator = override.RatioDenominator
+	if err := config.ValidateDecomposition(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case config.DiscriminationThreshold < 0:
+		return nil, errors.New("discriminationThreshold 不能为负数")
+	case config.MinContributionThreshold < 0 || config.MinContributionThreshold > 100:
+		return nil, errors.New("minContributionThreshold 必须在 0-100 之间")
+	case config.MaxDrillDownLevels < 1 || config.MaxDrillDownLevels > maxContributionDrillLevels:
+		return nil, fmt.Errorf("maxDrillDownLevels 必须在 1-%d 之间", maxContributionDrillLevels)
+	case config.TopCombinationsCount < 1 || config.TopCombinationsCount > maxContributionCombinations:
+		return nil, fmt.Errorf("topCombinationsCount 必须在 1-%d 之间", maxContributionCombinations)
+	case config.MinTopCombinations < 0 || config.MinTopCombinations > config.TopCombinationsCount:
+		return nil, errors.New("minTopCombinations 必须在 0 到 topCombinationsCount 之间")
+	case config.DiscriminationImprovementThreshold < 0:
+		return nil, errors.New("discriminationImprovementThreshold 不能为负数")
+	}
+	return config, nil
+}
+
+// buildContributionResponse 将下钻分析结果整理为表格形式的响应
+// decomposition 非空时，合计值取分解结果（mix_rate 为整体比率），结果表追加各效应列
+func buildContributionResponse(analysisResponse *advanced_contribution_analyzer.AnalysisResponse, contributions []ContributionItem, config *advanced_contribution_analyzer.AnalysisConfig, decomposition *advanced_contribution_analyzer.DecompositionResult) *sugarRes.SugarFormulaContributionResponse {
+	drillDown := analysisResponse.DrillDownResult
+	result := &sugarRes.SugarFormulaContributionResponse{
+		DrillDownPath: drillDown.DrillDownPath,
+		Summary:       analysisResponse.EnhancedSummary,
+		Warnings:      analysisResponse.Warnings,
+		Config: sugarRes.ContributionAnalysisSettings{
+			DiscriminationThreshold:            config.DiscriminationThreshold,
+			MinContributionThreshold:           config.MinContributionThreshold,
+			MaxDrillDownLevels:                 config.MaxDrillDownLevels,
+			TopCombinationsCount:               config.TopCombinationsCount,
+			MinTopCombinations:                 config.MinTopCombinations,
+			EnableSmartStop:                    config.EnableSmartStop,
+			DiscriminationImprovementThreshold: config.DiscriminationImprovementThreshold,
+			DecompositionMethod:                config.DecompositionMethod,
+			FactorMetrics:                      config.FactorMetrics,
+			RatioNumerator:                     config.RatioNumerator,
+			RatioDenominator:                   config.RatioDenominator,
+		},
+	}
+	if analysisResponse.AnalysisMetrics != nil {
+		result.StopReason = analysisResponse.AnalysisMetrics.StopReason
+	}
+	var effectNames []string
+	fixedColumns := []string{contributionColumnCurrent, contributionColumnBase, contributionColumnChange, contributionColumnPercent, contributionColumnDirection}
+	if decomposition != nil {
+		result.CurrentTotal = decomposition.CurrentValue
+		result.BaseTotal = decomposition.BaseValue
+		effectNames = decomposition.EffectNames
+		result.Decomposition = &sugarRes.ContributionDecomposition{
+			Method:       decomposition.Method,
+			BaseValue:    decomposition.BaseValue,
+			CurrentValue: decomposition.CurrentValue,
+			TotalChange:  decomposition.TotalChange,
+			Effects:      decompositionEffectTotals(decomposition),
+		}
+		if decomposition.Method == advanced_contribution_analyzer.DecompositionMixRate {
+			fixedColumns = []string{contributionColumnCurrent, contributionColumnBase, contributionColumnChange, contributionColumnRatioShare, contributionColumnPercent, contributionColumnDirection}
+		}
+	} else {
+		for _, item := range contributions {
+			result.CurrentTotal += item.CurrentValue
+			result.BaseTotal += item.BaseValue
+		}
+	}
+	result.TotalChange = result.CurrentTotal - result.BaseTotal
+
+	for i, level := range drillDown.Levels {
+		result.Levels = append(result.Levels, sugarRes.ContributionLevel{
+			Dimensions:       level.Dimensions,
+			Discrimination:   level.Discrimination,
+			CombinationCount: len(level.Combinations),
+			MaxContribution:  level.MaxContribution,
+			Optimal:          i == drillDown.OptimalLevel,
+		})
+	}
+	if drillDown.OptimalLevel >= 0 && drillDown.OptimalLevel < len(drillDown.Levels) {
+		result.OptimalDimensions = drillDown.Levels[drillDown.OptimalLevel].Dimensions
+	}
+
+	if report := analysisResponse.DataQualityReport; report != nil {
+		result.DataQuality = &sugarRes.ContributionDataQuality{
+			QualityScore:      report.QualityScore,
+			TotalCombinations: report.TotalCombinations,
+			ValidCombinations: report.ValidCombinations,
+			Issues:            report.Issues,
+			Recommendations:   report.Recommendations,
+			DimensionCoverage: report.DimensionCoverage,
+			Stats: sugarRes.ContributionStat{
+				Mean:      report.ContributionStats.Mean,
+				Median:    report.ContributionStats.Median,
+				StdDev:    report.ContributionStats.StdDev,
+				Min:       report.ContributionStats.Min,
+				Max:       report.ContributionStats.Max,
+				ZeroCount: report.ContributionStats.ZeroCount,
+			},
+		}
+	}
+
+	result.Columns = append(append([]string{}, result.OptimalDimensions...), fixedColumns...)
+	result.Columns = append(result.Columns, effectNames...)
+	result.Results = buildContributionRows(drillDown.TopCombinations, result.OptimalDimensions, effectNames, contributions, result.TotalChange)
+	result.Count = len(result.Results)
+	return result
+}
+
+// buildContributionRows 为每个顶级组合汇总其覆盖的明细贡献项，生成结果表的行
+// 顺序为贡献度绝对值降序，相同时按维度值排序；比率指标的本期值、基期值、变化值为组合汇总后的比率及其差，
+// 对整体比率变化的贡献（各效应之和，贡献度和驱动方向据此计算）另列为整体比率变化贡献
+func buildContributionRows(combinations []*advanced_contribution_analyzer.DimensionCombination, dimensions, effectNames []string, contributions []ContributionItem, totalChange float64) []map[string]interface{} {
+	type contributionRow struct {
+		key     string
+		percent float64
+		values  map[string]interface{}
+	}
+	rows := make([]contributionRow, 0, len(combinations))
+	for _, combination := range combinations {
+		var currentValue, baseValue, changeValue float64
+		var ratio advanced_contribution_analyzer.RatioObservation
+		isRatio := false
+		effects := make(map[string]float64, len(effectNames))
+		for _, item := range contributions {
+			if !contributionItemMatches(item, combination) {
+				continue
+			}
+			currentValue += item.CurrentValue
+			baseValue += item.BaseValue
+			changeValue += item.ChangeValue
+			for name, effect := range item.Effects {
+				effects[name] += effect
+			}
+			if item.Components != nil {
+				isRatio = true
+				ratio.BaseNumerator += item.Components.BaseNumerator
+				ratio.BaseDenominator += item.Components.BaseDenominator
+				ratio.CurrentNumerator += item.Components.CurrentNumerator
+				ratio.CurrentDenominator += item.Components.CurrentDenominator
+			}
+		}
+		driverValue := changeValue
+		if isRatio {
+			baseValue, currentValue = groupRatioValues(ratio)
+			changeValue = currentValue - baseValue
+		}
+
+		values := make(map[string]interface{}, len(dimensions)+len(effectNames)+5)
+		keyParts := make([]string, 0, len(dimensions))
+		for _, dimension := range dimensions {
+			value := ""
+			for _, dimensionValue := range combination.Values {
+				if dimensionValue.Dimension == dimension {
+					value = dimensionValue.Value
+					break
+				}
+			}
+			values[dimension] = value
+			keyParts = append(keyParts, value)
+		}
+		values[contributionColumnCurrent] = currentValue
+		values[contributionColumnBase] = baseValue
+		values[contributionColumnChange] = changeValue
+		if isRatio {
+			values[contributionColumnRatioShare] = driverValue
+		}
+		values[contributionColumnPercent] = combination.Contribution
+		if driverValue*totalChange >= 0 {
+			values[contributionColumnDirection] = "正向"
+		} else {
+			values[contributionColumnDirection] = "负向"
+		}
+		for _, name := range effectNames {
+			values[name] = effects[name]
+		}
+		rows = append(rows, contributionRow{key: strings.Join(keyParts, "|"), percent: combination.Contribution, values: values})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		left, right := math.Abs(rows[i].percent), math.Abs(rows[j].percent)
+		if left != right {
+			return left > right
+		}
+		return rows[i].key < rows[j].key
+	})
+	results := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		results[i] = row.values
+	}
+	return results
+}
+
+// contributionItemMatches 判断明细贡献项是否属于指定的维度组合
+func contributionItemMatches(item ContributionItem, combination *advanced_contribution_analyzer.DimensionCombination) bool {
+	for _, dimensionValue := range combination.Values {
+		value, ok := item.DimensionValues[dimensionValue.Dimension]
+		if !ok || strings.TrimSpace(fmt.Sprintf("%v", value)) != dimensionValue.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// groupRatioValues 组合汇总后的基期、本期比率，分母为0的一期比率记为0
+func groupRatioValues(ratio advanced_contribution_analyzer.RatioObservation) (float64, float64) {
+	var baseValue, currentValue float64
+	if ratio.BaseDenominator != 0 {
+		baseValue = ratio.BaseNumerator / ratio.BaseDenominator
+	}
+	if ratio.CurrentDenominator != 0 {
+		currentValue = ratio.CurrentNumerator / ratio.CurrentDenominator
+	}
+	return baseValue, currentValue
+}
@@ -0,0 +1,181 @@
+package sugar
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// setupDecompositionDB 初始化变化分解测试数据：两个月份、两个区域、三家门店，其中一家门店本期新开
+func setupDecompositionDB(t *testing.T) {
+	t.Helper()
+	db := setupTestDB(t)
+	statements := []string{
+		`CREATE TABLE store_sales (month TEXT, region TEXT, store TEXT, orders REAL, revenue REAL, profit REAL)`,
+		`INSERT INTO sugar_team_members (team_id, user_id, role) VALUES ('team-1', '1', 'editor')`,
+		`INSERT INTO store_sales VALUES
+			('2024-01', '华北', '一店', 100, 5000, 1000), ('2024-01', '华北', '二店', 80, 3200, 480),
+			('2024-01', '华东', '三店', 120, 7200, 1800),
+			('2024-02', '华北', '一店', 110, 5830, 1049), ('2024-02', '华北', '二店', 60, 2700, 378),
+			('2024-02', '华东', '三店', 150, 8250, 1815), ('2024-02', '华东', '四店', 40, 1600, 160)`,
+	}
+	seedTestData(t, statements...)
+	id, name, teamId, table := "model-decomposition", "门店经营", "team-1", "store_sales"
+	model := sugar.SugarSemanticModels{
+		Id:                      &id,
+		Name:                    &name,
+		TeamId:                  &teamId,
+		SourceTableName:         &table,
+		ParameterConfig:         []byte(`{"月份": {"column": "month", "operator": "="}}`),
+		ReturnableColumnsConfig: []byte(`{"月份": {"column": "month", "type": "dimension"}, "区域": {"column": "region", "type": "dimension"}, "门店": {"column": "store", "type": "dimension"}, "订单数": {"column": "orders", "type": "metric"}, "收入": {"column": "revenue", "type": "metric"}, "利润": {"column": "profit", "type": "metric"}}`),
+	}
+	if err := db.Create(&model).Error; err != nil {
+		t.Fatalf("创建语义模型失败: %v", err)
+	}
+}
+
+func executeDecompositionFormula(t *testing.T, targetMetric string, config *sugarReq.ContributionAnalysisConfig) *sugarRes.SugarFormulaContributionResponse {
+	t.Helper()
+	service := &SugarFormulaQueryService{}
+	result, err := service.ExecuteContributionFormula(context.Background(), &sugarReq.SugarFormulaContributionRequest{
+		ModelName:            "门店经营",
+		TargetMetric:         targetMetric,
+		Dimensions:           []string{"区域", "门店"},
+		CurrentPeriodFilters: map[string]interface{}{"月份": "2024-02"},
+		BasePeriodFilters:    map[string]interface{}{"月份": "2024-01"},
+		Config:               config,
+	}, "1")
+	if err != nil {
+		t.Fatalf("执行贡献度公式失败: %v", err)
+	}
+	if result.Error != "" {
+		t.Fatalf("贡献度公式返回错误: %s", result.Error)
+	}
+	return result
+}
+
+// assertEffectsSumToChange 检查分解效应之和等于总变化，且每行的效应列之和等于该行变化值（比率指标为整体比率变化贡献）
+func assertEffectsSumToChange(t *testing.T, result *sugarRes.SugarFormulaContributionResponse, expectedBase, expectedCurrent float64) {
+	t.Helper()
+	const tolerance = 1e-9
+	if math.Abs(result.BaseTotal-expectedBase) > tolerance || math.Abs(result.CurrentTotal-expectedCurrent) > tolerance {
+		t.Fatalf("合计值 = (%v, %v)，期望 (%v, %v)", result.BaseTotal, result.CurrentTotal, expectedBase, expectedCurrent)
+	}
+	if result.Decomposition == nil {
+		t.Fatal("缺少分解结果")
+	}
+	var effectSum float64
+	for _, effect := range result.Decomposition.Effects {
+		effectSum += effect.Value
+	}
+	if math.Abs(effectSum-result.TotalChange) > tolerance {
+		t.Fatalf("效应之和 %v 与总变化 %v 不一致", effectSum, result.TotalChange)
+	}
+	for _, row := range result.Results {
+		var rowSum float64
+		for _, effect := range result.Decomposition.Effects {
+			value, ok := row[effect.Name].(float64)
+			if !ok {
+				t.Fatalf("结果行缺少效应列 %s: %v", effect.Name, row)
+			}
+			rowSum += value
+		}
+		change, ok := row[contributionColumnRatioShare].(float64)
+		if !ok {
+			change = row[contributionColumnChange].(float64)
+		}
+		if math.Abs(rowSum-change) > tolerance {
+			t.Fatalf("行效应之和 %v 与变化值 %v 不一致: %v", rowSum, change, row)
+		}
+	}
+}
+
+func TestExecuteContributionFormula_LMDI(t *testing.T) {
+	setupDecompositionDB(t)
+	method := "lmdi"
+	levels := 2
+	result := executeDecompositionFormula(t, "利润", &sugarReq.ContributionAnalysisConfig{
+		DecompositionMethod: method,
+		FactorMetrics:       []string{"订单数", "收入"},
+		MaxDrillDownLevels:  &levels,
+	})
+
+	assertEffectsSumToChange(t, result, 3280, 3402)
+	names := make([]string, 0, len(result.Decomposition.Effects))
+	for _, effect := range result.Decomposition.Effects {
+		names = append(names, effect.Name)
+	}
+	// 四店本期新开，基期因素为0，计入进出效应
+	expected := []string{"订单数效应", "收入/订单数效应", "利润/收入效应", "进出效应"}
+	if len(names) != len(expected) {
+		t.Fatalf("效应列 = %v，期望 %v", names, expected)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("效应列 = %v，期望 %v", names, expected)
+		}
+	}
+}
+
+func TestExecuteContributionFormula_Shapley(t *testing.T) {
+	setupDecompositionDB(t)
+	result := executeDecompositionFormula(t, "利润", &sugarReq.ContributionAnalysisConfig{
+		DecompositionMethod: "shapley",
+		FactorMetrics:       []string{"收入"},
+	})
+	assertEffectsSumToChange(t, result, 3280, 3402)
+}
+
+func TestExecuteContributionFormula_MixRate(t *testing.T) {
+	setupDecompositionDB(t)
+	result := executeDecompositionFormula(t, "利润", &sugarReq.ContributionAnalysisConfig{
+		DecompositionMethod: "mix_rate",
+		RatioNumerator:      "利润",
+		RatioDenominator:    "收入",
+	})
+	assertEffectsSumToChange(t, result, 3280.0/15400, 3402.0/18380)
+
+	// 本期值、基期值为组合自身的比率，变化值为两者之差；对整体比率变化的贡献另列
+	if result.Columns[len(result.OptimalDimensions)+3] != contributionColumnRatioShare {
+		t.Fatalf("结果列 = %v，缺少整体比率变化贡献列", result.Columns)
+	}
+	for _, row := range result.Results {
+		current, base, change := row[contributionColumnCurrent].(float64), row[contributionColumnBase].(float64), row[contributionColumnChange].(float64)
+		if math.Abs(current-base-change) > 1e-9 {
+			t.Fatalf("变化值 %v 应等于本期值 %v 减基期值 %v: %v", change, current, base, row)
+		}
+	}
+}
+
+func TestExecuteContributionFormula_InvalidDecomposition(t *testing.T) {
+	setupDecompositionDB(t)
+	service := &SugarFormulaQueryService{}
+	cases := map[string]*sugarReq.ContributionAnalysisConfig{
+		"未知方法":   {DecompositionMethod: "unknown"},
+		"缺少因素指标": {DecompositionMethod: "lmdi"},
+		"因素包含目标": {DecompositionMethod: "lmdi", FactorMetrics: []string{"利润"}},
+		"缺少分母":   {DecompositionMethod: "mix_rate", RatioNumerator: "利润"},
+	}
+	for name, config := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, err := service.ExecuteContributionFormula(context.Background(), &sugarReq.SugarFormulaContributionRequest{
+				ModelName:            "门店经营",
+				TargetMetric:         "利润",
+				Dimensions:           []string{"门店"},
+				CurrentPeriodFilters: map[string]interface{}{"月份": "2024-02"},
+				BasePeriodFilters:    map[string]interface{}{"月份": "2024-01"},
+				Config:               config,
+			}, "1")
+			if err != nil {
+				t.Fatalf("非预期错误: %v", err)
+			}
+			if result.Error == "" {
+				t.Fatal("期望返回配置错误")
+			}
+		})
+	}
+}
@@ -29,6 +29,11 @@ func (s *SugarFormulaQueryService) ExecuteCalcFormula(ctx context.Context, req *
 		return sugarRes.NewCalcErrorResponse(err.Error()), nil
 	}
 
+	// 时间智能函数：由服务端计算本期与对比期后分别查询
+	if req.TimeIntelligence != nil {
+		return s.executeCalcWithTimeIntelligence(ctx, model, req, userId)
+	}
+
 	// 构建SQL查询
 	sql, args, err := s.buildCalcSQL(model, req)
 	if err != nil {
@@ -80,6 +85,11 @@ func (s *SugarFormulaQueryService) ExecuteGetFormula(ctx context.Context, req *s
 		return sugarRes.NewGetErrorResponse(err.Error()), nil
 	}
 
+	// 时间智能函数：由服务端计算本期与对比期后分别查询
+	if req.TimeIntelligence != nil {
+		return s.executeGetWithTimeIntelligence(ctx, model, req, userId)
+	}
+
 	// 构建SQL查询
 	sql, args, err := s.buildGetSQL(model, req)
 	if err != nil {
@@ -119,9 +129,8 @@ func (s *SugarFormulaQueryService) ExecuteGetFormula(ctx context.Context, req *s
 func (s *SugarFormulaQueryService) getSemanticModel(ctx context.Context, modelName, userId string) (*sugar.SugarSemanticModels, error) {
 	var model sugar.SugarSemanticModels
 
-	// 获取用户所属团队
-	var teamIds []string
-	err := global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
+	// 获取用户可使用语义模型的团队
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceSemanticModel, SugarActionRead)
 	if err != nil {
 		return nil, errors.New("获取用户团队信息失败")
 	}
@@ -273,14 +282,31 @@ func (s *SugarFormulaQueryService) buildWhereClause(model *sugar.SugarSemanticMo
 
 	// 解析参数配置
 	var parameterConfig map[string]map[string]interface{}
-	if err := json.Unmarshal(model.ParameterConfig, &parameterConfig); err != nil {
-		return "", nil, errors.New("解析参数配置失败")
+	if len(model.ParameterConfig) > 0 {
+		if err := json.Unmarshal(model.ParameterConfig, &parameterConfig); err != nil {
+			return "", nil, errors.New("解析参数配置失败")
+		}
 	}
 
 	var conditions []string
 	var args []interface{}
 
 	for filterKey, filterValue := range filters {
+		// 日期区间筛选：{"start": "YYYY-MM-DD", "end": "YYYY-MM-DD"}，可作用于日期参数或分析口径声明的日期字段
+		if dateRange, ok := parseDateRangeValue(filterValue); ok {
+			actualColumn, layout, err := s.dateRangeColumn(model, parameterConfig, filterKey)
+			if err != nil {
+				return "", nil, err
+			}
+			condition, rangeArgs, err := buildDateRangeCondition(actualColumn, layout, dateRange)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions = append(conditions, condition)
+			args = append(args, rangeArgs...)
+			continue
+		}
+
 		paramConfig, exists := parameterConfig[filterKey]
 		if !exists {
 			return "", nil, errors.New("筛选条件不存在: " + filterKey)
@@ -369,6 +395,13 @@ func (s *SugarFormulaQueryService) addRowLevelPermissions(sql string, args []int
 	// 添加权限条件
 	permissionColumn := *model.PermissionKeyColumn
 
+	// 权限条件必须位于 GROUP BY 之前，先拆出分组子句，拼接完成后再追加
+	groupByClause := ""
+	if idx := strings.Index(strings.ToUpper(sql), " GROUP BY "); idx >= 0 {
+		groupByClause = sql[idx:]
+		sql = sql[:idx]
+	}
+
 	// 检查SQL是否已有WHERE子句
 	if strings.Contains(strings.ToUpper(sql), "WHERE") {
 		sql += " AND "
@@ -384,6 +417,7 @@ func (s *SugarFormulaQueryService) addRowLevelPermissions(sql string, args []int
 	}
 
 	sql += fmt.Sprintf("t.%s IN (%s)", permissionColumn, strings.Join(placeholders, ","))
+	sql += groupByClause
 
 	return sql, args, nil
 }
@@ -0,0 +1,246 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultEpsilonPerQuery = 0.5
+	defaultBudgetPerWindow = 5.0
+	defaultBudgetWindow    = 24 * time.Hour
+
+	// minReducedGrantRatio 剩余预算至少为单次消耗的该比例时按剩余预算降噪授予，否则视为耗尽
+	minReducedGrantRatio = 0.2
+	// budgetEpsilonTolerance 浮点累加误差容忍度
+	budgetEpsilonTolerance = 1e-9
+	// maxBudgetReserveAttempts 并发扣减冲突时的最大重试次数
+	maxBudgetReserveAttempts = 3
+)
+
+type SugarPrivacyBudgetService struct{}
+
+// PrivacyBudgetGrant 一次AIFETCH分析预留的隐私预算
+type PrivacyBudgetGrant struct {
+	LedgerId string
+	Epsilon  float64
+	Status   *sugarRes.SugarPrivacyBudgetStatus
+}
+
+// Refused 预算耗尽且策略为拒绝
+func (g *PrivacyBudgetGrant) Refused() bool {
+	return g.Status.Mode == sugarRes.PrivacyBudgetModeRefused
+}
+
+// ApplyTo 按授予的预算调整匿名化配置：授予ε时使用拉普拉斯噪声，耗尽降级时只输出定性字段
+func (g *PrivacyBudgetGrant) ApplyTo(config *anonymization_lite.LiteConfig) *anonymization_lite.LiteConfig {
+	config.Epsilon = g.Epsilon
+	config.Coarse = g.Status.Mode == sugarRes.PrivacyBudgetModeCoarse
+	return config
+}
+
+// Warning 预算不足时提示给用户的信息
+func (g *PrivacyBudgetGrant) Warning() string {
+	switch g.Status.Mode {
+	case sugarRes.PrivacyBudgetModeReduced:
+		return fmt.Sprintf("模型「%s」的隐私预算即将耗尽，本次结果的数值噪声较大", g.Status.ModelName)
+	case sugarRes.PrivacyBudgetModeCoarse:
+		return fmt.Sprintf("模型「%s」的隐私预算已耗尽，本次只提供定性结论，预算将于 %s 重置", g.Status.ModelName, g.Status.WindowEnd.Format("2006-01-02 15:04"))
+	case sugarRes.PrivacyBudgetModeRefused:
+		return fmt.Sprintf("模型「%s」的隐私预算已耗尽，预算将于 %s 重置", g.Status.ModelName, g.Status.WindowEnd.Format("2006-01-02 15:04"))
+	}
+	return ""
+}
+
+// privacyBudgetSettings 读取隐私预算配置并补全默认值
+func privacyBudgetSettings() (perQuery, perWindow float64, window time.Duration, refuse bool) {
+	config := global.GVA_CONFIG.Sugar.PrivacyBudget
+	perQuery, perWindow, window = config.EpsilonPerQuery, config.BudgetPerWindow, time.Duration(config.WindowHours)*time.Hour
+	if perQuery <= 0 {
+		perQuery = defaultEpsilonPerQuery
+	}
+	if perWindow <= 0 {
+		perWindow = defaultBudgetPerWindow
+	}
+	if window <= 0 {
+		window = defaultBudgetWindow
+	}
+	refuse = strings.EqualFold(strings.TrimSpace(config.ExhaustedAction), "refuse")
+	return
+}
+
+// ReserveBudget 为用户在语义模型上的一次分析预留隐私预算
+// 剩余预算充足时授予配置的ε；不足一次完整消耗时按剩余ε授予；耗尽后按配置降级为定性输出或拒绝
+// epsilon 为匿名化策略指定的单次消耗，<=0 时使用全局配置
+func (s *SugarPrivacyBudgetService) ReserveBudget(ctx context.Context, userId, modelName string, epsilon float64) (*PrivacyBudgetGrant, error) {
+	model, err := (&SugarFormulaQueryService{}).getSemanticModel(ctx, modelName, userId)
+	if err != nil {
+		return nil, err
+	}
+	perQuery, perWindow, window, refuse := privacyBudgetSettings()
+	if epsilon > 0 {
+		perQuery = epsilon
+	}
+	windowStart := time.Now().Truncate(window)
+
+	ledger, err := s.getOrCreateLedger(ctx, userId, model, windowStart, windowStart.Add(window), perWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxBudgetReserveAttempts; attempt++ {
+		remaining := ledger.EpsilonBudget - ledger.EpsilonSpent
+		var granted float64
+		var mode string
+		switch {
+		case remaining >= perQuery-budgetEpsilonTolerance:
+			granted, mode = perQuery, sugarRes.PrivacyBudgetModeFull
+		case remaining >= perQuery*minReducedGrantRatio:
+			granted, mode = remaining, sugarRes.PrivacyBudgetModeReduced
+		case refuse:
+			mode = sugarRes.PrivacyBudgetModeRefused
+		default:
+			mode = sugarRes.PrivacyBudgetModeCoarse
+		}
+
+		query := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarPrivacyBudgetLedgers{}).Where("id = ?", *ledger.Id)
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		switch mode {
+		case sugarRes.PrivacyBudgetModeCoarse:
+			updates["coarsened_count"] = gorm.Expr("coarsened_count + 1")
+		case sugarRes.PrivacyBudgetModeRefused:
+			updates["refused_count"] = gorm.Expr("refused_count + 1")
+		default:
+			// 条件扣减，保证并发请求不会超出预算
+			query = query.Where("epsilon_spent + ? <= epsilon_budget + ?", granted, budgetEpsilonTolerance)
+			updates["epsilon_spent"] = gorm.Expr("epsilon_spent + ?", granted)
+			updates["query_count"] = gorm.Expr("query_count + 1")
+		}
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return nil, fmt.Errorf("更新隐私预算账本失败: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			grant := &PrivacyBudgetGrant{
+				LedgerId: *ledger.Id,
+				Epsilon:  granted,
+				Status: &sugarRes.SugarPrivacyBudgetStatus{
+					SemanticModelId:  safeDeref(model.Id),
+					ModelName:        safeDeref(model.Name),
+					WindowStart:      *ledger.WindowStart,
+					WindowEnd:        *ledger.WindowEnd,
+					EpsilonBudget:    ledger.EpsilonBudget,
+					EpsilonSpent:     ledger.EpsilonSpent + granted,
+					EpsilonRemaining: math.Max(0, remaining-granted),
+					EpsilonGranted:   granted,
+					Mode:             mode,
+				},
+			}
+			global.GVA_LOG.Info("预留隐私预算",
+				zap.String("userId", userId),
+				zap.String("modelName", modelName),
+				zap.String("mode", mode),
+				zap.Float64("epsilonGranted", granted),
+				zap.Float64("epsilonRemaining", grant.Status.EpsilonRemaining))
+			return grant, nil
+		}
+
+		// 其他请求已先行扣减，重新读取账本后再决定
+		if err := global.GVA_DB.WithContext(ctx).Where("id = ?", *ledger.Id).First(ledger).Error; err != nil {
+			return nil, fmt.Errorf("读取隐私预算账本失败: %w", err)
+		}
+	}
+	return nil, errors.New("隐私预算账本更新冲突，请稍后重试")
+}
+
+// ReleaseBudget 分析未能产出结果时退回预留的预算
+func (s *SugarPrivacyBudgetService) ReleaseBudget(ctx context.Context, grant *PrivacyBudgetGrant) {
+	if grant == nil || grant.Epsilon <= 0 {
+		return
+	}
+	err := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarPrivacyBudgetLedgers{}).
+		Where("id = ?", grant.LedgerId).
+		Updates(map[string]interface{}{
+			"epsilon_spent": gorm.Expr("epsilon_spent - ?", grant.Epsilon),
+			"query_count":   gorm.Expr("query_count - 1"),
+			"updated_at":    time.Now(),
+		}).Error
+	if err != nil {
+		global.GVA_LOG.Error("退回隐私预算失败", zap.String("ledgerId", grant.LedgerId), zap.Error(err))
+	}
+}
+
+// GetPrivacyBudgetList 分页获取隐私预算账本
+func (s *SugarPrivacyBudgetService) GetPrivacyBudgetList(ctx context.Context, info sugarReq.SugarPrivacyBudgetSearch) (list []sugar.SugarPrivacyBudgetLedgers, total int64, err error) {
+	limit := info.PageSize
+	offset := info.PageSize * (info.Page - 1)
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarPrivacyBudgetLedgers{})
+	if info.UserId != "" {
+		db = db.Where("user_id = ?", info.UserId)
+	}
+	if info.SemanticModelId != "" {
+		db = db.Where("semantic_model_id = ?", info.SemanticModelId)
+	}
+	if info.OnlyActive {
+		db = db.Where("window_end > ?", time.Now())
+	}
+
+	if err = db.Count(&total).Error; err != nil {
+		return
+	}
+	if limit != 0 {
+		db = db.Limit(limit).Offset(offset)
+	}
+	err = db.Order("window_start DESC, updated_at DESC").Find(&list).Error
+	return list, total, err
+}
+
+// getOrCreateLedger 获取当前时间窗口的账本，不存在时创建；并发创建冲突时读取已创建的账本
+func (s *SugarPrivacyBudgetService) getOrCreateLedger(ctx context.Context, userId string, model *sugar.SugarSemanticModels, windowStart, windowEnd time.Time, budget float64) (*sugar.SugarPrivacyBudgetLedgers, error) {
+	var ledger sugar.SugarPrivacyBudgetLedgers
+	find := func(dest *sugar.SugarPrivacyBudgetLedgers) error {
+		return global.GVA_DB.WithContext(ctx).Where("user_id = ? AND semantic_model_id = ? AND window_start = ?", userId, *model.Id, windowStart).First(dest).Error
+	}
+	err := find(&ledger)
+	if err == nil {
+		return &ledger, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("读取隐私预算账本失败: %w", err)
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+	ledger = sugar.SugarPrivacyBudgetLedgers{
+		Id:              &id,
+		UserId:          &userId,
+		SemanticModelId: model.Id,
+		ModelName:       model.Name,
+		WindowStart:     &windowStart,
+		WindowEnd:       &windowEnd,
+		EpsilonBudget:   budget,
+		CreatedAt:       &now,
+		UpdatedAt:       &now,
+	}
+	if err := global.GVA_DB.WithContext(ctx).Create(&ledger).Error; err != nil {
+		var existing sugar.SugarPrivacyBudgetLedgers
+		if findErr := find(&existing); findErr == nil {
+			return &existing, nil
+		}
+		return nil, fmt.Errorf("创建隐私预算账本失败: %w", err)
+	}
+	return &ledger, nil
+}
@@ -0,0 +1,200 @@
+package sugar
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/config"
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/anonymization_lite"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+	"gorm.io/gorm"
+)
+
+// setupPrivacyBudget 初始化团队1的语义模型「销售」并设置隐私预算配置，测试结束后恢复
+func setupPrivacyBudget(t *testing.T, budget config.PrivacyBudget) {
+	t.Helper()
+	setupTeamWorkbook(t)
+	seedTestData(t, `INSERT INTO sugar_semantic_models (id, name, team_id) VALUES ('model-1', '销售', 'team-1')`)
+	previous := global.GVA_CONFIG.Sugar.PrivacyBudget
+	global.GVA_CONFIG.Sugar.PrivacyBudget = budget
+	t.Cleanup(func() { global.GVA_CONFIG.Sugar.PrivacyBudget = previous })
+}
+
+func budgetLedger(t *testing.T, userId string) sugar.SugarPrivacyBudgetLedgers {
+	t.Helper()
+	var ledger sugar.SugarPrivacyBudgetLedgers
+	if err := global.GVA_DB.Where("user_id = ? AND semantic_model_id = ?", userId, "model-1").First(&ledger).Error; err != nil {
+		t.Fatalf("读取隐私预算账本失败: %v", err)
+	}
+	return ledger
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestReserveBudgetDegradation(t *testing.T) {
+	setupPrivacyBudget(t, config.PrivacyBudget{EpsilonPerQuery: 0.4, BudgetPerWindow: 1})
+	ctx := context.Background()
+	service := &SugarPrivacyBudgetService{}
+
+	// 完整授予两次后只剩0.2，按剩余预算降噪授予，之后降级为定性输出
+	tests := []struct {
+		mode      string
+		granted   float64
+		remaining float64
+	}{
+		{sugarRes.PrivacyBudgetModeFull, 0.4, 0.6},
+		{sugarRes.PrivacyBudgetModeFull, 0.4, 0.2},
+		{sugarRes.PrivacyBudgetModeReduced, 0.2, 0},
+		{sugarRes.PrivacyBudgetModeCoarse, 0, 0},
+		{sugarRes.PrivacyBudgetModeCoarse, 0, 0},
+	}
+	for i, tt := range tests {
+		grant, err := service.ReserveBudget(ctx, "1", "销售", 0)
+		if err != nil {
+			t.Fatalf("第%d次预留失败: %v", i+1, err)
+		}
+		if grant.Status.Mode != tt.mode || !nearlyEqual(grant.Epsilon, tt.granted) || !nearlyEqual(grant.Status.EpsilonRemaining, tt.remaining) {
+			t.Fatalf("第%d次预留 = %s ε=%v 剩余%v，期望 %s ε=%v 剩余%v",
+				i+1, grant.Status.Mode, grant.Epsilon, grant.Status.EpsilonRemaining, tt.mode, tt.granted, tt.remaining)
+		}
+		if config := grant.ApplyTo(&anonymization_lite.LiteConfig{}); config.Coarse != (tt.mode == sugarRes.PrivacyBudgetModeCoarse) || config.Epsilon != grant.Epsilon {
+			t.Fatalf("第%d次预留的匿名化配置不符合预期: %+v", i+1, config)
+		}
+		if (grant.Warning() == "") != (tt.mode == sugarRes.PrivacyBudgetModeFull) {
+			t.Fatalf("第%d次预留的提示不符合预期: %q", i+1, grant.Warning())
+		}
+	}
+	ledger := budgetLedger(t, "1")
+	if !nearlyEqual(ledger.EpsilonSpent, 1) || ledger.QueryCount != 3 || ledger.CoarsenedCount != 2 || ledger.RefusedCount != 0 {
+		t.Fatalf("账本不符合预期: %+v", ledger)
+	}
+
+	// 配置为拒绝时预算耗尽后拒绝分析
+	global.GVA_CONFIG.Sugar.PrivacyBudget.ExhaustedAction = " Refuse "
+	grant, err := service.ReserveBudget(ctx, "1", "销售", 0)
+	if err != nil || !grant.Refused() || grant.Epsilon != 0 {
+		t.Fatalf("预算耗尽后应拒绝: %+v %v", grant, err)
+	}
+	if ledger = budgetLedger(t, "1"); ledger.RefusedCount != 1 {
+		t.Fatalf("拒绝次数未记录: %+v", ledger)
+	}
+
+	// 账本按用户区分；匿名化策略指定的ε优先于全局配置；无权访问的模型不预留
+	if grant, err = service.ReserveBudget(ctx, "2", "销售", 0.9); err != nil || grant.Status.Mode != sugarRes.PrivacyBudgetModeFull || grant.Epsilon != 0.9 {
+		t.Fatalf("其他用户应有独立的预算: %+v %v", grant, err)
+	}
+	if _, err = service.ReserveBudget(ctx, "3", "销售", 0); err == nil {
+		t.Fatal("非团队成员不应预留预算")
+	}
+}
+
+func TestReleaseBudget(t *testing.T) {
+	setupPrivacyBudget(t, config.PrivacyBudget{EpsilonPerQuery: 0.5, BudgetPerWindow: 1})
+	ctx := context.Background()
+	service := &SugarPrivacyBudgetService{}
+
+	first, _ := service.ReserveBudget(ctx, "1", "销售", 0)
+	second, _ := service.ReserveBudget(ctx, "1", "销售", 0)
+	coarse, _ := service.ReserveBudget(ctx, "1", "销售", 0)
+	if second.Status.Mode != sugarRes.PrivacyBudgetModeFull || coarse.Status.Mode != sugarRes.PrivacyBudgetModeCoarse {
+		t.Fatalf("预留结果不符合预期: %+v %+v", second.Status, coarse.Status)
+	}
+
+	// 分析失败后退回预算，下次可再次完整授予；定性输出和空授予不退回
+	service.ReleaseBudget(ctx, second)
+	service.ReleaseBudget(ctx, coarse)
+	service.ReleaseBudget(ctx, nil)
+	if ledger := budgetLedger(t, "1"); !nearlyEqual(ledger.EpsilonSpent, first.Epsilon) || ledger.QueryCount != 1 || ledger.CoarsenedCount != 1 {
+		t.Fatalf("退回后的账本不符合预期: %+v", ledger)
+	}
+	if grant, err := service.ReserveBudget(ctx, "1", "销售", 0); err != nil || grant.Status.Mode != sugarRes.PrivacyBudgetModeFull {
+		t.Fatalf("退回后应能再次完整授予: %+v %v", grant, err)
+	}
+}
+
+func TestReserveBudgetConcurrent(t *testing.T) {
+	setupPrivacyBudget(t, config.PrivacyBudget{EpsilonPerQuery: 0.6, BudgetPerWindow: 1})
+	ctx := context.Background()
+	service := &SugarPrivacyBudgetService{}
+
+	// 第一次预留读取账本后、扣减前，另一次预留先完成扣减；两次完整消耗合计超出预算
+	var concurrent *PrivacyBudgetGrant
+	var concurrentErr error
+	reserved := false
+	err := global.GVA_DB.Callback().Update().Before("gorm:begin_transaction").Register("test:concurrent_reserve", func(tx *gorm.DB) {
+		if tx.Statement.Table == "sugar_privacy_budget_ledgers" && !reserved {
+			reserved = true
+			concurrent, concurrentErr = service.ReserveBudget(ctx, "1", "销售", 0)
+		}
+	})
+	if err != nil {
+		t.Fatalf("注册回调失败: %v", err)
+	}
+
+	// 条件扣减保证只有一次完整授予，另一次重新读取账本后按剩余预算授予
+	grant, err := service.ReserveBudget(ctx, "1", "销售", 0)
+	if err != nil || concurrentErr != nil {
+		t.Fatalf("并发预留失败: %v %v", err, concurrentErr)
+	}
+	if concurrent.Status.Mode != sugarRes.PrivacyBudgetModeFull || grant.Status.Mode != sugarRes.PrivacyBudgetModeReduced ||
+		!nearlyEqual(grant.Epsilon, 0.4) || !nearlyEqual(grant.Status.EpsilonRemaining, 0) {
+		t.Fatalf("并发预留结果 = %+v / %+v，期望一次完整一次按剩余预算授予", concurrent.Status, grant.Status)
+	}
+	if ledger := budgetLedger(t, "1"); !nearlyEqual(ledger.EpsilonSpent, 1) || ledger.QueryCount != 2 {
+		t.Fatalf("并发预留后的账本超出预算: %+v", ledger)
+	}
+}
+
+func TestPrivacyBudgetReleasedWhenPromptBlocked(t *testing.T) {
+	setupGoldenDB(t, `{"leakPolicy": "block"}`)
+	previous := global.GVA_CONFIG.Sugar.PrivacyBudget
+	global.GVA_CONFIG.Sugar.PrivacyBudget = config.PrivacyBudget{EpsilonPerQuery: 0.5, BudgetPerWindow: 5}
+	t.Cleanup(func() { global.GVA_CONFIG.Sugar.PrivacyBudget = previous })
+	stub, llmConfig := newStubLLM(t)
+	ctx := context.Background()
+
+	analyze := func(description string) *sugarRes.SugarFormulaAiResponse {
+		t.Helper()
+		p := NewAiFetchProcessor(nil)
+		inv := &aiFetchInvocation{processor: p, req: &sugarReq.SugarFormulaAiFetchRequest{Description: description}, agent: &sugar.SugarAgents{}, llmConfig: llmConfig}
+		toolCall := system.OpenAIToolCall{Type: "function", Function: system.OpenAIToolCallFunction{
+			Name:      "smart_anonymized_analyzer",
+			Arguments: `{"modelName": "月度销售", "targetMetric": "销售额", "currentPeriodFilters": {"月份": "2024-02"}, "basePeriodFilters": {"月份": "2024-01"}, "groupByDimensions": ["城市"]}`,
+		}}
+		result, err := p.handleSmartAnonymizedAnalyzer(ctx, inv, "1", toolCall)
+		if err != nil {
+			t.Fatalf("执行分析失败: %v", err)
+		}
+		return result
+	}
+	spent := func() float64 {
+		var ledger sugar.SugarPrivacyBudgetLedgers
+		if err := global.GVA_DB.Where("user_id = ? AND semantic_model_id = ?", "1", "model-golden").First(&ledger).Error; err != nil {
+			t.Fatalf("读取隐私预算账本失败: %v", err)
+		}
+		return ledger.EpsilonSpent
+	}
+
+	// 提示词中出现原始维度值，block 策略拒绝发送，预留的预算全部退回
+	if result := analyze("为什么北京的销售额上涨了？"); result.Error == "" {
+		t.Fatalf("block 策略应拒绝发送: %+v", result)
+	}
+	if len(stub.prompts) != 0 || !nearlyEqual(spent(), 0) {
+		t.Fatalf("拒绝发送时不应调用模型或消耗预算: %d %v", len(stub.prompts), spent())
+	}
+
+	// 正常发送后预算照常消耗
+	if result := analyze("分析2月销售额变化的原因"); result.Error != "" {
+		t.Fatalf("分析失败: %s", result.Error)
+	}
+	if len(stub.prompts) != 1 || !nearlyEqual(spent(), 0.5) {
+		t.Fatalf("发送后应消耗一次预算: %d %v", len(stub.prompts), spent())
+	}
+}
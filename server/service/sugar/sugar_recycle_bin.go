@@ -0,0 +1,382 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultRecycleBinRetention = 30 * 24 * time.Hour
+	recycleBinPurgeBatchSize   = 200 // 定时清理时每个事务处理的项目数
+	maxRestoreRenameAttempts   = 100
+)
+
+// recycleBinRetention 读取回收站保留期限并补全默认值
+func recycleBinRetention() time.Duration {
+	if days := global.GVA_CONFIG.Sugar.RecycleBin.RetentionDays; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return defaultRecycleBinRetention
+}
+
+// ErrWorkspaceTooDeep 文件夹层级超过 workspaceMaxDepth，无法完整处理其下的内容
+var ErrWorkspaceTooDeep = fmt.Errorf("文件夹层级超过 %d 层，请先移动或删除其中较深的内容", workspaceMaxDepth)
+
+// ErrNoAvailableName 追加序号后仍找不到未被使用的名称
+var ErrNoAvailableName = errors.New("无法生成不重复的名称，请先重命名原位置的同名项目")
+
+// RecycleBinConflictError 恢复时原位置已存在同名项目
+type RecycleBinConflictError struct {
+	Conflict *sugarRes.SugarRecycleBinRestoreConflict
+}
+
+func (e *RecycleBinConflictError) Error() string {
+	return fmt.Sprintf("原位置已存在同名项目“%s”，可重命名后恢复", e.Conflict.Name)
+}
+
+// softDeleteWorkspaceTree 在事务中将项目及其下全部未删除的内容移入回收站，返回删除的项目数
+// 同一次删除的项目通过 deleted_root 关联到用户直接删除的项目，在回收站中作为一项展示和恢复
+// 层级超过 workspaceMaxDepth 时返回 ErrWorkspaceTooDeep，不删除任何内容，避免更深的内容成为无法访问的孤儿
+func softDeleteWorkspaceTree(tx *gorm.DB, workspace *sugar.SugarWorkspaces, userId string, now time.Time) (int, error) {
+	ids := []string{*workspace.Id}
+	frontier := []string{*workspace.Id}
+	for depth := 0; len(frontier) > 0 && depth < workspaceMaxDepth; depth++ {
+		var children []string
+		err := tx.Model(&sugar.SugarWorkspaces{}).Where("parent_id IN ? AND deleted_at IS NULL", frontier).Pluck("id", &children).Error
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, children...)
+		frontier = children
+	}
+	if len(frontier) > 0 {
+		return 0, ErrWorkspaceTooDeep
+	}
+	err := tx.Model(&sugar.SugarWorkspaces{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"deleted_at":   now,
+		"deleted_by":   userId,
+		"deleted_root": *workspace.Id,
+		"updated_by":   userId,
+		"updated_at":   now,
+	}).Error
+	return len(ids), err
+}
+
+// purgeWorkspaceBatches 彻底删除回收站中的项目及随其一起删除的内容，连同历史版本、协作操作、授权和分享链接
+func purgeWorkspaceBatches(tx *gorm.DB, rootIds []string) (int, error) {
+	var ids []string
+	err := tx.Model(&sugar.SugarWorkspaces{}).
+		Where("deleted_at IS NOT NULL AND (id IN ? OR deleted_root IN ?)", rootIds, rootIds).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	if err = tx.Where("file_id IN ?", ids).Delete(&sugar.SugarFileVersions{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("file_id IN ?", ids).Delete(&sugar.SugarWorkbookOperations{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("workspace_id IN ?", ids).Delete(&sugar.SugarWorkspacePermissions{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("workspace_id IN ?", ids).Delete(&sugar.SugarShareLinks{}).Error; err != nil {
+		return 0, err
+	}
+	if err = deleteWorkbookSearchIndex(tx, ids); err != nil {
+		return 0, err
+	}
+	if err = tx.Where("id IN ?", ids).Delete(&sugar.SugarWorkspaces{}).Error; err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// PurgeExpiredWorkspaces 彻底删除在回收站中超过保留期限的项目，返回删除的项目数，供定时任务调用
+func PurgeExpiredWorkspaces(db *gorm.DB, now time.Time) (int, error) {
+	before := now.Add(-recycleBinRetention())
+	total := 0
+	for {
+		var rootIds []string
+		err := db.Model(&sugar.SugarWorkspaces{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ? AND (deleted_root IS NULL OR deleted_root = id)", before).
+			Limit(recycleBinPurgeBatchSize).Pluck("id", &rootIds).Error
+		if err != nil || len(rootIds) == 0 {
+			return total, err
+		}
+		var purged int
+		err = db.Transaction(func(tx *gorm.DB) error {
+			purged, err = purgeWorkspaceBatches(tx, rootIds)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		total += purged
+		if len(rootIds) < recycleBinPurgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+type SugarRecycleBinService struct{}
+
+// findDeletedRoot 查询回收站中用户直接删除的项目
+func (s *SugarRecycleBinService) findDeletedRoot(ctx context.Context, id string) (*sugar.SugarWorkspaces, error) {
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND deleted_at IS NOT NULL", id).First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("回收站中不存在该项目")
+		}
+		return nil, errors.New("查询项目失败")
+	}
+	if workspace.DeletedRoot != nil && *workspace.DeletedRoot != id {
+		return nil, errors.New("该项目随文件夹一起删除，请恢复其所在的文件夹")
+	}
+	return &workspace, nil
+}
+
+// GetRecycleBinList 分页获取团队回收站，文件夹连同其下内容作为一项展示
+func (s *SugarRecycleBinService) GetRecycleBinList(ctx context.Context, req sugarReq.SugarRecycleBinSearch, userId string) ([]sugarRes.SugarRecycleBinItem, int64, error) {
+	if err := authorizeTeamAction(ctx, req.TeamId, userId, SugarResourceWorkspace, SugarActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).
+		Where("team_id = ? AND deleted_at IS NOT NULL AND (deleted_root IS NULL OR deleted_root = id)", req.TeamId)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if req.PageSize > 0 {
+		db = db.Limit(req.PageSize).Offset(req.PageSize * (req.Page - 1))
+	}
+	var workspaces []sugar.SugarWorkspaces
+	if err := db.Omit("content").Order("deleted_at DESC").Find(&workspaces).Error; err != nil {
+		return nil, 0, err
+	}
+
+	rootIds := make([]string, 0, len(workspaces))
+	parentIds := make([]string, 0)
+	deleterIds := make([]string, 0)
+	for _, workspace := range workspaces {
+		rootIds = append(rootIds, *workspace.Id)
+		if workspace.ParentId != nil {
+			parentIds = append(parentIds, *workspace.ParentId)
+		}
+		if workspace.DeletedBy != nil {
+			deleterIds = append(deleterIds, *workspace.DeletedBy)
+		}
+	}
+	itemCounts := map[string]int64{}
+	parentNames := map[string]string{}
+	if len(rootIds) > 0 {
+		var counts []struct {
+			DeletedRoot string
+			Count       int64
+		}
+		err := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).Select("deleted_root, COUNT(*) AS count").
+			Where("deleted_root IN ? AND id <> deleted_root AND deleted_at IS NOT NULL", rootIds).Group("deleted_root").Scan(&counts).Error
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, count := range counts {
+			itemCounts[count.DeletedRoot] = count.Count
+		}
+	}
+	if len(parentIds) > 0 {
+		var parents []sugar.SugarWorkspaces
+		if err := global.GVA_DB.WithContext(ctx).Select("id", "name").Where("id IN ?", parentIds).Find(&parents).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, parent := range parents {
+			if parent.Name != nil {
+				parentNames[*parent.Id] = *parent.Name
+			}
+		}
+	}
+	deleterNames := lookupUserNames(ctx, deleterIds)
+
+	retention := recycleBinRetention()
+	list := make([]sugarRes.SugarRecycleBinItem, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		item := sugarRes.SugarRecycleBinItem{
+			Id:        *workspace.Id,
+			Type:      workspace.Type,
+			TeamId:    req.TeamId,
+			ParentId:  workspace.ParentId,
+			ItemCount: itemCounts[*workspace.Id],
+			DeletedBy: workspace.DeletedBy,
+			DeletedAt: workspace.DeletedAt,
+		}
+		if workspace.Name != nil {
+			item.Name = *workspace.Name
+		}
+		if workspace.ParentId != nil {
+			item.ParentName = parentNames[*workspace.ParentId]
+		}
+		if workspace.DeletedBy != nil {
+			item.DeletedByName = deleterNames[*workspace.DeletedBy]
+		}
+		if workspace.DeletedAt != nil {
+			purgeAt := workspace.DeletedAt.Add(retention)
+			item.PurgeAt = &purgeAt
+		}
+		list = append(list, item)
+	}
+	return list, total, nil
+}
+
+// siblingWithName 查找同级目录下未删除的同名项目，不存在时返回空字符串
+// 检查结果用于写入时 db 应为写入所在的事务
+func siblingWithName(db *gorm.DB, teamId string, parentId *string, name, excludeId string) (string, error) {
+	query := db.Model(&sugar.SugarWorkspaces{}).
+		Where("name = ? AND team_id = ? AND id != ? AND deleted_at IS NULL", name, teamId, excludeId)
+	if parentId != nil {
+		query = query.Where("parent_id = ?", *parentId)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	var ids []string
+	if err := query.Limit(1).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// availableName 在名称后追加序号，找到同级目录下未被使用的名称
+func availableName(db *gorm.DB, teamId string, parentId *string, name, excludeId string) (string, error) {
+	for i := 1; i <= maxRestoreRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		existing, err := siblingWithName(db, teamId, parentId, candidate, excludeId)
+		if err != nil {
+			return "", err
+		}
+		if existing == "" {
+			return candidate, nil
+		}
+	}
+	return "", ErrNoAvailableName
+}
+
+// RestoreItem 从回收站恢复项目及随其一起删除的内容
+// 原文件夹已被删除时恢复到团队根目录；原位置已有同名项目时按 ConflictStrategy 返回冲突或自动重命名
+func (s *SugarRecycleBinService) RestoreItem(ctx context.Context, req sugarReq.SugarRecycleBinRestoreRequest, userId string) (*sugarRes.SugarRecycleBinRestoreResponse, error) {
+	if req.ConflictStrategy != sugarReq.RecycleBinConflictFail && req.ConflictStrategy != sugarReq.RecycleBinConflictRename {
+		return nil, errors.New("冲突处理方式只能为空或 rename")
+	}
+	workspace, err := s.findDeletedRoot(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeWorkspaceItem(ctx, workspace, userId, SugarActionDelete); err != nil {
+		return nil, err
+	}
+
+	// 原文件夹和同名项目的检查与恢复在同一事务中进行，恢复的项目行加锁，避免并发恢复或新建同名项目后仍写入重复名称
+	result := &sugarRes.SugarRecycleBinRestoreResponse{Id: req.Id, Name: *workspace.Name, ParentId: workspace.ParentId}
+	now := time.Now()
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked sugar.SugarWorkspaces
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND deleted_at IS NOT NULL", req.Id).First(&locked).Error
+		if err != nil {
+			return err
+		}
+		if workspace.ParentId != nil {
+			var count int64
+			err = tx.Model(&sugar.SugarWorkspaces{}).
+				Where("id = ? AND team_id = ? AND deleted_at IS NULL", *workspace.ParentId, *workspace.TeamId).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				result.ParentId, result.MovedToRoot = nil, true
+			}
+		}
+
+		existingId, err := siblingWithName(tx, *workspace.TeamId, result.ParentId, result.Name, req.Id)
+		if err != nil {
+			return err
+		}
+		if existingId != "" {
+			suggested, err := availableName(tx, *workspace.TeamId, result.ParentId, result.Name, req.Id)
+			if err != nil {
+				return err
+			}
+			if req.ConflictStrategy != sugarReq.RecycleBinConflictRename {
+				return &RecycleBinConflictError{Conflict: &sugarRes.SugarRecycleBinRestoreConflict{ExistingId: existingId, Name: result.Name, SuggestedName: suggested}}
+			}
+			result.Name, result.Renamed = suggested, true
+		}
+
+		err = tx.Model(&sugar.SugarWorkspaces{}).Where("id = ?", req.Id).Updates(map[string]interface{}{
+			"name":      result.Name,
+			"parent_id": result.ParentId,
+		}).Error
+		if err != nil {
+			return err
+		}
+		restored := tx.Model(&sugar.SugarWorkspaces{}).
+			Where("deleted_at IS NOT NULL AND (id = ? OR deleted_root = ?)", req.Id, req.Id).
+			Updates(map[string]interface{}{
+				"deleted_at":   nil,
+				"deleted_by":   nil,
+				"deleted_root": nil,
+				"updated_by":   userId,
+				"updated_at":   now,
+			})
+		result.RestoredCount = int(restored.RowsAffected)
+		return restored.Error
+	})
+	var conflict *RecycleBinConflictError
+	if errors.As(err, &conflict) {
+		return nil, conflict
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("回收站中不存在该项目")
+	}
+	if errors.Is(err, ErrNoAvailableName) {
+		return nil, err
+	}
+	if err != nil {
+		global.GVA_LOG.Error("恢复项目失败", zap.String("id", req.Id), zap.Error(err))
+		return nil, errors.New("恢复失败")
+	}
+
+	global.GVA_LOG.Info("从回收站恢复项目", zap.String("id", req.Id), zap.Int("count", result.RestoredCount),
+		zap.Bool("renamed", result.Renamed), zap.Bool("movedToRoot", result.MovedToRoot), zap.String("by", userId))
+	return result, nil
+}
+
+// PurgeItem 彻底删除回收站中的项目，删除后无法恢复
+func (s *SugarRecycleBinService) PurgeItem(ctx context.Context, id string, userId string) (int, error) {
+	workspace, err := s.findDeletedRoot(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if err = authorizeWorkspaceItem(ctx, workspace, userId, SugarActionDelete); err != nil {
+		return 0, err
+	}
+	var purged int
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		purged, err = purgeWorkspaceBatches(tx, []string{id})
+		return err
+	})
+	if err != nil {
+		global.GVA_LOG.Error("彻底删除项目失败", zap.String("id", id), zap.Error(err))
+		return 0, errors.New("彻底删除失败")
+	}
+	global.GVA_LOG.Info("彻底删除回收站项目", zap.String("id", id), zap.Int("count", purged), zap.String("by", userId))
+	return purged, nil
+}
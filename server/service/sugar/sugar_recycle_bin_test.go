@@ -0,0 +1,174 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+func deleteItem(t *testing.T, id, userId string) {
+	t.Helper()
+	if _, err := (&SugarFoldersService{}).DeleteItem(context.Background(), &sugarReq.SugarFoldersDeleteRequest{Id: id}, userId); err != nil {
+		t.Fatalf("删除 %s 失败: %v", id, err)
+	}
+}
+
+func recycleBinList(t *testing.T, userId string) []sugarRes.SugarRecycleBinItem {
+	t.Helper()
+	list, total, err := (&SugarRecycleBinService{}).GetRecycleBinList(context.Background(), sugarReq.SugarRecycleBinSearch{
+		TeamId: "team-1", PageInfo: request.PageInfo{Page: 1, PageSize: 10},
+	}, userId)
+	if err != nil || int(total) != len(list) {
+		t.Fatalf("获取回收站失败: %d %v", total, err)
+	}
+	return list
+}
+
+func countWhere(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var count int64
+	if err := global.GVA_DB.Model(model).Where(query, args...).Count(&count).Error; err != nil {
+		t.Fatalf("统计失败: %v", err)
+	}
+	return count
+}
+
+func TestRecycleBinRecursiveDeleteAndRestore(t *testing.T) {
+	setupSharedFolder(t)
+	ctx := context.Background()
+	service := &SugarRecycleBinService{}
+
+	// 删除非空文件夹时连同其中的文件一起移入回收站
+	deleteItem(t, "folder-1", "1")
+	if count := countWhere(t, &sugar.SugarWorkspaces{}, "deleted_at IS NULL"); count != 0 {
+		t.Fatalf("文件夹及其内容都应被删除，剩余 %d", count)
+	}
+
+	// 回收站中只显示直接删除的文件夹，并标明删除者
+	list := recycleBinList(t, "12")
+	if len(list) != 1 || list[0].Id != "folder-1" || list[0].ItemCount != 1 || list[0].DeletedByName != "Alice" || list[0].PurgeAt == nil {
+		t.Fatalf("回收站列表不符合预期: %+v", list)
+	}
+	if _, _, err := service.GetRecycleBinList(ctx, sugarReq.SugarRecycleBinSearch{TeamId: "team-1"}, "3"); err == nil {
+		t.Fatal("非团队成员不应查看回收站")
+	}
+
+	// 查看者不能恢复；随文件夹删除的文件不能单独恢复
+	_, err := service.RestoreItem(ctx, sugarReq.SugarRecycleBinRestoreRequest{Id: "folder-1"}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	if _, err = service.RestoreItem(ctx, sugarReq.SugarRecycleBinRestoreRequest{Id: testFileId}, "1"); err == nil || !strings.Contains(err.Error(), "一起删除") {
+		t.Fatalf("不应单独恢复随文件夹删除的文件: %v", err)
+	}
+
+	// 原位置已有同名文件夹时返回冲突和建议名称，选择重命名后恢复
+	if _, err = (&SugarFoldersService{}).CreateFolder(ctx, &sugarReq.SugarFoldersCreateFolderRequest{Name: "报表", TeamId: "team-1", Type: "folder"}, "1"); err != nil {
+		t.Fatalf("创建同名文件夹失败: %v", err)
+	}
+	_, err = service.RestoreItem(ctx, sugarReq.SugarRecycleBinRestoreRequest{Id: "folder-1"}, "1")
+	var conflictErr *RecycleBinConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Conflict.SuggestedName != "报表 (1)" {
+		t.Fatalf("应返回同名冲突: %v", err)
+	}
+	restored, err := service.RestoreItem(ctx, sugarReq.SugarRecycleBinRestoreRequest{Id: "folder-1", ConflictStrategy: sugarReq.RecycleBinConflictRename}, "1")
+	if err != nil || !restored.Renamed || restored.Name != "报表 (1)" || restored.RestoredCount != 2 {
+		t.Fatalf("重命名恢复结果不符合预期: %+v %v", restored, err)
+	}
+	var file sugar.SugarWorkspaces
+	global.GVA_DB.Where("id = ?", testFileId).First(&file)
+	if file.DeletedAt != nil || file.DeletedRoot != nil || file.ParentId == nil || *file.ParentId != "folder-1" {
+		t.Fatalf("文件应随文件夹恢复到原位置: %+v", file)
+	}
+	if list = recycleBinList(t, "1"); len(list) != 0 {
+		t.Fatalf("恢复后回收站应为空: %+v", list)
+	}
+}
+
+func TestRecycleBinDeleteTooDeep(t *testing.T) {
+	setupSharedFolder(t)
+
+	// 层级超过上限时拒绝删除，不留下无法访问的深层内容
+	parentId := "folder-1"
+	for depth := 1; depth <= workspaceMaxDepth; depth++ {
+		id := fmt.Sprintf("deep-%d", depth)
+		seedTestData(t, fmt.Sprintf(`INSERT INTO sugar_workspaces (id, name, type, team_id, parent_id) VALUES ('%s', '%s', 'folder', 'team-1', '%s')`, id, id, parentId))
+		parentId = id
+	}
+	_, err := (&SugarFoldersService{}).DeleteItem(context.Background(), &sugarReq.SugarFoldersDeleteRequest{Id: "folder-1"}, "1")
+	if !errors.Is(err, ErrWorkspaceTooDeep) {
+		t.Fatalf("层级过深时应拒绝删除: %v", err)
+	}
+	if count := countWhere(t, &sugar.SugarWorkspaces{}, "deleted_at IS NOT NULL"); count != 0 {
+		t.Fatalf("拒绝删除时不应删除任何项目，已删除 %d", count)
+	}
+
+	// 去掉最深的一层后可以完整删除
+	seedTestData(t, fmt.Sprintf(`DELETE FROM sugar_workspaces WHERE id = 'deep-%d'`, workspaceMaxDepth))
+	deleteItem(t, "folder-1", "1")
+	if count := countWhere(t, &sugar.SugarWorkspaces{}, "deleted_at IS NULL"); count != 0 {
+		t.Fatalf("文件夹及其内容都应被删除，剩余 %d", count)
+	}
+}
+
+func TestRecycleBinRestoreToRootAndPurge(t *testing.T) {
+	setupSharedFolder(t)
+	ctx := context.Background()
+	service := &SugarRecycleBinService{}
+
+	// 先单独删除文件，再删除文件夹：回收站中是两项
+	deleteItem(t, testFileId, "1")
+	deleteItem(t, "folder-1", "10")
+	list := recycleBinList(t, "1")
+	if len(list) != 2 || list[0].Id != "folder-1" && list[1].Id != "folder-1" {
+		t.Fatalf("回收站应包含两项: %+v", list)
+	}
+	for _, item := range list {
+		if item.Id == testFileId && (item.ParentName != "报表" || item.ItemCount != 0) {
+			t.Fatalf("单独删除的文件应标明原文件夹: %+v", item)
+		}
+	}
+
+	// 彻底删除文件夹后，文件恢复到团队根目录
+	if _, err := service.PurgeItem(ctx, "folder-1", "12"); err == nil {
+		t.Fatal("查看者不应彻底删除")
+	}
+	if purged, err := service.PurgeItem(ctx, "folder-1", "1"); err != nil || purged != 1 {
+		t.Fatalf("彻底删除失败: %d %v", purged, err)
+	}
+	restored, err := service.RestoreItem(ctx, sugarReq.SugarRecycleBinRestoreRequest{Id: testFileId}, "1")
+	if err != nil || !restored.MovedToRoot || restored.ParentId != nil || restored.Renamed {
+		t.Fatalf("原文件夹不存在时应恢复到根目录: %+v %v", restored, err)
+	}
+
+	// 定时清理只删除超过保留期限的项目，连同历史版本、授权和分享链接
+	saveWorkbook(t, "1", `"0": {"0": {"v": "清理前"}}`)
+	grantWorkspace(t, testFileId, sugar.WorkspaceGranteeUser, "3", sugar.WorkspacePermissionViewer)
+	createShareLink(t, sugarReq.SugarShareLinkCreateRequest{PermissionLevel: sugar.ShareLinkPermissionViewer}, "1")
+	deleteItem(t, testFileId, "1")
+	purged, err := PurgeExpiredWorkspaces(global.GVA_DB, time.Now())
+	if err != nil || purged != 0 {
+		t.Fatalf("未过期的项目不应被清理: %d %v", purged, err)
+	}
+	global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("id = ?", testFileId).Update("deleted_at", time.Now().AddDate(0, 0, -31))
+	if purged, err = PurgeExpiredWorkspaces(global.GVA_DB, time.Now()); err != nil || purged != 1 {
+		t.Fatalf("过期的项目应被清理: %d %v", purged, err)
+	}
+	for model, column := range map[interface{}]string{
+		&sugar.SugarWorkspaces{}:           "id",
+		&sugar.SugarFileVersions{}:         "file_id",
+		&sugar.SugarWorkspacePermissions{}: "workspace_id",
+		&sugar.SugarShareLinks{}:           "workspace_id",
+	} {
+		if count := countWhere(t, model, column+" = ?", testFileId); count != 0 {
+			t.Fatalf("%T 中仍有 %d 条相关记录", model, count)
+		}
+	}
+}
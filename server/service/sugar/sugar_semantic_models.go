@@ -12,40 +12,89 @@ import (
 type SugarSemanticModelsService struct{}
 
 // CreateSugarSemanticModels 创建Sugar指标语义表记录
-func (s *SugarSemanticModelsService) CreateSugarSemanticModels(ctx context.Context, model *sugar.SugarSemanticModels) (err error) {
+func (s *SugarSemanticModelsService) CreateSugarSemanticModels(ctx context.Context, model *sugar.SugarSemanticModels, userId string) (err error) {
+	if err = authorizeTeamResource(ctx, model.TeamId, userId, SugarResourceSemanticModel, SugarActionEdit); err != nil {
+		return err
+	}
+	if _, err = ParseAnonymizationPolicy(model.AnonymizationPolicy); err != nil {
+		return err
+	}
+	if err = ValidateAnalysisSemantics(model); err != nil {
+		return err
+	}
 	err = global.GVA_DB.Create(model).Error
 	return err
 }
 
-// DeleteSugarSemanticModels 删除Sugar指标语义表记录
-func (s *SugarSemanticModelsService) DeleteSugarSemanticModels(ctx context.Context, id string, userId string) (err error) {
+// DeleteSugarSemanticModels 删除Sugar指标语义表记录，仍被工作簿引用时需 force 确认
+func (s *SugarSemanticModelsService) DeleteSugarSemanticModels(ctx context.Context, id string, userId string, force bool) (err error) {
 	var model sugar.SugarSemanticModels
 	if err = global.GVA_DB.Where("id = ?", id).First(&model).Error; err != nil {
 		return errors.New("记录不存在")
 	}
-	if *model.CreatedBy != userId {
-		return errors.New("无权删除")
+	if err = authorizeTeamResource(ctx, model.TeamId, userId, SugarResourceSemanticModel, SugarActionDelete); err != nil {
+		return err
+	}
+	if !force {
+		if err = checkModelDeletion(ctx, []sugar.SugarSemanticModels{model}, userId); err != nil {
+			return err
+		}
 	}
 	err = global.GVA_DB.Delete(&sugar.SugarSemanticModels{}, "id = ?", id).Error
 	return err
 }
 
-// DeleteSugarSemanticModelsByIds 批量删除Sugar指标语义表记录
-func (s *SugarSemanticModelsService) DeleteSugarSemanticModelsByIds(ctx context.Context, ids []string, userId string) (err error) {
-	err = global.GVA_DB.Where("id IN ? AND created_by = ?", ids, userId).Delete(&[]sugar.SugarSemanticModels{}).Error
+// DeleteSugarSemanticModelsByIds 批量删除Sugar指标语义表记录，其中有模型仍被工作簿引用时需 force 确认
+func (s *SugarSemanticModelsService) DeleteSugarSemanticModelsByIds(ctx context.Context, ids []string, userId string, force bool) (err error) {
+	// 只批量删除用户有删除权限的团队中的语义模型
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceSemanticModel, SugarActionDelete)
+	if err != nil || len(teamIds) == 0 {
+		return err
+	}
+	if !force {
+		var models []sugar.SugarSemanticModels
+		if err = global.GVA_DB.Where("id IN ? AND team_id IN ?", ids, teamIds).Find(&models).Error; err != nil {
+			return err
+		}
+		if err = checkModelDeletion(ctx, models, userId); err != nil {
+			return err
+		}
+	}
+	err = global.GVA_DB.Where("id IN ? AND team_id IN ?", ids, teamIds).Delete(&[]sugar.SugarSemanticModels{}).Error
 	return err
 }
 
-// UpdateSugarSemanticModels 更新Sugar指标语义表记录
-func (s *SugarSemanticModelsService) UpdateSugarSemanticModels(ctx context.Context, model sugar.SugarSemanticModels, userId string) (err error) {
+// UpdateSugarSemanticModels 更新Sugar指标语义表记录，重命名模型或移除仍被工作簿引用的字段时需 force 确认
+func (s *SugarSemanticModelsService) UpdateSugarSemanticModels(ctx context.Context, model sugar.SugarSemanticModels, userId string, force bool) (err error) {
 	var oldModel sugar.SugarSemanticModels
 	if err = global.GVA_DB.Where("id = ?", model.Id).First(&oldModel).Error; err != nil {
 		return errors.New("记录不存在")
 	}
-	if *oldModel.CreatedBy != userId {
-		return errors.New("无权更新")
+	if err = authorizeTeamResource(ctx, oldModel.TeamId, userId, SugarResourceSemanticModel, SugarActionEdit); err != nil {
+		return err
+	}
+	// 修改所属团队需要原团队和目标团队的管理权限
+	if model.TeamId != nil && (oldModel.TeamId == nil || *model.TeamId != *oldModel.TeamId) {
+		if err = authorizeTeamResource(ctx, oldModel.TeamId, userId, SugarResourceSemanticModel, SugarActionManage); err != nil {
+			return err
+		}
+		if err = authorizeTeamAction(ctx, *model.TeamId, userId, SugarResourceSemanticModel, SugarActionManage); err != nil {
+			return err
+		}
+	}
+	if _, err = ParseAnonymizationPolicy(model.AnonymizationPolicy); err != nil {
+		return err
+	}
+	if err = ValidateAnalysisSemantics(&model); err != nil {
+		return err
+	}
+	if !force {
+		if err = checkModelUpdate(ctx, &oldModel, &model, userId); err != nil {
+			return err
+		}
 	}
-	err = global.GVA_DB.Model(&sugar.SugarSemanticModels{}).Where("id = ?", model.Id).Updates(&model).Error
+	// 噪声种子只由 ensureAnonymizationSeed 生成，编辑模型时不覆盖
+	err = global.GVA_DB.Model(&sugar.SugarSemanticModels{}).Where("id = ?", model.Id).Omit("anonymization_seed").Updates(&model).Error
 	return err
 }
 
@@ -54,9 +103,8 @@ func (s *SugarSemanticModelsService) GetSugarSemanticModels(ctx context.Context,
 	if err = global.GVA_DB.Where("id = ?", id).First(&model).Error; err != nil {
 		return model, errors.New("记录不存在")
 	}
-	// TODO: 增加团队成员权限校验
-	if *model.CreatedBy != userId {
-		// return model, errors.New("无权查看")
+	if err = authorizeTeamResource(ctx, model.TeamId, userId, SugarResourceSemanticModel, SugarActionRead); err != nil {
+		return model, err
 	}
 	return model, nil
 }
@@ -66,8 +114,7 @@ func (s *SugarSemanticModelsService) GetSugarSemanticModelsListByUser(ctx contex
 	limit := info.PageSize
 	offset := info.PageSize * (info.Page - 1)
 
-	var teamIds []string
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceSemanticModel, SugarActionRead)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,534 @@
+package sugar
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/flipped-aurora/gin-vue-admin/server/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	shareLinkSessionTTL = 12 * time.Hour // 打开链接后会话的有效期
+
+	// 访问密码错误次数限制：同一来源和同一链接分别计数，超过次数后在窗口期内拒绝继续尝试
+	shareLinkFailureWindow    = 15 * time.Minute
+	shareLinkMaxClientFailure = 5
+	shareLinkMaxLinkFailure   = 20
+)
+
+// ErrShareLinkPasswordRequired 链接设置了访问密码但请求未提供
+var ErrShareLinkPasswordRequired = errors.New("该链接需要访问密码")
+
+// sugarFormulaPattern 匹配会执行实时查询的公式：SUGAR 系列公式和调用智能体的 AI.FETCH
+var sugarFormulaPattern = regexp.MustCompile(`(?i)\b(?:SUGAR\.[A-Z]+|AI\.FETCH)\s*\(`)
+
+const shareLinkFailureRedisPrefix = "sugar:share-link:failures:"
+
+// shareLinkFailureStore 记录访问密码的错误次数
+// keys 为计数键到错误次数上限的映射，同一来源和同一链接分别计数
+type shareLinkFailureStore interface {
+	// retryAfter 任一计数达到上限时返回需要等待的时间
+	retryAfter(ctx context.Context, keys map[string]int, now time.Time) time.Duration
+	fail(ctx context.Context, keys map[string]int, now time.Time)
+	reset(ctx context.Context, key string)
+}
+
+// shareLinkFailureLimiter 在本进程内记录访问密码的错误次数，未启用 redis 时使用
+type shareLinkFailureLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+var shareLinkLimiter = &shareLinkFailureLimiter{failures: map[string][]time.Time{}}
+
+// shareLinkFailures 按配置返回错误次数的记录方式
+// 启用 redis 时在多个实例之间共享计数，避免通过轮流访问不同实例绕过次数限制
+func shareLinkFailures() shareLinkFailureStore {
+	if global.GVA_REDIS == nil {
+		return shareLinkLimiter
+	}
+	return redisShareLinkFailures{client: global.GVA_REDIS}
+}
+
+// recent 返回窗口期内的错误时间，同时清理过期记录
+func (l *shareLinkFailureLimiter) recent(key string, now time.Time) []time.Time {
+	times := l.failures[key]
+	kept := times[:0]
+	for _, at := range times {
+		if now.Sub(at) < shareLinkFailureWindow {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = kept
+	return kept
+}
+
+// retryAfter 任一计数达到上限时返回需要等待的时间
+func (l *shareLinkFailureLimiter) retryAfter(_ context.Context, keys map[string]int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for key, limit := range keys {
+		if times := l.recent(key, now); len(times) >= limit {
+			if d := shareLinkFailureWindow - now.Sub(times[len(times)-limit]); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+func (l *shareLinkFailureLimiter) fail(_ context.Context, keys map[string]int, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range keys {
+		l.failures[key] = append(l.recent(key, now), now)
+	}
+}
+
+func (l *shareLinkFailureLimiter) reset(_ context.Context, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+// redisShareLinkFailures 在 redis 中记录访问密码的错误次数，每个计数键一个有序集合，成员的分值为错误时间
+// redis 不可用时退回到本进程内计数，不因此放开次数限制
+type redisShareLinkFailures struct {
+	client redis.UniversalClient
+}
+
+// recent 返回窗口期内的错误时间，同时清理过期记录
+func (r redisShareLinkFailures) recent(ctx context.Context, key string, now time.Time) ([]time.Time, error) {
+	key = shareLinkFailureRedisPrefix + key
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-shareLinkFailureWindow).UnixNano(), 10))
+	scores := pipe.ZRangeWithScores(ctx, key, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, 0, len(scores.Val()))
+	for _, member := range scores.Val() {
+		times = append(times, time.Unix(0, int64(member.Score)))
+	}
+	return times, nil
+}
+
+func (r redisShareLinkFailures) retryAfter(ctx context.Context, keys map[string]int, now time.Time) time.Duration {
+	var wait time.Duration
+	for key, limit := range keys {
+		times, err := r.recent(ctx, key, now)
+		if err != nil {
+			global.GVA_LOG.Warn("读取分享链接密码错误次数失败，使用本进程内的计数", zap.Error(err))
+			return shareLinkLimiter.retryAfter(ctx, keys, now)
+		}
+		if len(times) >= limit {
+			if d := shareLinkFailureWindow - now.Sub(times[len(times)-limit]); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+func (r redisShareLinkFailures) fail(ctx context.Context, keys map[string]int, now time.Time) {
+	// 成员使用随机ID，同一时刻的多次错误分别计数
+	member := uuid.New().String()
+	pipe := r.client.TxPipeline()
+	for key := range keys {
+		pipe.ZAdd(ctx, shareLinkFailureRedisPrefix+key, redis.Z{Score: float64(now.UnixNano()), Member: member})
+		pipe.Expire(ctx, shareLinkFailureRedisPrefix+key, shareLinkFailureWindow)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		global.GVA_LOG.Warn("记录分享链接密码错误次数失败，使用本进程内的计数", zap.Error(err))
+		shareLinkLimiter.fail(ctx, keys, now)
+	}
+}
+
+func (r redisShareLinkFailures) reset(ctx context.Context, key string) {
+	if err := r.client.Del(ctx, shareLinkFailureRedisPrefix+key).Err(); err != nil {
+		global.GVA_LOG.Warn("清除分享链接密码错误次数失败", zap.Error(err))
+	}
+	shareLinkLimiter.reset(ctx, key)
+}
+
+// shareLinkAction 链接权限级别要求创建者仍具有的操作权限
+func shareLinkAction(level string) SugarAction {
+	if level == sugar.ShareLinkPermissionEditor {
+		return SugarActionEdit
+	}
+	return SugarActionRead
+}
+
+// workbookCells 返回工作簿全部工作表中的单元格，修改返回的单元格即修改工作簿本身
+func workbookCells(workbook map[string]interface{}) []map[string]interface{} {
+	sheets, _ := workbook["sheets"].(map[string]interface{})
+	var cells []map[string]interface{}
+	for _, sheet := range sheets {
+		sheetData, ok := sheet.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		forEachIndexed(sheetData["cellData"], func(_ int, rowData interface{}) {
+			forEachIndexed(rowData, func(_ int, cellData interface{}) {
+				if cell, ok := cellData.(map[string]interface{}); ok {
+					cells = append(cells, cell)
+				}
+			})
+		})
+	}
+	return cells
+}
+
+// freezeSugarFormulas 将工作簿中的 SUGAR 和 AI.FETCH 公式替换为保存时的计算结果，返回冻结的单元格数
+// 共享公式（si）的源单元格为这类公式时，引用它的单元格一并冻结
+func freezeSugarFormulas(content datatypes.JSON) (datatypes.JSON, int, error) {
+	var workbook map[string]interface{}
+	if err := json.Unmarshal(content, &workbook); err != nil {
+		return nil, 0, err
+	}
+	cells := workbookCells(workbook)
+
+	frozen := 0
+	sharedIds := map[string]bool{}
+	for _, cell := range cells {
+		if formula, ok := cell["f"].(string); ok && sugarFormulaPattern.MatchString(formula) {
+			if si, ok := cell["si"].(string); ok && si != "" {
+				sharedIds[si] = true
+			}
+			delete(cell, "f")
+			delete(cell, "si")
+			frozen++
+		}
+	}
+	for _, cell := range cells {
+		if si, ok := cell["si"].(string); ok && sharedIds[si] {
+			delete(cell, "f")
+			delete(cell, "si")
+			frozen++
+		}
+	}
+
+	result, err := json.Marshal(workbook)
+	return result, frozen, err
+}
+
+type SugarShareLinksService struct{}
+
+// CreateShareLink 为工作簿创建分享链接，需要对文件有分享权限
+func (s *SugarShareLinksService) CreateShareLink(ctx context.Context, req sugarReq.SugarShareLinkCreateRequest, userId string) (*sugar.SugarShareLinks, error) {
+	if req.PermissionLevel != sugar.ShareLinkPermissionEditor && req.PermissionLevel != sugar.ShareLinkPermissionViewer {
+		return nil, errors.New("权限级别只能是 editor 或 viewer")
+	}
+	if req.FreezeData && req.PermissionLevel == sugar.ShareLinkPermissionEditor {
+		return nil, errors.New("冻结数据的链接只能设置为只读")
+	}
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, errors.New("过期时间必须晚于当前时间")
+	}
+
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND type = ? AND deleted_at IS NULL", req.WorkspaceId, "file").First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionShare); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 24)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, errors.New("生成链接失败")
+	}
+	link := sugar.SugarShareLinks{
+		WorkspaceId:     &req.WorkspaceId,
+		Token:           base64.RawURLEncoding.EncodeToString(secret),
+		PermissionLevel: req.PermissionLevel,
+		ExpiresAt:       req.ExpiresAt,
+		IsActive:        true,
+		FreezeData:      req.FreezeData,
+		CreatedBy:       &userId,
+		CreatedAt:       &now,
+		UpdatedBy:       &userId,
+		UpdatedAt:       &now,
+	}
+	if req.Password != "" {
+		hash := utils.BcryptHash(req.Password)
+		link.PasswordHash = &hash
+	}
+	if req.FreezeData {
+		content := workspace.Content
+		if len(content) == 0 {
+			content = datatypes.JSON("{}")
+		}
+		snapshot, frozen, err := freezeSugarFormulas(content)
+		if err != nil {
+			global.GVA_LOG.Error("冻结工作簿数据失败", zap.String("workspaceId", req.WorkspaceId), zap.Error(err))
+			return nil, errors.New("工作簿内容格式错误，无法冻结数据")
+		}
+		link.Snapshot = snapshot
+		global.GVA_LOG.Info("冻结工作簿 SUGAR 公式", zap.String("workspaceId", req.WorkspaceId), zap.Int("cells", frozen))
+	}
+	if err = global.GVA_DB.WithContext(ctx).Create(&link).Error; err != nil {
+		global.GVA_LOG.Error("创建分享链接失败", zap.String("workspaceId", req.WorkspaceId), zap.Error(err))
+		return nil, errors.New("创建分享链接失败")
+	}
+
+	global.GVA_LOG.Info("创建分享链接", zap.Int64("id", link.Id), zap.String("workspaceId", req.WorkspaceId),
+		zap.String("level", link.PermissionLevel), zap.Bool("password", link.PasswordHash != nil), zap.Bool("freeze", link.FreezeData), zap.String("by", userId))
+	return &link, nil
+}
+
+// RevokeShareLink 关闭分享链接，创建者或对文件有分享权限的用户可以关闭
+func (s *SugarShareLinksService) RevokeShareLink(ctx context.Context, id int64, userId string) error {
+	var link sugar.SugarShareLinks
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("分享链接不存在")
+		}
+		return errors.New("查询分享链接失败")
+	}
+	if link.CreatedBy == nil || *link.CreatedBy != userId {
+		var workspace sugar.SugarWorkspaces
+		if err := global.GVA_DB.WithContext(ctx).Where("id = ?", *link.WorkspaceId).First(&workspace).Error; err != nil {
+			return errors.New("文件不存在")
+		}
+		if err := authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionShare); err != nil {
+			return err
+		}
+	}
+	err := global.GVA_DB.WithContext(ctx).Model(&link).Updates(map[string]interface{}{
+		"is_active":  false,
+		"updated_by": userId,
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		global.GVA_LOG.Error("关闭分享链接失败", zap.Int64("id", id), zap.Error(err))
+		return errors.New("关闭分享链接失败")
+	}
+	global.GVA_LOG.Info("关闭分享链接", zap.Int64("id", id), zap.String("workspaceId", *link.WorkspaceId), zap.String("by", userId))
+	return nil
+}
+
+// GetShareLinkList 获取工作簿的全部分享链接，需要对文件有分享权限
+func (s *SugarShareLinksService) GetShareLinkList(ctx context.Context, workspaceId string, userId string) ([]sugarRes.SugarShareLinkItem, error) {
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", workspaceId).First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionShare); err != nil {
+		return nil, err
+	}
+
+	var links []sugar.SugarShareLinks
+	if err = global.GVA_DB.WithContext(ctx).Omit("snapshot").Where("workspace_id = ?", workspaceId).Order("id DESC").Find(&links).Error; err != nil {
+		global.GVA_LOG.Error("查询分享链接失败", zap.String("workspaceId", workspaceId), zap.Error(err))
+		return nil, errors.New("查询分享链接失败")
+	}
+	creatorIds := make([]string, 0, len(links))
+	for _, link := range links {
+		if link.CreatedBy != nil {
+			creatorIds = append(creatorIds, *link.CreatedBy)
+		}
+	}
+	creatorNames := lookupUserNames(ctx, creatorIds)
+
+	now := time.Now()
+	list := make([]sugarRes.SugarShareLinkItem, 0, len(links))
+	for _, link := range links {
+		item := sugarRes.SugarShareLinkItem{
+			Id:              link.Id,
+			WorkspaceId:     workspaceId,
+			Token:           link.Token,
+			PermissionLevel: link.PermissionLevel,
+			HasPassword:     link.PasswordHash != nil,
+			ExpiresAt:       link.ExpiresAt,
+			Expired:         link.ExpiresAt != nil && !link.ExpiresAt.After(now),
+			IsActive:        link.IsActive,
+			FreezeData:      link.FreezeData,
+			CreatedBy:       link.CreatedBy,
+			CreatedAt:       link.CreatedAt,
+		}
+		if link.CreatedBy != nil {
+			item.CreatorName = creatorNames[*link.CreatedBy]
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// validateLink 校验链接仍可使用：未关闭、未过期、文件未删除，且创建者仍有相应的权限
+func (s *SugarShareLinksService) validateLink(ctx context.Context, link *sugar.SugarShareLinks) (*sugar.SugarWorkspaces, error) {
+	if !link.IsActive {
+		return nil, errors.New("分享链接不存在或已关闭")
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("分享链接已过期")
+	}
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND type = ? AND deleted_at IS NULL", *link.WorkspaceId, "file").First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("分享的文件已被删除")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	if link.CreatedBy == nil {
+		return nil, errors.New("分享链接已失效")
+	}
+	if err = authorizeWorkspaceItem(ctx, &workspace, *link.CreatedBy, shareLinkAction(link.PermissionLevel)); err != nil {
+		global.GVA_LOG.Info("分享链接创建者已无权限", zap.Int64("id", link.Id), zap.String("createdBy", *link.CreatedBy), zap.Error(err))
+		return nil, errors.New("分享链接已失效")
+	}
+	return &workspace, nil
+}
+
+// sessionSignature 会话令牌签名，包含链接令牌和密码摘要，链接重新生成或修改密码后旧会话随之失效
+func sessionSignature(link *sugar.SugarShareLinks, payload string) string {
+	mac := hmac.New(sha256.New, []byte(global.GVA_CONFIG.JWT.SigningKey))
+	mac.Write([]byte(payload))
+	mac.Write([]byte("." + link.Token))
+	if link.PasswordHash != nil {
+		mac.Write([]byte("." + *link.PasswordHash))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func issueShareSession(link *sugar.SugarShareLinks, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", link.Id, expiresAt.Unix())
+	return payload + "." + sessionSignature(link, payload)
+}
+
+// OpenSharedWorkbook 通过链接打开工作簿，校验访问密码并返回内容和会话令牌
+// clientKey 标识请求来源（通常为客户端IP），用于限制密码错误次数
+func (s *SugarShareLinksService) OpenSharedWorkbook(ctx context.Context, req sugarReq.SugarShareLinkOpenRequest, clientKey string) (*sugarRes.SugarSharedWorkbookResponse, error) {
+	now := time.Now()
+	limits := map[string]int{
+		req.Token + "|" + clientKey: shareLinkMaxClientFailure,
+		req.Token:                   shareLinkMaxLinkFailure,
+	}
+	failures := shareLinkFailures()
+	if wait := failures.retryAfter(ctx, limits, now); wait > 0 {
+		return nil, fmt.Errorf("密码错误次数过多，请 %d 分钟后再试", int(wait.Minutes())+1)
+	}
+
+	var link sugar.SugarShareLinks
+	if err := global.GVA_DB.WithContext(ctx).Where("token = ?", req.Token).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("分享链接不存在或已关闭")
+		}
+		return nil, errors.New("查询分享链接失败")
+	}
+	workspace, err := s.validateLink(ctx, &link)
+	if err != nil {
+		return nil, err
+	}
+	if link.PasswordHash != nil {
+		if req.Password == "" {
+			return nil, ErrShareLinkPasswordRequired
+		}
+		if !utils.BcryptCheck(req.Password, *link.PasswordHash) {
+			failures.fail(ctx, limits, now)
+			global.GVA_LOG.Warn("分享链接密码错误", zap.Int64("id", link.Id), zap.String("client", clientKey))
+			return nil, errors.New("访问密码错误")
+		}
+		failures.reset(ctx, req.Token+"|"+clientKey)
+	}
+
+	sessionExpiresAt := now.Add(shareLinkSessionTTL)
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(sessionExpiresAt) {
+		sessionExpiresAt = *link.ExpiresAt
+	}
+	result := &sugarRes.SugarSharedWorkbookResponse{
+		SessionToken:     issueShareSession(&link, sessionExpiresAt),
+		SessionExpiresAt: sessionExpiresAt,
+		PermissionLevel:  link.PermissionLevel,
+		ReadOnly:         link.PermissionLevel != sugar.ShareLinkPermissionEditor,
+		Frozen:           link.FreezeData,
+		Content:          workspace.Content,
+		Revision:         workspace.Revision,
+	}
+	if workspace.Name != nil {
+		result.Name = *workspace.Name
+	}
+	if link.FreezeData {
+		result.Content = link.Snapshot
+	}
+	global.GVA_LOG.Info("通过分享链接打开工作簿", zap.Int64("id", link.Id), zap.String("workspaceId", *workspace.Id), zap.String("client", clientKey))
+	return result, nil
+}
+
+// resolveShareSession 校验会话令牌并返回对应的链接和文件
+func (s *SugarShareLinksService) resolveShareSession(ctx context.Context, sessionToken string) (*sugar.SugarShareLinks, *sugar.SugarWorkspaces, error) {
+	invalid := errors.New("会话无效或已过期，请重新打开分享链接")
+	parts := strings.Split(sessionToken, ".")
+	if len(parts) != 3 {
+		return nil, nil, invalid
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, nil, invalid
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return nil, nil, invalid
+	}
+	var link sugar.SugarShareLinks
+	if err = global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&link).Error; err != nil {
+		return nil, nil, invalid
+	}
+	expected := sessionSignature(&link, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, nil, invalid
+	}
+	workspace, err := s.validateLink(ctx, &link)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &link, workspace, nil
+}
+
+// SaveSharedWorkbook 通过可编辑的链接保存工作簿，以链接创建者的身份保存并记录历史版本
+func (s *SugarShareLinksService) SaveSharedWorkbook(ctx context.Context, sessionToken string, content, baseContent datatypes.JSON, revision int) (*sugarRes.SugarWorkbookSaveResponse, error) {
+	link, workspace, err := s.resolveShareSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if link.PermissionLevel != sugar.ShareLinkPermissionEditor {
+		return nil, errors.New("只读链接不能保存修改")
+	}
+	global.GVA_LOG.Info("通过分享链接保存工作簿", zap.Int64("id", link.Id), zap.String("workspaceId", *workspace.Id))
+	return (&SugarWorkspacesService{}).SaveWorkbookContent(ctx, *workspace.Id, content, baseContent, revision, *link.CreatedBy)
+}
@@ -0,0 +1,191 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"gorm.io/datatypes"
+)
+
+func TestFreezeSugarFormulas(t *testing.T) {
+	content := workbookJSON(`"0": {
+		"0": {"f": "=SUGAR.GET(\"销售\", \"金额\")", "si": "s1", "v": 100},
+		"1": {"si": "s1", "v": 200},
+		"2": {"f": "=SUM(A1:B1)", "v": 300},
+		"3": {"f": "=sugar.calc(\"销售\", \"金额\", \"SUM\")", "v": 400}
+	}`)
+	frozen, count, err := freezeSugarFormulas(datatypes.JSON(content))
+	if err != nil {
+		t.Fatalf("冻结失败: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("应冻结 3 个单元格，实际 %d", count)
+	}
+	cells := univerCellMatrix(mustSheet(t, frozen)["cellData"])
+	for col, want := range map[int]float64{0: 100, 1: 200, 3: 400} {
+		cell := cells[univerCellPosition{row: 0, col: col}]
+		if _, ok := cell["f"]; ok || cell["si"] != nil || cell["v"] != want {
+			t.Fatalf("第 %d 列应只保留计算结果: %v", col, cell)
+		}
+	}
+	if cell := cells[univerCellPosition{row: 0, col: 2}]; cell["f"] != "=SUM(A1:B1)" {
+		t.Fatalf("普通公式不应被冻结: %v", cell)
+	}
+}
+
+func mustSheet(t *testing.T, content datatypes.JSON) map[string]interface{} {
+	t.Helper()
+	var workbook struct {
+		Sheets map[string]map[string]interface{} `json:"sheets"`
+	}
+	if err := json.Unmarshal(content, &workbook); err != nil {
+		t.Fatalf("解析工作簿失败: %v", err)
+	}
+	return workbook.Sheets["sheet-1"]
+}
+
+func createShareLink(t *testing.T, req sugarReq.SugarShareLinkCreateRequest, userId string) *sugar.SugarShareLinks {
+	t.Helper()
+	req.WorkspaceId = testFileId
+	link, err := (&SugarShareLinksService{}).CreateShareLink(context.Background(), req, userId)
+	if err != nil {
+		t.Fatalf("创建分享链接失败: %v", err)
+	}
+	return link
+}
+
+func TestShareLinkPasswordAndFreeze(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	service := &SugarShareLinksService{}
+
+	// 查看者不能分享；冻结的链接不能设为可编辑
+	_, err := service.CreateShareLink(ctx, sugarReq.SugarShareLinkCreateRequest{WorkspaceId: testFileId, PermissionLevel: sugar.ShareLinkPermissionViewer}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = service.CreateShareLink(ctx, sugarReq.SugarShareLinkCreateRequest{WorkspaceId: testFileId, PermissionLevel: sugar.ShareLinkPermissionEditor, FreezeData: true}, "1")
+	if err == nil {
+		t.Fatal("冻结数据的链接不应允许编辑")
+	}
+
+	err = global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("id = ?", testFileId).
+		Update("content", workbookJSON(`"0": {"0": {"f": "=SUGAR.GET(\"销售\", \"金额\")", "v": 42}, "1": {"f": "=AI.FETCH(\"助手\", \"总结\")", "v": "增长"}}`)).Error
+	if err != nil {
+		t.Fatalf("更新文件内容失败: %v", err)
+	}
+	link := createShareLink(t, sugarReq.SugarShareLinkCreateRequest{PermissionLevel: sugar.ShareLinkPermissionViewer, Password: "secret", FreezeData: true}, "1")
+
+	// 需要密码
+	_, err = service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token}, "ip-1")
+	if !errors.Is(err, ErrShareLinkPasswordRequired) {
+		t.Fatalf("未提供密码时应提示需要密码: %v", err)
+	}
+
+	// 连续输错密码后，同一来源即使密码正确也被暂时拒绝，其他来源不受影响
+	for i := 0; i < shareLinkMaxClientFailure; i++ {
+		if _, err = service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token, Password: "wrong"}, "ip-1"); err == nil || !strings.Contains(err.Error(), "密码错误") {
+			t.Fatalf("密码错误时应拒绝: %v", err)
+		}
+	}
+	_, err = service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token, Password: "secret"}, "ip-1")
+	if err == nil || !strings.Contains(err.Error(), "错误次数过多") {
+		t.Fatalf("错误次数过多后应暂时拒绝: %v", err)
+	}
+	opened, err := service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token, Password: "secret"}, "ip-2")
+	if err != nil {
+		t.Fatalf("其他来源使用正确密码应能打开: %v", err)
+	}
+
+	// 冻结的链接返回快照：SUGAR 和 AI.FETCH 公式只保留创建时的结果，之后的修改不可见
+	if !opened.ReadOnly || !opened.Frozen {
+		t.Fatalf("冻结的查看链接应为只读快照: %+v", opened)
+	}
+	cell := univerCellMatrix(mustSheet(t, opened.Content)["cellData"])[univerCellPosition{row: 0, col: 0}]
+	if _, ok := cell["f"]; ok || cell["v"] != float64(42) {
+		t.Fatalf("快照中的 SUGAR 公式应替换为结果: %v", cell)
+	}
+	cell = univerCellMatrix(mustSheet(t, opened.Content)["cellData"])[univerCellPosition{row: 0, col: 1}]
+	if _, ok := cell["f"]; ok || cell["v"] != "增长" {
+		t.Fatalf("快照中的 AI.FETCH 公式应替换为结果: %v", cell)
+	}
+	saveWorkbook(t, "1", `"0": {"0": {"v": "新内容"}}`)
+	again, err := service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token, Password: "secret"}, "ip-2")
+	if err != nil || string(again.Content) != string(opened.Content) {
+		t.Fatalf("冻结的链接应始终返回创建时的快照: %v", err)
+	}
+
+	// 只读会话不能保存
+	_, err = service.SaveSharedWorkbook(ctx, opened.SessionToken, []byte(workbookJSON(`"0": {"0": {"v": "匿名"}}`)), nil, opened.Revision)
+	if err == nil {
+		t.Fatal("只读链接不应允许保存")
+	}
+
+	list, err := service.GetShareLinkList(ctx, testFileId, "1")
+	if err != nil || len(list) != 1 || !list[0].HasPassword || !list[0].FreezeData || list[0].CreatorName != "Alice" {
+		t.Fatalf("分享链接列表不符合预期: %+v %v", list, err)
+	}
+}
+
+func TestShareLinkEditorSession(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	service := &SugarShareLinksService{}
+
+	link := createShareLink(t, sugarReq.SugarShareLinkCreateRequest{PermissionLevel: sugar.ShareLinkPermissionEditor}, "1")
+	opened, err := service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token}, "ip-1")
+	if err != nil || opened.ReadOnly || opened.Frozen {
+		t.Fatalf("可编辑的链接应打开实时内容: %+v %v", opened, err)
+	}
+
+	// 匿名保存以创建者的身份记录历史版本
+	saved, err := service.SaveSharedWorkbook(ctx, opened.SessionToken, []byte(workbookJSON(`"0": {"0": {"v": "匿名修改"}}`)), nil, opened.Revision)
+	if err != nil || saved.Revision != opened.Revision+1 {
+		t.Fatalf("可编辑的链接应能保存: %+v %v", saved, err)
+	}
+	if versions := listVersions(t); versions[0].CreatedBy != "1" {
+		t.Fatalf("历史版本应记录为链接创建者: %+v", versions[0])
+	}
+
+	// 篡改的会话令牌无效
+	parts := strings.Split(opened.SessionToken, ".")
+	forged := parts[0] + "." + parts[1] + ".x" + parts[2][1:]
+	if _, err = service.SaveSharedWorkbook(ctx, forged, []byte(workbookJSON(``)), nil, saved.Revision); err == nil {
+		t.Fatal("篡改的会话令牌不应通过校验")
+	}
+
+	// 创建者失去编辑权限后链接失效
+	global.GVA_DB.Exec(`UPDATE sugar_team_members SET role = 'viewer' WHERE user_id = '1'`)
+	if _, err = service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token}, "ip-1"); err == nil || !strings.Contains(err.Error(), "已失效") {
+		t.Fatalf("创建者失去权限后链接应失效: %v", err)
+	}
+	global.GVA_DB.Exec(`UPDATE sugar_team_members SET role = 'editor' WHERE user_id = '1'`)
+
+	// 过期的链接不能打开
+	expired := time.Now().Add(-time.Minute)
+	global.GVA_DB.Model(&sugar.SugarShareLinks{}).Where("id = ?", link.Id).Update("expires_at", expired)
+	if _, err = service.OpenSharedWorkbook(ctx, sugarReq.SugarShareLinkOpenRequest{Token: link.Token}, "ip-1"); err == nil || !strings.Contains(err.Error(), "过期") {
+		t.Fatalf("过期的链接不应能打开: %v", err)
+	}
+	global.GVA_DB.Model(&sugar.SugarShareLinks{}).Where("id = ?", link.Id).Update("expires_at", nil)
+
+	// 关闭链接后已打开的会话也不能继续保存；查看者不能关闭他人的链接
+	expectPermissionDenied(t, service.RevokeShareLink(ctx, link.Id, "12"), SugarTeamRoleViewer)
+	if err = service.RevokeShareLink(ctx, link.Id, "1"); err != nil {
+		t.Fatalf("创建者应能关闭链接: %v", err)
+	}
+	_, err = service.SaveSharedWorkbook(ctx, opened.SessionToken, []byte(workbookJSON(`"0": {"0": {"v": "关闭后"}}`)), nil, saved.Revision)
+	if err == nil || !strings.Contains(err.Error(), "已关闭") {
+		t.Fatalf("关闭链接后不应能保存: %v", err)
+	}
+	var list []sugarRes.SugarShareLinkItem
+	if list, err = service.GetShareLinkList(ctx, testFileId, "10"); err != nil || len(list) != 1 || list[0].IsActive {
+		t.Fatalf("关闭的链接应保留在列表中: %+v %v", list, err)
+	}
+}
@@ -2,39 +2,78 @@ package sugar
 
 import (
 	"context"
+	"errors"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"gorm.io/gorm"
 )
 
 type SugarTeamMembersService struct{}
 
 // CreateSugarTeamMembers 创建sugarTeamMembers表记录
+// 需要团队的 manage 权限，且不能授予高于自己的角色
 // Author [yourname](https://github.com/yourname)
-func (sugarTeamMembersService *SugarTeamMembersService) CreateSugarTeamMembers(ctx context.Context, sugarTeamMembers *sugar.SugarTeamMembers) (err error) {
+func (sugarTeamMembersService *SugarTeamMembersService) CreateSugarTeamMembers(ctx context.Context, sugarTeamMembers *sugar.SugarTeamMembers, userId string) (err error) {
+	if sugarTeamMembers.TeamId == nil || *sugarTeamMembers.TeamId == "" {
+		return errors.New("团队不能为空")
+	}
+	if err = authorizeTeamMemberRoles(ctx, *sugarTeamMembers.TeamId, userId, sugarTeamMembers.Role); err != nil {
+		return err
+	}
 	err = global.GVA_DB.Create(sugarTeamMembers).Error
 	return err
 }
 
 // DeleteSugarTeamMembers 删除sugarTeamMembers表记录
+// 需要团队的 manage 权限，且不能移除角色高于自己的成员
 // Author [yourname](https://github.com/yourname)
 func (sugarTeamMembersService *SugarTeamMembersService) DeleteSugarTeamMembers(ctx context.Context, id string, userId string) (err error) {
-	err = global.GVA_DB.Delete(&sugar.SugarTeamMembers{}, "id = ?", id).Where("user_id = ?", userId).Error
-	return err
+	return sugarTeamMembersService.DeleteSugarTeamMembersByIds(ctx, []string{id}, userId)
 }
 
-// DeleteSugarTeamMembersByIds 批量删除sugarTeamMembers表记录
+// DeleteSugarTeamMembersByIds 批量删除sugarTeamMembers表记录，逐条校验权限，任一条不允许时都不删除
 // Author [yourname](https://github.com/yourname)
-func (sugarTeamMembersService *SugarTeamMembersService) DeleteSugarTeamMembersByIds(ctx context.Context, ids []string) (err error) {
+func (sugarTeamMembersService *SugarTeamMembersService) DeleteSugarTeamMembersByIds(ctx context.Context, ids []string, userId string) (err error) {
+	var members []sugar.SugarTeamMembers
+	if err = global.GVA_DB.WithContext(ctx).Where("id in ?", ids).Find(&members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return errors.New("团队成员不存在")
+	}
+	for _, member := range members {
+		if err = authorizeTeamMemberRoles(ctx, *member.TeamId, userId, member.Role); err != nil {
+			return err
+		}
+	}
 	err = global.GVA_DB.Delete(&[]sugar.SugarTeamMembers{}, "id in ?", ids).Error
 	return err
 }
 
-// UpdateSugarTeamMembers 更新sugarTeamMembers表记录
+// UpdateSugarTeamMembers 更新sugarTeamMembers表记录，只能修改成员的角色
+// 需要团队的 manage 权限，成员的原角色和新角色都不能高于自己的角色
 // Author [yourname](https://github.com/yourname)
-func (sugarTeamMembersService *SugarTeamMembersService) UpdateSugarTeamMembers(ctx context.Context, sugarTeamMembers sugar.SugarTeamMembers) (err error) {
-	err = global.GVA_DB.Model(&sugar.SugarTeamMembers{}).Where("id = ?", sugarTeamMembers.Id).Updates(&sugarTeamMembers).Error
+func (sugarTeamMembersService *SugarTeamMembersService) UpdateSugarTeamMembers(ctx context.Context, sugarTeamMembers sugar.SugarTeamMembers, userId string) (err error) {
+	var existing sugar.SugarTeamMembers
+	if err = global.GVA_DB.WithContext(ctx).Where("id = ?", sugarTeamMembers.Id).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("团队成员不存在")
+		}
+		return err
+	}
+	if (sugarTeamMembers.TeamId != nil && *sugarTeamMembers.TeamId != *existing.TeamId) || (sugarTeamMembers.UserId != nil && *sugarTeamMembers.UserId != *existing.UserId) {
+		return errors.New("不能修改成员所属的团队或用户")
+	}
+	role := existing.Role
+	if sugarTeamMembers.Role != "" {
+		role = sugarTeamMembers.Role
+	}
+	if err = authorizeTeamMemberRoles(ctx, *existing.TeamId, userId, existing.Role, role); err != nil {
+		return err
+	}
+	err = global.GVA_DB.Model(&existing).Updates(map[string]interface{}{"role": role, "updated_by": userId}).Error
 	return err
 }
 
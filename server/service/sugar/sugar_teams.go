@@ -7,50 +7,64 @@ import (
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type SugarTeamsService struct{}
 
-// CreateSugarTeams 创建团队信息表记录
+// CreateSugarTeams 创建团队信息表记录，创建者同时加入团队成为所有者
 // Author [yourname](https://github.com/yourname)
 func (sugarTeamsService *SugarTeamsService) CreateSugarTeams(ctx context.Context, sugarTeams *sugar.SugarTeams) (err error) {
-	err = global.GVA_DB.Create(sugarTeams).Error
-	return err
+	if sugarTeams.OwnerId == nil || *sugarTeams.OwnerId == "" {
+		return errors.New("团队所有者不能为空")
+	}
+	if sugarTeams.Id == nil || *sugarTeams.Id == "" {
+		id := uuid.New().String()
+		sugarTeams.Id = &id
+	}
+	return global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sugarTeams).Error; err != nil {
+			return err
+		}
+		return tx.Create(&sugar.SugarTeamMembers{
+			TeamId:    sugarTeams.Id,
+			UserId:    sugarTeams.OwnerId,
+			Role:      SugarTeamRoleOwner,
+			CreatedBy: sugarTeams.OwnerId,
+		}).Error
+	})
 }
 
-// DeleteSugarTeams 删除团队信息表记录
+// DeleteSugarTeams 删除团队信息表记录，只有团队所有者可以解散团队
 // Author [yourname](https://github.com/yourname)
 func (sugarTeamsService *SugarTeamsService) DeleteSugarTeams(ctx context.Context, id string, userId string) (err error) {
-	err = global.GVA_DB.Where("owner_id = ?", userId).Delete(&sugar.SugarTeams{}, "id = ?", id).Error
-	return err
+	return sugarTeamsService.DeleteSugarTeamsByIds(ctx, []string{id}, userId)
 }
 
-// DeleteSugarTeamsByIds 批量删除团队信息表记录
+// DeleteSugarTeamsByIds 批量删除团队信息表记录，逐个团队校验权限，任一个不允许时都不删除
 // Author [yourname](https://github.com/yourname)
 func (sugarTeamsService *SugarTeamsService) DeleteSugarTeamsByIds(ctx context.Context, ids []string, userId string) (err error) {
-	err = global.GVA_DB.Where("owner_id = ?", userId).Delete(&[]sugar.SugarTeams{}, "id in ?", ids).Error
+	for _, id := range ids {
+		if err = authorizeTeamAction(ctx, id, userId, SugarResourceTeam, SugarActionDelete); err != nil {
+			return err
+		}
+	}
+	err = global.GVA_DB.Delete(&[]sugar.SugarTeams{}, "id in ?", ids).Error
 	return err
 }
 
-// UpdateSugarTeams 更新团队信息表记录
+// UpdateSugarTeams 更新团队信息表记录，需要团队的 manage 权限
 // Author [yourname](https://github.com/yourname)
 func (sugarTeamsService *SugarTeamsService) UpdateSugarTeams(ctx context.Context, sugarTeams sugar.SugarTeams) (err error) {
-	// 查询团队成员表，如果团队成员表中的角色不属于"owner"\"admin"，则不允许更新
 	if sugarTeams.UpdatedBy == nil || *sugarTeams.UpdatedBy == "" {
 		return errors.New("更新人不能为空")
 	}
-	var existingTeamMembers []sugar.SugarTeamMembers
-	err = global.GVA_DB.Where("team_id = ? AND user_id = ?", sugarTeams.Id, *sugarTeams.UpdatedBy).Find(&existingTeamMembers).Error
-	if err != nil {
-		return err
-	}
-	if len(existingTeamMembers) == 0 {
-		return errors.New("团队成员不存在或没有权限更新")
+	if sugarTeams.Id == nil || *sugarTeams.Id == "" {
+		return errors.New("团队不能为空")
 	}
-	for _, member := range existingTeamMembers {
-		if member.Role != "owner" && member.Role != "admin" {
-			return errors.New("只有团队所有者或管理员可以更新团队信息")
-		}
+	if err = authorizeTeamAction(ctx, *sugarTeams.Id, *sugarTeams.UpdatedBy, SugarResourceTeam, SugarActionManage); err != nil {
+		return err
 	}
 
 	err = global.GVA_DB.Model(&sugar.SugarTeams{}).Where("id = ?", sugarTeams.Id).Updates(&sugarTeams).Error
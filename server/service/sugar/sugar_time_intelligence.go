@@ -0,0 +1,481 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+)
+
+// dateLayout 时间智能期间边界使用的日期格式
+const dateLayout = "2006-01-02"
+
+// maxRollingPeriods ROLLING 函数允许的最大期数
+const maxRollingPeriods = 366
+
+// 时间智能对比结果列的后缀
+const (
+	timeBaseColumnSuffix       = "_对比期"
+	timeChangeColumnSuffix     = "_变化"
+	timeChangeRateColumnSuffix = "_变化率"
+)
+
+// TimeWindows 时间智能函数解析出的本期与对比期
+type TimeWindows struct {
+	Function   string
+	DateColumn string              // 用于筛选的日期字段（可返回字段名）
+	Current    sugarReq.DateRange  // 本期
+	Base       *sugarReq.DateRange // 对比期，SPLY 等只取单一期间的函数为空
+}
+
+// Info 转换为响应中的期间说明
+func (w *TimeWindows) Info() *sugarRes.TimeIntelligenceInfo {
+	info := &sugarRes.TimeIntelligenceInfo{
+		Function:   w.Function,
+		DateColumn: w.DateColumn,
+		Current:    sugarRes.PeriodRange{Start: w.Current.Start, End: w.Current.End},
+	}
+	if w.Base != nil {
+		info.Base = &sugarRes.PeriodRange{Start: w.Base.Start, End: w.Base.End}
+	}
+	return info
+}
+
+// ApplyTo 在筛选条件上附加期间条件，返回本期和对比期的筛选条件；原筛选条件中的日期字段条件被期间条件替换
+func (w *TimeWindows) ApplyTo(filters map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	current := withDateRange(filters, w.DateColumn, w.Current)
+	if w.Base == nil {
+		return current, nil
+	}
+	return current, withDateRange(filters, w.DateColumn, *w.Base)
+}
+
+// withDateRange 复制筛选条件并设置日期字段的区间条件
+func withDateRange(filters map[string]interface{}, column string, dateRange sugarReq.DateRange) map[string]interface{} {
+	result := make(map[string]interface{}, len(filters)+1)
+	for key, value := range filters {
+		result[key] = value
+	}
+	result[column] = dateRange
+	return result
+}
+
+// ResolveTimeWindows 按语义模型的分析口径计算时间智能函数的本期与对比期
+// 期间边界全部在服务端确定：粒度为季度或年时按财年口径划分，now 为未指定锚定日期时使用的当前时间
+// 未指定锚定日期时，YOY/MOM/QOQ/ROLLING/SPLY 锚定到最近一个完整期间，避免用未结束的本期对比完整的对比期
+func ResolveTimeWindows(semantics *AnalysisSemantics, ti *sugarReq.TimeIntelligence, now time.Time) (*TimeWindows, error) {
+	if ti == nil {
+		return nil, errors.New("时间智能参数不能为空")
+	}
+	column, _, err := semantics.TimeColumn()
+	if err != nil {
+		return nil, err
+	}
+
+	function := strings.ToUpper(strings.TrimSpace(ti.Function))
+	grain := ti.Grain
+	if grain == "" {
+		grain = semantics.Granularity
+	}
+	if grain == "" {
+		grain = PeriodGranularityMonth
+	}
+	switch function {
+	case sugarReq.TimeFunctionMoM:
+		grain = PeriodGranularityMonth
+	case sugarReq.TimeFunctionQoQ:
+		grain = PeriodGranularityQuarter
+	case sugarReq.TimeFunctionYTD:
+		grain = PeriodGranularityYear
+	}
+	if _, ok := periodGranularityNames[grain]; !ok {
+		return nil, fmt.Errorf("不支持的期间粒度: %s", grain)
+	}
+
+	calendar := periodCalendar{grain: grain, fiscalStartMonth: semantics.FiscalStartMonth()}
+	anchor := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if ti.AnchorDate != "" {
+		if anchor, err = time.Parse(dateLayout, ti.AnchorDate); err != nil {
+			return nil, fmt.Errorf("锚定日期格式错误，应为 YYYY-MM-DD: %s", ti.AnchorDate)
+		}
+	} else if function != sugarReq.TimeFunctionYTD && function != sugarReq.TimeFunctionPTD {
+		anchor = calendar.periodStart(anchor).AddDate(0, 0, -1)
+	}
+	start := calendar.periodStart(anchor)
+	end := calendar.periodEnd(start)
+	windows := &TimeWindows{Function: function, DateColumn: column}
+
+	switch function {
+	case sugarReq.TimeFunctionYoY:
+		baseStart := calendar.periodStart(sameDayLastYear(start))
+		windows.Current = newDateRange(start, end)
+		windows.Base = newDateRangePtr(baseStart, calendar.periodEnd(baseStart))
+	case sugarReq.TimeFunctionMoM, sugarReq.TimeFunctionQoQ:
+		baseStart := calendar.addPeriods(start, -1)
+		windows.Current = newDateRange(start, end)
+		windows.Base = newDateRangePtr(baseStart, calendar.periodEnd(baseStart))
+	case sugarReq.TimeFunctionYTD:
+		windows.Current = newDateRange(start, anchor)
+		windows.Base = newDateRangePtr(calendar.addPeriods(start, -1), sameDayLastYear(anchor))
+	case sugarReq.TimeFunctionPTD:
+		baseStart := calendar.addPeriods(start, -1)
+		baseEnd := baseStart.AddDate(0, 0, int(anchor.Sub(start).Hours()/24))
+		if limit := calendar.periodEnd(baseStart); baseEnd.After(limit) {
+			baseEnd = limit
+		}
+		windows.Current = newDateRange(start, anchor)
+		windows.Base = newDateRangePtr(baseStart, baseEnd)
+	case sugarReq.TimeFunctionRolling:
+		periods := ti.Periods
+		if periods == 0 {
+			periods = 12
+		}
+		if periods < 1 || periods > maxRollingPeriods {
+			return nil, fmt.Errorf("ROLLING 期数必须在 1-%d 之间: %d", maxRollingPeriods, periods)
+		}
+		currentStart := calendar.addPeriods(start, -(periods - 1))
+		windows.Current = newDateRange(currentStart, end)
+		windows.Base = newDateRangePtr(calendar.addPeriods(start, -(2*periods-1)), currentStart.AddDate(0, 0, -1))
+	case sugarReq.TimeFunctionSPLY:
+		baseStart := calendar.periodStart(sameDayLastYear(start))
+		windows.Current = newDateRange(baseStart, calendar.periodEnd(baseStart))
+	default:
+		return nil, fmt.Errorf("不支持的时间智能函数: %s，可选 YOY、MOM、QOQ、YTD、PTD、ROLLING、SPLY", ti.Function)
+	}
+	return windows, nil
+}
+
+// periodCalendar 按粒度和财年起始月份划分期间，周从周一开始
+type periodCalendar struct {
+	grain            string
+	fiscalStartMonth int
+}
+
+// periodStart 返回日期所在期间的第一天
+func (c periodCalendar) periodStart(day time.Time) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := day.AddDate(0, 0, 1-day.Day())
+	fiscalOffset := (int(day.Month()) - c.fiscalStartMonth + 12) % 12
+	switch c.grain {
+	case PeriodGranularityWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case PeriodGranularityMonth:
+		return monthStart
+	case PeriodGranularityQuarter:
+		return monthStart.AddDate(0, -(fiscalOffset % 3), 0)
+	case PeriodGranularityYear:
+		return monthStart.AddDate(0, -fiscalOffset, 0)
+	default:
+		return day
+	}
+}
+
+// addPeriods 将期间起始日平移 n 个期间
+func (c periodCalendar) addPeriods(start time.Time, n int) time.Time {
+	switch c.grain {
+	case PeriodGranularityWeek:
+		return start.AddDate(0, 0, 7*n)
+	case PeriodGranularityMonth:
+		return start.AddDate(0, n, 0)
+	case PeriodGranularityQuarter:
+		return start.AddDate(0, 3*n, 0)
+	case PeriodGranularityYear:
+		return start.AddDate(0, 12*n, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// periodEnd 返回期间的最后一天
+func (c periodCalendar) periodEnd(start time.Time) time.Time {
+	return c.addPeriods(start, 1).AddDate(0, 0, -1)
+}
+
+// sameDayLastYear 上年同日，2月29日对应上年2月28日
+func sameDayLastYear(day time.Time) time.Time {
+	result := day.AddDate(-1, 0, 0)
+	if result.Month() != day.Month() {
+		result = result.AddDate(0, 0, -result.Day())
+	}
+	return result
+}
+
+func newDateRange(start, end time.Time) sugarReq.DateRange {
+	return sugarReq.DateRange{Start: start.Format(dateLayout), End: end.Format(dateLayout)}
+}
+
+func newDateRangePtr(start, end time.Time) *sugarReq.DateRange {
+	dateRange := newDateRange(start, end)
+	return &dateRange
+}
+
+// parseDateRangeValue 识别筛选值中的日期区间：DateRange 或 {"start": ..., "end": ...}
+func parseDateRangeValue(value interface{}) (*sugarReq.DateRange, bool) {
+	switch v := value.(type) {
+	case sugarReq.DateRange:
+		return &v, true
+	case *sugarReq.DateRange:
+		return v, v != nil
+	case map[string]interface{}:
+		start, startOk := v["start"].(string)
+		end, endOk := v["end"].(string)
+		if !startOk || !endOk || len(v) != 2 {
+			return nil, false
+		}
+		return &sugarReq.DateRange{Start: start, End: end}, true
+	}
+	return nil, false
+}
+
+// buildDateRangeCondition 构建日期区间条件，按字段格式转换边界后使用左闭右开区间，兼容日期时间类型的字段
+func buildDateRangeCondition(column, layout string, dateRange *sugarReq.DateRange) (string, []interface{}, error) {
+	start, err := time.Parse(dateLayout, dateRange.Start)
+	if err != nil {
+		return "", nil, fmt.Errorf("日期区间起始日期格式错误，应为 YYYY-MM-DD: %s", dateRange.Start)
+	}
+	end, err := time.Parse(dateLayout, dateRange.End)
+	if err != nil {
+		return "", nil, fmt.Errorf("日期区间结束日期格式错误，应为 YYYY-MM-DD: %s", dateRange.End)
+	}
+	if end.Before(start) {
+		return "", nil, fmt.Errorf("日期区间结束日期早于起始日期: %s ~ %s", dateRange.Start, dateRange.End)
+	}
+	condition := fmt.Sprintf("t.%s >= ? AND t.%s < ?", column, column)
+	return condition, []interface{}{start.Format(layout), end.AddDate(0, 0, 1).Format(layout)}, nil
+}
+
+// dateRangeColumn 返回日期区间筛选对应的实际列名和格式
+// 筛选参数中配置了该字段时使用参数的列，否则使用可返回字段的列；分析口径声明的日期字段使用声明的格式
+func (s *SugarFormulaQueryService) dateRangeColumn(model *sugar.SugarSemanticModels, parameterConfig map[string]map[string]interface{}, filterKey string) (string, string, error) {
+	layout := dateLayout
+	if semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics); err == nil {
+		if column, columnLayout, err := semantics.TimeColumn(); err == nil && column == filterKey {
+			layout = columnLayout
+		}
+	}
+
+	if paramConfig, exists := parameterConfig[filterKey]; exists {
+		if actualColumn, ok := paramConfig["column"].(string); ok {
+			return actualColumn, layout, nil
+		}
+		return "", "", errors.New("筛选条件配置错误: " + filterKey)
+	}
+
+	var returnableColumns map[string]map[string]interface{}
+	if err := json.Unmarshal(model.ReturnableColumnsConfig, &returnableColumns); err != nil {
+		return "", "", errors.New("解析可返回列配置失败")
+	}
+	columnConfig, exists := returnableColumns[filterKey]
+	if !exists {
+		return "", "", errors.New("筛选条件不存在: " + filterKey)
+	}
+	actualColumn, ok := columnConfig["column"].(string)
+	if !ok {
+		return "", "", errors.New("筛选条件配置错误: " + filterKey)
+	}
+	return actualColumn, layout, nil
+}
+
+// resolveTimeIntelligence 解析请求中的时间智能函数
+func (s *SugarFormulaQueryService) resolveTimeIntelligence(model *sugar.SugarSemanticModels, ti *sugarReq.TimeIntelligence) (*TimeWindows, error) {
+	semantics, err := ParseAnalysisSemantics(model.AnalysisSemantics)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveTimeWindows(semantics, ti, time.Now())
+}
+
+// executeGetWithTimeIntelligence 按时间智能函数执行 SUGAR.GET
+// 有对比期时按非指标列合并本期与对比期结果，每个指标列追加 _对比期、_变化、_变化率 三列
+func (s *SugarFormulaQueryService) executeGetWithTimeIntelligence(ctx context.Context, model *sugar.SugarSemanticModels, req *sugarReq.SugarFormulaGetRequest, userId string) (*sugarRes.SugarFormulaGetResponse, error) {
+	windows, err := s.resolveTimeIntelligence(model, req.TimeIntelligence)
+	if err != nil {
+		return sugarRes.NewGetErrorResponse(err.Error()), nil
+	}
+	currentFilters, baseFilters := windows.ApplyTo(req.Filters)
+
+	global.GVA_LOG.Info("执行时间智能GET查询",
+		zap.String("function", windows.Function),
+		zap.Any("current", windows.Current),
+		zap.Any("base", windows.Base))
+
+	currentReq := *req
+	currentReq.TimeIntelligence = nil
+	currentReq.Filters = currentFilters
+	current, err := s.ExecuteGetFormula(ctx, &currentReq, userId)
+	if err != nil || current.Error != "" {
+		return current, err
+	}
+	if windows.Base == nil {
+		current.TimeIntelligence = windows.Info()
+		return current, nil
+	}
+
+	baseReq := currentReq
+	baseReq.Filters = baseFilters
+	base, err := s.ExecuteGetFormula(ctx, &baseReq, userId)
+	if err != nil || base.Error != "" {
+		return base, err
+	}
+
+	var returnableColumns map[string]map[string]interface{}
+	if err := json.Unmarshal(model.ReturnableColumnsConfig, &returnableColumns); err != nil {
+		return sugarRes.NewGetErrorResponse("解析可返回列配置失败"), nil
+	}
+	var dimensions, metrics []string
+	for _, column := range req.ReturnColumns {
+		if columnType, _ := returnableColumns[column]["type"].(string); columnType == "metric" || columnType == "measure" {
+			metrics = append(metrics, column)
+		} else {
+			dimensions = append(dimensions, column)
+		}
+	}
+
+	results := mergePeriodResults(current.Results, base.Results, dimensions, metrics)
+	columns := append([]string{}, req.ReturnColumns...)
+	for _, metric := range metrics {
+		columns = append(columns, metric+timeBaseColumnSuffix, metric+timeChangeColumnSuffix, metric+timeChangeRateColumnSuffix)
+	}
+	response := sugarRes.NewGetSuccessResponse(results, columns)
+	response.TimeIntelligence = windows.Info()
+	return response, nil
+}
+
+// mergePeriodResults 按维度列合并本期与对比期结果，同一维度组合的指标值相加
+func mergePeriodResults(current, base []map[string]interface{}, dimensions, metrics []string) []map[string]interface{} {
+	extractor := &DataProcessor{}
+	type periodValues struct {
+		row     map[string]interface{}
+		current map[string]float64
+		base    map[string]float64
+	}
+	var order []string
+	groups := make(map[string]*periodValues)
+	collect := func(rows []map[string]interface{}, isBase bool) {
+		for _, row := range rows {
+			keyParts := make([]string, len(dimensions))
+			for i, dim := range dimensions {
+				keyParts[i] = fmt.Sprintf("%v", row[dim])
+			}
+			key := strings.Join(keyParts, "|")
+			group, ok := groups[key]
+			if !ok {
+				group = &periodValues{row: make(map[string]interface{}), current: make(map[string]float64), base: make(map[string]float64)}
+				for _, dim := range dimensions {
+					group.row[dim] = row[dim]
+				}
+				groups[key] = group
+				order = append(order, key)
+			}
+			for _, metric := range metrics {
+				if isBase {
+					group.base[metric] += extractor.extractFloatValue(row[metric])
+				} else {
+					group.current[metric] += extractor.extractFloatValue(row[metric])
+				}
+			}
+		}
+	}
+	collect(current, false)
+	collect(base, true)
+
+	results := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		group := groups[key]
+		for _, metric := range metrics {
+			currentValue, baseValue := group.current[metric], group.base[metric]
+			group.row[metric] = currentValue
+			group.row[metric+timeBaseColumnSuffix] = baseValue
+			group.row[metric+timeChangeColumnSuffix] = currentValue - baseValue
+			if baseValue != 0 {
+				group.row[metric+timeChangeRateColumnSuffix] = (currentValue - baseValue) / baseValue * 100
+			} else {
+				group.row[metric+timeChangeRateColumnSuffix] = nil
+			}
+		}
+		results = append(results, group.row)
+	}
+	return results
+}
+
+// executeCalcWithTimeIntelligence 按时间智能函数执行 SUGAR.CALC，有对比期时同时返回对比期结果、变化值和变化率
+func (s *SugarFormulaQueryService) executeCalcWithTimeIntelligence(ctx context.Context, model *sugar.SugarSemanticModels, req *sugarReq.SugarFormulaCalcRequest, userId string) (*sugarRes.SugarFormulaCalcResponse, error) {
+	windows, err := s.resolveTimeIntelligence(model, req.TimeIntelligence)
+	if err != nil {
+		return sugarRes.NewCalcErrorResponse(err.Error()), nil
+	}
+	currentFilters, baseFilters := windows.ApplyTo(req.Filters)
+
+	global.GVA_LOG.Info("执行时间智能CALC查询",
+		zap.String("function", windows.Function),
+		zap.Any("current", windows.Current),
+		zap.Any("base", windows.Base))
+
+	currentReq := *req
+	currentReq.TimeIntelligence = nil
+	currentReq.Filters = currentFilters
+	current, err := s.ExecuteCalcFormula(ctx, &currentReq, userId)
+	if err != nil || current.Error != "" {
+		return current, err
+	}
+	current.TimeIntelligence = windows.Info()
+	if windows.Base == nil {
+		return current, nil
+	}
+
+	baseReq := currentReq
+	baseReq.Filters = baseFilters
+	base, err := s.ExecuteCalcFormula(ctx, &baseReq, userId)
+	if err != nil || base.Error != "" {
+		return base, err
+	}
+
+	extractor := &DataProcessor{}
+	currentValue, baseValue := extractor.extractFloatValue(current.Result), extractor.extractFloatValue(base.Result)
+	change := currentValue - baseValue
+	current.BaseResult = base.Result
+	current.Change = &change
+	if baseValue != 0 {
+		changeRate := change / baseValue * 100
+		current.ChangeRate = &changeRate
+	}
+	return current, nil
+}
+
+// ResolvePeriodFilters 按时间智能函数生成贡献度分析的本期和基期筛选条件
+// 期间条件由服务端计算并覆盖筛选条件中的日期字段；基期未提供其他筛选条件时沿用本期的筛选条件
+func (dp *DataProcessor) ResolvePeriodFilters(ctx context.Context, modelName, userId string, ti *sugarReq.TimeIntelligence, currentPeriodFilters, basePeriodFilters map[string]interface{}) (map[string]interface{}, map[string]interface{}, *TimeWindows, error) {
+	semantics, err := dp.GetAnalysisSemantics(ctx, modelName, userId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	windows, err := ResolveTimeWindows(semantics, ti, time.Now())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if windows.Base == nil {
+		return nil, nil, nil, fmt.Errorf("时间智能函数 %s 不产生对比期，无法用于本期与基期的对比分析", windows.Function)
+	}
+
+	baseSource := basePeriodFilters
+	if len(baseSource) == 0 {
+		baseSource = currentPeriodFilters
+	}
+	current, _ := windows.ApplyTo(currentPeriodFilters)
+	_, base := windows.ApplyTo(baseSource)
+
+	global.GVA_LOG.Info("按时间智能函数确定分析期间",
+		zap.String("modelName", modelName),
+		zap.String("function", windows.Function),
+		zap.Any("current", windows.Current),
+		zap.Any("base", windows.Base))
+	return current, base, windows, nil
+}
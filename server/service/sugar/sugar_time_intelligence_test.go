@@ -0,0 +1,139 @@
+package sugar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+)
+
+// formatWindows 以 "本期起~本期止 | 对比期起~对比期止" 的形式输出期间，便于表格比较
+func formatWindows(w *TimeWindows) string {
+	result := w.Current.Start + "~" + w.Current.End
+	if w.Base != nil {
+		result += " | " + w.Base.Start + "~" + w.Base.End
+	}
+	return result
+}
+
+func TestResolveTimeWindows(t *testing.T) {
+	monthly := &AnalysisSemantics{PeriodColumn: "月份", Granularity: PeriodGranularityMonth, DateColumn: "日期"}
+	fiscal := &AnalysisSemantics{PeriodColumn: "月份", Granularity: PeriodGranularityMonth, DateColumn: "日期", FiscalCalendar: &FiscalCalendar{StartMonth: 4}}
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		semantics *AnalysisSemantics
+		ti        sugarReq.TimeIntelligence
+		want      string
+	}{
+		// 指定锚定日期
+		{"YOY 按月", monthly, sugarReq.TimeIntelligence{Function: "yoy", AnchorDate: "2024-03-15"}, "2024-03-01~2024-03-31 | 2023-03-01~2023-03-31"},
+		{"YOY 财季", fiscal, sugarReq.TimeIntelligence{Function: "YOY", Grain: "quarter", AnchorDate: "2024-05-10"}, "2024-04-01~2024-06-30 | 2023-04-01~2023-06-30"},
+		{"YOY 财年", fiscal, sugarReq.TimeIntelligence{Function: "YOY", Grain: "year", AnchorDate: "2024-02-29"}, "2023-04-01~2024-03-31 | 2022-04-01~2023-03-31"},
+		{"YOY 按日的2月29日", monthly, sugarReq.TimeIntelligence{Function: "YOY", Grain: "day", AnchorDate: "2024-02-29"}, "2024-02-29~2024-02-29 | 2023-02-28~2023-02-28"},
+		{"YOY 按周", monthly, sugarReq.TimeIntelligence{Function: "YOY", Grain: "week", AnchorDate: "2024-03-13"}, "2024-03-11~2024-03-17 | 2023-03-06~2023-03-12"},
+		{"MOM 到闰年2月", monthly, sugarReq.TimeIntelligence{Function: "MOM", Grain: "week", AnchorDate: "2024-03-31"}, "2024-03-01~2024-03-31 | 2024-02-01~2024-02-29"},
+		{"QOQ 自然季度", monthly, sugarReq.TimeIntelligence{Function: "QOQ", AnchorDate: "2024-02-29"}, "2024-01-01~2024-03-31 | 2023-10-01~2023-12-31"},
+		{"QOQ 财季", fiscal, sugarReq.TimeIntelligence{Function: "QOQ", AnchorDate: "2024-04-01"}, "2024-04-01~2024-06-30 | 2024-01-01~2024-03-31"},
+		{"YTD 自然年", monthly, sugarReq.TimeIntelligence{Function: "YTD", AnchorDate: "2024-03-15"}, "2024-01-01~2024-03-15 | 2023-01-01~2023-03-15"},
+		{"YTD 财年的2月29日", fiscal, sugarReq.TimeIntelligence{Function: "YTD", AnchorDate: "2024-02-29"}, "2023-04-01~2024-02-29 | 2022-04-01~2023-02-28"},
+		{"PTD 按月", monthly, sugarReq.TimeIntelligence{Function: "PTD", AnchorDate: "2024-03-10"}, "2024-03-01~2024-03-10 | 2024-02-01~2024-02-10"},
+		{"PTD 对比期不超过上月月末", monthly, sugarReq.TimeIntelligence{Function: "PTD", AnchorDate: "2024-03-31"}, "2024-03-01~2024-03-31 | 2024-02-01~2024-02-29"},
+		{"PTD 按周", monthly, sugarReq.TimeIntelligence{Function: "PTD", Grain: "week", AnchorDate: "2024-03-13"}, "2024-03-11~2024-03-13 | 2024-03-04~2024-03-06"},
+		{"ROLLING 3个月", monthly, sugarReq.TimeIntelligence{Function: "ROLLING", Periods: 3, AnchorDate: "2024-03-15"}, "2024-01-01~2024-03-31 | 2023-10-01~2023-12-31"},
+		{"ROLLING 2周", monthly, sugarReq.TimeIntelligence{Function: "ROLLING", Grain: "week", Periods: 2, AnchorDate: "2024-03-13"}, "2024-03-04~2024-03-17 | 2024-02-19~2024-03-03"},
+		{"SPLY 闰年2月", monthly, sugarReq.TimeIntelligence{Function: "SPLY", AnchorDate: "2024-02-29"}, "2023-02-01~2023-02-28"},
+
+		// 未指定锚定日期：完整期间的函数使用最近一个完整期间，至今函数截至当天
+		{"MOM 默认上个完整月", monthly, sugarReq.TimeIntelligence{Function: "MOM"}, "2024-02-01~2024-02-29 | 2024-01-01~2024-01-31"},
+		{"YOY 默认上个完整季度", monthly, sugarReq.TimeIntelligence{Function: "YOY", Grain: "quarter"}, "2023-10-01~2023-12-31 | 2022-10-01~2022-12-31"},
+		{"QOQ 财年默认上个完整财季", fiscal, sugarReq.TimeIntelligence{Function: "QOQ"}, "2023-10-01~2023-12-31 | 2023-07-01~2023-09-30"},
+		{"ROLLING 默认12期", monthly, sugarReq.TimeIntelligence{Function: "ROLLING"}, "2023-03-01~2024-02-29 | 2022-03-01~2023-02-28"},
+		{"SPLY 默认上个完整月", monthly, sugarReq.TimeIntelligence{Function: "SPLY"}, "2023-02-01~2023-02-28"},
+		{"YTD 默认截至当天", fiscal, sugarReq.TimeIntelligence{Function: "YTD"}, "2023-04-01~2024-03-15 | 2022-04-01~2023-03-15"},
+		{"PTD 默认截至当天", monthly, sugarReq.TimeIntelligence{Function: "PTD"}, "2024-03-01~2024-03-15 | 2024-02-01~2024-02-15"},
+	}
+	for _, tt := range tests {
+		windows, err := ResolveTimeWindows(tt.semantics, &tt.ti, now)
+		if err != nil {
+			t.Errorf("%s: 解析失败: %v", tt.name, err)
+			continue
+		}
+		if got := formatWindows(windows); got != tt.want {
+			t.Errorf("%s: 期间 = %s，期望 %s", tt.name, got, tt.want)
+		}
+		if windows.DateColumn != "日期" {
+			t.Errorf("%s: 日期字段 = %s", tt.name, windows.DateColumn)
+		}
+	}
+
+	// 参数错误
+	invalid := []struct {
+		semantics *AnalysisSemantics
+		ti        *sugarReq.TimeIntelligence
+	}{
+		{monthly, nil},
+		{monthly, &sugarReq.TimeIntelligence{Function: "YOY", AnchorDate: "2024/03/15"}},
+		{monthly, &sugarReq.TimeIntelligence{Function: "WOW"}},
+		{monthly, &sugarReq.TimeIntelligence{Function: "YOY", Grain: "hour"}},
+		{monthly, &sugarReq.TimeIntelligence{Function: "ROLLING", Periods: maxRollingPeriods + 1}},
+		{&AnalysisSemantics{PeriodColumn: "季度", Granularity: PeriodGranularityQuarter}, &sugarReq.TimeIntelligence{Function: "YOY"}},
+	}
+	for _, tt := range invalid {
+		if windows, err := ResolveTimeWindows(tt.semantics, tt.ti, now); err == nil {
+			t.Errorf("参数 %+v 应解析失败，实际 %s", tt.ti, formatWindows(windows))
+		}
+	}
+}
+
+func TestSameDayLastYear(t *testing.T) {
+	for day, want := range map[string]string{"2024-02-29": "2023-02-28", "2024-03-01": "2023-03-01", "2023-12-31": "2022-12-31", "2025-02-28": "2024-02-28"} {
+		parsed, _ := time.Parse(dateLayout, day)
+		if got := sameDayLastYear(parsed).Format(dateLayout); got != want {
+			t.Errorf("sameDayLastYear(%s) = %s，期望 %s", day, got, want)
+		}
+	}
+}
+
+func TestTimeWindowsApplyTo(t *testing.T) {
+	windows := &TimeWindows{
+		DateColumn: "日期",
+		Current:    sugarReq.DateRange{Start: "2024-03-01", End: "2024-03-31"},
+		Base:       &sugarReq.DateRange{Start: "2024-02-01", End: "2024-02-29"},
+	}
+	filters := map[string]interface{}{"城市": "北京", "日期": "2020-01-01"}
+	current, base := windows.ApplyTo(filters)
+	if !reflect.DeepEqual(current, map[string]interface{}{"城市": "北京", "日期": windows.Current}) ||
+		!reflect.DeepEqual(base, map[string]interface{}{"城市": "北京", "日期": *windows.Base}) {
+		t.Fatalf("期间条件应替换日期字段的条件: %v %v", current, base)
+	}
+	if filters["日期"] != "2020-01-01" {
+		t.Fatal("不应修改原筛选条件")
+	}
+}
+
+func TestBuildDateRangeCondition(t *testing.T) {
+	condition, args, err := buildDateRangeCondition("biz_date", "2006-01", &sugarReq.DateRange{Start: "2024-01-01", End: "2024-02-29"})
+	if err != nil || condition != "t.biz_date >= ? AND t.biz_date < ?" || !reflect.DeepEqual(args, []interface{}{"2024-01", "2024-03"}) {
+		t.Fatalf("日期区间条件 = %s %v %v", condition, args, err)
+	}
+	for _, dateRange := range []sugarReq.DateRange{{Start: "2024-13-01", End: "2024-12-31"}, {Start: "2024-01-01", End: "明天"}, {Start: "2024-02-01", End: "2024-01-31"}} {
+		if _, _, err := buildDateRangeCondition("biz_date", dateLayout, &dateRange); err == nil {
+			t.Errorf("无效的日期区间应返回错误: %+v", dateRange)
+		}
+	}
+}
+
+func TestMergePeriodResults(t *testing.T) {
+	current := []map[string]interface{}{{"城市": "北京", "收入": 120.0}, {"城市": "上海", "收入": 50.0}, {"城市": "北京", "收入": 30.0}}
+	base := []map[string]interface{}{{"城市": "北京", "收入": 100.0}, {"城市": "深圳", "收入": 40.0}}
+	want := []map[string]interface{}{
+		{"城市": "北京", "收入": 150.0, "收入_对比期": 100.0, "收入_变化": 50.0, "收入_变化率": 50.0},
+		{"城市": "上海", "收入": 50.0, "收入_对比期": 0.0, "收入_变化": 50.0, "收入_变化率": nil},
+		{"城市": "深圳", "收入": 0.0, "收入_对比期": 40.0, "收入_变化": -40.0, "收入_变化率": -100.0},
+	}
+	if got := mergePeriodResults(current, base, []string{"城市"}, []string{"收入"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("合并结果 = %v，期望 %v", got, want)
+	}
+}
@@ -0,0 +1,693 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCollabCompactOperations = 200         // 默认未压缩操作达到200条时请求快照
+	defaultCollabCompactInterval   = time.Minute // 默认每分钟检查一次是否有未压缩的操作
+	collabSendBufferSize           = 256         // 每个连接的发送队列长度，队列满时断开连接
+	collabMaxSyncOperations        = 1000        // 一次最多补发的操作数，超过时要求客户端重新加载
+)
+
+// collaborationSettings 读取实时协作配置并补全默认值
+func collaborationSettings() (compactOperations int, compactInterval time.Duration) {
+	config := global.GVA_CONFIG.Sugar.Collaboration
+	compactOperations = config.CompactOperations
+	compactInterval = time.Duration(config.CompactIntervalSeconds) * time.Second
+	if compactOperations <= 0 {
+		compactOperations = defaultCollabCompactOperations
+	}
+	if compactInterval <= 0 {
+		compactInterval = defaultCollabCompactInterval
+	}
+	return
+}
+
+// WorkbookCollabConn 实时协作连接，由 API 层适配具体的 WebSocket 实现，每条消息是一个 JSON 文本
+type WorkbookCollabConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+	Close() error
+}
+
+type SugarWorkbookCollaborationService struct{}
+
+// collabSession 一个协作客户端连接
+type collabSession struct {
+	id        string
+	fileId    string
+	userId    string
+	userName  string
+	conn      WorkbookCollabConn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	selection json.RawMessage // 当前选区，由 hub.mu 保护
+	readOnly  bool            // 没有编辑权限（加入时或提交修改时校验），只接收其他协作者的修改，由 hub.mu 保护
+}
+
+func (s *collabSession) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+// deliver 把消息放入发送队列；队列已满说明客户端跟不上，断开连接，客户端重连后重新同步
+func (s *collabSession) deliver(data []byte) {
+	select {
+	case <-s.done:
+	case s.send <- data:
+	default:
+		global.GVA_LOG.Warn("协作连接发送队列已满，断开连接", zap.String("fileId", s.fileId), zap.String("sessionId", s.id))
+		s.close()
+	}
+}
+
+func (s *collabSession) sendMessage(message sugarRes.WorkbookCollabMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		global.GVA_LOG.Error("序列化协作消息失败", zap.Error(err))
+		return
+	}
+	s.deliver(data)
+}
+
+func (s *collabSession) sendError(message string) {
+	s.sendMessage(sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeError, Message: message})
+}
+
+// writeLoop 按顺序把发送队列中的消息写入连接
+func (s *collabSession) writeLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case data := <-s.send:
+			if err := s.conn.WriteMessage(data); err != nil {
+				s.close()
+				return
+			}
+		}
+	}
+}
+
+// collabRoom 本实例内同一工作簿的所有协作连接
+type collabRoom struct {
+	fileId      string
+	sessions    []*collabSession // 按加入顺序排列，由 hub.mu 保护
+	stop        chan struct{}
+	unsubscribe func()
+
+	// mu 串行化本实例内对该工作簿的操作追加和广播，保证广播顺序与修订号一致
+	mu                sync.Mutex
+	latestRevision    int // 已知的最新操作修订号
+	snapshotRevision  int // 内容快照已包含的操作修订号
+	requestedRevision int // 最近一次请求快照时的操作修订号
+}
+
+// observe 记录已知的快照修订号和最新操作修订号，调用方需持有 mu
+func (r *collabRoom) observe(snapshotRevision, latestRevision int) {
+	if snapshotRevision > r.snapshotRevision {
+		r.snapshotRevision = snapshotRevision
+	}
+	if latestRevision > r.latestRevision {
+		r.latestRevision = latestRevision
+	}
+	if r.snapshotRevision > r.latestRevision {
+		r.latestRevision = r.snapshotRevision
+	}
+}
+
+// workbookCollabHub 管理本实例的协作房间：本实例内直接分发消息，并通过 broker 与其他实例互相转发
+type workbookCollabHub struct {
+	mu         sync.Mutex
+	rooms      map[string]*collabRoom
+	instance   string
+	brokerOnce sync.Once
+	broker     collabBroker
+}
+
+var collabHub = &workbookCollabHub{rooms: map[string]*collabRoom{}, instance: uuid.New().String()}
+
+// getBroker 首次使用时按配置创建 broker，此时配置和 redis 均已初始化
+func (h *workbookCollabHub) getBroker() collabBroker {
+	h.brokerOnce.Do(func() {
+		h.broker = newCollabBroker()
+	})
+	return h.broker
+}
+
+// join 把连接加入工作簿房间，房间不存在时创建房间、订阅其他实例的消息并启动快照检查
+func (h *workbookCollabHub) join(session *collabSession) *collabRoom {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room := h.rooms[session.fileId]
+	if room == nil {
+		room = &collabRoom{fileId: session.fileId, stop: make(chan struct{})}
+		room.unsubscribe = h.getBroker().Subscribe(session.fileId, h.receive)
+		h.rooms[session.fileId] = room
+		_, interval := collaborationSettings()
+		go h.compactLoop(room, interval)
+	}
+	room.sessions = append(room.sessions, session)
+	return room
+}
+
+// leave 把连接移出房间，房间没有连接时关闭房间
+func (h *workbookCollabHub) leave(room *collabRoom, session *collabSession) {
+	h.mu.Lock()
+	for i, item := range room.sessions {
+		if item == session {
+			room.sessions = append(room.sessions[:i], room.sessions[i+1:]...)
+			break
+		}
+	}
+	empty := len(room.sessions) == 0
+	if empty && h.rooms[room.fileId] == room {
+		delete(h.rooms, room.fileId)
+	}
+	h.mu.Unlock()
+
+	if empty {
+		close(room.stop)
+		room.unsubscribe()
+	}
+}
+
+func (h *workbookCollabHub) sessions(room *collabRoom) []*collabSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*collabSession(nil), room.sessions...)
+}
+
+// presences 返回本实例房间内除 exclude 外的在线协作者
+func (h *workbookCollabHub) presences(room *collabRoom, exclude string) []sugarRes.WorkbookCollabPresence {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	presences := []sugarRes.WorkbookCollabPresence{}
+	for _, session := range room.sessions {
+		if session.id != exclude {
+			presences = append(presences, sugarRes.WorkbookCollabPresence{
+				SessionId: session.id,
+				UserId:    session.userId,
+				UserName:  session.userName,
+				Selection: session.selection,
+			})
+		}
+	}
+	return presences
+}
+
+// broadcast 把消息发给房间内除 exclude 外的连接，并转发给其他实例
+func (h *workbookCollabHub) broadcast(ctx context.Context, room *collabRoom, message sugarRes.WorkbookCollabMessage, exclude string) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		global.GVA_LOG.Error("序列化协作消息失败", zap.Error(err))
+		return
+	}
+	for _, session := range h.sessions(room) {
+		if session.id != exclude {
+			session.deliver(data)
+		}
+	}
+	h.publish(ctx, room.fileId, data, exclude)
+}
+
+func (h *workbookCollabHub) publish(ctx context.Context, fileId string, data []byte, exclude string) {
+	envelope := collabEnvelope{Instance: h.instance, FileId: fileId, Exclude: exclude, Payload: data}
+	if err := h.getBroker().Publish(ctx, envelope); err != nil {
+		global.GVA_LOG.Warn("转发协作消息失败", zap.String("fileId", fileId), zap.Error(err))
+	}
+}
+
+// receive 处理其他实例转发的消息：更新房间的修订号，再分发给本实例的连接
+func (h *workbookCollabHub) receive(envelope collabEnvelope) {
+	if envelope.Instance == h.instance {
+		return
+	}
+	h.mu.Lock()
+	room := h.rooms[envelope.FileId]
+	h.mu.Unlock()
+	if room == nil {
+		return
+	}
+	var message sugarRes.WorkbookCollabMessage
+	if err := json.Unmarshal(envelope.Payload, &message); err != nil {
+		global.GVA_LOG.Warn("解析协作消息失败", zap.String("fileId", envelope.FileId), zap.Error(err))
+		return
+	}
+
+	switch message.Type {
+	case sugarRes.WorkbookCollabTypeOperation:
+		room.mu.Lock()
+		room.observe(0, message.Revision)
+		room.mu.Unlock()
+	case sugarRes.WorkbookCollabTypeSnapshotSaved:
+		room.mu.Lock()
+		room.observe(message.SnapshotRevision, 0)
+		room.mu.Unlock()
+	case sugarRes.WorkbookCollabTypePresence:
+		// 其他实例有新协作者加入：向其通告本实例的在线协作者
+		if message.Joined {
+			for _, presence := range h.presences(room, "") {
+				data, err := json.Marshal(sugarRes.WorkbookCollabMessage{
+					Type:      sugarRes.WorkbookCollabTypePresence,
+					SessionId: presence.SessionId,
+					UserId:    presence.UserId,
+					UserName:  presence.UserName,
+					Selection: presence.Selection,
+				})
+				if err == nil {
+					h.publish(context.Background(), room.fileId, data, "")
+				}
+			}
+		}
+	}
+
+	for _, session := range h.sessions(room) {
+		if session.id != envelope.Exclude {
+			session.deliver(envelope.Payload)
+		}
+	}
+}
+
+// requestSnapshot 请求房间内最早加入的可编辑连接提交内容快照，用于压缩操作日志
+func (h *workbookCollabHub) requestSnapshot(room *collabRoom) {
+	var target *collabSession
+	h.mu.Lock()
+	for _, session := range room.sessions {
+		if !session.readOnly {
+			target = session
+			break
+		}
+	}
+	h.mu.Unlock()
+	if target == nil {
+		return
+	}
+	room.mu.Lock()
+	revision := room.latestRevision
+	room.requestedRevision = revision
+	room.mu.Unlock()
+	target.sendMessage(sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSnapshotRequest, Revision: revision})
+}
+
+// compactLoop 定期检查房间是否有未压缩的操作，有则请求快照，房间关闭时退出
+func (h *workbookCollabHub) compactLoop(room *collabRoom, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-room.stop:
+			return
+		case <-ticker.C:
+			room.mu.Lock()
+			pending := room.latestRevision > room.snapshotRevision
+			room.mu.Unlock()
+			if pending {
+				h.requestSnapshot(room)
+			}
+		}
+	}
+}
+
+// Authorize 校验用户可以查看该工作簿，建立连接前调用；没有编辑权限的用户以只读方式加入协作
+func (s *SugarWorkbookCollaborationService) Authorize(ctx context.Context, fileId string, userId string) error {
+	_, err := (&SugarFileVersionsService{}).getAccessibleWorkbook(ctx, fileId, userId, SugarActionRead)
+	return err
+}
+
+// Serve 处理一个协作连接直到连接断开
+// 连接建立后发送 welcome 消息，客户端随后用 sync 补齐打开工作簿以来的操作；
+// 客户端提交的变更命令按到达顺序分配修订号并写入操作日志，再广播给其他协作者
+func (s *SugarWorkbookCollaborationService) Serve(ctx context.Context, fileId, userId, userName string, conn WorkbookCollabConn) {
+	_, editErr := (&SugarFileVersionsService{}).getAccessibleWorkbook(ctx, fileId, userId, SugarActionEdit)
+	session := &collabSession{
+		id:       uuid.New().String(),
+		fileId:   fileId,
+		userId:   userId,
+		userName: userName,
+		conn:     conn,
+		send:     make(chan []byte, collabSendBufferSize),
+		done:     make(chan struct{}),
+		readOnly: editErr != nil,
+	}
+	go session.writeLoop()
+	room := collabHub.join(session)
+	defer func() {
+		collabHub.leave(room, session)
+		collabHub.broadcast(context.Background(), room, sugarRes.WorkbookCollabMessage{
+			Type:      sugarRes.WorkbookCollabTypeLeave,
+			SessionId: session.id,
+			UserId:    userId,
+		}, session.id)
+		session.close()
+		global.GVA_LOG.Info("协作连接已断开", zap.String("fileId", fileId), zap.String("userId", userId), zap.String("sessionId", session.id))
+	}()
+
+	workspace, latestRevision, err := loadCollabRevisions(ctx, fileId)
+	if err != nil {
+		global.GVA_LOG.Error("读取协作修订号失败", zap.String("fileId", fileId), zap.Error(err))
+		session.sendError("读取工作簿失败")
+		return
+	}
+	room.mu.Lock()
+	room.observe(workspace.OperationRevision, latestRevision)
+	room.mu.Unlock()
+
+	session.sendMessage(sugarRes.WorkbookCollabMessage{
+		Type:             sugarRes.WorkbookCollabTypeWelcome,
+		SessionId:        session.id,
+		UserId:           userId,
+		UserName:         userName,
+		Revision:         latestRevision,
+		SnapshotRevision: workspace.OperationRevision,
+		DocumentRevision: workspace.Revision,
+		ReadOnly:         session.readOnly,
+		Users:            collabHub.presences(room, session.id),
+	})
+	collabHub.broadcast(ctx, room, sugarRes.WorkbookCollabMessage{
+		Type:      sugarRes.WorkbookCollabTypePresence,
+		SessionId: session.id,
+		UserId:    userId,
+		UserName:  userName,
+		Joined:    true,
+	}, session.id)
+	global.GVA_LOG.Info("协作连接已建立", zap.String("fileId", fileId), zap.String("userId", userId), zap.String("sessionId", session.id))
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message sugarRes.WorkbookCollabMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			session.sendError("消息格式不正确")
+			continue
+		}
+		switch message.Type {
+		case sugarRes.WorkbookCollabTypeOperation:
+			s.applyOperation(ctx, room, session, message)
+		case sugarRes.WorkbookCollabTypeSnapshot:
+			s.saveSnapshot(ctx, room, session, message)
+		case sugarRes.WorkbookCollabTypeSync:
+			s.syncOperations(ctx, session, message.Revision)
+		case sugarRes.WorkbookCollabTypePresence:
+			collabHub.mu.Lock()
+			session.selection = message.Selection
+			collabHub.mu.Unlock()
+			collabHub.broadcast(ctx, room, sugarRes.WorkbookCollabMessage{
+				Type:      sugarRes.WorkbookCollabTypePresence,
+				SessionId: session.id,
+				UserId:    userId,
+				UserName:  userName,
+				Selection: message.Selection,
+			}, session.id)
+		default:
+			session.sendError("不支持的消息类型: " + message.Type)
+		}
+	}
+}
+
+// authorizeEdit 提交修改前重新校验编辑权限：连接期间团队角色或文件授权被收回时，连接转为只读，不再写入修改
+func (s *SugarWorkbookCollaborationService) authorizeEdit(ctx context.Context, session *collabSession) bool {
+	collabHub.mu.Lock()
+	readOnly := session.readOnly
+	collabHub.mu.Unlock()
+	if !readOnly {
+		_, err := (&SugarFileVersionsService{}).getAccessibleWorkbook(ctx, session.fileId, session.userId, SugarActionEdit)
+		var permissionErr *SugarPermissionError
+		if errors.As(err, &permissionErr) {
+			global.GVA_LOG.Warn("协作连接的编辑权限已被收回，转为只读", zap.String("fileId", session.fileId), zap.String("userId", session.userId), zap.String("sessionId", session.id))
+			collabHub.mu.Lock()
+			session.readOnly = true
+			collabHub.mu.Unlock()
+			readOnly = true
+		} else if err != nil {
+			session.sendError("校验编辑权限失败，修改未保存")
+			return false
+		}
+	}
+	if readOnly {
+		session.sendError("没有编辑权限，修改不会被保存")
+	}
+	return !readOnly
+}
+
+// applyOperation 为变更命令分配修订号并写入操作日志，确认给提交者并广播给其他协作者
+func (s *SugarWorkbookCollaborationService) applyOperation(ctx context.Context, room *collabRoom, session *collabSession, message sugarRes.WorkbookCollabMessage) {
+	var command struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(message.Command, &command); err != nil || command.Id == "" {
+		session.sendError("变更命令格式不正确")
+		return
+	}
+	if !s.authorizeEdit(ctx, session) {
+		return
+	}
+
+	compactOperations, _ := collaborationSettings()
+	room.mu.Lock()
+	operation, snapshotRevision, err := appendWorkbookOperation(ctx, session, message.Command)
+	if err != nil {
+		room.mu.Unlock()
+		global.GVA_LOG.Error("写入协作操作失败", zap.String("fileId", session.fileId), zap.Error(err))
+		session.sendError("提交修改失败")
+		return
+	}
+	room.observe(snapshotRevision, operation.Revision)
+	session.sendMessage(sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeAck, ClientSeq: message.ClientSeq, Revision: operation.Revision})
+	collabHub.broadcast(ctx, room, sugarRes.WorkbookCollabMessage{
+		Type:      sugarRes.WorkbookCollabTypeOperation,
+		SessionId: session.id,
+		UserId:    session.userId,
+		UserName:  session.userName,
+		Revision:  operation.Revision,
+		Command:   message.Command,
+	}, session.id)
+	compact := room.latestRevision-room.snapshotRevision >= compactOperations && room.latestRevision-room.requestedRevision >= compactOperations
+	room.mu.Unlock()
+
+	if compact {
+		collabHub.requestSnapshot(room)
+	}
+}
+
+// appendWorkbookOperation 在锁定文件的事务中分配下一个修订号并写入操作日志，返回写入的操作和当前快照修订号
+// 修订号在操作日志的最大修订号和快照修订号中取较大者递增，压缩删除旧操作后修订号仍然连续
+func appendWorkbookOperation(ctx context.Context, session *collabSession, command json.RawMessage) (*sugar.SugarWorkbookOperations, int, error) {
+	var operation *sugar.SugarWorkbookOperations
+	var snapshotRevision int
+	err := global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockWorkbookFile(tx, session.fileId)
+		if err != nil {
+			return err
+		}
+		latest, err := latestOperationRevision(tx, session.fileId)
+		if err != nil {
+			return err
+		}
+		if latest < locked.OperationRevision {
+			latest = locked.OperationRevision
+		}
+		now := time.Now()
+		operation = &sugar.SugarWorkbookOperations{
+			FileId:    &session.fileId,
+			Revision:  latest + 1,
+			Command:   []byte(command),
+			SessionId: session.id,
+			CreatedBy: &session.userId,
+			CreatedAt: &now,
+		}
+		snapshotRevision = locked.OperationRevision
+		return tx.Create(operation).Error
+	})
+	return operation, snapshotRevision, err
+}
+
+func latestOperationRevision(tx *gorm.DB, fileId string) (int, error) {
+	var latest int
+	err := tx.Model(&sugar.SugarWorkbookOperations{}).Where("file_id = ?", fileId).Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error
+	return latest, err
+}
+
+// loadCollabRevisions 读取工作簿的快照修订号、内容修订号和最新操作修订号
+func loadCollabRevisions(ctx context.Context, fileId string) (*sugar.SugarWorkspaces, int, error) {
+	var workspace sugar.SugarWorkspaces
+	db := global.GVA_DB.WithContext(ctx)
+	err := db.Select("id", "revision", "operation_revision").Where("id = ? AND type = ? AND deleted_at IS NULL", fileId, "file").First(&workspace).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	latest, err := latestOperationRevision(db, fileId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if latest < workspace.OperationRevision {
+		latest = workspace.OperationRevision
+	}
+	return &workspace, latest, nil
+}
+
+// syncOperations 补发 since 之后的操作；这些操作已压缩到内容快照或数量过多时，要求客户端重新加载工作簿
+func (s *SugarWorkbookCollaborationService) syncOperations(ctx context.Context, session *collabSession, since int) {
+	workspace, latestRevision, err := loadCollabRevisions(ctx, session.fileId)
+	if err != nil {
+		global.GVA_LOG.Error("读取协作修订号失败", zap.String("fileId", session.fileId), zap.Error(err))
+		session.sendError("同步修改失败")
+		return
+	}
+	reload := sugarRes.WorkbookCollabMessage{
+		Type:             sugarRes.WorkbookCollabTypeReload,
+		Revision:         latestRevision,
+		SnapshotRevision: workspace.OperationRevision,
+		DocumentRevision: workspace.Revision,
+	}
+	if since < workspace.OperationRevision || latestRevision-since > collabMaxSyncOperations {
+		session.sendMessage(reload)
+		return
+	}
+
+	var operations []sugar.SugarWorkbookOperations
+	err = global.GVA_DB.WithContext(ctx).Where("file_id = ? AND revision > ?", session.fileId, since).
+		Order("revision").Limit(collabMaxSyncOperations).Find(&operations).Error
+	if err != nil {
+		global.GVA_LOG.Error("读取协作操作失败", zap.String("fileId", session.fileId), zap.Error(err))
+		session.sendError("同步修改失败")
+		return
+	}
+	// 查询期间操作被压缩：补发的操作不连续，改为重新加载
+	if len(operations) > 0 && operations[0].Revision != since+1 {
+		session.sendMessage(reload)
+		return
+	}
+
+	items := make([]sugarRes.WorkbookCollabOperation, 0, len(operations))
+	for _, operation := range operations {
+		item := sugarRes.WorkbookCollabOperation{
+			Revision:  operation.Revision,
+			SessionId: operation.SessionId,
+			Command:   json.RawMessage(operation.Command),
+			CreatedAt: operation.CreatedAt,
+		}
+		if operation.CreatedBy != nil {
+			item.UserId = *operation.CreatedBy
+		}
+		items = append(items, item)
+	}
+	session.sendMessage(sugarRes.WorkbookCollabMessage{
+		Type:             sugarRes.WorkbookCollabTypeOperations,
+		Revision:         latestRevision,
+		SnapshotRevision: workspace.OperationRevision,
+		Operations:       items,
+	})
+}
+
+// saveSnapshot 保存客户端提交的内容快照：快照包含 revision 及之前的全部操作，
+// 写入工作簿内容并记录历史版本，删除已压缩的操作，再通知所有协作者新的内容修订号；
+// 客户端加载的内容修订号 documentRevision 已过期（期间有整体保存或恢复历史版本）时拒绝快照，要求客户端重新加载
+func (s *SugarWorkbookCollaborationService) saveSnapshot(ctx context.Context, room *collabRoom, session *collabSession, message sugarRes.WorkbookCollabMessage) {
+	if message.Revision <= 0 {
+		session.sendError("快照修订号不正确")
+		return
+	}
+	if _, err := decodeUniverWorkbook(message.Content); err != nil {
+		session.sendError("快照内容不是有效的工作簿")
+		return
+	}
+	if !s.authorizeEdit(ctx, session) {
+		return
+	}
+
+	saved := false
+	var reload *sugarRes.WorkbookCollabMessage
+	var documentRevision int
+	err := global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockWorkbookFile(tx, session.fileId)
+		if err != nil {
+			return err
+		}
+		// 已有包含这些操作的快照
+		if message.Revision <= locked.OperationRevision {
+			return nil
+		}
+		latest, err := latestOperationRevision(tx, session.fileId)
+		if err != nil {
+			return err
+		}
+		if message.Revision > latest {
+			return errors.New("快照修订号超出操作日志")
+		}
+		// 快照基于的内容已被覆盖，保存会丢失其他人的修改
+		if message.DocumentRevision != locked.Revision {
+			reload = &sugarRes.WorkbookCollabMessage{
+				Type:             sugarRes.WorkbookCollabTypeReload,
+				Revision:         latest,
+				SnapshotRevision: locked.OperationRevision,
+				DocumentRevision: locked.Revision,
+			}
+			return nil
+		}
+
+		if _, err := snapshotWorkbookContent(tx, locked, []byte(message.Content), session.userId, sugar.FileVersionSourceSave, nil); err != nil {
+			return err
+		}
+		documentRevision = locked.Revision + 1
+		err = tx.Model(locked).Updates(map[string]interface{}{
+			"content":            []byte(message.Content),
+			"revision":           documentRevision,
+			"operation_revision": message.Revision,
+			"updated_by":         session.userId,
+			"updated_at":         time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err = indexWorkbookContent(tx, session.fileId, []byte(message.Content), documentRevision); err != nil {
+			return err
+		}
+		saved = true
+		return tx.Where("file_id = ? AND revision <= ?", session.fileId, message.Revision).Delete(&sugar.SugarWorkbookOperations{}).Error
+	})
+	if err != nil {
+		global.GVA_LOG.Error("保存协作快照失败", zap.String("fileId", session.fileId), zap.Int("revision", message.Revision), zap.Error(err))
+		session.sendError("保存快照失败")
+		return
+	}
+	if reload != nil {
+		global.GVA_LOG.Warn("协作快照基于的内容已过期，要求重新加载", zap.String("fileId", session.fileId), zap.Int("documentRevision", message.DocumentRevision), zap.Int("revision", reload.DocumentRevision))
+		session.sendMessage(*reload)
+		return
+	}
+	if !saved {
+		return
+	}
+
+	room.mu.Lock()
+	room.observe(message.Revision, 0)
+	room.mu.Unlock()
+	collabHub.broadcast(ctx, room, sugarRes.WorkbookCollabMessage{
+		Type:             sugarRes.WorkbookCollabTypeSnapshotSaved,
+		SessionId:        session.id,
+		UserId:           session.userId,
+		Revision:         message.Revision,
+		SnapshotRevision: message.Revision,
+		DocumentRevision: documentRevision,
+	}, "")
+	global.GVA_LOG.Info("协作快照已保存", zap.String("fileId", session.fileId), zap.Int("operationRevision", message.Revision), zap.Int("revision", documentRevision))
+}
@@ -0,0 +1,276 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// fakeCollabConn 内存中的协作连接，incoming 为客户端发出的消息，outgoing 为服务器发给客户端的消息
+type fakeCollabConn struct {
+	incoming  chan []byte
+	outgoing  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *fakeCollabConn) ReadMessage() ([]byte, error) {
+	select {
+	case data := <-c.incoming:
+		return data, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *fakeCollabConn) WriteMessage(data []byte) error {
+	select {
+	case c.outgoing <- data:
+		return nil
+	case <-c.closed:
+		return errors.New("连接已关闭")
+	}
+}
+
+func (c *fakeCollabConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// connectCollab 以指定用户加入测试工作簿的协作，并返回其 welcome 消息
+func connectCollab(t *testing.T, userId string) (*fakeCollabConn, sugarRes.WorkbookCollabMessage) {
+	t.Helper()
+	conn := &fakeCollabConn{incoming: make(chan []byte, 16), outgoing: make(chan []byte, 64), closed: make(chan struct{})}
+	t.Cleanup(func() { _ = conn.Close() })
+	go (&SugarWorkbookCollaborationService{}).Serve(context.Background(), testFileId, userId, "user-"+userId, conn)
+	return conn, expectCollab(t, conn, sugarRes.WorkbookCollabTypeWelcome)
+}
+
+func sendCollab(t *testing.T, conn *fakeCollabConn, message sugarRes.WorkbookCollabMessage) {
+	t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("序列化消息失败: %v", err)
+	}
+	conn.incoming <- data
+}
+
+// expectCollab 读取服务器发来的消息直到出现指定类型，跳过其他类型的消息
+func expectCollab(t *testing.T, conn *fakeCollabConn, messageType string) sugarRes.WorkbookCollabMessage {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-conn.outgoing:
+			var message sugarRes.WorkbookCollabMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("解析消息失败: %v", err)
+			}
+			if message.Type == sugarRes.WorkbookCollabTypeError {
+				t.Fatalf("等待 %s 时收到错误: %s", messageType, message.Message)
+			}
+			if message.Type == messageType {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("等待 %s 消息超时", messageType)
+		}
+	}
+}
+
+func collabCommand(value int) json.RawMessage {
+	return json.RawMessage(`{"id": "sheet.mutation.set-range-values", "params": {"unitId": "wb", "subUnitId": "sheet-1", "cellValue": {"0": {"1": {"v": ` + strconv.Itoa(value) + `}}}}}`)
+}
+
+func TestWorkbookCollaboration(t *testing.T) {
+	setupTeamWorkbook(t)
+	global.GVA_CONFIG.Sugar.Collaboration.CompactOperations = 2
+	defer func() { global.GVA_CONFIG.Sugar.Collaboration.CompactOperations = 0 }()
+
+	service := &SugarWorkbookCollaborationService{}
+	if err := service.Authorize(context.Background(), testFileId, "3"); err == nil {
+		t.Fatal("非团队成员不应能加入协作")
+	}
+
+	alice, welcome := connectCollab(t, "1")
+	if welcome.Revision != 0 || welcome.SessionId == "" || len(welcome.Users) != 0 {
+		t.Fatalf("welcome 消息不符合预期: %+v", welcome)
+	}
+	bob, welcome := connectCollab(t, "2")
+	if len(welcome.Users) != 1 || welcome.Users[0].UserId != "1" {
+		t.Fatalf("应看到已在线的协作者: %+v", welcome.Users)
+	}
+	if joined := expectCollab(t, alice, sugarRes.WorkbookCollabTypePresence); !joined.Joined || joined.UserId != "2" {
+		t.Fatalf("应收到协作者加入通知: %+v", joined)
+	}
+
+	// 双方提交的命令按到达顺序分配修订号，并广播给对方
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 1, Command: collabCommand(1)})
+	if ack := expectCollab(t, alice, sugarRes.WorkbookCollabTypeAck); ack.ClientSeq != 1 || ack.Revision != 1 {
+		t.Fatalf("ack 不符合预期: %+v", ack)
+	}
+	if op := expectCollab(t, bob, sugarRes.WorkbookCollabTypeOperation); op.Revision != 1 || op.UserId != "1" {
+		t.Fatalf("广播的操作不符合预期: %+v", op)
+	}
+	sendCollab(t, bob, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 1, Command: collabCommand(2)})
+	if ack := expectCollab(t, bob, sugarRes.WorkbookCollabTypeAck); ack.Revision != 2 {
+		t.Fatalf("ack 不符合预期: %+v", ack)
+	}
+	if op := expectCollab(t, alice, sugarRes.WorkbookCollabTypeOperation); op.Revision != 2 || op.UserId != "2" {
+		t.Fatalf("广播的操作不符合预期: %+v", op)
+	}
+	// 未压缩的操作达到阈值：请求最早加入的协作者提交快照
+	if request := expectCollab(t, alice, sugarRes.WorkbookCollabTypeSnapshotRequest); request.Revision != 2 {
+		t.Fatalf("快照请求不符合预期: %+v", request)
+	}
+
+	// 补发操作日志
+	sendCollab(t, bob, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSync})
+	if ops := expectCollab(t, bob, sugarRes.WorkbookCollabTypeOperations); len(ops.Operations) != 2 || ops.Operations[0].Revision != 1 || ops.Operations[1].Revision != 2 {
+		t.Fatalf("补发的操作不符合预期: %+v", ops)
+	}
+
+	// 选区广播给其他协作者
+	sendCollab(t, bob, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypePresence, Selection: json.RawMessage(`{"sheetId": "sheet-1", "range": "B2"}`)})
+	if presence := expectCollab(t, alice, sugarRes.WorkbookCollabTypePresence); presence.UserId != "2" || len(presence.Selection) == 0 {
+		t.Fatalf("选区广播不符合预期: %+v", presence)
+	}
+
+	// 提交快照：写入内容、记录历史版本并删除已压缩的操作
+	content := workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 2}}`)
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSnapshot, Revision: 2, Content: json.RawMessage(content)})
+	saved := expectCollab(t, bob, sugarRes.WorkbookCollabTypeSnapshotSaved)
+	if saved.SnapshotRevision != 2 || saved.DocumentRevision != 1 {
+		t.Fatalf("快照保存通知不符合预期: %+v", saved)
+	}
+	expectCollab(t, alice, sugarRes.WorkbookCollabTypeSnapshotSaved)
+
+	current, err := (&SugarWorkspacesService{}).GetWorkbookContent(context.Background(), testFileId, "1")
+	if err != nil {
+		t.Fatalf("获取工作簿内容失败: %v", err)
+	}
+	if current.Revision != 1 || current.OperationRevision != 2 || !sameWorkbookContent(current.Content, []byte(content)) {
+		t.Fatalf("快照未写入工作簿: %+v", current)
+	}
+	var remaining int64
+	global.GVA_DB.Model(&sugar.SugarWorkbookOperations{}).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("已压缩的操作应被删除，剩余 %d", remaining)
+	}
+	if list := listVersions(t); len(list) != 2 {
+		t.Fatalf("快照应生成历史版本: %+v", list)
+	}
+
+	// 早于快照的同步请求要求重新加载；压缩后修订号继续递增
+	sendCollab(t, bob, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSync, Revision: 1})
+	if reload := expectCollab(t, bob, sugarRes.WorkbookCollabTypeReload); reload.SnapshotRevision != 2 || reload.DocumentRevision != 1 {
+		t.Fatalf("reload 消息不符合预期: %+v", reload)
+	}
+	sendCollab(t, bob, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 2, Command: collabCommand(3)})
+	if ack := expectCollab(t, bob, sugarRes.WorkbookCollabTypeAck); ack.Revision != 3 {
+		t.Fatalf("压缩后的修订号应连续: %+v", ack)
+	}
+
+	// 断开连接：通知其他协作者
+	_ = alice.Close()
+	if leave := expectCollab(t, bob, sugarRes.WorkbookCollabTypeLeave); leave.UserId != "1" {
+		t.Fatalf("离开通知不符合预期: %+v", leave)
+	}
+	_ = bob.Close()
+	waitCollabRoomClosed(t)
+}
+
+// waitCollabRoomClosed 等待所有连接退出、房间关闭，避免影响后续测试
+func waitCollabRoomClosed(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		collabHub.mu.Lock()
+		_, open := collabHub.rooms[testFileId]
+		collabHub.mu.Unlock()
+		if !open {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("协作房间未关闭")
+}
+
+// expectCollabError 读取服务器发来的消息直到出现错误消息
+func expectCollabError(t *testing.T, conn *fakeCollabConn) string {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-conn.outgoing:
+			var message sugarRes.WorkbookCollabMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("解析消息失败: %v", err)
+			}
+			if message.Type == sugarRes.WorkbookCollabTypeError {
+				return message.Message
+			}
+		case <-timeout:
+			t.Fatal("等待错误消息超时")
+		}
+	}
+}
+
+func TestWorkbookCollaborationRevalidation(t *testing.T) {
+	setupTeamWorkbook(t)
+	countOperations := func() int64 {
+		var count int64
+		global.GVA_DB.Model(&sugar.SugarWorkbookOperations{}).Count(&count)
+		return count
+	}
+
+	alice, welcome := connectCollab(t, "1")
+	if welcome.ReadOnly || welcome.DocumentRevision != 0 {
+		t.Fatalf("welcome 消息不符合预期: %+v", welcome)
+	}
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 1, Command: collabCommand(1)})
+	expectCollab(t, alice, sugarRes.WorkbookCollabTypeAck)
+
+	// 连接期间其他人整体保存了工作簿：基于旧内容的快照被拒绝，要求重新加载
+	saveWorkbook(t, "2", `"0": {"0": {"v": "成本"}}`)
+	content := workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 1}}`)
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSnapshot, Revision: 1, DocumentRevision: 0, Content: json.RawMessage(content)})
+	if reload := expectCollab(t, alice, sugarRes.WorkbookCollabTypeReload); reload.DocumentRevision != 1 || reload.Revision != 1 {
+		t.Fatalf("reload 消息不符合预期: %+v", reload)
+	}
+	current, err := (&SugarWorkspacesService{}).GetWorkbookContent(context.Background(), testFileId, "1")
+	if err != nil || current.Revision != 1 || current.OperationRevision != 0 || countOperations() != 1 {
+		t.Fatalf("过期的快照不应覆盖内容或压缩操作: %+v %v", current, err)
+	}
+	// 重新加载后基于最新内容的快照正常保存
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSnapshot, Revision: 1, DocumentRevision: 1, Content: json.RawMessage(content)})
+	if saved := expectCollab(t, alice, sugarRes.WorkbookCollabTypeSnapshotSaved); saved.DocumentRevision != 2 || saved.SnapshotRevision != 1 {
+		t.Fatalf("快照保存通知不符合预期: %+v", saved)
+	}
+
+	// 连接期间团队角色被降为只读：后续修改被拒绝，不再写入操作日志
+	global.GVA_DB.Model(&sugar.SugarTeamMembers{}).Where("team_id = ? AND user_id = ?", "team-1", "1").Update("role", "viewer")
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeOperation, ClientSeq: 2, Command: collabCommand(2)})
+	if message := expectCollabError(t, alice); message != "没有编辑权限，修改不会被保存" {
+		t.Fatalf("错误消息不符合预期: %s", message)
+	}
+	sendCollab(t, alice, sugarRes.WorkbookCollabMessage{Type: sugarRes.WorkbookCollabTypeSnapshot, Revision: 2, DocumentRevision: 2, Content: json.RawMessage(content)})
+	if message := expectCollabError(t, alice); message != "没有编辑权限，修改不会被保存" {
+		t.Fatalf("错误消息不符合预期: %s", message)
+	}
+	if count := countOperations(); count != 0 {
+		t.Fatalf("收回编辑权限后不应写入操作，实际 %d 条", count)
+	}
+
+	_ = alice.Close()
+	waitCollabRoomClosed(t)
+}
@@ -0,0 +1,746 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	lineageMaxReferences = 5000 // 每个工作簿保存的最大引用数
+	lineageMaxWorkbooks  = 200  // 影响分析返回的最大工作簿数
+	lineageMaxCells      = 5    // 每个工作簿返回的引用位置数
+	lineageBatchSize     = 500  // 写入引用的批大小
+)
+
+// lineageFunctions 按名称引用语义模型或智能体的公式函数，第一个参数为对象名称
+var lineageFunctions = map[string]string{
+	"SUGAR.GET":          sugar.WorkbookReferenceModel,
+	"SUGAR.CALC":         sugar.WorkbookReferenceModel,
+	"SUGAR.CONTRIBUTION": sugar.WorkbookReferenceModel,
+	"SUGAR.ANOMALY":      sugar.WorkbookReferenceModel,
+	"AI.FETCH":           sugar.WorkbookReferenceAgent,
+}
+
+// formulaCall 公式中的一次函数调用
+type formulaCall struct {
+	name string   // 大写的函数名
+	args []string // 去掉首尾空白的参数原文
+}
+
+// literal 返回第 i 个参数的字符串字面量，参数不是单个字符串字面量（如单元格引用、拼接表达式）时返回 false
+func (c formulaCall) literal(i int) (string, bool) {
+	if i >= len(c.args) {
+		return "", false
+	}
+	arg := c.args[i]
+	if len(arg) < 2 || arg[0] != '"' || arg[len(arg)-1] != '"' {
+		return "", false
+	}
+	inner := arg[1 : len(arg)-1]
+	if strings.Contains(strings.ReplaceAll(inner, `""`, ""), `"`) {
+		return "", false
+	}
+	return strings.ReplaceAll(inner, `""`, `"`), true
+}
+
+// isFormulaNameByte 函数名由字母、数字、点和下划线组成
+func isFormulaNameByte(ch byte) bool {
+	return ch == '.' || ch == '_' || (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9')
+}
+
+// parseFormulaCalls 找出公式中对 lineageFunctions 的全部调用，包括嵌套在其他函数中的调用
+func parseFormulaCalls(formula string) []formulaCall {
+	type frame struct {
+		name     string
+		argStart int
+		args     []string
+	}
+	var calls []formulaCall
+	var stack []*frame
+	nameStart := -1
+	for i := 0; i < len(formula); i++ {
+		ch := formula[i]
+		switch {
+		case ch == '"':
+			// 跳过字符串字面量，"" 为转义的引号
+			for i++; i < len(formula); i++ {
+				if formula[i] == '"' {
+					if i+1 < len(formula) && formula[i+1] == '"' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			nameStart = -1
+		case isFormulaNameByte(ch):
+			if nameStart < 0 {
+				nameStart = i
+			}
+		case ch == '(':
+			name := ""
+			if nameStart >= 0 {
+				name = strings.ToUpper(formula[nameStart:i])
+			}
+			stack = append(stack, &frame{name: name, argStart: i + 1})
+			nameStart = -1
+		case ch == ',' && len(stack) > 0:
+			top := stack[len(stack)-1]
+			top.args = append(top.args, strings.TrimSpace(formula[top.argStart:i]))
+			top.argStart = i + 1
+			nameStart = -1
+		case ch == ')' && len(stack) > 0:
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if last := strings.TrimSpace(formula[top.argStart:i]); last != "" || len(top.args) > 0 {
+				top.args = append(top.args, last)
+			}
+			if _, ok := lineageFunctions[top.name]; ok {
+				calls = append(calls, formulaCall{name: top.name, args: top.args})
+			}
+			nameStart = -1
+		default:
+			nameStart = -1
+		}
+	}
+	return calls
+}
+
+// splitNameList 拆分逗号分隔的字段列表
+func splitNameList(text string) []string {
+	var names []string
+	for _, name := range strings.Split(text, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// keyValueNames 返回 "key:value;key:value" 格式条件中的字段名
+func keyValueNames(text string) []string {
+	var names []string
+	for _, part := range strings.Split(text, ";") {
+		if key, value, ok := strings.Cut(part, ":"); ok && strings.TrimSpace(key) != "" && strings.TrimSpace(value) != "" {
+			names = append(names, strings.TrimSpace(key))
+		}
+	}
+	return names
+}
+
+// filterArgumentNames 返回 SUGAR.GET 和 SUGAR.CALC 筛选参数中的字段名，支持 "key:value" 和成对的 "key", value 两种写法
+// 筛选字段来自单元格引用时无法确定后续参数的写法，停止解析
+func filterArgumentNames(call formulaCall, start int) []string {
+	var names []string
+	for i := start; i < len(call.args); {
+		key, ok := call.literal(i)
+		if !ok {
+			break
+		}
+		if name, value, found := strings.Cut(key, ":"); found {
+			if strings.TrimSpace(name) != "" && strings.TrimSpace(value) != "" {
+				names = append(names, strings.TrimSpace(name))
+			}
+			i++
+			continue
+		}
+		if i+1 < len(call.args) && key != "" {
+			names = append(names, key)
+		}
+		i += 2
+	}
+	return names
+}
+
+// formulaCallColumns 返回 SUGAR 公式引用的模型字段：返回列、计算列、指标、维度和筛选条件中的字段
+// 只能识别以字符串字面量书写的字段，来自单元格引用的字段无法静态确定
+func formulaCallColumns(call formulaCall) []string {
+	var columns []string
+	literal := func(i int) string {
+		value, _ := call.literal(i)
+		return value
+	}
+	switch call.name {
+	case "SUGAR.GET":
+		columns = append(columns, splitNameList(literal(1))...)
+		columns = append(columns, filterArgumentNames(call, 2)...)
+	case "SUGAR.CALC":
+		columns = append(columns, splitNameList(literal(1))...)
+		columns = append(columns, filterArgumentNames(call, 3)...)
+	case "SUGAR.CONTRIBUTION":
+		columns = append(columns, splitNameList(literal(1))...)
+		columns = append(columns, splitNameList(literal(2))...)
+		columns = append(columns, keyValueNames(literal(3))...)
+		columns = append(columns, keyValueNames(literal(4))...)
+		// 分析配置中的比率分子、分母和因子指标也是模型字段
+		for _, part := range strings.Split(literal(5), ";") {
+			key, value, _ := strings.Cut(part, ":")
+			switch strings.TrimSpace(key) {
+			case "ratioNumerator", "ratioDenominator", "factorMetrics":
+				columns = append(columns, splitNameList(value)...)
+			}
+		}
+	case "SUGAR.ANOMALY":
+		columns = append(columns, splitNameList(literal(1))...)
+		columns = append(columns, splitNameList(literal(4))...)
+		columns = append(columns, keyValueNames(literal(5))...)
+	}
+	return columns
+}
+
+// extractWorkbookReferences 从解析后的工作簿中提取公式按名称引用的语义模型及其字段、智能体
+func extractWorkbookReferences(workbook map[string]interface{}) []sugar.SugarWorkbookReferences {
+	references := make([]sugar.SugarWorkbookReferences, 0)
+	seen := map[sugar.SugarWorkbookReferences]bool{}
+	add := func(reference sugar.SugarWorkbookReferences) {
+		if len(references) < lineageMaxReferences && !seen[reference] {
+			seen[reference] = true
+			references = append(references, reference)
+		}
+	}
+	sheets := asObject(workbook["sheets"])
+	for _, sheetId := range orderedSheetIds(workbook, sheets, workbook, sheets) {
+		sheet := asObject(sheets[sheetId])
+		sheetName, _ := sheet["name"].(string)
+		cells := univerCellMatrix(sheet["cellData"])
+		for _, position := range sortedCellPositions(cells) {
+			formula, _ := cells[position]["f"].(string)
+			if formula == "" {
+				continue
+			}
+			for _, call := range parseFormulaCalls(formula) {
+				name, ok := call.literal(0)
+				if name = strings.TrimSpace(name); !ok || name == "" {
+					continue
+				}
+				reference := sugar.SugarWorkbookReferences{
+					Kind:     lineageFunctions[call.name],
+					NameKey:  strings.ToLower(truncateRunes(name, 100)),
+					Name:     truncateRunes(name, 100),
+					Function: call.name,
+					Sheet:    truncateRunes(sheetName, 100),
+					Cell:     cellReference(position.row, position.col),
+				}
+				columns := formulaCallColumns(call)
+				if len(columns) == 0 {
+					add(reference)
+				}
+				for _, column := range columns {
+					reference.ColumnName = truncateRunes(column, 255)
+					add(reference)
+				}
+			}
+		}
+	}
+	return references
+}
+
+// saveWorkbookReferences 写入工作簿的引用关系，调用前应已删除旧的引用
+func saveWorkbookReferences(tx *gorm.DB, fileId string, workbook map[string]interface{}) error {
+	references := extractWorkbookReferences(workbook)
+	if len(references) == 0 {
+		return nil
+	}
+	for i := range references {
+		references[i].FileId = &fileId
+	}
+	return tx.CreateInBatches(&references, lineageBatchSize).Error
+}
+
+// LineageConflictError 修改或删除的对象仍被工作簿引用，确认后可强制执行
+type LineageConflictError struct {
+	Action string
+	Impact *sugarRes.SugarLineageImpact
+}
+
+func (e *LineageConflictError) Error() string {
+	return fmt.Sprintf("%s后，%d 个工作簿中的公式将无法计算，确认后可强制执行", e.Action, e.Impact.WorkbookCount)
+}
+
+// lineageTarget 被工作簿按名称引用的语义模型或智能体
+type lineageTarget struct {
+	kind    string
+	id      string
+	name    string
+	teamId  string
+	columns []string // 只查找引用了这些字段的工作簿，为空时查找全部
+}
+
+func modelLineageTarget(model *sugar.SugarSemanticModels, columns []string) lineageTarget {
+	target := lineageTarget{kind: sugar.WorkbookReferenceModel, columns: columns}
+	if model.Id != nil {
+		target.id = *model.Id
+	}
+	if model.Name != nil {
+		target.name = *model.Name
+	}
+	if model.TeamId != nil {
+		target.teamId = *model.TeamId
+	}
+	return target
+}
+
+// references 返回依赖对象的引用查询，不包括回收站中的工作簿
+// 公式按名称在使用者所在的团队中查找对象，无法静态确定；其他团队中存在同名对象时，该团队的工作簿视为引用其本团队的对象
+func (t lineageTarget) references(ctx context.Context) (*gorm.DB, error) {
+	var table interface{} = &sugar.SugarSemanticModels{}
+	if t.kind == sugar.WorkbookReferenceAgent {
+		table = &sugar.SugarAgents{}
+	}
+	var shadowTeams []string
+	err := global.GVA_DB.WithContext(ctx).Model(table).Where("LOWER(name) = ? AND id <> ? AND team_id <> ?", strings.ToLower(t.name), t.id, t.teamId).
+		Distinct().Pluck("team_id", &shadowTeams).Error
+	if err != nil {
+		return nil, err
+	}
+
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkbookReferences{}).
+		Joins("JOIN sugar_workspaces ON sugar_workspaces.id = sugar_workbook_references.file_id").
+		Where("sugar_workbook_references.kind = ? AND sugar_workbook_references.name_key = ? AND sugar_workspaces.deleted_at IS NULL", t.kind, strings.ToLower(t.name))
+	if len(shadowTeams) > 0 {
+		db = db.Where("sugar_workspaces.team_id NOT IN ?", shadowTeams)
+	}
+	if len(t.columns) > 0 {
+		db = db.Where("sugar_workbook_references.column_name IN ?", t.columns)
+	}
+	return db, nil
+}
+
+// collectLineageImpact 汇总依赖对象的工作簿，只列出用户有权查看的工作簿，总数包括无权查看的
+func collectLineageImpact(ctx context.Context, impact *sugarRes.SugarLineageImpact, targets []lineageTarget, userId string) error {
+	impact.Columns = []sugarRes.SugarLineageColumn{}
+	impact.Workbooks = []sugarRes.SugarLineageWorkbook{}
+	queries := make([]*gorm.DB, 0, len(targets))
+	dependents := map[string]bool{}
+	for _, target := range targets {
+		query, err := target.references(ctx)
+		if err != nil {
+			return err
+		}
+		queries = append(queries, query)
+		var fileIds []string
+		if err = query.Session(&gorm.Session{}).Distinct("sugar_workbook_references.file_id").Pluck("sugar_workbook_references.file_id", &fileIds).Error; err != nil {
+			return err
+		}
+		for _, fileId := range fileIds {
+			dependents[fileId] = true
+		}
+		for i := range impact.Models {
+			if impact.Models[i].Id == target.id {
+				impact.Models[i].WorkbookCount = len(fileIds)
+			}
+		}
+	}
+	impact.WorkbookCount = len(dependents)
+	if len(dependents) == 0 {
+		return nil
+	}
+
+	// 单个语义模型按字段汇总，便于判断重命名或删除字段的影响
+	if len(targets) == 1 && targets[0].kind == sugar.WorkbookReferenceModel {
+		var columns []sugarRes.SugarLineageColumn
+		err := queries[0].Session(&gorm.Session{}).Select("sugar_workbook_references.column_name AS name, COUNT(DISTINCT sugar_workbook_references.file_id) AS workbook_count").
+			Where("sugar_workbook_references.column_name <> ''").Group("sugar_workbook_references.column_name").Order("workbook_count DESC, sugar_workbook_references.column_name ASC").Scan(&columns).Error
+		if err != nil {
+			return err
+		}
+		impact.Columns = append(impact.Columns, columns...)
+	}
+
+	fileIds := make([]string, 0, len(dependents))
+	for fileId := range dependents {
+		fileIds = append(fileIds, fileId)
+	}
+	_, teamIds, sharedIds, err := readableWorkspaceScope(ctx, userId)
+	if err != nil {
+		return err
+	}
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).Select("id", "name", "team_id", "parent_id").Where("id IN ?", fileIds)
+	switch {
+	case len(teamIds) == 0 && len(sharedIds) == 0:
+		return nil
+	case len(sharedIds) == 0:
+		db = db.Where("team_id IN ?", teamIds)
+	case len(teamIds) == 0:
+		db = db.Where("id IN ?", sharedIds)
+	default:
+		db = db.Where("team_id IN ? OR id IN ?", teamIds, sharedIds)
+	}
+	var files []sugar.SugarWorkspaces
+	if err = db.Order("name ASC").Limit(lineageMaxWorkbooks).Find(&files).Error; err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	visibleIds := make([]string, 0, len(files))
+	resultTeamIds := make([]string, 0, len(files))
+	for _, file := range files {
+		visibleIds = append(visibleIds, *file.Id)
+		resultTeamIds = append(resultTeamIds, *file.TeamId)
+	}
+	cellsByFile := map[string][]sugarRes.SugarLineageCellReference{}
+	for _, query := range queries {
+		var references []sugar.SugarWorkbookReferences
+		err = query.Session(&gorm.Session{}).Select("sugar_workbook_references.*").Where("sugar_workbook_references.file_id IN ?", visibleIds).
+			Order("sugar_workbook_references.id ASC").Find(&references).Error
+		if err != nil {
+			return err
+		}
+		for _, reference := range references {
+			cellsByFile[*reference.FileId] = append(cellsByFile[*reference.FileId], sugarRes.SugarLineageCellReference{
+				Sheet: reference.Sheet, Cell: reference.Cell, Function: reference.Function, Column: reference.ColumnName,
+			})
+		}
+	}
+	teamNames := lookupTeamNames(ctx, resultTeamIds)
+	for _, file := range files {
+		cells := cellsByFile[*file.Id]
+		workbook := sugarRes.SugarLineageWorkbook{
+			Id:             *file.Id,
+			TeamId:         *file.TeamId,
+			TeamName:       teamNames[*file.TeamId],
+			ParentId:       file.ParentId,
+			References:     cells,
+			ReferenceCount: len(cells),
+		}
+		if file.Name != nil {
+			workbook.Name = *file.Name
+		}
+		if len(cells) > lineageMaxCells {
+			workbook.References = cells[:lineageMaxCells]
+		}
+		impact.Workbooks = append(impact.Workbooks, workbook)
+	}
+	return nil
+}
+
+// lineageConflict 对象仍被工作簿引用时返回 LineageConflictError
+func lineageConflict(ctx context.Context, action string, impact *sugarRes.SugarLineageImpact, targets []lineageTarget, userId string) error {
+	if err := collectLineageImpact(ctx, impact, targets, userId); err != nil {
+		global.GVA_LOG.Error("查询依赖的工作簿失败", zap.String("kind", impact.Kind), zap.String("id", impact.Id), zap.Error(err))
+		return errors.New("查询依赖的工作簿失败")
+	}
+	if impact.WorkbookCount == 0 {
+		return nil
+	}
+	return &LineageConflictError{Action: action, Impact: impact}
+}
+
+// modelColumnNames 返回语义模型的可返回字段和查询参数名称
+func modelColumnNames(model *sugar.SugarSemanticModels) map[string]bool {
+	names := map[string]bool{}
+	for _, config := range [][]byte{model.ReturnableColumnsConfig, model.ParameterConfig} {
+		var columns map[string]json.RawMessage
+		if len(config) > 0 && json.Unmarshal(config, &columns) == nil {
+			for name := range columns {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// quoteNames 将名称列表格式化为 “a”、“b”
+func quoteNames(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, "“"+name+"”")
+	}
+	return strings.Join(quoted, "、")
+}
+
+// checkModelDeletion 删除语义模型前检查依赖的工作簿
+func checkModelDeletion(ctx context.Context, models []sugar.SugarSemanticModels, userId string) error {
+	if len(models) == 0 {
+		return nil
+	}
+	impact := &sugarRes.SugarLineageImpact{Kind: sugar.WorkbookReferenceModel, Models: []sugarRes.SugarLineageModel{}}
+	targets := make([]lineageTarget, 0, len(models))
+	names := make([]string, 0, len(models))
+	for i := range models {
+		target := modelLineageTarget(&models[i], nil)
+		targets = append(targets, target)
+		names = append(names, target.name)
+		impact.Models = append(impact.Models, sugarRes.SugarLineageModel{Id: target.id, Name: target.name})
+	}
+	if len(models) == 1 {
+		impact.Id, impact.Name = targets[0].id, targets[0].name
+	}
+	return lineageConflict(ctx, "删除语义模型"+quoteNames(names), impact, targets, userId)
+}
+
+// checkModelUpdate 更新语义模型前检查重命名模型或移除字段对工作簿的影响，未提交的配置视为不修改
+func checkModelUpdate(ctx context.Context, oldModel *sugar.SugarSemanticModels, model *sugar.SugarSemanticModels, userId string) error {
+	target := modelLineageTarget(oldModel, nil)
+	impact := &sugarRes.SugarLineageImpact{Kind: sugar.WorkbookReferenceModel, Id: target.id, Name: target.name, Models: []sugarRes.SugarLineageModel{}}
+	if model.Name != nil && !strings.EqualFold(strings.TrimSpace(*model.Name), target.name) {
+		return lineageConflict(ctx, "重命名语义模型“"+target.name+"”", impact, []lineageTarget{target}, userId)
+	}
+
+	updated := *oldModel
+	if len(model.ReturnableColumnsConfig) > 0 {
+		updated.ReturnableColumnsConfig = model.ReturnableColumnsConfig
+	}
+	if len(model.ParameterConfig) > 0 {
+		updated.ParameterConfig = model.ParameterConfig
+	}
+	remaining := modelColumnNames(&updated)
+	var removed []string
+	for name := range modelColumnNames(oldModel) {
+		if !remaining[name] {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	sort.Strings(removed)
+	target.columns = removed
+	return lineageConflict(ctx, "移除语义模型“"+target.name+"”的字段"+quoteNames(removed), impact, []lineageTarget{target}, userId)
+}
+
+// checkConnectionDeletion 删除数据库连接前检查使用该连接的语义模型及依赖这些模型的工作簿
+func checkConnectionDeletion(ctx context.Context, connections []sugar.SugarDbConnections, userId string) error {
+	if len(connections) == 0 {
+		return nil
+	}
+	impact, targets, err := connectionLineage(ctx, connections)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(connections))
+	for _, connection := range connections {
+		if connection.Name != nil {
+			names = append(names, *connection.Name)
+		}
+	}
+	return lineageConflict(ctx, "删除数据库连接"+quoteNames(names), impact, targets, userId)
+}
+
+// connectionLineage 返回使用数据库连接的语义模型
+func connectionLineage(ctx context.Context, connections []sugar.SugarDbConnections) (*sugarRes.SugarLineageImpact, []lineageTarget, error) {
+	impact := &sugarRes.SugarLineageImpact{Kind: "connection", Models: []sugarRes.SugarLineageModel{}}
+	if len(connections) == 1 {
+		impact.Id = *connections[0].Id
+		if connections[0].Name != nil {
+			impact.Name = *connections[0].Name
+		}
+	}
+	connectionIds := make([]string, 0, len(connections))
+	for _, connection := range connections {
+		connectionIds = append(connectionIds, *connection.Id)
+	}
+	var models []sugar.SugarSemanticModels
+	err := global.GVA_DB.WithContext(ctx).Select("id", "name", "team_id").Where("connection_id IN ?", connectionIds).Order("name ASC").Find(&models).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	targets := make([]lineageTarget, 0, len(models))
+	for i := range models {
+		target := modelLineageTarget(&models[i], nil)
+		targets = append(targets, target)
+		impact.Models = append(impact.Models, sugarRes.SugarLineageModel{Id: target.id, Name: target.name})
+	}
+	return impact, targets, nil
+}
+
+type SugarWorkbookLineageService struct{}
+
+// GetModelImpact 影响分析：依赖语义模型或其指定字段的工作簿
+func (s *SugarWorkbookLineageService) GetModelImpact(ctx context.Context, req sugarReq.SugarLineageImpactRequest, userId string) (*sugarRes.SugarLineageImpact, error) {
+	var model sugar.SugarSemanticModels
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", req.Id).First(&model).Error; err != nil {
+		return nil, errors.New("语义模型不存在")
+	}
+	if err := authorizeTeamResource(ctx, model.TeamId, userId, SugarResourceSemanticModel, SugarActionRead); err != nil {
+		return nil, err
+	}
+	target := modelLineageTarget(&model, req.Columns)
+	impact := &sugarRes.SugarLineageImpact{Kind: sugar.WorkbookReferenceModel, Id: target.id, Name: target.name, Models: []sugarRes.SugarLineageModel{}}
+	if err := collectLineageImpact(ctx, impact, []lineageTarget{target}, userId); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+// GetConnectionImpact 影响分析：使用数据库连接的语义模型，以及依赖这些模型的工作簿
+func (s *SugarWorkbookLineageService) GetConnectionImpact(ctx context.Context, id string, userId string) (*sugarRes.SugarLineageImpact, error) {
+	var connection sugar.SugarDbConnections
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&connection).Error; err != nil {
+		return nil, errors.New("数据库连接不存在")
+	}
+	if err := authorizeTeamResource(ctx, connection.TeamId, userId, SugarResourceDbConnection, SugarActionRead); err != nil {
+		return nil, err
+	}
+	impact, targets, err := connectionLineage(ctx, []sugar.SugarDbConnections{connection})
+	if err != nil {
+		return nil, err
+	}
+	if err = collectLineageImpact(ctx, impact, targets, userId); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+// GetAgentImpact 影响分析：通过 AI.FETCH 引用智能体的工作簿
+func (s *SugarWorkbookLineageService) GetAgentImpact(ctx context.Context, id string, userId string) (*sugarRes.SugarLineageImpact, error) {
+	var agent sugar.SugarAgents
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&agent).Error; err != nil {
+		return nil, errors.New("智能体不存在")
+	}
+	if err := authorizeTeamResource(ctx, agent.TeamId, userId, SugarResourceAgent, SugarActionRead); err != nil {
+		return nil, err
+	}
+	target := lineageTarget{kind: sugar.WorkbookReferenceAgent, id: *agent.Id, teamId: *agent.TeamId}
+	if agent.Name != nil {
+		target.name = *agent.Name
+	}
+	impact := &sugarRes.SugarLineageImpact{Kind: sugar.WorkbookReferenceAgent, Id: target.id, Name: target.name, Models: []sugarRes.SugarLineageModel{}}
+	if err := collectLineageImpact(ctx, impact, []lineageTarget{target}, userId); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+// GetWorkbookDependencies 查询工作簿依赖的语义模型、字段和智能体，并标出已不存在的对象和字段
+// 名称优先在工作簿所属团队中查找，其次在用户所在的其他团队中查找，与公式计算时的查找范围一致
+func (s *SugarWorkbookLineageService) GetWorkbookDependencies(ctx context.Context, id string, userId string) ([]sugarRes.SugarWorkbookDependency, error) {
+	var file sugar.SugarWorkspaces
+	if err := global.GVA_DB.WithContext(ctx).Omit("content").Where("id = ? AND type = ? AND deleted_at IS NULL", id, "file").First(&file).Error; err != nil {
+		return nil, errors.New("工作簿不存在")
+	}
+	if err := authorizeWorkspaceItem(ctx, &file, userId, SugarActionRead); err != nil {
+		return nil, err
+	}
+	var references []sugar.SugarWorkbookReferences
+	if err := global.GVA_DB.WithContext(ctx).Where("file_id = ?", id).Order("id ASC").Find(&references).Error; err != nil {
+		return nil, err
+	}
+
+	type dependencyKey struct{ kind, name string }
+	dependencies := make([]*sugarRes.SugarWorkbookDependency, 0)
+	byKey := map[dependencyKey]*sugarRes.SugarWorkbookDependency{}
+	columnSeen := map[dependencyKey]map[string]bool{}
+	names := map[string][]string{}
+	for _, reference := range references {
+		key := dependencyKey{reference.Kind, reference.NameKey}
+		dependency, ok := byKey[key]
+		if !ok {
+			dependency = &sugarRes.SugarWorkbookDependency{Kind: reference.Kind, Name: reference.Name, Columns: []string{}, MissingColumns: []string{}, References: []sugarRes.SugarLineageCellReference{}}
+			byKey[key] = dependency
+			columnSeen[key] = map[string]bool{}
+			dependencies = append(dependencies, dependency)
+			names[reference.Kind] = append(names[reference.Kind], reference.NameKey)
+		}
+		if reference.ColumnName != "" && !columnSeen[key][reference.ColumnName] {
+			columnSeen[key][reference.ColumnName] = true
+			dependency.Columns = append(dependency.Columns, reference.ColumnName)
+		}
+		dependency.ReferenceCount++
+		if len(dependency.References) < lineageMaxCells {
+			dependency.References = append(dependency.References, sugarRes.SugarLineageCellReference{
+				Sheet: reference.Sheet, Cell: reference.Cell, Function: reference.Function, Column: reference.ColumnName,
+			})
+		}
+	}
+	if len(dependencies) == 0 {
+		return []sugarRes.SugarWorkbookDependency{}, nil
+	}
+
+	roles, err := userTeamRoles(ctx, userId)
+	if err != nil {
+		return nil, errors.New("获取用户团队信息失败")
+	}
+	teamIds := append(teamIdsOf(roles), *file.TeamId)
+	preferred := func(current *string, candidate *string) bool {
+		return current == nil || (*current != *file.TeamId && candidate != nil && *candidate == *file.TeamId)
+	}
+
+	if len(names[sugar.WorkbookReferenceModel]) > 0 {
+		var models []sugar.SugarSemanticModels
+		err = global.GVA_DB.WithContext(ctx).Where("LOWER(name) IN ? AND team_id IN ?", names[sugar.WorkbookReferenceModel], teamIds).Order("id ASC").Find(&models).Error
+		if err != nil {
+			return nil, err
+		}
+		resolved := map[string]*sugar.SugarSemanticModels{}
+		for i := range models {
+			key := strings.ToLower(*models[i].Name)
+			if current := resolved[key]; current == nil || preferred(current.TeamId, models[i].TeamId) {
+				resolved[key] = &models[i]
+			}
+		}
+		connectionIds := make([]string, 0)
+		for _, model := range resolved {
+			if model.ConnectionId != nil {
+				connectionIds = append(connectionIds, *model.ConnectionId)
+			}
+		}
+		connectionNames := map[string]string{}
+		if len(connectionIds) > 0 {
+			var connections []sugar.SugarDbConnections
+			if err = global.GVA_DB.WithContext(ctx).Select("id", "name").Where("id IN ?", connectionIds).Find(&connections).Error; err != nil {
+				return nil, err
+			}
+			for _, connection := range connections {
+				if connection.Name != nil {
+					connectionNames[*connection.Id] = *connection.Name
+				}
+			}
+		}
+		for key, dependency := range byKey {
+			model := resolved[key.name]
+			if key.kind != sugar.WorkbookReferenceModel || model == nil {
+				continue
+			}
+			dependency.Id, dependency.TeamId = *model.Id, *model.TeamId
+			if model.ConnectionId != nil {
+				dependency.ConnectionId, dependency.ConnectionName = *model.ConnectionId, connectionNames[*model.ConnectionId]
+			}
+			available := modelColumnNames(model)
+			for _, column := range dependency.Columns {
+				if !available[column] {
+					dependency.MissingColumns = append(dependency.MissingColumns, column)
+				}
+			}
+		}
+	}
+
+	if len(names[sugar.WorkbookReferenceAgent]) > 0 {
+		var agents []sugar.SugarAgents
+		err = global.GVA_DB.WithContext(ctx).Select("id", "name", "team_id").Where("LOWER(name) IN ? AND team_id IN ?", names[sugar.WorkbookReferenceAgent], teamIds).Order("id ASC").Find(&agents).Error
+		if err != nil {
+			return nil, err
+		}
+		resolved := map[string]*sugar.SugarAgents{}
+		for i := range agents {
+			key := strings.ToLower(*agents[i].Name)
+			if current := resolved[key]; current == nil || preferred(current.TeamId, agents[i].TeamId) {
+				resolved[key] = &agents[i]
+			}
+		}
+		for key, dependency := range byKey {
+			if agent := resolved[key.name]; key.kind == sugar.WorkbookReferenceAgent && agent != nil {
+				dependency.Id, dependency.TeamId = *agent.Id, *agent.TeamId
+			}
+		}
+	}
+
+	result := make([]sugarRes.SugarWorkbookDependency, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		result = append(result, *dependency)
+	}
+	return result, nil
+}
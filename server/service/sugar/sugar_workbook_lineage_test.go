@@ -0,0 +1,186 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestParseFormulaCalls(t *testing.T) {
+	calls := parseFormulaCalls(`=IFERROR(sugar.get("销售", "金额, 城市", "城市:上海", "渠道", B2), 0) + AI.FETCH("分析""助手", A1) + SUGAR.CALC(A1, "金额", "SUM") + SUM(1, 2)`)
+	if len(calls) != 3 {
+		t.Fatalf("应识别 3 个调用: %+v", calls)
+	}
+	if name, ok := calls[0].literal(0); !ok || name != "销售" || calls[0].name != "SUGAR.GET" {
+		t.Fatalf("嵌套的 SUGAR.GET 解析错误: %+v", calls[0])
+	}
+	if got := formulaCallColumns(calls[0]); !reflect.DeepEqual(got, []string{"金额", "城市", "城市", "渠道"}) {
+		t.Fatalf("SUGAR.GET 引用的字段不符合预期: %v", got)
+	}
+	if name, ok := calls[1].literal(0); !ok || name != `分析"助手` {
+		t.Fatalf("转义的引号应还原: %q", name)
+	}
+	// 模型名称来自单元格引用时无法确定
+	if _, ok := calls[2].literal(0); ok {
+		t.Fatal("单元格引用不是字符串字面量")
+	}
+
+	contribution := parseFormulaCalls(`=SUGAR.CONTRIBUTION("销售", "金额", "城市,渠道", "月份:2024-02", "月份:2024-01", "factorMetrics:单价,数量")`)[0]
+	if got := formulaCallColumns(contribution); !reflect.DeepEqual(got, []string{"金额", "城市", "渠道", "月份", "月份", "单价", "数量"}) {
+		t.Fatalf("SUGAR.CONTRIBUTION 引用的字段不符合预期: %v", got)
+	}
+	anomaly := parseFormulaCalls(`=SUGAR.ANOMALY("销售", "金额", "2024-01-01", "2024-06-30", "城市", "渠道:线上")`)[0]
+	if got := formulaCallColumns(anomaly); !reflect.DeepEqual(got, []string{"金额", "城市", "渠道"}) {
+		t.Fatalf("SUGAR.ANOMALY 引用的字段不符合预期: %v", got)
+	}
+}
+
+// setupLineage 初始化血缘测试数据：团队1的连接 conn-1 下有模型“销售”，团队2有同名模型；团队1有智能体 Analyst
+// 团队1的文件引用了模型的“金额”“城市”字段和智能体，团队2的文件引用同名模型
+func setupLineage(t *testing.T) {
+	t.Helper()
+	setupTeamWorkbook(t)
+	seedTestData(t,
+		`INSERT INTO sugar_db_connections (id, name, team_id) VALUES ('conn-1', '数仓', 'team-1')`,
+		`INSERT INTO sugar_semantic_models (id, name, team_id, connection_id, parameter_config, returnable_columns_config) VALUES
+			('model-1', '销售', 'team-1', 'conn-1', '{"城市": {"column": "city"}}', '{"金额": {"column": "amount", "type": "metric"}, "城市": {"column": "city"}}'),
+			('model-2', '销售', 'team-2', NULL, '{}', '{"金额": {"column": "amount"}}')`,
+		`INSERT INTO sugar_agents (id, name, team_id) VALUES ('agent-1', 'Analyst', 'team-1')`,
+		`INSERT INTO sugar_workspaces (id, name, type, team_id) VALUES ('file-2', '团队2报表', 'file', 'team-2')`,
+	)
+	saveWorkbook(t, "1", `"0": {
+		"0": {"f": "=SUGAR.GET(\"销售\", \"金额\", \"城市:上海\")", "v": 1},
+		"1": {"f": "=SUGAR.CALC(\"销售\", \"金额\", \"SUM\")", "v": 2},
+		"2": {"f": "=AI.FETCH(\"analyst\", A1)", "v": "ok"}
+	}`)
+	err := global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		return indexWorkbookContent(tx, "file-2", datatypes.JSON(workbookJSON(`"0": {"0": {"f": "=SUGAR.GET(\"销售\", \"金额\")"}}`)), 0)
+	})
+	if err != nil {
+		t.Fatalf("建立团队2文件的索引失败: %v", err)
+	}
+}
+
+func expectLineageConflict(t *testing.T, err error, workbookCount int) *sugarRes.SugarLineageImpact {
+	t.Helper()
+	var conflictErr *LineageConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("期望依赖冲突，实际: %v", err)
+	}
+	if conflictErr.Impact.WorkbookCount != workbookCount {
+		t.Fatalf("受影响的工作簿应为 %d 个: %+v", workbookCount, conflictErr.Impact)
+	}
+	return conflictErr.Impact
+}
+
+func TestWorkbookLineageImpact(t *testing.T) {
+	setupLineage(t)
+	ctx := context.Background()
+	service := &SugarWorkbookLineageService{}
+
+	// 团队2有同名模型，团队2的工作簿不算作依赖团队1的模型
+	impact, err := service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-1"}, "1")
+	if err != nil || impact.WorkbookCount != 1 || len(impact.Workbooks) != 1 || impact.Workbooks[0].Id != testFileId {
+		t.Fatalf("模型影响分析不符合预期: %+v %v", impact, err)
+	}
+	if want := []sugarRes.SugarLineageColumn{{Name: "城市", WorkbookCount: 1}, {Name: "金额", WorkbookCount: 1}}; !reflect.DeepEqual(impact.Columns, want) {
+		t.Fatalf("按字段汇总不符合预期: %+v", impact.Columns)
+	}
+	if workbook := impact.Workbooks[0]; workbook.ReferenceCount != 3 || workbook.References[0].Cell != "A1" || workbook.TeamName != "财务部" {
+		t.Fatalf("引用位置不符合预期: %+v", workbook)
+	}
+	if impact, err = service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-1", Columns: []string{"渠道"}}, "1"); err != nil || impact.WorkbookCount != 0 {
+		t.Fatalf("未被引用的字段不应有依赖: %+v %v", impact, err)
+	}
+	if impact, err = service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-2"}, "12"); err != nil || impact.WorkbookCount != 1 || impact.Workbooks[0].Id != "file-2" {
+		t.Fatalf("团队2的模型应只被团队2的工作簿依赖: %+v %v", impact, err)
+	}
+
+	// 连接 → 模型 → 工作簿
+	impact, err = service.GetConnectionImpact(ctx, "conn-1", "1")
+	if err != nil || impact.WorkbookCount != 1 || len(impact.Models) != 1 || impact.Models[0].WorkbookCount != 1 {
+		t.Fatalf("连接影响分析不符合预期: %+v %v", impact, err)
+	}
+	// 智能体名称不区分大小写
+	if impact, err = service.GetAgentImpact(ctx, "agent-1", "1"); err != nil || impact.WorkbookCount != 1 {
+		t.Fatalf("智能体影响分析不符合预期: %+v %v", impact, err)
+	}
+
+	// 团队外的用户不能查看，团队查看者可以
+	_, err = service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-1"}, "3")
+	expectPermissionDenied(t, err, "")
+	if impact, err = service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-1"}, "12"); err != nil || impact.WorkbookCount != 1 || len(impact.Workbooks) != 1 {
+		t.Fatalf("团队查看者应能看到依赖的工作簿: %+v %v", impact, err)
+	}
+
+	// 回收站中的工作簿不算依赖
+	deleteItem(t, testFileId, "1")
+	if impact, err = service.GetModelImpact(ctx, sugarReq.SugarLineageImpactRequest{Id: "model-1"}, "1"); err != nil || impact.WorkbookCount != 0 {
+		t.Fatalf("已删除的工作簿不应算作依赖: %+v %v", impact, err)
+	}
+}
+
+func TestSemanticModelChangesBlockedByWorkbooks(t *testing.T) {
+	setupLineage(t)
+	ctx := context.Background()
+	service := &SugarSemanticModelsService{}
+	name := func(value string) *string { return &value }
+
+	// 重命名模型会使全部引用失效
+	err := service.UpdateSugarSemanticModels(ctx, sugar.SugarSemanticModels{Id: name("model-1"), Name: name("销售明细")}, "10", false)
+	expectLineageConflict(t, err, 1)
+
+	// 字段仍作为查询参数存在，不影响引用；移除被引用的“金额”需要确认
+	err = service.UpdateSugarSemanticModels(ctx, sugar.SugarSemanticModels{Id: name("model-1"), ReturnableColumnsConfig: datatypes.JSON(`{"金额": {"column": "amount"}}`)}, "10", false)
+	if err != nil {
+		t.Fatalf("移除未被引用的字段不应被阻止: %v", err)
+	}
+	removeAmount := sugar.SugarSemanticModels{Id: name("model-1"), ReturnableColumnsConfig: datatypes.JSON(`{"数量": {"column": "qty"}}`)}
+	impact := expectLineageConflict(t, service.UpdateSugarSemanticModels(ctx, removeAmount, "10", false), 1)
+	if len(impact.Columns) != 1 || impact.Columns[0].Name != "金额" {
+		t.Fatalf("应只列出被移除的字段: %+v", impact.Columns)
+	}
+	if err = service.UpdateSugarSemanticModels(ctx, removeAmount, "10", true); err != nil {
+		t.Fatalf("确认后应能更新: %v", err)
+	}
+
+	// 工作簿依赖中标出已不存在的字段
+	dependencies, err := (&SugarWorkbookLineageService{}).GetWorkbookDependencies(ctx, testFileId, "1")
+	if err != nil || len(dependencies) != 2 {
+		t.Fatalf("工作簿依赖不符合预期: %+v %v", dependencies, err)
+	}
+	model, agent := dependencies[0], dependencies[1]
+	if model.Id != "model-1" || model.ConnectionName != "数仓" || !reflect.DeepEqual(model.MissingColumns, []string{"金额"}) || model.ReferenceCount != 3 {
+		t.Fatalf("模型依赖不符合预期: %+v", model)
+	}
+	if agent.Kind != sugar.WorkbookReferenceAgent || agent.Id != "agent-1" {
+		t.Fatalf("智能体依赖不符合预期: %+v", agent)
+	}
+
+	// 删除连接和模型前需要确认
+	connections := &SugarDbConnectionsService{}
+	impact = expectLineageConflict(t, connections.DeleteSugarDbConnections(ctx, "conn-1", "10", false), 1)
+	if len(impact.Models) != 1 || impact.Models[0].Id != "model-1" {
+		t.Fatalf("应列出使用该连接的模型: %+v", impact.Models)
+	}
+	expectLineageConflict(t, service.DeleteSugarSemanticModelsByIds(ctx, []string{"model-1"}, "10", false), 1)
+	expectLineageConflict(t, service.DeleteSugarSemanticModels(ctx, "model-1", "10", false), 1)
+	if err = service.DeleteSugarSemanticModels(ctx, "model-1", "10", true); err != nil {
+		t.Fatalf("确认后应能删除: %v", err)
+	}
+	if countWhere(t, &sugar.SugarSemanticModels{}, "id = ?", "model-1") != 0 {
+		t.Fatal("模型应已删除")
+	}
+	// 连接下已没有模型，可直接删除
+	if err = connections.DeleteSugarDbConnections(ctx, "conn-1", "10", false); err != nil {
+		t.Fatalf("没有依赖时应能直接删除连接: %v", err)
+	}
+}
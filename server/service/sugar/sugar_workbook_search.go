@@ -0,0 +1,433 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	searchEntryMaxRunes  = 500   // 每个单元格保存的文本和公式的最大长度
+	searchMaxEntries     = 20000 // 每个工作簿保存的最大条目数
+	searchMaxTerms       = 50000 // 每个工作簿索引的最大搜索词数
+	searchTermMaxRunes   = 64    // 英文和数字词的最大长度，超出部分不参与索引
+	searchMaxMatches     = 5     // 每个搜索结果返回的命中位置数
+	searchMaxKeywords    = 10    // 关键词最多包含的词数
+	searchRebuildBatch   = 100   // 重建索引时每批处理的工作簿数
+	searchIndexBatchSize = 500   // 写入搜索词的批大小
+)
+
+// isIdeograph 中日韩文字没有空格分词，按单字和相邻两字索引
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchTerms 将文本切分为小写的搜索词：英文和数字按连续的字母数字切词，中日韩文字取单字及相邻两字
+// indexing 为 false 时用于切分查询，连续两个以上的中日韩文字只取相邻两字，避免单字带来的大量候选
+func searchTerms(text string, indexing bool) []string {
+	var terms []string
+	var word, ideographs []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			if len(word) > searchTermMaxRunes {
+				word = word[:searchTermMaxRunes]
+			}
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushIdeographs := func() {
+		if len(ideographs) == 1 || indexing {
+			for _, r := range ideographs {
+				terms = append(terms, string(r))
+			}
+		}
+		for i := 0; i+1 < len(ideographs); i++ {
+			terms = append(terms, string(ideographs[i:i+2]))
+		}
+		ideographs = ideographs[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isIdeograph(r):
+			flushWord()
+			ideographs = append(ideographs, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushIdeographs()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushIdeographs()
+		}
+	}
+	flushWord()
+	flushIdeographs()
+	return terms
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max])
+	}
+	return text
+}
+
+// cellText 返回单元格显示的文本：富文本取正文，其余取值
+func cellText(cell map[string]interface{}) string {
+	if body, ok := asObject(cell["p"])["body"].(map[string]interface{}); ok {
+		if stream, ok := body["dataStream"].(string); ok {
+			return strings.TrimSpace(strings.TrimRight(stream, "\r\n"))
+		}
+	}
+	switch value := cell["v"].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// sortedCellPositions 按行、列顺序返回单元格位置
+func sortedCellPositions(cells map[univerCellPosition]map[string]interface{}) []univerCellPosition {
+	positions := make([]univerCellPosition, 0, len(cells))
+	for position := range cells {
+		positions = append(positions, position)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].row != positions[j].row {
+			return positions[i].row < positions[j].row
+		}
+		return positions[i].col < positions[j].col
+	})
+	return positions
+}
+
+// extractWorkbookSearchContent 从解析后的工作簿中提取工作表名、单元格文本和公式，以及去重后的搜索词
+// 公式引用的模型和智能体由 extractWorkbookReferences 提取，按引用查找时使用引用关系
+func extractWorkbookSearchContent(workbook map[string]interface{}) ([]sugarRes.SugarWorkbookSearchMatch, map[string]bool) {
+	terms := map[string]bool{}
+	addText := func(text string) {
+		for _, term := range searchTerms(text, true) {
+			if len(terms) < searchMaxTerms {
+				terms[term] = true
+			}
+		}
+	}
+
+	sheets := asObject(workbook["sheets"])
+	entries := make([]sugarRes.SugarWorkbookSearchMatch, 0)
+	for _, sheetId := range orderedSheetIds(workbook, sheets, workbook, sheets) {
+		sheet := asObject(sheets[sheetId])
+		sheetName, _ := sheet["name"].(string)
+		if sheetName != "" {
+			entries = append(entries, sugarRes.SugarWorkbookSearchMatch{Sheet: sheetName})
+			addText(sheetName)
+		}
+
+		cells := univerCellMatrix(sheet["cellData"])
+		for _, position := range sortedCellPositions(cells) {
+			if len(entries) >= searchMaxEntries {
+				break
+			}
+			cell := cells[position]
+			text := truncateRunes(cellText(cell), searchEntryMaxRunes)
+			formula, _ := cell["f"].(string)
+			formula = truncateRunes(formula, searchEntryMaxRunes)
+			if text == "" && formula == "" {
+				continue
+			}
+			entries = append(entries, sugarRes.SugarWorkbookSearchMatch{Sheet: sheetName, Cell: cellReference(position.row, position.col), Text: text, Formula: formula})
+			addText(text)
+			addText(formula)
+		}
+	}
+	return entries, terms
+}
+
+// indexWorkbookContent 重建工作簿的搜索索引和引用关系，应与内容写入在同一事务中执行
+// 内容无法解析时只清空索引，不影响内容的保存
+func indexWorkbookContent(tx *gorm.DB, fileId string, content datatypes.JSON, revision int) error {
+	if err := deleteWorkbookSearchIndex(tx, []string{fileId}); err != nil {
+		return err
+	}
+	workbook, err := decodeUniverWorkbook(content)
+	if err != nil {
+		global.GVA_LOG.Warn("工作簿内容无法解析，跳过建立搜索索引", zap.String("fileId", fileId), zap.Error(err))
+		return nil
+	}
+	if err = saveWorkbookReferences(tx, fileId, workbook); err != nil {
+		return err
+	}
+	entries, terms := extractWorkbookSearchContent(workbook)
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	document := sugar.SugarWorkbookSearchDocuments{FileId: &fileId, Entries: entriesJSON, Revision: revision, IndexedAt: &now}
+	if err = tx.Create(&document).Error; err != nil {
+		return err
+	}
+	rows := make([]sugar.SugarWorkbookSearchTerms, 0, len(terms))
+	for term := range terms {
+		rows = append(rows, sugar.SugarWorkbookSearchTerms{FileId: &fileId, Kind: sugar.WorkbookSearchTermText, Term: term})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&rows, searchIndexBatchSize).Error
+}
+
+// deleteWorkbookSearchIndex 删除工作簿的搜索索引和引用关系，彻底删除工作簿时调用
+func deleteWorkbookSearchIndex(tx *gorm.DB, fileIds []string) error {
+	if err := tx.Where("file_id IN ?", fileIds).Delete(&sugar.SugarWorkbookReferences{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("file_id IN ?", fileIds).Delete(&sugar.SugarWorkbookSearchTerms{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("file_id IN ?", fileIds).Delete(&sugar.SugarWorkbookSearchDocuments{}).Error
+}
+
+// referenceCellKey 引用关系中单元格位置的键，工作表名按引用表的长度截断
+func referenceCellKey(sheet, cell string) string {
+	return truncateRunes(sheet, 100) + "!" + cell
+}
+
+// matchSearchEntries 返回内容中命中关键词或引用了指定模型、智能体的位置
+// referencedCells 为引用了指定模型或智能体的单元格，键由 referenceCellKey 生成
+func matchSearchEntries(entries []sugarRes.SugarWorkbookSearchMatch, keywords []string, referencedCells map[string]bool) []sugarRes.SugarWorkbookSearchMatch {
+	matches := make([]sugarRes.SugarWorkbookSearchMatch, 0)
+	for _, entry := range entries {
+		haystack := strings.ToLower(entry.Text + "\n" + entry.Formula)
+		if entry.Cell == "" {
+			haystack = strings.ToLower(entry.Sheet)
+		}
+		matched := entry.Cell != "" && referencedCells[referenceCellKey(entry.Sheet, entry.Cell)]
+		for _, keyword := range keywords {
+			if matched {
+				break
+			}
+			matched = strings.Contains(haystack, keyword)
+		}
+		if matched {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// readableWorkspaceScope 返回用户可查看的范围，与工作空间树一致：可查看的团队，以及分享给用户的文件、文件夹及其下全部内容
+func readableWorkspaceScope(ctx context.Context, userId string) (roles map[string]string, teamIds []string, sharedIds []string, err error) {
+	roles, err = userTeamRoles(ctx, userId)
+	if err != nil {
+		return nil, nil, nil, errors.New("获取用户团队信息失败")
+	}
+	grants, err := userGrants(ctx, userId, teamIdsOf(roles), nil)
+	if err != nil {
+		return nil, nil, nil, errors.New("获取用户授权信息失败")
+	}
+	teamIds = readableTeamIds(roles)
+	sharedIds, err = sharedWorkspaceIds(ctx, maxGrantLevels(grants), teamIds)
+	if err != nil {
+		return nil, nil, nil, errors.New("查询分享的文件失败")
+	}
+	return roles, teamIds, sharedIds, nil
+}
+
+type SugarWorkbookSearchService struct{}
+
+// SearchWorkbooks 在用户可查看的工作簿中按关键词、引用的语义模型或智能体搜索
+// 关键词中的每个词需出现在文件名，或通过索引出现在工作表名、单元格文本、公式中；英文按词前缀匹配
+func (s *SugarWorkbookSearchService) SearchWorkbooks(ctx context.Context, req sugarReq.SugarWorkbookSearchRequest, userId string) ([]sugarRes.SugarWorkbookSearchItem, int64, error) {
+	keywords := strings.Fields(strings.ToLower(req.Keyword))
+	modelName, agentName := strings.TrimSpace(req.ModelName), strings.TrimSpace(req.AgentName)
+	if len(keywords) == 0 && modelName == "" && agentName == "" {
+		return nil, 0, errors.New("请输入关键词，或选择要查找引用的模型或智能体")
+	}
+	if len(keywords) > searchMaxKeywords {
+		return nil, 0, fmt.Errorf("关键词不能超过 %d 个", searchMaxKeywords)
+	}
+
+	roles, teamIds, sharedIds, err := readableWorkspaceScope(ctx, userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).Where("type = ? AND deleted_at IS NULL", "file")
+	if req.TeamId != "" {
+		db = db.Where("team_id = ?", req.TeamId)
+		if !SugarRoleAllows(roles[req.TeamId], SugarResourceWorkspace, SugarActionRead) {
+			teamIds = []string{}
+		} else {
+			teamIds = []string{req.TeamId}
+		}
+	}
+	switch {
+	case len(teamIds) == 0 && len(sharedIds) == 0:
+		return []sugarRes.SugarWorkbookSearchItem{}, 0, nil
+	case len(sharedIds) == 0:
+		db = db.Where("team_id IN ?", teamIds)
+	case len(teamIds) == 0:
+		db = db.Where("id IN ?", sharedIds)
+	default:
+		db = db.Where("team_id IN ? OR id IN ?", teamIds, sharedIds)
+	}
+
+	termQuery := func(term string, prefix bool) *gorm.DB {
+		query := global.GVA_DB.Model(&sugar.SugarWorkbookSearchTerms{}).Select("file_id").Where("kind = ?", sugar.WorkbookSearchTermText)
+		if prefix {
+			return query.Where("term LIKE ?", term+"%")
+		}
+		return query.Where("term = ?", term)
+	}
+	for _, keyword := range keywords {
+		condition := global.GVA_DB.Where("name LIKE ?", "%"+keyword+"%")
+		terms := searchTerms(keyword, false)
+		if len(terms) > 0 {
+			indexed := global.GVA_DB
+			for _, term := range terms {
+				runes := []rune(term)
+				indexed = indexed.Where("id IN (?)", termQuery(term, !isIdeograph(runes[0])))
+			}
+			condition = condition.Or(indexed)
+		}
+		db = db.Where(condition)
+	}
+
+	// 按引用查找使用血缘关系中的引用，与影响分析的结果一致，包括嵌套在其他函数中的调用
+	targets := map[string]string{}
+	if modelName != "" {
+		targets[sugar.WorkbookReferenceModel] = strings.ToLower(truncateRunes(modelName, 100))
+	}
+	if agentName != "" {
+		targets[sugar.WorkbookReferenceAgent] = strings.ToLower(truncateRunes(agentName, 100))
+	}
+	referenceQuery := func(kind, nameKey string) *gorm.DB {
+		return global.GVA_DB.Model(&sugar.SugarWorkbookReferences{}).Where("kind = ? AND name_key = ?", kind, nameKey)
+	}
+	for kind, nameKey := range targets {
+		db = db.Where("id IN (?)", referenceQuery(kind, nameKey).Select("file_id"))
+	}
+
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if req.PageSize > 0 {
+		db = db.Limit(req.PageSize).Offset(req.PageSize * (req.Page - 1))
+	}
+	var files []sugar.SugarWorkspaces
+	if err = db.Omit("content").Order("updated_at DESC").Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(files) == 0 {
+		return []sugarRes.SugarWorkbookSearchItem{}, total, nil
+	}
+
+	fileIds := make([]string, 0, len(files))
+	resultTeamIds := make([]string, 0, len(files))
+	for _, file := range files {
+		fileIds = append(fileIds, *file.Id)
+		resultTeamIds = append(resultTeamIds, *file.TeamId)
+	}
+	var documents []sugar.SugarWorkbookSearchDocuments
+	if err = global.GVA_DB.WithContext(ctx).Where("file_id IN ?", fileIds).Find(&documents).Error; err != nil {
+		return nil, 0, err
+	}
+	entriesByFile := make(map[string][]sugarRes.SugarWorkbookSearchMatch, len(documents))
+	for _, document := range documents {
+		var entries []sugarRes.SugarWorkbookSearchMatch
+		if err := json.Unmarshal(document.Entries, &entries); err != nil {
+			global.GVA_LOG.Warn("解析搜索索引失败", zap.String("fileId", *document.FileId), zap.Error(err))
+			continue
+		}
+		entriesByFile[*document.FileId] = entries
+	}
+	referencedCells := make(map[string]map[string]bool, len(files))
+	for kind, nameKey := range targets {
+		var references []sugar.SugarWorkbookReferences
+		if err = referenceQuery(kind, nameKey).WithContext(ctx).Select("file_id", "sheet", "cell").Where("file_id IN ?", fileIds).Find(&references).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, reference := range references {
+			if referencedCells[*reference.FileId] == nil {
+				referencedCells[*reference.FileId] = map[string]bool{}
+			}
+			referencedCells[*reference.FileId][referenceCellKey(reference.Sheet, reference.Cell)] = true
+		}
+	}
+	teamNames := lookupTeamNames(ctx, resultTeamIds)
+
+	list := make([]sugarRes.SugarWorkbookSearchItem, 0, len(files))
+	for _, file := range files {
+		matches := matchSearchEntries(entriesByFile[*file.Id], keywords, referencedCells[*file.Id])
+		item := sugarRes.SugarWorkbookSearchItem{
+			Id:         *file.Id,
+			TeamId:     *file.TeamId,
+			TeamName:   teamNames[*file.TeamId],
+			ParentId:   file.ParentId,
+			UpdatedAt:  file.UpdatedAt,
+			Matches:    matches,
+			MatchCount: len(matches),
+		}
+		if file.Name != nil {
+			item.Name = *file.Name
+		}
+		if len(matches) > searchMaxMatches {
+			item.Matches = matches[:searchMaxMatches]
+		}
+		list = append(list, item)
+	}
+	return list, total, nil
+}
+
+// RebuildSearchIndex 重建团队全部工作簿的搜索索引和引用关系，用于索引功能上线前已有的工作簿，需要团队管理权限
+func (s *SugarWorkbookSearchService) RebuildSearchIndex(ctx context.Context, teamId string, userId string) (int, error) {
+	if err := authorizeTeamAction(ctx, teamId, userId, SugarResourceWorkspace, SugarActionManage); err != nil {
+		return 0, err
+	}
+	indexed := 0
+	lastId := ""
+	for {
+		var files []sugar.SugarWorkspaces
+		err := global.GVA_DB.WithContext(ctx).Where("team_id = ? AND type = ? AND deleted_at IS NULL AND id > ?", teamId, "file", lastId).
+			Order("id ASC").Limit(searchRebuildBatch).Find(&files).Error
+		if err != nil {
+			return indexed, err
+		}
+		for _, file := range files {
+			err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return indexWorkbookContent(tx, *file.Id, file.Content, file.Revision)
+			})
+			if err != nil {
+				global.GVA_LOG.Error("重建搜索索引失败", zap.String("fileId", *file.Id), zap.Error(err))
+				return indexed, errors.New("重建搜索索引失败")
+			}
+			indexed++
+		}
+		if len(files) < searchRebuildBatch {
+			break
+		}
+		lastId = *files[len(files)-1].Id
+	}
+	global.GVA_LOG.Info("重建团队搜索索引", zap.String("teamId", teamId), zap.Int("count", indexed), zap.String("by", userId))
+	return indexed, nil
+}
@@ -0,0 +1,165 @@
+package sugar
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+func TestSearchTerms(t *testing.T) {
+	if got := searchTerms("Revenue 2024，销售额", true); !reflect.DeepEqual(got, []string{"revenue", "2024", "销", "售", "额", "销售", "售额"}) {
+		t.Fatalf("建立索引时的切词结果不符合预期: %v", got)
+	}
+	// 查询时连续的中文只取相邻两字，单个字保留
+	if got := searchTerms("销售额 利", false); !reflect.DeepEqual(got, []string{"销售", "售额", "利"}) {
+		t.Fatalf("查询时的切词结果不符合预期: %v", got)
+	}
+}
+
+func TestExtractWorkbookSearchContent(t *testing.T) {
+	content := workbookJSON(`"0": {
+		"0": {"v": "华东区"},
+		"1": {"f": "=SUGAR.GET(\"Sales Model\", \"金额\")", "v": 100},
+		"2": {"f": "=AI.FETCH(\"分析助手\", A1)", "v": "ok"}
+	}, "2": {"0": {"p": {"body": {"dataStream": "备注\r\n"}}}}`)
+	workbook, err := decodeUniverWorkbook([]byte(content))
+	if err != nil {
+		t.Fatalf("解析工作簿失败: %v", err)
+	}
+	entries, terms := extractWorkbookSearchContent(workbook)
+	want := []sugarRes.SugarWorkbookSearchMatch{
+		{Sheet: "Sheet1"},
+		{Sheet: "Sheet1", Cell: "A1", Text: "华东区"},
+		{Sheet: "Sheet1", Cell: "B1", Text: "100", Formula: `=SUGAR.GET("Sales Model", "金额")`},
+		{Sheet: "Sheet1", Cell: "C1", Text: "ok", Formula: `=AI.FETCH("分析助手", A1)`},
+		{Sheet: "Sheet1", Cell: "A3", Text: "备注"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("提取的条目不符合预期: %+v", entries)
+	}
+	for _, term := range []string{"sheet1", "华东", "sugar", "金额", "备注", "分析"} {
+		if !terms[term] {
+			t.Fatalf("文本索引缺少 %q", term)
+		}
+	}
+}
+
+func searchWorkbooks(t *testing.T, req sugarReq.SugarWorkbookSearchRequest, userId string) []sugarRes.SugarWorkbookSearchItem {
+	t.Helper()
+	req.PageInfo = request.PageInfo{Page: 1, PageSize: 10}
+	list, total, err := (&SugarWorkbookSearchService{}).SearchWorkbooks(context.Background(), req, userId)
+	if err != nil {
+		t.Fatalf("搜索失败: %v", err)
+	}
+	if int(total) != len(list) {
+		t.Fatalf("总数 %d 与结果数 %d 不一致", total, len(list))
+	}
+	return list
+}
+
+func TestSearchWorkbooksAfterSave(t *testing.T) {
+	setupSharedFolder(t)
+	saveWorkbook(t, "1", `"0": {
+		"0": {"v": "Quarterly revenue"},
+		"1": {"v": "华东区销售额"},
+		"2": {"f": "=IFERROR(sugar.calc(\"Sales\", \"金额\", \"SUM\"), 0)", "v": 10},
+		"3": {"f": "=AI.FETCH(\"Analyst\", A1)", "v": "ok"}
+	}`)
+
+	// 英文按词前缀匹配，中文按相邻两字匹配，并返回命中位置
+	list := searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "Quarter"}, "1")
+	if len(list) != 1 || list[0].Id != testFileId || list[0].TeamName != "财务部" || list[0].Matches[0].Cell != "A1" {
+		t.Fatalf("应按英文前缀找到工作簿: %+v", list)
+	}
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "销售额"}, "1"); len(list) != 1 || list[0].Matches[0].Cell != "B1" {
+		t.Fatalf("应按中文找到工作簿: %+v", list)
+	}
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "华西"}, "1"); len(list) != 0 {
+		t.Fatalf("不含关键词时不应命中: %+v", list)
+	}
+	// 文件名也参与匹配
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "预算"}, "1"); len(list) != 1 || list[0].MatchCount != 0 {
+		t.Fatalf("应按文件名找到工作簿: %+v", list)
+	}
+
+	// 按引用的模型和智能体查找，名称和函数名不区分大小写，包括嵌套在其他函数中的调用
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{ModelName: "sales"}, "1"); len(list) != 1 || list[0].Matches[0].Cell != "C1" {
+		t.Fatalf("应找到引用了模型的工作簿: %+v", list)
+	}
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{ModelName: "Sale"}, "1"); len(list) != 0 {
+		t.Fatalf("模型名称应完全匹配: %+v", list)
+	}
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{AgentName: "Analyst"}, "1"); len(list) != 1 || list[0].Matches[0].Cell != "D1" {
+		t.Fatalf("应找到引用了智能体的工作簿: %+v", list)
+	}
+
+	// 保存后索引随之更新
+	saveWorkbook(t, "1", `"0": {"0": {"v": "华西区"}}`)
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "quarterly"}, "1"); len(list) != 0 {
+		t.Fatalf("保存后旧内容不应再命中: %+v", list)
+	}
+	if list = searchWorkbooks(t, sugarReq.SugarWorkbookSearchRequest{Keyword: "华西"}, "1"); len(list) != 1 {
+		t.Fatalf("保存后应能搜到新内容: %+v", list)
+	}
+
+	if _, _, err := (&SugarWorkbookSearchService{}).SearchWorkbooks(context.Background(), sugarReq.SugarWorkbookSearchRequest{}, "1"); err == nil {
+		t.Fatal("没有搜索条件时应返回错误")
+	}
+}
+
+func TestSearchWorkbooksPermissions(t *testing.T) {
+	setupSharedFolder(t)
+	saveWorkbook(t, "1", `"0": {"0": {"v": "机密数据"}}`)
+	query := sugarReq.SugarWorkbookSearchRequest{Keyword: "机密"}
+
+	// 团队外的用户搜不到，授权后可以搜到
+	if list := searchWorkbooks(t, query, "3"); len(list) != 0 {
+		t.Fatalf("未授权的用户不应搜到工作簿: %+v", list)
+	}
+	grantWorkspace(t, "folder-1", sugar.WorkspaceGranteeUser, "3", sugar.WorkspacePermissionViewer)
+	if list := searchWorkbooks(t, query, "3"); len(list) != 1 {
+		t.Fatalf("授权后应能搜到工作簿: %+v", list)
+	}
+
+	// 按团队筛选
+	query.TeamId = "team-2"
+	if list := searchWorkbooks(t, query, "12"); len(list) != 0 {
+		t.Fatalf("按团队筛选时不应返回其他团队的工作簿: %+v", list)
+	}
+	query.TeamId = "team-1"
+	if list := searchWorkbooks(t, query, "12"); len(list) != 1 {
+		t.Fatalf("查看者应能搜到团队的工作簿: %+v", list)
+	}
+
+	// 回收站中的工作簿不出现在结果中
+	deleteItem(t, testFileId, "1")
+	if list := searchWorkbooks(t, query, "1"); len(list) != 0 {
+		t.Fatalf("已删除的工作簿不应出现在搜索结果中: %+v", list)
+	}
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	service := &SugarWorkbookSearchService{}
+
+	// 测试数据直接写入数据库，尚未建立索引
+	if countWhere(t, &sugar.SugarWorkbookSearchDocuments{}, "file_id = ?", testFileId) != 0 {
+		t.Fatal("初始数据不应有索引")
+	}
+	_, err := service.RebuildSearchIndex(ctx, "team-1", "1")
+	expectPermissionDenied(t, err, SugarTeamRoleEditor)
+
+	indexed, err := service.RebuildSearchIndex(ctx, "team-1", "11")
+	if err != nil || indexed != 1 {
+		t.Fatalf("管理员应能重建索引: %d %v", indexed, err)
+	}
+	if countWhere(t, &sugar.SugarWorkbookSearchDocuments{}, "file_id = ?", testFileId) != 1 {
+		t.Fatal("重建后应有索引")
+	}
+}
@@ -0,0 +1,291 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// templatePlaceholderPattern 匹配 SUGAR 公式参数中的 {{name}} 占位符，如 SUGAR.GET("销售", "金额", "月份={{period}}")
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([\p{L}_][\p{L}\p{N}_]*)\s*\}\}`)
+
+// templatePlaceholders 返回工作簿 SUGAR 公式中出现的占位符名称，按名称排序
+func templatePlaceholders(content datatypes.JSON) ([]string, error) {
+	var workbook map[string]interface{}
+	if err := json.Unmarshal(content, &workbook); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	names := make([]string, 0)
+	for _, cell := range workbookCells(workbook) {
+		formula, ok := cell["f"].(string)
+		if !ok || !sugarFormulaPattern.MatchString(formula) {
+			continue
+		}
+		for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(formula, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolveTemplateParameters 合并用户声明的参数与公式中的占位符：声明的参数在前，未声明的占位符以名称作为显示名称
+func resolveTemplateParameters(placeholders []string, declared []sugar.SugarTemplateParameter) ([]sugar.SugarTemplateParameter, error) {
+	used := map[string]bool{}
+	for _, name := range placeholders {
+		used[name] = true
+	}
+	declaredNames := map[string]bool{}
+	parameters := make([]sugar.SugarTemplateParameter, 0, len(placeholders))
+	for _, parameter := range declared {
+		if !used[parameter.Name] {
+			return nil, fmt.Errorf("参数 %s 未在 SUGAR 公式中使用，请在公式参数中写入 {{%s}}", parameter.Name, parameter.Name)
+		}
+		if declaredNames[parameter.Name] {
+			return nil, fmt.Errorf("参数 %s 重复声明", parameter.Name)
+		}
+		declaredNames[parameter.Name] = true
+		if parameter.Label == "" {
+			parameter.Label = parameter.Name
+		}
+		parameters = append(parameters, parameter)
+	}
+	for _, name := range placeholders {
+		if !declaredNames[name] {
+			parameters = append(parameters, sugar.SugarTemplateParameter{Name: name, Label: name})
+		}
+	}
+	return parameters, nil
+}
+
+// applyTemplateParameters 将参数取值代入 SUGAR 公式中的占位符，未提供的参数使用默认值
+// 取值作为公式字符串参数的一部分，其中的双引号按公式语法转义；代入后清除单元格中模板的旧计算结果
+func applyTemplateParameters(content datatypes.JSON, parameters []sugar.SugarTemplateParameter, values map[string]string) (datatypes.JSON, error) {
+	resolved := make(map[string]string, len(parameters))
+	for _, parameter := range parameters {
+		value := values[parameter.Name]
+		if value == "" {
+			value = parameter.DefaultValue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("缺少参数 %s", parameter.Label)
+		}
+		resolved[parameter.Name] = strings.ReplaceAll(value, `"`, `""`)
+	}
+	for name := range values {
+		if _, ok := resolved[name]; !ok {
+			return nil, fmt.Errorf("模板没有参数 %s", name)
+		}
+	}
+
+	var workbook map[string]interface{}
+	if err := json.Unmarshal(content, &workbook); err != nil {
+		return nil, err
+	}
+	for _, cell := range workbookCells(workbook) {
+		formula, ok := cell["f"].(string)
+		if !ok || !sugarFormulaPattern.MatchString(formula) {
+			continue
+		}
+		substituted := templatePlaceholderPattern.ReplaceAllStringFunc(formula, func(placeholder string) string {
+			name := templatePlaceholderPattern.FindStringSubmatch(placeholder)[1]
+			if value, ok := resolved[name]; ok {
+				return value
+			}
+			return placeholder
+		})
+		if substituted != formula {
+			cell["f"] = substituted
+			delete(cell, "v")
+		}
+	}
+	return json.Marshal(workbook)
+}
+
+type SugarWorkbookTemplatesService struct{}
+
+// findTemplate 查询模板
+func (s *SugarWorkbookTemplatesService) findTemplate(ctx context.Context, id int64) (*sugar.SugarWorkbookTemplates, error) {
+	var template sugar.SugarWorkbookTemplates
+	err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("模板不存在")
+		}
+		return nil, errors.New("查询模板失败")
+	}
+	return &template, nil
+}
+
+// CreateTemplate 以工作簿当前内容生成团队模板，需要能查看该工作簿并在其所在团队编辑文件
+// 工作簿 SUGAR 公式参数中的 {{name}} 占位符成为模板参数，实例化时替换为填写的值
+func (s *SugarWorkbookTemplatesService) CreateTemplate(ctx context.Context, req sugarReq.SugarWorkbookTemplateCreateRequest, userId string) (*sugar.SugarWorkbookTemplates, error) {
+	var file sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND type = ? AND deleted_at IS NULL", req.FileId, "file").First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	if err = authorizeWorkspaceItem(ctx, &file, userId, SugarActionRead); err != nil {
+		return nil, err
+	}
+	if err = authorizeTeamAction(ctx, *file.TeamId, userId, SugarResourceWorkspace, SugarActionEdit); err != nil {
+		return nil, err
+	}
+	if len(file.Content) == 0 {
+		return nil, errors.New("工作簿内容为空，无法生成模板")
+	}
+
+	placeholders, err := templatePlaceholders(file.Content)
+	if err != nil {
+		return nil, errors.New("工作簿内容格式错误")
+	}
+	parameters, err := resolveTemplateParameters(placeholders, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	parametersJSON, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var existCount int64
+	err = global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkbookTemplates{}).Where("team_id = ? AND name = ?", *file.TeamId, req.Name).Count(&existCount).Error
+	if err != nil {
+		return nil, errors.New("检查名称重复失败")
+	}
+	if existCount > 0 {
+		return nil, errors.New("模板库中已存在同名模板")
+	}
+
+	now := time.Now()
+	template := sugar.SugarWorkbookTemplates{
+		TeamId:       file.TeamId,
+		Name:         req.Name,
+		Description:  req.Description,
+		SourceFileId: file.Id,
+		Content:      file.Content,
+		Parameters:   parametersJSON,
+		CreatedBy:    &userId,
+		CreatedAt:    &now,
+		UpdatedBy:    &userId,
+		UpdatedAt:    &now,
+	}
+	if err = global.GVA_DB.WithContext(ctx).Create(&template).Error; err != nil {
+		global.GVA_LOG.Error("创建模板失败", zap.Error(err))
+		return nil, errors.New("创建模板失败")
+	}
+
+	global.GVA_LOG.Info("创建工作簿模板", zap.Int64("id", template.Id), zap.String("fileId", req.FileId), zap.Int("parameters", len(parameters)), zap.String("by", userId))
+	return &template, nil
+}
+
+// GetTemplateList 获取团队模板库
+func (s *SugarWorkbookTemplatesService) GetTemplateList(ctx context.Context, teamId string, userId string) ([]sugarRes.SugarWorkbookTemplateItem, error) {
+	if err := authorizeTeamAction(ctx, teamId, userId, SugarResourceWorkspace, SugarActionRead); err != nil {
+		return nil, err
+	}
+
+	var templates []sugar.SugarWorkbookTemplates
+	err := global.GVA_DB.WithContext(ctx).Omit("content").Where("team_id = ?", teamId).Order("created_at DESC").Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	creatorIds := make([]string, 0, len(templates))
+	for _, template := range templates {
+		if template.CreatedBy != nil {
+			creatorIds = append(creatorIds, *template.CreatedBy)
+		}
+	}
+	creatorNames := lookupUserNames(ctx, creatorIds)
+
+	list := make([]sugarRes.SugarWorkbookTemplateItem, 0, len(templates))
+	for _, template := range templates {
+		item := sugarRes.SugarWorkbookTemplateItem{
+			Id:           template.Id,
+			TeamId:       teamId,
+			Name:         template.Name,
+			Description:  template.Description,
+			SourceFileId: template.SourceFileId,
+			Parameters:   []sugar.SugarTemplateParameter{},
+			CreatedBy:    template.CreatedBy,
+			CreatedAt:    template.CreatedAt,
+		}
+		if len(template.Parameters) > 0 {
+			if err = json.Unmarshal(template.Parameters, &item.Parameters); err != nil {
+				global.GVA_LOG.Warn("解析模板参数失败", zap.Int64("id", template.Id), zap.Error(err))
+			}
+		}
+		if template.CreatedBy != nil {
+			item.CreatorName = creatorNames[*template.CreatedBy]
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// DeleteTemplate 从模板库删除模板，已由模板创建的工作簿不受影响
+func (s *SugarWorkbookTemplatesService) DeleteTemplate(ctx context.Context, id int64, userId string) error {
+	template, err := s.findTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err = authorizeTeamAction(ctx, *template.TeamId, userId, SugarResourceWorkspace, SugarActionDelete); err != nil {
+		return err
+	}
+	if err = global.GVA_DB.WithContext(ctx).Delete(&sugar.SugarWorkbookTemplates{}, "id = ?", id).Error; err != nil {
+		global.GVA_LOG.Error("删除模板失败", zap.Int64("id", id), zap.Error(err))
+		return errors.New("删除模板失败")
+	}
+	return nil
+}
+
+// InstantiateTemplate 由模板新建工作簿，参数取值代入 SUGAR 公式
+// 需要能查看模板所属团队，并在目标位置有创建权限；目标可以是其他团队
+func (s *SugarWorkbookTemplatesService) InstantiateTemplate(ctx context.Context, req sugarReq.SugarWorkbookTemplateInstantiateRequest, userId string) (*sugar.SugarWorkspaces, error) {
+	template, err := s.findTemplate(ctx, req.TemplateId)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeTeamAction(ctx, *template.TeamId, userId, SugarResourceWorkspace, SugarActionRead); err != nil {
+		return nil, err
+	}
+
+	var parameters []sugar.SugarTemplateParameter
+	if len(template.Parameters) > 0 {
+		if err = json.Unmarshal(template.Parameters, &parameters); err != nil {
+			return nil, errors.New("模板参数格式错误")
+		}
+	}
+	content, err := applyTemplateParameters(template.Content, parameters, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := (&SugarWorkspacesService{}).CreateWorkbookFile(ctx, req.Name, req.ParentId, req.TeamId, userId, content)
+	if err != nil {
+		return nil, err
+	}
+	global.GVA_LOG.Info("由模板创建工作簿", zap.Int64("templateId", req.TemplateId), zap.String("id", *workspace.Id), zap.String("by", userId))
+	return workspace, nil
+}
@@ -0,0 +1,103 @@
+package sugar
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	"gorm.io/datatypes"
+)
+
+func TestApplyTemplateParameters(t *testing.T) {
+	content := datatypes.JSON(workbookJSON(`"0": {
+		"0": {"f": "=SUGAR.GET(\"销售\", \"金额\", \"月份={{ period }}\", \"区域={{region}}\")", "v": 100},
+		"1": {"f": "=SUGAR.CALC(\"销售\", \"金额\", \"SUM\", \"月份={{period}}\")", "v": 200},
+		"2": {"f": "=CONCAT(\"{{period}}\")", "v": "{{period}}"}
+	}`))
+	placeholders, err := templatePlaceholders(content)
+	if err != nil || strings.Join(placeholders, ",") != "period,region" {
+		t.Fatalf("应只识别 SUGAR 公式中的占位符: %v %v", placeholders, err)
+	}
+	parameters, err := resolveTemplateParameters(placeholders, []sugar.SugarTemplateParameter{{Name: "region", Label: "区域", DefaultValue: "华东"}})
+	if err != nil || len(parameters) != 2 || parameters[0].Name != "region" || parameters[1].Label != "period" {
+		t.Fatalf("参数合并结果不符合预期: %+v %v", parameters, err)
+	}
+	if _, err = resolveTemplateParameters(placeholders, []sugar.SugarTemplateParameter{{Name: "city"}}); err == nil {
+		t.Fatal("不应声明公式中没有使用的参数")
+	}
+
+	if _, err = applyTemplateParameters(content, parameters, nil); err == nil || !strings.Contains(err.Error(), "period") {
+		t.Fatalf("缺少没有默认值的参数时应报错: %v", err)
+	}
+	if _, err = applyTemplateParameters(content, parameters, map[string]string{"period": "2024-06", "city": "上海"}); err == nil {
+		t.Fatal("提供模板没有的参数时应报错")
+	}
+	applied, err := applyTemplateParameters(content, parameters, map[string]string{"period": `2024"06`})
+	if err != nil {
+		t.Fatalf("代入参数失败: %v", err)
+	}
+	cells := univerCellMatrix(mustSheet(t, applied)["cellData"])
+	if cell := cells[univerCellPosition{row: 0, col: 0}]; cell["f"] != `=SUGAR.GET("销售", "金额", "月份=2024""06", "区域=华东")` || cell["v"] != nil {
+		t.Fatalf("SUGAR.GET 参数代入结果不符合预期: %v", cell)
+	}
+	if cell := cells[univerCellPosition{row: 0, col: 2}]; cell["f"] != `=CONCAT("{{period}}")` || cell["v"] != "{{period}}" {
+		t.Fatalf("普通公式不应被替换: %v", cell)
+	}
+}
+
+func TestWorkbookTemplateInstantiate(t *testing.T) {
+	setupTeamWorkbook(t)
+	ctx := context.Background()
+	service := &SugarWorkbookTemplatesService{}
+	saveWorkbook(t, "1", `"0": {"0": {"f": "=SUGAR.GET(\"销售\", \"金额\", \"月份={{period}}\")", "v": 1}}`)
+
+	// 查看者不能生成模板
+	create := sugarReq.SugarWorkbookTemplateCreateRequest{FileId: testFileId, Name: "月报", Parameters: []sugar.SugarTemplateParameter{{Name: "period", Label: "月份"}}}
+	_, err := service.CreateTemplate(ctx, create, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	template, err := service.CreateTemplate(ctx, create, "1")
+	if err != nil {
+		t.Fatalf("生成模板失败: %v", err)
+	}
+	if _, err = service.CreateTemplate(ctx, create, "1"); err == nil {
+		t.Fatal("同一团队不应有同名模板")
+	}
+
+	// 模板是生成时的快照，之后修改源工作簿不影响模板
+	saveWorkbook(t, "1", `"0": {"0": {"v": "已修改"}}`)
+	list, err := service.GetTemplateList(ctx, "team-1", "12")
+	if err != nil || len(list) != 1 || list[0].CreatorName != "Alice" || len(list[0].Parameters) != 1 || list[0].Parameters[0].Label != "月份" {
+		t.Fatalf("模板库不符合预期: %+v %v", list, err)
+	}
+
+	// 团队 1 的查看者可以把模板实例化到自己能编辑的团队 2
+	_, err = service.InstantiateTemplate(ctx, sugarReq.SugarWorkbookTemplateInstantiateRequest{TemplateId: template.Id, Name: "6月月报", TeamId: "team-2"}, "12")
+	if err == nil || !strings.Contains(err.Error(), "月份") {
+		t.Fatalf("缺少参数时应报错: %v", err)
+	}
+	workspace, err := service.InstantiateTemplate(ctx, sugarReq.SugarWorkbookTemplateInstantiateRequest{
+		TemplateId: template.Id, Name: "6月月报", TeamId: "team-2", Parameters: map[string]string{"period": "2024-06"},
+	}, "12")
+	if err != nil || *workspace.TeamId != "team-2" {
+		t.Fatalf("由模板创建工作簿失败: %+v %v", workspace, err)
+	}
+	var created sugar.SugarWorkspaces
+	global.GVA_DB.Where("id = ?", *workspace.Id).First(&created)
+	cell := univerCellMatrix(mustSheet(t, created.Content)["cellData"])[univerCellPosition{row: 0, col: 0}]
+	if cell["f"] != `=SUGAR.GET("销售", "金额", "月份=2024-06")` {
+		t.Fatalf("参数应代入 SUGAR 公式: %v", cell)
+	}
+	_, err = service.InstantiateTemplate(ctx, sugarReq.SugarWorkbookTemplateInstantiateRequest{
+		TemplateId: template.Id, Name: "团队1月报", TeamId: "team-1", Parameters: map[string]string{"period": "2024-06"},
+	}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+
+	// 查看者不能删除模板
+	expectPermissionDenied(t, service.DeleteTemplate(ctx, template.Id, "12"), SugarTeamRoleViewer)
+	if err = service.DeleteTemplate(ctx, template.Id, "1"); err != nil {
+		t.Fatalf("删除模板失败: %v", err)
+	}
+}
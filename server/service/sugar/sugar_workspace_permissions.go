@@ -0,0 +1,607 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+	systemModel "github.com/flipped-aurora/gin-vue-admin/server/model/system"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// workspaceMaxDepth 查找上级文件夹的最大层数，防止数据异常形成环时无限循环
+const workspaceMaxDepth = 64
+
+// sugarGrantLevelRank 授权级别等级
+var sugarGrantLevelRank = map[string]int{
+	sugar.WorkspacePermissionViewer: 1,
+	sugar.WorkspacePermissionEditor: 2,
+}
+
+var sugarGrantLevelLabels = map[string]string{
+	sugar.WorkspacePermissionEditor: "编辑",
+	sugar.WorkspacePermissionViewer: "查看",
+}
+
+// MigrateCommenterGrants 将旧版本的评论授权迁移为查看授权，返回迁移的授权数
+// 评论授权没有对应的评论功能，实际只能查看，迁移后权限判断和授权级别校验不再需要兼容该级别
+func MigrateCommenterGrants(db *gorm.DB) (int64, error) {
+	result := db.Model(&sugar.SugarWorkspacePermissions{}).
+		Where("permission_level = ?", sugar.WorkspacePermissionLegacyCommenter).
+		Update("permission_level", sugar.WorkspacePermissionViewer)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		global.GVA_LOG.Warn("评论授权已迁移为查看授权", zap.Int64("count", result.RowsAffected))
+	}
+	return result.RowsAffected, nil
+}
+
+// grantLevelAllows 判断授权级别能否执行操作
+// 分享、删除和管理只能由团队角色获得：被授权的外部协作者不能继续扩大访问范围，也不能删除或转移团队的文件
+func grantLevelAllows(level string, action SugarAction) bool {
+	switch action {
+	case SugarActionRead:
+		return sugarGrantLevelRank[level] > 0
+	case SugarActionEdit:
+		return level == sugar.WorkspacePermissionEditor
+	}
+	return false
+}
+
+// workspaceAccess 用户对文件或文件夹的有效权限，团队角色与授权取并集
+type workspaceAccess struct {
+	role      string // 用户在文件所属团队的角色，非成员为空
+	level     string // 文件及上级文件夹上授予用户或其所在团队的最高权限级别
+	grantedOn string // level 来自哪个文件或文件夹的授权
+}
+
+func (a workspaceAccess) allows(action SugarAction) bool {
+	return SugarRoleAllows(a.role, SugarResourceWorkspace, action) || grantLevelAllows(a.level, action)
+}
+
+func (a workspaceAccess) actions() []string {
+	actions := make([]string, 0, len(SugarActions))
+	for _, action := range SugarActions {
+		if a.allows(action) {
+			actions = append(actions, string(action))
+		}
+	}
+	return actions
+}
+
+// userTeamRoles 获取用户所在的全部团队及角色
+func userTeamRoles(ctx context.Context, userId string) (map[string]string, error) {
+	var members []sugar.SugarTeamMembers
+	if err := global.GVA_DB.WithContext(ctx).Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[string]string, len(members))
+	for _, member := range members {
+		if member.TeamId != nil && sugarRoleRank[member.Role] > sugarRoleRank[roles[*member.TeamId]] {
+			roles[*member.TeamId] = member.Role
+		}
+	}
+	return roles, nil
+}
+
+// workspaceAncestors 返回文件自身及全部上级文件夹的ID，由近及远
+func workspaceAncestors(ctx context.Context, workspace *sugar.SugarWorkspaces) ([]string, error) {
+	ids := []string{*workspace.Id}
+	visited := map[string]bool{*workspace.Id: true}
+	parentId := workspace.ParentId
+	for parentId != nil && *parentId != "" && !visited[*parentId] && len(ids) < workspaceMaxDepth {
+		var parent sugar.SugarWorkspaces
+		err := global.GVA_DB.WithContext(ctx).Select("id", "parent_id").Where("id = ?", *parentId).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, *parent.Id)
+		visited[*parent.Id] = true
+		parentId = parent.ParentId
+	}
+	return ids, nil
+}
+
+// userGrants 查询授予用户本人或其所在团队的授权，workspaceIds 为 nil 时查询全部
+func userGrants(ctx context.Context, userId string, teamIds []string, workspaceIds []string) ([]sugar.SugarWorkspacePermissions, error) {
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspacePermissions{})
+	if len(teamIds) > 0 {
+		db = db.Where("(grantee_type = ? AND grantee_id = ?) OR (grantee_type = ? AND grantee_id IN ?)",
+			sugar.WorkspaceGranteeUser, userId, sugar.WorkspaceGranteeTeam, teamIds)
+	} else {
+		db = db.Where("grantee_type = ? AND grantee_id = ?", sugar.WorkspaceGranteeUser, userId)
+	}
+	if workspaceIds != nil {
+		db = db.Where("workspace_id IN ?", workspaceIds)
+	}
+	var grants []sugar.SugarWorkspacePermissions
+	err := db.Find(&grants).Error
+	return grants, err
+}
+
+// maxGrantLevels 按文件或文件夹汇总授权，取最高级别
+func maxGrantLevels(grants []sugar.SugarWorkspacePermissions) map[string]string {
+	levels := make(map[string]string, len(grants))
+	for _, grant := range grants {
+		if grant.WorkspaceId != nil && sugarGrantLevelRank[grant.PermissionLevel] > sugarGrantLevelRank[levels[*grant.WorkspaceId]] {
+			levels[*grant.WorkspaceId] = grant.PermissionLevel
+		}
+	}
+	return levels
+}
+
+func teamIdsOf(roles map[string]string) []string {
+	teamIds := make([]string, 0, len(roles))
+	for teamId := range roles {
+		teamIds = append(teamIds, teamId)
+	}
+	return teamIds
+}
+
+// resolveWorkspaceAccess 计算用户对文件或文件夹的有效权限：所属团队的角色，加上自身及上级文件夹上的授权
+func resolveWorkspaceAccess(ctx context.Context, workspace *sugar.SugarWorkspaces, userId string) (workspaceAccess, error) {
+	var access workspaceAccess
+	roles, err := userTeamRoles(ctx, userId)
+	if err != nil {
+		return access, err
+	}
+	if workspace.TeamId != nil {
+		access.role = roles[*workspace.TeamId]
+	}
+	// 团队角色已能编辑时，授权不会带来更多权限
+	if SugarRoleAllows(access.role, SugarResourceWorkspace, SugarActionEdit) {
+		return access, nil
+	}
+
+	ancestors, err := workspaceAncestors(ctx, workspace)
+	if err != nil {
+		return access, err
+	}
+	grants, err := userGrants(ctx, userId, teamIdsOf(roles), ancestors)
+	if err != nil {
+		return access, err
+	}
+	levels := maxGrantLevels(grants)
+	for _, id := range ancestors {
+		if sugarGrantLevelRank[levels[id]] > sugarGrantLevelRank[access.level] {
+			access.level, access.grantedOn = levels[id], id
+		}
+	}
+	return access, nil
+}
+
+// authorizeWorkspaceItem 校验用户对文件或文件夹的有效权限能否执行操作
+func authorizeWorkspaceItem(ctx context.Context, workspace *sugar.SugarWorkspaces, userId string, action SugarAction) error {
+	access, err := resolveWorkspaceAccess(ctx, workspace, userId)
+	if err != nil {
+		global.GVA_LOG.Error("查询文件权限失败", zap.String("workspaceId", *workspace.Id), zap.String("userId", userId), zap.Error(err))
+		return errors.New("校验权限失败")
+	}
+	if !access.allows(action) {
+		return &SugarPermissionError{Resource: SugarResourceWorkspace, Action: action, Role: access.role, GrantLevel: access.level}
+	}
+	return nil
+}
+
+// authorizeWorkspaceCreate 校验用户能否创建文件或文件夹：在文件夹下创建按该文件夹的有效权限校验，在根目录创建按团队角色校验
+func authorizeWorkspaceCreate(ctx context.Context, teamId string, parent *sugar.SugarWorkspaces, userId string) error {
+	if parent != nil {
+		return authorizeWorkspaceItem(ctx, parent, userId, SugarActionEdit)
+	}
+	return authorizeTeamAction(ctx, teamId, userId, SugarResourceWorkspace, SugarActionEdit)
+}
+
+// lookupTeamNames 查询团队名称
+func lookupTeamNames(ctx context.Context, teamIds []string) map[string]string {
+	names := map[string]string{}
+	if len(teamIds) == 0 {
+		return names
+	}
+	var teams []sugar.SugarTeams
+	if err := global.GVA_DB.WithContext(ctx).Select("id", "team_name").Where("id IN ?", teamIds).Find(&teams).Error; err != nil {
+		global.GVA_LOG.Warn("查询团队名称失败", zap.Error(err))
+		return names
+	}
+	for _, team := range teams {
+		if team.Id != nil && team.TeamName != nil {
+			names[*team.Id] = *team.TeamName
+		}
+	}
+	return names
+}
+
+// readableTeamIds 返回角色允许查看文件的团队
+func readableTeamIds(roles map[string]string) []string {
+	teamIds := make([]string, 0, len(roles))
+	for teamId, role := range roles {
+		if SugarRoleAllows(role, SugarResourceWorkspace, SugarActionRead) {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+	sort.Strings(teamIds)
+	return teamIds
+}
+
+// sharedWorkspaceRoots 返回授予用户、且不在其可查看团队中的文件或文件夹
+func sharedWorkspaceRoots(ctx context.Context, levels map[string]string, readableTeams []string) ([]string, error) {
+	ids := make([]string, 0)
+	if len(levels) == 0 {
+		return ids, nil
+	}
+	grantedIds := make([]string, 0, len(levels))
+	for id := range levels {
+		grantedIds = append(grantedIds, id)
+	}
+	db := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).Where("id IN ? AND deleted_at IS NULL", grantedIds)
+	if len(readableTeams) > 0 {
+		db = db.Where("team_id NOT IN ?", readableTeams)
+	}
+	err := db.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// sharedWorkspaceIds 返回被分享的文件或文件夹及其下全部内容的ID，授权向下继承
+func sharedWorkspaceIds(ctx context.Context, levels map[string]string, readableTeams []string) ([]string, error) {
+	frontier, err := sharedWorkspaceRoots(ctx, levels, readableTeams)
+	if err != nil {
+		return nil, err
+	}
+	visited := make(map[string]bool, len(frontier))
+	ids := make([]string, 0, len(frontier))
+	for depth := 0; len(frontier) > 0 && depth < workspaceMaxDepth; depth++ {
+		next := make([]string, 0)
+		for _, id := range frontier {
+			if !visited[id] {
+				visited[id] = true
+				ids = append(ids, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		var children []string
+		err = global.GVA_DB.WithContext(ctx).Model(&sugar.SugarWorkspaces{}).
+			Where("parent_id IN ? AND deleted_at IS NULL", next).Pluck("id", &children).Error
+		if err != nil {
+			return nil, err
+		}
+		frontier = children
+	}
+	return ids, nil
+}
+
+type SugarWorkspacePermissionsService struct{}
+
+// findWorkspace 查询未删除的文件或文件夹
+func (s *SugarWorkspacePermissionsService) findWorkspace(ctx context.Context, id string) (*sugar.SugarWorkspaces, error) {
+	var workspace sugar.SugarWorkspaces
+	err := global.GVA_DB.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&workspace).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件或文件夹不存在")
+		}
+		return nil, errors.New("查询文件失败")
+	}
+	return &workspace, nil
+}
+
+// validateGrantee 校验授权对象存在，用户可以不属于文件所在团队
+func (s *SugarWorkspacePermissionsService) validateGrantee(ctx context.Context, granteeType, granteeId string) error {
+	switch granteeType {
+	case sugar.WorkspaceGranteeUser:
+		id, err := strconv.ParseUint(granteeId, 10, 64)
+		if err != nil {
+			return errors.New("用户ID无效")
+		}
+		var count int64
+		if err = global.GVA_DB.WithContext(ctx).Model(&systemModel.SysUser{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return errors.New("查询用户失败")
+		}
+		if count == 0 {
+			return errors.New("用户不存在")
+		}
+	case sugar.WorkspaceGranteeTeam:
+		var count int64
+		if err := global.GVA_DB.WithContext(ctx).Model(&sugar.SugarTeams{}).Where("id = ?", granteeId).Count(&count).Error; err != nil {
+			return errors.New("查询团队失败")
+		}
+		if count == 0 {
+			return errors.New("团队不存在")
+		}
+	default:
+		return errors.New("授权对象类型只能是 user 或 team")
+	}
+	return nil
+}
+
+// GrantWorkspacePermission 为用户或团队授予文件/文件夹的权限，授予文件夹的权限继承到其下全部内容
+// 需要对该项目有分享权限；同一对象已有授权时更新权限级别
+func (s *SugarWorkspacePermissionsService) GrantWorkspacePermission(ctx context.Context, req sugarReq.SugarWorkspacePermissionGrantRequest, userId string) (*sugar.SugarWorkspacePermissions, error) {
+	if _, ok := sugarGrantLevelRank[req.PermissionLevel]; !ok {
+		return nil, errors.New("权限级别只能是 editor 或 viewer")
+	}
+	if req.GranteeType == sugar.WorkspaceGranteeUser && req.GranteeId == userId {
+		return nil, errors.New("不能给自己授权")
+	}
+	workspace, err := s.findWorkspace(ctx, req.WorkspaceId)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeWorkspaceItem(ctx, workspace, userId, SugarActionShare); err != nil {
+		return nil, err
+	}
+	if err = s.validateGrantee(ctx, req.GranteeType, req.GranteeId); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var grant sugar.SugarWorkspacePermissions
+	err = global.GVA_DB.WithContext(ctx).Where("workspace_id = ? AND grantee_type = ? AND grantee_id = ?", req.WorkspaceId, req.GranteeType, req.GranteeId).First(&grant).Error
+	switch {
+	case err == nil:
+		grant.PermissionLevel, grant.UpdatedBy, grant.UpdatedAt = req.PermissionLevel, &userId, &now
+		err = global.GVA_DB.WithContext(ctx).Model(&grant).Updates(map[string]interface{}{
+			"permission_level": req.PermissionLevel,
+			"updated_by":       userId,
+			"updated_at":       now,
+		}).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		grant = sugar.SugarWorkspacePermissions{
+			WorkspaceId:     &req.WorkspaceId,
+			GranteeType:     req.GranteeType,
+			GranteeId:       req.GranteeId,
+			PermissionLevel: req.PermissionLevel,
+			CreatedBy:       &userId,
+			CreatedAt:       &now,
+			UpdatedBy:       &userId,
+			UpdatedAt:       &now,
+		}
+		err = global.GVA_DB.WithContext(ctx).Create(&grant).Error
+	}
+	if err != nil {
+		global.GVA_LOG.Error("授予文件权限失败", zap.String("workspaceId", req.WorkspaceId), zap.Error(err))
+		return nil, errors.New("授权失败")
+	}
+
+	global.GVA_LOG.Info("授予文件权限", zap.String("workspaceId", req.WorkspaceId), zap.String("granteeType", req.GranteeType),
+		zap.String("granteeId", req.GranteeId), zap.String("level", req.PermissionLevel), zap.String("by", userId))
+	return &grant, nil
+}
+
+// RevokeWorkspacePermission 撤销授权，需要对授权所在项目有分享权限；被授权的用户也可以撤销自己的授权以退出共享
+func (s *SugarWorkspacePermissionsService) RevokeWorkspacePermission(ctx context.Context, id int64, userId string) error {
+	var grant sugar.SugarWorkspacePermissions
+	if err := global.GVA_DB.WithContext(ctx).Where("id = ?", id).First(&grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("授权不存在")
+		}
+		return errors.New("查询授权失败")
+	}
+	self := grant.GranteeType == sugar.WorkspaceGranteeUser && grant.GranteeId == userId
+	if !self {
+		var workspace sugar.SugarWorkspaces
+		if err := global.GVA_DB.WithContext(ctx).Where("id = ?", *grant.WorkspaceId).First(&workspace).Error; err != nil {
+			return errors.New("文件或文件夹不存在")
+		}
+		if err := authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionShare); err != nil {
+			return err
+		}
+	}
+	if err := global.GVA_DB.WithContext(ctx).Delete(&grant).Error; err != nil {
+		global.GVA_LOG.Error("撤销文件权限失败", zap.Int64("id", id), zap.Error(err))
+		return errors.New("撤销授权失败")
+	}
+	global.GVA_LOG.Info("撤销文件权限", zap.String("workspaceId", *grant.WorkspaceId), zap.String("granteeType", grant.GranteeType),
+		zap.String("granteeId", grant.GranteeId), zap.String("by", userId))
+	return nil
+}
+
+// loadAncestorGrants 查询文件自身及上级文件夹上的全部授权，并补充项目和授权对象的名称
+func (s *SugarWorkspacePermissionsService) loadAncestorGrants(ctx context.Context, workspace *sugar.SugarWorkspaces) ([]sugarRes.SugarWorkspaceGrantItem, error) {
+	ancestors, err := workspaceAncestors(ctx, workspace)
+	if err != nil {
+		return nil, err
+	}
+	var grants []sugar.SugarWorkspacePermissions
+	if err = global.GVA_DB.WithContext(ctx).Where("workspace_id IN ?", ancestors).Order("id").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	depth := make(map[string]int, len(ancestors))
+	for i, id := range ancestors {
+		depth[id] = i
+	}
+	var items []sugar.SugarWorkspaces
+	if err = global.GVA_DB.WithContext(ctx).Select("id", "name").Where("id IN ?", ancestors).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	workspaceNames := make(map[string]string, len(items))
+	for _, item := range items {
+		if item.Name != nil {
+			workspaceNames[*item.Id] = *item.Name
+		}
+	}
+	var userIds, teamIds []string
+	for _, grant := range grants {
+		if grant.GranteeType == sugar.WorkspaceGranteeUser {
+			userIds = append(userIds, grant.GranteeId)
+		} else {
+			teamIds = append(teamIds, grant.GranteeId)
+		}
+	}
+	userNames, teamNames := lookupUserNames(ctx, userIds), lookupTeamNames(ctx, teamIds)
+
+	list := make([]sugarRes.SugarWorkspaceGrantItem, 0, len(grants))
+	for _, grant := range grants {
+		item := sugarRes.SugarWorkspaceGrantItem{
+			Id:              grant.Id,
+			WorkspaceId:     *grant.WorkspaceId,
+			WorkspaceName:   workspaceNames[*grant.WorkspaceId],
+			Inherited:       *grant.WorkspaceId != *workspace.Id,
+			GranteeType:     grant.GranteeType,
+			GranteeId:       grant.GranteeId,
+			PermissionLevel: grant.PermissionLevel,
+			CreatedBy:       grant.CreatedBy,
+			CreatedAt:       grant.CreatedAt,
+		}
+		if grant.GranteeType == sugar.WorkspaceGranteeUser {
+			item.GranteeName = userNames[grant.GranteeId]
+		} else {
+			item.GranteeName = teamNames[grant.GranteeId]
+		}
+		list = append(list, item)
+	}
+	// 自身的授权在前，继承的授权按由近及远排列
+	sort.SliceStable(list, func(i, j int) bool {
+		return depth[list[i].WorkspaceId] < depth[list[j].WorkspaceId]
+	})
+	return list, nil
+}
+
+// GetWorkspacePermissionList 获取文件或文件夹上的授权，包括从上级文件夹继承的授权
+func (s *SugarWorkspacePermissionsService) GetWorkspacePermissionList(ctx context.Context, workspaceId string, userId string) ([]sugarRes.SugarWorkspaceGrantItem, error) {
+	workspace, err := s.findWorkspace(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeWorkspaceItem(ctx, workspace, userId, SugarActionRead); err != nil {
+		return nil, err
+	}
+	list, err := s.loadAncestorGrants(ctx, workspace)
+	if err != nil {
+		global.GVA_LOG.Error("查询文件授权失败", zap.String("workspaceId", workspaceId), zap.Error(err))
+		return nil, errors.New("查询授权失败")
+	}
+	return list, nil
+}
+
+// GetWorkspaceAccess 获取文件或文件夹的有效权限：所属团队成员按角色，被授权的用户和团队按授权级别，两者取并集
+func (s *SugarWorkspacePermissionsService) GetWorkspaceAccess(ctx context.Context, workspaceId string, userId string) (*sugarRes.SugarWorkspaceAccessResponse, error) {
+	workspace, err := s.findWorkspace(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	access, err := resolveWorkspaceAccess(ctx, workspace, userId)
+	if err != nil {
+		global.GVA_LOG.Error("查询文件权限失败", zap.String("workspaceId", workspaceId), zap.Error(err))
+		return nil, errors.New("查询权限失败")
+	}
+	if !access.allows(SugarActionRead) {
+		return nil, &SugarPermissionError{Resource: SugarResourceWorkspace, Action: SugarActionRead, Role: access.role, GrantLevel: access.level}
+	}
+
+	grants, err := s.loadAncestorGrants(ctx, workspace)
+	if err != nil {
+		global.GVA_LOG.Error("查询文件授权失败", zap.String("workspaceId", workspaceId), zap.Error(err))
+		return nil, errors.New("查询权限失败")
+	}
+	var members []sugar.SugarTeamMembers
+	if workspace.TeamId != nil {
+		if err = global.GVA_DB.WithContext(ctx).Where("team_id = ?", *workspace.TeamId).Find(&members).Error; err != nil {
+			return nil, errors.New("查询团队成员失败")
+		}
+	}
+
+	// 按授权对象汇总：团队成员以角色为基础，再合并授予本人或其所在团队的最高级别
+	users := map[string]*accessPrincipal{}
+	teams := map[string]*accessPrincipal{}
+	var order []*accessPrincipal
+	userEntry := func(id string) *accessPrincipal {
+		if p, ok := users[id]; ok {
+			return p
+		}
+		p := &accessPrincipal{entry: sugarRes.SugarWorkspaceAccessEntry{GranteeType: sugar.WorkspaceGranteeUser, GranteeId: id}}
+		users[id], order = p, append(order, p)
+		return p
+	}
+	raise := func(p *accessPrincipal, level, grantedOn string) {
+		if sugarGrantLevelRank[level] > sugarGrantLevelRank[p.access.level] {
+			p.access.level, p.access.grantedOn = level, grantedOn
+		}
+	}
+	for _, member := range members {
+		p := userEntry(*member.UserId)
+		if sugarRoleRank[member.Role] > sugarRoleRank[p.access.role] {
+			p.access.role = member.Role
+		}
+	}
+	for _, grant := range grants {
+		if grant.GranteeType == sugar.WorkspaceGranteeUser {
+			raise(userEntry(grant.GranteeId), grant.PermissionLevel, grant.WorkspaceId)
+			continue
+		}
+		p, ok := teams[grant.GranteeId]
+		if !ok {
+			p = &accessPrincipal{entry: sugarRes.SugarWorkspaceAccessEntry{GranteeType: sugar.WorkspaceGranteeTeam, GranteeId: grant.GranteeId, GranteeName: grant.GranteeName}}
+			teams[grant.GranteeId], order = p, append(order, p)
+		}
+		raise(p, grant.PermissionLevel, grant.WorkspaceId)
+	}
+	// 已列出的用户通过所在团队获得的授权
+	if len(teams) > 0 && len(users) > 0 {
+		var memberships []sugar.SugarTeamMembers
+		err = global.GVA_DB.WithContext(ctx).Where("team_id IN ? AND user_id IN ?", principalIds(teams), principalIds(users)).Find(&memberships).Error
+		if err != nil {
+			return nil, errors.New("查询团队成员失败")
+		}
+		for _, membership := range memberships {
+			team := teams[*membership.TeamId]
+			raise(users[*membership.UserId], team.access.level, team.access.grantedOn)
+		}
+	}
+
+	userNames := lookupUserNames(ctx, principalIds(users))
+	result := &sugarRes.SugarWorkspaceAccessResponse{
+		WorkspaceId: workspaceId,
+		Actions:     access.actions(),
+		Entries:     make([]sugarRes.SugarWorkspaceAccessEntry, 0, len(order)),
+	}
+	if workspace.TeamId != nil {
+		result.TeamId = *workspace.TeamId
+	}
+	for _, p := range order {
+		entry := p.entry
+		if entry.GranteeType == sugar.WorkspaceGranteeUser {
+			entry.GranteeName = userNames[entry.GranteeId]
+		}
+		entry.Role, entry.PermissionLevel, entry.GrantedOn = p.access.role, p.access.level, p.access.grantedOn
+		entry.Actions = p.access.actions()
+		result.Entries = append(result.Entries, entry)
+	}
+	// 权限多的在前，团队成员按角色排列
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		left, right := result.Entries[i], result.Entries[j]
+		if len(left.Actions) != len(right.Actions) {
+			return len(left.Actions) > len(right.Actions)
+		}
+		return sugarRoleRank[left.Role] > sugarRoleRank[right.Role]
+	})
+	return result, nil
+}
+
+// accessPrincipal 汇总有效权限时的一个用户或团队
+type accessPrincipal struct {
+	entry  sugarRes.SugarWorkspaceAccessEntry
+	access workspaceAccess
+}
+
+func principalIds(principals map[string]*accessPrincipal) []string {
+	ids := make([]string, 0, len(principals))
+	for id := range principals {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,222 @@
+package sugar
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/common/request"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// setupSharedFolder 把测试文件放进团队 1 的文件夹 folder-1
+func setupSharedFolder(t *testing.T) {
+	t.Helper()
+	setupTeamWorkbook(t)
+	seedTestData(t,
+		`INSERT INTO sugar_workspaces (id, name, type, team_id) VALUES ('folder-1', '报表', 'folder', 'team-1')`,
+		`UPDATE sugar_workspaces SET parent_id = 'folder-1' WHERE id = '`+testFileId+`'`,
+	)
+}
+
+func grantWorkspace(t *testing.T, workspaceId, granteeType, granteeId, level string) *sugar.SugarWorkspacePermissions {
+	t.Helper()
+	grant, err := (&SugarWorkspacePermissionsService{}).GrantWorkspacePermission(context.Background(), sugarReq.SugarWorkspacePermissionGrantRequest{
+		WorkspaceId: workspaceId, GranteeType: granteeType, GranteeId: granteeId, PermissionLevel: level,
+	}, "10")
+	if err != nil {
+		t.Fatalf("授权失败: %v", err)
+	}
+	return grant
+}
+
+func workspaceTree(t *testing.T, userId string, teamId *string) []*sugarRes.SugarFoldersWorkspaceTreeNode {
+	t.Helper()
+	result, err := (&SugarFoldersService{}).GetWorkspaceTree(context.Background(), &sugarReq.SugarFoldersGetWorkspaceTreeRequest{TeamId: teamId}, userId)
+	if err != nil {
+		t.Fatalf("获取工作空间树失败: %v", err)
+	}
+	return result.Tree
+}
+
+func TestWorkspaceShareWithExternalUser(t *testing.T) {
+	setupSharedFolder(t)
+	ctx := context.Background()
+	workspaces := &SugarWorkspacesService{}
+	permissions := &SugarWorkspacePermissionsService{}
+
+	// 不是团队成员且没有授权时不能查看
+	_, err := workspaces.GetWorkbookContent(ctx, testFileId, "3")
+	expectPermissionDenied(t, err, "")
+	if tree := workspaceTree(t, "3", nil); len(tree) != 0 {
+		t.Fatalf("没有授权的用户不应看到任何文件: %+v", tree)
+	}
+
+	// 查看者不能分享，也不能给自己授权
+	_, err = permissions.GrantWorkspacePermission(ctx, sugarReq.SugarWorkspacePermissionGrantRequest{
+		WorkspaceId: testFileId, GranteeType: sugar.WorkspaceGranteeUser, GranteeId: "3", PermissionLevel: sugar.WorkspacePermissionViewer,
+	}, "12")
+	expectPermissionDenied(t, err, SugarTeamRoleViewer)
+	_, err = permissions.GrantWorkspacePermission(ctx, sugarReq.SugarWorkspacePermissionGrantRequest{
+		WorkspaceId: testFileId, GranteeType: sugar.WorkspaceGranteeUser, GranteeId: "10", PermissionLevel: sugar.WorkspacePermissionEditor,
+	}, "10")
+	if err == nil {
+		t.Fatal("不应允许给自己授权")
+	}
+
+	// 单独分享给团队外的用户：只读
+	grant := grantWorkspace(t, testFileId, sugar.WorkspaceGranteeUser, "3", sugar.WorkspacePermissionViewer)
+	current, err := workspaces.GetWorkbookContent(ctx, testFileId, "3")
+	if err != nil {
+		t.Fatalf("被授权的用户应能打开文件: %v", err)
+	}
+	_, err = workspaces.SaveWorkbookContent(ctx, testFileId, []byte(workbookJSON(`"0": {"0": {"v": "改"}}`)), nil, current.Revision, "3")
+	expectPermissionDenied(t, err, "")
+	if !strings.Contains(err.Error(), "只有查看权限") {
+		t.Fatalf("错误信息应说明授权级别: %v", err)
+	}
+
+	// 重复授权更新级别，编辑者可以保存但不能删除，也不能继续分享
+	if upgraded := grantWorkspace(t, testFileId, sugar.WorkspaceGranteeUser, "3", sugar.WorkspacePermissionEditor); upgraded.Id != grant.Id {
+		t.Fatalf("重复授权应更新原有授权: %d != %d", upgraded.Id, grant.Id)
+	}
+	saveWorkbook(t, "3", `"0": {"0": {"v": "外部修改"}}`)
+	_, err = (&SugarFoldersService{}).DeleteItem(ctx, &sugarReq.SugarFoldersDeleteRequest{Id: testFileId}, "3")
+	expectPermissionDenied(t, err, "")
+	_, err = permissions.GrantWorkspacePermission(ctx, sugarReq.SugarWorkspacePermissionGrantRequest{
+		WorkspaceId: testFileId, GranteeType: sugar.WorkspaceGranteeUser, GranteeId: "4", PermissionLevel: sugar.WorkspacePermissionViewer,
+	}, "3")
+	expectPermissionDenied(t, err, "")
+	_, err = (&SugarShareLinksService{}).CreateShareLink(ctx, sugarReq.SugarShareLinkCreateRequest{WorkspaceId: testFileId, PermissionLevel: sugar.ShareLinkPermissionViewer}, "3")
+	expectPermissionDenied(t, err, "")
+
+	// 分享的文件单独出现在树的根节点，上级文件夹不可见
+	tree := workspaceTree(t, "3", nil)
+	if len(tree) != 1 || tree[0].Id != testFileId || !tree[0].Shared {
+		t.Fatalf("树中应只有被分享的文件: %+v", tree)
+	}
+	if strings.Join(tree[0].Actions, ",") != "read,edit" {
+		t.Fatalf("编辑授权允许的操作不符合预期: %v", tree[0].Actions)
+	}
+
+	// 被授权的用户可以自行退出分享
+	if err = permissions.RevokeWorkspacePermission(ctx, grant.Id, "3"); err != nil {
+		t.Fatalf("被授权的用户应能撤销自己的授权: %v", err)
+	}
+	_, err = workspaces.GetWorkbookContent(ctx, testFileId, "3")
+	expectPermissionDenied(t, err, "")
+}
+
+func TestWorkspaceFolderGrantInheritance(t *testing.T) {
+	setupSharedFolder(t)
+	ctx := context.Background()
+	folders := &SugarFoldersService{}
+	permissions := &SugarWorkspacePermissionsService{}
+
+	grantWorkspace(t, "folder-1", sugar.WorkspaceGranteeUser, "3", sugar.WorkspacePermissionEditor)
+	grantWorkspace(t, "folder-1", sugar.WorkspaceGranteeTeam, "team-2", sugar.WorkspacePermissionEditor)
+
+	// 文件夹的授权继承到其中的文件和新建的子文件夹
+	content, err := folders.GetFolderContent(ctx, &sugarReq.SugarFoldersGetFolderContentRequest{FolderId: "folder-1", PageInfo: request.PageInfo{Page: 1, PageSize: 10}}, "3")
+	if err != nil || content.Total != 1 {
+		t.Fatalf("被授权的用户应能查看文件夹内容: %+v %v", content, err)
+	}
+	saveWorkbook(t, "3", `"0": {"0": {"v": "继承"}}`)
+	parentId := "folder-1"
+	if _, err = folders.CreateFolder(ctx, &sugarReq.SugarFoldersCreateFolderRequest{Name: "子文件夹", TeamId: "team-1", Type: "folder", ParentId: &parentId}, "3"); err != nil {
+		t.Fatalf("被授权编辑文件夹的用户应能在其中创建: %v", err)
+	}
+	_, err = folders.CreateFolder(ctx, &sugarReq.SugarFoldersCreateFolderRequest{Name: "根目录文件夹", TeamId: "team-1", Type: "folder"}, "3")
+	expectPermissionDenied(t, err, "")
+
+	// 团队外用户的树以被分享的文件夹为根，指定团队时只返回分享的内容
+	teamId := "team-1"
+	for _, tree := range [][]*sugarRes.SugarFoldersWorkspaceTreeNode{workspaceTree(t, "3", nil), workspaceTree(t, "3", &teamId)} {
+		if len(tree) != 1 || tree[0].Id != "folder-1" || len(tree[0].Children) != 2 {
+			t.Fatalf("树应以被分享的文件夹为根并包含其全部内容: %+v", tree)
+		}
+		for _, child := range tree[0].Children {
+			if !child.Shared || len(child.Actions) != 2 {
+				t.Fatalf("子节点应继承文件夹的授权: %+v", child)
+			}
+		}
+	}
+	list, total, err := (&SugarWorkspacesService{}).GetSugarWorkspacesInfoListByUser(ctx, sugarReq.SugarWorkspacesSearch{}, "3")
+	if err != nil || total != 1 || *list[0].Id != "folder-1" {
+		t.Fatalf("根目录列表应包含被分享的文件夹: %v %d %v", list, total, err)
+	}
+
+	// 团队 1 的查看者通过团队 2 的授权获得编辑权限
+	saveWorkbook(t, "12", `"0": {"0": {"v": "团队授权"}}`)
+	for _, node := range workspaceTree(t, "12", &teamId) {
+		if node.Id == "folder-1" && (node.Shared || len(node.Actions) != 2) {
+			t.Fatalf("团队成员的节点不应标记为分享，并应合并团队授权: %+v", node)
+		}
+	}
+
+	// 授权列表标明继承来源
+	grants, err := permissions.GetWorkspacePermissionList(ctx, testFileId, "12")
+	if err != nil || len(grants) != 2 {
+		t.Fatalf("应列出从文件夹继承的两条授权: %+v %v", grants, err)
+	}
+	for _, grant := range grants {
+		if !grant.Inherited || grant.WorkspaceName != "报表" {
+			t.Fatalf("授权应标记为继承自文件夹: %+v", grant)
+		}
+	}
+
+	// 有效权限合并团队角色与授权
+	access, err := permissions.GetWorkspaceAccess(ctx, testFileId, "10")
+	if err != nil {
+		t.Fatalf("获取有效权限失败: %v", err)
+	}
+	entries := map[string]sugarRes.SugarWorkspaceAccessEntry{}
+	for _, entry := range access.Entries {
+		entries[entry.GranteeType+":"+entry.GranteeId] = entry
+	}
+	if carol := entries["user:3"]; carol.Role != "" || carol.PermissionLevel != sugar.WorkspacePermissionEditor || carol.GrantedOn != "folder-1" || carol.GranteeName != "Carol" {
+		t.Fatalf("团队外用户的有效权限不符合预期: %+v", carol)
+	}
+	if viewer := entries["user:12"]; viewer.Role != SugarTeamRoleViewer || len(viewer.Actions) != 2 {
+		t.Fatalf("查看者应通过团队授权获得编辑权限: %+v", viewer)
+	}
+	if team := entries["team:team-2"]; team.GranteeName != "销售部" || team.PermissionLevel != sugar.WorkspacePermissionEditor {
+		t.Fatalf("团队授权不符合预期: %+v", team)
+	}
+	if owner := entries["user:10"]; len(owner.Actions) != len(SugarActions) || access.Entries[0].Role != SugarTeamRoleOwner {
+		t.Fatalf("所有者应拥有全部权限并排在最前: %+v", access.Entries)
+	}
+	_, err = permissions.GetWorkspaceAccess(ctx, testFileId, "4")
+	expectPermissionDenied(t, err, "")
+}
+
+func TestMigrateCommenterGrants(t *testing.T) {
+	setupSharedFolder(t)
+	seedTestData(t,
+		`INSERT INTO sugar_workspace_permissions (workspace_id, grantee_type, grantee_id, permission_level) VALUES ('folder-1', 'user', '3', 'commenter'), ('folder-1', 'user', '4', 'editor')`,
+	)
+
+	// 评论授权迁移为查看授权，其他级别不变
+	migrated, err := MigrateCommenterGrants(global.GVA_DB)
+	if err != nil || migrated != 1 {
+		t.Fatalf("应迁移一条评论授权: %d %v", migrated, err)
+	}
+	levels := map[string]string{}
+	var grants []sugar.SugarWorkspacePermissions
+	global.GVA_DB.Find(&grants)
+	for _, grant := range grants {
+		levels[grant.GranteeId] = grant.PermissionLevel
+	}
+	if levels["3"] != sugar.WorkspacePermissionViewer || levels["4"] != sugar.WorkspacePermissionEditor {
+		t.Fatalf("迁移后的授权级别不符合预期: %v", levels)
+	}
+	if _, err = (&SugarWorkspacesService{}).GetWorkbookContent(context.Background(), testFileId, "3"); err != nil {
+		t.Fatalf("迁移后应能查看文件: %v", err)
+	}
+	if migrated, err = MigrateCommenterGrants(global.GVA_DB); err != nil || migrated != 0 {
+		t.Fatalf("重复迁移不应修改授权: %d %v", migrated, err)
+	}
+}
@@ -3,11 +3,13 @@ package sugar
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/flipped-aurora/gin-vue-admin/server/global"
 	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
 	sugarReq "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/request"
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/datatypes"
@@ -18,7 +20,15 @@ type SugarWorkspacesService struct{}
 
 // CreateSugarWorkspaces 创建Sugar文件列表记录
 func (s *SugarWorkspacesService) CreateSugarWorkspaces(ctx context.Context, workspace *sugar.SugarWorkspaces) (err error) {
-	err = global.GVA_DB.Create(workspace).Error
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workspace).Error; err != nil {
+			return err
+		}
+		if workspace.Type != "file" || workspace.Id == nil {
+			return nil
+		}
+		return indexWorkbookContent(tx, *workspace.Id, workspace.Content, workspace.Revision)
+	})
 	return err
 }
 
@@ -28,18 +38,65 @@ func (s *SugarWorkspacesService) DeleteSugarWorkspaces(ctx context.Context, id s
 	if err = global.GVA_DB.Where("id = ?", id).First(&workspace).Error; err != nil {
 		return errors.New("文件或文件夹不存在")
 	}
-	// 简化权限：只有创建者能删除
-	if *workspace.CreatedBy != userId {
-		return errors.New("无权删除")
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionDelete); err != nil {
+		return err
 	}
-	err = global.GVA_DB.Delete(&sugar.SugarWorkspaces{}, "id = ?", id).Error
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&sugar.SugarWorkspaces{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", id).Delete(&sugar.SugarWorkspacePermissions{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", id).Delete(&sugar.SugarShareLinks{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", id).Delete(&sugar.SugarFileVersions{}).Error; err != nil {
+			return err
+		}
+		if err := deleteWorkbookSearchIndex(tx, []string{id}); err != nil {
+			return err
+		}
+		return tx.Where("file_id = ?", id).Delete(&sugar.SugarWorkbookOperations{}).Error
+	})
 	return err
 }
 
 // DeleteSugarWorkspacesByIds 批量删除Sugar文件列表记录
 func (s *SugarWorkspacesService) DeleteSugarWorkspacesByIds(ctx context.Context, ids []string, userId string) (err error) {
-	// 简化权限：只批量删除用户自己创建的文件
-	err = global.GVA_DB.Where("id IN ? AND created_by = ?", ids, userId).Delete(&[]sugar.SugarWorkspaces{}).Error
+	// 只批量删除用户有删除权限的团队中的文件
+	teamIds, err := authorizedTeamIds(ctx, userId, SugarResourceWorkspace, SugarActionDelete)
+	if err != nil {
+		return err
+	}
+	if len(teamIds) == 0 {
+		return nil
+	}
+	var ownedIds []string
+	if err = global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("id IN ? AND team_id IN ?", ids, teamIds).Pluck("id", &ownedIds).Error; err != nil {
+		return err
+	}
+	if len(ownedIds) == 0 {
+		return nil
+	}
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", ownedIds).Delete(&[]sugar.SugarWorkspaces{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id IN ?", ownedIds).Delete(&sugar.SugarWorkspacePermissions{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id IN ?", ownedIds).Delete(&sugar.SugarShareLinks{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id IN ?", ownedIds).Delete(&sugar.SugarFileVersions{}).Error; err != nil {
+			return err
+		}
+		if err := deleteWorkbookSearchIndex(tx, ownedIds); err != nil {
+			return err
+		}
+		return tx.Where("file_id IN ?", ownedIds).Delete(&sugar.SugarWorkbookOperations{}).Error
+	})
 	return err
 }
 
@@ -49,11 +106,27 @@ func (s *SugarWorkspacesService) UpdateSugarWorkspaces(ctx context.Context, work
 	if err = global.GVA_DB.Where("id = ?", workspace.Id).First(&oldWorkspace).Error; err != nil {
 		return errors.New("文件或文件夹不存在")
 	}
-	// 简化权限：只有创建者能更新
-	if *oldWorkspace.CreatedBy != userId {
-		return errors.New("无权更新")
+	if err = authorizeWorkspaceItem(ctx, &oldWorkspace, userId, SugarActionEdit); err != nil {
+		return err
+	}
+	// 修改所属团队需要原团队和目标团队的管理权限
+	if workspace.TeamId != nil && (oldWorkspace.TeamId == nil || *workspace.TeamId != *oldWorkspace.TeamId) {
+		if err = authorizeTeamResource(ctx, oldWorkspace.TeamId, userId, SugarResourceWorkspace, SugarActionManage); err != nil {
+			return err
+		}
+		if err = authorizeTeamAction(ctx, *workspace.TeamId, userId, SugarResourceWorkspace, SugarActionManage); err != nil {
+			return err
+		}
 	}
-	err = global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("id = ?", workspace.Id).Updates(&workspace).Error
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&sugar.SugarWorkspaces{}).Where("id = ?", workspace.Id).Updates(&workspace).Error; err != nil {
+			return err
+		}
+		if oldWorkspace.Type != "file" || len(workspace.Content) == 0 {
+			return nil
+		}
+		return indexWorkbookContent(tx, *oldWorkspace.Id, workspace.Content, oldWorkspace.Revision)
+	})
 	return err
 }
 
@@ -62,7 +135,9 @@ func (s *SugarWorkspacesService) GetSugarWorkspaces(ctx context.Context, id stri
 	if err = global.GVA_DB.Where("id = ?", id).First(&workspace).Error; err != nil {
 		return workspace, errors.New("文件或文件夹不存在")
 	}
-	// TODO: 此处应增加对 sugar_workspace_permissions 表的校验，判断用户是否有权限查看
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionRead); err != nil {
+		return workspace, err
+	}
 	return workspace, nil
 }
 
@@ -71,22 +146,42 @@ func (s *SugarWorkspacesService) GetSugarWorkspacesInfoListByUser(ctx context.Co
 	limit := info.PageSize
 	offset := info.PageSize * (info.Page - 1)
 
-	var teamIds []string
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
-	if err != nil {
-		return nil, 0, err
-	}
-	if len(teamIds) == 0 {
-		return []sugar.SugarWorkspaces{}, 0, nil
-	}
-
-	db := global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("team_id IN ?", teamIds)
+	db := global.GVA_DB.Model(&sugar.SugarWorkspaces{})
 
-	// 根据 parent_id 筛选
+	// 根据 parent_id 筛选：文件夹下的内容继承文件夹的权限，根目录包含所在团队的内容和分享给用户的内容
 	if info.ParentId != nil {
+		var parent sugar.SugarWorkspaces
+		if err = global.GVA_DB.Where("id = ?", *info.ParentId).First(&parent).Error; err != nil {
+			return nil, 0, err
+		}
+		if err = authorizeWorkspaceItem(ctx, &parent, userId, SugarActionRead); err != nil {
+			return nil, 0, err
+		}
 		db = db.Where("parent_id = ?", *info.ParentId)
 	} else {
-		db = db.Where("parent_id IS NULL")
+		roles, err := userTeamRoles(ctx, userId)
+		if err != nil {
+			return nil, 0, err
+		}
+		grants, err := userGrants(ctx, userId, teamIdsOf(roles), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		teamIds := readableTeamIds(roles)
+		sharedIds, err := sharedWorkspaceRoots(ctx, maxGrantLevels(grants), teamIds)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case len(teamIds) == 0 && len(sharedIds) == 0:
+			return []sugar.SugarWorkspaces{}, 0, nil
+		case len(sharedIds) == 0:
+			db = db.Where("team_id IN ? AND parent_id IS NULL", teamIds)
+		case len(teamIds) == 0:
+			db = db.Where("id IN ?", sharedIds)
+		default:
+			db = db.Where("(team_id IN ? AND parent_id IS NULL) OR id IN ?", teamIds, sharedIds)
+		}
 	}
 
 	var sugarWorkspacess []sugar.SugarWorkspaces
@@ -105,17 +200,11 @@ func (s *SugarWorkspacesService) GetSugarWorkspacesInfoListByUser(ctx context.Co
 
 // CreateWorkbookFile 创建新的工作簿文件
 func (s *SugarWorkspacesService) CreateWorkbookFile(ctx context.Context, name string, parentId *string, teamId string, userId string, defaultContent datatypes.JSON) (*sugar.SugarWorkspaces, error) {
-	// 验证用户是否有权限在该团队创建文件
-	var count int64
-	err := global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, teamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限在该团队创建文件")
-	}
-
 	// 如果指定了父文件夹，验证父文件夹是否存在且为文件夹类型
+	var parent *sugar.SugarWorkspaces
 	if parentId != nil && *parentId != "" {
-		var parent sugar.SugarWorkspaces
-		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *parentId, teamId, "folder").First(&parent).Error
+		parent = &sugar.SugarWorkspaces{}
+		err := global.GVA_DB.Where("id = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", *parentId, teamId, "folder").First(parent).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return nil, errors.New("父文件夹不存在")
@@ -124,6 +213,12 @@ func (s *SugarWorkspacesService) CreateWorkbookFile(ctx context.Context, name st
 		}
 	}
 
+	// 验证用户是否有权限在团队根目录或父文件夹下创建文件
+	err := authorizeWorkspaceCreate(ctx, teamId, parent, userId)
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查同级目录下是否已存在同名文件
 	var existCount int64
 	query := global.GVA_DB.Model(&sugar.SugarWorkspaces{}).Where("name = ? AND team_id = ? AND type = ? AND deleted_at IS NULL", name, teamId, "file")
@@ -156,7 +251,12 @@ func (s *SugarWorkspacesService) CreateWorkbookFile(ctx context.Context, name st
 		UpdatedAt: &now,
 	}
 
-	err = global.GVA_DB.Create(&workspace).Error
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return indexWorkbookContent(tx, id, defaultContent, 0)
+	})
 	if err != nil {
 		global.GVA_LOG.Error("创建工作簿文件失败", zap.Error(err))
 		return nil, errors.New("创建文件失败")
@@ -166,43 +266,93 @@ func (s *SugarWorkspacesService) CreateWorkbookFile(ctx context.Context, name st
 	return &workspace, nil
 }
 
+// WorkbookConflictError 保存时提交的修订号已过期且无法自动合并
+type WorkbookConflictError struct {
+	Conflict *sugarRes.SugarWorkbookSaveConflict
+}
+
+func (e *WorkbookConflictError) Error() string {
+	if e.Conflict.NeedBaseContent {
+		return "工作簿已被其他人修改，正在尝试自动合并"
+	}
+	return fmt.Sprintf("工作簿已被其他人修改，有 %d 处修改冲突无法自动合并，请重新打开文件", len(e.Conflict.Conflicts))
+}
+
 // SaveWorkbookContent 保存工作簿内容
-func (s *SugarWorkspacesService) SaveWorkbookContent(ctx context.Context, id string, content datatypes.JSON, userId string) error {
+// revision 为客户端打开或上次保存时获得的修订号，与当前修订号不一致说明期间有其他人保存过：
+// 提供了 baseContent（该修订号对应的内容）时对双方修改做三方合并，修改不重叠则保存合并结果，否则返回 WorkbookConflictError
+func (s *SugarWorkspacesService) SaveWorkbookContent(ctx context.Context, id string, content, baseContent datatypes.JSON, revision int, userId string) (*sugarRes.SugarWorkbookSaveResponse, error) {
 	// 查找要保存的文件
 	var workspace sugar.SugarWorkspaces
 	err := global.GVA_DB.Where("id = ? AND type = ? AND deleted_at IS NULL", id, "file").First(&workspace).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("文件不存在")
+			return nil, errors.New("文件不存在")
 		}
-		return errors.New("查询文件失败")
+		return nil, errors.New("查询文件失败")
 	}
 
-	// 验证用户是否有权限操作该文件
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *workspace.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return errors.New("无权限操作该文件")
+	// 验证用户是否有权限编辑该文件
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionEdit); err != nil {
+		return nil, err
 	}
 
-	// 更新文件内容
-	now := time.Now()
-	err = global.GVA_DB.Model(&workspace).Updates(map[string]interface{}{
-		"content":    content,
-		"updated_by": userId,
-		"updated_at": now,
-	}).Error
+	// 校验修订号、记录历史版本并更新文件内容
+	result := &sugarRes.SugarWorkbookSaveResponse{}
+	err = global.GVA_DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockWorkbookFile(tx, id)
+		if err != nil {
+			return err
+		}
+		if locked.Revision != revision {
+			if len(baseContent) == 0 {
+				return &WorkbookConflictError{Conflict: &sugarRes.SugarWorkbookSaveConflict{CurrentRevision: locked.Revision, NeedBaseContent: true}}
+			}
+			merged, conflicts, err := mergeUniverWorkbooks(baseContent, content, locked.Content)
+			if err != nil {
+				return err
+			}
+			if len(conflicts) > 0 {
+				return &WorkbookConflictError{Conflict: &sugarRes.SugarWorkbookSaveConflict{CurrentRevision: locked.Revision, Conflicts: conflicts}}
+			}
+			content = merged
+			result.Merged, result.Content = true, merged
+		}
+
+		version, err := snapshotWorkbookContent(tx, locked, content, userId, sugar.FileVersionSourceSave, nil)
+		if err != nil {
+			return err
+		}
+		result.Revision, result.Version = locked.Revision+1, version.VersionNumber
+		err = tx.Model(locked).Updates(map[string]interface{}{
+			"content":    content,
+			"revision":   result.Revision,
+			"updated_by": userId,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return indexWorkbookContent(tx, id, content, result.Revision)
+	})
 	if err != nil {
+		var conflictErr *WorkbookConflictError
+		if errors.As(err, &conflictErr) {
+			global.GVA_LOG.Info("工作簿保存冲突", zap.String("id", id), zap.Int("revision", revision),
+				zap.Int("currentRevision", conflictErr.Conflict.CurrentRevision), zap.Int("conflicts", len(conflictErr.Conflict.Conflicts)))
+			return nil, conflictErr
+		}
 		global.GVA_LOG.Error("保存工作簿内容失败", zap.Error(err))
-		return errors.New("保存文件失败")
+		return nil, errors.New("保存文件失败")
 	}
 
-	global.GVA_LOG.Info("工作簿内容保存成功", zap.String("id", id))
-	return nil
+	global.GVA_LOG.Info("工作簿内容保存成功", zap.String("id", id), zap.Int("revision", result.Revision),
+		zap.Int("version", result.Version), zap.Bool("merged", result.Merged))
+	return result, nil
 }
 
 // GetWorkbookContent 获取工作簿内容
-func (s *SugarWorkspacesService) GetWorkbookContent(ctx context.Context, id string, userId string) (datatypes.JSON, error) {
+func (s *SugarWorkspacesService) GetWorkbookContent(ctx context.Context, id string, userId string) (*sugarRes.SugarWorkbookContentResponse, error) {
 	// 查找文件
 	var workspace sugar.SugarWorkspaces
 	err := global.GVA_DB.Where("id = ? AND type = ? AND deleted_at IS NULL", id, "file").First(&workspace).Error
@@ -213,12 +363,10 @@ func (s *SugarWorkspacesService) GetWorkbookContent(ctx context.Context, id stri
 		return nil, errors.New("查询文件失败")
 	}
 
-	// 验证用户是否有权限访问该文件
-	var count int64
-	err = global.GVA_DB.Table("sugar_team_members").Where("user_id = ? AND team_id = ?", userId, *workspace.TeamId).Count(&count).Error
-	if err != nil || count == 0 {
-		return nil, errors.New("无权限访问该文件")
+	// 验证用户是否有权限查看该文件
+	if err = authorizeWorkspaceItem(ctx, &workspace, userId, SugarActionRead); err != nil {
+		return nil, err
 	}
 
-	return workspace.Content, nil
+	return &sugarRes.SugarWorkbookContentResponse{Content: workspace.Content, Revision: workspace.Revision, OperationRevision: workspace.OperationRevision}, nil
 }
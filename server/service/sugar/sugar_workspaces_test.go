@@ -0,0 +1,97 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSaveWorkbookContent_OptimisticConcurrency(t *testing.T) {
+	setupTeamWorkbook(t)
+	service := &SugarWorkspacesService{}
+	ctx := context.Background()
+
+	opened, err := service.GetWorkbookContent(ctx, testFileId, "1")
+	if err != nil {
+		t.Fatalf("获取工作簿内容失败: %v", err)
+	}
+	base := []byte(opened.Content)
+
+	// 用户2先保存：修改 B1 并新增 A2
+	theirs := workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 200}}, "1": {"0": {"v": "北京"}}`)
+	saved, err := service.SaveWorkbookContent(ctx, testFileId, []byte(theirs), nil, opened.Revision, "2")
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if saved.Revision != opened.Revision+1 || saved.Merged {
+		t.Fatalf("保存结果不符合预期: %+v", saved)
+	}
+
+	// 用户1基于旧修订号保存且未提供基准内容：要求携带基准内容重试
+	mine := workbookJSON(`"0": {"0": {"v": "营收"}, "1": {"v": 100}}`)
+	_, err = service.SaveWorkbookContent(ctx, testFileId, []byte(mine), nil, opened.Revision, "1")
+	var conflictErr *WorkbookConflictError
+	if !errors.As(err, &conflictErr) || !conflictErr.Conflict.NeedBaseContent || conflictErr.Conflict.CurrentRevision != saved.Revision {
+		t.Fatalf("期望返回需要基准内容的冲突，实际: %v", err)
+	}
+
+	// 携带基准内容：双方修改的单元格不重叠，自动合并
+	merged, err := service.SaveWorkbookContent(ctx, testFileId, []byte(mine), base, opened.Revision, "1")
+	if err != nil {
+		t.Fatalf("自动合并失败: %v", err)
+	}
+	expected := workbookJSON(`"0": {"0": {"v": "营收"}, "1": {"v": 200}}, "1": {"0": {"v": "北京"}}`)
+	if !merged.Merged || merged.Revision != saved.Revision+1 || !sameWorkbookContent(merged.Content, []byte(expected)) {
+		t.Fatalf("合并结果不符合预期: %+v, %s", merged, merged.Content)
+	}
+
+	// 基于合并前的修订号再次修改 B1：与用户2的修改重叠，返回冲突单元格
+	conflicting := workbookJSON(`"0": {"0": {"v": "收入"}, "1": {"v": 300}}`)
+	_, err = service.SaveWorkbookContent(ctx, testFileId, []byte(conflicting), base, opened.Revision, "1")
+	if !errors.As(err, &conflictErr) || len(conflictErr.Conflict.Conflicts) != 1 || conflictErr.Conflict.Conflicts[0].Cell != "B1" {
+		t.Fatalf("期望 B1 冲突，实际: %v", err)
+	}
+	current, err := service.GetWorkbookContent(ctx, testFileId, "1")
+	if err != nil {
+		t.Fatalf("获取工作簿内容失败: %v", err)
+	}
+	if current.Revision != merged.Revision || !sameWorkbookContent(current.Content, []byte(expected)) {
+		t.Fatalf("冲突时不应写入内容: revision=%d, %s", current.Revision, current.Content)
+	}
+}
+
+func TestMergeUniverWorkbooks_Sheets(t *testing.T) {
+	base := `{"sheetOrder": ["s1", "s2"], "sheets": {
+		"s1": {"id": "s1", "name": "收入", "cellData": {}},
+		"s2": {"id": "s2", "name": "成本", "cellData": {"0": {"0": {"v": 1}}}}}}`
+	// 本次提交：新增工作表 s3，删除未被他人修改的 s1
+	mine := `{"sheetOrder": ["s2", "s3"], "sheets": {
+		"s2": {"id": "s2", "name": "成本", "cellData": {"0": {"0": {"v": 1}}}},
+		"s3": {"id": "s3", "name": "利润", "cellData": {}}}}`
+	// 服务器当前：新增工作表 s4，重命名 s2
+	theirs := `{"sheetOrder": ["s1", "s2", "s4"], "sheets": {
+		"s1": {"id": "s1", "name": "收入", "cellData": {}},
+		"s2": {"id": "s2", "name": "成本明细", "cellData": {"0": {"0": {"v": 1}}}},
+		"s4": {"id": "s4", "name": "预算", "cellData": {}}}}`
+
+	merged, conflicts, err := mergeUniverWorkbooks([]byte(base), []byte(mine), []byte(theirs))
+	if err != nil || len(conflicts) > 0 {
+		t.Fatalf("合并失败: %v %+v", err, conflicts)
+	}
+	expected := `{"sheetOrder": ["s2", "s4", "s3"], "sheets": {
+		"s2": {"id": "s2", "name": "成本明细", "cellData": {"0": {"0": {"v": 1}}}},
+		"s3": {"id": "s3", "name": "利润", "cellData": {}},
+		"s4": {"id": "s4", "name": "预算", "cellData": {}}}}`
+	if !sameWorkbookContent(merged, []byte(expected)) {
+		t.Fatalf("合并结果 = %s", merged)
+	}
+
+	// 删除的工作表被他人修改过：冲突
+	theirs = `{"sheetOrder": ["s1", "s2"], "sheets": {
+		"s1": {"id": "s1", "name": "收入", "cellData": {"0": {"0": {"v": 5}}}},
+		"s2": {"id": "s2", "name": "成本", "cellData": {"0": {"0": {"v": 1}}}}}}`
+	_, conflicts, err = mergeUniverWorkbooks([]byte(base), []byte(mine), []byte(theirs))
+	if err != nil || len(conflicts) != 1 || conflicts[0].SheetId != "s1" || conflicts[0].Property != "sheet" {
+		t.Fatalf("期望工作表 s1 冲突，实际: %v %+v", err, conflicts)
+	}
+}
@@ -1,6 +1,9 @@
 package sugar
 
-import "github.com/flipped-aurora/gin-vue-admin/server/service/system"
+import (
+	"github.com/flipped-aurora/gin-vue-admin/server/service/sugar/advanced_contribution_analyzer"
+	"github.com/flipped-aurora/gin-vue-admin/server/service/system"
+)
 
 // AnonymizationSession 匿名化会话，为单次请求保存状态
 type AnonymizationSession struct {
@@ -23,9 +26,12 @@ type ContributionItem struct {
 	DimensionValues     map[string]interface{} // 维度值组合，如 {"区域": "华东", "产品": "A产品"}
 	CurrentValue        float64                // 本期值
 	BaseValue           float64                // 基期值
-	ChangeValue         float64                // 变化值 (本期值 - 基期值)
+	ChangeValue         float64                // 变化值 (本期值 - 基期值)；结构/比率分解时为对整体比率变化的贡献
 	ContributionPercent float64                // 贡献度百分比
 	IsPositiveDriver    bool                   // 是否为正向驱动因子
+
+	Effects    map[string]float64                               // 非加法分解时各效应的取值，合计等于变化值
+	Components *advanced_contribution_analyzer.RatioObservation // 结构/比率分解时该组合的分子、分母
 }
 
 // DataValidationResult 数据验证结果
@@ -0,0 +1,84 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const collabRedisChannelPrefix = "sugar:collaboration:"
+
+// collabEnvelope 在服务实例之间转发的协作消息
+type collabEnvelope struct {
+	Instance string          `json:"instance"`          // 发出消息的实例，实例忽略自己发出的消息
+	FileId   string          `json:"fileId"`            // 工作簿文件ID
+	Exclude  string          `json:"exclude,omitempty"` // 不接收该消息的会话，一般是消息的提交者
+	Payload  json.RawMessage `json:"payload"`           // WorkbookCollabMessage
+}
+
+// collabBroker 协作消息的跨实例转发，本实例内的分发由 workbookCollabHub 完成
+type collabBroker interface {
+	// Publish 把消息转发给其他实例
+	Publish(ctx context.Context, envelope collabEnvelope) error
+	// Subscribe 订阅其他实例发出的某个工作簿的消息，返回取消订阅函数
+	Subscribe(fileId string, handler func(collabEnvelope)) (unsubscribe func())
+}
+
+// newCollabBroker 按配置创建协作消息转发方式，redis 不可用时退回到仅本进程分发
+func newCollabBroker() collabBroker {
+	config := global.GVA_CONFIG.Sugar.Collaboration
+	if config.Broker != "redis" {
+		return localCollabBroker{}
+	}
+	client := global.GVA_REDIS
+	if config.RedisName != "" {
+		client = global.GVA_REDISList[config.RedisName]
+	}
+	if client == nil {
+		global.GVA_LOG.Warn("实时协作配置为 redis 转发，但 redis 未初始化，仅在本进程内分发协作消息", zap.String("redisName", config.RedisName))
+		return localCollabBroker{}
+	}
+	return &redisCollabBroker{client: client}
+}
+
+// localCollabBroker 单实例部署时使用，不做跨实例转发
+type localCollabBroker struct{}
+
+func (localCollabBroker) Publish(context.Context, collabEnvelope) error { return nil }
+
+func (localCollabBroker) Subscribe(string, func(collabEnvelope)) func() { return func() {} }
+
+// redisCollabBroker 通过 Redis 发布订阅在多个实例之间转发协作消息，每个工作簿一个频道
+type redisCollabBroker struct {
+	client redis.UniversalClient
+}
+
+func (b *redisCollabBroker) Publish(ctx context.Context, envelope collabEnvelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, collabRedisChannelPrefix+envelope.FileId, data).Err()
+}
+
+func (b *redisCollabBroker) Subscribe(fileId string, handler func(collabEnvelope)) func() {
+	pubsub := b.client.Subscribe(context.Background(), collabRedisChannelPrefix+fileId)
+	go func() {
+		for message := range pubsub.Channel() {
+			var envelope collabEnvelope
+			if err := json.Unmarshal([]byte(message.Payload), &envelope); err != nil {
+				global.GVA_LOG.Warn("解析协作消息失败", zap.String("fileId", fileId), zap.Error(err))
+				continue
+			}
+			handler(envelope)
+		}
+	}()
+	return func() {
+		if err := pubsub.Close(); err != nil {
+			global.GVA_LOG.Warn("取消订阅协作消息失败", zap.String("fileId", fileId), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package sugar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// maxWorkbookDiffCells 版本对比最多返回的单元格变化明细条数，超出部分只计入统计
+const maxWorkbookDiffCells = 2000
+
+// univerCellPosition 单元格位置
+type univerCellPosition struct {
+	row int
+	col int
+}
+
+// decodeUniverWorkbook 解析 Univer 工作簿 JSON，数字保留原始精度以便精确比较
+func decodeUniverWorkbook(content []byte) (map[string]interface{}, error) {
+	workbook := map[string]interface{}{}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return workbook, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&workbook); err != nil {
+		return nil, errors.New("工作簿内容不是有效的JSON对象")
+	}
+	return workbook, nil
+}
+
+// diffUniverWorkbooks 按工作表和单元格对比两个 Univer 工作簿
+func diffUniverWorkbooks(before, after []byte) (*sugarRes.SugarFileVersionDiffResponse, error) {
+	oldBook, err := decodeUniverWorkbook(before)
+	if err != nil {
+		return nil, err
+	}
+	newBook, err := decodeUniverWorkbook(after)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &sugarRes.SugarFileVersionDiffResponse{
+		WorkbookChanges: changedKeys(oldBook, newBook, "sheets", "id", "rev"),
+		Sheets:          []sugarRes.WorkbookSheetDiff{},
+	}
+
+	oldSheets, newSheets := asObject(oldBook["sheets"]), asObject(newBook["sheets"])
+	remaining := maxWorkbookDiffCells
+	for _, sheetId := range orderedSheetIds(newBook, newSheets, oldBook, oldSheets) {
+		oldSheet, inOld := oldSheets[sheetId]
+		newSheet, inNew := newSheets[sheetId]
+		sheetDiff := diffUniverSheet(sheetId, asObject(oldSheet), asObject(newSheet), inOld, inNew, &remaining, &result.Truncated)
+		if sheetDiff == nil {
+			continue
+		}
+		switch sheetDiff.Status {
+		case sugarRes.WorkbookDiffAdded:
+			result.Summary.SheetsAdded++
+		case sugarRes.WorkbookDiffRemoved:
+			result.Summary.SheetsRemoved++
+		default:
+			result.Summary.SheetsModified++
+		}
+		result.Summary.CellsAdded += sheetDiff.CellsAdded
+		result.Summary.CellsRemoved += sheetDiff.CellsRemoved
+		result.Summary.CellsModified += sheetDiff.CellsModified
+		result.Sheets = append(result.Sheets, *sheetDiff)
+	}
+	return result, nil
+}
+
+// diffUniverSheet 对比单个工作表，没有任何变化时返回 nil
+func diffUniverSheet(sheetId string, oldSheet, newSheet map[string]interface{}, inOld, inNew bool, remaining *int, truncated *bool) *sugarRes.WorkbookSheetDiff {
+	sheetDiff := &sugarRes.WorkbookSheetDiff{
+		SheetId:         sheetId,
+		Status:          sugarRes.WorkbookDiffModified,
+		PropertyChanges: []string{},
+		Cells:           []sugarRes.WorkbookCellDiff{},
+	}
+	oldName, _ := oldSheet["name"].(string)
+	newName, _ := newSheet["name"].(string)
+	switch {
+	case !inOld:
+		sheetDiff.Status, sheetDiff.Name = sugarRes.WorkbookDiffAdded, newName
+	case !inNew:
+		sheetDiff.Status, sheetDiff.Name = sugarRes.WorkbookDiffRemoved, oldName
+	default:
+		sheetDiff.Name = newName
+		if oldName != newName {
+			sheetDiff.OldName = oldName
+		}
+		sheetDiff.PropertyChanges = changedKeys(oldSheet, newSheet, "cellData", "id", "name")
+	}
+
+	oldCells, newCells := univerCellMatrix(oldSheet["cellData"]), univerCellMatrix(newSheet["cellData"])
+	positions := make([]univerCellPosition, 0, len(oldCells)+len(newCells))
+	for position := range oldCells {
+		positions = append(positions, position)
+	}
+	for position := range newCells {
+		if _, ok := oldCells[position]; !ok {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].row != positions[j].row {
+			return positions[i].row < positions[j].row
+		}
+		return positions[i].col < positions[j].col
+	})
+
+	for _, position := range positions {
+		oldCell, inOldCells := oldCells[position]
+		newCell, inNewCells := newCells[position]
+		cellDiff := sugarRes.WorkbookCellDiff{
+			Cell:   cellReference(position.row, position.col),
+			Row:    position.row,
+			Col:    position.col,
+			Before: oldCell,
+			After:  newCell,
+		}
+		switch {
+		case !inOldCells:
+			cellDiff.Type = sugarRes.WorkbookDiffAdded
+			cellDiff.ChangedFields = changedKeys(nil, newCell)
+			sheetDiff.CellsAdded++
+		case !inNewCells:
+			cellDiff.Type = sugarRes.WorkbookDiffRemoved
+			cellDiff.ChangedFields = changedKeys(oldCell, nil)
+			sheetDiff.CellsRemoved++
+		default:
+			cellDiff.ChangedFields = changedKeys(oldCell, newCell)
+			if len(cellDiff.ChangedFields) == 0 {
+				continue
+			}
+			cellDiff.Type = sugarRes.WorkbookDiffModified
+			sheetDiff.CellsModified++
+		}
+		if *remaining <= 0 {
+			*truncated = true
+			continue
+		}
+		*remaining--
+		sheetDiff.Cells = append(sheetDiff.Cells, cellDiff)
+	}
+
+	if sheetDiff.Status == sugarRes.WorkbookDiffModified && sheetDiff.OldName == "" &&
+		len(sheetDiff.PropertyChanges) == 0 && sheetDiff.CellsAdded+sheetDiff.CellsRemoved+sheetDiff.CellsModified == 0 {
+		return nil
+	}
+	return sheetDiff
+}
+
+// orderedSheetIds 按新版本的 sheetOrder 排列工作表，其后为只存在于旧版本的工作表
+func orderedSheetIds(newBook, newSheets, oldBook, oldSheets map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var ids []string
+	appendIds := func(book, sheets map[string]interface{}) {
+		if order, ok := book["sheetOrder"].([]interface{}); ok {
+			for _, item := range order {
+				if id, ok := item.(string); ok && !seen[id] {
+					if _, exists := sheets[id]; exists {
+						seen[id] = true
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+		// 未出现在 sheetOrder 中的工作表按ID排序追加
+		var rest []string
+		for id := range sheets {
+			if !seen[id] {
+				rest = append(rest, id)
+			}
+		}
+		sort.Strings(rest)
+		for _, id := range rest {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	appendIds(newBook, newSheets)
+	appendIds(oldBook, oldSheets)
+	return ids
+}
+
+// univerCellMatrix 将 cellData 展开为 位置 -> 单元格，忽略空单元格
+// cellData 通常为 {"行": {"列": 单元格}}，也兼容以数组表示的行列
+func univerCellMatrix(cellData interface{}) map[univerCellPosition]map[string]interface{} {
+	cells := map[univerCellPosition]map[string]interface{}{}
+	forEachIndexed(cellData, func(row int, rowData interface{}) {
+		forEachIndexed(rowData, func(col int, cell interface{}) {
+			if cellObject := asObject(cell); !isEmptyCell(cellObject) {
+				cells[univerCellPosition{row: row, col: col}] = cellObject
+			}
+		})
+	})
+	return cells
+}
+
+// forEachIndexed 遍历以数字为键的对象或数组
+func forEachIndexed(value interface{}, fn func(index int, item interface{})) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if index, err := strconv.Atoi(key); err == nil && index >= 0 {
+				fn(index, item)
+			}
+		}
+	case []interface{}:
+		for index, item := range typed {
+			if item != nil {
+				fn(index, item)
+			}
+		}
+	}
+}
+
+// isEmptyCell 没有任何非空字段的单元格视为不存在
+func isEmptyCell(cell map[string]interface{}) bool {
+	for _, value := range cell {
+		if value != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// changedKeys 返回两个对象中取值不同的键（按字母排序），ignore 中的键不参与比较
+func changedKeys(before, after map[string]interface{}, ignore ...string) []string {
+	ignored := map[string]bool{}
+	for _, key := range ignore {
+		ignored[key] = true
+	}
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	changed := []string{}
+	for key := range keys {
+		if ignored[key] {
+			continue
+		}
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// asObject 将任意值断言为 JSON 对象，非对象时返回空对象
+func asObject(value interface{}) map[string]interface{} {
+	if object, ok := value.(map[string]interface{}); ok {
+		return object
+	}
+	return map[string]interface{}{}
+}
+
+// cellReference 将从0开始的行列号转换为 A1 形式的单元格地址
+func cellReference(row, col int) string {
+	column := ""
+	for n := col + 1; n > 0; n = (n - 1) / 26 {
+		column = string(rune('A'+(n-1)%26)) + column
+	}
+	return fmt.Sprintf("%s%d", column, row+1)
+}
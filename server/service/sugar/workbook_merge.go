@@ -0,0 +1,275 @@
+package sugar
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	sugarRes "github.com/flipped-aurora/gin-vue-admin/server/model/sugar/response"
+)
+
+// absentValue 表示对象中不存在的键，参与三方比较时与任何实际值都不相等
+type absentValue struct{}
+
+var absent = absentValue{}
+
+// lookupValue 读取对象中的键，不存在时返回 absent
+func lookupValue(object map[string]interface{}, key string) interface{} {
+	if object == nil {
+		return absent
+	}
+	if value, ok := object[key]; ok {
+		return value
+	}
+	return absent
+}
+
+// mergeValue 三方合并单个值：只有一方相对 base 修改时取修改方，双方改成相同结果时取该结果，否则冲突
+func mergeValue(base, mine, theirs interface{}) (interface{}, bool) {
+	switch {
+	case reflect.DeepEqual(mine, base):
+		return theirs, true
+	case reflect.DeepEqual(theirs, base), reflect.DeepEqual(mine, theirs):
+		return mine, true
+	}
+	return theirs, false
+}
+
+// workbookMerger 以 base 为共同祖先，合并本次提交（mine）和服务器当前内容（theirs）
+// 冲突位置保留服务器当前内容并记录冲突
+type workbookMerger struct {
+	conflicts []sugarRes.WorkbookMergeConflict
+}
+
+// mergeUniverWorkbooks 对 Univer 工作簿做三方合并，双方修改不重叠时返回合并结果，否则返回冲突列表
+// 合并粒度：工作簿级属性、工作表（新增/删除）、工作表属性和单个单元格
+func mergeUniverWorkbooks(base, mine, theirs []byte) ([]byte, []sugarRes.WorkbookMergeConflict, error) {
+	baseBook, err := decodeUniverWorkbook(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	mineBook, err := decodeUniverWorkbook(mine)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsBook, err := decodeUniverWorkbook(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merger := &workbookMerger{}
+	merged := merger.mergeObject(baseBook, mineBook, theirsBook, func(key string) sugarRes.WorkbookMergeConflict {
+		return sugarRes.WorkbookMergeConflict{Property: key}
+	}, "sheets", "sheetOrder")
+
+	sheets := merger.mergeSheets(asObject(baseBook["sheets"]), asObject(mineBook["sheets"]), asObject(theirsBook["sheets"]))
+	merged["sheets"] = sheets
+	merged["sheetOrder"] = mergeSheetOrder(stringList(baseBook["sheetOrder"]), stringList(mineBook["sheetOrder"]), stringList(theirsBook["sheetOrder"]), sheets)
+
+	if len(merger.conflicts) > 0 {
+		return nil, merger.conflicts, nil
+	}
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, nil, nil
+}
+
+// mergeObject 逐键合并对象，skip 中的键由调用方单独处理
+func (m *workbookMerger) mergeObject(base, mine, theirs map[string]interface{}, conflict func(key string) sugarRes.WorkbookMergeConflict, skip ...string) map[string]interface{} {
+	skipped := map[string]bool{}
+	for _, key := range skip {
+		skipped[key] = true
+	}
+	merged := map[string]interface{}{}
+	for _, key := range unionKeys(base, mine, theirs) {
+		if skipped[key] {
+			continue
+		}
+		value, ok := mergeValue(lookupValue(base, key), lookupValue(mine, key), lookupValue(theirs, key))
+		if !ok {
+			m.conflicts = append(m.conflicts, conflict(key))
+		}
+		if value != absent {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// mergeSheets 合并工作表：三方都存在的工作表逐属性、逐单元格合并，新增和删除按整表合并
+func (m *workbookMerger) mergeSheets(base, mine, theirs map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, sheetId := range unionKeys(base, mine, theirs) {
+		baseSheet, mineSheet, theirsSheet := lookupValue(base, sheetId), lookupValue(mine, sheetId), lookupValue(theirs, sheetId)
+		if baseSheet != absent && mineSheet != absent && theirsSheet != absent {
+			merged[sheetId] = m.mergeSheet(sheetId, asObject(baseSheet), asObject(mineSheet), asObject(theirsSheet))
+			continue
+		}
+		value, ok := mergeValue(baseSheet, mineSheet, theirsSheet)
+		if !ok {
+			m.conflicts = append(m.conflicts, sugarRes.WorkbookMergeConflict{
+				SheetId:   sheetId,
+				SheetName: sheetName(asObject(baseSheet), asObject(mineSheet), asObject(theirsSheet)),
+				Property:  "sheet",
+			})
+		}
+		if value != absent {
+			merged[sheetId] = value
+		}
+	}
+	return merged
+}
+
+// mergeSheet 合并三方都存在的工作表
+func (m *workbookMerger) mergeSheet(sheetId string, base, mine, theirs map[string]interface{}) map[string]interface{} {
+	name := sheetName(base, mine, theirs)
+	merged := m.mergeObject(base, mine, theirs, func(key string) sugarRes.WorkbookMergeConflict {
+		return sugarRes.WorkbookMergeConflict{SheetId: sheetId, SheetName: name, Property: key}
+	}, "cellData")
+
+	baseCells, mineCells, theirsCells := univerCellMatrix(base["cellData"]), univerCellMatrix(mine["cellData"]), univerCellMatrix(theirs["cellData"])
+	positions := map[univerCellPosition]bool{}
+	for _, cells := range []map[univerCellPosition]map[string]interface{}{baseCells, mineCells, theirsCells} {
+		for position := range cells {
+			positions[position] = true
+		}
+	}
+	ordered := make([]univerCellPosition, 0, len(positions))
+	for position := range positions {
+		ordered = append(ordered, position)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].row != ordered[j].row {
+			return ordered[i].row < ordered[j].row
+		}
+		return ordered[i].col < ordered[j].col
+	})
+
+	cellData := map[string]interface{}{}
+	for _, position := range ordered {
+		value, ok := mergeValue(cellOrAbsent(baseCells, position), cellOrAbsent(mineCells, position), cellOrAbsent(theirsCells, position))
+		if !ok {
+			m.conflicts = append(m.conflicts, sugarRes.WorkbookMergeConflict{
+				SheetId:   sheetId,
+				SheetName: name,
+				Cell:      cellReference(position.row, position.col),
+			})
+		}
+		if value == absent {
+			continue
+		}
+		rowKey := strconv.Itoa(position.row)
+		row, ok := cellData[rowKey].(map[string]interface{})
+		if !ok {
+			row = map[string]interface{}{}
+			cellData[rowKey] = row
+		}
+		row[strconv.Itoa(position.col)] = value
+	}
+	if len(cellData) > 0 || lookupValue(theirs, "cellData") != absent || lookupValue(mine, "cellData") != absent {
+		merged["cellData"] = cellData
+	}
+	return merged
+}
+
+// mergeSheetOrder 合并工作表顺序：一方未调整时取另一方；双方都调整时以服务器顺序为准，
+// 再删除本次提交删掉的工作表、追加本次提交新增的工作表，最后与合并后的工作表集合对齐
+func mergeSheetOrder(base, mine, theirs []string, sheets map[string]interface{}) []interface{} {
+	var order []string
+	switch {
+	case reflect.DeepEqual(mine, base):
+		order = theirs
+	case reflect.DeepEqual(theirs, base):
+		order = mine
+	default:
+		inBase, inMine, inTheirs := stringSet(base), stringSet(mine), stringSet(theirs)
+		for _, id := range theirs {
+			if inBase[id] && !inMine[id] {
+				continue
+			}
+			order = append(order, id)
+		}
+		for _, id := range mine {
+			if !inBase[id] && !inTheirs[id] {
+				order = append(order, id)
+			}
+		}
+	}
+
+	result := []interface{}{}
+	seen := map[string]bool{}
+	for _, id := range order {
+		if _, ok := sheets[id]; ok && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	var rest []string
+	for id := range sheets {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	for _, id := range rest {
+		result = append(result, id)
+	}
+	return result
+}
+
+// cellOrAbsent 读取单元格，空单元格视为不存在
+func cellOrAbsent(cells map[univerCellPosition]map[string]interface{}, position univerCellPosition) interface{} {
+	if cell, ok := cells[position]; ok {
+		return cell
+	}
+	return absent
+}
+
+// sheetName 取工作表名称，优先服务器当前名称
+func sheetName(base, mine, theirs map[string]interface{}) string {
+	for _, sheet := range []map[string]interface{}{theirs, mine, base} {
+		if name, ok := sheet["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// unionKeys 返回多个对象的键并集，按字母排序
+func unionKeys(objects ...map[string]interface{}) []string {
+	set := map[string]bool{}
+	for _, object := range objects {
+		for key := range object {
+			set[key] = true
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// stringList 将 JSON 数组转换为字符串列表，忽略非字符串元素
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if text, ok := item.(string); ok {
+			list = append(list, text)
+		}
+	}
+	return list
+}
+
+func stringSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
@@ -19,10 +19,13 @@ type SysLLMService struct{}
 
 // LLMConfig 定义了调用LLM所需的配置
 type LLMConfig struct {
-	BaseURL             string `json:"baseUrl"`              // OpenAI兼容的API地址，例如 https://api.openai.com/v1
-	Token               string `json:"token"`                // API密钥或访问令牌
-	ModelName           string `json:"modelName"`            // 要使用的模型名称
-	EnableAnonymization bool   `json:"enable_anonymization"` //
+	BaseURL          string `json:"baseUrl"`           // OpenAI兼容的API地址，例如 https://api.openai.com/v1
+	Token            string `json:"token"`             // API密钥或访问令牌
+	ModelName        string `json:"modelName"`         // 要使用的模型名称
+	StructuredOutput string `json:"structured_output"` // 结构化输出能力: json_schema, json_object, 留空表示仅通过提示词约束
+
+	Temperature *float64 `json:"temperature,omitempty"` // 采样温度，为空时使用模型默认值
+	MaxTokens   int      `json:"max_tokens,omitempty"`  // 最大输出Token数，0表示使用模型默认值
 }
 
 // ChatMessage 聊天消息结构
@@ -44,9 +47,11 @@ type OpenAIRequest struct {
 	Messages    []ChatMessage `json:"messages"`
 	Tools       []OpenAITool  `json:"tools,omitempty"`
 	ToolChoice  interface{}   `json:"tool_choice,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+
+	ResponseFormat interface{} `json:"response_format,omitempty"`
 }
 
 // OpenAI兼容的工具结构
@@ -94,9 +99,35 @@ type OpenAIToolCallFunction struct {
 	Arguments string `json:"arguments"`
 }
 
+// 结构化输出模式
+const (
+	StructuredOutputJSONSchema = "json_schema"
+	StructuredOutputJSONObject = "json_object"
+)
+
 // ChatWithTools 发起一个支持工具调用的会话（直接使用OpenAI兼容接口）
 func (s *SysLLMService) ChatWithTools(ctx context.Context, config LLMConfig, messages []ChatMessage, tools []ToolDefinition) (string, error) {
-	return s.chatWithOpenAI(ctx, config, messages, tools)
+	return s.chatWithOpenAI(ctx, config, messages, tools, nil)
+}
+
+// ChatStructured 发起一个要求返回JSON的会话
+// 根据配置的 StructuredOutput 能力传递 response_format，不支持时仅依赖提示词约束，调用方需自行校验返回内容
+func (s *SysLLMService) ChatStructured(ctx context.Context, config LLMConfig, messages []ChatMessage, schemaName string, schema map[string]interface{}) (string, error) {
+	var responseFormat interface{}
+	switch config.StructuredOutput {
+	case StructuredOutputJSONSchema:
+		responseFormat = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   schemaName,
+				"schema": schema,
+				"strict": true,
+			},
+		}
+	case StructuredOutputJSONObject:
+		responseFormat = map[string]interface{}{"type": "json_object"}
+	}
+	return s.chatWithOpenAI(ctx, config, messages, nil, responseFormat)
 }
 
 // ChatSimple 发起一个简单的聊天会话（不带工具）
@@ -105,12 +136,15 @@ func (s *SysLLMService) ChatSimple(ctx context.Context, config LLMConfig, messag
 }
 
 // chatWithOpenAI 使用OpenAI兼容接口进行聊天
-func (s *SysLLMService) chatWithOpenAI(ctx context.Context, config LLMConfig, messages []ChatMessage, tools []ToolDefinition) (string, error) {
+func (s *SysLLMService) chatWithOpenAI(ctx context.Context, config LLMConfig, messages []ChatMessage, tools []ToolDefinition, responseFormat interface{}) (string, error) {
 	// 构造请求
 	request := OpenAIRequest{
-		Model:    config.ModelName,
-		Messages: messages,
-		Tools:    s.convertToolsToOpenAI(tools),
+		Model:          config.ModelName,
+		Messages:       messages,
+		Tools:          s.convertToolsToOpenAI(tools),
+		ResponseFormat: responseFormat,
+		Temperature:    config.Temperature,
+		MaxTokens:      config.MaxTokens,
 	}
 
 	// 如果有工具，设置tool_choice为auto
@@ -221,9 +255,10 @@ func (s *SysLLMService) ParseLLMConfigFromJSON(configJSON string) (*LLMConfig, e
 func (s *SysLLMService) GetDefaultLLMConfig() *LLMConfig {
 	config := global.GVA_CONFIG.LLM.OpenAI
 	return &LLMConfig{
-		BaseURL:   config.BaseURL,
-		Token:     config.Token,
-		ModelName: config.ModelName,
+		BaseURL:          config.BaseURL,
+		Token:            config.Token,
+		ModelName:        config.ModelName,
+		StructuredOutput: config.StructuredOutput,
 	}
 }
 
@@ -0,0 +1,22 @@
+package task
+
+import (
+	"errors"
+
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"gorm.io/gorm"
+)
+
+//@function: BackfillAnalysisSemantics
+//@description: 为包含年初/年末金额字段但未声明期初/期末指标对的语义模型补充分析口径中的 balancePairs
+//@param: db(数据库对象) *gorm.DB
+//@return: error
+
+func BackfillAnalysisSemantics(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db Cannot be empty")
+	}
+
+	_, err := sugarService.BackfillBalancePairs(db)
+	return err
+}
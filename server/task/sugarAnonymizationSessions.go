@@ -0,0 +1,35 @@
+package task
+
+import (
+	"errors"
+	"time"
+
+	"github.com/flipped-aurora/gin-vue-admin/server/global"
+	"github.com/flipped-aurora/gin-vue-admin/server/model/sugar"
+	"gorm.io/gorm"
+)
+
+//@function: PurgeAnonymizationSessions
+//@description: 销毁超过保留期限的匿名化映射（清空加密映射，保留审计元数据），并删除超过元数据保留期限的会话记录
+//@param: db(数据库对象) *gorm.DB
+//@return: error
+
+func PurgeAnonymizationSessions(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db Cannot be empty")
+	}
+
+	now := time.Now()
+	err := db.Model(&sugar.SugarAnonymizationSessions{}).
+		Where("purged_at IS NULL AND expires_at <= ?", now).
+		Updates(map[string]interface{}{"encrypted_mapping": nil, "purged_at": now}).Error
+	if err != nil {
+		return err
+	}
+
+	if days := global.GVA_CONFIG.Sugar.Anonymization.MetadataRetentionDays; days > 0 {
+		return db.Where("purged_at IS NOT NULL AND created_at < ?", now.AddDate(0, 0, -days)).
+			Delete(&sugar.SugarAnonymizationSessions{}).Error
+	}
+	return nil
+}
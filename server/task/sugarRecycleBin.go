@@ -0,0 +1,23 @@
+package task
+
+import (
+	"errors"
+	"time"
+
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"gorm.io/gorm"
+)
+
+//@function: PurgeSugarRecycleBin
+//@description: 彻底删除在回收站中超过保留期限（sugar.recycle-bin.retention-days）的文件和文件夹
+//@param: db(数据库对象) *gorm.DB
+//@return: error
+
+func PurgeSugarRecycleBin(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db Cannot be empty")
+	}
+
+	_, err := sugarService.PurgeExpiredWorkspaces(db, time.Now())
+	return err
+}
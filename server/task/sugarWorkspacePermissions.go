@@ -0,0 +1,22 @@
+package task
+
+import (
+	"errors"
+
+	sugarService "github.com/flipped-aurora/gin-vue-admin/server/service/sugar"
+	"gorm.io/gorm"
+)
+
+//@function: MigrateCommenterGrants
+//@description: 将旧版本的评论授权迁移为查看授权，评论级别已移除
+//@param: db(数据库对象) *gorm.DB
+//@return: error
+
+func MigrateCommenterGrants(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db Cannot be empty")
+	}
+
+	_, err := sugarService.MigrateCommenterGrants(db)
+	return err
+}
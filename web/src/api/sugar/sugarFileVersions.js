@@ -0,0 +1,81 @@
+import service from '@/utils/request'
+
+// @Tags SugarFileVersions
+// @Summary 分页获取工作簿历史版本
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarFileVersionsSearch true "文件ID及分页信息"
+// @Success 200 {object} response.Response{data=response.PageResult,msg=string} "获取成功"
+// @Router /sugarFileVersions/getFileVersionList [get]
+export const getFileVersionList = (params) => {
+  return service({
+    url: '/sugarFileVersions/getFileVersionList',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarFileVersions
+// @Summary 获取历史版本详情
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "历史版本ID"
+// @Success 200 {object} response.Response{data=object,msg=string} "查询成功"
+// @Router /sugarFileVersions/findFileVersion [get]
+export const findFileVersion = (params) => {
+  return service({
+    url: '/sugarFileVersions/findFileVersion',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarFileVersions
+// @Summary 对比两个历史版本，toVersion 为0时与当前内容对比
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarFileVersionDiffRequest true "文件ID及版本号"
+// @Success 200 {object} response.Response{data=object,msg=string} "对比成功"
+// @Router /sugarFileVersions/diffFileVersions [get]
+export const diffFileVersions = (params) => {
+  return service({
+    url: '/sugarFileVersions/diffFileVersions',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarFileVersions
+// @Summary 恢复历史版本
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFileVersionRestoreRequest true "历史版本ID"
+// @Success 200 {object} response.Response{data=object,msg=string} "恢复成功"
+// @Router /sugarFileVersions/restoreFileVersion [post]
+export const restoreFileVersion = (data) => {
+  return service({
+    url: '/sugarFileVersions/restoreFileVersion',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarFileVersions
+// @Summary 命名或置顶历史版本
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarFileVersionUpdateRequest true "版本名称及置顶状态"
+// @Success 200 {object} response.Response{msg=string} "更新成功"
+// @Router /sugarFileVersions/updateFileVersion [put]
+export const updateFileVersion = (data) => {
+  return service({
+    url: '/sugarFileVersions/updateFileVersion',
+    method: 'put',
+    data
+  })
+}
@@ -48,6 +48,22 @@ export const renameItem = (data) => {
   })
 }
 
+// @Tags SugarFolders
+// @Summary 复制文件夹或文件，文件夹连同其下内容一起复制
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body object true "复制数据"
+// @Success 200 {object} response.Response{data=object,msg=string} "复制成功"
+// @Router /sugarFolders/copy [post]
+export const copyItem = (data) => {
+  return service({
+    url: '/sugarFolders/copy',
+    method: 'post',
+    data
+  })
+}
+
 // @Tags SugarFolders
 // @Summary 移动文件夹或文件
 // @Security ApiKeyAuth
@@ -0,0 +1,49 @@
+import service from '@/utils/request'
+
+// @Tags SugarRecycleBin
+// @Summary 分页获取团队回收站
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarRecycleBinSearch true "团队ID及分页参数"
+// @Success 200 {object} response.Response{data=response.PageResult{list=[]sugarRes.SugarRecycleBinItem},msg=string} "获取成功"
+// @Router /sugarRecycleBin/getRecycleBinList [get]
+export const getRecycleBinList = (params) => {
+  return service({
+    url: '/sugarRecycleBin/getRecycleBinList',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarRecycleBin
+// @Summary 从回收站恢复项目，conflictStrategy 为 rename 时同名冲突自动重命名
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarRecycleBinRestoreRequest true "项目ID及冲突处理方式"
+// @Success 200 {object} response.Response{data=sugarRes.SugarRecycleBinRestoreResponse,msg=string} "恢复成功"
+// @Router /sugarRecycleBin/restoreItem [post]
+export const restoreRecycleBinItem = (data) => {
+  return service({
+    url: '/sugarRecycleBin/restoreItem',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarRecycleBin
+// @Summary 彻底删除回收站中的项目
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarRecycleBinPurgeRequest true "项目ID"
+// @Success 200 {object} response.Response{data=int,msg=string} "删除成功"
+// @Router /sugarRecycleBin/purgeItem [delete]
+export const purgeRecycleBinItem = (params) => {
+  return service({
+    url: '/sugarRecycleBin/purgeItem',
+    method: 'delete',
+    params
+  })
+}
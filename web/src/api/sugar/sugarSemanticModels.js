@@ -55,11 +55,12 @@ export const deleteSugarSemanticModelsByIds = (params) => {
 // @Param data body model.SugarSemanticModels true "更新Sugar指标语义表"
 // @Success 200 {string} string "{"success":true,"data":{},"msg":"更新成功"}"
 // @Router /sugarSemanticModels/updateSugarSemanticModels [put]
-export const updateSugarSemanticModels = (data) => {
+export const updateSugarSemanticModels = (data, params) => {
   return service({
     url: '/sugarSemanticModels/updateSugarSemanticModels',
     method: 'put',
-    data
+    data,
+    params
   })
 }
 
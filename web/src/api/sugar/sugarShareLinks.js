@@ -0,0 +1,79 @@
+import service from '@/utils/request'
+
+// @Tags SugarShareLinks
+// @Summary 为工作簿创建公开分享链接
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkCreateRequest true "文件ID、权限级别及链接选项"
+// @Success 200 {object} response.Response{data=sugar.SugarShareLinks,msg=string} "创建成功"
+// @Router /sugarShareLinks/createShareLink [post]
+export const createShareLink = (data) => {
+  return service({
+    url: '/sugarShareLinks/createShareLink',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarShareLinks
+// @Summary 关闭分享链接
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "链接ID"
+// @Success 200 {object} response.Response{msg=string} "关闭成功"
+// @Router /sugarShareLinks/revokeShareLink [delete]
+export const revokeShareLink = (params) => {
+  return service({
+    url: '/sugarShareLinks/revokeShareLink',
+    method: 'delete',
+    params
+  })
+}
+
+// @Tags SugarShareLinks
+// @Summary 获取工作簿的分享链接列表
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarShareLinkSearch true "文件ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarShareLinkItem,msg=string} "获取成功"
+// @Router /sugarShareLinks/getShareLinkList [get]
+export const getShareLinkList = (params) => {
+  return service({
+    url: '/sugarShareLinks/getShareLinkList',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarShareLinks
+// @Summary 通过分享链接打开工作簿，无需登录
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkOpenRequest true "链接令牌及访问密码"
+// @Success 200 {object} response.Response{data=sugarRes.SugarSharedWorkbookResponse,msg=string} "打开成功"
+// @Router /sugarShareLinks/openSharedWorkbook [post]
+export const openSharedWorkbook = (data) => {
+  return service({
+    url: '/sugarShareLinks/openSharedWorkbook',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarShareLinks
+// @Summary 通过可编辑的分享链接保存工作簿，无需登录
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarShareLinkSaveRequest true "会话令牌及工作簿内容"
+// @Success 200 {object} response.Response{data=object,msg=string} "保存成功"
+// @Router /sugarShareLinks/saveSharedWorkbook [put]
+export const saveSharedWorkbook = (data) => {
+  return service({
+    url: '/sugarShareLinks/saveSharedWorkbook',
+    method: 'put',
+    data
+  })
+}
@@ -95,6 +95,22 @@ export const getSugarTeamsList = (params) => {
   })
 }
 
+// @Tags SugarTeams
+// @Summary 获取当前用户在团队中的角色及允许的操作
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param teamId query string true "团队ID"
+// @Success 200 {string} string "{"success":true,"data":{"role":"editor","permissions":{}},"msg":"获取成功"}"
+// @Router /sugarTeams/getMyTeamPermissions [get]
+export const getMyTeamPermissions = (params) => {
+  return service({
+    url: '/sugarTeams/getMyTeamPermissions',
+    method: 'get',
+    params
+  })
+}
+
 // @Tags SugarTeams
 // @Summary 不需要鉴权的团队信息表接口
 // @Accept application/json
@@ -0,0 +1,14 @@
+import { useUserStore } from '@/pinia/modules/user'
+
+// @Tags SugarWorkbookCollaboration
+// @Summary 建立工作簿实时协作连接，浏览器无法为 WebSocket 设置请求头，令牌通过查询参数 x-token 传递
+// @Security ApiKeyAuth
+// @Param id query string true "工作簿文件ID"
+// @Success 101 {string} string "切换为 WebSocket 协议"
+// @Router /sugarWorkbookCollaboration/connect [get]
+export const connectWorkbookCollaboration = (id) => {
+  const userStore = useUserStore()
+  const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+  const params = new URLSearchParams({ id, 'x-token': userStore.token })
+  return new WebSocket(`${protocol}//${window.location.host}${import.meta.env.VITE_BASE_API}/sugarWorkbookCollaboration/connect?${params}`)
+}
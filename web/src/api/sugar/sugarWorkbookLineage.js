@@ -0,0 +1,65 @@
+import service from '@/utils/request'
+
+// @Tags SugarWorkbookLineage
+// @Summary 查询依赖语义模型的工作簿，可只分析指定字段
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "语义模型ID及可选的字段"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getModelImpact [get]
+export const getModelImpact = (params) => {
+  return service({
+    url: '/sugarWorkbookLineage/getModelImpact',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkbookLineage
+// @Summary 查询使用数据库连接的语义模型，以及依赖这些模型的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "数据库连接ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getConnectionImpact [get]
+export const getConnectionImpact = (params) => {
+  return service({
+    url: '/sugarWorkbookLineage/getConnectionImpact',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkbookLineage
+// @Summary 查询通过 AI.FETCH 引用智能体的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarLineageImpactRequest true "智能体ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarLineageImpact,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getAgentImpact [get]
+export const getAgentImpact = (params) => {
+  return service({
+    url: '/sugarWorkbookLineage/getAgentImpact',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkbookLineage
+// @Summary 查询工作簿公式引用的语义模型、字段和智能体，并标出已不存在的对象和字段
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookDependenciesRequest true "工作簿ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkbookDependency,msg=string} "获取成功"
+// @Router /sugarWorkbookLineage/getWorkbookDependencies [get]
+export const getWorkbookDependencies = (params) => {
+  return service({
+    url: '/sugarWorkbookLineage/getWorkbookDependencies',
+    method: 'get',
+    params
+  })
+}
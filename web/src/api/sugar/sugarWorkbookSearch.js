@@ -0,0 +1,33 @@
+import service from '@/utils/request'
+
+// @Tags SugarWorkbookSearch
+// @Summary 搜索工作簿：按关键词，或查找引用了指定语义模型、智能体的工作簿
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookSearchRequest true "关键词、模型或智能体名称及分页参数"
+// @Success 200 {object} response.Response{data=response.PageResult{list=[]sugarRes.SugarWorkbookSearchItem},msg=string} "获取成功"
+// @Router /sugarWorkbookSearch/searchWorkbooks [get]
+export const searchWorkbooks = (params) => {
+  return service({
+    url: '/sugarWorkbookSearch/searchWorkbooks',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkbookSearch
+// @Summary 重建团队全部工作簿的搜索索引和引用关系
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookSearchRebuildRequest true "团队ID"
+// @Success 200 {object} response.Response{data=int,msg=string} "重建成功"
+// @Router /sugarWorkbookSearch/rebuildSearchIndex [post]
+export const rebuildSearchIndex = (data) => {
+  return service({
+    url: '/sugarWorkbookSearch/rebuildSearchIndex',
+    method: 'post',
+    data
+  })
+}
@@ -0,0 +1,65 @@
+import service from '@/utils/request'
+
+// @Tags SugarWorkbookTemplates
+// @Summary 由工作簿生成团队模板
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookTemplateCreateRequest true "工作簿ID、模板名称及参数说明"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkbookTemplates,msg=string} "创建成功"
+// @Router /sugarWorkbookTemplates/createTemplate [post]
+export const createWorkbookTemplate = (data) => {
+  return service({
+    url: '/sugarWorkbookTemplates/createTemplate',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarWorkbookTemplates
+// @Summary 获取团队模板库
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookTemplateSearch true "团队ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkbookTemplateItem,msg=string} "获取成功"
+// @Router /sugarWorkbookTemplates/getTemplateList [get]
+export const getWorkbookTemplateList = (params) => {
+  return service({
+    url: '/sugarWorkbookTemplates/getTemplateList',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkbookTemplates
+// @Summary 删除模板
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkbookTemplateDeleteRequest true "模板ID"
+// @Success 200 {object} response.Response{msg=string} "删除成功"
+// @Router /sugarWorkbookTemplates/deleteTemplate [delete]
+export const deleteWorkbookTemplate = (params) => {
+  return service({
+    url: '/sugarWorkbookTemplates/deleteTemplate',
+    method: 'delete',
+    params
+  })
+}
+
+// @Tags SugarWorkbookTemplates
+// @Summary 由模板新建工作簿，parameters 中的取值代入 SUGAR 公式
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkbookTemplateInstantiateRequest true "模板ID、新工作簿位置及参数取值"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkspaces,msg=string} "创建成功"
+// @Router /sugarWorkbookTemplates/instantiateTemplate [post]
+export const instantiateWorkbookTemplate = (data) => {
+  return service({
+    url: '/sugarWorkbookTemplates/instantiateTemplate',
+    method: 'post',
+    data
+  })
+}
@@ -0,0 +1,65 @@
+import service from '@/utils/request'
+
+// @Tags SugarWorkspacePermissions
+// @Summary 将文件或文件夹分享给用户或团队
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data body sugarReq.SugarWorkspacePermissionGrantRequest true "文件ID、授权对象及权限级别"
+// @Success 200 {object} response.Response{data=sugar.SugarWorkspacePermissions,msg=string} "授权成功"
+// @Router /sugarWorkspacePermissions/grantWorkspacePermission [post]
+export const grantWorkspacePermission = (data) => {
+  return service({
+    url: '/sugarWorkspacePermissions/grantWorkspacePermission',
+    method: 'post',
+    data
+  })
+}
+
+// @Tags SugarWorkspacePermissions
+// @Summary 撤销文件或文件夹授权
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "授权ID"
+// @Success 200 {object} response.Response{msg=string} "撤销成功"
+// @Router /sugarWorkspacePermissions/revokeWorkspacePermission [delete]
+export const revokeWorkspacePermission = (params) => {
+  return service({
+    url: '/sugarWorkspacePermissions/revokeWorkspacePermission',
+    method: 'delete',
+    params
+  })
+}
+
+// @Tags SugarWorkspacePermissions
+// @Summary 获取文件或文件夹自身及继承的授权
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkspacePermissionSearch true "文件或文件夹ID"
+// @Success 200 {object} response.Response{data=[]sugarRes.SugarWorkspaceGrantItem,msg=string} "获取成功"
+// @Router /sugarWorkspacePermissions/getWorkspacePermissionList [get]
+export const getWorkspacePermissionList = (params) => {
+  return service({
+    url: '/sugarWorkspacePermissions/getWorkspacePermissionList',
+    method: 'get',
+    params
+  })
+}
+
+// @Tags SugarWorkspacePermissions
+// @Summary 获取能访问文件或文件夹的全部用户和团队及其允许的操作
+// @Security ApiKeyAuth
+// @Accept application/json
+// @Produce application/json
+// @Param data query sugarReq.SugarWorkspacePermissionSearch true "文件或文件夹ID"
+// @Success 200 {object} response.Response{data=sugarRes.SugarWorkspaceAccessResponse,msg=string} "获取成功"
+// @Router /sugarWorkspacePermissions/getWorkspaceAccess [get]
+export const getWorkspaceAccess = (params) => {
+  return service({
+    url: '/sugarWorkspacePermissions/getWorkspaceAccess',
+    method: 'get',
+    params
+  })
+}
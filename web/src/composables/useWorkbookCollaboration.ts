@@ -0,0 +1,349 @@
+import { ref } from 'vue'
+import { ElMessage } from 'element-plus'
+// @ts-ignore
+import { connectWorkbookCollaboration } from '@/api/sugar/sugarWorkbookCollaboration'
+
+/**
+ * 在线协作者
+ */
+export interface CollaboratorPresence {
+  sessionId: string
+  userId: string
+  userName: string
+  selection?: { sheetId: string; range: any }
+}
+
+export interface WorkbookCollaborationOptions {
+  fileId: string
+  univerAPI: any
+  // 已加载内容包含的操作修订号，连接后从这里开始同步
+  snapshotRevision: number
+  // 已加载内容的修订号，提交快照时携带，内容已被整体保存或恢复历史版本覆盖时服务器拒绝快照
+  documentRevision: number
+  // 获取当前工作簿内容，用于提交快照
+  getSnapshot: () => any
+  // 需要的操作已压缩到内容快照，重新加载工作簿
+  onReload: () => Promise<void> | void
+  // 内容快照已保存，documentRevision 为新的内容修订号
+  onSnapshotSaved?: (documentRevision: number) => void
+}
+
+// Univer 命令类型：1 为操作（选区等，仅影响本地视图），2 为变更（修改文档内容）
+const COMMAND_TYPE_OPERATION = 1
+const COMMAND_TYPE_MUTATION = 2
+const SET_SELECTIONS_OPERATION = 'sheet.operation.set-selections'
+const RECONNECT_DELAY = 3000
+const PRESENCE_THROTTLE = 200
+const SNAPSHOT_SAVE_TIMEOUT = 10000
+
+/**
+ * 工作簿实时协作组合式函数
+ * 本地执行的 Univer 变更命令提交到服务器，按服务器分配的修订号顺序应用其他协作者的命令；
+ * 尚未确认的本地命令在应用远端命令后重新执行，保证本地修改覆盖在较早的远端修改之上
+ */
+export function useWorkbookCollaboration() {
+  const connected = ref(false)
+  const collaborators = ref<CollaboratorPresence[]>([])
+  // 团队角色没有编辑权限：本地修改不提交到服务器
+  const readOnly = ref(false)
+
+  let options: WorkbookCollaborationOptions | null = null
+  let socket: WebSocket | null = null
+  let disposers: Array<() => void> = []
+  let reconnectTimer: ReturnType<typeof setTimeout> | null = null
+  let presenceTimer: ReturnType<typeof setTimeout> | null = null
+  let closedByUser = false
+
+  let appliedRevision = 0
+  let snapshotRevision = 0
+  let documentRevision = 0
+  let clientSeq = 0
+  // 已提交但尚未确认的本地命令
+  let pending: Array<{ seq: number; command: any }> = []
+  // 乱序到达的操作，own 为本地提交的命令（已在本地执行）
+  const buffered = new Map<number, { command?: any; own?: boolean }>()
+  // 本客户端历次连接的会话ID，用于在补发的操作中识别自己提交的命令
+  const ownSessions = new Set<string>()
+  let currentSession = ''
+  let resyncing = false
+  let applyingRemote = false
+  let snapshotWaiters: Array<{ revision: number; resolve: (saved: boolean) => void }> = []
+
+  const send = (message: Record<string, any>) => {
+    if (socket?.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify(message))
+    }
+  }
+
+  // 执行命令但不再提交到服务器
+  const executeSilently = (command: any) => {
+    applyingRemote = true
+    try {
+      options?.univerAPI.syncExecuteCommand(command.id, command.params, { onlyLocal: true, fromCollaboration: true })
+    } catch (error) {
+      console.error('应用协作命令失败:', command?.id, error)
+    } finally {
+      applyingRemote = false
+    }
+  }
+
+  // 按修订号顺序应用操作，缺失中间的操作时先缓存并请求补发
+  const receiveOperation = (revision: number, entry: { command?: any; own?: boolean }) => {
+    if (revision <= appliedRevision) {
+      return
+    }
+    buffered.set(revision, entry)
+    let applied = false
+    while (buffered.has(appliedRevision + 1)) {
+      const next = buffered.get(appliedRevision + 1)!
+      buffered.delete(appliedRevision + 1)
+      if (!next.own && next.command) {
+        executeSilently(next.command)
+        applied = true
+      }
+      appliedRevision++
+    }
+    if (applied) {
+      pending.forEach(item => executeSilently(item.command))
+    }
+    if (buffered.size > 0) {
+      send({ type: 'sync', revision: appliedRevision })
+    }
+  }
+
+  const upsertCollaborator = (presence: CollaboratorPresence) => {
+    const index = collaborators.value.findIndex(item => item.sessionId === presence.sessionId)
+    if (index > -1) {
+      collaborators.value.splice(index, 1, presence)
+    } else {
+      collaborators.value.push(presence)
+    }
+  }
+
+  const handleMessage = async (message: any) => {
+    switch (message.type) {
+      case 'welcome':
+        currentSession = message.sessionId
+        ownSessions.add(message.sessionId)
+        // 断线重连：还有未确认的本地命令，补发完成后判断哪些需要重新提交
+        resyncing = pending.length > 0
+        collaborators.value = message.users || []
+        readOnly.value = !!message.readOnly
+        connected.value = true
+        send({ type: 'sync', revision: appliedRevision })
+        break
+      case 'ops':
+        for (const op of message.ops || []) {
+          if (op.sessionId === currentSession) {
+            receiveOperation(op.revision, { own: true })
+          } else if (ownSessions.has(op.sessionId)) {
+            // 断线前提交、已写入日志但未收到确认的本地命令，按提交顺序对应
+            if (resyncing) {
+              pending.shift()
+            }
+            receiveOperation(op.revision, { own: true })
+          } else {
+            receiveOperation(op.revision, { command: op.command })
+          }
+        }
+        // 重新提交断线前未写入日志的本地命令
+        if (resyncing) {
+          resyncing = false
+          const unsent = pending
+          pending = []
+          unsent.forEach(item => submit(item.command))
+        }
+        break
+      case 'op':
+        receiveOperation(message.revision, { command: message.command })
+        break
+      case 'ack': {
+        const index = pending.findIndex(item => item.seq === message.clientSeq)
+        if (index > -1) {
+          pending.splice(index, 1)
+        }
+        receiveOperation(message.revision, { own: true })
+        break
+      }
+      case 'reload':
+        await options?.onReload()
+        break
+      case 'presence':
+        if (!ownSessions.has(message.sessionId)) {
+          upsertCollaborator({
+            sessionId: message.sessionId,
+            userId: message.userId,
+            userName: message.userName,
+            selection: message.selection
+          })
+        }
+        break
+      case 'leave':
+        collaborators.value = collaborators.value.filter(item => item.sessionId !== message.sessionId)
+        break
+      case 'snapshotRequest':
+        requestSnapshot()
+        break
+      case 'snapshotSaved':
+        snapshotRevision = Math.max(snapshotRevision, message.snapshotRevision || 0)
+        documentRevision = Math.max(documentRevision, message.documentRevision || 0)
+        options?.onSnapshotSaved?.(message.documentRevision)
+        snapshotWaiters = snapshotWaiters.filter(waiter => {
+          if (waiter.revision <= snapshotRevision) {
+            waiter.resolve(true)
+            return false
+          }
+          return true
+        })
+        break
+      case 'error':
+        console.warn('协作服务返回错误:', message.message)
+        ElMessage.warning(message.message || '协作同步失败')
+        break
+    }
+  }
+
+  // 提交包含全部已确认操作的内容快照；还有未确认的本地命令时快照不对应任何修订号，跳过本次请求
+  const requestSnapshot = (): number | null => {
+    if (!options || pending.length > 0 || buffered.size > 0 || appliedRevision <= snapshotRevision) {
+      return null
+    }
+    send({ type: 'snapshot', revision: appliedRevision, documentRevision, content: options.getSnapshot() })
+    return appliedRevision
+  }
+
+  const submit = (command: any) => {
+    const seq = ++clientSeq
+    pending.push({ seq, command })
+    send({ type: 'op', clientSeq: seq, command })
+  }
+
+  const sendPresence = (params: any) => {
+    if (presenceTimer) {
+      clearTimeout(presenceTimer)
+    }
+    presenceTimer = setTimeout(() => {
+      const range = params?.selections?.[0]?.range
+      send({ type: 'presence', selection: range ? { sheetId: params.subUnitId, range } : null })
+    }, PRESENCE_THROTTLE)
+  }
+
+  const listenCommands = () => {
+    const { univerAPI, fileId } = options!
+    const disposable = univerAPI.addEvent(univerAPI.Event.CommandExecuted, (event: any) => {
+      if (applyingRemote || event.options?.onlyLocal || event.options?.fromCollaboration) {
+        return
+      }
+      if (event.params?.unitId && event.params.unitId !== fileId) {
+        return
+      }
+      if (event.type === COMMAND_TYPE_MUTATION) {
+        if (readOnly.value) {
+          return
+        }
+        submit({ id: event.id, params: event.params })
+      } else if (event.type === COMMAND_TYPE_OPERATION && event.id === SET_SELECTIONS_OPERATION) {
+        sendPresence(event.params)
+      }
+    })
+    disposers.push(() => disposable?.dispose?.())
+  }
+
+  const openSocket = () => {
+    if (!options) {
+      return
+    }
+    socket = connectWorkbookCollaboration(options.fileId)
+    socket!.onmessage = (event: MessageEvent) => {
+      try {
+        handleMessage(JSON.parse(event.data))
+      } catch (error) {
+        console.error('处理协作消息失败:', error)
+      }
+    }
+    socket!.onclose = () => {
+      connected.value = false
+      collaborators.value = []
+      socket = null
+      if (!closedByUser) {
+        reconnectTimer = setTimeout(openSocket, RECONNECT_DELAY)
+      }
+    }
+  }
+
+  /**
+   * 加入工作簿的实时协作，已有连接时先断开
+   */
+  const connect = (connectOptions: WorkbookCollaborationOptions) => {
+    disconnect()
+    options = connectOptions
+    closedByUser = false
+    readOnly.value = false
+    appliedRevision = connectOptions.snapshotRevision || 0
+    snapshotRevision = appliedRevision
+    documentRevision = connectOptions.documentRevision || 0
+    pending = []
+    buffered.clear()
+    ownSessions.clear()
+    resyncing = false
+    listenCommands()
+    openSocket()
+  }
+
+  /**
+   * 离开实时协作
+   */
+  const disconnect = () => {
+    closedByUser = true
+    if (reconnectTimer) {
+      clearTimeout(reconnectTimer)
+      reconnectTimer = null
+    }
+    if (presenceTimer) {
+      clearTimeout(presenceTimer)
+      presenceTimer = null
+    }
+    disposers.forEach(dispose => dispose())
+    disposers = []
+    socket?.close()
+    socket = null
+    options = null
+    connected.value = false
+    collaborators.value = []
+    snapshotWaiters.forEach(waiter => waiter.resolve(false))
+    snapshotWaiters = []
+  }
+
+  /**
+   * 手动保存：提交内容快照并等待服务器保存
+   * 返回 unchanged 表示没有需要保存的修改，pending 表示还有修改在同步中
+   */
+  const saveSnapshot = (): Promise<'saved' | 'unchanged' | 'pending' | 'failed'> => {
+    if (appliedRevision <= snapshotRevision && pending.length === 0) {
+      return Promise.resolve('unchanged')
+    }
+    const revision = requestSnapshot()
+    if (revision === null) {
+      return Promise.resolve('pending')
+    }
+    return new Promise(resolve => {
+      const waiter = { revision, resolve: (saved: boolean) => resolve(saved ? 'saved' : 'failed') }
+      snapshotWaiters.push(waiter)
+      setTimeout(() => {
+        if (snapshotWaiters.includes(waiter)) {
+          snapshotWaiters = snapshotWaiters.filter(item => item !== waiter)
+          resolve('failed')
+        }
+      }, SNAPSHOT_SAVE_TIMEOUT)
+    })
+  }
+
+  return {
+    connected,
+    collaborators,
+    readOnly,
+    connect,
+    disconnect,
+    saveSnapshot
+  }
+}
@@ -657,6 +657,142 @@ export const functionSugarGetZhCN = {
   },
 }
 
+/**
+ * SUGAR.CONTRIBUTION 函数的中文本地化
+ */
+export const functionSugarContributionZhCN = {
+  formula: {
+    functionList: {
+      'SUGAR.CONTRIBUTION': {
+        description: '对语义模型的指标进行本期与基期的贡献度下钻分析，返回带表头的顶级贡献组合表，不调用大模型。',
+        abstract: '贡献度下钻分析',
+        links: [
+          {
+            title: '教学',
+            url: 'https://univer.ai',
+          },
+        ],
+        functionParameter: {
+          modelName: {
+            name: '模型名称',
+            detail: '语义模型的友好名称',
+          },
+          targetMetric: {
+            name: '目标指标',
+            detail: '需要分析变化原因的指标',
+          },
+          dimensions: {
+            name: '分析维度',
+            detail: '候选下钻维度，多个维度用逗号分隔',
+          },
+          currentFilters: {
+            name: '本期条件',
+            detail: '本期筛选条件，格式为：筛选列1:筛选值1;筛选列2:筛选值2',
+          },
+          baseFilters: {
+            name: '基期条件',
+            detail: '基期筛选条件，格式同本期条件',
+          },
+          config: {
+            name: '分析配置',
+            detail: '可选，覆盖默认分析配置，格式为：maxDrillDownLevels:3;topCombinationsCount:10；变化分解方法 decompositionMethod 可选 additive、lmdi、shapley、mix_rate，lmdi/shapley 需配置 factorMetrics（逗号分隔的因素指标），mix_rate 需配置 ratioNumerator、ratioDenominator',
+          },
+        },
+      },
+    },
+  },
+}
+
+/**
+ * SUGAR.ANOMALY 函数的中文本地化
+ */
+export const functionSugarAnomalyZhCN = {
+  formula: {
+    functionList: {
+      'SUGAR.ANOMALY': {
+        description: '对语义模型指标的时间序列进行异常值与变点检测，可按维度拆分，返回带表头的异常期与变点列表。',
+        abstract: '时间序列异常检测',
+        links: [
+          {
+            title: '教学',
+            url: 'https://univer.ai',
+          },
+        ],
+        functionParameter: {
+          modelName: {
+            name: '模型名称',
+            detail: '语义模型的友好名称，模型需声明分析语义中的日期列',
+          },
+          metric: {
+            name: '指标',
+            detail: '需要检测的指标',
+          },
+          startDate: {
+            name: '开始日期',
+            detail: '可选，检测区间的开始日期，格式为 YYYY-MM-DD',
+          },
+          endDate: {
+            name: '结束日期',
+            detail: '可选，检测区间的结束日期，格式为 YYYY-MM-DD',
+          },
+          dimensions: {
+            name: '拆分维度',
+            detail: '可选，按维度拆分为多条序列分别检测，多个维度用逗号分隔',
+          },
+          filters: {
+            name: '筛选条件',
+            detail: '可选，格式为：筛选列1:筛选值1;筛选列2:筛选值2',
+          },
+          config: {
+            name: '检测配置',
+            detail: '可选，格式为：grain:month;threshold:3.5；可配置 grain（day、week、month、quarter、year）、threshold、seasonLength、trendWindow、changePointPenalty、minSegmentLength、maxChangePoints',
+          },
+        },
+      },
+    },
+  },
+}
+
+/**
+ * 解析 "key:value;key:value" 格式的条件字符串
+ */
+const parseKeyValuePairs = (text: string): Record<string, string> => {
+  const result: Record<string, string> = {}
+  text.split(';').forEach(part => {
+    const [key, ...valueParts] = part.split(':')
+    const value = valueParts.join(':') // 处理值中可能包含冒号的情况
+    if (key && key.trim() && value.trim()) {
+      result[key.trim()] = value.trim()
+    }
+  })
+  return result
+}
+
+/**
+ * SUGAR.CONTRIBUTION 分析配置中的数值项、布尔项、字符串项和列表项（逗号分隔）
+ */
+const CONTRIBUTION_NUMBER_CONFIGS = [
+  'discriminationThreshold',
+  'minContributionThreshold',
+  'maxDrillDownLevels',
+  'topCombinationsCount',
+  'minTopCombinations',
+  'discriminationImprovementThreshold',
+]
+const CONTRIBUTION_BOOLEAN_CONFIGS = ['enableSmartStop']
+const CONTRIBUTION_STRING_CONFIGS = ['decompositionMethod', 'ratioNumerator', 'ratioDenominator']
+const CONTRIBUTION_LIST_CONFIGS = ['factorMetrics']
+
+// SUGAR.ANOMALY 配置项类型
+const ANOMALY_NUMBER_CONFIGS = [
+  'threshold',
+  'seasonLength',
+  'trendWindow',
+  'changePointPenalty',
+  'minSegmentLength',
+  'maxChangePoints',
+]
+
 /**
  * 公式刷新缓存管理
  */
@@ -1095,6 +1231,344 @@ export const dbFormulas = [
     },
     locales: functionSugarGetZhCN,
   },
+  {
+    name: 'SUGAR.CONTRIBUTION',
+    implementation: async (modelName: any, targetMetric: any, dimensions: any, currentFilters: any, baseFilters: any, config?: any) => {
+      // 参数验证：至少需要5个参数（模型名称、目标指标、分析维度、本期条件、基期条件）
+      if (!modelName || !targetMetric || !dimensions || !currentFilters || !baseFilters) {
+        return '#VALUE!'
+      }
+
+      // 检查是否为Excel错误值
+      const isExcelError = (value: any): boolean => {
+        if (typeof value === 'string') {
+          return /^#(NAME\?|VALUE!|REF!|DIV\/0!|NUM!|N\/A|NULL!)$/.test(value)
+        }
+        return false
+      }
+
+      // 处理单元格引用传入的嵌套数组，取第一个有效值
+      const extractFirstValue = (value: any): any => {
+        if (!Array.isArray(value)) {
+          return value
+        }
+        if (value.length === 0) {
+          return ''
+        }
+        return extractFirstValue(value[0])
+      }
+
+      const args = [modelName, targetMetric, dimensions, currentFilters, baseFilters, config].map(extractFirstValue)
+      const errorArg = args.find(isExcelError)
+      if (errorArg) {
+        return errorArg
+      }
+
+      const [modelNameStr, targetMetricStr, dimensionsStr, currentFiltersStr, baseFiltersStr, configStr] =
+        args.map(arg => (arg === null || arg === undefined ? '' : String(arg)))
+
+      // 解析分析维度（逗号分隔）
+      const dimensionList = dimensionsStr.split(',').map(dim => dim.trim()).filter(dim => dim)
+      if (dimensionList.length === 0) {
+        return '#VALUE!'
+      }
+
+      // 解析分析配置
+      const configObj: Record<string, any> = {}
+      const configPairs = parseKeyValuePairs(configStr)
+      for (const key of Object.keys(configPairs)) {
+        if (CONTRIBUTION_NUMBER_CONFIGS.includes(key)) {
+          const num = Number(configPairs[key])
+          if (Number.isNaN(num)) {
+            return '#VALUE!'
+          }
+          configObj[key] = num
+        } else if (CONTRIBUTION_BOOLEAN_CONFIGS.includes(key)) {
+          configObj[key] = ['true', '1', 'yes'].includes(configPairs[key].toLowerCase())
+        } else if (CONTRIBUTION_STRING_CONFIGS.includes(key)) {
+          configObj[key] = configPairs[key]
+        } else if (CONTRIBUTION_LIST_CONFIGS.includes(key)) {
+          configObj[key] = configPairs[key].split(',').map(item => item.trim()).filter(item => item)
+        } else {
+          return '#NAME?'
+        }
+      }
+
+      try {
+        // 构建请求数据
+        const requestData: Record<string, any> = {
+          modelName: modelNameStr,
+          targetMetric: targetMetricStr,
+          dimensions: dimensionList,
+          currentPeriodFilters: parseKeyValuePairs(currentFiltersStr),
+          basePeriodFilters: parseKeyValuePairs(baseFiltersStr),
+        }
+        if (Object.keys(configObj).length > 0) {
+          requestData.config = configObj
+        }
+
+        // 使用数据库公式管理器发送异步请求
+        const result = await databaseFormulaManager.executeDatabaseRequest(
+          '/api/sugarFormulaQuery/executeContribution',
+          requestData
+        )
+        if (result.code === 0 && result.data && result.data.columns) {
+          const columns: string[] = result.data.columns
+          const rows = (result.data.results || []).map((row: any) =>
+            columns.map(col => {
+              const val = row[col]
+              return val !== null && val !== undefined ? val : ''
+            })
+          )
+          // 第一行为表头，其后为按贡献度排序的顶级组合
+          return [columns, ...rows]
+        } else {
+          return result.msg || '#ERROR!'
+        }
+      } catch (error) {
+        console.error('SUGAR.CONTRIBUTION: 执行异常:', error)
+        if (error.name === 'TimeoutError') {
+          return '#TIMEOUT!'
+        } else if (error.name === 'AbortError') {
+          return '#ABORTED!'
+        } else {
+          return '#ERROR!'
+        }
+      }
+    },
+    config: {
+      isAsync: true, // 标记为异步函数
+      description: {
+        functionName: 'SUGAR.CONTRIBUTION',
+        description: '对语义模型的指标进行本期与基期的贡献度下钻分析，返回带表头的顶级贡献组合表，不调用大模型。',
+        abstract: '贡献度下钻分析',
+        functionParameter: [
+          {
+            name: '模型名称',
+            detail: '语义模型的友好名称',
+            example: '"业务指标查询"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '目标指标',
+            detail: '需要分析变化原因的指标',
+            example: '"指标金额"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '分析维度',
+            detail: '候选下钻维度，多个维度用逗号分隔',
+            example: '"战区名称,城市名称"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '本期条件',
+            detail: '本期筛选条件，格式为：筛选列1:筛选值1;筛选列2:筛选值2',
+            example: '"月份:2024-02"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '基期条件',
+            detail: '基期筛选条件，格式同本期条件',
+            example: '"月份:2024-01"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '分析配置',
+            detail: '可选，覆盖默认分析配置，格式为：maxDrillDownLevels:3;topCombinationsCount:10；变化分解方法 decompositionMethod 可选 additive、lmdi、shapley、mix_rate，lmdi/shapley 需配置 factorMetrics（逗号分隔的因素指标），mix_rate 需配置 ratioNumerator、ratioDenominator',
+            example: '"maxDrillDownLevels:2"',
+            require: 0,
+            repeat: 0,
+          },
+        ],
+      },
+      locales: {
+        zhCN: functionSugarContributionZhCN,
+      },
+    },
+    locales: functionSugarContributionZhCN,
+  },
+  {
+    name: 'SUGAR.ANOMALY',
+    implementation: async (modelName: any, metric: any, startDate?: any, endDate?: any, dimensions?: any, filters?: any, config?: any) => {
+      // 参数验证：至少需要模型名称和指标
+      if (!modelName || !metric) {
+        return '#VALUE!'
+      }
+
+      // 检查是否为Excel错误值
+      const isExcelError = (value: any): boolean => {
+        if (typeof value === 'string') {
+          return /^#(NAME\?|VALUE!|REF!|DIV\/0!|NUM!|N\/A|NULL!)$/.test(value)
+        }
+        return false
+      }
+
+      // 处理单元格引用传入的嵌套数组，取第一个有效值
+      const extractFirstValue = (value: any): any => {
+        if (!Array.isArray(value)) {
+          return value
+        }
+        if (value.length === 0) {
+          return ''
+        }
+        return extractFirstValue(value[0])
+      }
+
+      const args = [modelName, metric, startDate, endDate, dimensions, filters, config].map(extractFirstValue)
+      const errorArg = args.find(isExcelError)
+      if (errorArg) {
+        return errorArg
+      }
+
+      const [modelNameStr, metricStr, startDateStr, endDateStr, dimensionsStr, filtersStr, configStr] =
+        args.map(arg => (arg === null || arg === undefined ? '' : String(arg).trim()))
+
+      // 解析拆分维度（逗号分隔）
+      const dimensionList = dimensionsStr.split(',').map(dim => dim.trim()).filter(dim => dim)
+
+      // 解析检测配置，grain 为请求级参数，其余为检测算法参数
+      let grain = ''
+      const configObj: Record<string, any> = {}
+      const configPairs = parseKeyValuePairs(configStr)
+      for (const key of Object.keys(configPairs)) {
+        if (key === 'grain') {
+          grain = configPairs[key]
+        } else if (ANOMALY_NUMBER_CONFIGS.includes(key)) {
+          const num = Number(configPairs[key])
+          if (Number.isNaN(num)) {
+            return '#VALUE!'
+          }
+          configObj[key] = num
+        } else {
+          return '#NAME?'
+        }
+      }
+
+      try {
+        // 构建请求数据
+        const requestData: Record<string, any> = {
+          modelName: modelNameStr,
+          metric: metricStr,
+        }
+        if (dimensionList.length > 0) {
+          requestData.dimensions = dimensionList
+        }
+        const filterObj = parseKeyValuePairs(filtersStr)
+        if (Object.keys(filterObj).length > 0) {
+          requestData.filters = filterObj
+        }
+        if (startDateStr) {
+          requestData.startDate = startDateStr
+        }
+        if (endDateStr) {
+          requestData.endDate = endDateStr
+        }
+        if (grain) {
+          requestData.grain = grain
+        }
+        if (Object.keys(configObj).length > 0) {
+          requestData.config = configObj
+        }
+
+        // 使用数据库公式管理器发送异步请求
+        const result = await databaseFormulaManager.executeDatabaseRequest(
+          '/api/sugarFormulaQuery/executeAnomaly',
+          requestData
+        )
+        if (result.code === 0 && result.data && result.data.columns) {
+          const columns: string[] = result.data.columns
+          const rows = (result.data.results || []).map((row: any) =>
+            columns.map(col => {
+              const val = row[col]
+              return val !== null && val !== undefined ? val : ''
+            })
+          )
+          // 第一行为表头，其后为按期间排序的异常值与变点
+          return [columns, ...rows]
+        } else {
+          return result.msg || '#ERROR!'
+        }
+      } catch (error) {
+        console.error('SUGAR.ANOMALY: 执行异常:', error)
+        if (error.name === 'TimeoutError') {
+          return '#TIMEOUT!'
+        } else if (error.name === 'AbortError') {
+          return '#ABORTED!'
+        } else {
+          return '#ERROR!'
+        }
+      }
+    },
+    config: {
+      isAsync: true, // 标记为异步函数
+      description: {
+        functionName: 'SUGAR.ANOMALY',
+        description: '对语义模型指标的时间序列进行异常值与变点检测，可按维度拆分，返回带表头的异常期与变点列表。',
+        abstract: '时间序列异常检测',
+        functionParameter: [
+          {
+            name: '模型名称',
+            detail: '语义模型的友好名称，模型需声明分析语义中的日期列',
+            example: '"业务指标查询"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '指标',
+            detail: '需要检测的指标',
+            example: '"指标金额"',
+            require: 1,
+            repeat: 0,
+          },
+          {
+            name: '开始日期',
+            detail: '可选，检测区间的开始日期，格式为 YYYY-MM-DD',
+            example: '"2023-01-01"',
+            require: 0,
+            repeat: 0,
+          },
+          {
+            name: '结束日期',
+            detail: '可选，检测区间的结束日期，格式为 YYYY-MM-DD',
+            example: '"2024-12-31"',
+            require: 0,
+            repeat: 0,
+          },
+          {
+            name: '拆分维度',
+            detail: '可选，按维度拆分为多条序列分别检测，多个维度用逗号分隔',
+            example: '"城市名称"',
+            require: 0,
+            repeat: 0,
+          },
+          {
+            name: '筛选条件',
+            detail: '可选，格式为：筛选列1:筛选值1;筛选列2:筛选值2',
+            example: '"战区名称:华东"',
+            require: 0,
+            repeat: 0,
+          },
+          {
+            name: '检测配置',
+            detail: '可选，格式为：grain:month;threshold:3.5；可配置 grain（day、week、month、quarter、year）、threshold、seasonLength、trendWindow、changePointPenalty、minSegmentLength、maxChangePoints',
+            example: '"grain:month;threshold:3"',
+            require: 0,
+            repeat: 0,
+          },
+        ],
+      },
+      locales: {
+        zhCN: functionSugarAnomalyZhCN,
+      },
+    },
+    locales: functionSugarAnomalyZhCN,
+  },
 ];
 
 // 导出数据库公式管理器，供外部使用